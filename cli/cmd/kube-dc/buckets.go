@@ -0,0 +1,580 @@
+// `kube-dc buckets` — S3 buckets of the current Project, as the
+// objectbucket.io ObjectBucketClaims skills/manage-storage/
+// obc-template.yaml describes. A Bound claim has a same-name Secret
+// with the bucket's own key pair and a ConfigMap with its S3 name and
+// region; credentials reads both so nobody has to base64-decode them
+// by hand. The ConfigMap's BUCKET_HOST is the in-cluster RGW address,
+// so a workstation uses the installation's external S3 endpoint: from
+// --endpoint, else $S3_ENDPOINT, else s3.<domain>, the hostname the
+// installer exposes S3 on by default.
+//
+// Verbs:
+//   create       — POST an ObjectBucketClaim, wait for Bound         (k8s)
+//   list         — claims with S3 bucket name, class and phase       (k8s)
+//   delete       — DELETE the claim, and with it every object        (k8s)
+//   credentials  — the bucket's keys as env, rclone, s3cmd or AWS    (k8s)
+//   cp, sync     — files to and from the bucket (buckets_transfer.go) (k8s + S3)
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+	"github.com/shalb/kube-dc/cli/internal/s3"
+	"github.com/spf13/cobra"
+)
+
+// bucketPollInterval is how often create re-reads the claim. A var so
+// tests can shorten it.
+var bucketPollInterval = 2 * time.Second
+
+var validCredentialFormats = []string{"env", "rclone", "s3cmd", "aws"}
+
+func bucketsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "buckets",
+		Aliases: []string{"bucket"},
+		Short:   "Manage the Project's S3 buckets",
+		Long: `Create and delete S3 buckets (ObjectBucketClaims) in the current Project, print
+their credentials for common S3 tools, and copy files to and from them.
+
+Each bucket has its own key pair, valid for that bucket only. Its S3 name is
+the claim name prefixed with the Project's backing namespace, e.g. bucket
+assets in acme-web is acme-web-assets.`,
+	}
+	cmd.AddCommand(bucketsCreateCmd())
+	cmd.AddCommand(bucketsListCmd())
+	cmd.AddCommand(bucketsDeleteCmd())
+	cmd.AddCommand(bucketsCredentialsCmd())
+	cmd.AddCommand(bucketsCpCmd())
+	cmd.AddCommand(bucketsSyncCmd())
+	return cmd
+}
+
+// -------- create ---------------------------------------------------
+
+func bucketsCreateCmd() *cobra.Command {
+	var namespace, class, org string
+	var noWait bool
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create an S3 bucket",
+		Long: `Create an ObjectBucketClaim and wait until it is Bound.
+
+The claim is labelled with the owning Organization (the current context's,
+or --org) so the console attributes it and counts it in the Organization's
+object storage usage. Without --storage-class, the one bucket StorageClass the
+installation offers is used.`,
+		Example: `  kube-dc buckets create assets
+  kube-dc buckets create backups --storage-class ceph-bucket`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if !projectNameRE.MatchString(name) {
+				return fmt.Errorf("invalid name %q: must be a DNS label (lowercase letters, digits, '-')", name)
+			}
+			org = fmtCoalesce(org, readCurrentRealm())
+			if org == "" || org == adminRealm {
+				return fmt.Errorf("pass --org: the bucket must be labelled with the Organization that owns the Project")
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			if class == "" {
+				list, err := cli.ListStorageClasses(ctx)
+				if err != nil {
+					return fmt.Errorf("list StorageClasses: %w", err)
+				}
+				if class, err = pickBucketClass(list.Items); err != nil {
+					return err
+				}
+			}
+			obc, err := cli.CreateObjectBucketClaim(ctx, &k8sapi.ObjectBucketClaim{
+				Metadata: k8sapi.ObjectMeta{Name: name, Namespace: scope.Namespace, Labels: map[string]string{k8sapi.OrganizationLabel: org}},
+				Spec:     k8sapi.ObjectBucketClaimSpec{BucketName: scope.Namespace + "-" + name, StorageClassName: class},
+			})
+			if err != nil {
+				return err
+			}
+			if noWait {
+				fmt.Printf("Requested bucket %s (%s)\n", name, obc.Spec.BucketName)
+				return nil
+			}
+			if _, err := waitForBucket(context.Background(), cli, scope.Namespace, name, timeout); err != nil {
+				return err
+			}
+			fmt.Printf("Created bucket %s (%s); see `kube-dc buckets credentials %s`\n", name, obc.Spec.BucketName, name)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVar(&class, "storage-class", "", "Bucket StorageClass (default: the installation's only bucket class)")
+	cmd.Flags().StringVar(&org, "org", "", "Owning Organization for the claim's label (default: the current context's)")
+	cmd.Flags().BoolVar(&noWait, "no-wait", false, "Return once the claim is created, without waiting for Bound")
+	cmd.Flags().DurationVar(&timeout, "timeout", 2*time.Minute, "How long to wait for the bucket")
+	return cmd
+}
+
+// pickBucketClass returns the single StorageClass backed by a bucket
+// provisioner (Rook's is <namespace>.ceph.rook.io/bucket).
+func pickBucketClass(classes []k8sapi.StorageClass) (string, error) {
+	var names []string
+	for _, sc := range classes {
+		if strings.HasSuffix(sc.Provisioner, "/bucket") {
+			names = append(names, sc.Metadata.Name)
+		}
+	}
+	sort.Strings(names)
+	switch len(names) {
+	case 0:
+		return "", fmt.Errorf("this installation offers no bucket StorageClass; object storage may not be enabled")
+	case 1:
+		return names[0], nil
+	}
+	return "", fmt.Errorf("several bucket StorageClasses (%s); pick one with --storage-class", strings.Join(names, ", "))
+}
+
+// waitForBucket polls until the claim is Bound; Failed ends it early.
+func waitForBucket(ctx context.Context, cli *k8sapi.Client, ns, name string, timeout time.Duration) (*k8sapi.ObjectBucketClaim, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	phase := ""
+	for {
+		reqCtx, reqCancel := context.WithTimeout(ctx, 30*time.Second)
+		obc, err := cli.GetObjectBucketClaim(reqCtx, ns, name)
+		reqCancel()
+		switch {
+		case err == nil:
+			phase = obc.Status.Phase
+			if phase == k8sapi.ObjectBucketClaimBound {
+				return obc, nil
+			}
+			if phase == "Failed" {
+				return nil, fmt.Errorf("bucket %s failed to provision; check `kubectl describe obc %s -n %s`", name, name, ns)
+			}
+		case k8sapi.IsNotFound(err):
+			return nil, fmt.Errorf("bucket %s not found", name)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timed out after %s waiting for bucket %s to be Bound (phase %s)", timeout, name, fmtCoalesce(phase, "unknown"))
+		case <-time.After(bucketPollInterval):
+		}
+	}
+}
+
+// -------- list -----------------------------------------------------
+
+func bucketsListCmd() *cobra.Command {
+	var namespace, outFlag string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List S3 buckets in the current Project",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			list, err := cli.ListObjectBucketClaims(ctx, scope.Namespace)
+			if err != nil {
+				return err
+			}
+			if out != outTable {
+				return printSerialized(out, list)
+			}
+			if len(list.Items) == 0 {
+				fmt.Printf("No buckets in %s\n", scope.Namespace)
+				return nil
+			}
+			sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Metadata.Name < list.Items[j].Metadata.Name })
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "NAME\tBUCKET\tCLASS\tPHASE\tAGE")
+			for _, b := range list.Items {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", b.Metadata.Name, fmtCoalesce(b.Spec.BucketName, "-"),
+					fmtCoalesce(b.Spec.StorageClassName, "-"), fmtCoalesce(b.Status.Phase, "Pending"), formatAge(b.Metadata.CreationTimestamp))
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+// -------- delete ---------------------------------------------------
+
+func bucketsDeleteCmd() *cobra.Command {
+	var namespace string
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete an S3 bucket and every object in it",
+		Long: `Delete an ObjectBucketClaim. The provisioner deletes the bucket with it,
+including every object, and the bucket's keys stop working. This cannot be
+undone; copy anything worth keeping first with ` + "`kube-dc buckets sync`" + `.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if !yes {
+				fmt.Fprintf(os.Stderr, "Delete bucket %s and every object in it? Re-run with --yes to confirm.\n", name)
+				return fmt.Errorf("not confirmed")
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			if err := cli.DeleteObjectBucketClaim(ctx, scope.Namespace, name); err != nil {
+				return err
+			}
+			fmt.Printf("Deleted bucket %s\n", name)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().BoolVar(&yes, "yes", false, "Confirm the deletion")
+	return cmd
+}
+
+// -------- credentials ----------------------------------------------
+
+// bucketCredentials is what a client needs for one bucket.
+type bucketCredentials struct {
+	Name      string // the claim
+	Bucket    string // the S3 bucket name
+	Endpoint  string
+	Region    string
+	AccessKey string
+	SecretKey string
+}
+
+// bucketEndpoint picks the external S3 endpoint: the flag, then
+// $S3_ENDPOINT, then the installer's default s3.<domain>.
+func bucketEndpoint(flag, domain string) string {
+	return strings.TrimRight(fmtCoalesce(flag, os.Getenv("S3_ENDPOINT"), "https://s3."+domain), "/")
+}
+
+// loadBucketCredentials reads a Bound claim's Secret and ConfigMap.
+func loadBucketCredentials(ctx context.Context, cli *k8sapi.Client, ns, name, endpoint string) (*bucketCredentials, error) {
+	obc, err := cli.GetObjectBucketClaim(ctx, ns, name)
+	if err != nil {
+		if k8sapi.IsNotFound(err) {
+			return nil, fmt.Errorf("no bucket named %s in %s; see `kube-dc buckets list`", name, ns)
+		}
+		return nil, err
+	}
+	if obc.Status.Phase != k8sapi.ObjectBucketClaimBound {
+		return nil, fmt.Errorf("bucket %s is %s; its credentials appear once it is Bound", name, fmtCoalesce(obc.Status.Phase, "Pending"))
+	}
+	secret, err := cli.GetSecret(ctx, ns, name)
+	if err != nil {
+		return nil, fmt.Errorf("read bucket %s credentials: %w", name, err)
+	}
+	ak, err := secret.Value("AWS_ACCESS_KEY_ID")
+	if err != nil {
+		return nil, err
+	}
+	sk, err := secret.Value("AWS_SECRET_ACCESS_KEY")
+	if err != nil {
+		return nil, err
+	}
+	cm, err := cli.GetConfigMap(ctx, ns, name)
+	if err != nil {
+		return nil, fmt.Errorf("read bucket %s connection info: %w", name, err)
+	}
+	return &bucketCredentials{
+		Name:      name,
+		Bucket:    fmtCoalesce(cm.Data["BUCKET_NAME"], obc.Spec.BucketName),
+		Endpoint:  endpoint,
+		Region:    fmtCoalesce(cm.Data["BUCKET_REGION"], s3.DefaultRegion),
+		AccessKey: string(ak),
+		SecretKey: string(sk),
+	}, nil
+}
+
+func bucketsCredentialsCmd() *cobra.Command {
+	var namespace, format, profile, endpoint, writeProfile string
+	cmd := &cobra.Command{
+		Use:   "credentials <name>",
+		Short: "Print a bucket's S3 credentials for env, rclone, s3cmd or the AWS CLI",
+		Long: `Print the bucket's own access key, endpoint and S3 bucket name.
+
+  env      export lines for a shell (AWS_*, S3_ENDPOINT, BUCKET_NAME)
+  rclone   a remote section for rclone.conf
+  s3cmd    a .s3cfg file
+  aws      a profile for ~/.aws/credentials and ~/.aws/config
+
+--write-aws-profile writes the profile straight into the AWS CLI's files,
+replacing the keys of a profile with that name and leaving the rest alone.
+
+The endpoint is the installation's external S3 address: --endpoint, else
+$S3_ENDPOINT, else https://s3.<domain>. The output contains the secret key.`,
+		Example: `  eval "$(kube-dc buckets credentials assets)"
+  kube-dc buckets credentials assets --format rclone >> ~/.config/rclone/rclone.conf
+  kube-dc buckets credentials assets --write-aws-profile acme-assets`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if !containsString(validCredentialFormats, format) {
+				return fmt.Errorf("invalid --format %q (want %s)", format, strings.Join(validCredentialFormats, "|"))
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			creds, err := loadBucketCredentials(ctx, cli, scope.Namespace, name, bucketEndpoint(endpoint, scope.Domain))
+			if err != nil {
+				return err
+			}
+			if writeProfile != "" {
+				credsFile, configFile, err := writeAWSProfile(writeProfile, creds)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Wrote profile %s to %s and %s\n", writeProfile, credsFile, configFile)
+				fmt.Printf("  aws --profile %s s3 ls s3://%s/\n", writeProfile, creds.Bucket)
+				return nil
+			}
+			fmt.Print(renderBucketCredentials(format, fmtCoalesce(profile, name), creds))
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVar(&format, "format", "env", "Output format: env|rclone|s3cmd|aws")
+	cmd.Flags().StringVar(&profile, "profile", "", "rclone remote or AWS profile name in the output (default: the bucket name)")
+	cmd.Flags().StringVar(&endpoint, "endpoint", "", "External S3 endpoint (default: $S3_ENDPOINT, else https://s3.<domain>)")
+	cmd.Flags().StringVar(&writeProfile, "write-aws-profile", "", "Write the credentials as this AWS CLI profile instead of printing them")
+	return cmd
+}
+
+// renderBucketCredentials formats creds; profile names the rclone
+// remote or the AWS profile.
+func renderBucketCredentials(format, profile string, c *bucketCredentials) string {
+	var b strings.Builder
+	switch format {
+	case "env":
+		for _, kv := range [][2]string{
+			{"AWS_ACCESS_KEY_ID", c.AccessKey},
+			{"AWS_SECRET_ACCESS_KEY", c.SecretKey},
+			{"AWS_DEFAULT_REGION", c.Region},
+			{"AWS_ENDPOINT_URL", c.Endpoint},
+			{"S3_ENDPOINT", c.Endpoint},
+			{"BUCKET_NAME", c.Bucket},
+		} {
+			fmt.Fprintf(&b, "export %s=%s\n", kv[0], shellQuote(kv[1]))
+		}
+	case "rclone":
+		fmt.Fprintf(&b, "[%s]\ntype = s3\nprovider = Ceph\naccess_key_id = %s\nsecret_access_key = %s\nendpoint = %s\nregion = %s\n",
+			profile, c.AccessKey, c.SecretKey, c.Endpoint, c.Region)
+		fmt.Fprintf(&b, "# rclone ls %s:%s\n", profile, c.Bucket)
+	case "s3cmd":
+		host, https := endpointHost(c.Endpoint)
+		// host_bucket without %(bucket)s keeps s3cmd on path-style
+		// addressing, which needs no wildcard DNS.
+		fmt.Fprintf(&b, "[default]\naccess_key = %s\nsecret_key = %s\nhost_base = %s\nhost_bucket = %s\nuse_https = %s\nbucket_location = %s\n",
+			c.AccessKey, c.SecretKey, host, host, map[bool]string{true: "True", false: "False"}[https], c.Region)
+		fmt.Fprintf(&b, "# s3cmd -c <this file> ls s3://%s/\n", c.Bucket)
+	case "aws":
+		fmt.Fprintf(&b, "# ~/.aws/credentials\n[%s]\n", profile)
+		for _, kv := range awsCredentialKeys(c) {
+			fmt.Fprintf(&b, "%s = %s\n", kv[0], kv[1])
+		}
+		fmt.Fprintf(&b, "\n# ~/.aws/config\n[%s]\n", awsConfigSection(profile))
+		for _, kv := range awsConfigKeys(c) {
+			fmt.Fprintf(&b, "%s = %s\n", kv[0], kv[1])
+		}
+		fmt.Fprintf(&b, "\n# aws --profile %s s3 ls s3://%s/\n", profile, c.Bucket)
+	}
+	return b.String()
+}
+
+func endpointHost(endpoint string) (string, bool) {
+	host := strings.TrimPrefix(strings.TrimPrefix(endpoint, "https://"), "http://")
+	return strings.TrimRight(host, "/"), !strings.HasPrefix(endpoint, "http://")
+}
+
+func awsCredentialKeys(c *bucketCredentials) [][2]string {
+	return [][2]string{{"aws_access_key_id", c.AccessKey}, {"aws_secret_access_key", c.SecretKey}}
+}
+
+// awsConfigKeys go to ~/.aws/config: the AWS CLI reads the endpoint
+// and region only from there.
+func awsConfigKeys(c *bucketCredentials) [][2]string {
+	return [][2]string{{"region", c.Region}, {"endpoint_url", c.Endpoint}}
+}
+
+// awsConfigSection is the profile's section name in ~/.aws/config,
+// where every profile but default carries a "profile " prefix.
+func awsConfigSection(profile string) string {
+	if profile == "default" {
+		return profile
+	}
+	return "profile " + profile
+}
+
+// writeAWSProfile upserts the profile into the AWS CLI's credentials
+// and config files, honouring AWS_SHARED_CREDENTIALS_FILE and
+// AWS_CONFIG_FILE as the CLI does.
+func writeAWSProfile(profile string, c *bucketCredentials) (string, string, error) {
+	if strings.ContainsAny(profile, "[] \t") {
+		return "", "", fmt.Errorf("invalid profile name %q", profile)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", "", err
+	}
+	credsFile := fmtCoalesce(os.Getenv("AWS_SHARED_CREDENTIALS_FILE"), filepath.Join(home, ".aws", "credentials"))
+	configFile := fmtCoalesce(os.Getenv("AWS_CONFIG_FILE"), filepath.Join(home, ".aws", "config"))
+	if err := upsertINIFile(credsFile, profile, awsCredentialKeys(c)); err != nil {
+		return "", "", err
+	}
+	if err := upsertINIFile(configFile, awsConfigSection(profile), awsConfigKeys(c)); err != nil {
+		return "", "", err
+	}
+	return credsFile, configFile, nil
+}
+
+// upsertINIFile rewrites path with section updated, atomically. A
+// symlinked file (dotfile managers link ~/.aws/credentials) is written
+// at its target so the link survives.
+func upsertINIFile(path, section string, kv [][2]string) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.WriteString(upsertINISection(string(raw), section, kv)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// upsertINISection sets kv inside [section] of an INI document,
+// replacing those keys where present and keeping every other line,
+// comments included. A missing section is appended.
+func upsertINISection(doc, section string, kv [][2]string) string {
+	lines := strings.Split(strings.TrimRight(doc, "\n"), "\n")
+	if doc == "" {
+		lines = nil
+	}
+	start, end := -1, len(lines)
+	for i, l := range lines {
+		t := strings.TrimSpace(l)
+		if start < 0 && t == "["+section+"]" {
+			start = i
+			continue
+		}
+		if start >= 0 && strings.HasPrefix(t, "[") {
+			end = i
+			break
+		}
+	}
+	if start < 0 {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "["+section+"]")
+		for _, p := range kv {
+			lines = append(lines, p[0]+" = "+p[1])
+		}
+		return strings.Join(lines, "\n") + "\n"
+	}
+
+	body := lines[start+1 : end]
+	done := map[string]bool{}
+	var out []string
+	for _, l := range body {
+		key, _, found := strings.Cut(l, "=")
+		key = strings.TrimSpace(key)
+		idx := -1
+		for i, p := range kv {
+			if found && p[0] == key {
+				idx = i
+			}
+		}
+		switch {
+		case idx < 0:
+			out = append(out, l)
+		case !done[key]:
+			out = append(out, key+" = "+kv[idx][1])
+			done[key] = true
+		}
+	}
+	// New keys go after the last non-blank line of the section.
+	insert := len(out)
+	for insert > 0 && strings.TrimSpace(out[insert-1]) == "" {
+		insert--
+	}
+	var added []string
+	for _, p := range kv {
+		if !done[p[0]] {
+			added = append(added, p[0]+" = "+p[1])
+		}
+	}
+	out = append(out[:insert], append(added, out[insert:]...)...)
+
+	result := append(append(append([]string{}, lines[:start+1]...), out...), lines[end:]...)
+	return strings.Join(result, "\n") + "\n"
+}
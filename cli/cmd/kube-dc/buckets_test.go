@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+	"github.com/shalb/kube-dc/cli/internal/s3"
+	"github.com/shalb/kube-dc/cli/internal/s3/s3test"
+)
+
+func TestPickBucketClass(t *testing.T) {
+	sc := func(name, prov string) k8sapi.StorageClass {
+		return k8sapi.StorageClass{Metadata: k8sapi.ObjectMeta{Name: name}, Provisioner: prov}
+	}
+	classes := []k8sapi.StorageClass{sc("local-path", "rancher.io/local-path"), sc("ceph-bucket", "rook-ceph.ceph.rook.io/bucket")}
+	if got, err := pickBucketClass(classes); err != nil || got != "ceph-bucket" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	if _, err := pickBucketClass(classes[:1]); err == nil || !strings.Contains(err.Error(), "no bucket StorageClass") {
+		t.Fatalf("none: %v", err)
+	}
+	classes = append(classes, sc("ceph-bucket-ec", "rook-ceph.ceph.rook.io/bucket"))
+	if _, err := pickBucketClass(classes); err == nil || !strings.Contains(err.Error(), "ceph-bucket, ceph-bucket-ec") {
+		t.Fatalf("several: %v", err)
+	}
+}
+
+func testBucketCredentials() *bucketCredentials {
+	return &bucketCredentials{Name: "assets", Bucket: "acme-web-assets", Endpoint: "https://s3.kube-dc.cloud",
+		Region: "us-east-1", AccessKey: "AKEXAMPLE", SecretKey: "sk/with'quote"}
+}
+
+func TestRenderBucketCredentials(t *testing.T) {
+	c := testBucketCredentials()
+	env := renderBucketCredentials("env", "assets", c)
+	for _, want := range []string{
+		"export AWS_ACCESS_KEY_ID=AKEXAMPLE\n",
+		`export AWS_SECRET_ACCESS_KEY='sk/with'\''quote'` + "\n",
+		"export AWS_ENDPOINT_URL=https://s3.kube-dc.cloud\n",
+		"export BUCKET_NAME=acme-web-assets\n",
+	} {
+		if !strings.Contains(env, want) {
+			t.Errorf("env output lacks %q:\n%s", want, env)
+		}
+	}
+	if s := renderBucketCredentials("s3cmd", "assets", c); !strings.Contains(s, "host_bucket = s3.kube-dc.cloud\nuse_https = True\n") {
+		t.Errorf("s3cmd must stay path-style over https:\n%s", s)
+	}
+	if s := renderBucketCredentials("rclone", "assets", c); !strings.HasPrefix(s, "[assets]\ntype = s3\nprovider = Ceph\n") {
+		t.Errorf("rclone:\n%s", s)
+	}
+	aws := renderBucketCredentials("aws", "assets", c)
+	if !strings.Contains(aws, "[profile assets]\nregion = us-east-1\nendpoint_url = https://s3.kube-dc.cloud\n") {
+		t.Errorf("aws config section:\n%s", aws)
+	}
+}
+
+func TestUpsertINISection(t *testing.T) {
+	doc := "[default]\naws_access_key_id = OLD\n\n[assets]\n# rotated monthly\naws_access_key_id = OLD\nextra = keep\naws_access_key_id = DUP\n\n[other]\nx = 1\n"
+	kv := [][2]string{{"aws_access_key_id", "NEW"}, {"aws_secret_access_key", "SECRET"}}
+	got := upsertINISection(doc, "assets", kv)
+	want := "[default]\naws_access_key_id = OLD\n\n[assets]\n# rotated monthly\naws_access_key_id = NEW\nextra = keep\naws_secret_access_key = SECRET\n\n[other]\nx = 1\n"
+	if got != want {
+		t.Fatalf("update:\n%s\nwant:\n%s", got, want)
+	}
+	if again := upsertINISection(got, "assets", kv); again != got {
+		t.Fatalf("not idempotent:\n%s", again)
+	}
+	if got := upsertINISection("", "assets", kv[:1]); got != "[assets]\naws_access_key_id = NEW\n" {
+		t.Fatalf("empty file: %q", got)
+	}
+	if got := upsertINISection("[default]\na = 1\n", "assets", kv[:1]); got != "[default]\na = 1\n\n[assets]\naws_access_key_id = NEW\n" {
+		t.Fatalf("append: %q", got)
+	}
+}
+
+func TestWriteAWSProfile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "aws", "credentials"))
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "aws", "config"))
+	credsFile, configFile, err := writeAWSProfile("assets", testBucketCredentials())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(credsFile)
+	if err != nil || fi.Mode().Perm() != 0o600 {
+		t.Fatalf("credentials file: %v, %v", fi, err)
+	}
+	raw, _ := os.ReadFile(configFile)
+	if !strings.HasPrefix(string(raw), "[profile assets]\n") {
+		t.Fatalf("config:\n%s", raw)
+	}
+	if _, _, err := writeAWSProfile("my profile", testBucketCredentials()); err == nil {
+		t.Fatal("a profile name with a space must be refused")
+	}
+}
+
+func TestUpsertINIFile_KeepsSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "dotfiles", "credentials")
+	if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(target, []byte("[default]\naws_access_key_id = OLD\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "credentials")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	if err := upsertINIFile(link, "assets", [][2]string{{"aws_access_key_id", "NEW"}}); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("the symlink was replaced: %v, %v", fi, err)
+	}
+	raw, _ := os.ReadFile(target)
+	if !strings.Contains(string(raw), "[default]") || !strings.Contains(string(raw), "[assets]") {
+		t.Errorf("target:\n%s", raw)
+	}
+}
+
+func TestParseBucketRef(t *testing.T) {
+	tests := []struct {
+		arg    string
+		want   bucketRef
+		remote bool
+	}{
+		{"assets:site/index.html", bucketRef{Name: "assets", Key: "site/index.html"}, true},
+		{"assets:", bucketRef{Name: "assets"}, true},
+		{"assets:/site/", bucketRef{Name: "assets", Key: "site/"}, true},
+		{"./assets:x", bucketRef{}, false},
+		{"/tmp/a:b", bucketRef{}, false},
+		{`C:\backup`, bucketRef{}, false},
+		{"dump.sql", bucketRef{}, false},
+	}
+	for _, tt := range tests {
+		got, remote := parseBucketRef(tt.arg)
+		if got != tt.want || remote != tt.remote {
+			t.Errorf("%s: %+v %v", tt.arg, got, remote)
+		}
+	}
+	if _, _, _, err := transferArgs("a.txt", "b.txt"); err == nil {
+		t.Error("local to local must be refused")
+	}
+	if _, _, _, err := transferArgs("assets:a", "backups:a"); err == nil {
+		t.Error("bucket to bucket must be refused")
+	}
+}
+
+func TestPlanSync(t *testing.T) {
+	old, now := time.Unix(1_700_000_000, 0), time.Unix(1_800_000_000, 0)
+	local := map[string]syncEntry{
+		"same.txt":    {Size: 5, ModTime: old},
+		"edited.txt":  {Size: 5, ModTime: old},
+		"grown.txt":   {Size: 9, ModTime: old},
+		"new.txt":     {Size: 1, ModTime: old},
+		"big-old.bin": {Size: 100, ModTime: old},
+		"big-new.bin": {Size: 100, ModTime: now},
+	}
+	remote := map[string]syncEntry{
+		"same.txt":    {Size: 5, ModTime: now, ETag: "d41d8cd9"},
+		"edited.txt":  {Size: 5, ModTime: now, ETag: "0cc175b9"},
+		"grown.txt":   {Size: 5, ModTime: now, ETag: "x"},
+		"big-old.bin": {Size: 100, ModTime: old.Add(time.Hour), ETag: "abc-2"},
+		"big-new.bin": {Size: 100, ModTime: old, ETag: "abc-2"},
+		"gone.txt":    {Size: 1, ModTime: old, ETag: "y"},
+	}
+	sums := map[string]string{"same.txt": "d41d8cd9", "edited.txt": "92eb5ffe"}
+	md5 := func(rel string) (string, error) { return sums[rel], nil }
+	ops := func(plan []syncAction) string {
+		var s []string
+		for _, a := range plan {
+			s = append(s, a.Op+" "+a.Rel)
+		}
+		return strings.Join(s, ",")
+	}
+
+	up, err := planSync(local, remote, true, true, md5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ops(up); got != "copy big-new.bin,copy edited.txt,copy grown.txt,copy new.txt,delete gone.txt" {
+		t.Errorf("upload plan = %s", got)
+	}
+	down, err := planSync(remote, local, false, false, md5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ops(down); got != "copy big-old.bin,copy edited.txt,copy gone.txt,copy grown.txt" {
+		t.Errorf("download plan = %s", got)
+	}
+}
+
+func testBucket(t *testing.T) (*s3test.Server, *bucketClient) {
+	t.Helper()
+	srv := s3test.New("AK", "acme-web-assets")
+	t.Cleanup(srv.Close)
+	c, err := s3.New(srv.URL, "", "AK", "SK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.PartSize = 5 << 20
+	return srv, &bucketClient{s3: c, Name: "assets", Bucket: "acme-web-assets"}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for rel, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSyncBucketRoundTrip(t *testing.T) {
+	srv, b := testBucket(t)
+	ctx := context.Background()
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"index.html":     "<h1>hi</h1>",
+		"css/site.css":   "body{}",
+		"img/logo.png":   strings.Repeat("p", 6<<20),
+		"img/.gitignore": "",
+	})
+	srv.Put("acme-web-assets", "site/stale.html", []byte("old"), time.Now())
+	srv.Put("acme-web-assets", "other/keep.txt", []byte("x"), time.Now())
+
+	var out bytes.Buffer
+	if err := syncBucket(ctx, b, src, "site", true, syncOptions{Delete: true, DryRun: true}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(srv.Keys("acme-web-assets")) != 2 || !strings.Contains(out.String(), "(dry run) delete stale.html") {
+		t.Fatalf("dry run changed the bucket or missed the delete:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := syncBucket(ctx, b, src, "site", true, syncOptions{Delete: true}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(srv.Keys("acme-web-assets"), ","); got != "other/keep.txt,site/css/site.css,site/img/.gitignore,site/img/logo.png,site/index.html" {
+		t.Fatalf("bucket after upload: %s\n%s", got, out.String())
+	}
+	if !strings.Contains(out.String(), "4 copied") || !strings.Contains(out.String(), "1 deleted") {
+		t.Fatalf("summary:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := syncBucket(ctx, b, src, "site/", true, syncOptions{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "0 copied") {
+		t.Fatalf("second upload should be a no-op:\n%s", out.String())
+	}
+
+	dst := t.TempDir()
+	out.Reset()
+	if err := syncBucket(ctx, b, dst, "site/", false, syncOptions{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(filepath.Join(dst, "img", "logo.png"))
+	if err != nil || len(raw) != 6<<20 {
+		t.Fatalf("downloaded multipart object: %d bytes, %v", len(raw), err)
+	}
+	out.Reset()
+	if err := syncBucket(ctx, b, dst, "site/", false, syncOptions{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "0 copied") {
+		t.Fatalf("second download should be a no-op:\n%s", out.String())
+	}
+}
+
+// Object keys are untrusted: one that climbs out of the prefix must not
+// turn into a path outside the directory being synced.
+func TestSyncBucketRejectsEscapingKeys(t *testing.T) {
+	for _, key := range []string{"site/../../escaped.txt", "site/a/../../../escaped.txt", "site//etc/escaped.txt"} {
+		srv, b := testBucket(t)
+		parent := t.TempDir()
+		dst := filepath.Join(parent, "dst")
+		srv.Put("acme-web-assets", "site/ok.txt", []byte("ok"), time.Now())
+		srv.Put("acme-web-assets", key, []byte("pwned"), time.Now())
+
+		var out bytes.Buffer
+		err := syncBucket(context.Background(), b, dst, "site/", false, syncOptions{}, &out)
+		if err == nil || !strings.Contains(err.Error(), key) {
+			t.Errorf("%s: err = %v", key, err)
+		}
+		if _, err := os.Stat(filepath.Join(parent, "escaped.txt")); !os.IsNotExist(err) {
+			t.Errorf("%s: a file was written outside the sync directory", key)
+		}
+		if _, err := os.Stat(dst); !os.IsNotExist(err) {
+			t.Errorf("%s: the sync went ahead", key)
+		}
+	}
+}
+
+func TestCopyUpDown(t *testing.T) {
+	srv, b := testBucket(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"dump.sql": "select 1;"})
+
+	var out bytes.Buffer
+	if err := copyUp(ctx, b, filepath.Join(dir, "dump.sql"), "db/", &out); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.Keys("acme-web-assets"); len(got) != 1 || got[0] != "db/dump.sql" {
+		t.Fatalf("keys: %v", got)
+	}
+	if err := copyUp(ctx, b, dir, "db/", &out); err == nil || !strings.Contains(err.Error(), "buckets sync") {
+		t.Fatalf("directory upload: %v", err)
+	}
+
+	restore := filepath.Join(t.TempDir(), "restore")
+	if err := os.Mkdir(restore, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := copyDown(ctx, b, "db/dump.sql", restore, &out); err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := os.ReadFile(filepath.Join(restore, "dump.sql")); string(raw) != "select 1;" {
+		t.Fatalf("downloaded %q", raw)
+	}
+	if err := copyDown(ctx, b, "db/missing.sql", filepath.Join(restore, "missing.sql"), &out); !s3.IsNotFound(err) {
+		t.Fatalf("missing key: %v", err)
+	}
+	if entries, _ := os.ReadDir(restore); len(entries) != 1 {
+		t.Fatalf("a failed download left files behind: %v", entries)
+	}
+}
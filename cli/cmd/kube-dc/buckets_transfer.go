@@ -0,0 +1,466 @@
+// `kube-dc buckets cp` and `sync` — copy files between this machine
+// and a Project bucket over S3 with the bucket's own keys, so a jump
+// host needs no awscli. A remote is written <bucket>:<key>, where
+// <bucket> is the claim name as `buckets list` shows it.
+//
+// sync compares by size, then by content: a single-part object's ETag
+// is its MD5, so it is checked against the local file; a multipart one
+// is compared by modification time instead. Downloads get the object's
+// Last-Modified as their mtime so the next sync sees them unchanged.
+
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/s3"
+	"github.com/spf13/cobra"
+)
+
+// bucketRef is a parsed <bucket>:<key> argument.
+type bucketRef struct {
+	Name string
+	Key  string
+}
+
+// parseBucketRef reports whether arg names a remote. Anything without
+// a DNS-label prefix before the colon (./a:b, C:\x, /tmp/a:b) is local.
+func parseBucketRef(arg string) (bucketRef, bool) {
+	name, key, found := strings.Cut(arg, ":")
+	if !found || len(name) < 2 || !projectNameRE.MatchString(name) {
+		return bucketRef{}, false
+	}
+	return bucketRef{Name: name, Key: strings.TrimPrefix(key, "/")}, true
+}
+
+// bucketClient is an S3 client bound to one bucket.
+type bucketClient struct {
+	s3     *s3.Client
+	Name   string // the claim, for messages
+	Bucket string
+}
+
+func (b *bucketClient) ref(key string) string { return b.Name + ":" + key }
+
+// openBucket resolves the claim's credentials and builds the client.
+func openBucket(namespace, endpoint, name string) (*bucketClient, error) {
+	scope, err := resolveScope(namespace)
+	if err != nil {
+		return nil, err
+	}
+	cli, err := scope.k8s()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := ctxWithTimeout()
+	defer cancel()
+	creds, err := loadBucketCredentials(ctx, cli, scope.Namespace, name, bucketEndpoint(endpoint, scope.Domain))
+	if err != nil {
+		return nil, err
+	}
+	c, err := s3.New(creds.Endpoint, creds.Region, creds.AccessKey, creds.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+	return &bucketClient{s3: c, Name: name, Bucket: creds.Bucket}, nil
+}
+
+// transferArgs splits src and dst into the local path and the remote,
+// refusing local-to-local and remote-to-remote.
+func transferArgs(src, dst string) (local string, remote bucketRef, upload bool, err error) {
+	sr, srcRemote := parseBucketRef(src)
+	dr, dstRemote := parseBucketRef(dst)
+	switch {
+	case srcRemote && dstRemote:
+		return "", bucketRef{}, false, fmt.Errorf("copying between buckets is not supported; go through a local directory")
+	case !srcRemote && !dstRemote:
+		return "", bucketRef{}, false, fmt.Errorf("one side must be a bucket, written <bucket>:<key>")
+	case dstRemote:
+		return src, dr, true, nil
+	}
+	return dst, sr, false, nil
+}
+
+// -------- cp -------------------------------------------------------
+
+func bucketsCpCmd() *cobra.Command {
+	var namespace, endpoint string
+	cmd := &cobra.Command{
+		Use:   "cp <src> <dst>",
+		Short: "Copy one file to or from a bucket",
+		Long: `Copy one file between this machine and a bucket. The bucket side is written
+<bucket>:<key>. A key that is empty or ends in / takes the file's name; a
+local destination that is a directory takes the key's last segment, and -
+writes to stdout. Files above 16 MiB are uploaded in parts.`,
+		Example: `  kube-dc buckets cp ./dump.sql.gz backups:db/
+  kube-dc buckets cp backups:db/dump.sql.gz .
+  kube-dc buckets cp assets:site/index.html - | head`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			local, remote, upload, err := transferArgs(args[0], args[1])
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			b, err := openBucket(namespace, endpoint, remote.Name)
+			if err != nil {
+				return err
+			}
+			if upload {
+				return copyUp(context.Background(), b, local, remote.Key, os.Stdout)
+			}
+			return copyDown(context.Background(), b, remote.Key, local, os.Stdout)
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVar(&endpoint, "endpoint", "", "External S3 endpoint (default: $S3_ENDPOINT, else https://s3.<domain>)")
+	return cmd
+}
+
+func copyUp(ctx context.Context, b *bucketClient, local, key string, out io.Writer) error {
+	fi, err := os.Stat(local)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("%s is a directory; use `kube-dc buckets sync`", local)
+	}
+	if key == "" || strings.HasSuffix(key, "/") {
+		key += filepath.Base(local)
+	}
+	if err := putFile(ctx, b, local, key, fi.Size()); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "upload %s → %s (%s)\n", local, b.ref(key), humanBytes(fi.Size()))
+	return nil
+}
+
+func copyDown(ctx context.Context, b *bucketClient, key, local string, out io.Writer) error {
+	if key == "" || strings.HasSuffix(key, "/") {
+		return fmt.Errorf("%s is not an object key; use `kube-dc buckets sync` for a prefix", b.ref(key))
+	}
+	if local == "-" {
+		_, err := b.s3.Get(ctx, b.Bucket, key, os.Stdout)
+		return err
+	}
+	if fi, err := os.Stat(local); (err == nil && fi.IsDir()) || strings.HasSuffix(local, string(filepath.Separator)) {
+		local = filepath.Join(local, path.Base(key))
+	}
+	obj, err := getFile(ctx, b, key, local)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "download %s → %s (%s)\n", b.ref(key), local, humanBytes(obj.Size))
+	return nil
+}
+
+func putFile(ctx context.Context, b *bucketClient, local, key string, size int64) error {
+	f, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := b.s3.Put(ctx, b.Bucket, key, f, size); err != nil {
+		return fmt.Errorf("upload %s: %w", local, err)
+	}
+	return nil
+}
+
+// getFile downloads to a temporary file beside local and renames it
+// into place, so an interrupted download never leaves a truncated file
+// under the real name.
+func getFile(ctx context.Context, b *bucketClient, key, local string) (*s3.Object, error) {
+	if err := os.MkdirAll(filepath.Dir(local), 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(local), "."+filepath.Base(local)+".*.part")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	obj, err := b.s3.Get(ctx, b.Bucket, key, tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", b.ref(key), err)
+	}
+	if err := os.Rename(tmp.Name(), local); err != nil {
+		return nil, err
+	}
+	if !obj.LastModified.IsZero() {
+		_ = os.Chtimes(local, obj.LastModified, obj.LastModified)
+	}
+	return obj, nil
+}
+
+// -------- sync -----------------------------------------------------
+
+type syncOptions struct {
+	Delete bool
+	DryRun bool
+}
+
+func bucketsSyncCmd() *cobra.Command {
+	var namespace, endpoint string
+	var opts syncOptions
+	cmd := &cobra.Command{
+		Use:   "sync <src> <dst>",
+		Short: "Mirror a directory to a bucket prefix, or a prefix to a directory",
+		Long: `Copy every file that is missing or different on the destination side. One side
+is a local directory, the other <bucket>:<prefix>. Files compare by size, then
+by MD5 where the object's ETag carries one, else by modification time.
+
+--delete also removes destination files the source does not have. --dry-run
+prints what would change without changing it. Symlinks and other non-regular
+files are skipped.`,
+		Example: `  kube-dc buckets sync ./public assets:site/
+  kube-dc buckets sync backups:db/ ./restore --dry-run
+  kube-dc buckets sync ./public assets:site/ --delete`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			local, remote, upload, err := transferArgs(args[0], args[1])
+			if err != nil {
+				return err
+			}
+			if fi, err := os.Stat(local); upload && (err != nil || !fi.IsDir()) {
+				return fmt.Errorf("%s is not a directory", local)
+			}
+			cmd.SilenceUsage = true
+			b, err := openBucket(namespace, endpoint, remote.Name)
+			if err != nil {
+				return err
+			}
+			return syncBucket(context.Background(), b, local, remote.Key, upload, opts, os.Stdout)
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVar(&endpoint, "endpoint", "", "External S3 endpoint (default: $S3_ENDPOINT, else https://s3.<domain>)")
+	cmd.Flags().BoolVar(&opts.Delete, "delete", false, "Remove destination files the source does not have")
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Print the changes without making them")
+	return cmd
+}
+
+// syncEntry is one file on either side, keyed by its slash-separated
+// path relative to the directory or prefix. ETag is set only remotely.
+type syncEntry struct {
+	Size    int64
+	ModTime time.Time
+	ETag    string
+}
+
+type syncAction struct {
+	Op   string // copy | delete
+	Rel  string
+	Size int64
+}
+
+// planSync lists the copies and deletions that make dst match src.
+// localMD5 hashes a local file on demand; upload tells which side is
+// local.
+func planSync(src, dst map[string]syncEntry, upload, del bool, localMD5 func(rel string) (string, error)) ([]syncAction, error) {
+	var plan []syncAction
+	for _, rel := range sortedKeys(src) {
+		s := src[rel]
+		d, ok := dst[rel]
+		changed := !ok || s.Size != d.Size
+		if !changed {
+			local, remote := s, d
+			if !upload {
+				local, remote = d, s
+			}
+			switch {
+			case remote.ETag != "" && !strings.Contains(remote.ETag, "-"):
+				sum, err := localMD5(rel)
+				if err != nil {
+					return nil, err
+				}
+				changed = sum != remote.ETag
+			case upload:
+				changed = newer(local.ModTime, remote.ModTime)
+			default:
+				changed = newer(remote.ModTime, local.ModTime)
+			}
+		}
+		if changed {
+			plan = append(plan, syncAction{Op: "copy", Rel: rel, Size: s.Size})
+		}
+	}
+	if del {
+		for _, rel := range sortedKeys(dst) {
+			if _, ok := src[rel]; !ok {
+				plan = append(plan, syncAction{Op: "delete", Rel: rel})
+			}
+		}
+	}
+	return plan, nil
+}
+
+// newer compares at whole seconds, the precision of S3's Last-Modified.
+func newer(a, b time.Time) bool {
+	return a.Truncate(time.Second).After(b.Truncate(time.Second))
+}
+
+func sortedKeys(m map[string]syncEntry) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// localEntries walks dir for regular files.
+func localEntries(dir string) (map[string]syncEntry, error) {
+	out := map[string]syncEntry{}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return out, nil
+	}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		out[filepath.ToSlash(rel)] = syncEntry{Size: fi.Size(), ModTime: fi.ModTime()}
+		return nil
+	})
+	return out, err
+}
+
+// remoteEntries lists the objects under prefix; "directory" marker
+// objects ending in / are skipped. A key that would not stay inside the
+// local directory as a relative path (../x, /etc/x, a/../../x) fails
+// the listing: a download would otherwise write outside it.
+func remoteEntries(ctx context.Context, b *bucketClient, prefix string) (map[string]syncEntry, error) {
+	objs, err := b.s3.List(ctx, b.Bucket, prefix)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]syncEntry{}
+	for _, o := range objs {
+		rel := strings.TrimPrefix(o.Key, prefix)
+		if rel == "" || strings.HasSuffix(rel, "/") {
+			continue
+		}
+		if !filepath.IsLocal(filepath.FromSlash(rel)) {
+			return nil, fmt.Errorf("object %q does not map to a path inside the sync directory; rename or remove it first", o.Key)
+		}
+		out[rel] = syncEntry{Size: o.Size, ModTime: o.LastModified, ETag: o.ETag}
+	}
+	return out, nil
+}
+
+func fileMD5(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func syncBucket(ctx context.Context, b *bucketClient, dir, prefix string, upload bool, opts syncOptions, out io.Writer) error {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	local, err := localEntries(dir)
+	if err != nil {
+		return err
+	}
+	remote, err := remoteEntries(ctx, b, prefix)
+	if err != nil {
+		return err
+	}
+	src, dst := local, remote
+	if !upload {
+		src, dst = remote, local
+	}
+	localPath := func(rel string) string { return filepath.Join(dir, filepath.FromSlash(rel)) }
+	plan, err := planSync(src, dst, upload, opts.Delete, func(rel string) (string, error) { return fileMD5(localPath(rel)) })
+	if err != nil {
+		return err
+	}
+
+	verb, from, to := "upload", dir, b.ref(prefix)
+	if !upload {
+		verb, from, to = "download", b.ref(prefix), dir
+	}
+	dry := ""
+	if opts.DryRun {
+		dry = "(dry run) "
+	}
+	var copied, deleted int
+	var bytes int64
+	for _, a := range plan {
+		switch {
+		case a.Op == "copy":
+			fmt.Fprintf(out, "%s%s %s (%s)\n", dry, verb, a.Rel, humanBytes(a.Size))
+			if opts.DryRun {
+				break
+			}
+			if upload {
+				err = putFile(ctx, b, localPath(a.Rel), prefix+a.Rel, a.Size)
+			} else {
+				_, err = getFile(ctx, b, prefix+a.Rel, localPath(a.Rel))
+			}
+			copied++
+			bytes += a.Size
+		default:
+			fmt.Fprintf(out, "%sdelete %s\n", dry, a.Rel)
+			if opts.DryRun {
+				break
+			}
+			if upload {
+				err = b.s3.Delete(ctx, b.Bucket, prefix+a.Rel)
+			} else {
+				err = os.Remove(localPath(a.Rel))
+			}
+			deleted++
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if opts.DryRun {
+		fmt.Fprintf(out, "(dry run) %d to change, %d unchanged\n", len(plan), len(src)-countOp(plan, "copy"))
+		return nil
+	}
+	fmt.Fprintf(out, "Synced %s → %s: %d copied (%s), %d deleted, %d unchanged\n",
+		from, to, copied, humanBytes(bytes), deleted, len(src)-copied)
+	return nil
+}
+
+func countOp(plan []syncAction, op string) int {
+	n := 0
+	for _, a := range plan {
+		if a.Op == op {
+			n++
+		}
+	}
+	return n
+}
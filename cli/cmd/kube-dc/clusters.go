@@ -0,0 +1,981 @@
+// `kube-dc clusters` — Managed Clusters: KdcCluster (k8s.kube-dc.com/
+// v1alpha1) in the current Project, straight against the kube-apiserver
+// with the user's JWT. The kdc-cluster controller turns each one into a
+// Kamaji control plane and Cluster API worker pools (a KubevirtMachine
+// Template and MachineDeployment per pool). The manifest is the one
+// docs/cloud/provisioning-cluster.md and skills/manage-cluster describe.
+//
+// Verbs:
+//   create      — KdcCluster with one worker pool, wait for Ready     (k8s)
+//   list        — clusters with version, workers, phase, endpoint      (k8s)
+//   scale       — JSON-patch one pool's replicas, wait                 (k8s)
+//   upgrade     — skew check, JSON-patch version + pool images, wait   (k8s)
+//   delete      — the KdcCluster (controller removes CP and workers)   (k8s)
+//   kubeconfig  — merge the external admin.conf into ~/.kube/config    (k8s)
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+	"github.com/shalb/kube-dc/cli/internal/kubeconfig"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/resource"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+)
+
+// clusterPollInterval is how often the wait loops re-read the cluster.
+// A var so tests can shorten it.
+var clusterPollInterval = 10 * time.Second
+
+// clusterVersionRE is the vMAJOR.MINOR.PATCH shape the API accepts for
+// spec.version and pool pins.
+var clusterVersionRE = regexp.MustCompile(`^v\d+\.\d+\.\d+$`)
+
+// maxWorkerSkew is how many minors a worker pool may run behind the
+// control plane (the kubeadm rule the controller enforces on pins).
+const maxWorkerSkew = 2
+
+// The console allocates a dedicated datastore's service port from this
+// range, unique per EIP; the CLI does the same.
+const (
+	dataStorePortMin = 32380
+	dataStorePortMax = 32499
+)
+
+func clustersCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "clusters",
+		Aliases: []string{"cluster"},
+		Short:   "Manage Managed Kubernetes Clusters in the current Project",
+		Long: `Create, scale, upgrade and delete Managed Clusters (KdcCluster) in the
+current Project, and merge their kubeconfig into ~/.kube/config.
+
+A Managed Cluster is a separate Kubernetes API: a hosted control plane plus
+worker pools of KubeVirt VMs in the Project. Its workers count against the
+Organization and Project quota.`,
+	}
+	cmd.AddCommand(clustersCreateCmd())
+	cmd.AddCommand(clustersListCmd())
+	cmd.AddCommand(clustersScaleCmd())
+	cmd.AddCommand(clustersUpgradeCmd())
+	cmd.AddCommand(clustersDeleteCmd())
+	cmd.AddCommand(clustersKubeconfigCmd())
+	return cmd
+}
+
+// clusterOpts are the `clusters create` flags.
+type clusterOpts struct {
+	Name, Namespace      string
+	Version, Image       string
+	ControlPlaneReplicas int
+	Pool                 string
+	Workers              int
+	CPU                  int
+	Memory, Disk         string
+	StorageType          string
+	Architecture         string
+	Network              string
+	ServiceCIDR, PodCIDR string
+	DedicatedDataStore   bool
+	DataStorePort        int
+	Expose               bool
+	EncryptEtcd          bool
+}
+
+// buildKdcCluster validates the flags and assembles the manifest with
+// a single KubeVirt worker pool. Pure — no I/O; the datastore port is
+// chosen by the caller.
+func buildKdcCluster(opts clusterOpts) (*k8sapi.KdcCluster, error) {
+	if !projectNameRE.MatchString(opts.Name) || len(opts.Name) < 2 || len(opts.Name) > 12 {
+		return nil, fmt.Errorf("invalid cluster name %q: use 2-12 lowercase letters, digits and '-'", opts.Name)
+	}
+	if !clusterVersionRE.MatchString(opts.Version) {
+		return nil, fmt.Errorf("invalid --version %q: use the catalog's vX.Y.Z", opts.Version)
+	}
+	if opts.Image == "" {
+		return nil, fmt.Errorf("--image is required: pass the worker image the catalog pairs with %s", opts.Version)
+	}
+	if opts.ControlPlaneReplicas < 1 || opts.ControlPlaneReplicas > 5 {
+		return nil, fmt.Errorf("invalid --control-plane-replicas %d: use 1 to 5", opts.ControlPlaneReplicas)
+	}
+	if !projectNameRE.MatchString(opts.Pool) {
+		return nil, fmt.Errorf("invalid --pool %q: use lowercase letters, digits and '-'", opts.Pool)
+	}
+	if opts.Workers < 0 {
+		return nil, fmt.Errorf("invalid --workers %d", opts.Workers)
+	}
+	if opts.CPU < 1 {
+		return nil, fmt.Errorf("invalid --cpu %d: use whole cores, at least 1", opts.CPU)
+	}
+	for flag, v := range map[string]string{"--memory": opts.Memory, "--disk": opts.Disk} {
+		if _, err := resource.ParseQuantity(v); err != nil {
+			return nil, fmt.Errorf("invalid %s %q: use a quantity such as 8Gi", flag, v)
+		}
+	}
+	switch opts.StorageType {
+	case "datavolume", "containerdisk":
+	default:
+		return nil, fmt.Errorf("invalid --storage-type %q (want datavolume or containerdisk)", opts.StorageType)
+	}
+	switch opts.Network {
+	case k8sapi.EgressNetworkCloud, k8sapi.EgressNetworkPublic:
+	default:
+		return nil, fmt.Errorf("invalid --network %q (want cloud or public)", opts.Network)
+	}
+
+	kc := &k8sapi.KdcCluster{
+		Metadata: k8sapi.ObjectMeta{Name: opts.Name, Namespace: opts.Namespace},
+		Spec: k8sapi.KdcClusterSpec{
+			Version:          opts.Version,
+			ControlPlane:     &k8sapi.KdcControlPlaneSpec{Replicas: opts.ControlPlaneReplicas},
+			Network:          &k8sapi.KdcClusterNetwork{ServiceCIDR: opts.ServiceCIDR, PodCIDR: opts.PodCIDR},
+			EIP:              &k8sapi.KdcClusterEIP{Create: true, ExternalNetworkType: opts.Network},
+			EnableClusterAPI: true,
+			Workers: []k8sapi.WorkerPool{{
+				Name:                   opts.Pool,
+				Replicas:               opts.Workers,
+				CPUCores:               opts.CPU,
+				Memory:                 opts.Memory,
+				DiskSize:               opts.Disk,
+				Image:                  opts.Image,
+				Architecture:           opts.Architecture,
+				InfrastructureProvider: k8sapi.WorkerProviderKubeVirt,
+				StorageType:            opts.StorageType,
+			}},
+		},
+	}
+	if opts.DedicatedDataStore {
+		kc.Spec.DataStore = &k8sapi.KdcClusterDataStore{Dedicated: true, EIPName: "default-gw", Port: opts.DataStorePort}
+	}
+	if opts.Expose {
+		kc.Metadata.Annotations = map[string]string{k8sapi.KdcClusterExposeRouteAnnotation: "true"}
+	}
+	if opts.EncryptEtcd {
+		kc.Spec.Encryption = &k8sapi.KdcClusterEncryption{Etcd: &k8sapi.KdcClusterEtcdEncryption{Enabled: true}}
+	}
+	return kc, nil
+}
+
+// pickDataStorePort returns the lowest port in the console's range that
+// no other dedicated datastore on the same EIP uses.
+func pickDataStorePort(existing []k8sapi.KdcCluster, eip string) (int, error) {
+	used := map[int]bool{}
+	for _, c := range existing {
+		if ds := c.Spec.DataStore; ds != nil && ds.Dedicated && fmtCoalesce(ds.EIPName, "default-gw") == eip {
+			used[ds.Port] = true
+		}
+	}
+	for p := dataStorePortMin; p <= dataStorePortMax; p++ {
+		if !used[p] {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("no free datastore port on EIP %s (%d-%d are all taken); pass --datastore-port", eip, dataStorePortMin, dataStorePortMax)
+}
+
+// -------- create ---------------------------------------------------
+
+func clustersCreateCmd() *cobra.Command {
+	opts := clusterOpts{}
+	var outFlag string
+	var noWait bool
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create a Managed Cluster and wait for it to be Ready",
+		Long: `Create a KdcCluster in the current Project with one KubeVirt worker pool and
+wait until it is Ready.
+
+--version and --image must be a pair from the platform's version catalog (the
+dashboard's version selector); the CLI never derives an image from a version.
+Add further pools, CloudSigma workers or autoscaling through the manifest —
+see skills/manage-cluster.
+
+--expose (on by default) publishes the cluster's API through a TLSRoute so
+kube-dc clusters kubeconfig can merge its external kubeconfig. Workers count
+against the quota: check headroom first with
+kube-dc quota --check cpu=<n>,memory=<size>.`,
+		Example: `  kube-dc clusters create dev --version v1.36.1 \
+    --image docker.io/shalb/ubuntu-2404-container-disk:v1.36.1 --workers 3 --cpu 2 --memory 8Gi
+  kube-dc clusters create prod --version v1.36.1 --image <catalog image> \
+    --control-plane-replicas 3 --dedicated-datastore --encrypt-etcd`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			opts.Name = args[0]
+			kc, err := buildKdcCluster(opts)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(opts.Namespace)
+			if err != nil {
+				return err
+			}
+			kc.Metadata.Namespace = scope.Namespace
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			if ds := kc.Spec.DataStore; ds != nil && ds.Port == 0 {
+				existing, err := cli.ListKdcClusters(ctx, scope.Namespace)
+				if err != nil {
+					return err
+				}
+				if ds.Port, err = pickDataStorePort(existing.Items, ds.EIPName); err != nil {
+					return err
+				}
+			}
+			created, err := cli.CreateKdcCluster(ctx, kc)
+			if err != nil {
+				return fmt.Errorf("create KdcCluster %s: %w", opts.Name, err)
+			}
+			pool := kc.Spec.Workers[0]
+			summary := fmt.Sprintf("Kubernetes %s, %d control-plane replica(s), %d worker(s) of %d CPU / %s",
+				kc.Spec.Version, kc.Spec.ControlPlane.Replicas, pool.Replicas, pool.CPUCores, pool.Memory)
+			if noWait {
+				if out != outTable {
+					return printSerialized(out, created)
+				}
+				fmt.Printf("Created Managed Cluster %s (%s)\n", opts.Name, summary)
+				fmt.Printf("Not waiting; follow with `kube-dc clusters list`\n")
+				return nil
+			}
+			if out == outTable {
+				fmt.Printf("Created Managed Cluster %s (%s); waiting for it to be Ready...\n", opts.Name, summary)
+			}
+			ready, err := waitForCluster(context.Background(), cli, scope.Namespace, opts.Name, timeout, "Ready", clusterReady)
+			if err != nil {
+				return err
+			}
+			if out != outTable {
+				return printSerialized(out, ready)
+			}
+			fmt.Printf("Managed Cluster %s is Ready at %s\n", opts.Name, fmtCoalesce(ready.Status.PublicAPIEndpoint, ready.Status.Endpoint, "-"))
+			if opts.Expose {
+				fmt.Printf("Add it to your kubeconfig with `kube-dc clusters kubeconfig %s`\n", opts.Name)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVar(&opts.Version, "version", "", "Kubernetes version from the catalog, e.g. v1.36.1 (required)")
+	cmd.Flags().StringVar(&opts.Image, "image", "", "Worker image the catalog pairs with --version (required)")
+	cmd.Flags().IntVar(&opts.ControlPlaneReplicas, "control-plane-replicas", 2, "API server replicas, 1-5")
+	cmd.Flags().StringVar(&opts.Pool, "pool", "workers", "Name of the worker pool")
+	cmd.Flags().IntVar(&opts.Workers, "workers", 2, "Worker VMs in the pool")
+	cmd.Flags().IntVar(&opts.CPU, "cpu", 1, "CPU cores per worker")
+	cmd.Flags().StringVar(&opts.Memory, "memory", "2Gi", "Memory per worker")
+	cmd.Flags().StringVar(&opts.Disk, "disk", "10Gi", "Root disk per worker (datavolume storage only)")
+	cmd.Flags().StringVar(&opts.StorageType, "storage-type", "datavolume", "Worker root disk: datavolume (persistent) or containerdisk (ephemeral)")
+	cmd.Flags().StringVar(&opts.Architecture, "architecture", "amd64", "Worker CPU architecture")
+	cmd.Flags().StringVar(&opts.Network, "network", k8sapi.EgressNetworkCloud, "External network for the API EIP: cloud|public")
+	cmd.Flags().StringVar(&opts.ServiceCIDR, "service-cidr", "10.96.0.0/16", "Service CIDR inside the cluster")
+	cmd.Flags().StringVar(&opts.PodCIDR, "pod-cidr", "10.244.0.0/16", "Pod CIDR inside the cluster")
+	cmd.Flags().BoolVar(&opts.DedicatedDataStore, "dedicated-datastore", false, "Give the cluster its own etcd instead of the shared datastore")
+	cmd.Flags().IntVar(&opts.DataStorePort, "datastore-port", 0, "Dedicated datastore port on the EIP (default: the first free one in 32380-32499)")
+	cmd.Flags().BoolVar(&opts.Expose, "expose", true, "Expose the cluster's API externally (needed for kube-dc clusters kubeconfig)")
+	cmd.Flags().BoolVar(&opts.EncryptEtcd, "encrypt-etcd", false, "Encrypt Secrets at rest in the cluster's etcd with a Project KMS key")
+	cmd.Flags().BoolVar(&noWait, "no-wait", false, "Return once the cluster is created instead of waiting for Ready")
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Minute, "How long to wait for Ready")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	_ = cmd.MarkFlagRequired("version")
+	_ = cmd.MarkFlagRequired("image")
+	return cmd
+}
+
+// -------- list -----------------------------------------------------
+
+func clustersListCmd() *cobra.Command {
+	var namespace, outFlag string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List Managed Clusters in the current Project",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			list, err := cli.ListKdcClusters(ctx, scope.Namespace)
+			if err != nil {
+				return err
+			}
+			if out != outTable {
+				return printSerialized(out, list)
+			}
+			return printClusterTable(scope.Namespace, list.Items)
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+// -------- scale ----------------------------------------------------
+
+// resolveWorkerPool returns the index of the named pool, or of the
+// only pool when name is empty.
+func resolveWorkerPool(kc *k8sapi.KdcCluster, name string) (int, error) {
+	if name == "" {
+		switch len(kc.Spec.Workers) {
+		case 0:
+			return -1, fmt.Errorf("cluster %s has no worker pools", kc.Metadata.Name)
+		case 1:
+			return 0, nil
+		}
+		return -1, fmt.Errorf("cluster %s has several worker pools (%s); pass --pool", kc.Metadata.Name, strings.Join(workerPoolNames(kc), ", "))
+	}
+	if i := kc.WorkerPoolIndex(name); i >= 0 {
+		return i, nil
+	}
+	return -1, fmt.Errorf("cluster %s has no worker pool %q (pools: %s)", kc.Metadata.Name, name, strings.Join(workerPoolNames(kc), ", "))
+}
+
+func workerPoolNames(kc *k8sapi.KdcCluster) []string {
+	names := make([]string, 0, len(kc.Spec.Workers))
+	for _, p := range kc.Spec.Workers {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// clusterScalePatch validates a replica change for one pool and builds
+// the JSON Patch. The test operation pins the index to the pool's name
+// so a concurrent edit of the list fails the patch instead of scaling
+// the wrong pool.
+func clusterScalePatch(kc *k8sapi.KdcCluster, i, replicas int) ([]k8sapi.JSONPatchOp, error) {
+	pool := kc.Spec.Workers[i]
+	if replicas < 0 {
+		return nil, fmt.Errorf("invalid --replicas %d", replicas)
+	}
+	if a := pool.Autoscaling; a != nil && a.Enabled {
+		if a.Mode == "ClusterAutoscaler" {
+			return nil, fmt.Errorf("pool %s is in ClusterAutoscaler mode and the platform owns its replicas; change its minReplicas/maxReplicas instead", pool.Name)
+		}
+		lo := a.MinReplicas
+		if lo == 0 {
+			lo = 1
+		}
+		if replicas < lo || (a.MaxReplicas > 0 && replicas > a.MaxReplicas) {
+			return nil, fmt.Errorf("pool %s autoscales between %d and %d replicas; %d is outside that range", pool.Name, lo, a.MaxReplicas, replicas)
+		}
+	}
+	if replicas == 0 {
+		other := false
+		for _, ps := range kc.Status.WorkerPools {
+			if ps.Name != pool.Name && ps.ReadyReplicas > 0 {
+				other = true
+				break
+			}
+		}
+		if !other {
+			return nil, fmt.Errorf("pool %s is the last pool with Ready workers and cannot scale to 0", pool.Name)
+		}
+	}
+	base := "/spec/workers/" + strconv.Itoa(i)
+	return []k8sapi.JSONPatchOp{
+		{Op: "test", Path: base + "/name", Value: pool.Name},
+		{Op: "replace", Path: base + "/replicas", Value: replicas},
+	}, nil
+}
+
+func clustersScaleCmd() *cobra.Command {
+	var namespace, poolName string
+	var replicas int
+	var noWait bool
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "scale <name> --replicas <n>",
+		Short: "Change the number of workers in a pool",
+		Long: `Set the replica count of one worker pool and wait until that many workers are
+Ready.
+
+Only the pool's replicas field is patched; the other pools and settings are
+left alone. --pool may be omitted when the cluster has a single pool. A pool
+can scale to 0 only while another pool has Ready workers. Pools in
+ClusterAutoscaler mode are sized by the platform and refuse a manual count;
+other autoscaled pools accept one within their minReplicas and maxReplicas.`,
+		Example: `  kube-dc clusters scale dev --replicas 5
+  kube-dc clusters scale dev --pool gpu --replicas 0`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if !cmd.Flags().Changed("replicas") {
+				return errors.New("--replicas is required")
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			kc, err := cli.GetKdcCluster(ctx, scope.Namespace, name)
+			if err != nil {
+				return err
+			}
+			i, err := resolveWorkerPool(kc, poolName)
+			if err != nil {
+				return err
+			}
+			pool := kc.Spec.Workers[i].Name
+			ops, err := clusterScalePatch(kc, i, replicas)
+			if err != nil {
+				return err
+			}
+			if _, err := cli.PatchKdcClusterJSON(ctx, scope.Namespace, name, ops); err != nil {
+				return err
+			}
+			if noWait {
+				fmt.Printf("Scaling %s pool %s to %d; follow with `kube-dc clusters list`\n", name, pool, replicas)
+				return nil
+			}
+			fmt.Printf("Scaling %s pool %s from %d to %d; waiting for the workers...\n", name, pool, kc.Spec.Workers[i].Replicas, replicas)
+			done := func(c *k8sapi.KdcCluster) bool {
+				ps := c.WorkerPoolStatus(pool)
+				return ps != nil && ps.Replicas == replicas && ps.ReadyReplicas == replicas
+			}
+			if _, err := waitForCluster(context.Background(), cli, scope.Namespace, name, timeout, fmt.Sprintf("%d Ready workers in pool %s", replicas, pool), done); err != nil {
+				return err
+			}
+			fmt.Printf("Pool %s of %s has %d Ready workers\n", pool, name, replicas)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVar(&poolName, "pool", "", "Worker pool (default: the only pool)")
+	cmd.Flags().IntVar(&replicas, "replicas", 0, "Desired number of workers (required)")
+	cmd.Flags().BoolVar(&noWait, "no-wait", false, "Return once the change is submitted")
+	cmd.Flags().DurationVar(&timeout, "timeout", 20*time.Minute, "How long to wait for the workers")
+	return cmd
+}
+
+// -------- upgrade --------------------------------------------------
+
+// clusterUpgrade is a validated upgrade: the JSON Patch plus, for
+// output, the pools that take a new image and those left as they are.
+type clusterUpgrade struct {
+	From, To string
+	Ops      []k8sapi.JSONPatchOp
+	Images   map[string]string // pool → new image
+	Kept     map[string]string // pool → why its image is unchanged
+}
+
+// planClusterUpgrade checks that moving the control plane to target
+// keeps every pool within the supported skew and builds the patch.
+//
+// The control plane moves at most one minor at a time and never back.
+// A worker pool may run up to maxWorkerSkew minors behind the control
+// plane and never ahead of it — both the version it runs now (its
+// Machines roll only after the control plane) and a pinned version it
+// will keep. Pools that track spec.version take the new image: the
+// one given for the pool in poolImages, else image. CloudSigma pools
+// boot from an image UUID and pinned pools keep theirs.
+func planClusterUpgrade(kc *k8sapi.KdcCluster, target, image string, poolImages map[string]string) (*clusterUpgrade, error) {
+	if !clusterVersionRE.MatchString(target) {
+		return nil, fmt.Errorf("invalid --version %q: use the catalog's vX.Y.Z", target)
+	}
+	to := utilversion.MustParseSemantic(target)
+	current := kc.Spec.Version
+	from, err := utilversion.ParseSemantic(current)
+	if err != nil {
+		return nil, fmt.Errorf("cluster %s has an unparseable version %q", kc.Metadata.Name, current)
+	}
+	if running := kc.ControlPlaneVersion(); running != current {
+		return nil, fmt.Errorf("the control plane of %s is still moving from %s to %s; wait for it to finish", kc.Metadata.Name, running, current)
+	}
+	switch {
+	case to.EqualTo(from):
+		return nil, fmt.Errorf("cluster %s already runs %s", kc.Metadata.Name, current)
+	case to.LessThan(from):
+		return nil, fmt.Errorf("%s is older than %s: Kubernetes downgrades are not supported", target, current)
+	}
+	if to.Major() != from.Major() || to.Minor() > from.Minor()+1 {
+		return nil, fmt.Errorf("%s → %s skips a minor version; upgrade one minor at a time (next: v%d.%d.x)", current, target, from.Major(), from.Minor()+1)
+	}
+
+	for pool := range poolImages {
+		if kc.WorkerPoolIndex(pool) < 0 {
+			return nil, fmt.Errorf("--pool-image: cluster %s has no worker pool %q", kc.Metadata.Name, pool)
+		}
+	}
+	up := &clusterUpgrade{From: current, To: target, Images: map[string]string{}, Kept: map[string]string{}}
+	up.Ops = []k8sapi.JSONPatchOp{
+		{Op: "test", Path: "/spec/version", Value: current},
+		{Op: "replace", Path: "/spec/version", Value: target},
+	}
+	for i, pool := range kc.Spec.Workers {
+		running := pool.Version
+		if ps := kc.WorkerPoolStatus(pool.Name); ps != nil && ps.ObservedVersion != "" {
+			running = ps.ObservedVersion
+		}
+		if pool.Version != "" {
+			if err := checkWorkerSkew(pool.Name, pool.Version, to); err != nil {
+				return nil, fmt.Errorf("%w; raise or clear its version pin first", err)
+			}
+		}
+		if err := checkWorkerSkew(pool.Name, fmtCoalesce(running, current), to); err != nil {
+			return nil, err
+		}
+		if pool.Version != "" {
+			if _, ok := poolImages[pool.Name]; ok {
+				return nil, fmt.Errorf("--pool-image: pool %s is pinned to %s and keeps its image", pool.Name, pool.Version)
+			}
+			up.Kept[pool.Name] = "pinned to " + pool.Version
+			continue
+		}
+		if pool.InfrastructureProvider == k8sapi.WorkerProviderCloudSigma {
+			if _, ok := poolImages[pool.Name]; ok {
+				return nil, fmt.Errorf("--pool-image: CloudSigma pool %s boots from an image UUID; change it in the manifest", pool.Name)
+			}
+			up.Kept[pool.Name] = "CloudSigma image UUID, managed in the manifest"
+			continue
+		}
+		img := fmtCoalesce(poolImages[pool.Name], image)
+		if img == "" {
+			return nil, fmt.Errorf("--image is required: pass the worker image the catalog pairs with %s (it is never derived from the version)", target)
+		}
+		base := "/spec/workers/" + strconv.Itoa(i)
+		op := "replace"
+		if pool.Image == "" {
+			op = "add"
+		}
+		up.Ops = append(up.Ops,
+			k8sapi.JSONPatchOp{Op: "test", Path: base + "/name", Value: pool.Name},
+			k8sapi.JSONPatchOp{Op: op, Path: base + "/image", Value: img},
+		)
+		up.Images[pool.Name] = img
+	}
+	return up, nil
+}
+
+// checkWorkerSkew reports whether a pool at v may run against a control
+// plane at cp.
+func checkWorkerSkew(pool, v string, cp *utilversion.Version) error {
+	pv, err := utilversion.ParseSemantic(v)
+	if err != nil {
+		return fmt.Errorf("pool %s has an unparseable version %q", pool, v)
+	}
+	if pv.Major() > cp.Major() || (pv.Major() == cp.Major() && pv.Minor() > cp.Minor()) {
+		return fmt.Errorf("pool %s runs %s, newer than the control plane's v%s", pool, v, cp)
+	}
+	if pv.Major() != cp.Major() || int(cp.Minor())-int(pv.Minor()) > maxWorkerSkew {
+		return fmt.Errorf("pool %s runs %s, more than %d minor versions behind v%s", pool, v, maxWorkerSkew, cp)
+	}
+	return nil
+}
+
+// parsePoolImages turns repeated pool=image flags into a map.
+func parsePoolImages(values []string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, v := range values {
+		pool, image, ok := strings.Cut(v, "=")
+		if !ok || pool == "" || image == "" {
+			return nil, fmt.Errorf("invalid --pool-image %q: use <pool>=<image>", v)
+		}
+		out[pool] = image
+	}
+	return out, nil
+}
+
+func clustersUpgradeCmd() *cobra.Command {
+	var namespace, target, image string
+	var poolImageFlags []string
+	var dryRun, noWait bool
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "upgrade <name> --version <vX.Y.Z> --image <worker image>",
+		Short: "Upgrade a Managed Cluster's Kubernetes version",
+		Long: `Move the control plane to a newer Kubernetes version and roll every worker pool
+onto the matching image, in a single patch, then wait for the rollout.
+
+The version skew is checked first: the control plane moves one minor version
+at a time and never back, and every worker pool must stay at most two minor
+versions behind it and never ahead — including pools pinned with
+spec.workers[].version, which keep their version and image.
+
+--version and --image must be a pair from the platform's version catalog (the
+dashboard's version selector); the image is never derived from the version.
+Use --pool-image for a pool that needs a different image, e.g. arm64 workers.
+Plan a maintenance window: workers are replaced one by one, and workloads
+without spare replicas or disruption budgets can be interrupted.`,
+		Example: `  kube-dc clusters upgrade dev --version v1.36.1 \
+    --image docker.io/shalb/ubuntu-2404-container-disk:v1.36.1
+  kube-dc clusters upgrade dev --version v1.36.1 --image <amd64 image> --pool-image arm=<arm64 image> --dry-run`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if target == "" {
+				return errors.New("--version is required")
+			}
+			poolImages, err := parsePoolImages(poolImageFlags)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			kc, err := cli.GetKdcCluster(ctx, scope.Namespace, name)
+			if err != nil {
+				return err
+			}
+			up, err := planClusterUpgrade(kc, target, image, poolImages)
+			if err != nil {
+				return err
+			}
+			printClusterUpgrade(name, up)
+			if dryRun {
+				fmt.Println("Dry run: nothing changed")
+				return nil
+			}
+			if _, err := cli.PatchKdcClusterJSON(ctx, scope.Namespace, name, up.Ops); err != nil {
+				return err
+			}
+			if noWait {
+				fmt.Printf("Upgrade started; follow with `kube-dc clusters list`\n")
+				return nil
+			}
+			fmt.Println("Upgrade started; waiting for the control plane and workers...")
+			done := func(c *k8sapi.KdcCluster) bool {
+				if !clusterReady(c) || c.ControlPlaneVersion() != up.To {
+					return false
+				}
+				for pool := range up.Images {
+					ps := c.WorkerPoolStatus(pool)
+					if ps == nil || ps.ObservedVersion != up.To || ps.ReadyReplicas != ps.Replicas {
+						return false
+					}
+				}
+				return true
+			}
+			if _, err := waitForCluster(context.Background(), cli, scope.Namespace, name, timeout, "upgraded to "+up.To, done); err != nil {
+				return err
+			}
+			fmt.Printf("Managed Cluster %s runs %s\n", name, up.To)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVar(&target, "version", "", "Target Kubernetes version from the catalog (required)")
+	cmd.Flags().StringVar(&image, "image", "", "Worker image the catalog pairs with --version")
+	cmd.Flags().StringArrayVar(&poolImageFlags, "pool-image", nil, "Image for one pool, <pool>=<image> (repeatable)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Check the version skew and show the change without applying it")
+	cmd.Flags().BoolVar(&noWait, "no-wait", false, "Return once the upgrade is submitted")
+	cmd.Flags().DurationVar(&timeout, "timeout", time.Hour, "How long to wait for the rollout")
+	return cmd
+}
+
+func printClusterUpgrade(name string, up *clusterUpgrade) {
+	fmt.Printf("Upgrade %s: %s → %s\n", name, up.From, up.To)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, pool := range slices.Sorted(maps.Keys(up.Images)) {
+		fmt.Fprintf(w, "  pool %s\timage %s\n", pool, up.Images[pool])
+	}
+	for _, pool := range slices.Sorted(maps.Keys(up.Kept)) {
+		fmt.Fprintf(w, "  pool %s\tunchanged (%s)\n", pool, up.Kept[pool])
+	}
+	w.Flush()
+}
+
+// -------- delete ---------------------------------------------------
+
+func clustersDeleteCmd() *cobra.Command {
+	var namespace string
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a Managed Cluster and its workers",
+		Long: `Delete a KdcCluster. The controller removes its control plane, worker VMs and
+dedicated datastore; volumes created inside the cluster go with the workers.
+The kubeconfig context kube-dc clusters kubeconfig added is removed too.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if !yes {
+				fmt.Fprintf(os.Stderr, "Delete Managed Cluster %s, its workers and its data? Re-run with --yes to confirm.\n", name)
+				return fmt.Errorf("not confirmed")
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			if err := cli.DeleteKdcCluster(ctx, scope.Namespace, name); err != nil {
+				return err
+			}
+			fmt.Printf("Deleted Managed Cluster %s\n", name)
+			if params, err := clusterContextParams(scope, name); err == nil {
+				if kubeMgr, err := kubeconfig.NewManager(); err == nil {
+					if contexts, err := kubeMgr.ListKubeDCContexts(); err == nil && hasContext(contexts, params.ContextName) {
+						if err := kubeMgr.RemoveContext(params.ContextName); err != nil {
+							fmt.Fprintf(os.Stderr, "Warning: remove context %s: %v\n", params.ContextName, err)
+						} else {
+							fmt.Printf("Removed context %s\n", params.ContextName)
+						}
+					}
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().BoolVar(&yes, "yes", false, "Confirm the deletion")
+	return cmd
+}
+
+func hasContext(contexts []kubeconfig.NamedContext, name string) bool {
+	for _, c := range contexts {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// -------- kubeconfig -----------------------------------------------
+
+// clusterContextParams names a Managed Cluster's kubeconfig entries
+// after the Project it lives in:
+// kube-dc/<domain>/<org>/<project>/cluster/<name>. Only an Organization
+// context knows the Organization, so the admin context is refused.
+func clusterContextParams(scope *secretsScope, cluster string) (kubeconfig.MergeContextParams, error) {
+	org := scope.Realm
+	if org == "" || org == adminRealm {
+		return kubeconfig.MergeContextParams{}, errors.New("Managed Cluster contexts are named after their Organization; run this from one of its Project contexts (kube-dc use <domain>/<org>/<project>)")
+	}
+	project := strings.TrimPrefix(scope.Namespace, org+"-")
+	path := fmt.Sprintf("%s/%s/%s/cluster/%s", scope.Domain, org, project, cluster)
+	return kubeconfig.MergeContextParams{
+		ClusterName: fmt.Sprintf("kube-dc-%s-%s-%s-cluster-%s", scope.Domain, org, project, cluster),
+		UserName:    "kube-dc@" + path,
+		ContextName: "kube-dc/" + path,
+	}, nil
+}
+
+// projectOfClusterContext returns the <domain>/<org>/<project> a Managed
+// Cluster context belongs to; ok is false for every other context.
+func projectOfClusterContext(name string) (string, bool) {
+	parts := strings.Split(strings.TrimPrefix(name, "kube-dc/"), "/")
+	if len(parts) != 5 || parts[3] != "cluster" {
+		return "", false
+	}
+	return strings.Join(parts[:3], "/"), true
+}
+
+func clustersKubeconfigCmd() *cobra.Command {
+	var namespace string
+	var setCurrent bool
+	cmd := &cobra.Command{
+		Use:   "kubeconfig <name>",
+		Short: "Merge a Managed Cluster's kubeconfig into ~/.kube/config",
+		Long: `Read the Managed Cluster's external kubeconfig and merge it into
+~/.kube/config (or $KUBECONFIG) as the context
+kube-dc/<domain>/<org>/<project>/cluster/<name>. Running it again refreshes the
+entry; other contexts are left alone.
+
+The kubeconfig exists only while the cluster's API is exposed externally
+(kube-dc clusters create --expose, the default). Its server and credentials are
+used as issued — a cluster admin certificate, so treat the file accordingly.
+kube-dc logout removes the context along with the other kube-dc ones.`,
+		Example: `  kube-dc clusters kubeconfig dev
+  kubectl --context kube-dc/kube-dc.cloud/acme/web/cluster/dev get nodes
+  kube-dc clusters kubeconfig dev --use`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			params, err := clusterContextParams(scope, name)
+			if err != nil {
+				return err
+			}
+			params.SetCurrent = setCurrent
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			kc, err := cli.GetKdcCluster(ctx, scope.Namespace, name)
+			if err != nil {
+				return err
+			}
+			secret, err := cli.GetSecret(ctx, scope.Namespace, k8sapi.KdcClusterExternalKubeconfigSecret(name))
+			if k8sapi.IsNotFound(err) {
+				if kc.Metadata.Annotations[k8sapi.KdcClusterExposeRouteAnnotation] != "true" {
+					return fmt.Errorf("cluster %s has no external kubeconfig because its API is not exposed; enable exposure in the console or annotate it with %s=true", name, k8sapi.KdcClusterExposeRouteAnnotation)
+				}
+				return fmt.Errorf("cluster %s has no external kubeconfig yet (phase %s); try again once it is Ready", name, fmtCoalesce(kc.Status.Phase, "unknown"))
+			}
+			if err != nil {
+				return err
+			}
+			data, err := secret.Value(k8sapi.KdcClusterExternalKubeconfigKey)
+			if err != nil {
+				return err
+			}
+			kubeMgr, err := kubeconfig.NewManager()
+			if err != nil {
+				return fmt.Errorf("load kubeconfig: %w", err)
+			}
+			if err := kubeMgr.MergeContext(data, params); err != nil {
+				return fmt.Errorf("merge kubeconfig: %w", err)
+			}
+			fmt.Printf("Merged Managed Cluster %s into %s as context %s\n", name, kubeconfigPath(), params.ContextName)
+			if setCurrent {
+				project, _ := projectOfClusterContext(params.ContextName)
+				fmt.Printf("Switched to it; return to the Project with: kube-dc use %s\n", project)
+				return nil
+			}
+			fmt.Printf("Use it with: kubectl --context %s get nodes\n", params.ContextName)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().BoolVar(&setCurrent, "use", false, "Also make it the current context")
+	return cmd
+}
+
+// -------- wait -----------------------------------------------------
+
+// clusterReady is the cluster-wide Ready signal: the controller's phase
+// and a ready control plane.
+func clusterReady(kc *k8sapi.KdcCluster) bool {
+	return kc.Status.Phase == "Ready" && kc.Status.ControlPlaneReady
+}
+
+// waitForCluster polls until done reports true. Failed ends the wait
+// early; on timeout the error carries the last phase and the
+// conditions that are not True. what describes the goal for messages.
+func waitForCluster(ctx context.Context, cli *k8sapi.Client, ns, name string, timeout time.Duration, what string, done func(*k8sapi.KdcCluster) bool) (*k8sapi.KdcCluster, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var last *k8sapi.KdcCluster
+	var lastErr error
+	for {
+		reqCtx, reqCancel := context.WithTimeout(ctx, 30*time.Second)
+		kc, err := cli.GetKdcCluster(reqCtx, ns, name)
+		reqCancel()
+		switch {
+		case err == nil:
+			last, lastErr = kc, nil
+			if done(kc) {
+				return kc, nil
+			}
+			if kc.Status.Phase == "Failed" {
+				msg := fmt.Sprintf("Managed Cluster %s failed", name)
+				if pending := notTrueConditions(kc.Status.Conditions); pending != "" {
+					msg += ": " + pending
+				}
+				return nil, errors.New(msg)
+			}
+		case k8sapi.IsNotFound(err):
+			return nil, fmt.Errorf("Managed Cluster %s not found", name)
+		default:
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			msg := fmt.Sprintf("timed out after %s waiting for Managed Cluster %s: %s", timeout, name, what)
+			if last != nil {
+				msg += fmt.Sprintf("; phase %s", fmtCoalesce(last.Status.Phase, "unknown"))
+				if pending := notTrueConditions(last.Status.Conditions); pending != "" {
+					msg += "; pending: " + pending
+				}
+			}
+			if lastErr != nil {
+				msg += fmt.Sprintf(" (last error: %v)", lastErr)
+			}
+			return nil, fmt.Errorf("%s", msg)
+		case <-time.After(clusterPollInterval):
+		}
+	}
+}
+
+// -------- rendering ------------------------------------------------
+
+func printClusterTable(ns string, items []k8sapi.KdcCluster) error {
+	if len(items) == 0 {
+		fmt.Println("No Managed Clusters in", ns)
+		return nil
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Metadata.Name < items[j].Metadata.Name })
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVERSION\tWORKERS\tPOOLS\tPHASE\tENDPOINT\tAGE")
+	for i := range items {
+		kc := &items[i]
+		ready, desired := 0, 0
+		for _, p := range kc.Spec.Workers {
+			desired += p.Replicas
+			if ps := kc.WorkerPoolStatus(p.Name); ps != nil {
+				ready += ps.ReadyReplicas
+			}
+		}
+		ver := kc.Spec.Version
+		if running := kc.ControlPlaneVersion(); running != ver {
+			ver = running + "→" + ver
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d/%d\t%s\t%s\t%s\t%s\n",
+			kc.Metadata.Name,
+			ver,
+			ready, desired,
+			fmtCoalesce(strings.Join(workerPoolNames(kc), ","), "-"),
+			fmtCoalesce(kc.Status.Phase, "-"),
+			fmtCoalesce(kc.Status.PublicAPIEndpoint, kc.Status.Endpoint, "-"),
+			formatAge(kc.Metadata.CreationTimestamp),
+		)
+	}
+	return w.Flush()
+}
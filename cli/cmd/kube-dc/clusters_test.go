@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+)
+
+func testClusterOpts() clusterOpts {
+	return clusterOpts{
+		Name: "dev", Namespace: "acme-web", Version: "v1.36.1",
+		Image:                "docker.io/shalb/ubuntu-2404-container-disk:v1.36.1",
+		ControlPlaneReplicas: 2, Pool: "workers", Workers: 3, CPU: 2, Memory: "8Gi", Disk: "30Gi",
+		StorageType: "datavolume", Architecture: "amd64", Network: "cloud",
+		ServiceCIDR: "10.96.0.0/16", PodCIDR: "10.244.0.0/16", Expose: true,
+	}
+}
+
+func TestBuildKdcCluster(t *testing.T) {
+	kc, err := buildKdcCluster(testClusterOpts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kc.Metadata.Annotations[k8sapi.KdcClusterExposeRouteAnnotation] != "true" || !kc.Spec.EnableClusterAPI {
+		t.Errorf("exposure and Cluster API: %+v", kc)
+	}
+	if p := kc.Spec.Workers[0]; p.Replicas != 3 || p.InfrastructureProvider != "kubevirt" || p.Image == "" {
+		t.Errorf("pool: %+v", p)
+	}
+	if kc.Spec.DataStore != nil || kc.Spec.Encryption != nil {
+		t.Errorf("shared datastore and no encryption by default: %+v", kc.Spec)
+	}
+
+	for name, mutate := range map[string]func(*clusterOpts){
+		"one-letter name": func(o *clusterOpts) { o.Name = "d" },
+		"long name":       func(o *clusterOpts) { o.Name = "development-1" },
+		"bare version":    func(o *clusterOpts) { o.Version = "1.36" },
+		"no image":        func(o *clusterOpts) { o.Image = "" },
+		"six replicas":    func(o *clusterOpts) { o.ControlPlaneReplicas = 6 },
+		"bad memory":      func(o *clusterOpts) { o.Memory = "lots" },
+		"bad storage":     func(o *clusterOpts) { o.StorageType = "nfs" },
+		"bad network":     func(o *clusterOpts) { o.Network = "private" },
+	} {
+		o := testClusterOpts()
+		mutate(&o)
+		if _, err := buildKdcCluster(o); err == nil {
+			t.Errorf("%s: should be refused", name)
+		}
+	}
+}
+
+func TestPickDataStorePort(t *testing.T) {
+	ds := func(port int, eip string) k8sapi.KdcCluster {
+		return k8sapi.KdcCluster{Spec: k8sapi.KdcClusterSpec{DataStore: &k8sapi.KdcClusterDataStore{Dedicated: true, EIPName: eip, Port: port}}}
+	}
+	existing := []k8sapi.KdcCluster{ds(32380, "default-gw"), ds(32381, ""), ds(32382, "other"), {}}
+	if p, err := pickDataStorePort(existing, "default-gw"); err != nil || p != 32382 {
+		t.Errorf("port = %d, %v", p, err)
+	}
+}
+
+// testManagedCluster is v1.35.0 with four pools: two that track the
+// cluster version, one pinned two minors back and one on CloudSigma.
+func testManagedCluster() *k8sapi.KdcCluster {
+	kc := &k8sapi.KdcCluster{Metadata: k8sapi.ObjectMeta{Name: "dev", Namespace: "acme-web"}}
+	kc.Spec.Version = "v1.35.0"
+	kc.Spec.Workers = []k8sapi.WorkerPool{
+		{Name: "workers", Replicas: 3, Image: "docker.io/shalb/ubuntu-2404-container-disk:v1.35.2", InfrastructureProvider: "kubevirt"},
+		{Name: "arm", Replicas: 1, Architecture: "arm64"},
+		{Name: "legacy", Replicas: 1, Version: "v1.33.4", Image: "quay.io/capk/ubuntu-2404-container-disk:v1.33.4"},
+		{Name: "cs", Replicas: 2, InfrastructureProvider: "cloudsigma"},
+	}
+	kc.Status.Phase = "Ready"
+	kc.Status.ControlPlaneReady = true
+	kc.Status.ControlPlane = &k8sapi.KdcControlPlaneStatus{Version: "v1.35.0"}
+	kc.Status.WorkerPools = []k8sapi.WorkerPoolStatus{
+		{Name: "workers", Replicas: 3, ReadyReplicas: 3, ObservedVersion: "v1.35.0"},
+		{Name: "arm", Replicas: 1, ReadyReplicas: 1, ObservedVersion: "v1.35.0"},
+		{Name: "legacy", Replicas: 1, ReadyReplicas: 1, ObservedVersion: "v1.33.4"},
+		{Name: "cs", Replicas: 2, ReadyReplicas: 2},
+	}
+	return kc
+}
+
+func TestPlanClusterUpgrade(t *testing.T) {
+	kc := testManagedCluster()
+	kc.Spec.Workers[2].Version = "v1.34.1"
+	kc.Status.WorkerPools[2].ObservedVersion = "v1.34.1"
+	up, err := planClusterUpgrade(kc, "v1.36.1", "amd64-image", map[string]string{"arm": "arm64-image"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, op := range up.Ops {
+		got = append(got, op.Op+" "+op.Path+"="+op.Value.(string))
+	}
+	want := "test /spec/version=v1.35.0,replace /spec/version=v1.36.1," +
+		"test /spec/workers/0/name=workers,replace /spec/workers/0/image=amd64-image," +
+		"test /spec/workers/1/name=arm,add /spec/workers/1/image=arm64-image"
+	if strings.Join(got, ",") != want {
+		t.Errorf("ops = %v", got)
+	}
+	if len(up.Kept) != 2 || !strings.Contains(up.Kept["legacy"], "v1.34.1") {
+		t.Errorf("kept = %v", up.Kept)
+	}
+
+	if _, err := planClusterUpgrade(kc, "v1.35.2", "amd64-image", nil); err != nil {
+		t.Errorf("a patch release within the minor: %v", err)
+	}
+
+	for _, c := range []struct {
+		target, image string
+		images        map[string]string
+		mutate        func(*k8sapi.KdcCluster)
+		want          string
+	}{
+		{"v1.37.0", "img", nil, nil, "one minor at a time"},
+		{"v2.0.0", "img", nil, nil, "one minor at a time"},
+		{"v1.34.9", "img", nil, nil, "downgrades are not supported"},
+		{"v1.35.0", "img", nil, nil, "already runs"},
+		{"1.36.1", "img", nil, nil, "invalid --version"},
+		{"v1.36.1", "", nil, nil, "never derived"},
+		{"v1.36.1", "img", map[string]string{"cs": "img"}, nil, "CloudSigma"},
+		{"v1.36.1", "img", map[string]string{"gpu": "img"}, nil, `no worker pool "gpu"`},
+		// The pinned pool is already two minors behind v1.35.
+		{"v1.36.1", "img", nil, func(kc *k8sapi.KdcCluster) {}, "raise or clear its version pin"},
+		// A previous upgrade's worker rollout has not finished.
+		{"v1.36.1", "img", nil, func(kc *k8sapi.KdcCluster) {
+			kc.Spec.Workers[2].Version = ""
+			kc.Status.WorkerPools[2].ObservedVersion = "v1.33.4"
+		}, "pool legacy runs v1.33.4, more than 2 minor versions behind v1.36.1"},
+		{"v1.36.1", "img", nil, func(kc *k8sapi.KdcCluster) {
+			kc.Status.ControlPlane.Version = "v1.34.2"
+		}, "still moving from v1.34.2 to v1.35.0"},
+		{"v1.36.1", "img", nil, func(kc *k8sapi.KdcCluster) {
+			kc.Status.WorkerPools[0].ObservedVersion = "v1.37.0"
+		}, "newer than the control plane"},
+	} {
+		kc := testManagedCluster()
+		if c.mutate == nil {
+			kc.Spec.Workers[2].Version = "v1.34.1"
+			kc.Status.WorkerPools[2].ObservedVersion = "v1.34.1"
+		} else {
+			c.mutate(kc)
+		}
+		_, err := planClusterUpgrade(kc, c.target, c.image, c.images)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: error %v, want %q", c.target, err, c.want)
+		}
+	}
+}
+
+func TestParsePoolImages(t *testing.T) {
+	got, err := parsePoolImages([]string{"arm=repo/img:v1.36.1", "gpu=repo/gpu:v1"})
+	if err != nil || got["arm"] != "repo/img:v1.36.1" || len(got) != 2 {
+		t.Errorf("got %v, %v", got, err)
+	}
+	for _, bad := range []string{"arm", "=img", "arm="} {
+		if _, err := parsePoolImages([]string{bad}); err == nil {
+			t.Errorf("%q should be refused", bad)
+		}
+	}
+}
+
+func TestClusterScalePatch(t *testing.T) {
+	kc := testManagedCluster()
+	if i, err := resolveWorkerPool(kc, ""); err == nil || !strings.Contains(err.Error(), "workers, arm, legacy, cs") {
+		t.Errorf("ambiguous pool: %d, %v", i, err)
+	}
+	if _, err := resolveWorkerPool(kc, "gpu"); err == nil {
+		t.Error("unknown pool must be refused")
+	}
+	i, _ := resolveWorkerPool(kc, "arm")
+	ops, err := clusterScalePatch(kc, i, 0)
+	if err != nil {
+		t.Fatalf("arm can scale to 0 while workers is Ready: %v", err)
+	}
+	if ops[0].Path != "/spec/workers/1/name" || ops[1].Path != "/spec/workers/1/replicas" || ops[1].Value != 0 {
+		t.Errorf("ops = %+v", ops)
+	}
+
+	// Only workers has Ready nodes: it cannot go to 0.
+	kc.Status.WorkerPools = kc.Status.WorkerPools[:1]
+	if _, err := clusterScalePatch(kc, 0, 0); err == nil || !strings.Contains(err.Error(), "last pool") {
+		t.Errorf("last Ready pool: %v", err)
+	}
+
+	kc.Spec.Workers[0].Autoscaling = &k8sapi.WorkerAutoscaling{Enabled: true, MinReplicas: 2, MaxReplicas: 8}
+	if _, err := clusterScalePatch(kc, 0, 9); err == nil || !strings.Contains(err.Error(), "between 2 and 8") {
+		t.Errorf("outside autoscaling bounds: %v", err)
+	}
+	if _, err := clusterScalePatch(kc, 0, 5); err != nil {
+		t.Errorf("within bounds: %v", err)
+	}
+	kc.Spec.Workers[0].Autoscaling.Mode = "ClusterAutoscaler"
+	if _, err := clusterScalePatch(kc, 0, 5); err == nil || !strings.Contains(err.Error(), "platform owns") {
+		t.Errorf("ClusterAutoscaler: %v", err)
+	}
+}
+
+// TestPatchKdcClusterJSON checks what reaches the API server: a JSON
+// Patch body in which a replace to 0 keeps its value.
+func TestPatchKdcClusterJSON(t *testing.T) {
+	var contentType, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		_ = json.NewEncoder(w).Encode(k8sapi.KdcCluster{})
+	}))
+	defer srv.Close()
+	cli, _ := k8sapi.New(srv.URL, "token", "", false)
+	kc := testManagedCluster()
+	ops, _ := clusterScalePatch(kc, 1, 0)
+	if _, err := cli.PatchKdcClusterJSON(context.Background(), "acme-web", "dev", ops); err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "application/json-patch+json" {
+		t.Errorf("content type %q", contentType)
+	}
+	if !strings.Contains(body, `{"op":"replace","path":"/spec/workers/1/replicas","value":0}`) {
+		t.Errorf("body %s", body)
+	}
+}
+
+func TestClusterContextNames(t *testing.T) {
+	scope := &secretsScope{Domain: "kube-dc.cloud", Namespace: "acme-web", Realm: "acme"}
+	p, err := clusterContextParams(scope, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ContextName != "kube-dc/kube-dc.cloud/acme/web/cluster/dev" ||
+		p.UserName != "kube-dc@kube-dc.cloud/acme/web/cluster/dev" ||
+		p.ClusterName != "kube-dc-kube-dc.cloud-acme-web-cluster-dev" {
+		t.Errorf("params = %+v", p)
+	}
+	if project, ok := projectOfClusterContext(p.ContextName); !ok || project != "kube-dc.cloud/acme/web" {
+		t.Errorf("project of %s = %q, %v", p.ContextName, project, ok)
+	}
+	for _, name := range []string{"kube-dc/kube-dc.cloud/acme/web", "kube-dc/kube-dc.cloud/admin", "kube-dc/a/b/c/d/e"} {
+		if _, ok := projectOfClusterContext(name); ok {
+			t.Errorf("%s is not a Managed Cluster context", name)
+		}
+	}
+	if got := realmFromContext(p.ContextName); got != "" {
+		t.Errorf("realm of a cluster context = %q", got)
+	}
+
+	scope.Realm = adminRealm
+	if _, err := clusterContextParams(scope, "dev"); err == nil {
+		t.Error("the admin context has no Organization to name the context after")
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/shalb/kube-dc/cli/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// credentialEncryptionCmds are the `kube-dc config` verbs that manage
+// encryption at rest for ~/.kube-dc/credentials/.
+//
+//   - encrypt-credentials   switch the store to age, passphrase or helper
+//     mode and re-encrypt every cached credential
+//   - decrypt-credentials   switch back to plaintext
+//   - unlock-credentials    cache the passphrase key for this session
+//   - lock-credentials      forget it again
+//
+// Files written before encryption was turned on are also migrated lazily the
+// first time they are read, so an encrypt-credentials that skipped a file (or
+// a login from an older CLI) does not leave plaintext behind for long.
+func credentialEncryptionCmds() []*cobra.Command {
+	var ageIdentity string
+	var passphrase bool
+	var helper string
+
+	encryptCmd := &cobra.Command{
+		Use:   "encrypt-credentials",
+		Short: "Encrypt the cached credentials at rest",
+		Long: `Encrypt every file under ~/.kube-dc/credentials/ and keep future logins
+and token refreshes encrypted. Pick exactly one key source:
+
+  --age-identity <file>   age identity file; needs the age binary on PATH.
+                          Files are encrypted to the identity's own recipient.
+  --passphrase            a passphrase, asked for once per login session.
+                          Non-interactive callers can set ` + config.PassphraseEnv + `.
+  --helper <command>      a git-credential-style helper that stores a generated
+                          key, e.g. "git credential-osxkeychain".
+
+kubectl keeps working unchanged: the credential plugin decrypts transparently.
+With --passphrase, run 'kube-dc config unlock-credentials' in a terminal once
+per session so kubectl, which cannot prompt, finds the key cached.`,
+		Example: `  kube-dc config encrypt-credentials --age-identity ~/.config/kube-dc/age.key
+  kube-dc config encrypt-credentials --passphrase
+  kube-dc config encrypt-credentials --helper "git credential-libsecret"`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			enc := &config.Encryption{}
+			set := 0
+			if ageIdentity != "" {
+				abs, err := filepath.Abs(ageIdentity)
+				if err != nil {
+					return err
+				}
+				enc.Mode, enc.AgeIdentity = config.EncryptionAge, abs
+				set++
+			}
+			if passphrase {
+				enc.Mode = config.EncryptionPassphrase
+				set++
+			}
+			if helper != "" {
+				enc.Mode, enc.Helper = config.EncryptionHelper, helper
+				set++
+			}
+			if set != 1 {
+				return fmt.Errorf("pass exactly one of --age-identity, --passphrase or --helper")
+			}
+			cmd.SilenceUsage = true
+			return runSetCredentialEncryption(enc)
+		},
+	}
+	encryptCmd.Flags().StringVar(&ageIdentity, "age-identity", "", "age identity file to encrypt to and decrypt with")
+	encryptCmd.Flags().BoolVar(&passphrase, "passphrase", false, "Encrypt with a key derived from a passphrase")
+	encryptCmd.Flags().StringVar(&helper, "helper", "", "git-credential-style helper command that holds the key")
+
+	decryptCmd := &cobra.Command{
+		Use:   "decrypt-credentials",
+		Short: "Store cached credentials as plaintext again",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return runSetCredentialEncryption(&config.Encryption{Mode: config.EncryptionNone})
+		},
+	}
+
+	unlockCmd := &cobra.Command{
+		Use:   "unlock-credentials",
+		Short: "Cache the credential store key for this session",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			credMgr, err := config.NewCredentialsManager()
+			if err != nil {
+				return err
+			}
+			if err := credMgr.UnlockEncryption(); err != nil {
+				return err
+			}
+			fmt.Println("Credential store unlocked.")
+			return nil
+		},
+	}
+
+	lockCmd := &cobra.Command{
+		Use:   "lock-credentials",
+		Short: "Forget the session-cached credential store key",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			credMgr, err := config.NewCredentialsManager()
+			if err != nil {
+				return err
+			}
+			if err := credMgr.LockEncryption(); err != nil {
+				return err
+			}
+			fmt.Println("Credential store locked.")
+			return nil
+		},
+	}
+
+	return []*cobra.Command{encryptCmd, decryptCmd, unlockCmd, lockCmd}
+}
+
+func runSetCredentialEncryption(enc *config.Encryption) error {
+	credMgr, err := config.NewCredentialsManager()
+	if err != nil {
+		return fmt.Errorf("failed to initialize credentials manager: %w", err)
+	}
+	n, err := credMgr.SetEncryption(enc)
+	if err != nil {
+		return err
+	}
+	mode := enc.Mode
+	if mode == config.EncryptionNone {
+		mode = "plaintext"
+	}
+	fmt.Printf("Credential store is now %s; %d cached credential file(s) rewritten.\n", mode, n)
+	return nil
+}
+
+// encryptionLabel is the one-line store status `config show` prints.
+func encryptionLabel(credMgr *config.CredentialsManager) string {
+	enc, err := credMgr.EncryptionSettings()
+	if err != nil {
+		return "unknown (" + err.Error() + ")"
+	}
+	switch enc.Mode {
+	case config.EncryptionNone:
+		return "none (plaintext, owner-only file permissions)"
+	case config.EncryptionAge:
+		return "age (" + enc.AgeIdentity + ")"
+	case config.EncryptionHelper:
+		return "helper (" + enc.Helper + ")"
+	default:
+		return enc.Mode
+	}
+}
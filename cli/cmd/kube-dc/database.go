@@ -0,0 +1,587 @@
+// `kube-dc db` instance lifecycle — KdcDatabase (db.kube-dc.com/v1alpha1)
+// in the current Project, straight against the kube-apiserver with the
+// user's JWT; db-manager turns each one into a CNPG Cluster or a
+// MariaDB CR. The manifest is the one skills/create-database/
+// pg-template.yaml and mariadb-template.yaml describe.
+//
+// Verbs:
+//   create    — KdcDatabase, then wait for phase Ready           (k8s)
+//   list      — databases with engine, size, phase, endpoint      (k8s)
+//   describe  — one database: sizing, endpoints, backup, status   (k8s)
+//   resize    — merge-patch cpu/memory/storage/replicas, wait     (k8s)
+//   delete    — the KdcDatabase (db-manager removes the engine)   (k8s)
+//   connect   — port-forward + psql/mariadb (database_connect.go) (backend + k8s)
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// dbPollInterval is how often the wait loop re-reads the database. A
+// var so tests can shorten it.
+var dbPollInterval = 5 * time.Second
+
+// dbEngineVersions are the major versions db-manager supports;
+// dbDefaultVersion is the templates' choice.
+var (
+	dbEngineVersions = map[string][]string{
+		k8sapi.DBEnginePostgreSQL: {"14", "15", "16", "17"},
+		k8sapi.DBEngineMariaDB:    {"10.11", "11.4"},
+	}
+	dbDefaultVersion = map[string]string{
+		k8sapi.DBEnginePostgreSQL: "16",
+		k8sapi.DBEngineMariaDB:    "11.4",
+	}
+)
+
+// dbOpts are the `db create` flags.
+type dbOpts struct {
+	Name, Namespace      string
+	Engine, Version      string
+	Replicas             int
+	CPU, Memory, Storage string
+	StorageClass         string
+	Database, Username   string
+	Expose               string
+	NoBackup             bool
+	BackupSchedule       string
+	BackupRetentionDays  int
+}
+
+// normalizeDBEngine accepts the engine under its common names.
+func normalizeDBEngine(engine string) (string, error) {
+	switch strings.ToLower(engine) {
+	case "", "postgresql", "postgres", "pg":
+		return k8sapi.DBEnginePostgreSQL, nil
+	case "mariadb", "mysql":
+		return k8sapi.DBEngineMariaDB, nil
+	}
+	return "", fmt.Errorf("invalid --engine %q (want postgresql or mariadb)", engine)
+}
+
+// buildKdcDatabase validates the flags and assembles the manifest.
+// Pure — no I/O.
+func buildKdcDatabase(opts dbOpts) (*k8sapi.KdcDatabase, error) {
+	if !projectNameRE.MatchString(opts.Name) || len(opts.Name) > 50 {
+		return nil, fmt.Errorf("invalid database name %q: use lowercase letters, digits and '-', at most 50 characters", opts.Name)
+	}
+	engine, err := normalizeDBEngine(opts.Engine)
+	if err != nil {
+		return nil, err
+	}
+	version := fmtCoalesce(opts.Version, dbDefaultVersion[engine])
+	if !containsString(dbEngineVersions[engine], version) {
+		return nil, fmt.Errorf("%s %s is not supported (available: %s)", engine, version, strings.Join(dbEngineVersions[engine], ", "))
+	}
+	replicas := opts.Replicas
+	if replicas == 0 {
+		// The templates' defaults: an HA pair for PostgreSQL, a single
+		// MariaDB (whose Service layout changes with replicas).
+		replicas = 2
+		if engine == k8sapi.DBEngineMariaDB {
+			replicas = 1
+		}
+	}
+	if replicas < 1 || replicas > 9 {
+		return nil, fmt.Errorf("invalid --replicas %d: use 1 to 9", replicas)
+	}
+	for flag, v := range map[string]string{"--cpu": opts.CPU, "--memory": opts.Memory, "--storage": opts.Storage} {
+		if _, err := resource.ParseQuantity(v); err != nil {
+			return nil, fmt.Errorf("invalid %s %q: use a quantity such as 2Gi", flag, v)
+		}
+	}
+	switch opts.Expose {
+	case "internal", "loadbalancer":
+	case "gateway":
+		return nil, errors.New("--expose gateway is manifest-only (PostgreSQL 17 direct TLS); see skills/create-database")
+	default:
+		return nil, fmt.Errorf("invalid --expose %q (want internal or loadbalancer)", opts.Expose)
+	}
+	if opts.BackupRetentionDays < 1 || opts.BackupRetentionDays > 365 {
+		return nil, fmt.Errorf("invalid --backup-retention %d: use 1 to 365 days", opts.BackupRetentionDays)
+	}
+
+	db := &k8sapi.KdcDatabase{
+		Metadata: k8sapi.ObjectMeta{Name: opts.Name, Namespace: opts.Namespace},
+		Spec: k8sapi.KdcDatabaseSpec{
+			Engine:           engine,
+			Version:          version,
+			Replicas:         replicas,
+			CPU:              k8sapi.Quantity(opts.CPU),
+			Memory:           k8sapi.Quantity(opts.Memory),
+			Storage:          k8sapi.Quantity(opts.Storage),
+			StorageClassName: opts.StorageClass,
+			DatabaseName:     opts.Database,
+			Username:         opts.Username,
+			Expose:           &k8sapi.KdcDatabaseExpose{Type: opts.Expose},
+			Backup:           &k8sapi.KdcDatabaseBackup{Enabled: !opts.NoBackup},
+		},
+	}
+	if !opts.NoBackup {
+		db.Spec.Backup.Schedule = opts.BackupSchedule
+		db.Spec.Backup.RetentionDays = opts.BackupRetentionDays
+	}
+	return db, nil
+}
+
+// -------- create ---------------------------------------------------
+
+func dbCreateCmd() *cobra.Command {
+	opts := dbOpts{}
+	var outFlag string
+	var noWait bool
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create a managed PostgreSQL or MariaDB database and wait for it",
+		Long: `Create a KdcDatabase in the current Project and wait until it is Ready.
+
+PostgreSQL (14, 15, 16, 17; default 16) defaults to two replicas with
+failover; MariaDB (10.11, 11.4; default 11.4) to one. CPU and memory are both
+request and limit per instance; storage is per instance and can only grow
+later. Daily backups at 02:00 with 7 days' retention are on unless
+--no-backup is given.
+
+--expose loadbalancer gives the database a dedicated public address, which
+counts against the Organization's public IPv4 quota. Applications inside the
+Project use the internal endpoint; from a workstation, kube-dc db connect
+works without one.`,
+		Example: `  kube-dc db create orders-pg
+  kube-dc db create orders-pg --engine postgresql --version 17 --cpu 2 --memory 4Gi --storage 50Gi
+  kube-dc db create cms-db --engine mariadb --database wordpress --username wp`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			opts.Name = args[0]
+			db, err := buildKdcDatabase(opts)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(opts.Namespace)
+			if err != nil {
+				return err
+			}
+			db.Metadata.Namespace = scope.Namespace
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			created, err := cli.CreateKdcDatabase(ctx, db)
+			if err != nil {
+				return fmt.Errorf("create KdcDatabase %s: %w", opts.Name, err)
+			}
+			summary := fmt.Sprintf("%s %s, %d replica(s), %s CPU / %s memory / %s storage each",
+				db.Spec.Engine, db.Spec.Version, db.Spec.Replicas, db.Spec.CPU, db.Spec.Memory, db.Spec.Storage)
+			if noWait {
+				if out != outTable {
+					return printSerialized(out, created)
+				}
+				fmt.Printf("Created database %s (%s)\n", opts.Name, summary)
+				fmt.Printf("Not waiting; follow with `kube-dc db describe %s`\n", opts.Name)
+				return nil
+			}
+			if out == outTable {
+				fmt.Printf("Created database %s (%s); waiting for it to be Ready...\n", opts.Name, summary)
+			}
+			ready, err := waitForDatabase(context.Background(), cli, scope.Namespace, opts.Name, timeout)
+			if err != nil {
+				return err
+			}
+			if out != outTable {
+				return printSerialized(out, ready)
+			}
+			fmt.Printf("Database %s is Ready at %s\n", opts.Name, fmtCoalesce(ready.Status.Endpoint, ready.InternalEndpoint()))
+			fmt.Printf("Connect with `kube-dc db connect %s`\n", opts.Name)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVar(&opts.Engine, "engine", "postgresql", "Database engine: postgresql|mariadb")
+	cmd.Flags().StringVar(&opts.Version, "version", "", "Engine major version (default: 16 for PostgreSQL, 11.4 for MariaDB)")
+	cmd.Flags().IntVar(&opts.Replicas, "replicas", 0, "Instances, 1-9 (default: 2 for PostgreSQL, 1 for MariaDB)")
+	cmd.Flags().StringVar(&opts.CPU, "cpu", "1", "CPU per instance, e.g. 500m or 2")
+	cmd.Flags().StringVar(&opts.Memory, "memory", "2Gi", "Memory per instance")
+	cmd.Flags().StringVar(&opts.Storage, "storage", "20Gi", "Storage per instance; can only grow later")
+	cmd.Flags().StringVar(&opts.StorageClass, "storage-class", "", "StorageClass for the data volumes (default: the cluster default)")
+	cmd.Flags().StringVar(&opts.Database, "database", "app", "Name of the application database")
+	cmd.Flags().StringVar(&opts.Username, "username", "app", "Application user")
+	cmd.Flags().StringVar(&opts.Expose, "expose", "internal", "Exposure: internal|loadbalancer")
+	cmd.Flags().BoolVar(&opts.NoBackup, "no-backup", false, "Disable scheduled backups")
+	cmd.Flags().StringVar(&opts.BackupSchedule, "backup-schedule", "0 2 * * *", "Backup cron schedule")
+	cmd.Flags().IntVar(&opts.BackupRetentionDays, "backup-retention", 7, "Days to keep backups, 1-365")
+	cmd.Flags().BoolVar(&noWait, "no-wait", false, "Return once the database is created instead of waiting for Ready")
+	cmd.Flags().DurationVar(&timeout, "timeout", 20*time.Minute, "How long to wait for Ready")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+// -------- list -----------------------------------------------------
+
+func dbListCmd() *cobra.Command {
+	var namespace, outFlag string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List databases in the current Project",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			list, err := cli.ListKdcDatabases(ctx, scope.Namespace)
+			if err != nil {
+				return err
+			}
+			if out != outTable {
+				return printSerialized(out, list)
+			}
+			return printDatabaseTable(scope.Namespace, list.Items)
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+// -------- describe -------------------------------------------------
+
+func dbDescribeCmd() *cobra.Command {
+	var namespace, outFlag string
+	cmd := &cobra.Command{
+		Use:     "describe <name>",
+		Aliases: []string{"show"},
+		Short:   "Show one database: sizing, endpoints, backups and conditions",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			db, err := cli.GetKdcDatabase(ctx, scope.Namespace, args[0])
+			if err != nil {
+				return err
+			}
+			if out != outTable {
+				return printSerialized(out, db)
+			}
+			printDatabaseDetail(db)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+// -------- resize ---------------------------------------------------
+
+// dbResizePatch validates a resize against the current spec and builds
+// the merge patch. Storage can only grow (it is a PVC expansion).
+func dbResizePatch(db *k8sapi.KdcDatabase, cpu, memory, storage string, replicas int) (map[string]any, error) {
+	spec := map[string]any{}
+	for _, f := range []struct{ flag, key, value string }{
+		{"--cpu", "cpu", cpu}, {"--memory", "memory", memory}, {"--storage", "storage", storage},
+	} {
+		if f.value == "" {
+			continue
+		}
+		if _, err := resource.ParseQuantity(f.value); err != nil {
+			return nil, fmt.Errorf("invalid %s %q: use a quantity such as 2Gi", f.flag, f.value)
+		}
+		spec[f.key] = f.value
+	}
+	if storage != "" {
+		want := resource.MustParse(storage)
+		if have, err := resource.ParseQuantity(string(db.Spec.Storage)); err == nil && want.Cmp(have) < 0 {
+			return nil, fmt.Errorf("--storage %s is smaller than the current %s; storage can only grow", storage, db.Spec.Storage)
+		}
+	}
+	if replicas != 0 {
+		if replicas < 1 || replicas > 9 {
+			return nil, fmt.Errorf("invalid --replicas %d: use 1 to 9", replicas)
+		}
+		spec["replicas"] = replicas
+	}
+	if len(spec) == 0 {
+		return nil, errors.New("nothing to change: pass --cpu, --memory, --storage or --replicas")
+	}
+	return map[string]any{"spec": spec}, nil
+}
+
+func dbResizeCmd() *cobra.Command {
+	var namespace, cpu, memory, storage string
+	var replicas int
+	var noWait bool
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "resize <name>",
+		Short: "Change a database's CPU, memory, storage or replicas",
+		Long: `Change the per-instance CPU, memory or storage, or the number of replicas, and
+wait until db-manager has rolled the change out.
+
+Storage can only grow. CPU and memory changes restart the instances one at a
+time; with a single replica that means a short outage. Moving a MariaDB
+database between one and several replicas changes its write Service from
+<name> to <name>-primary.`,
+		Example: `  kube-dc db resize orders-pg --storage 50Gi
+  kube-dc db resize orders-pg --cpu 2 --memory 4Gi --replicas 3`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if cpu == "" && memory == "" && storage == "" && replicas == 0 {
+				return errors.New("nothing to change: pass --cpu, --memory, --storage or --replicas")
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			db, err := cli.GetKdcDatabase(ctx, scope.Namespace, name)
+			if err != nil {
+				return err
+			}
+			patch, err := dbResizePatch(db, cpu, memory, storage, replicas)
+			if err != nil {
+				return err
+			}
+			if _, err := cli.PatchKdcDatabase(ctx, scope.Namespace, name, patch); err != nil {
+				return err
+			}
+			if noWait {
+				fmt.Printf("Resizing database %s; follow with `kube-dc db describe %s`\n", name, name)
+				return nil
+			}
+			fmt.Printf("Resizing database %s; waiting for it to be Ready...\n", name)
+			if _, err := waitForDatabase(context.Background(), cli, scope.Namespace, name, timeout); err != nil {
+				return err
+			}
+			fmt.Printf("Database %s is Ready\n", name)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVar(&cpu, "cpu", "", "CPU per instance")
+	cmd.Flags().StringVar(&memory, "memory", "", "Memory per instance")
+	cmd.Flags().StringVar(&storage, "storage", "", "Storage per instance (grow only)")
+	cmd.Flags().IntVar(&replicas, "replicas", 0, "Instances, 1-9")
+	cmd.Flags().BoolVar(&noWait, "no-wait", false, "Return once the change is submitted")
+	cmd.Flags().DurationVar(&timeout, "timeout", 20*time.Minute, "How long to wait for Ready")
+	return cmd
+}
+
+// -------- delete ---------------------------------------------------
+
+func dbDeleteCmd() *cobra.Command {
+	var namespace string
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a database and its data",
+		Long: `Delete a KdcDatabase. db-manager removes the engine instances and their
+volumes; backups already in object storage are kept until their retention
+expires.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if !yes {
+				fmt.Fprintf(os.Stderr, "Delete database %s and its data? Re-run with --yes to confirm.\n", name)
+				return fmt.Errorf("not confirmed")
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			if err := cli.DeleteKdcDatabase(ctx, scope.Namespace, name); err != nil {
+				return err
+			}
+			fmt.Printf("Deleted database %s\n", name)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().BoolVar(&yes, "yes", false, "Confirm the deletion")
+	return cmd
+}
+
+// -------- wait -----------------------------------------------------
+
+// waitForDatabase polls until db-manager has observed the latest spec
+// and reports Ready. Failed ends the wait early; on timeout the error
+// carries the last phase and the conditions that are not True.
+func waitForDatabase(ctx context.Context, cli *k8sapi.Client, ns, name string, timeout time.Duration) (*k8sapi.KdcDatabase, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var last *k8sapi.KdcDatabase
+	var lastErr error
+	for {
+		reqCtx, reqCancel := context.WithTimeout(ctx, 30*time.Second)
+		db, err := cli.GetKdcDatabase(reqCtx, ns, name)
+		reqCancel()
+		switch {
+		case err == nil:
+			last, lastErr = db, nil
+			current := db.Status.ObservedGeneration >= db.Metadata.Generation
+			if current && db.Status.Phase == "Ready" {
+				return db, nil
+			}
+			if current && db.Status.Phase == "Failed" {
+				msg := fmt.Sprintf("database %s failed", name)
+				if pending := notTrueConditions(db.Status.Conditions); pending != "" {
+					msg += ": " + pending
+				}
+				return nil, errors.New(msg)
+			}
+		case k8sapi.IsNotFound(err):
+			return nil, fmt.Errorf("database %s not found", name)
+		default:
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			msg := fmt.Sprintf("timed out after %s waiting for database %s to be Ready", timeout, name)
+			if last != nil {
+				msg += fmt.Sprintf("; phase %s", fmtCoalesce(last.Status.Phase, "unknown"))
+				if pending := notTrueConditions(last.Status.Conditions); pending != "" {
+					msg += "; pending: " + pending
+				}
+			}
+			if lastErr != nil {
+				msg += fmt.Sprintf(" (last error: %v)", lastErr)
+			}
+			return nil, fmt.Errorf("%s", msg)
+		case <-time.After(dbPollInterval):
+		}
+	}
+}
+
+// -------- rendering ------------------------------------------------
+
+func printDatabaseTable(ns string, items []k8sapi.KdcDatabase) error {
+	if len(items) == 0 {
+		fmt.Println("No databases in", ns)
+		return nil
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Metadata.Name < items[j].Metadata.Name })
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tENGINE\tVERSION\tREADY\tCPU\tMEMORY\tSTORAGE\tPHASE\tENDPOINT\tAGE")
+	for i := range items {
+		db := &items[i]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d/%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			db.Metadata.Name,
+			db.Spec.Engine,
+			db.Spec.Version,
+			db.Status.ReadyInstances, db.Spec.Replicas,
+			db.Spec.CPU,
+			db.Spec.Memory,
+			db.Spec.Storage,
+			fmtCoalesce(db.Status.Phase, "-"),
+			fmtCoalesce(db.Status.ExternalEndpoint, db.Status.Endpoint, "-"),
+			formatAge(db.Metadata.CreationTimestamp),
+		)
+	}
+	return w.Flush()
+}
+
+func printDatabaseDetail(db *k8sapi.KdcDatabase) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", db.Metadata.Name)
+	fmt.Fprintf(w, "Namespace:\t%s\n", db.Metadata.Namespace)
+	fmt.Fprintf(w, "Engine:\t%s %s\n", db.Spec.Engine, fmtCoalesce(db.Status.Version, db.Spec.Version))
+	fmt.Fprintf(w, "Phase:\t%s\n", fmtCoalesce(db.Status.Phase, "-"))
+	fmt.Fprintf(w, "Replicas:\t%d/%d ready\n", db.Status.ReadyInstances, db.Spec.Replicas)
+	fmt.Fprintf(w, "CPU:\t%s\n", db.Spec.CPU)
+	fmt.Fprintf(w, "Memory:\t%s\n", db.Spec.Memory)
+	storage := string(db.Spec.Storage)
+	if db.Status.StorageUsed != "" {
+		storage += " (" + db.Status.StorageUsed + " used)"
+	}
+	fmt.Fprintf(w, "Storage:\t%s\n", storage)
+	if db.Spec.StorageClassName != "" {
+		fmt.Fprintf(w, "Storage class:\t%s\n", db.Spec.StorageClassName)
+	}
+	fmt.Fprintf(w, "Database:\t%s\n", fmtCoalesce(db.Spec.DatabaseName, "app"))
+	fmt.Fprintf(w, "Username:\t%s\n", fmtCoalesce(db.Spec.Username, "app"))
+	fmt.Fprintf(w, "Endpoint:\t%s\n", fmtCoalesce(db.Status.Endpoint, db.InternalEndpoint()))
+	if db.Spec.Expose != nil && db.Spec.Expose.Type != "internal" {
+		fmt.Fprintf(w, "External (%s):\t%s\n", db.Spec.Expose.Type, fmtCoalesce(db.Status.ExternalEndpoint, "pending"))
+	}
+	if b := db.Spec.Backup; b != nil && b.Enabled {
+		retention := b.RetentionDays
+		if retention == 0 {
+			retention = 7
+		}
+		fmt.Fprintf(w, "Backups:\t%s, kept %d days\n", fmtCoalesce(b.Schedule, "0 2 * * *"), retention)
+		fmt.Fprintf(w, "Latest backup:\t%s\n", fmtCoalesce(db.Status.LatestBackup, "-"))
+	} else {
+		fmt.Fprintf(w, "Backups:\tdisabled\n")
+	}
+	if r := db.Status.Restore; r != nil {
+		fmt.Fprintf(w, "Last restore:\t%s from %s\n", r.Phase, r.BackupName)
+	}
+	fmt.Fprintf(w, "Created:\t%s\n", fmtCoalesce(db.Metadata.CreationTimestamp, "-"))
+	_ = w.Flush()
+
+	if len(db.Status.Conditions) > 0 {
+		fmt.Println("\nConditions:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  TYPE\tSTATUS\tREASON\tMESSAGE")
+		for _, c := range db.Status.Conditions {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", c.Type, c.Status, fmtCoalesce(c.Reason, "-"), truncCLI(c.Message, 80))
+		}
+		_ = w.Flush()
+	}
+}
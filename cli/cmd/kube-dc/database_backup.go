@@ -0,0 +1,828 @@
+// `kube-dc db backup` and `kube-dc db restore` — on-demand engine
+// backups and recovery for a KdcDatabase, without composing the CRs of
+// skills/create-database/backup-restore-patterns.md by hand.
+//
+// PostgreSQL backups are CNPG Backup CRs; MariaDB backups are
+// mariadb-operator PhysicalBackups, whose S3 storage block is copied
+// from the <database>-scheduled one db-manager maintains. Both are shown
+// through one dbBackup view.
+//
+// Verbs:
+//   backup create    — engine backup CR, then wait for it to complete   (k8s)
+//   backup list      — backups of one or all databases                  (k8s)
+//   backup describe  — one backup, plus the PostgreSQL PITR window      (k8s)
+//   restore          — pre-flight the backup and target time, then a new
+//                      KdcDatabase with spec.restoreFrom (--into) or the
+//                      in-place restore-from annotation; watch to Ready (k8s)
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+	"github.com/spf13/cobra"
+)
+
+// Backup phases as the CLI reports them, across both engines.
+const (
+	backupPending   = "Pending"
+	backupRunning   = "Running"
+	backupCompleted = "Completed"
+	backupFailed    = "Failed"
+)
+
+// dbBackup is the engine-neutral view of a CNPG Backup or a
+// PhysicalBackup.
+type dbBackup struct {
+	Name        string `json:"name"`
+	Database    string `json:"database"`
+	Engine      string `json:"engine"`
+	Kind        string `json:"kind"`
+	Trigger     string `json:"trigger"`
+	Phase       string `json:"phase"`
+	StartedAt   string `json:"startedAt,omitempty"`
+	CompletedAt string `json:"completedAt,omitempty"`
+	BeginWAL    string `json:"beginWal,omitempty"`
+	EndWAL      string `json:"endWal,omitempty"`
+	Message     string `json:"message,omitempty"`
+	Created     string `json:"created,omitempty"`
+}
+
+func pgBackupView(b *k8sapi.PGBackup) dbBackup {
+	v := dbBackup{
+		Name:      b.Metadata.Name,
+		Database:  b.Spec.Cluster.Name,
+		Engine:    k8sapi.DBEnginePostgreSQL,
+		Kind:      "Backup",
+		Trigger:   "manual",
+		StartedAt: b.Status.StartedAt,
+		BeginWAL:  b.Status.BeginWAL,
+		EndWAL:    b.Status.EndWAL,
+		Message:   b.Status.Error,
+		Created:   b.Metadata.CreationTimestamp,
+	}
+	if _, ok := b.Metadata.Labels["cnpg.io/scheduled-backup"]; ok {
+		v.Trigger = "scheduled"
+	}
+	switch b.Status.Phase {
+	case "":
+		v.Phase = backupPending
+	case "completed":
+		v.Phase, v.CompletedAt = backupCompleted, b.Status.StoppedAt
+	case "failed", "walArchivingFailing":
+		v.Phase = backupFailed
+	default:
+		v.Phase = backupRunning
+	}
+	return v
+}
+
+func physicalBackupView(b *k8sapi.PhysicalBackup) dbBackup {
+	v := dbBackup{
+		Name:      b.Metadata.Name,
+		Database:  b.Spec.MariaDBRef.Name,
+		Engine:    k8sapi.DBEngineMariaDB,
+		Kind:      "PhysicalBackup",
+		Trigger:   "manual",
+		Phase:     backupPending,
+		StartedAt: b.Status.LastScheduleTime,
+		Created:   b.Metadata.CreationTimestamp,
+	}
+	if b.Spec.Schedule != nil {
+		v.Trigger = "scheduled"
+	}
+	for _, c := range b.Status.Conditions {
+		if c.Type != "Complete" {
+			continue
+		}
+		switch {
+		case c.Status == "True":
+			v.Phase, v.CompletedAt = backupCompleted, c.LastTransitionTime
+		case strings.Contains(strings.ToLower(c.Reason), "fail"):
+			v.Phase, v.Message = backupFailed, fmtCoalesce(c.Message, c.Reason)
+		default:
+			v.Phase, v.Message = backupRunning, c.Message
+		}
+	}
+	return v
+}
+
+// listDBBackups returns the backups of database (all databases when
+// empty) of both engines, newest first per database. An engine whose
+// CRD is not installed contributes none.
+func listDBBackups(ctx context.Context, cli *k8sapi.Client, ns, database string) ([]dbBackup, error) {
+	var out []dbBackup
+	pg, err := cli.ListPGBackups(ctx, ns)
+	switch {
+	case err == nil:
+		for i := range pg.Items {
+			out = append(out, pgBackupView(&pg.Items[i]))
+		}
+	case !k8sapi.IsNotFound(err):
+		return nil, fmt.Errorf("list PostgreSQL backups: %w", err)
+	}
+	mdb, err := cli.ListPhysicalBackups(ctx, ns)
+	switch {
+	case err == nil:
+		for i := range mdb.Items {
+			out = append(out, physicalBackupView(&mdb.Items[i]))
+		}
+	case !k8sapi.IsNotFound(err):
+		return nil, fmt.Errorf("list MariaDB backups: %w", err)
+	}
+	if database != "" {
+		kept := out[:0]
+		for _, b := range out {
+			if b.Database == database {
+				kept = append(kept, b)
+			}
+		}
+		out = kept
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Database != out[j].Database {
+			return out[i].Database < out[j].Database
+		}
+		return out[i].Created > out[j].Created
+	})
+	return out, nil
+}
+
+// backupNotFoundError is getDBBackup's answer when neither engine has
+// the backup.
+type backupNotFoundError struct{ name, ns string }
+
+func (e *backupNotFoundError) Error() string {
+	return fmt.Sprintf("backup %s not found in %s", e.name, e.ns)
+}
+
+// getDBBackup reads one backup. An empty engine tries PostgreSQL, then
+// MariaDB.
+func getDBBackup(ctx context.Context, cli *k8sapi.Client, ns, engine, name string) (*dbBackup, error) {
+	if engine != k8sapi.DBEngineMariaDB {
+		b, err := cli.GetPGBackup(ctx, ns, name)
+		if err == nil {
+			v := pgBackupView(b)
+			return &v, nil
+		}
+		if !k8sapi.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	if engine != k8sapi.DBEnginePostgreSQL {
+		b, err := cli.GetPhysicalBackup(ctx, ns, name)
+		if err == nil {
+			v := physicalBackupView(b)
+			return &v, nil
+		}
+		if !k8sapi.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	return nil, &backupNotFoundError{name: name, ns: ns}
+}
+
+// -------- backup create --------------------------------------------
+
+func buildPGBackup(db *k8sapi.KdcDatabase, name string) *k8sapi.PGBackup {
+	b := &k8sapi.PGBackup{
+		Metadata: k8sapi.ObjectMeta{
+			Name:      name,
+			Namespace: db.Metadata.Namespace,
+			Labels: map[string]string{
+				"kube-dc.com/database":    db.Metadata.Name,
+				"kube-dc.com/backup-type": "manual",
+			},
+		},
+	}
+	b.Spec.Cluster.Name = db.Metadata.Name
+	b.Spec.Method = "barmanObjectStore"
+	return b
+}
+
+// buildPhysicalBackup copies the storage of the database's scheduled
+// PhysicalBackup, so the one-off lands next to the scheduled ones with
+// the same endpoint and credentials. PreferReplica, because the
+// operator's default of Replica waits forever without one.
+func buildPhysicalBackup(db *k8sapi.KdcDatabase, name string, scheduled *k8sapi.PhysicalBackup) (*k8sapi.PhysicalBackup, error) {
+	if len(scheduled.Spec.Storage) == 0 {
+		return nil, fmt.Errorf("PhysicalBackup %s has no storage to copy", scheduled.Metadata.Name)
+	}
+	b := &k8sapi.PhysicalBackup{
+		Metadata: k8sapi.ObjectMeta{
+			Name:      name,
+			Namespace: db.Metadata.Namespace,
+			Labels: map[string]string{
+				"kube-dc.com/database":    db.Metadata.Name,
+				"kube-dc.com/backup-type": "manual",
+			},
+		},
+	}
+	b.Spec.MariaDBRef.Name = db.Metadata.Name
+	b.Spec.Target = "PreferReplica"
+	b.Spec.BackoffLimit = 3
+	b.Spec.Storage = scheduled.Spec.Storage
+	return b, nil
+}
+
+func dbBackupCreateCmd() *cobra.Command {
+	var namespace, name string
+	var noWait bool
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "create <database>",
+		Short: "Take an on-demand backup of a database and wait for it",
+		Long: `Take an on-demand backup of a database and wait until it completes.
+
+PostgreSQL gets a CNPG base backup; WAL keeps being archived next to it, so
+it is also a starting point for point-in-time restores. MariaDB gets a
+physical backup in the same bucket as the scheduled ones. Backups must be
+enabled on the database (they are unless it was created with --no-backup).`,
+		Example: `  kube-dc db backup create orders-pg
+  kube-dc db backup create cms-db --name cms-db-before-upgrade`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database := args[0]
+			if name != "" && !projectNameRE.MatchString(name) {
+				return fmt.Errorf("invalid --name %q: use lowercase letters, digits and '-'", name)
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			db, err := cli.GetKdcDatabase(ctx, scope.Namespace, database)
+			if err != nil {
+				return err
+			}
+			if db.Spec.Backup == nil || !db.Spec.Backup.Enabled {
+				return fmt.Errorf("backups are disabled on database %s; enable spec.backup first", database)
+			}
+			if name == "" {
+				name = fmt.Sprintf("%s-snap-%d", database, time.Now().Unix())
+			}
+
+			if db.Spec.Engine == k8sapi.DBEngineMariaDB {
+				scheduled, err := cli.GetPhysicalBackup(ctx, scope.Namespace, database+"-scheduled")
+				if err != nil {
+					if k8sapi.IsNotFound(err) {
+						return fmt.Errorf("database %s has no scheduled PhysicalBackup yet to copy the storage from; wait for its backup configuration to reconcile", database)
+					}
+					return err
+				}
+				b, err := buildPhysicalBackup(db, name, scheduled)
+				if err != nil {
+					return err
+				}
+				if _, err := cli.CreatePhysicalBackup(ctx, b); err != nil {
+					return fmt.Errorf("create PhysicalBackup %s: %w", name, err)
+				}
+			} else if _, err := cli.CreatePGBackup(ctx, buildPGBackup(db, name)); err != nil {
+				return fmt.Errorf("create Backup %s: %w", name, err)
+			}
+			if noWait {
+				fmt.Printf("Started backup %s of database %s\n", name, database)
+				fmt.Printf("Not waiting; follow with `kube-dc db backup describe %s`\n", name)
+				return nil
+			}
+			fmt.Printf("Started backup %s of database %s; waiting for it to complete...\n", name, database)
+			done, err := waitForBackup(context.Background(), cli, scope.Namespace, db.Spec.Engine, name, timeout)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Backup %s completed at %s\n", name, fmtCoalesce(done.CompletedAt, "-"))
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVar(&name, "name", "", "Backup name (default: <database>-snap-<unix time>)")
+	cmd.Flags().BoolVar(&noWait, "no-wait", false, "Return once the backup is started")
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Minute, "How long to wait for the backup to complete")
+	return cmd
+}
+
+// waitForBackup polls until the backup completes or fails.
+func waitForBackup(ctx context.Context, cli *k8sapi.Client, ns, engine, name string, timeout time.Duration) (*dbBackup, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var last *dbBackup
+	var lastErr error
+	for {
+		reqCtx, reqCancel := context.WithTimeout(ctx, 30*time.Second)
+		b, err := getDBBackup(reqCtx, cli, ns, engine, name)
+		reqCancel()
+		switch {
+		case err == nil:
+			last, lastErr = b, nil
+			switch b.Phase {
+			case backupCompleted:
+				return b, nil
+			case backupFailed:
+				return nil, fmt.Errorf("backup %s failed: %s", name, fmtCoalesce(b.Message, "no reason reported"))
+			}
+		case errors.As(err, new(*backupNotFoundError)):
+			return nil, err
+		default:
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			msg := fmt.Sprintf("timed out after %s waiting for backup %s", timeout, name)
+			if last != nil {
+				msg += "; phase " + last.Phase
+			}
+			if lastErr != nil {
+				msg += fmt.Sprintf(" (last error: %v)", lastErr)
+			}
+			return nil, errors.New(msg)
+		case <-time.After(dbPollInterval):
+		}
+	}
+}
+
+// -------- backup list / describe -----------------------------------
+
+func dbBackupListCmd() *cobra.Command {
+	var namespace, outFlag string
+	cmd := &cobra.Command{
+		Use:     "list [database]",
+		Aliases: []string{"ls"},
+		Short:   "List backups of one or all databases",
+		Args:    cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			var database string
+			if len(args) == 1 {
+				database = args[0]
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			items, err := listDBBackups(ctx, cli, scope.Namespace, database)
+			if err != nil {
+				return err
+			}
+			if out != outTable {
+				return printSerialized(out, map[string]any{"items": items})
+			}
+			if len(items) == 0 {
+				fmt.Println("No backups in", scope.Namespace)
+				return nil
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tDATABASE\tENGINE\tTRIGGER\tPHASE\tCOMPLETED\tAGE")
+			for _, b := range items {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					b.Name, b.Database, b.Engine, b.Trigger, b.Phase,
+					fmtCoalesce(b.CompletedAt, "-"), formatAge(b.Created))
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+func dbBackupDescribeCmd() *cobra.Command {
+	var namespace, outFlag string
+	cmd := &cobra.Command{
+		Use:     "describe <backup>",
+		Aliases: []string{"show"},
+		Short:   "Show a backup and, for PostgreSQL, its point-in-time window",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			b, err := getDBBackup(ctx, cli, scope.Namespace, "", args[0])
+			if err != nil {
+				return err
+			}
+			if out != outTable {
+				return printSerialized(out, b)
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "Name:\t%s\n", b.Name)
+			fmt.Fprintf(w, "Database:\t%s (%s)\n", b.Database, b.Engine)
+			fmt.Fprintf(w, "Kind:\t%s, %s\n", b.Kind, b.Trigger)
+			fmt.Fprintf(w, "Phase:\t%s\n", b.Phase)
+			fmt.Fprintf(w, "Started:\t%s\n", fmtCoalesce(b.StartedAt, "-"))
+			fmt.Fprintf(w, "Completed:\t%s\n", fmtCoalesce(b.CompletedAt, "-"))
+			if b.BeginWAL != "" {
+				fmt.Fprintf(w, "WAL:\t%s – %s\n", b.BeginWAL, fmtCoalesce(b.EndWAL, "?"))
+			}
+			if b.Message != "" {
+				fmt.Fprintf(w, "Message:\t%s\n", b.Message)
+			}
+			if b.Engine == k8sapi.DBEnginePostgreSQL && b.Phase == backupCompleted {
+				// Best effort: a backup outlives its database.
+				if cluster, err := cli.GetPGCluster(ctx, scope.Namespace, b.Database); err == nil {
+					win := pgRecoveryWindow(b, cluster, time.Now())
+					to := win.To.Format(time.RFC3339)
+					if win.Archiving {
+						to = "now (continuous WAL archiving)"
+					}
+					fmt.Fprintf(w, "Point in time:\t%s to %s\n", win.From.Format(time.RFC3339), to)
+				}
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+func dbBackupCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "backup",
+		Aliases: []string{"backups"},
+		Short:   "Take and list database backups",
+		Long: `On-demand and scheduled backups of managed databases: CNPG base backups for
+PostgreSQL, physical backups for MariaDB. Restore one with kube-dc db restore.`,
+	}
+	cmd.AddCommand(dbBackupCreateCmd())
+	cmd.AddCommand(dbBackupListCmd())
+	cmd.AddCommand(dbBackupDescribeCmd())
+	return cmd
+}
+
+// -------- restore --------------------------------------------------
+
+// recoveryWindow is the span a PostgreSQL --to-time may fall in.
+type recoveryWindow struct {
+	From, To  time.Time
+	Archiving bool
+}
+
+// pgRecoveryWindow runs from the end of base backup b (or the cluster's
+// first recoverability point, if later) to now while WAL archiving is
+// healthy; otherwise only to the last successful backup. Without the
+// Cluster, archiving is assumed healthy.
+func pgRecoveryWindow(b *dbBackup, cluster *k8sapi.PGCluster, now time.Time) recoveryWindow {
+	from, _ := time.Parse(time.RFC3339, b.CompletedAt)
+	win := recoveryWindow{From: from, To: now, Archiving: true}
+	if cluster == nil {
+		return win
+	}
+	if first, err := time.Parse(time.RFC3339, cluster.Status.FirstRecoverabilityPoint); err == nil && first.After(win.From) {
+		win.From = first
+	}
+	win.Archiving = false
+	for _, c := range cluster.Status.Conditions {
+		if c.Type == "ContinuousArchiving" && c.Status == "True" {
+			win.Archiving = true
+		}
+	}
+	if !win.Archiving {
+		win.To = win.From
+		if last, err := time.Parse(time.RFC3339, cluster.Status.LastSuccessfulBackup); err == nil && last.After(win.From) {
+			win.To = last
+		}
+	}
+	return win
+}
+
+// restorePlan is what a restore recovers: a completed backup and, for
+// PostgreSQL, an optional target time (RFC 3339, UTC).
+type restorePlan struct {
+	Backup     dbBackup
+	TargetTime string
+}
+
+// planRestore is the pre-flight of `db restore`: the backup must belong
+// to src and be completed, and a target time must lie after the end of
+// the backup and inside the WAL window. With only a target time, the
+// latest backup that completed before it is chosen.
+func planRestore(src *k8sapi.KdcDatabase, backups []dbBackup, backupName, toTime string, cluster *k8sapi.PGCluster, now time.Time) (*restorePlan, error) {
+	name := src.Metadata.Name
+	var target time.Time
+	if toTime != "" {
+		if src.Spec.Engine == k8sapi.DBEngineMariaDB {
+			return nil, errors.New("--to-time is PostgreSQL only; MariaDB restores to the end of a completed backup (use --from-backup)")
+		}
+		t, err := time.Parse(time.RFC3339, toTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --to-time %q: use RFC 3339, e.g. 2026-05-09T19:30:00Z", toTime)
+		}
+		if t.After(now) {
+			return nil, fmt.Errorf("--to-time %s is in the future", toTime)
+		}
+		target = t.UTC()
+	}
+
+	var chosen *dbBackup
+	var chosenAt time.Time
+	if backupName != "" {
+		for i := range backups {
+			if backups[i].Name == backupName && backups[i].Database == name {
+				chosen = &backups[i]
+			}
+		}
+		if chosen == nil {
+			return nil, fmt.Errorf("no backup %s of database %s; see `kube-dc db backup list %s`", backupName, name, name)
+		}
+		if chosen.Phase != backupCompleted {
+			msg := fmt.Sprintf("backup %s is %s, not Completed", backupName, chosen.Phase)
+			if chosen.Message != "" {
+				msg += ": " + chosen.Message
+			}
+			return nil, errors.New(msg)
+		}
+		chosenAt, _ = time.Parse(time.RFC3339, chosen.CompletedAt)
+		if !target.IsZero() && target.Before(chosenAt) {
+			return nil, fmt.Errorf("--to-time %s is before backup %s completed (%s); recovery only rolls forward from a base backup, so pick an earlier backup",
+				target.Format(time.RFC3339), backupName, chosen.CompletedAt)
+		}
+	} else {
+		for i := range backups {
+			b := &backups[i]
+			at, err := time.Parse(time.RFC3339, b.CompletedAt)
+			if b.Database != name || b.Phase != backupCompleted || err != nil || at.After(target) {
+				continue
+			}
+			if chosen == nil || at.After(chosenAt) {
+				chosen, chosenAt = b, at
+			}
+		}
+		if chosen == nil {
+			return nil, fmt.Errorf("no completed backup of database %s finished before %s", name, target.Format(time.RFC3339))
+		}
+	}
+
+	plan := &restorePlan{Backup: *chosen}
+	if target.IsZero() {
+		return plan, nil
+	}
+	win := pgRecoveryWindow(chosen, cluster, now)
+	if target.Before(win.From) || target.After(win.To) {
+		msg := fmt.Sprintf("--to-time %s is outside the recoverable window %s to %s",
+			target.Format(time.RFC3339), win.From.Format(time.RFC3339), win.To.Format(time.RFC3339))
+		if !win.Archiving {
+			msg += " (continuous WAL archiving is not healthy, so the window ends at the last successful backup)"
+		}
+		return nil, errors.New(msg)
+	}
+	plan.TargetTime = target.Format(time.RFC3339)
+	return plan, nil
+}
+
+// buildRestoredDatabase is src's sizing under a new name, bootstrapped
+// from the plan's backup. It stays internal: a restored copy is
+// verified before anything is pointed at it.
+func buildRestoredDatabase(src *k8sapi.KdcDatabase, into string, plan *restorePlan) (*k8sapi.KdcDatabase, error) {
+	if !projectNameRE.MatchString(into) || len(into) > 50 {
+		return nil, fmt.Errorf("invalid --into %q: use lowercase letters, digits and '-', at most 50 characters", into)
+	}
+	spec := src.Spec
+	spec.Expose = &k8sapi.KdcDatabaseExpose{Type: "internal"}
+	spec.RestoreFrom = &k8sapi.KdcDatabaseRestore{
+		BackupName:         plan.Backup.Name,
+		SourceDatabaseName: src.Metadata.Name,
+		TargetTime:         plan.TargetTime,
+	}
+	return &k8sapi.KdcDatabase{
+		Metadata: k8sapi.ObjectMeta{Name: into, Namespace: src.Metadata.Namespace},
+		Spec:     spec,
+	}, nil
+}
+
+// restoreInPlacePatch sets the restore-from annotation and sets or
+// clears the target time, so a stale one from an earlier restore never
+// applies.
+func restoreInPlacePatch(plan *restorePlan) map[string]any {
+	var target any
+	if plan.TargetTime != "" {
+		target = plan.TargetTime
+	}
+	return map[string]any{"metadata": map[string]any{"annotations": map[string]any{
+		k8sapi.RestoreFromAnnotation:       plan.Backup.Name,
+		k8sapi.RestoreTargetTimeAnnotation: target,
+	}}}
+}
+
+// waitForRestore polls the restored database until db-manager reports
+// the restore finished and the database Ready, writing each change of
+// phase to progress. prior is status.restore from before the trigger,
+// so the result of an earlier restore is never taken for this one.
+func waitForRestore(ctx context.Context, cli *k8sapi.Client, ns, name string, prior *k8sapi.KdcDatabaseRestoreStatus, inPlace bool, timeout time.Duration, progress io.Writer) (*k8sapi.KdcDatabase, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var lastLine string
+	var lastErr error
+	for {
+		reqCtx, reqCancel := context.WithTimeout(ctx, 30*time.Second)
+		db, err := cli.GetKdcDatabase(reqCtx, ns, name)
+		reqCancel()
+		switch {
+		case err == nil:
+			lastErr = nil
+			r := db.Status.Restore
+			ours := r != nil && (prior == nil || r.StartedAt != prior.StartedAt)
+			line := "phase " + fmtCoalesce(db.Status.Phase, "Pending")
+			if ours {
+				line += ", restore " + r.Phase
+				if r.Message != "" {
+					line += ": " + r.Message
+				}
+			}
+			if line != lastLine {
+				fmt.Fprintf(progress, "  %s\n", line)
+				lastLine = line
+			}
+			if ours && r.Phase == "Failed" {
+				return nil, fmt.Errorf("restore of database %s failed: %s", name, fmtCoalesce(r.Message, "no reason reported"))
+			}
+			if db.Status.Phase == "Failed" && (ours || !inPlace) {
+				msg := fmt.Sprintf("database %s failed", name)
+				if pending := notTrueConditions(db.Status.Conditions); pending != "" {
+					msg += ": " + pending
+				}
+				return nil, errors.New(msg)
+			}
+			if db.Status.Phase == "Ready" {
+				_, triggered := db.Metadata.Annotations[k8sapi.RestoreFromAnnotation]
+				if inPlace && ours && r.Phase == "Succeeded" && !triggered {
+					return db, nil
+				}
+				if !inPlace && (r == nil || r.Phase == "Succeeded") {
+					return db, nil
+				}
+			}
+		case k8sapi.IsNotFound(err):
+			return nil, fmt.Errorf("database %s not found", name)
+		default:
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			msg := fmt.Sprintf("timed out after %s waiting for the restore of database %s", timeout, name)
+			if lastLine != "" {
+				msg += "; last: " + lastLine
+			}
+			if lastErr != nil {
+				msg += fmt.Sprintf(" (last error: %v)", lastErr)
+			}
+			return nil, errors.New(msg)
+		case <-time.After(dbPollInterval):
+		}
+	}
+}
+
+func dbRestoreCmd() *cobra.Command {
+	var namespace, fromBackup, toTime, into string
+	var yes, noWait bool
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "restore <database> (--from-backup <backup> | --to-time <RFC3339>) [--into <new-database>]",
+		Short: "Restore a database from a backup, optionally to a point in time",
+		Long: `Restore a database from one of its backups and wait until it is Ready.
+
+--from-backup names a completed backup (see kube-dc db backup list).
+--to-time recovers a PostgreSQL database to an RFC 3339 instant by replaying
+archived WAL on top of a base backup; on its own it picks the latest backup
+that completed before that instant. The target must lie inside the WAL
+window that kube-dc db backup describe shows. MariaDB restores to the end of
+a backup only.
+
+With --into, the backup is restored into a new database with the source's
+engine, version and sizing, and the source keeps running; point applications
+at the copy once you have checked it. Without --into the source database
+itself is wiped and re-created from the backup, which needs --yes.`,
+		Example: `  kube-dc db restore orders-pg --from-backup orders-pg-snap-1778356023 --into orders-pg-restored
+  kube-dc db restore orders-pg --to-time 2026-05-09T19:30:00Z --into orders-pg-0930
+  kube-dc db restore cms-db --from-backup cms-db-scheduled --yes`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if fromBackup == "" && toTime == "" {
+				return errors.New("--from-backup or --to-time is required")
+			}
+			if into == name {
+				return errors.New("--into must name a new database; omit it to restore in place")
+			}
+			if into == "" && !yes {
+				fmt.Fprintf(os.Stderr, "Replace all data of database %s with the backup? Re-run with --yes to confirm, or restore into a new database with --into.\n", name)
+				return fmt.Errorf("not confirmed")
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			src, err := cli.GetKdcDatabase(ctx, scope.Namespace, name)
+			if err != nil {
+				return err
+			}
+			if into != "" {
+				if _, err := cli.GetKdcDatabase(ctx, scope.Namespace, into); err == nil {
+					return fmt.Errorf("database %s already exists", into)
+				} else if !k8sapi.IsNotFound(err) {
+					return err
+				}
+			}
+			backups, err := listDBBackups(ctx, cli, scope.Namespace, name)
+			if err != nil {
+				return err
+			}
+			var cluster *k8sapi.PGCluster
+			if toTime != "" && src.Spec.Engine != k8sapi.DBEngineMariaDB {
+				if cluster, err = cli.GetPGCluster(ctx, scope.Namespace, name); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: cannot read the WAL archive window of %s (%v); checking --to-time against the backup only\n", name, err)
+					cluster = nil
+				}
+			}
+			plan, err := planRestore(src, backups, fromBackup, toTime, cluster, time.Now())
+			if err != nil {
+				return err
+			}
+
+			target, prior, inPlace := name, src.Status.Restore, into == ""
+			from := fmt.Sprintf("backup %s (completed %s)", plan.Backup.Name, plan.Backup.CompletedAt)
+			if plan.TargetTime != "" {
+				from += " to " + plan.TargetTime
+			}
+			if inPlace {
+				if _, err := cli.PatchKdcDatabase(ctx, scope.Namespace, name, restoreInPlacePatch(plan)); err != nil {
+					return fmt.Errorf("start in-place restore of %s: %w", name, err)
+				}
+				fmt.Printf("Restoring database %s in place from %s\n", name, from)
+			} else {
+				db, err := buildRestoredDatabase(src, into, plan)
+				if err != nil {
+					return err
+				}
+				if _, err := cli.CreateKdcDatabase(ctx, db); err != nil {
+					return fmt.Errorf("create KdcDatabase %s: %w", into, err)
+				}
+				target, prior = into, nil
+				fmt.Printf("Restoring %s into new database %s from %s\n", name, into, from)
+			}
+			if noWait {
+				fmt.Printf("Not waiting; follow with `kube-dc db describe %s`\n", target)
+				return nil
+			}
+			ready, err := waitForRestore(context.Background(), cli, scope.Namespace, target, prior, inPlace, timeout, os.Stderr)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Database %s is restored and Ready at %s\n", target, fmtCoalesce(ready.Status.Endpoint, ready.InternalEndpoint()))
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVar(&fromBackup, "from-backup", "", "Completed backup to restore from")
+	cmd.Flags().StringVar(&toTime, "to-time", "", "PostgreSQL point in time to recover to (RFC 3339)")
+	cmd.Flags().StringVar(&into, "into", "", "Restore into this new database and keep the source")
+	cmd.Flags().BoolVar(&yes, "yes", false, "Confirm an in-place restore, which replaces the database's data")
+	cmd.Flags().BoolVar(&noWait, "no-wait", false, "Return once the restore is started")
+	cmd.Flags().DurationVar(&timeout, "timeout", time.Hour, "How long to wait for the restored database to be Ready")
+	return cmd
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+)
+
+func TestBackupViews(t *testing.T) {
+	var pg k8sapi.PGBackup
+	_ = json.Unmarshal([]byte(`{"metadata":{"name":"orders-pg-20261016020000","labels":{"cnpg.io/scheduled-backup":"orders-pg"}},
+		"spec":{"cluster":{"name":"orders-pg"}},
+		"status":{"phase":"completed","beginWal":"00000001000000000000000A","endWal":"00000001000000000000000B","startedAt":"2026-10-16T02:00:01Z","stoppedAt":"2026-10-16T02:03:10Z"}}`), &pg)
+	v := pgBackupView(&pg)
+	if v.Database != "orders-pg" || v.Trigger != "scheduled" || v.Phase != backupCompleted || v.CompletedAt != "2026-10-16T02:03:10Z" {
+		t.Errorf("pg view = %+v", v)
+	}
+	pg.Status.Phase = "walArchivingFailing"
+	if v := pgBackupView(&pg); v.Phase != backupFailed || v.CompletedAt != "" {
+		t.Errorf("failing pg view = %+v", v)
+	}
+
+	var mdb k8sapi.PhysicalBackup
+	_ = json.Unmarshal([]byte(`{"metadata":{"name":"cms-db-snap-1"},"spec":{"mariaDbRef":{"name":"cms-db"}},
+		"status":{"conditions":[{"type":"Complete","status":"False","reason":"JobFailed","message":"backoff limit reached"}]}}`), &mdb)
+	if v := physicalBackupView(&mdb); v.Database != "cms-db" || v.Trigger != "manual" || v.Phase != backupFailed || v.Message != "backoff limit reached" {
+		t.Errorf("mariadb view = %+v", v)
+	}
+	mdb.Status.Conditions = []k8sapi.Condition{{Type: "Complete", Status: "True", LastTransitionTime: "2026-10-16T03:00:00Z"}}
+	if v := physicalBackupView(&mdb); v.Phase != backupCompleted || v.CompletedAt != "2026-10-16T03:00:00Z" {
+		t.Errorf("completed mariadb view = %+v", v)
+	}
+}
+
+func TestBuildPhysicalBackup(t *testing.T) {
+	db := &k8sapi.KdcDatabase{Metadata: k8sapi.ObjectMeta{Name: "cms-db", Namespace: "acme-web"}, Spec: k8sapi.KdcDatabaseSpec{Engine: "mariadb"}}
+	scheduled := &k8sapi.PhysicalBackup{Metadata: k8sapi.ObjectMeta{Name: "cms-db-scheduled"}}
+	if _, err := buildPhysicalBackup(db, "cms-db-snap-1", scheduled); err == nil {
+		t.Error("expected an error without storage to copy")
+	}
+	scheduled.Spec.Schedule = map[string]any{"cron": "0 2 * * *"}
+	scheduled.Spec.Storage = map[string]any{"s3": map[string]any{"bucket": "acme-web-db-backups", "prefix": "databases/cms-db/"}}
+	b, err := buildPhysicalBackup(db, "cms-db-snap-1", scheduled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _ := json.Marshal(b)
+	for _, want := range []string{`"target":"PreferReplica"`, `"mariaDbRef":{"name":"cms-db"}`, `"bucket":"acme-web-db-backups"`, `"kube-dc.com/backup-type":"manual"`} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("PhysicalBackup lacks %s: %s", want, out)
+		}
+	}
+	if strings.Contains(string(out), "schedule") {
+		t.Errorf("a one-off backup must not carry the schedule: %s", out)
+	}
+}
+
+func restoreFixture() (*k8sapi.KdcDatabase, []dbBackup, *k8sapi.PGCluster) {
+	src := &k8sapi.KdcDatabase{
+		Metadata: k8sapi.ObjectMeta{Name: "orders-pg", Namespace: "acme-web"},
+		Spec: k8sapi.KdcDatabaseSpec{
+			Engine: "postgresql", Version: "16", Replicas: 2, CPU: "1", Memory: "2Gi", Storage: "20Gi",
+			DatabaseName: "orders", Username: "app",
+			Expose: &k8sapi.KdcDatabaseExpose{Type: "loadbalancer"},
+			Backup: &k8sapi.KdcDatabaseBackup{Enabled: true, Schedule: "0 2 * * *", RetentionDays: 7},
+		},
+	}
+	backups := []dbBackup{
+		{Name: "orders-pg-mon", Database: "orders-pg", Phase: backupCompleted, CompletedAt: "2026-10-12T02:03:00Z"},
+		{Name: "orders-pg-tue", Database: "orders-pg", Phase: backupCompleted, CompletedAt: "2026-10-13T02:03:00Z"},
+		{Name: "orders-pg-now", Database: "orders-pg", Phase: backupRunning},
+		{Name: "other-db-tue", Database: "other-db", Phase: backupCompleted, CompletedAt: "2026-10-13T02:03:00Z"},
+	}
+	cluster := &k8sapi.PGCluster{}
+	cluster.Status.FirstRecoverabilityPoint = "2026-10-09T02:03:00Z"
+	cluster.Status.LastSuccessfulBackup = "2026-10-16T02:03:00Z"
+	cluster.Status.Conditions = []k8sapi.Condition{{Type: "ContinuousArchiving", Status: "True"}}
+	return src, backups, cluster
+}
+
+func TestPlanRestore(t *testing.T) {
+	src, backups, cluster := restoreFixture()
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	plan, err := planRestore(src, backups, "orders-pg-mon", "", nil, now)
+	if err != nil || plan.Backup.Name != "orders-pg-mon" || plan.TargetTime != "" {
+		t.Errorf("from backup = %+v, %v", plan, err)
+	}
+	// --to-time alone picks the latest backup that finished before it.
+	plan, err = planRestore(src, backups, "", "2026-10-15T09:30:00+02:00", cluster, now)
+	if err != nil || plan.Backup.Name != "orders-pg-tue" || plan.TargetTime != "2026-10-15T07:30:00Z" {
+		t.Errorf("to time = %+v, %v", plan, err)
+	}
+	plan, err = planRestore(src, backups, "orders-pg-mon", "2026-10-12T10:00:00Z", cluster, now)
+	if err != nil || plan.Backup.Name != "orders-pg-mon" {
+		t.Errorf("both = %+v, %v", plan, err)
+	}
+
+	for name, tc := range map[string]struct {
+		backup, at string
+		cluster    func(*k8sapi.PGCluster)
+		want       string
+	}{
+		"unknown":        {backup: "orders-pg-sun", want: "no backup orders-pg-sun"},
+		"other database": {backup: "other-db-tue", want: "no backup other-db-tue"},
+		"running":        {backup: "orders-pg-now", want: "is Running, not Completed"},
+		"before backup":  {backup: "orders-pg-tue", at: "2026-10-13T01:00:00Z", want: "before backup orders-pg-tue completed"},
+		"future":         {at: "2026-10-17T00:00:00Z", want: "in the future"},
+		"not rfc3339":    {at: "yesterday", want: "invalid --to-time"},
+		"no base backup": {at: "2026-10-11T00:00:00Z", want: "no completed backup of database orders-pg finished before"},
+		"archiving down": {at: "2026-10-16T11:00:00Z", cluster: func(c *k8sapi.PGCluster) {
+			c.Status.Conditions[0].Status = "False"
+		}, want: "continuous WAL archiving is not healthy"},
+		"before floor": {backup: "orders-pg-mon", at: "2026-10-12T05:00:00Z", cluster: func(c *k8sapi.PGCluster) {
+			c.Status.FirstRecoverabilityPoint = "2026-10-12T06:00:00Z"
+		}, want: "outside the recoverable window 2026-10-12T06:00:00Z"},
+	} {
+		src, backups, cluster := restoreFixture()
+		if tc.cluster != nil {
+			tc.cluster(cluster)
+		}
+		if _, err := planRestore(src, backups, tc.backup, tc.at, cluster, now); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: %v, want %q", name, err, tc.want)
+		}
+	}
+
+	src.Spec.Engine = "mariadb"
+	if _, err := planRestore(src, backups, "orders-pg-mon", "2026-10-12T10:00:00Z", nil, now); err == nil || !strings.Contains(err.Error(), "PostgreSQL only") {
+		t.Errorf("mariadb pitr: %v", err)
+	}
+}
+
+func TestBuildRestoredDatabase(t *testing.T) {
+	src, backups, _ := restoreFixture()
+	plan := &restorePlan{Backup: backups[1], TargetTime: "2026-10-15T07:30:00Z"}
+	db, err := buildRestoredDatabase(src, "orders-pg-0730", plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := db.Spec.RestoreFrom
+	if db.Metadata.Name != "orders-pg-0730" || db.Metadata.Namespace != "acme-web" || r == nil ||
+		r.BackupName != "orders-pg-tue" || r.SourceDatabaseName != "orders-pg" || r.TargetTime != "2026-10-15T07:30:00Z" {
+		t.Errorf("restored = %+v, restoreFrom %+v", db, r)
+	}
+	if db.Spec.Expose.Type != "internal" || src.Spec.Expose.Type != "loadbalancer" || db.Spec.Storage != "20Gi" || db.Spec.DatabaseName != "orders" {
+		t.Errorf("spec = %+v", db.Spec)
+	}
+	if src.Spec.RestoreFrom != nil {
+		t.Error("the source spec must not change")
+	}
+	if _, err := buildRestoredDatabase(src, "Orders_Copy", plan); err == nil {
+		t.Error("expected a name error")
+	}
+
+	b, _ := json.Marshal(restoreInPlacePatch(&restorePlan{Backup: backups[0]}))
+	if string(b) != `{"metadata":{"annotations":{"kube-dc.com/restore-from":"orders-pg-mon","kube-dc.com/restore-target-time":null}}}` {
+		t.Errorf("in-place patch = %s", b)
+	}
+}
+
+func TestWaitForRestore_InPlace(t *testing.T) {
+	prev := dbPollInterval
+	dbPollInterval = 5 * time.Millisecond
+	t.Cleanup(func() { dbPollInterval = prev })
+
+	// An earlier failed restore stays in status until db-manager picks
+	// up the new annotation.
+	prior := &k8sapi.KdcDatabaseRestoreStatus{BackupName: "orders-pg-mon", Phase: "Failed", StartedAt: "2026-10-14T10:00:00Z"}
+	gets := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gets++
+		db := k8sapi.KdcDatabase{Metadata: k8sapi.ObjectMeta{Name: "orders-pg", Annotations: map[string]string{k8sapi.RestoreFromAnnotation: "orders-pg-tue"}}}
+		db.Status.Phase, db.Status.Restore = "Ready", prior
+		switch {
+		case gets == 2:
+			db.Status.Phase = "Provisioning"
+			db.Status.Restore = &k8sapi.KdcDatabaseRestoreStatus{BackupName: "orders-pg-tue", Phase: "InProgress", Message: "recovering", StartedAt: "2026-10-16T12:00:00Z"}
+		case gets >= 3:
+			db.Metadata.Annotations = nil
+			db.Status.Restore = &k8sapi.KdcDatabaseRestoreStatus{BackupName: "orders-pg-tue", Phase: "Succeeded", StartedAt: "2026-10-16T12:00:00Z"}
+		}
+		_ = json.NewEncoder(w).Encode(db)
+	}))
+	defer srv.Close()
+	cli, _ := k8sapi.New(srv.URL, "token", "", false)
+
+	var progress bytes.Buffer
+	if _, err := waitForRestore(context.Background(), cli, "acme-web", "orders-pg", prior, true, time.Second, &progress); err != nil || gets != 3 {
+		t.Fatalf("wait: %v after %d gets", err, gets)
+	}
+	want := "  phase Ready\n  phase Provisioning, restore InProgress: recovering\n  phase Ready, restore Succeeded\n"
+	if progress.String() != want {
+		t.Errorf("progress = %q", progress.String())
+	}
+}
+
+func TestWaitForRestore_Failed(t *testing.T) {
+	prev := dbPollInterval
+	dbPollInterval = 5 * time.Millisecond
+	t.Cleanup(func() { dbPollInterval = prev })
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		db := k8sapi.KdcDatabase{Metadata: k8sapi.ObjectMeta{Name: "orders-pg-copy"}}
+		db.Status.Phase = "Provisioning"
+		db.Status.Restore = &k8sapi.KdcDatabaseRestoreStatus{BackupName: "orders-pg-tue", Phase: "Failed", Message: "recovery ended before configured recovery target was reached"}
+		_ = json.NewEncoder(w).Encode(db)
+	}))
+	defer srv.Close()
+	cli, _ := k8sapi.New(srv.URL, "token", "", false)
+
+	var progress bytes.Buffer
+	_, err := waitForRestore(context.Background(), cli, "acme-web", "orders-pg-copy", nil, false, time.Second, &progress)
+	if err == nil || !strings.Contains(err.Error(), "recovery target was reached") {
+		t.Errorf("wait: %v", err)
+	}
+}
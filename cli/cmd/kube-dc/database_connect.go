@@ -0,0 +1,427 @@
+// `kube-dc db connect` — a psql / mariadb session against a managed
+// database from a workstation. By default a local listener
+// port-forwards each connection through the kube-apiserver to the pod
+// behind the database's write Service, so it works for the default
+// internal exposure. --external goes to the database's own public
+// endpoint instead (the LoadBalancer address in status.externalEndpoint,
+// or the PostgreSQL 17 direct-TLS Gateway); the client then has to
+// verify the server's certificate, and connect refuses to start it
+// when it cannot. The password comes from the database's static-rotated
+// DatabaseCredentialPolicy via the backend and reaches the client
+// through its environment only.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shalb/kube-dc/cli/internal/backend"
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+	"github.com/spf13/cobra"
+)
+
+// selectStaticPolicy picks the static-rotated DatabaseCredentialPolicy
+// of db: the one named by --policy, or the only one there is.
+func selectStaticPolicy(items []backend.DBCredentialPolicySummary, db, policy string) (string, error) {
+	var names []string
+	for _, p := range items {
+		if p.DatabaseRef != db || p.Mode != "static-rotated" {
+			continue
+		}
+		if policy != "" && p.Name == policy {
+			return p.Name, nil
+		}
+		names = append(names, p.Name)
+	}
+	sort.Strings(names)
+	switch {
+	case policy != "":
+		return "", fmt.Errorf("no static-rotated credential policy %s for database %s", policy, db)
+	case len(names) == 0:
+		return "", fmt.Errorf("no static-rotated credential policy targets database %s; create one with `kube-dc db credentials create %s-app --database %s`", db, db, db)
+	case len(names) > 1:
+		return "", fmt.Errorf("database %s has several credential policies (%s); pick one with --policy", db, strings.Join(names, ", "))
+	}
+	return names[0], nil
+}
+
+// dbEndpoint is where the client connects: the local end of the
+// port-forward, or with External the database's public endpoint.
+type dbEndpoint struct {
+	Host string
+	Port int
+	// External is a public endpoint: the client must use TLS and
+	// verify the server against CAFile (or, for psql without one, the
+	// system trust store).
+	External bool
+	CAFile   string
+	// Gateway is the SNI-routed PostgreSQL 17 path, which needs the
+	// client to start with TLS (sslnegotiation=direct).
+	Gateway bool
+}
+
+// dbExternalEndpoint is the public endpoint of db, from its exposure
+// and status.externalEndpoint. Pure — no I/O.
+func dbExternalEndpoint(db *k8sapi.KdcDatabase) (dbEndpoint, error) {
+	name := db.Metadata.Name
+	expose := "internal"
+	if db.Spec.Expose != nil && db.Spec.Expose.Type != "" {
+		expose = db.Spec.Expose.Type
+	}
+	switch expose {
+	case "loadbalancer", "gateway":
+	case "internal":
+		return dbEndpoint{}, fmt.Errorf("database %s is only reachable inside the Project (%s); connect without --external to go through a port-forward", name, db.InternalEndpoint())
+	default:
+		return dbEndpoint{}, fmt.Errorf("database %s has unknown exposure %q", name, expose)
+	}
+	if db.Status.ExternalEndpoint == "" {
+		return dbEndpoint{}, fmt.Errorf("database %s has no external endpoint yet; check `kube-dc db describe %s`", name, name)
+	}
+	host, portStr, err := net.SplitHostPort(db.Status.ExternalEndpoint)
+	if err != nil {
+		host, portStr = db.Status.ExternalEndpoint, ""
+	}
+	if expose == "gateway" {
+		if db.Spec.Engine == k8sapi.DBEngineMariaDB {
+			return dbEndpoint{}, fmt.Errorf("database %s is exposed through the Gateway, which MariaDB clients cannot use; expose it with spec.expose.type: loadbalancer", name)
+		}
+		// The controller reports the engine port; the Gateway listens
+		// on 443.
+		return dbEndpoint{Host: host, Port: 443, External: true, Gateway: true}, nil
+	}
+	port := k8sapi.DBServicePort(db.Spec.Engine)
+	if portStr != "" {
+		if port, err = strconv.Atoi(portStr); err != nil {
+			return dbEndpoint{}, fmt.Errorf("database %s: bad external endpoint %q", name, db.Status.ExternalEndpoint)
+		}
+	}
+	return dbEndpoint{Host: host, Port: port, External: true}, nil
+}
+
+// dbClient is the command line that opens a session: the binary, its
+// arguments, and the extra environment that carries the password.
+type dbClient struct {
+	Bin  string
+	Args []string
+	Env  []string
+}
+
+// dbClientCommand builds the psql or mariadb/mysql invocation for ep.
+// The password only ever goes into Env. Through the port-forward the
+// session rides the API server's TLS; an external endpoint gets TLS
+// with full server verification, and a client that cannot be made to
+// verify is refused rather than started without it.
+func dbClientCommand(engine, override string, lookPath func(string) (string, error), ep dbEndpoint, user, password, database string, extra []string) (*dbClient, error) {
+	candidates := []string{"psql"}
+	if engine == k8sapi.DBEngineMariaDB {
+		candidates = []string{"mariadb", "mysql"}
+	}
+	if override != "" {
+		candidates = []string{override}
+	}
+	var bin string
+	for _, c := range candidates {
+		if p, err := lookPath(c); err == nil {
+			bin = p
+			break
+		}
+	}
+	if bin == "" {
+		return nil, fmt.Errorf("%s not found in PATH: install a client, or use --forward-only with your own tool", strings.Join(candidates, " or "))
+	}
+	portStr := strconv.Itoa(ep.Port)
+	if engine != k8sapi.DBEngineMariaDB {
+		env := []string{
+			"PGHOST=" + ep.Host, "PGPORT=" + portStr, "PGUSER=" + user,
+			"PGPASSWORD=" + password, "PGDATABASE=" + database,
+		}
+		if ep.External {
+			// sslrootcert=system is libpq's system trust store; a libpq
+			// too old to know it fails to load it and does not connect.
+			env = append(env, "PGSSLMODE=verify-full", "PGSSLROOTCERT="+fmtCoalesce(ep.CAFile, "system"))
+			if ep.Gateway {
+				env = append(env, "PGSSLNEGOTIATION=direct")
+			}
+		}
+		return &dbClient{Bin: bin, Args: extra, Env: env}, nil
+	}
+	args := []string{"--host=" + ep.Host, "--port=" + portStr, "--user=" + user}
+	mariadbClient := strings.HasPrefix(filepath.Base(bin), "mariadb")
+	switch {
+	case !ep.External:
+		if mariadbClient {
+			// The server's certificate names the in-cluster Service,
+			// never 127.0.0.1; the tunnel itself is TLS to the API
+			// server.
+			args = append(args, "--skip-ssl-verify-server-cert")
+		}
+	case ep.CAFile == "":
+		return nil, fmt.Errorf("cannot verify the server certificate of %s without its CA: pass --ca-file, or connect without --external", net.JoinHostPort(ep.Host, portStr))
+	case mariadbClient:
+		args = append(args, "--ssl", "--ssl-verify-server-cert", "--ssl-ca="+ep.CAFile)
+	default:
+		args = append(args, "--ssl-mode=VERIFY_IDENTITY", "--ssl-ca="+ep.CAFile)
+	}
+	args = append(args, extra...)
+	args = append(args, database)
+	return &dbClient{Bin: bin, Args: args, Env: []string{"MYSQL_PWD=" + password}}, nil
+}
+
+// writeDBCAFile puts a PEM bundle where a client can read it, in a
+// private temporary directory; cleanup removes it.
+func writeDBCAFile(pem string) (path string, cleanup func(), err error) {
+	dir, err := os.MkdirTemp("", "kube-dc-db-ca-")
+	if err != nil {
+		return "", nil, err
+	}
+	path = filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(path, []byte(pem), 0o600); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	return path, func() { os.RemoveAll(dir) }, nil
+}
+
+// dbForwardTarget resolves the write Service of db to a ready pod and
+// the container port behind the engine port.
+func dbForwardTarget(ctx context.Context, cli *k8sapi.Client, db *k8sapi.KdcDatabase) (string, int, error) {
+	ns, svcName := db.Metadata.Namespace, db.WriteServiceName()
+	svc, err := cli.GetService(ctx, ns, svcName)
+	if err != nil {
+		return "", 0, fmt.Errorf("read Service %s: %w", svcName, err)
+	}
+	if len(svc.Spec.Selector) == 0 {
+		return "", 0, fmt.Errorf("Service %s has no pod selector", svcName)
+	}
+	var sel []string
+	for k, v := range svc.Spec.Selector {
+		sel = append(sel, k+"="+v)
+	}
+	sort.Strings(sel)
+	pods, err := cli.ListPods(ctx, ns, strings.Join(sel, ","))
+	if err != nil {
+		return "", 0, err
+	}
+	sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Metadata.Name < pods.Items[j].Metadata.Name })
+	var pod *k8sapi.Pod
+	for i := range pods.Items {
+		if p := &pods.Items[i]; p.Status.Phase == "Running" && p.Ready() {
+			pod = p
+			break
+		}
+	}
+	if pod == nil {
+		return "", 0, fmt.Errorf("no ready pod behind Service %s; check `kube-dc db describe %s`", svcName, db.Metadata.Name)
+	}
+	want := k8sapi.DBServicePort(db.Spec.Engine)
+	for _, p := range svc.Spec.Ports {
+		if p.Port != want && len(svc.Spec.Ports) > 1 {
+			continue
+		}
+		if n := p.TargetPortNumber(); n != 0 {
+			return pod.Metadata.Name, n, nil
+		}
+		if name, ok := p.TargetPort.(string); ok {
+			if n := pod.NamedPort(name); n != 0 {
+				return pod.Metadata.Name, n, nil
+			}
+		}
+	}
+	return pod.Metadata.Name, want, nil
+}
+
+// serveForward accepts local connections until ln is closed and pipes
+// each through its own port-forward stream.
+func serveForward(ln net.Listener, dial func(context.Context) (io.ReadWriteCloser, error)) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			stream, err := dial(context.Background())
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "port-forward: %v\n", err)
+				return
+			}
+			defer stream.Close()
+			_ = relay(stream, conn, conn)
+		}()
+	}
+}
+
+func dbConnectCmd() *cobra.Command {
+	var namespace, policy, client, caFile string
+	var localPort int
+	var forwardOnly, external bool
+	cmd := &cobra.Command{
+		Use:   "connect <name> [-- client args...]",
+		Short: "Open psql or mariadb against a database through a local port-forward",
+		Long: `Open a SQL session against a managed database from your workstation.
+
+connect port-forwards a local port through the API server to the database's
+primary instance, fetches the current password of the database's
+static-rotated credential policy, and starts psql (PostgreSQL) or mariadb /
+mysql (MariaDB) with it. The password is passed in the client's environment
+and never printed. Arguments after -- go to the client. This works for every
+database, including the default --expose internal; the session is carried
+inside the TLS connection to the API server.
+
+--external connects to the database's public endpoint instead: the
+LoadBalancer address of a database created with --expose loadbalancer, or the
+Gateway hostname of a PostgreSQL 17 database exposed by manifest. The client
+then requires TLS and verifies the server's certificate and host name against
+--ca-file, else the platform CA your login trusts, else (psql only) the system
+trust store. When the server cannot be verified the client is not started, or
+refuses to connect.
+
+--forward-only keeps just the port-forward open for a GUI or another tool and
+prints how to connect; read the password with
+kube-dc db credentials get <policy> --show-password.
+
+Needs a role that may read the policy's password and, without --external,
+port-forward to pods (Project admin or developer).`,
+		Example: `  kube-dc db connect orders-pg
+  kube-dc db connect orders-pg -- -c 'select count(*) from orders'
+  kube-dc db connect cms-db --policy cms-db-reporting
+  kube-dc db connect orders-pg --forward-only --local-port 15432
+  kube-dc db connect orders-pg --external --ca-file db-ca.pem`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, extra := args[0], args[1:]
+			if dash := cmd.ArgsLenAtDash(); dash > 1 || (dash == -1 && len(args) > 1) {
+				return errors.New("pass client arguments after --")
+			}
+			switch {
+			case external && (forwardOnly || localPort != 0):
+				return errors.New("--forward-only and --local-port apply to the port-forward, not --external")
+			case caFile != "" && !external:
+				return errors.New("--ca-file applies to --external only")
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			db, err := cli.GetKdcDatabase(ctx, scope.Namespace, name)
+			if err != nil {
+				return err
+			}
+			if db.Status.Phase != "Ready" {
+				return fmt.Errorf("database %s is %s, not Ready", name, fmtCoalesce(db.Status.Phase, "pending"))
+			}
+			var ep dbEndpoint
+			if external {
+				if ep, err = dbExternalEndpoint(db); err != nil {
+					return err
+				}
+				ep.CAFile = caFile
+				if ep.CAFile == "" && scope.PlatformCACert != "" {
+					path, cleanup, err := writeDBCAFile(scope.PlatformCACert)
+					if err != nil {
+						return err
+					}
+					defer cleanup()
+					ep.CAFile = path
+				}
+			}
+
+			var creds *backend.DBCredentials
+			var policyName string
+			if !forwardOnly {
+				be, err := scope.backend()
+				if err != nil {
+					return err
+				}
+				list, err := be.ListDBCredentialPolicies(ctx, scope.Namespace)
+				if err != nil {
+					return fmt.Errorf("list credential policies: %w", err)
+				}
+				if policyName, err = selectStaticPolicy(list.Items, name, policy); err != nil {
+					return err
+				}
+				if creds, err = be.GetDBCredentials(ctx, scope.Namespace, policyName); err != nil {
+					return fmt.Errorf("read credentials of %s: %w", policyName, err)
+				}
+			}
+			database := fmtCoalesce(db.Spec.DatabaseName, "app")
+
+			if !external {
+				pod, port, err := dbForwardTarget(ctx, cli, db)
+				if err != nil {
+					return err
+				}
+				ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", localPort))
+				if err != nil {
+					return fmt.Errorf("listen on 127.0.0.1:%d: %w", localPort, err)
+				}
+				defer ln.Close()
+				go serveForward(ln, func(ctx context.Context) (io.ReadWriteCloser, error) {
+					return cli.PodPortForward(ctx, scope.Namespace, pod, port)
+				})
+				ep = dbEndpoint{Host: "127.0.0.1", Port: ln.Addr().(*net.TCPAddr).Port}
+
+				if forwardOnly {
+					fmt.Printf("Forwarding 127.0.0.1:%d to database %s (pod %s, port %d)\n", ep.Port, name, pod, port)
+					fmt.Printf("Database %s; read the password with `kube-dc db credentials get <policy> --show-password`\n", database)
+					fmt.Println("Press Ctrl+C to stop.")
+					sig := make(chan os.Signal, 1)
+					signal.Notify(sig, os.Interrupt)
+					<-sig
+					return nil
+				}
+			}
+
+			c, err := dbClientCommand(db.Spec.Engine, client, exec.LookPath, ep, creds.Username, creds.Password, database, extra)
+			if err != nil {
+				return err
+			}
+			if external {
+				fmt.Fprintf(os.Stderr, "Connecting to %s at %s as %s (credential policy %s)\n", name, net.JoinHostPort(ep.Host, strconv.Itoa(ep.Port)), creds.Username, policyName)
+			} else {
+				fmt.Fprintf(os.Stderr, "Connecting to %s as %s (credential policy %s)\n", name, creds.Username, policyName)
+			}
+			// Ctrl+C belongs to the client (it cancels the running query).
+			signal.Ignore(os.Interrupt)
+			defer signal.Reset(os.Interrupt)
+			run := exec.Command(c.Bin, c.Args...)
+			run.Env = append(os.Environ(), c.Env...)
+			run.Stdin, run.Stdout, run.Stderr = os.Stdin, os.Stdout, os.Stderr
+			if err := run.Run(); err != nil {
+				var ee *exec.ExitError
+				if errors.As(err, &ee) {
+					return &exitCodeError{code: ee.ExitCode()}
+				}
+				return fmt.Errorf("run %s: %w", c.Bin, err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVar(&policy, "policy", "", "Static-rotated credential policy to log in with (default: the database's only one)")
+	cmd.Flags().StringVar(&client, "client", "", "Client binary (default: psql, or mariadb/mysql)")
+	cmd.Flags().IntVar(&localPort, "local-port", 0, "Local port to listen on (default: a free one)")
+	cmd.Flags().BoolVar(&forwardOnly, "forward-only", false, "Only keep the port-forward open; don't fetch credentials or start a client")
+	cmd.Flags().BoolVar(&external, "external", false, "Connect to the database's public endpoint instead of through a port-forward")
+	cmd.Flags().StringVar(&caFile, "ca-file", "", "CA bundle to verify the server with --external (default: the platform CA of your login)")
+	return cmd
+}
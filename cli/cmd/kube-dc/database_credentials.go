@@ -13,17 +13,16 @@
 //   kube-dc db credentials rotate <name> [--root]
 //   kube-dc db credentials get <name> [--show-password] [-o table|json|yaml|env]
 //   kube-dc db credentials delete <name> --yes
-//   kube-dc db credentials issue <name> [-o table|json|yaml|env]
+//   kube-dc db credentials issue / renew / revoke / leases list   (database_leases.go)
 //
 // `-o env` emits `KUBE_DC_DB_*=value` lines suitable for shell
 // `eval "$(...)"` sourcing. For `get`, requires --show-password
-// (a masked env is useless for eval). The reserved `issue` command
-// will use the same format after dynamic issuance is implemented.
+// (a masked env is useless for eval); `issue` uses the same format.
 //
 // Dynamic mode (--mode dynamic + `issue`) is a reserved CLI/API
 // surface. The controller sets Ready=False/DynamicModeDeferred and
-// /issue returns 501; the command remains wired so the interface does
-// not shift when the controller implementation ships.
+// /issue returns 501; the lease verbs check that the backend serves the
+// lease API before doing anything, so they fail cleanly until it does.
 
 package main
 
@@ -40,20 +39,27 @@ import (
 // dbCmd is the parent. Sub-tree:
 //
 //	db
+//	  create / list / describe / resize / delete / connect   (database.go)
+//	  backup
+//	    create / list / describe                            (database_backup.go)
+//	  restore                                               (database_backup.go)
 //	  credentials
-//	    list / describe / create / rotate / get / delete / issue
-//
-// Kept under `db` (not a top-level alias) so future `kube-dc db
-// instances` / `kube-dc db backups` verbs have a home.
+//	    list / describe / create / rotate / get / delete
+//	    issue / renew / revoke / leases list              (database_leases.go)
 func dbCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "db",
-		Short: "Database operations (credentials, backups — credentials in M4)",
+		Short: "Managed databases and their credentials",
 		Long: `Database operations against KdcDatabase + DatabaseCredentialPolicy.
+create, list, describe, resize and delete manage PostgreSQL and MariaDB
+instances; connect opens a psql or mariadb session through a port-forward.
+backup takes and lists engine backups, and restore recovers one (PostgreSQL
+also to a point in time) into a new database or in place.
 The current release supports static-rotated credential lifecycle. Dynamic
-policy fields and the issue command are reserved for compatibility; dynamic
-policies remain Ready=False/DynamicModeDeferred and issue returns HTTP 501.
-Backups are reachable today via kubectl edit kdcdatabase.
+policy fields and the lease commands (issue, renew, revoke, leases list) are
+reserved for compatibility; dynamic policies remain
+Ready=False/DynamicModeDeferred and the lease commands report that dynamic
+credentials are not available.
 
 Permissions follow the exact standard Project roles:
   user               list + describe
@@ -63,6 +69,14 @@ Permissions follow the exact standard Project roles:
 
 The --root rotation flag is retired and the backend returns HTTP 410.`,
 	}
+	cmd.AddCommand(dbCreateCmd())
+	cmd.AddCommand(dbListCmd())
+	cmd.AddCommand(dbDescribeCmd())
+	cmd.AddCommand(dbResizeCmd())
+	cmd.AddCommand(dbDeleteCmd())
+	cmd.AddCommand(dbConnectCmd())
+	cmd.AddCommand(dbBackupCmd())
+	cmd.AddCommand(dbRestoreCmd())
 	cmd.AddCommand(dbCredentialsCmd())
 	return cmd
 }
@@ -70,7 +84,7 @@ The --root rotation flag is retired and the backend returns HTTP 410.`,
 func dbCredentialsCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "credentials",
-		Short: "DatabaseCredentialPolicy lifecycle (list/create/rotate/get/delete/issue)",
+		Short: "DatabaseCredentialPolicy lifecycle and dynamic credential leases",
 	}
 	cmd.AddCommand(dbCredentialsListCmd())
 	cmd.AddCommand(dbCredentialsDescribeCmd())
@@ -79,6 +93,9 @@ func dbCredentialsCmd() *cobra.Command {
 	cmd.AddCommand(dbCredentialsGetCmd())
 	cmd.AddCommand(dbCredentialsDeleteCmd())
 	cmd.AddCommand(dbCredentialsIssueCmd())
+	cmd.AddCommand(dbCredentialsRenewCmd())
+	cmd.AddCommand(dbCredentialsRevokeCmd())
+	cmd.AddCommand(dbCredentialsLeasesCmd())
 	return cmd
 }
 
@@ -415,66 +432,6 @@ Requires --yes to proceed.`,
 	return cmd
 }
 
-// -------- issue (dynamic mode) -------------------------------------
-
-func dbCredentialsIssueCmd() *cobra.Command {
-	var namespace, outFlag string
-	cmd := &cobra.Command{
-		Use:   "issue <name>",
-		Short: "Reserved dynamic credential command (currently returns HTTP 501)",
-		Long: `Dynamic credential issuance is not implemented in the current release.
-Policies created with mode=dynamic remain Ready=False with reason
-DynamicModeDeferred, and this command returns HTTP 501 without minting a
-username, password, or lease.
-
-The command is retained as a forward-compatible interface. Use a
-static-rotated policy and 'kube-dc db credentials get' for a supported
-credential workflow.`,
-		Args: cobra.ExactArgs(1),
-		RunE: func(cmd *cobra.Command, args []string) error {
-			name := args[0]
-			envOut, out, err := parseDBOutput(outFlag)
-			if err != nil {
-				return err
-			}
-			scope, err := resolveScope(namespace)
-			if err != nil {
-				return err
-			}
-			cli, err := scope.backend()
-			if err != nil {
-				return err
-			}
-			ctx, cancel := ctxWithTimeout()
-			defer cancel()
-			lease, err := cli.IssueDBCredentials(ctx, scope.Namespace, name)
-			if err != nil {
-				return err
-			}
-			if envOut {
-				return printDBLeaseEnv(lease)
-			}
-			if out != outTable {
-				return printSerialized(out, lease)
-			}
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			defer w.Flush()
-			fmt.Fprintf(w, "Username:\t%s\n", lease.Username)
-			fmt.Fprintf(w, "Password:\t%s\n", lease.Password)
-			if lease.LeaseId != "" {
-				fmt.Fprintf(w, "Lease ID:\t%s\n", lease.LeaseId)
-			}
-			if lease.LeaseDuration > 0 {
-				fmt.Fprintf(w, "Lease Duration:\t%d s\n", lease.LeaseDuration)
-			}
-			return nil
-		},
-	}
-	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
-	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml|env (env emits `KEY=value` for shell `eval`)")
-	return cmd
-}
-
 // -------- table renderers ------------------------------------------
 
 func printDBCPTable(items []backend.DBCredentialPolicySummary) error {
@@ -586,13 +543,8 @@ func printDBCredentialsEnv(creds *backend.DBCredentials) error {
 // Lease metadata included so the consumer can implement renew/revoke
 // against the lease ID. Same shell-escape caveat as the static helper.
 func printDBLeaseEnv(lease *backend.DBLease) error {
-	fmt.Printf("KUBE_DC_DB_USERNAME=%s\n", lease.Username)
-	fmt.Printf("KUBE_DC_DB_PASSWORD=%s\n", lease.Password)
-	if lease.LeaseId != "" {
-		fmt.Printf("KUBE_DC_DB_LEASE_ID=%s\n", lease.LeaseId)
-	}
-	if lease.LeaseDuration > 0 {
-		fmt.Printf("KUBE_DC_DB_LEASE_DURATION=%d\n", lease.LeaseDuration)
+	for _, kv := range dbLeaseEnv(lease) {
+		fmt.Println(kv)
 	}
 	return nil
 }
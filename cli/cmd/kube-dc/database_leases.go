@@ -0,0 +1,499 @@
+// `kube-dc db credentials` lease verbs for mode=dynamic policies:
+// every `issue` mints a database user that lives for an OpenBao lease.
+// Leases go through the backend's /api/database-leases/:ns family, so
+// they are audited like the rest of the credential surface. Backends
+// that do not serve that family yet are detected before any lease is
+// issued or touched.
+//
+//   kube-dc db credentials issue <name> [--ttl 15m] [-o table|json|yaml|env]
+//   kube-dc db credentials issue <name> --renew-until 2h -- <command> [args...]
+//   kube-dc db credentials renew <lease-id> [--increment 1h]
+//   kube-dc db credentials revoke <lease-id> | --prefix <prefix> --yes
+//   kube-dc db credentials leases list [--policy <name>]
+//
+// --renew-until wraps a child process: the lease is handed to it in
+// KUBE_DC_DB_* environment variables, renewed at two thirds of its TTL
+// while the child runs (for at most the given duration), and revoked
+// when the child exits — also when the wrapper is interrupted or
+// terminated, since those signals are passed to the child.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/backend"
+	"github.com/spf13/cobra"
+)
+
+// dbLeaseEnv is a lease as KUBE_DC_DB_* variables, username and
+// password first.
+func dbLeaseEnv(lease *backend.DBLease) []string {
+	env := []string{
+		"KUBE_DC_DB_USERNAME=" + lease.Username,
+		"KUBE_DC_DB_PASSWORD=" + lease.Password,
+	}
+	if lease.LeaseId != "" {
+		env = append(env, "KUBE_DC_DB_LEASE_ID="+lease.LeaseId)
+	}
+	if lease.LeaseDuration > 0 {
+		env = append(env, "KUBE_DC_DB_LEASE_DURATION="+strconv.Itoa(lease.LeaseDuration))
+	}
+	return env
+}
+
+func dbLeasesUnavailable(cause error) error {
+	return fmt.Errorf("dynamic credentials are not available on this installation (%w); use a static-rotated policy and `kube-dc db credentials get`", cause)
+}
+
+// requireDBLeases stops a lease verb before it does anything when the
+// backend does not serve the lease API: an issued lease could then be
+// neither renewed nor revoked.
+func requireDBLeases(ctx context.Context, cli *backend.Client, ns string) error {
+	ok, err := cli.DBLeasesAvailable(ctx, ns)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return dbLeasesUnavailable(errors.New("the backend does not serve the database lease API"))
+	}
+	return nil
+}
+
+// dynamicModeError turns the 501 of a dynamic policy the controller
+// still defers into something actionable; other errors pass through.
+func dynamicModeError(err error) error {
+	var apiErr *backend.APIError
+	if errors.As(err, &apiErr) && apiErr.Status == 501 {
+		return dbLeasesUnavailable(err)
+	}
+	return err
+}
+
+// keepLeaseAlive renews a lease at two thirds of its current TTL until
+// ctx ends or until passes. A failed renewal is reported to warn and
+// retried at the next tick; a lease that stops growing has reached its
+// max TTL, which is reported once, and one that comes back not
+// renewable is left to expire.
+func keepLeaseAlive(ctx context.Context, renew func(context.Context) (*backend.DBLease, error), ttl time.Duration, until time.Time, warn io.Writer) {
+	if ttl <= 0 {
+		return
+	}
+	full, capped := ttl, false
+	for {
+		next := ttl * 2 / 3
+		if time.Now().Add(next).After(until) {
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Until(until)):
+				fmt.Fprintf(warn, "kube-dc: --renew-until reached; the lease is no longer renewed and expires within %s\n", ttl)
+			}
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(next):
+		}
+		reqCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		lease, err := renew(reqCtx)
+		cancel()
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			fmt.Fprintf(warn, "kube-dc: renew lease: %v\n", err)
+			ttl -= next
+			if ttl <= 0 {
+				return
+			}
+		default:
+			renewed := time.Duration(lease.LeaseDuration) * time.Second
+			if renewed < full && !capped {
+				fmt.Fprintf(warn, "kube-dc: the lease has reached its max TTL and expires in %s\n", renewed)
+				capped = true
+			}
+			ttl = renewed
+			if ttl <= 0 {
+				return
+			}
+			if !lease.Renewable {
+				fmt.Fprintf(warn, "kube-dc: the lease is no longer renewable and expires in %s\n", renewed)
+				return
+			}
+		}
+	}
+}
+
+// runWithLease runs argv with the lease in its environment, keeping
+// the lease alive meanwhile when it is renewable. Interrupt and
+// SIGTERM are passed to the child rather than ending the wrapper, so
+// the caller still gets to revoke the lease. It returns the child's
+// exit code. client is called for every renewal, so each goes out with
+// a fresh login token however long the command runs.
+func runWithLease(client func() (*backend.Client, error), ns string, lease *backend.DBLease, renewFor time.Duration, argv []string) (int, error) {
+	bin, err := exec.LookPath(argv[0])
+	if err != nil {
+		return 0, err
+	}
+	child := exec.Command(bin, argv[1:]...)
+	child.Env = append(os.Environ(), dbLeaseEnv(lease)...)
+	child.Stdin, child.Stdout, child.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	if err := child.Start(); err != nil {
+		return 0, fmt.Errorf("run %s: %w", argv[0], err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- child.Wait() }()
+
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	ttl := time.Duration(lease.LeaseDuration) * time.Second
+	if lease.Renewable {
+		go func() {
+			defer close(done)
+			keepLeaseAlive(ctx, func(ctx context.Context) (*backend.DBLease, error) {
+				cli, err := client()
+				if err != nil {
+					return nil, err
+				}
+				return cli.RenewDBLease(ctx, ns, lease.LeaseId, lease.LeaseDuration)
+			}, ttl, time.Now().Add(renewFor), os.Stderr)
+		}()
+	} else {
+		close(done)
+		fmt.Fprintf(os.Stderr, "kube-dc: the lease is not renewable and expires in %s\n", ttl)
+	}
+	for {
+		select {
+		case sig := <-sigs:
+			_ = child.Process.Signal(sig)
+		case waitErr := <-exited:
+			stop()
+			<-done
+			return childExitCode(waitErr)
+		}
+	}
+}
+
+// -------- issue ----------------------------------------------------
+
+func dbCredentialsIssueCmd() *cobra.Command {
+	var namespace, outFlag string
+	var ttl, renewUntil time.Duration
+	cmd := &cobra.Command{
+		Use:   "issue <name> [--renew-until <duration> -- <command> [args...]]",
+		Short: "Issue short-lived database credentials from a dynamic policy",
+		Long: `Issue a username and password from a mode=dynamic policy. OpenBao creates a
+database user with the policy's role that lives for a lease: the policy's TTL,
+or the shorter --ttl. Renew the lease with 'renew', end it early with 'revoke'.
+
+With --renew-until and a command after --, the command runs with the
+credentials in KUBE_DC_DB_USERNAME, KUBE_DC_DB_PASSWORD, KUBE_DC_DB_LEASE_ID
+and KUBE_DC_DB_LEASE_DURATION. The lease is renewed while the command runs,
+for at most the --renew-until duration and never past the policy's max TTL,
+and revoked when the command exits. The command's exit status is passed
+through.
+
+Dynamic credentials are not available yet. The command first checks that the
+backend serves the lease API, and stops before issuing anything when it does
+not.`,
+		Example: `  kube-dc db credentials issue docs-pg-readonly
+  eval "$(kube-dc db credentials issue docs-pg-readonly --ttl 15m -o env)"
+  kube-dc db credentials issue docs-pg-migrate --renew-until 2h -- ./migrate.sh`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			var argv []string
+			if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+				if dash != 1 {
+					return errors.New("pass exactly one policy name before --")
+				}
+				argv = args[1:]
+			} else if len(args) > 1 {
+				return errors.New("pass the command to wrap after --")
+			}
+			switch {
+			case len(argv) > 0 && renewUntil <= 0:
+				return errors.New("wrapping a command needs --renew-until <duration>, the longest the lease is kept alive")
+			case len(argv) == 0 && renewUntil > 0:
+				return errors.New("--renew-until needs a command after --")
+			}
+			envOut, out, err := parseDBOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.backend()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			if err := requireDBLeases(ctx, cli, scope.Namespace); err != nil {
+				return err
+			}
+			var ttlArg string
+			if ttl > 0 {
+				ttlArg = fmt.Sprintf("%ds", int(ttl.Seconds()))
+			}
+			lease, err := cli.IssueDBCredentials(ctx, scope.Namespace, name, ttlArg)
+			if err != nil {
+				return dynamicModeError(err)
+			}
+
+			if len(argv) > 0 {
+				fmt.Fprintf(os.Stderr, "Issued %s for %s (lease %s, %ds)\n", lease.Username, name, lease.LeaseId, lease.LeaseDuration)
+				// The command can outlive the login token it started
+				// with; renewals and the final revoke refresh it.
+				fresh := func() (*backend.Client, error) {
+					if err := scope.refreshToken(); err != nil {
+						return nil, err
+					}
+					return scope.backend()
+				}
+				code, runErr := runWithLease(fresh, scope.Namespace, lease, renewUntil, argv)
+				revokeCtx, revokeCancel := ctxWithTimeout()
+				defer revokeCancel()
+				cli, err := fresh()
+				if err == nil {
+					_, err = cli.RevokeDBLeases(revokeCtx, scope.Namespace, lease.LeaseId, false)
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: revoke lease %s: %v; it expires on its own\n", lease.LeaseId, err)
+				}
+				if runErr != nil {
+					return runErr
+				}
+				if code != 0 {
+					return &exitCodeError{code: code}
+				}
+				return nil
+			}
+
+			if envOut {
+				return printDBLeaseEnv(lease)
+			}
+			if out != outTable {
+				return printSerialized(out, lease)
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			defer w.Flush()
+			fmt.Fprintf(w, "Username:\t%s\n", lease.Username)
+			fmt.Fprintf(w, "Password:\t%s\n", lease.Password)
+			if lease.LeaseId != "" {
+				fmt.Fprintf(w, "Lease ID:\t%s\n", lease.LeaseId)
+			}
+			if lease.LeaseDuration > 0 {
+				fmt.Fprintf(w, "Lease Duration:\t%d s\n", lease.LeaseDuration)
+			}
+			if lease.ExpireTime != "" {
+				fmt.Fprintf(w, "Expires:\t%s\n", lease.ExpireTime)
+			}
+			fmt.Fprintf(w, "Renewable:\t%v\n", lease.Renewable)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml|env (env emits `KEY=value` for shell `eval`)")
+	cmd.Flags().DurationVar(&ttl, "ttl", 0, "Lease TTL, up to the policy's (default: the policy's TTL)")
+	cmd.Flags().DurationVar(&renewUntil, "renew-until", 0, "Run the command after -- and renew the lease while it runs, for at most this long")
+	return cmd
+}
+
+// -------- renew / revoke -------------------------------------------
+
+func dbCredentialsRenewCmd() *cobra.Command {
+	var namespace string
+	var increment time.Duration
+	cmd := &cobra.Command{
+		Use:   "renew <lease-id>",
+		Short: "Extend a dynamic credential lease",
+		Long: `Extend a lease from 'issue' by --increment (default: the policy's TTL).
+OpenBao never extends a lease past the policy's max TTL; the remaining time is
+printed.`,
+		Example: `  kube-dc db credentials renew database/creds/acme-web-docs-pg-readonly/AbC123 --increment 1h`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.backend()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			if err := requireDBLeases(ctx, cli, scope.Namespace); err != nil {
+				return err
+			}
+			lease, err := cli.RenewDBLease(ctx, scope.Namespace, args[0], int(increment.Seconds()))
+			if err != nil {
+				return dynamicModeError(err)
+			}
+			remaining := time.Duration(lease.LeaseDuration) * time.Second
+			fmt.Printf("Renewed lease %s; expires in %s\n", args[0], remaining)
+			if increment > 0 && remaining < increment {
+				fmt.Fprintf(os.Stderr, "The lease has reached its policy's max TTL and cannot be extended further.\n")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().DurationVar(&increment, "increment", 0, "How far to extend the lease (default: the policy's TTL)")
+	return cmd
+}
+
+func dbCredentialsRevokeCmd() *cobra.Command {
+	var namespace, prefix string
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "revoke <lease-id> | --prefix <prefix>",
+		Short: "Revoke dynamic credential leases and drop their database users",
+		Long: `Revoke one lease, or with --prefix every lease of the Project whose ID starts
+with the prefix. OpenBao drops the database users at once, so connections
+using them fail. --prefix needs --yes.`,
+		Example: `  kube-dc db credentials revoke database/creds/acme-web-docs-pg-readonly/AbC123
+  kube-dc db credentials revoke --prefix database/creds/acme-web-docs-pg-readonly/ --yes`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (len(args) == 1) == (prefix != "") {
+				return errors.New("pass a lease ID or --prefix, not both")
+			}
+			id := prefix
+			if len(args) == 1 {
+				id = args[0]
+			}
+			if prefix != "" && !yes {
+				fmt.Fprintf(os.Stderr, "Revoke every lease starting with %s? Re-run with --yes to confirm.\n", prefix)
+				return fmt.Errorf("not confirmed")
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.backend()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			if err := requireDBLeases(ctx, cli, scope.Namespace); err != nil {
+				return err
+			}
+			res, err := cli.RevokeDBLeases(ctx, scope.Namespace, id, prefix != "")
+			if err != nil {
+				return dynamicModeError(err)
+			}
+			if prefix != "" {
+				fmt.Printf("Revoked %d lease(s) under %s\n", res.Revoked, prefix)
+			} else {
+				fmt.Printf("Revoked lease %s\n", id)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVar(&prefix, "prefix", "", "Revoke every lease whose ID starts with this prefix")
+	cmd.Flags().BoolVar(&yes, "yes", false, "Confirm a --prefix revocation")
+	return cmd
+}
+
+// -------- leases list ----------------------------------------------
+
+func dbCredentialsLeasesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "leases",
+		Short: "Outstanding dynamic credential leases",
+	}
+	cmd.AddCommand(dbCredentialsLeasesListCmd())
+	return cmd
+}
+
+func dbCredentialsLeasesListCmd() *cobra.Command {
+	var namespace, policy, outFlag string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List outstanding leases in the current Project",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.backend()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			if err := requireDBLeases(ctx, cli, scope.Namespace); err != nil {
+				return err
+			}
+			list, err := cli.ListDBLeases(ctx, scope.Namespace, policy)
+			if err != nil {
+				return dynamicModeError(err)
+			}
+			if out != outTable {
+				return printSerialized(out, list)
+			}
+			return printDBLeaseTable(list.Items)
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVar(&policy, "policy", "", "Only leases issued from this policy")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+func printDBLeaseTable(items []backend.DBLeaseSummary) error {
+	if len(items) == 0 {
+		fmt.Println("No outstanding leases")
+		return nil
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Policy != items[j].Policy {
+			return items[i].Policy < items[j].Policy
+		}
+		return items[i].ExpireTime < items[j].ExpireTime
+	})
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LEASE-ID\tPOLICY\tUSER\tTTL\tEXPIRES\tRENEWABLE")
+	for _, l := range items {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%v\n",
+			l.LeaseId,
+			fmtCoalesce(l.Policy, "-"),
+			fmtCoalesce(l.Username, "-"),
+			time.Duration(l.TTL)*time.Second,
+			fmtCoalesce(l.ExpireTime, "-"),
+			l.Renewable,
+		)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/backend"
+)
+
+func TestKeepLeaseAlive(t *testing.T) {
+	var mu sync.Mutex
+	renewals := 0
+	renew := func(context.Context) (*backend.DBLease, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		renewals++
+		switch renewals {
+		case 2:
+			return nil, errors.New("backend 503")
+		case 3:
+			// Hit the policy's max TTL.
+			return &backend.DBLease{LeaseDuration: 0}, nil
+		}
+		return &backend.DBLease{LeaseDuration: 1, Renewable: true}, nil
+	}
+	var warn bytes.Buffer
+	start := time.Now()
+	keepLeaseAlive(context.Background(), renew, 150*time.Millisecond, start.Add(time.Minute), &warn)
+	if renewals != 3 {
+		t.Errorf("renewals = %d", renewals)
+	}
+	if got := warn.String(); !strings.Contains(got, "renew lease: backend 503") || !strings.Contains(got, "max TTL") {
+		t.Errorf("warnings = %q", got)
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Errorf("took %s", d)
+	}
+}
+
+func TestKeepLeaseAlive_StopsAtDeadlineAndCancel(t *testing.T) {
+	renewals := 0
+	renew := func(context.Context) (*backend.DBLease, error) {
+		renewals++
+		return &backend.DBLease{LeaseDuration: 3600, Renewable: true}, nil
+	}
+	var warn bytes.Buffer
+	keepLeaseAlive(context.Background(), renew, time.Hour, time.Now().Add(20*time.Millisecond), &warn)
+	if renewals != 0 || !strings.Contains(warn.String(), "--renew-until reached") {
+		t.Errorf("deadline: %d renewals, %q", renewals, warn.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { time.Sleep(20 * time.Millisecond); cancel() }()
+	warn.Reset()
+	keepLeaseAlive(ctx, renew, time.Hour, time.Now().Add(48*time.Hour), &warn)
+	if renewals != 0 || warn.Len() != 0 {
+		t.Errorf("cancel: %d renewals, %q", renewals, warn.String())
+	}
+}
+
+// A renewal that comes back not renewable is the last one.
+func TestKeepLeaseAlive_StopsWhenNotRenewable(t *testing.T) {
+	renewals := 0
+	renew := func(context.Context) (*backend.DBLease, error) {
+		renewals++
+		return &backend.DBLease{LeaseDuration: 1}, nil
+	}
+	var warn bytes.Buffer
+	keepLeaseAlive(context.Background(), renew, 30*time.Millisecond, time.Now().Add(time.Minute), &warn)
+	if renewals != 1 || !strings.Contains(warn.String(), "no longer renewable") {
+		t.Errorf("%d renewals, %q", renewals, warn.String())
+	}
+}
+
+func TestDynamicModeError(t *testing.T) {
+	err := dynamicModeError(&backend.APIError{Status: 501, Message: "DynamicModeDeferred"})
+	if !strings.Contains(err.Error(), "not available on this installation") || !strings.Contains(err.Error(), "DynamicModeDeferred") {
+		t.Errorf("501: %v", err)
+	}
+	other := &backend.APIError{Status: 403, Message: "forbidden"}
+	if got := dynamicModeError(other); got != other {
+		t.Errorf("403 = %v", got)
+	}
+}
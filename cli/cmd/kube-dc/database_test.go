@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/shalb/kube-dc/cli/internal/backend"
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+)
+
+func testDBOpts() dbOpts {
+	return dbOpts{
+		Name: "orders-pg", Namespace: "acme-web", CPU: "1", Memory: "2Gi", Storage: "20Gi",
+		Database: "app", Username: "app", Expose: "internal", BackupSchedule: "0 2 * * *", BackupRetentionDays: 7,
+	}
+}
+
+func TestBuildKdcDatabase(t *testing.T) {
+	db, err := buildKdcDatabase(testDBOpts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Spec.Engine != "postgresql" || db.Spec.Version != "16" || db.Spec.Replicas != 2 || !db.Spec.Backup.Enabled || db.Spec.Backup.RetentionDays != 7 {
+		t.Errorf("spec = %+v", db.Spec)
+	}
+	if db.WriteServiceName() != "orders-pg-rw" || db.InternalEndpoint() != "orders-pg-rw.acme-web.svc:5432" {
+		t.Errorf("endpoint = %s", db.InternalEndpoint())
+	}
+
+	opts := testDBOpts()
+	opts.Name, opts.Engine, opts.NoBackup = "cms-db", "mysql", true
+	db, err = buildKdcDatabase(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Spec.Engine != "mariadb" || db.Spec.Version != "11.4" || db.Spec.Replicas != 1 || db.Spec.Backup.Enabled || db.Spec.Backup.Schedule != "" {
+		t.Errorf("mariadb spec = %+v, backup %+v", db.Spec, db.Spec.Backup)
+	}
+	if db.WriteServiceName() != "cms-db" {
+		t.Errorf("single MariaDB service = %s", db.WriteServiceName())
+	}
+	db.Spec.Replicas = 3
+	if db.WriteServiceName() != "cms-db-primary" || db.InternalEndpoint() != "cms-db-primary.acme-web.svc:3306" {
+		t.Errorf("HA MariaDB endpoint = %s", db.InternalEndpoint())
+	}
+
+	for name, mutate := range map[string]func(*dbOpts){
+		"version 12": func(o *dbOpts) { o.Version = "12" },
+		"engine":     func(o *dbOpts) { o.Engine = "oracle" },
+		"replicas":   func(o *dbOpts) { o.Replicas = 10 },
+		"memory":     func(o *dbOpts) { o.Memory = "lots" },
+		"gateway":    func(o *dbOpts) { o.Expose = "gateway" },
+		"retention":  func(o *dbOpts) { o.BackupRetentionDays = 400 },
+		"name":       func(o *dbOpts) { o.Name = "Orders_PG" },
+		"mariadb 16": func(o *dbOpts) { o.Engine, o.Version = "mariadb", "16" },
+	} {
+		o := testDBOpts()
+		mutate(&o)
+		if _, err := buildKdcDatabase(o); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestKdcDatabaseQuantity(t *testing.T) {
+	var db k8sapi.KdcDatabase
+	if err := json.Unmarshal([]byte(`{"spec":{"engine":"postgresql","cpu":2,"memory":"4Gi","storage":"20Gi"}}`), &db); err != nil {
+		t.Fatal(err)
+	}
+	if db.Spec.CPU != "2" || db.Spec.Memory != "4Gi" {
+		t.Errorf("spec = %+v", db.Spec)
+	}
+	b, _ := json.Marshal(db.Spec)
+	if !strings.Contains(string(b), `"cpu":"2"`) {
+		t.Errorf("marshal = %s", b)
+	}
+}
+
+func TestDBResizePatch(t *testing.T) {
+	db := &k8sapi.KdcDatabase{Spec: k8sapi.KdcDatabaseSpec{Storage: "20Gi"}}
+	patch, err := dbResizePatch(db, "2", "", "50Gi", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"spec": map[string]any{"cpu": "2", "storage": "50Gi", "replicas": 3}}
+	if !reflect.DeepEqual(patch, want) {
+		t.Errorf("patch = %v", patch)
+	}
+	if _, err := dbResizePatch(db, "", "", "10Gi", 0); err == nil || !strings.Contains(err.Error(), "can only grow") {
+		t.Errorf("shrink: %v", err)
+	}
+	if _, err := dbResizePatch(db, "", "", "", 0); err == nil {
+		t.Error("an empty resize must be rejected")
+	}
+	if _, err := dbResizePatch(db, "", "", "", 12); err == nil {
+		t.Error("12 replicas must be rejected")
+	}
+}
+
+func TestWaitForDatabase(t *testing.T) {
+	prev := dbPollInterval
+	dbPollInterval = 5 * time.Millisecond
+	t.Cleanup(func() { dbPollInterval = prev })
+
+	gets := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/apis/db.kube-dc.com/v1alpha1/namespaces/acme-web/kdcdatabases/")
+		db := k8sapi.KdcDatabase{Metadata: k8sapi.ObjectMeta{Name: name, Generation: 2}}
+		switch name {
+		case "resized":
+			// Still Ready from before the patch until the controller
+			// catches up with generation 2.
+			gets++
+			db.Status.Phase, db.Status.ObservedGeneration = "Ready", 1
+			if gets == 2 {
+				db.Status.Phase, db.Status.ObservedGeneration = "Upgrading", 2
+			}
+			if gets >= 3 {
+				db.Status.Phase = "Ready"
+				db.Status.ObservedGeneration = 2
+			}
+		case "broken":
+			db.Status.Phase, db.Status.ObservedGeneration = "Failed", 2
+			db.Status.Conditions = []k8sapi.Condition{{Type: "Ready", Status: "False", Reason: "QuotaExceeded", Message: "requests.storage exceeded"}}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"kind":"Status","code":404,"reason":"NotFound"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(db)
+	}))
+	defer srv.Close()
+	cli, _ := k8sapi.New(srv.URL, "token", "", false)
+
+	if _, err := waitForDatabase(context.Background(), cli, "acme-web", "resized", time.Second); err != nil || gets != 3 {
+		t.Errorf("resized: %v after %d gets", err, gets)
+	}
+	if _, err := waitForDatabase(context.Background(), cli, "acme-web", "broken", time.Second); err == nil || !strings.Contains(err.Error(), "QuotaExceeded") {
+		t.Errorf("broken: %v", err)
+	}
+	if _, err := waitForDatabase(context.Background(), cli, "acme-web", "gone", time.Second); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("gone: %v", err)
+	}
+}
+
+func TestSelectStaticPolicy(t *testing.T) {
+	items := []backend.DBCredentialPolicySummary{
+		{Name: "orders-app", DatabaseRef: "orders-pg", Mode: "static-rotated"},
+		{Name: "orders-ro", DatabaseRef: "orders-pg", Mode: "dynamic"},
+		{Name: "cms-app", DatabaseRef: "cms-db", Mode: "static-rotated"},
+		{Name: "cms-report", DatabaseRef: "cms-db", Mode: "static-rotated"},
+	}
+	if got, err := selectStaticPolicy(items, "orders-pg", ""); err != nil || got != "orders-app" {
+		t.Errorf("orders = %q, %v", got, err)
+	}
+	if _, err := selectStaticPolicy(items, "cms-db", ""); err == nil || !strings.Contains(err.Error(), "cms-app, cms-report") {
+		t.Errorf("ambiguous: %v", err)
+	}
+	if got, err := selectStaticPolicy(items, "cms-db", "cms-report"); err != nil || got != "cms-report" {
+		t.Errorf("--policy = %q, %v", got, err)
+	}
+	if _, err := selectStaticPolicy(items, "orders-pg", "orders-ro"); err == nil {
+		t.Error("a dynamic policy cannot log in")
+	}
+	if _, err := selectStaticPolicy(items, "new-db", ""); err == nil || !strings.Contains(err.Error(), "db credentials create new-db-app --database new-db") {
+		t.Errorf("none: %v", err)
+	}
+}
+
+func TestDBExternalEndpoint(t *testing.T) {
+	db, _ := buildKdcDatabase(testDBOpts())
+	db.Metadata.Namespace = "acme-web"
+	if _, err := dbExternalEndpoint(db); err == nil || !strings.Contains(err.Error(), "orders-pg-rw.acme-web.svc:5432") {
+		t.Errorf("internal: %v", err)
+	}
+
+	db.Spec.Expose = &k8sapi.KdcDatabaseExpose{Type: "loadbalancer"}
+	if _, err := dbExternalEndpoint(db); err == nil || !strings.Contains(err.Error(), "no external endpoint yet") {
+		t.Errorf("pending: %v", err)
+	}
+	db.Status.ExternalEndpoint = "100.65.0.42:5432"
+	if ep, err := dbExternalEndpoint(db); err != nil || ep != (dbEndpoint{Host: "100.65.0.42", Port: 5432, External: true}) {
+		t.Errorf("loadbalancer = %+v, %v", ep, err)
+	}
+	db.Status.ExternalEndpoint = "100.65.0.42"
+	if ep, err := dbExternalEndpoint(db); err != nil || ep.Port != 5432 {
+		t.Errorf("loadbalancer without a port = %+v, %v", ep, err)
+	}
+
+	// The controller reports the engine port for the Gateway; it listens
+	// on 443.
+	db.Spec.Expose.Type = "gateway"
+	db.Status.ExternalEndpoint = "orders-pg-db-acme-web.kube-dc.cloud:5432"
+	if ep, err := dbExternalEndpoint(db); err != nil || ep != (dbEndpoint{Host: "orders-pg-db-acme-web.kube-dc.cloud", Port: 443, External: true, Gateway: true}) {
+		t.Errorf("gateway = %+v, %v", ep, err)
+	}
+	db.Spec.Engine = "mariadb"
+	if _, err := dbExternalEndpoint(db); err == nil || !strings.Contains(err.Error(), "loadbalancer") {
+		t.Errorf("mariadb gateway: %v", err)
+	}
+}
+
+func TestDBClientCommand(t *testing.T) {
+	have := func(bins ...string) func(string) (string, error) {
+		return func(name string) (string, error) {
+			for _, b := range bins {
+				if b == name {
+					return "/usr/bin/" + name, nil
+				}
+			}
+			return "", errors.New("not found")
+		}
+	}
+	forward := func(port int) dbEndpoint { return dbEndpoint{Host: "127.0.0.1", Port: port} }
+	c, err := dbClientCommand("postgresql", "", have("psql"), forward(15432), "app", "s3cret", "orders", []string{"-c", "select 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Bin != "/usr/bin/psql" || !reflect.DeepEqual(c.Args, []string{"-c", "select 1"}) || !containsString(c.Env, "PGPASSWORD=s3cret") || !containsString(c.Env, "PGPORT=15432") {
+		t.Errorf("psql = %+v", c)
+	}
+	c, err = dbClientCommand("mariadb", "", have("mysql"), forward(13306), "wp", "s3cret", "wordpress", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Bin != "/usr/bin/mysql" || !reflect.DeepEqual(c.Args, []string{"--host=127.0.0.1", "--port=13306", "--user=wp", "wordpress"}) || !reflect.DeepEqual(c.Env, []string{"MYSQL_PWD=s3cret"}) {
+		t.Errorf("mysql = %+v", c)
+	}
+	c, _ = dbClientCommand("mariadb", "", have("mariadb", "mysql"), forward(13306), "wp", "s3cret", "wordpress", nil)
+	if c.Bin != "/usr/bin/mariadb" || !containsString(c.Args, "--skip-ssl-verify-server-cert") {
+		t.Errorf("mariadb = %+v", c)
+	}
+	for _, c := range []*dbClient{c} {
+		if strings.Contains(strings.Join(c.Args, " "), "s3cret") {
+			t.Error("the password must never be on the command line")
+		}
+	}
+	if _, err := dbClientCommand("postgresql", "", have(), forward(1), "u", "p", "d", nil); err == nil || !strings.Contains(err.Error(), "--forward-only") {
+		t.Errorf("no client: %v", err)
+	}
+
+	// An external endpoint is always verified, against the CA file or,
+	// for psql, the system trust store.
+	lb := dbEndpoint{Host: "100.65.0.42", Port: 5432, External: true}
+	c, _ = dbClientCommand("postgresql", "", have("psql"), lb, "app", "s3cret", "orders", nil)
+	if !containsString(c.Env, "PGHOST=100.65.0.42") || !containsString(c.Env, "PGSSLMODE=verify-full") || !containsString(c.Env, "PGSSLROOTCERT=system") || containsString(c.Env, "PGSSLNEGOTIATION=direct") {
+		t.Errorf("psql external = %+v", c)
+	}
+	gw := dbEndpoint{Host: "orders.example.com", Port: 443, External: true, Gateway: true, CAFile: "/tmp/ca.crt"}
+	c, _ = dbClientCommand("postgresql", "", have("psql"), gw, "app", "s3cret", "orders", nil)
+	if !containsString(c.Env, "PGSSLROOTCERT=/tmp/ca.crt") || !containsString(c.Env, "PGSSLNEGOTIATION=direct") {
+		t.Errorf("psql via gateway = %+v", c)
+	}
+	lb = dbEndpoint{Host: "100.65.0.43", Port: 3306, External: true}
+	if _, err := dbClientCommand("mariadb", "", have("mariadb"), lb, "wp", "s3cret", "wordpress", nil); err == nil || !strings.Contains(err.Error(), "--ca-file") {
+		t.Errorf("mariadb external without a CA: %v", err)
+	}
+	lb.CAFile = "/tmp/ca.crt"
+	c, _ = dbClientCommand("mariadb", "", have("mariadb"), lb, "wp", "s3cret", "wordpress", nil)
+	if !reflect.DeepEqual(c.Args, []string{"--host=100.65.0.43", "--port=3306", "--user=wp", "--ssl", "--ssl-verify-server-cert", "--ssl-ca=/tmp/ca.crt", "wordpress"}) {
+		t.Errorf("mariadb external = %+v", c)
+	}
+	c, _ = dbClientCommand("mariadb", "", have("mysql"), lb, "wp", "s3cret", "wordpress", nil)
+	if !reflect.DeepEqual(c.Args, []string{"--host=100.65.0.43", "--port=3306", "--user=wp", "--ssl-mode=VERIFY_IDENTITY", "--ssl-ca=/tmp/ca.crt", "wordpress"}) {
+		t.Errorf("mysql external = %+v", c)
+	}
+}
+
+// kubeletForward stands in for the kubelet's v4.channel.k8s.io
+// port-forward: it announces the port on both channels, then echoes
+// channel-0 data back upper-cased.
+func kubeletForward(t *testing.T, w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{Subprotocols: []string{"v4.channel.k8s.io"}}
+	if r.URL.Query().Get("ports") != "5432" {
+		t.Errorf("ports = %q", r.URL.Query().Get("ports"))
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	port := make([]byte, 2)
+	binary.LittleEndian.PutUint16(port, 5432)
+	_ = conn.WriteMessage(websocket.BinaryMessage, append([]byte{0}, port...))
+	_ = conn.WriteMessage(websocket.BinaryMessage, append([]byte{1}, port...))
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if len(msg) == 0 || msg[0] != 0 {
+			t.Errorf("client wrote %q", msg)
+			return
+		}
+		if string(msg[1:]) == "boom" {
+			_ = conn.WriteMessage(websocket.BinaryMessage, append([]byte{1}, "connection refused"...))
+			return
+		}
+		_ = conn.WriteMessage(websocket.BinaryMessage, append([]byte{0}, strings.ToUpper(string(msg[1:]))...))
+	}
+}
+
+func TestDBConnectForward(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/namespaces/acme-web/services/orders-pg-rw":
+			_, _ = w.Write([]byte(`{"metadata":{"name":"orders-pg-rw"},"spec":{"selector":{"cnpg.io/cluster":"orders-pg","cnpg.io/instanceRole":"primary"},"ports":[{"name":"postgres","port":5432,"targetPort":"postgresql"}]}}`))
+		case "/api/v1/namespaces/acme-web/pods":
+			if got := r.URL.Query().Get("labelSelector"); got != "cnpg.io/cluster=orders-pg,cnpg.io/instanceRole=primary" {
+				t.Errorf("labelSelector = %q", got)
+			}
+			_, _ = w.Write([]byte(`{"items":[
+				{"metadata":{"name":"orders-pg-1"},"status":{"phase":"Pending"}},
+				{"metadata":{"name":"orders-pg-2"},"spec":{"containers":[{"name":"postgres","ports":[{"name":"postgresql","containerPort":5432}]}]},
+				 "status":{"phase":"Running","conditions":[{"type":"Ready","status":"True"}]}}]}`))
+		case "/api/v1/namespaces/acme-web/pods/orders-pg-2/portforward":
+			kubeletForward(t, w, r)
+		default:
+			t.Errorf("unexpected %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	cli, _ := k8sapi.New(srv.URL, "token", "", false)
+
+	db, _ := buildKdcDatabase(testDBOpts())
+	pod, port, err := dbForwardTarget(context.Background(), cli, db)
+	if err != nil || pod != "orders-pg-2" || port != 5432 {
+		t.Fatalf("target = %s:%d, %v", pod, port, err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go serveForward(ln, func(ctx context.Context) (io.ReadWriteCloser, error) {
+		return cli.PodPortForward(ctx, "acme-web", pod, port)
+	})
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte("startup")); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, len("STARTUP"))
+	if _, err := io.ReadFull(conn, got); err != nil || string(got) != "STARTUP" {
+		t.Errorf("forwarded = %q, %v", got, err)
+	}
+
+	// An error on the error channel surfaces from Read.
+	stream, err := cli.PodPortForward(context.Background(), "acme-web", pod, port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	_, _ = stream.Write([]byte("boom"))
+	if _, err := io.ReadAll(stream); err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("error channel: %v", err)
+	}
+}
@@ -0,0 +1,712 @@
+// `kube-dc expose` — publish a Deployment, VM or existing Service of
+// the current Project. HTTP, HTTPS and TLS passthrough go through the
+// platform Gateway: the CLI writes a LoadBalancer Service carrying the
+// service.nlb.kube-dc.com/expose-route annotation and the Service
+// controller generates the HTTPRoute or TLSRoute for it. --tcp skips
+// the Gateway and gives the Service an EIp of its own, or binds it to
+// a named one. See docs/cloud/service-exposure.md.
+//
+// A --host on an https route gets a public ManagedCertificate through
+// the backend, so the Organization's allowlist must cover the name.
+// Before anything is written the hostname must resolve to the platform
+// ingress, which is taken to be wherever backend.<domain> resolves:
+// the console backend is served by the same Gateway.
+//
+// Steps:
+//   Service             — GET, then POST or merge-PATCH              (k8s)
+//   ManagedCertificate  — POST /api/certificates/{ns}/{name}         (backend)
+//   route               — list HTTPRoutes/TLSRoutes until Accepted   (k8s)
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/backend"
+	"github.com/shalb/kube-dc/cli/internal/bootstrap/adapters/dns"
+	"github.com/shalb/kube-dc/cli/internal/bootstrap/ports"
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+	"github.com/spf13/cobra"
+)
+
+// resolveA returns a name's A records; nil with no error means the
+// name does not exist. A var so tests can stub DNS.
+var resolveA = func(ctx context.Context, name string) ([]string, error) {
+	return dns.New().Resolve(ctx, name, ports.DNSRecordTypeA)
+}
+
+var validExposeKinds = []string{"deployment", "service", "vm"}
+
+// exposeOptions is the validated flag set. Mode is http, https,
+// tls-passthrough or tcp.
+type exposeOptions struct {
+	Mode        string
+	Host        string
+	Ports       []k8sapi.ServicePort
+	Name        string
+	EIp         string
+	NetworkType string
+	Issuer      string
+}
+
+func (o *exposeOptions) routed() bool { return o.Mode != "tcp" }
+
+func exposeCmd() *cobra.Command {
+	var namespace string
+	var opts exposeOptions
+	var rawPorts []string
+	var asHTTP, asHTTPS, asPassthrough, asTCP bool
+	var ignoreQuota, skipDNSCheck, noWait bool
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "expose <deployment|service|vm> <name>",
+		Short: "Publish a workload through the platform Gateway or a LoadBalancer address",
+		Long: `Publish a Deployment, a VM or an existing Service of the current Project.
+
+  --http             HTTPRoute on the platform Gateway, port 80
+  --https            HTTPRoute on the platform Gateway, TLS terminated there
+  --tls-passthrough  TLSRoute on the platform Gateway; the workload terminates TLS
+  --tcp              a LoadBalancer address of its own (or --eip); any TCP/UDP port
+
+For a Deployment or VM, expose creates a LoadBalancer Service named after it
+(--name to override) selecting its pods. For a Service, expose annotates it
+and turns it into a LoadBalancer.
+
+Without --host a route gets a generated hostname under the platform domain.
+With --host the name must already resolve to the platform ingress (checked
+before anything is created; --skip-dns-check to bypass), and --https issues a
+public ManagedCertificate for it, which the Organization's allowlist must
+permit. A generated https hostname uses the Project's cert-manager Issuer
+(--issuer, default letsencrypt), which must exist.
+
+expose waits until the route is Accepted (and the certificate Ready), or until
+a --tcp Service has its address, then prints the URL.`,
+		Example: `  kube-dc expose deployment web --https
+  kube-dc expose deployment web --https --host www.example.com
+  kube-dc expose service grpc --tls-passthrough --port 8443
+  kube-dc expose vm db-1 --tcp --port 5432 --network-type cloud
+  kube-dc expose deployment dns --tcp --port 53/udp --eip default-gw`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kind, name := args[0], args[1]
+			if !containsString(validExposeKinds, kind) {
+				return fmt.Errorf("invalid kind %q (want %s)", kind, strings.Join(validExposeKinds, "|"))
+			}
+			mode, err := exposeMode(asHTTP, asHTTPS, asPassthrough, asTCP)
+			if err != nil {
+				return err
+			}
+			opts.Mode = mode
+			if opts.Ports, err = parseExposePorts(rawPorts); err != nil {
+				return err
+			}
+			if err := opts.validate(kind); err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			deadline := time.Now().Add(timeout)
+
+			svc, existing, err := planExposeService(ctx, cli, scope.Namespace, kind, name, &opts)
+			if err != nil {
+				return err
+			}
+			svcName := svc.Metadata.Name
+
+			if opts.routed() && opts.Host != "" && !skipDNSCheck {
+				if err := checkIngressDNS(ctx, opts.Host, "backend."+scope.Domain); err != nil {
+					return err
+				}
+			}
+			if opts.Mode == "https" && opts.Host == "" {
+				issuer := fmtCoalesce(opts.Issuer, "letsencrypt")
+				if _, err := cli.GetIssuer(ctx, scope.Namespace, issuer); err != nil {
+					if k8sapi.IsNotFound(err) {
+						return fmt.Errorf("Issuer %s not found in %s; create it once per Project (docs/cloud/service-exposure.md, \"Create the Issuer\"), name another with --issuer, or pass --host to use a ManagedCertificate", issuer, scope.Namespace)
+					}
+					return fmt.Errorf("read Issuer %s: %w", issuer, err)
+				}
+			}
+			if opts.Mode == "tcp" && opts.EIp != "" && opts.EIp != k8sapi.DefaultGwEIp {
+				if _, err := cli.GetEIp(ctx, scope.Namespace, opts.EIp); err != nil {
+					if k8sapi.IsNotFound(err) {
+						return fmt.Errorf("no EIp named %s in %s; see `kube-dc ip list`", opts.EIp, scope.Namespace)
+					}
+					return err
+				}
+			}
+			if exposeAllocatesPublicIP(&opts, svc) && !ignoreQuota {
+				q, err := readPublicIPQuota(ctx, cli)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: %v; the EIp controller still enforces the quota\n", err)
+				}
+				if err := q.check(1); err != nil {
+					return err
+				}
+			}
+
+			var cert string
+			if opts.Mode == "https" && opts.Host != "" {
+				bcli, err := scope.backend()
+				if err != nil {
+					return err
+				}
+				c, err := bcli.CreateCertificate(ctx, scope.Namespace, svcName, backend.CreateCertificateOptions{
+					Type:             "public",
+					Purpose:          "server",
+					DnsNames:         []string{opts.Host},
+					TargetSecretName: exposeCertSecret(svcName),
+				})
+				if err != nil {
+					return fmt.Errorf("create ManagedCertificate %s for %s: %w", svcName, opts.Host, err)
+				}
+				cert = c.Name
+				fmt.Printf("Requested ManagedCertificate %s for %s\n", cert, opts.Host)
+			}
+
+			if existing {
+				if patch := exposePatch(svc, &opts); patch != nil {
+					if _, err := cli.PatchService(ctx, scope.Namespace, svcName, patch); err != nil {
+						return err
+					}
+					fmt.Printf("Updated Service %s (%s)\n", svcName, exposeDescription(&opts))
+				}
+			} else {
+				if _, err := cli.CreateService(ctx, svc); err != nil {
+					return err
+				}
+				fmt.Printf("Created Service %s (%s)\n", svcName, exposeDescription(&opts))
+			}
+			if noWait {
+				return nil
+			}
+
+			if !opts.routed() {
+				return waitForTCPExposure(cli, scope.Namespace, svcName, opts.Host, time.Until(deadline))
+			}
+			host, err := waitForRouteExposure(cli, scope.Namespace, svcName, opts.Mode, time.Until(deadline))
+			if err != nil {
+				return err
+			}
+			if cert != "" {
+				bcli, err := scope.backend()
+				if err != nil {
+					return err
+				}
+				if err := waitForCertificateReady(bcli, scope.Namespace, cert, time.Until(deadline)); err != nil {
+					return err
+				}
+			}
+			fmt.Println(exposeURL(opts.Mode, fmtCoalesce(opts.Host, host)))
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().BoolVar(&asHTTP, "http", false, "Plain HTTP route on the platform Gateway")
+	cmd.Flags().BoolVar(&asHTTPS, "https", false, "HTTPS route on the platform Gateway, TLS terminated there")
+	cmd.Flags().BoolVar(&asPassthrough, "tls-passthrough", false, "TLS passthrough route on the platform Gateway")
+	cmd.Flags().BoolVar(&asTCP, "tcp", false, "LoadBalancer address of its own instead of a Gateway route")
+	cmd.Flags().StringVar(&opts.Host, "host", "", "Hostname to serve (default: generated under the platform domain)")
+	cmd.Flags().StringSliceVar(&rawPorts, "port", nil, "Port to expose: N, or N/udp with --tcp (repeatable with --tcp; default: the Deployment's declared port)")
+	cmd.Flags().StringVar(&opts.Name, "name", "", "Name of the Service to create (default: the workload's name)")
+	cmd.Flags().StringVar(&opts.EIp, "eip", "", "With --tcp: bind to this existing EIp (default-gw for the Project gateway's address)")
+	cmd.Flags().StringVar(&opts.NetworkType, "network-type", "", "With --tcp: pool for the Service's own address, public|cloud (default public)")
+	cmd.Flags().StringVar(&opts.Issuer, "issuer", "", "With --https and no --host: cert-manager Issuer for the generated hostname (default letsencrypt)")
+	cmd.Flags().BoolVar(&ignoreQuota, "ignore-quota", false, "Expose even when the Organization's usage summary shows the public IPv4 quota exhausted")
+	cmd.Flags().BoolVar(&skipDNSCheck, "skip-dns-check", false, "Do not require --host to resolve to the platform ingress first")
+	cmd.Flags().BoolVar(&noWait, "no-wait", false, "Return once the Service is written, without waiting for the route or address")
+	cmd.Flags().DurationVar(&timeout, "timeout", 5*time.Minute, "How long to wait for the route, certificate and address")
+	return cmd
+}
+
+func exposeMode(asHTTP, asHTTPS, asPassthrough, asTCP bool) (string, error) {
+	var modes []string
+	for _, m := range []struct {
+		set  bool
+		name string
+	}{{asHTTP, "http"}, {asHTTPS, "https"}, {asPassthrough, "tls-passthrough"}, {asTCP, "tcp"}} {
+		if m.set {
+			modes = append(modes, m.name)
+		}
+	}
+	if len(modes) != 1 {
+		return "", fmt.Errorf("pass exactly one of --http, --https, --tls-passthrough, --tcp")
+	}
+	return modes[0], nil
+}
+
+// parseExposePorts turns --port values ("8080", "53/udp") into Service
+// ports. A Service with several ports needs them named, so each is
+// named <protocol>-<port>.
+func parseExposePorts(raw []string) ([]k8sapi.ServicePort, error) {
+	var out []k8sapi.ServicePort
+	seen := map[string]bool{}
+	for _, r := range raw {
+		num, proto, _ := strings.Cut(r, "/")
+		proto = strings.ToUpper(fmtCoalesce(proto, "tcp"))
+		if proto != "TCP" && proto != "UDP" {
+			return nil, fmt.Errorf("invalid --port %q: protocol must be tcp or udp", r)
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("invalid --port %q: want a port number 1-65535", r)
+		}
+		name := strings.ToLower(proto) + "-" + num
+		if seen[name] {
+			return nil, fmt.Errorf("--port %s given twice", r)
+		}
+		seen[name] = true
+		out = append(out, k8sapi.ServicePort{Name: name, Protocol: proto, Port: n, TargetPort: n})
+	}
+	return out, nil
+}
+
+func (o *exposeOptions) validate(kind string) error {
+	if o.Host != "" && !validHostname(o.Host) {
+		return fmt.Errorf("invalid --host %q: want a DNS name such as www.example.com", o.Host)
+	}
+	if o.Name != "" {
+		if kind == "service" {
+			return fmt.Errorf("--name names a new Service; exposing a Service annotates it in place")
+		}
+		if !projectNameRE.MatchString(o.Name) {
+			return fmt.Errorf("invalid --name %q: must be a DNS label (lowercase letters, digits, '-')", o.Name)
+		}
+	}
+	if o.routed() {
+		if len(o.Ports) > 1 {
+			return fmt.Errorf("a Gateway route targets a single port; pass one --port, or use --tcp for several")
+		}
+		if len(o.Ports) == 1 && o.Ports[0].Protocol != "TCP" {
+			return fmt.Errorf("a Gateway route cannot carry UDP; use --tcp for --port %d/udp", o.Ports[0].Port)
+		}
+		if o.EIp != "" || o.NetworkType != "" {
+			return fmt.Errorf("--eip and --network-type apply only to --tcp; Gateway routes share the platform Gateway's address")
+		}
+	} else {
+		if o.NetworkType != "" && !containsString(validIPNetworkTypes, o.NetworkType) {
+			return fmt.Errorf("invalid --network-type %q (want %s)", o.NetworkType, strings.Join(validIPNetworkTypes, "|"))
+		}
+		if o.EIp != "" && o.NetworkType != "" {
+			return fmt.Errorf("--eip binds an existing address, whose type is already fixed; drop --network-type")
+		}
+		if kind == "service" && len(o.Ports) > 0 {
+			return fmt.Errorf("--tcp exposes a Service's own ports; --port applies only to a new Service")
+		}
+	}
+	if o.Issuer != "" && (o.Mode != "https" || o.Host != "") {
+		return fmt.Errorf("--issuer applies only to --https without --host; a --host gets a ManagedCertificate")
+	}
+	if kind == "vm" && len(o.Ports) == 0 {
+		return fmt.Errorf("--port is required for a VM: KubeVirt does not record which ports the guest listens on")
+	}
+	return nil
+}
+
+// validHostname accepts a lowercase DNS name with at least two labels.
+func validHostname(h string) bool {
+	labels := strings.Split(h, ".")
+	if len(h) > 253 || len(labels) < 2 {
+		return false
+	}
+	for _, l := range labels {
+		if len(l) > 63 || !projectNameRE.MatchString(l) {
+			return false
+		}
+	}
+	return true
+}
+
+// planExposeService returns the Service to create, or the existing one
+// to annotate (existing true). For an existing Service with several
+// ports, opts.Ports ends up holding the one to route.
+func planExposeService(ctx context.Context, cli *k8sapi.Client, ns, kind, name string, opts *exposeOptions) (*k8sapi.Service, bool, error) {
+	if kind == "service" {
+		svc, err := cli.GetService(ctx, ns, name)
+		if err != nil {
+			if k8sapi.IsNotFound(err) {
+				return nil, false, fmt.Errorf("Service %s not found in %s", name, ns)
+			}
+			return nil, false, err
+		}
+		if err := checkExistingService(svc, opts); err != nil {
+			return nil, false, err
+		}
+		return svc, true, nil
+	}
+
+	var selector map[string]string
+	switch kind {
+	case "deployment":
+		d, err := cli.GetDeployment(ctx, ns, name)
+		if err != nil {
+			if k8sapi.IsNotFound(err) {
+				return nil, false, fmt.Errorf("Deployment %s not found in %s", name, ns)
+			}
+			return nil, false, err
+		}
+		if selector, err = deploymentSelector(d); err != nil {
+			return nil, false, err
+		}
+		if len(opts.Ports) == 0 {
+			if opts.Ports, err = deploymentPorts(d, opts.routed()); err != nil {
+				return nil, false, err
+			}
+		}
+	case "vm":
+		if _, err := cli.GetVirtualMachine(ctx, ns, name); err != nil {
+			if k8sapi.IsNotFound(err) {
+				return nil, false, fmt.Errorf("VM %s not found in %s", name, ns)
+			}
+			return nil, false, err
+		}
+		selector = map[string]string{vmNameLabel: name}
+	}
+
+	svcName := fmtCoalesce(opts.Name, name)
+	if _, err := cli.GetService(ctx, ns, svcName); err == nil {
+		return nil, false, fmt.Errorf("Service %s already exists in %s; expose it with `kube-dc expose service %s`, or pick another name with --name", svcName, ns, svcName)
+	} else if !k8sapi.IsNotFound(err) {
+		return nil, false, err
+	}
+	return &k8sapi.Service{
+		Metadata: k8sapi.ObjectMeta{Name: svcName, Namespace: ns, Annotations: exposeAnnotations(opts, svcName, 0)},
+		Spec:     k8sapi.ServiceSpec{Type: "LoadBalancer", Selector: selector, Ports: opts.Ports},
+	}, false, nil
+}
+
+// deploymentSelector is the Deployment's matchLabels; a Service cannot
+// express matchExpressions.
+func deploymentSelector(d *k8sapi.Deployment) (map[string]string, error) {
+	sel := d.Spec.Selector
+	if len(sel.MatchExpressions) > 0 {
+		return nil, fmt.Errorf("Deployment %s selects pods with matchExpressions, which a Service selector cannot express; write the Service yourself and run `kube-dc expose service`", d.Metadata.Name)
+	}
+	if len(sel.MatchLabels) == 0 {
+		return nil, fmt.Errorf("Deployment %s has no matchLabels selector", d.Metadata.Name)
+	}
+	return sel.MatchLabels, nil
+}
+
+// deploymentPorts lists the container ports the Deployment declares. A
+// route needs exactly one TCP port to be unambiguous.
+func deploymentPorts(d *k8sapi.Deployment, routed bool) ([]k8sapi.ServicePort, error) {
+	var raw []string
+	for _, c := range d.Spec.Template.Spec.Containers {
+		for _, p := range c.Ports {
+			proto := strings.ToLower(fmtCoalesce(p.Protocol, "TCP"))
+			if routed && proto != "tcp" {
+				continue
+			}
+			raw = append(raw, strconv.Itoa(p.ContainerPort)+"/"+proto)
+		}
+	}
+	switch {
+	case len(raw) == 0:
+		return nil, fmt.Errorf("Deployment %s declares no container ports; pass --port", d.Metadata.Name)
+	case routed && len(raw) > 1:
+		return nil, fmt.Errorf("Deployment %s declares ports %s; pick the one to route with --port", d.Metadata.Name, strings.Join(raw, ", "))
+	}
+	return parseExposePorts(raw)
+}
+
+// checkExistingService refuses changes the controllers would reject or
+// silently ignore, and picks the routed port of a multi-port Service.
+func checkExistingService(svc *k8sapi.Service, opts *exposeOptions) error {
+	name, ann := svc.Metadata.Name, svc.Metadata.Annotations
+	if opts.routed() {
+		if cur := ann[k8sapi.ExposeRouteAnnotation]; cur != "" && cur != opts.Mode {
+			return fmt.Errorf("Service %s is already exposed as %s; remove the %s annotation first", name, cur, k8sapi.ExposeRouteAnnotation)
+		}
+		var ports []string
+		for _, p := range svc.Spec.Ports {
+			if p.Protocol == "" || p.Protocol == "TCP" {
+				ports = append(ports, strconv.Itoa(p.Port))
+			}
+		}
+		if len(opts.Ports) == 1 {
+			if !containsString(ports, strconv.Itoa(opts.Ports[0].Port)) {
+				return fmt.Errorf("Service %s has no TCP port %d (it has %s)", name, opts.Ports[0].Port, fmtCoalesce(strings.Join(ports, ", "), "none"))
+			}
+			return nil
+		}
+		switch {
+		case len(ports) == 0:
+			return fmt.Errorf("Service %s has no TCP port to route", name)
+		case len(ports) > 1:
+			return fmt.Errorf("Service %s has ports %s; pick the one to route with --port", name, strings.Join(ports, ", "))
+		}
+		return nil
+	}
+	if svc.Spec.Type == "LoadBalancer" && (opts.EIp != "" || opts.NetworkType != "") {
+		return fmt.Errorf("Service %s is already a LoadBalancer and its address type is fixed; move it with `kube-dc ip attach <eip> service %s`", name, name)
+	}
+	return nil
+}
+
+// exposeAnnotations are the Service annotations for opts. routePort is
+// set only when a multi-port Service needs it named.
+func exposeAnnotations(opts *exposeOptions, svcName string, routePort int) map[string]string {
+	a := map[string]string{}
+	if !opts.routed() {
+		switch {
+		case opts.EIp == k8sapi.DefaultGwEIp:
+			a[k8sapi.BindOnDefaultGwEIpAnnotation] = "true"
+		case opts.EIp != "":
+			a[k8sapi.BindOnEIpAnnotation] = opts.EIp
+		default:
+			a[k8sapi.ExternalNetworkTypeAnnotation] = fmtCoalesce(opts.NetworkType, "public")
+		}
+		return a
+	}
+	a[k8sapi.ExposeRouteAnnotation] = opts.Mode
+	if opts.Host != "" {
+		a[k8sapi.RouteHostnameAnnotation] = opts.Host
+	}
+	if routePort > 0 {
+		a[k8sapi.RoutePortAnnotation] = strconv.Itoa(routePort)
+	}
+	if opts.Mode == "https" {
+		if opts.Host != "" {
+			a[k8sapi.TLSSecretAnnotation] = exposeCertSecret(svcName)
+		} else if opts.Issuer != "" {
+			a[k8sapi.TLSIssuerAnnotation] = opts.Issuer
+		}
+	}
+	return a
+}
+
+// exposePatch is the merge patch for an existing Service, or nil when
+// a --tcp Service is already a LoadBalancer and needs nothing.
+func exposePatch(svc *k8sapi.Service, opts *exposeOptions) map[string]any {
+	if !opts.routed() && svc.Spec.Type == "LoadBalancer" {
+		return nil
+	}
+	routePort := 0
+	if len(opts.Ports) == 1 && len(svc.Spec.Ports) > 1 {
+		routePort = opts.Ports[0].Port
+	}
+	ann := map[string]any{}
+	for k, v := range exposeAnnotations(opts, svc.Metadata.Name, routePort) {
+		ann[k] = v
+	}
+	return map[string]any{
+		"metadata": map[string]any{"annotations": ann},
+		"spec":     map[string]any{"type": "LoadBalancer"},
+	}
+}
+
+// exposeAllocatesPublicIP reports whether writing svc makes the EIp
+// controller allocate a public address for it.
+func exposeAllocatesPublicIP(opts *exposeOptions, svc *k8sapi.Service) bool {
+	if opts.routed() || opts.EIp != "" || (svc.Spec.Type == "LoadBalancer" && svc.Metadata.CreationTimestamp != "") {
+		return false
+	}
+	return fmtCoalesce(opts.NetworkType, "public") == "public"
+}
+
+func exposeCertSecret(svcName string) string { return svcName + "-tls" }
+
+func exposeDescription(opts *exposeOptions) string {
+	switch {
+	case opts.routed():
+		return opts.Mode + " route on the platform Gateway"
+	case opts.EIp == k8sapi.DefaultGwEIp:
+		return "LoadBalancer on the Project gateway's address"
+	case opts.EIp != "":
+		return "LoadBalancer on EIp " + opts.EIp
+	default:
+		return "LoadBalancer with its own " + fmtCoalesce(opts.NetworkType, "public") + " address"
+	}
+}
+
+func exposeURL(mode, host string) string {
+	if mode == "http" {
+		return "http://" + host
+	}
+	return "https://" + host
+}
+
+// checkIngressDNS requires host to resolve to at least one address the
+// platform ingress name resolves to. An ingress that cannot be
+// resolved from here only warns: the check is a guard, not a gate.
+func checkIngressDNS(ctx context.Context, host, ingress string) error {
+	want, err := resolveA(ctx, ingress)
+	if err == nil && len(want) == 0 {
+		err = fmt.Errorf("no A records")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: cannot resolve the platform ingress %s (%v); skipping the DNS check for %s\n", ingress, err, host)
+		return nil
+	}
+	if err := checkHostResolvesTo(ctx, host, "the platform ingress "+ingress, want); err != nil {
+		return fmt.Errorf("%w; fix its DNS record first, or pass --skip-dns-check", err)
+	}
+	return nil
+}
+
+// checkHostResolvesTo fails unless host has an A record among want.
+func checkHostResolvesTo(ctx context.Context, host, what string, want []string) error {
+	got, err := resolveA(ctx, host)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", host, err)
+	}
+	if len(got) == 0 {
+		return fmt.Errorf("%s does not resolve; it should point at %s (%s)", host, what, strings.Join(want, ", "))
+	}
+	for _, ip := range got {
+		if containsString(want, ip) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s resolves to %s, not %s (%s)", host, strings.Join(got, ", "), what, strings.Join(want, ", "))
+}
+
+// findRoute returns the route whose backendRefs name the Service.
+func findRoute(routes []k8sapi.GatewayRoute, svc string) *k8sapi.GatewayRoute {
+	for i := range routes {
+		for _, rule := range routes[i].Spec.Rules {
+			for _, ref := range rule.BackendRefs {
+				if ref.Name == svc && (ref.Kind == "" || ref.Kind == "Service") {
+					return &routes[i]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// routeAccepted reports whether every parent Gateway has accepted the
+// route, with the parents' conditions for a timeout message.
+func routeAccepted(r *k8sapi.GatewayRoute) (bool, []k8sapi.Condition) {
+	var conds []k8sapi.Condition
+	accepted := len(r.Status.Parents) > 0
+	for _, p := range r.Status.Parents {
+		ok := false
+		for _, c := range p.Conditions {
+			if c.Type == "Accepted" && c.Status == "True" {
+				ok = true
+			}
+		}
+		accepted = accepted && ok
+		conds = append(conds, p.Conditions...)
+	}
+	return accepted, conds
+}
+
+// waitForRouteExposure waits for the controller's route for svc to be
+// Accepted and returns the hostname it serves.
+func waitForRouteExposure(cli *k8sapi.Client, ns, svc, mode string, timeout time.Duration) (string, error) {
+	kind := "HTTPRoute"
+	if mode == "tls-passthrough" {
+		kind = "TLSRoute"
+	}
+	var route *k8sapi.GatewayRoute
+	_, err := waitForAddress(context.Background(), kind+" for Service "+svc, timeout, func(ctx context.Context) (bool, string, []k8sapi.Condition, error) {
+		list := cli.ListHTTPRoutes
+		if kind == "TLSRoute" {
+			list = cli.ListTLSRoutes
+		}
+		routes, err := list(ctx, ns)
+		if err != nil {
+			return false, "", nil, err
+		}
+		if route = findRoute(routes.Items, svc); route == nil {
+			return false, "", nil, nil
+		}
+		ok, conds := routeAccepted(route)
+		return ok, route.Metadata.Name, conds, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("%s %s accepted by the platform Gateway\n", kind, route.Metadata.Name)
+	if len(route.Spec.Hostnames) > 0 {
+		return route.Spec.Hostnames[0], nil
+	}
+	ctx, cancel := ctxWithTimeout()
+	defer cancel()
+	s, err := cli.GetService(ctx, ns, svc)
+	if err != nil {
+		return "", err
+	}
+	if h := s.Metadata.Annotations[k8sapi.RouteHostnameStatusAnnotation]; h != "" {
+		return h, nil
+	}
+	return "", fmt.Errorf("%s %s has no hostname yet; check `kubectl get svc %s -o yaml`", kind, route.Metadata.Name, svc)
+}
+
+func waitForCertificateReady(cli *backend.Client, ns, name string, timeout time.Duration) error {
+	_, err := waitForAddress(context.Background(), "ManagedCertificate "+name, timeout, func(ctx context.Context) (bool, string, []k8sapi.Condition, error) {
+		c, err := cli.GetCertificate(ctx, ns, name)
+		if err != nil {
+			return false, "", nil, err
+		}
+		return certificateReadyFromConditions(c.Status.Conditions) == "True", c.Name, certConditions(c.Status.Conditions), nil
+	})
+	return err
+}
+
+// certConditions converts the backend's loosely typed conditions.
+func certConditions(in []map[string]any) []k8sapi.Condition {
+	var out []k8sapi.Condition
+	for _, c := range in {
+		var cond k8sapi.Condition
+		cond.Type, _ = c["type"].(string)
+		cond.Status, _ = c["status"].(string)
+		cond.Reason, _ = c["reason"].(string)
+		cond.Message, _ = c["message"].(string)
+		out = append(out, cond)
+	}
+	return out
+}
+
+// waitForTCPExposure waits for the LoadBalancer address and prints one
+// URL per port. A --host that does not point at it only warns: the
+// address did not exist until now.
+func waitForTCPExposure(cli *k8sapi.Client, ns, svc, host string, timeout time.Duration) error {
+	var current *k8sapi.Service
+	addr, err := waitForAddress(context.Background(), "Service "+svc, timeout, func(ctx context.Context) (bool, string, []k8sapi.Condition, error) {
+		s, err := cli.GetService(ctx, ns, svc)
+		if err != nil {
+			return false, "", nil, err
+		}
+		current = s
+		for _, in := range s.Status.LoadBalancer.Ingress {
+			if a := fmtCoalesce(in.IP, in.Hostname); a != "" {
+				return true, a, nil, nil
+			}
+		}
+		return false, "", nil, nil
+	})
+	if err != nil {
+		return err
+	}
+	if host != "" {
+		ctx, cancel := ctxWithTimeout()
+		defer cancel()
+		if err := checkHostResolvesTo(ctx, host, "Service "+svc, []string{addr}); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else {
+			addr = host
+		}
+	}
+	for _, p := range current.Spec.Ports {
+		fmt.Printf("%s://%s:%d\n", strings.ToLower(fmtCoalesce(p.Protocol, "TCP")), addr, p.Port)
+	}
+	return nil
+}
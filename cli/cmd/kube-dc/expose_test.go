@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+)
+
+func TestParseExposePorts(t *testing.T) {
+	got, err := parseExposePorts([]string{"8080", "53/udp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Name != "tcp-8080" || got[0].Protocol != "TCP" || got[1].Name != "udp-53" || got[1].Protocol != "UDP" || got[1].TargetPort != 53 {
+		t.Fatalf("ports = %+v", got)
+	}
+	for _, bad := range [][]string{{"0"}, {"http"}, {"80/sctp"}, {"80", "80/tcp"}} {
+		if _, err := parseExposePorts(bad); err == nil {
+			t.Errorf("%v: want an error", bad)
+		}
+	}
+}
+
+func TestExposeOptionsValidate(t *testing.T) {
+	ports := func(raw ...string) []k8sapi.ServicePort {
+		p, err := parseExposePorts(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	tests := []struct {
+		name    string
+		kind    string
+		opts    exposeOptions
+		wantErr string
+	}{
+		{name: "https deployment", kind: "deployment", opts: exposeOptions{Mode: "https", Host: "www.example.com"}},
+		{name: "tcp vm", kind: "vm", opts: exposeOptions{Mode: "tcp", Ports: ports("5432"), NetworkType: "cloud"}},
+		{name: "bad host", kind: "deployment", opts: exposeOptions{Mode: "http", Host: "Web_1"}, wantErr: "invalid --host"},
+		{name: "two route ports", kind: "deployment", opts: exposeOptions{Mode: "http", Ports: ports("80", "81")}, wantErr: "single port"},
+		{name: "udp route", kind: "deployment", opts: exposeOptions{Mode: "http", Ports: ports("53/udp")}, wantErr: "cannot carry UDP"},
+		{name: "eip on route", kind: "deployment", opts: exposeOptions{Mode: "https", EIp: "web"}, wantErr: "apply only to --tcp"},
+		{name: "eip and type", kind: "deployment", opts: exposeOptions{Mode: "tcp", EIp: "web", NetworkType: "public"}, wantErr: "drop --network-type"},
+		{name: "bad type", kind: "deployment", opts: exposeOptions{Mode: "tcp", NetworkType: "private"}, wantErr: "invalid --network-type"},
+		{name: "issuer with host", kind: "deployment", opts: exposeOptions{Mode: "https", Host: "www.example.com", Issuer: "le"}, wantErr: "--issuer applies only"},
+		{name: "vm without port", kind: "vm", opts: exposeOptions{Mode: "https"}, wantErr: "--port is required for a VM"},
+		{name: "name on service", kind: "service", opts: exposeOptions{Mode: "http", Name: "web"}, wantErr: "--name names a new Service"},
+		{name: "tcp service ports", kind: "service", opts: exposeOptions{Mode: "tcp", Ports: ports("80")}, wantErr: "only to a new Service"},
+	}
+	for _, tt := range tests {
+		err := tt.opts.validate(tt.kind)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+	if _, err := exposeMode(true, true, false, false); err == nil {
+		t.Error("two modes: want an error")
+	}
+}
+
+func testDeployment(t *testing.T, spec string) *k8sapi.Deployment {
+	t.Helper()
+	var d k8sapi.Deployment
+	if err := json.Unmarshal([]byte(`{"metadata":{"name":"web"},"spec":`+spec+`}`), &d); err != nil {
+		t.Fatal(err)
+	}
+	return &d
+}
+
+func TestDeploymentSelectorAndPorts(t *testing.T) {
+	d := testDeployment(t, `{"selector":{"matchLabels":{"app":"web"}},"template":{"spec":{"containers":[
+		{"name":"app","ports":[{"containerPort":8080},{"containerPort":9090,"name":"metrics"}]},
+		{"name":"dns","ports":[{"containerPort":53,"protocol":"UDP"}]}]}}}`)
+	sel, err := deploymentSelector(d)
+	if err != nil || sel["app"] != "web" {
+		t.Fatalf("selector = %v, %v", sel, err)
+	}
+	if _, err := deploymentPorts(d, true); err == nil || !strings.Contains(err.Error(), "declares ports 8080/tcp, 9090/tcp; pick the one to route with --port") {
+		t.Fatalf("routed: %v", err)
+	}
+	all, err := deploymentPorts(d, false)
+	if err != nil || len(all) != 3 || all[2].Name != "udp-53" {
+		t.Fatalf("tcp: %+v, %v", all, err)
+	}
+
+	single := testDeployment(t, `{"selector":{"matchLabels":{"app":"web"}},"template":{"spec":{"containers":[{"name":"app","ports":[{"containerPort":8080}]}]}}}`)
+	if p, err := deploymentPorts(single, true); err != nil || len(p) != 1 || p[0].Port != 8080 {
+		t.Fatalf("single: %+v, %v", p, err)
+	}
+	none := testDeployment(t, `{"selector":{"matchExpressions":[{"key":"app","operator":"In","values":["web"]}]},"template":{"spec":{"containers":[{"name":"app"}]}}}`)
+	if _, err := deploymentSelector(none); err == nil || !strings.Contains(err.Error(), "matchExpressions") {
+		t.Fatalf("expressions: %v", err)
+	}
+	if _, err := deploymentPorts(none, true); err == nil || !strings.Contains(err.Error(), "declares no container ports; pass --port") {
+		t.Fatalf("no ports: %v", err)
+	}
+}
+
+func TestExposeExistingService(t *testing.T) {
+	multi := &k8sapi.Service{
+		Metadata: k8sapi.ObjectMeta{Name: "api", CreationTimestamp: "2026-10-01T00:00:00Z"},
+		Spec:     k8sapi.ServiceSpec{Type: "ClusterIP", Ports: []k8sapi.ServicePort{{Name: "http", Port: 80}, {Name: "grpc", Port: 50051}}},
+	}
+	opts := &exposeOptions{Mode: "https"}
+	if err := checkExistingService(multi, opts); err == nil || !strings.Contains(err.Error(), "has ports 80, 50051") {
+		t.Fatalf("multi-port without --port: %v", err)
+	}
+	opts.Ports, _ = parseExposePorts([]string{"8443"})
+	if err := checkExistingService(multi, opts); err == nil || !strings.Contains(err.Error(), "no TCP port 8443") {
+		t.Fatalf("unknown port: %v", err)
+	}
+	opts.Ports, _ = parseExposePorts([]string{"50051"})
+	if err := checkExistingService(multi, opts); err != nil {
+		t.Fatal(err)
+	}
+	patch := exposePatch(multi, opts)
+	ann := patch["metadata"].(map[string]any)["annotations"].(map[string]any)
+	if ann[k8sapi.ExposeRouteAnnotation] != "https" || ann[k8sapi.RoutePortAnnotation] != "50051" || patch["spec"].(map[string]any)["type"] != "LoadBalancer" {
+		t.Fatalf("patch = %v", patch)
+	}
+	if exposeAllocatesPublicIP(opts, multi) {
+		t.Fatal("a route allocates no address")
+	}
+
+	routed := &k8sapi.Service{Metadata: k8sapi.ObjectMeta{Name: "web", Annotations: map[string]string{k8sapi.ExposeRouteAnnotation: "http"}},
+		Spec: k8sapi.ServiceSpec{Ports: []k8sapi.ServicePort{{Port: 80}}}}
+	if err := checkExistingService(routed, &exposeOptions{Mode: "https"}); err == nil || !strings.Contains(err.Error(), "already exposed as http") {
+		t.Fatalf("mode change: %v", err)
+	}
+
+	lb := &k8sapi.Service{Metadata: k8sapi.ObjectMeta{Name: "db", CreationTimestamp: "2026-10-01T00:00:00Z"}, Spec: k8sapi.ServiceSpec{Type: "LoadBalancer"}}
+	if err := checkExistingService(lb, &exposeOptions{Mode: "tcp", NetworkType: "cloud"}); err == nil || !strings.Contains(err.Error(), "kube-dc ip attach") {
+		t.Fatalf("address type change: %v", err)
+	}
+	tcp := &exposeOptions{Mode: "tcp"}
+	if exposePatch(lb, tcp) != nil || exposeAllocatesPublicIP(tcp, lb) {
+		t.Fatal("an existing LoadBalancer needs no patch and no new address")
+	}
+}
+
+func TestExposeAnnotations(t *testing.T) {
+	tests := []struct {
+		opts exposeOptions
+		want map[string]string
+	}{
+		{exposeOptions{Mode: "https", Host: "www.example.com"}, map[string]string{
+			k8sapi.ExposeRouteAnnotation: "https", k8sapi.RouteHostnameAnnotation: "www.example.com", k8sapi.TLSSecretAnnotation: "web-tls"}},
+		{exposeOptions{Mode: "https", Issuer: "staging"}, map[string]string{k8sapi.ExposeRouteAnnotation: "https", k8sapi.TLSIssuerAnnotation: "staging"}},
+		{exposeOptions{Mode: "tls-passthrough"}, map[string]string{k8sapi.ExposeRouteAnnotation: "tls-passthrough"}},
+		{exposeOptions{Mode: "tcp"}, map[string]string{k8sapi.ExternalNetworkTypeAnnotation: "public"}},
+		{exposeOptions{Mode: "tcp", EIp: "web"}, map[string]string{k8sapi.BindOnEIpAnnotation: "web"}},
+		{exposeOptions{Mode: "tcp", EIp: "default-gw"}, map[string]string{k8sapi.BindOnDefaultGwEIpAnnotation: "true"}},
+	}
+	for _, tt := range tests {
+		got := exposeAnnotations(&tt.opts, "web", 0)
+		if len(got) != len(tt.want) {
+			t.Errorf("%+v: got %v, want %v", tt.opts, got, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("%+v: got %v, want %v", tt.opts, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestCheckIngressDNS(t *testing.T) {
+	defer func(f func(context.Context, string) ([]string, error)) { resolveA = f }(resolveA)
+	records := map[string][]string{
+		"backend.kube-dc.cloud": {"203.0.113.1", "203.0.113.2"},
+		"www.example.com":       {"203.0.113.2"},
+		"old.example.com":       {"198.51.100.7"},
+	}
+	resolveA = func(_ context.Context, name string) ([]string, error) { return records[name], nil }
+
+	ctx := context.Background()
+	if err := checkIngressDNS(ctx, "www.example.com", "backend.kube-dc.cloud"); err != nil {
+		t.Fatalf("pointed at the ingress: %v", err)
+	}
+	err := checkIngressDNS(ctx, "old.example.com", "backend.kube-dc.cloud")
+	if err == nil || !strings.Contains(err.Error(), "resolves to 198.51.100.7, not the platform ingress backend.kube-dc.cloud (203.0.113.1, 203.0.113.2)") {
+		t.Fatalf("mismatch: %v", err)
+	}
+	if err := checkIngressDNS(ctx, "new.example.com", "backend.kube-dc.cloud"); err == nil || !strings.Contains(err.Error(), "does not resolve") {
+		t.Fatalf("NXDOMAIN: %v", err)
+	}
+	if err := checkIngressDNS(ctx, "old.example.com", "backend.unknown.example"); err != nil {
+		t.Fatalf("an unresolvable ingress must only warn: %v", err)
+	}
+}
+
+func TestFindRouteAccepted(t *testing.T) {
+	var list k8sapi.GatewayRouteList
+	if err := json.Unmarshal([]byte(`{"items":[
+		{"metadata":{"name":"other"},"spec":{"rules":[{"backendRefs":[{"name":"api","port":80}]}]}},
+		{"metadata":{"name":"web-https"},"spec":{"hostnames":["web-acme-web.kube-dc.cloud"],"rules":[{"backendRefs":[{"kind":"Service","name":"web","port":80}]}]},
+		 "status":{"parents":[{"controllerName":"gateway.envoyproxy.io/gatewayclass-controller","conditions":[
+			{"type":"Accepted","status":"True"},{"type":"ResolvedRefs","status":"True"}]}]}}]}`), &list); err != nil {
+		t.Fatal(err)
+	}
+	r := findRoute(list.Items, "web")
+	if r == nil || r.Metadata.Name != "web-https" {
+		t.Fatalf("route = %+v", r)
+	}
+	if ok, _ := routeAccepted(r); !ok {
+		t.Fatal("want accepted")
+	}
+	if ok, _ := routeAccepted(&list.Items[0]); ok {
+		t.Fatal("a route without parents is not accepted")
+	}
+	if findRoute(list.Items, "db") != nil {
+		t.Fatal("no route targets db")
+	}
+	if u := exposeURL("http", "web.example.com"); u != "http://web.example.com" {
+		t.Fatalf("url = %s", u)
+	}
+}
@@ -0,0 +1,1091 @@
+// `kube-dc ip` — external addresses of the current Project, on top of
+// kube-dc.com/v1 EIp (an address allocated from the public or cloud
+// pool) and FIp (one-to-one NAT from such an address to a VM
+// interface). An EIp reaches a workload either through a FIp or by
+// being named in a LoadBalancer Service's bind-on-eip annotation; see
+// skills/manage-networking. All calls go straight to the kube-apiserver
+// with the user's JWT.
+//
+// Public addresses count against the Organization's publicIPv4 quota.
+// allocate reads the Organization's usage summary first and refuses
+// when it is exhausted; the summary lags by up to a minute and the EIp
+// controller stays authoritative, so an unreadable summary only warns.
+//
+// Verbs:
+//   list      — EIps and FIps with address and NAT target           (k8s)
+//   allocate  — POST an EIp; waits for its address                  (k8s)
+//   describe  — one address and the workload it NATs to             (k8s)
+//   attach    — FIp to a VM interface, or bind a LoadBalancer Service (k8s)
+//   detach    — delete the FIp / drop the Service annotation        (k8s)
+//   release   — DELETE the EIp, or a FIp with the EIp it owns       (k8s)
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ipPollInterval is how often allocate and attach re-read the address.
+// A var so tests can shorten it.
+var ipPollInterval = 2 * time.Second
+
+var (
+	validIPNetworkTypes = []string{"public", "cloud"}
+	validIPTargetKinds  = []string{"vm", "service"}
+)
+
+func ipCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "ip",
+		Aliases: []string{"ips"},
+		Short:   "Manage the Project's external IP addresses",
+		Long: `Allocate external addresses (EIps) for the current Project, attach them to a
+VM or a LoadBalancer Service, and release them.
+
+  vm        a Floating IP (FIp): one-to-one NAT of every port to one VM
+            interface on the Project VPC
+  service   the Service's service.nlb.kube-dc.com/bind-on-eip annotation:
+            only its declared ports, load-balanced across what it selects
+
+public addresses are internet-routable and count against the Organization's
+public IPv4 quota; cloud addresses are reachable only from the provider's
+configured networks.`,
+	}
+	cmd.AddCommand(ipListCmd())
+	cmd.AddCommand(ipAllocateCmd())
+	cmd.AddCommand(ipDescribeCmd())
+	cmd.AddCommand(ipAttachCmd())
+	cmd.AddCommand(ipDetachCmd())
+	cmd.AddCommand(ipReleaseCmd())
+	return cmd
+}
+
+// -------- views ----------------------------------------------------
+
+// ipView is one address as the CLI presents it. An EIp a FIp allocated
+// for itself is folded into that FIp in list output.
+type ipView struct {
+	Name        string             `json:"name" yaml:"name"`
+	Kind        string             `json:"kind" yaml:"kind"`
+	NetworkType string             `json:"networkType,omitempty" yaml:"networkType,omitempty"`
+	Address     string             `json:"address,omitempty" yaml:"address,omitempty"`
+	Ready       bool               `json:"ready" yaml:"ready"`
+	EIp         string             `json:"eip,omitempty" yaml:"eip,omitempty"`
+	OwnedBy     string             `json:"ownedBy,omitempty" yaml:"ownedBy,omitempty"`
+	Targets     []ipTarget         `json:"targets,omitempty" yaml:"targets,omitempty"`
+	Created     string             `json:"created,omitempty" yaml:"created,omitempty"`
+	Conditions  []k8sapi.Condition `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+}
+
+// ipTarget is what an address is attached to: a VM interface or an
+// internal IP for a FIp; a FIp or a Service for an EIp. Workload says
+// what traffic finally reaches.
+type ipTarget struct {
+	Kind      string `json:"kind" yaml:"kind"`
+	Name      string `json:"name" yaml:"name"`
+	Interface string `json:"interface,omitempty" yaml:"interface,omitempty"`
+	TargetIP  string `json:"targetIP,omitempty" yaml:"targetIP,omitempty"`
+	Workload  string `json:"workload,omitempty" yaml:"workload,omitempty"`
+}
+
+func (t ipTarget) String() string {
+	if t.Kind == "vm" && t.Interface != "" {
+		return "vm/" + t.Name + ":" + t.Interface
+	}
+	return t.Kind + "/" + t.Name
+}
+
+// fipEIp is the EIp a FIp uses: the referenced one, else the one the
+// controller allocated for it.
+func fipEIp(f *k8sapi.FIp) string {
+	return fmtCoalesce(f.Spec.EIp, f.Status.EIp)
+}
+
+// fipOwnsEIp reports whether deleting the FIp also releases its EIp.
+func fipOwnsEIp(f *k8sapi.FIp) bool {
+	return f.Spec.EIp == ""
+}
+
+func fipTarget(f *k8sapi.FIp) ipTarget {
+	if t := f.Spec.VMTarget; t != nil {
+		iface := t.InterfaceName
+		if iface == "" && f.Status.TargetVMInterface != nil {
+			iface = f.Status.TargetVMInterface.InterfaceName
+		}
+		return ipTarget{
+			Kind: "vm", Name: t.VMName, Interface: iface, TargetIP: f.Status.ResolvedTargetIP,
+			Workload: fmt.Sprintf("VM %s, interface %s → %s", t.VMName, fmtCoalesce(iface, "-"), fmtCoalesce(f.Status.ResolvedTargetIP, "unresolved")),
+		}
+	}
+	return ipTarget{Kind: "ip", Name: f.Spec.IPAddress, TargetIP: f.Spec.IPAddress, Workload: f.Spec.IPAddress}
+}
+
+func fipView(f *k8sapi.FIp, netType string) ipView {
+	return ipView{
+		Name: f.Metadata.Name, Kind: "FIp",
+		NetworkType: fmtCoalesce(f.Spec.ExternalNetworkType, netType),
+		Address:     f.Status.ExternalIP, Ready: f.Status.Ready,
+		EIp:     fipEIp(f),
+		Targets: []ipTarget{fipTarget(f)},
+		Created: f.Metadata.CreationTimestamp, Conditions: f.Status.Conditions,
+	}
+}
+
+// eipView includes every FIp using the EIp and every Service bound to
+// it.
+func eipView(e *k8sapi.EIp, fips []k8sapi.FIp, svcs []k8sapi.Service) ipView {
+	v := ipView{
+		Name: e.Metadata.Name, Kind: "EIp",
+		NetworkType: e.Spec.ExternalNetworkType,
+		Address:     e.Status.IPAddress, Ready: e.Status.Ready,
+		Created: e.Metadata.CreationTimestamp, Conditions: e.Status.Conditions,
+	}
+	for i := range fips {
+		f := &fips[i]
+		if fipEIp(f) != e.Metadata.Name {
+			continue
+		}
+		if fipOwnsEIp(f) {
+			v.OwnedBy = "fip/" + f.Metadata.Name
+		}
+		v.Targets = append(v.Targets, ipTarget{
+			Kind: "fip", Name: f.Metadata.Name, TargetIP: f.Status.ResolvedTargetIP, Workload: fipTarget(f).Workload,
+		})
+	}
+	if v.OwnedBy == "" && e.Spec.ChildRef != "" {
+		v.OwnedBy = e.Spec.ChildRef
+	}
+	gateway := e.Metadata.Name == k8sapi.DefaultGwEIp
+	if gateway {
+		v.OwnedBy = "the Project gateway"
+	}
+	for _, s := range svcs {
+		a := s.Metadata.Annotations
+		if a[k8sapi.BindOnEIpAnnotation] == e.Metadata.Name || (gateway && a[k8sapi.BindOnDefaultGwEIpAnnotation] == "true") {
+			v.Targets = append(v.Targets, ipTarget{Kind: "service", Name: s.Metadata.Name})
+		}
+	}
+	return v
+}
+
+// buildIPViews lists FIps and EIps by name. An EIp owned by a FIp in
+// the list is left out: the FIp row carries its address.
+func buildIPViews(eips []k8sapi.EIp, fips []k8sapi.FIp, svcs []k8sapi.Service) []ipView {
+	types := map[string]string{}
+	for _, e := range eips {
+		types[e.Metadata.Name] = e.Spec.ExternalNetworkType
+	}
+	var views []ipView
+	owned := map[string]bool{}
+	for i := range fips {
+		f := &fips[i]
+		if fipOwnsEIp(f) && f.Status.EIp != "" {
+			owned[f.Status.EIp] = true
+		}
+		views = append(views, fipView(f, types[fipEIp(f)]))
+	}
+	for i := range eips {
+		if owned[eips[i].Metadata.Name] {
+			continue
+		}
+		views = append(views, eipView(&eips[i], fips, svcs))
+	}
+	sort.Slice(views, func(i, j int) bool {
+		if views[i].Name != views[j].Name {
+			return views[i].Name < views[j].Name
+		}
+		return views[i].Kind < views[j].Kind
+	})
+	return views
+}
+
+// ipInventory is everything the address views are computed from.
+type ipInventory struct {
+	EIps     []k8sapi.EIp
+	FIps     []k8sapi.FIp
+	Services []k8sapi.Service
+}
+
+func loadIPInventory(ctx context.Context, cli *k8sapi.Client, ns string) (*ipInventory, error) {
+	eips, err := cli.ListEIps(ctx, ns)
+	if err != nil {
+		return nil, fmt.Errorf("list EIps: %w", err)
+	}
+	fips, err := cli.ListFIps(ctx, ns)
+	if err != nil {
+		return nil, fmt.Errorf("list FIps: %w", err)
+	}
+	svcs, err := cli.ListServices(ctx, ns)
+	if err != nil {
+		return nil, fmt.Errorf("list Services: %w", err)
+	}
+	return &ipInventory{EIps: eips.Items, FIps: fips.Items, Services: svcs.Items}, nil
+}
+
+func (inv *ipInventory) eip(name string) *k8sapi.EIp {
+	for i := range inv.EIps {
+		if inv.EIps[i].Metadata.Name == name {
+			return &inv.EIps[i]
+		}
+	}
+	return nil
+}
+
+func (inv *ipInventory) fip(name string) *k8sapi.FIp {
+	for i := range inv.FIps {
+		if inv.FIps[i].Metadata.Name == name {
+			return &inv.FIps[i]
+		}
+	}
+	return nil
+}
+
+func (inv *ipInventory) service(name string) *k8sapi.Service {
+	for i := range inv.Services {
+		if inv.Services[i].Metadata.Name == name {
+			return &inv.Services[i]
+		}
+	}
+	return nil
+}
+
+// view resolves a name the way every verb does: an EIp first, then a
+// FIp.
+func (inv *ipInventory) view(ns, name string) (ipView, error) {
+	if e := inv.eip(name); e != nil {
+		return eipView(e, inv.FIps, inv.Services), nil
+	}
+	if f := inv.fip(name); f != nil {
+		var netType string
+		if e := inv.eip(fipEIp(f)); e != nil {
+			netType = e.Spec.ExternalNetworkType
+		}
+		return fipView(f, netType), nil
+	}
+	return ipView{}, fmt.Errorf("no EIp or FIp named %s in %s", name, ns)
+}
+
+// -------- quota ----------------------------------------------------
+
+// ipQuota is the Organization's public IPv4 usage as last summarised.
+type ipQuota struct {
+	Org         string
+	Used, Hard  int64
+	LastUpdated string
+}
+
+// readPublicIPQuota returns nil with no error when the usage cannot be
+// known from here: an admin context, or an Organization status that
+// does not report it.
+func readPublicIPQuota(ctx context.Context, cli *k8sapi.Client) (*ipQuota, error) {
+	org := readCurrentRealm()
+	if org == "" || org == adminRealm {
+		return nil, nil
+	}
+	o, err := cli.GetOrganization(ctx, org)
+	if err != nil {
+		return nil, fmt.Errorf("read Organization %s quota: %w", org, err)
+	}
+	if o.Status.QuotaUsage == nil {
+		return nil, nil
+	}
+	return parsePublicIPQuota(org, o.Status.QuotaUsage.PublicIPv4, o.Status.QuotaUsage.LastUpdated), nil
+}
+
+// parsePublicIPQuota turns the summary pair into counts; an empty or
+// unparseable value means unknown, not zero.
+func parsePublicIPQuota(org string, p *k8sapi.QuotaPair, lastUpdated string) *ipQuota {
+	if p == nil || p.Used == "" || p.Hard == "" {
+		return nil
+	}
+	used, err := resource.ParseQuantity(p.Used)
+	if err != nil {
+		return nil
+	}
+	hard, err := resource.ParseQuantity(p.Hard)
+	if err != nil {
+		return nil
+	}
+	return &ipQuota{Org: org, Used: used.Value(), Hard: hard.Value(), LastUpdated: lastUpdated}
+}
+
+// check fails when n more public addresses would exceed the quota.
+func (q *ipQuota) check(n int64) error {
+	if q == nil || q.Used+n <= q.Hard {
+		return nil
+	}
+	msg := fmt.Sprintf("Organization %s has used %d of %d public IPv4 addresses", q.Org, q.Used, q.Hard)
+	if q.LastUpdated != "" {
+		msg += " (as of " + q.LastUpdated + ")"
+	}
+	return fmt.Errorf("%s; release one with `kube-dc ip release`, ask an Organization administrator for more, or pass --ignore-quota if an address was just released", msg)
+}
+
+func (q *ipQuota) String() string {
+	return fmt.Sprintf("Public IPv4 (Organization %s): %d of %d used", q.Org, q.Used, q.Hard)
+}
+
+// -------- list -----------------------------------------------------
+
+func ipListCmd() *cobra.Command {
+	var namespace, outFlag string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List EIps and FIps in the current Project",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			inv, err := loadIPInventory(ctx, cli, scope.Namespace)
+			if err != nil {
+				return err
+			}
+			views := buildIPViews(inv.EIps, inv.FIps, inv.Services)
+			if out != outTable {
+				return printSerialized(out, views)
+			}
+			if err := printIPTable(scope.Namespace, views); err != nil {
+				return err
+			}
+			// Usage is context, not the point of the listing; a failure
+			// here only costs the footer.
+			if q, err := readPublicIPQuota(ctx, cli); err == nil && q != nil {
+				fmt.Println()
+				fmt.Println(q)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+func printIPTable(ns string, views []ipView) error {
+	if len(views) == 0 {
+		fmt.Println("No external IPs in", ns)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tKIND\tTYPE\tADDRESS\tREADY\tATTACHED TO\tAGE")
+	for _, v := range views {
+		var targets []string
+		for _, t := range v.Targets {
+			targets = append(targets, t.String())
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%v\t%s\t%s\n",
+			v.Name, v.Kind,
+			fmtCoalesce(v.NetworkType, "-"),
+			fmtCoalesce(v.Address, "-"),
+			v.Ready,
+			fmtCoalesce(strings.Join(targets, ", "), "-"),
+			formatAge(v.Created),
+		)
+	}
+	return w.Flush()
+}
+
+// -------- allocate -------------------------------------------------
+
+func ipAllocateCmd() *cobra.Command {
+	var namespace, netType string
+	var noWait, ignoreQuota bool
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "allocate <name>",
+		Short: "Allocate an external IP (EIp) from the public or cloud pool",
+		Long: `Allocate an EIp and wait for its address. The network type is immutable once
+allocated.
+
+A public address counts against the Organization's public IPv4 quota; allocate
+refuses when the Organization's usage summary shows it exhausted. The summary
+is refreshed about once a minute, so --ignore-quota lets an allocation through
+right after another address was released. The EIp controller enforces the
+quota either way.`,
+		Example: `  kube-dc ip allocate web
+  kube-dc ip allocate partner-link --type cloud`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if !projectNameRE.MatchString(name) {
+				return fmt.Errorf("invalid name %q: must be a DNS label (lowercase letters, digits, '-')", name)
+			}
+			if !containsString(validIPNetworkTypes, netType) {
+				return fmt.Errorf("invalid --type %q (want %s)", netType, strings.Join(validIPNetworkTypes, "|"))
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			if netType == "public" && !ignoreQuota {
+				q, err := readPublicIPQuota(ctx, cli)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: %v; the EIp controller still enforces the quota\n", err)
+				}
+				if err := q.check(1); err != nil {
+					return err
+				}
+			}
+			eip, err := cli.CreateEIp(ctx, &k8sapi.EIp{
+				Metadata: k8sapi.ObjectMeta{Name: name, Namespace: scope.Namespace},
+				Spec:     k8sapi.EIpSpec{ExternalNetworkType: netType},
+			})
+			if err != nil {
+				return err
+			}
+			if noWait {
+				fmt.Printf("Requested %s EIp %s\n", netType, eip.Metadata.Name)
+				return nil
+			}
+			addr, err := waitForAddress(context.Background(), "EIp "+name, timeout, func(ctx context.Context) (bool, string, []k8sapi.Condition, error) {
+				e, err := cli.GetEIp(ctx, scope.Namespace, name)
+				if err != nil {
+					return false, "", nil, err
+				}
+				return e.Status.Ready, e.Status.IPAddress, e.Status.Conditions, nil
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Allocated %s EIp %s: %s\n", netType, name, addr)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVar(&netType, "type", "public", "Address pool: public|cloud")
+	cmd.Flags().BoolVar(&noWait, "no-wait", false, "Return once the EIp is created, without waiting for its address")
+	cmd.Flags().BoolVar(&ignoreQuota, "ignore-quota", false, "Allocate even when the Organization's usage summary shows the public IPv4 quota exhausted")
+	cmd.Flags().DurationVar(&timeout, "timeout", 2*time.Minute, "How long to wait for the address")
+	return cmd
+}
+
+// waitForAddress polls get until the object is ready with an address.
+// On timeout the error carries the conditions that are not True, which
+// is where a quota or pool refusal shows up.
+func waitForAddress(ctx context.Context, what string, timeout time.Duration, get func(context.Context) (bool, string, []k8sapi.Condition, error)) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var conds []k8sapi.Condition
+	var lastErr error
+	for {
+		reqCtx, reqCancel := context.WithTimeout(ctx, 30*time.Second)
+		ready, addr, c, err := get(reqCtx)
+		reqCancel()
+		switch {
+		case err == nil:
+			conds, lastErr = c, nil
+			if ready && addr != "" {
+				return addr, nil
+			}
+		case k8sapi.IsNotFound(err):
+			return "", fmt.Errorf("%s not found", what)
+		default:
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			msg := fmt.Sprintf("timed out after %s waiting for %s to be ready", timeout, what)
+			if pending := notTrueConditions(conds); pending != "" {
+				msg += "; pending: " + pending
+			}
+			if lastErr != nil {
+				msg += fmt.Sprintf(" (last error: %v)", lastErr)
+			}
+			return "", fmt.Errorf("%s", msg)
+		case <-time.After(ipPollInterval):
+		}
+	}
+}
+
+// -------- describe -------------------------------------------------
+
+func ipDescribeCmd() *cobra.Command {
+	var namespace, outFlag string
+	cmd := &cobra.Command{
+		Use:     "describe <name>",
+		Aliases: []string{"get", "show"},
+		Short:   "Show an address and the workload it NATs to",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			inv, err := loadIPInventory(ctx, cli, scope.Namespace)
+			if err != nil {
+				return err
+			}
+			v, err := inv.view(scope.Namespace, args[0])
+			if err != nil {
+				return err
+			}
+			for i, t := range v.Targets {
+				if t.Kind != "service" {
+					continue
+				}
+				if svc := inv.service(t.Name); svc != nil {
+					v.Targets[i].Workload = serviceWorkload(ctx, cli, svc)
+				}
+			}
+			if out != outTable {
+				return printSerialized(out, v)
+			}
+			printIPDetail(&v)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+// serviceWorkload describes where a bound Service sends traffic: its
+// ports, then the VM or Pods its selector matches.
+func serviceWorkload(ctx context.Context, cli *k8sapi.Client, svc *k8sapi.Service) string {
+	var ports []string
+	for _, p := range svc.Spec.Ports {
+		ports = append(ports, fmt.Sprintf("%d/%s", p.Port, fmtCoalesce(p.Protocol, "TCP")))
+	}
+	head := fmtCoalesce(strings.Join(ports, ", "), "no ports")
+	if svc.Spec.Type != "LoadBalancer" {
+		head = fmt.Sprintf("type %s, not exposed on the EIp", fmtCoalesce(svc.Spec.Type, "ClusterIP"))
+	}
+	return head + " → " + selectorWorkload(ctx, cli, svc.Metadata.Namespace, svc.Spec.Selector)
+}
+
+func selectorWorkload(ctx context.Context, cli *k8sapi.Client, ns string, selector map[string]string) string {
+	if vm := selector[vmNameLabel]; vm != "" {
+		return "VM " + vm
+	}
+	if len(selector) == 0 {
+		return "no selector (endpoints managed by hand)"
+	}
+	keys := make([]string, 0, len(selector))
+	for k := range selector {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		parts = append(parts, k+"="+selector[k])
+	}
+	sel := strings.Join(parts, ",")
+	pods, err := cli.ListPods(ctx, ns, sel)
+	if err != nil {
+		return "Pods matching " + sel
+	}
+	var names []string
+	for i := range pods.Items {
+		if pods.Items[i].Ready() {
+			names = append(names, pods.Items[i].Metadata.Name)
+		}
+	}
+	if len(names) == 0 {
+		return "no ready Pods match " + sel
+	}
+	sort.Strings(names)
+	return "Pods " + strings.Join(names, ", ")
+}
+
+func printIPDetail(v *ipView) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", v.Name)
+	fmt.Fprintf(w, "Kind:\t%s\n", v.Kind)
+	fmt.Fprintf(w, "Type:\t%s\n", fmtCoalesce(v.NetworkType, "-"))
+	fmt.Fprintf(w, "Address:\t%s\n", fmtCoalesce(v.Address, "-"))
+	fmt.Fprintf(w, "Ready:\t%v\n", v.Ready)
+	if v.EIp != "" {
+		fmt.Fprintf(w, "EIp:\t%s\n", v.EIp)
+	}
+	if v.OwnedBy != "" {
+		fmt.Fprintf(w, "Owned by:\t%s (released with it)\n", v.OwnedBy)
+	}
+	fmt.Fprintf(w, "Created:\t%s\n", fmtCoalesce(v.Created, "-"))
+	if len(v.Targets) == 0 {
+		fmt.Fprintf(w, "NAT target:\tnone (allocated, not attached)\n")
+	}
+	_ = w.Flush()
+	if len(v.Targets) > 0 {
+		fmt.Println("\nNAT targets:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, t := range v.Targets {
+			fmt.Fprintf(w, "  %s\t%s\n", t, fmtCoalesce(t.Workload, "-"))
+		}
+		_ = w.Flush()
+	}
+	if len(v.Conditions) > 0 {
+		fmt.Println("\nConditions:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  TYPE\tSTATUS\tREASON\tMESSAGE")
+		for _, c := range v.Conditions {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", c.Type, c.Status, fmtCoalesce(c.Reason, "-"), truncCLI(c.Message, 80))
+		}
+		_ = w.Flush()
+	}
+}
+
+// -------- attach ---------------------------------------------------
+
+// onProjectVPC reports whether a VM network entry is the Project VPC:
+// the pod network, or the Project's default Multus network.
+func onProjectVPC(network map[string]any, ns string) bool {
+	if _, ok := network["pod"]; ok {
+		return true
+	}
+	multus, _ := network["multus"].(map[string]any)
+	name, _ := multus["networkName"].(string)
+	return name == ns+"/default" || name == "default"
+}
+
+func networkLabel(network map[string]any) string {
+	if _, ok := network["pod"]; ok {
+		return "pod network"
+	}
+	multus, _ := network["multus"].(map[string]any)
+	if name, _ := multus["networkName"].(string); name != "" {
+		return name
+	}
+	return "unknown network"
+}
+
+// vpcInterface picks the VM interface a FIp NATs to: want when given,
+// else the first one on the Project VPC. A FIp to any other interface
+// would never resolve, so this fails before anything is created.
+func vpcInterface(vm *k8sapi.VirtualMachine, ns, want string) (string, error) {
+	networks, _ := vm.Spec.Template.Spec["networks"].([]any)
+	if len(networks) == 0 {
+		return "", fmt.Errorf("VM %s has no network interfaces; a Floating IP needs one on the Project VPC (%s/default)", vm.Metadata.Name, ns)
+	}
+	var seen []string
+	for _, n := range networks {
+		network, _ := n.(map[string]any)
+		name, _ := network["name"].(string)
+		if want != "" && name == want {
+			if !onProjectVPC(network, ns) {
+				return "", fmt.Errorf("interface %s of VM %s is on %s, not the Project VPC (%s/default); a Floating IP can only NAT to a Project VPC interface",
+					name, vm.Metadata.Name, networkLabel(network), ns)
+			}
+			return name, nil
+		}
+		if want == "" && onProjectVPC(network, ns) {
+			return name, nil
+		}
+		seen = append(seen, fmt.Sprintf("%s (%s)", name, networkLabel(network)))
+	}
+	if want != "" {
+		return "", fmt.Errorf("VM %s has no interface %q; it has %s", vm.Metadata.Name, want, strings.Join(seen, ", "))
+	}
+	return "", fmt.Errorf("VM %s has no interface on the Project VPC (%s/default); it has %s", vm.Metadata.Name, ns, strings.Join(seen, ", "))
+}
+
+func ipAttachCmd() *cobra.Command {
+	var namespace, iface, fipName string
+	var noWait bool
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "attach <ip> vm|service <name>",
+		Short: "Attach an EIp to a VM (Floating IP) or a LoadBalancer Service",
+		Long: `Attach an allocated EIp to a workload.
+
+vm creates a Floating IP (FIp) NATing every port of the address to one VM
+interface; the interface has to be on the Project VPC (--interface, default:
+the first such interface). The FIp is named after the EIp unless --name says
+otherwise, and attach waits until it resolves the VM's address when the VM
+is running.
+
+service sets service.nlb.kube-dc.com/bind-on-eip on a LoadBalancer Service;
+only its declared ports are exposed. Several Services can share one EIp on
+different ports, but an EIp behind a Floating IP cannot also serve Services.`,
+		Example: `  kube-dc ip attach web vm web-1
+  kube-dc ip attach web vm web-1 --interface default
+  kube-dc ip attach web service web-lb`,
+		Args: cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ip, kind, target := args[0], args[1], args[2]
+			if !containsString(validIPTargetKinds, kind) {
+				return fmt.Errorf("invalid target kind %q (want %s)", kind, strings.Join(validIPTargetKinds, "|"))
+			}
+			if kind == "service" && (iface != "" || fipName != "") {
+				return fmt.Errorf("--interface and --name apply to vm targets only")
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			inv, err := loadIPInventory(ctx, cli, scope.Namespace)
+			if err != nil {
+				return err
+			}
+			eip := inv.eip(ip)
+			if eip == nil {
+				return fmt.Errorf("no EIp named %s in %s; allocate one with `kube-dc ip allocate %s`", ip, scope.Namespace, ip)
+			}
+			view := eipView(eip, inv.FIps, inv.Services)
+			if view.OwnedBy != "" {
+				msg := fmt.Sprintf("EIp %s belongs to %s and cannot be attached", ip, view.OwnedBy)
+				if ip == k8sapi.DefaultGwEIp {
+					msg += fmt.Sprintf("; expose a LoadBalancer Service on it with the %s: \"true\" annotation", k8sapi.BindOnDefaultGwEIpAnnotation)
+				}
+				return fmt.Errorf("%s", msg)
+			}
+			if kind == "service" {
+				return attachService(ctx, cli, inv, scope.Namespace, view, target)
+			}
+			if len(view.Targets) > 0 {
+				return fmt.Errorf("EIp %s is already attached to %s; a Floating IP needs an address of its own", ip, view.Targets[0])
+			}
+			vm, err := cli.GetVirtualMachine(ctx, scope.Namespace, target)
+			if err != nil {
+				if k8sapi.IsNotFound(err) {
+					return fmt.Errorf("VM %s not found in %s", target, scope.Namespace)
+				}
+				return err
+			}
+			name, err := vpcInterface(vm, scope.Namespace, iface)
+			if err != nil {
+				return err
+			}
+			for i := range inv.FIps {
+				f := &inv.FIps[i]
+				if t := fipTarget(f); t.Kind == "vm" && t.Name == target && t.Interface == name {
+					return fmt.Errorf("interface %s of VM %s already has Floating IP %s (%s)", name, target, f.Metadata.Name, fmtCoalesce(f.Status.ExternalIP, "pending"))
+				}
+			}
+			if w := fipRoutingConflict(inv, eip, target); w != "" {
+				fmt.Fprintln(os.Stderr, "Warning: "+w)
+			}
+			fip, err := cli.CreateFIp(ctx, &k8sapi.FIp{
+				Metadata: k8sapi.ObjectMeta{Name: fmtCoalesce(fipName, ip), Namespace: scope.Namespace},
+				Spec: k8sapi.FIpSpec{
+					EIp:      ip,
+					VMTarget: &k8sapi.FIpVMTarget{VMName: target, InterfaceName: name},
+				},
+			})
+			if err != nil {
+				return err
+			}
+			if noWait {
+				fmt.Printf("Created Floating IP %s: %s → VM %s (%s)\n", fip.Metadata.Name, fmtCoalesce(eip.Status.IPAddress, ip), target, name)
+				return nil
+			}
+			if vm.Status.PrintableStatus != string(vmWantRunning) {
+				fmt.Printf("Created Floating IP %s: %s → VM %s (%s); it resolves once the VM is running (status %s)\n",
+					fip.Metadata.Name, fmtCoalesce(eip.Status.IPAddress, ip), target, name, fmtCoalesce(vm.Status.PrintableStatus, "unknown"))
+				return nil
+			}
+			var internal string
+			addr, err := waitForAddress(context.Background(), "Floating IP "+fip.Metadata.Name, timeout, func(ctx context.Context) (bool, string, []k8sapi.Condition, error) {
+				f, err := cli.GetFIp(ctx, scope.Namespace, fip.Metadata.Name)
+				if err != nil {
+					return false, "", nil, err
+				}
+				internal = f.Status.ResolvedTargetIP
+				return f.Status.Ready && internal != "", f.Status.ExternalIP, f.Status.Conditions, nil
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Attached %s to VM %s (%s, %s) as Floating IP %s\n", addr, target, name, internal, fip.Metadata.Name)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVar(&iface, "interface", "", "VM interface to NAT to (default: the first one on the Project VPC)")
+	cmd.Flags().StringVar(&fipName, "name", "", "Name of the Floating IP (default: the EIp's name)")
+	cmd.Flags().BoolVar(&noWait, "no-wait", false, "Return once the Floating IP is created")
+	cmd.Flags().DurationVar(&timeout, "timeout", 2*time.Minute, "How long to wait for the Floating IP")
+	return cmd
+}
+
+// fipRoutingConflict warns about the documented limitation: a public
+// FIp policy-routes all of the VM's outbound traffic to the public
+// gateway, which breaks cloud LoadBalancer Services in front of it.
+func fipRoutingConflict(inv *ipInventory, eip *k8sapi.EIp, vm string) string {
+	if eip.Spec.ExternalNetworkType != "public" {
+		return ""
+	}
+	for _, s := range inv.Services {
+		if s.Spec.Type != "LoadBalancer" || s.Spec.Selector[vmNameLabel] != vm {
+			continue
+		}
+		if bound := inv.eip(s.Metadata.Annotations[k8sapi.BindOnEIpAnnotation]); bound != nil && bound.Spec.ExternalNetworkType == "cloud" {
+			return fmt.Sprintf("VM %s is behind cloud LoadBalancer Service %s (EIp %s); a public Floating IP reroutes the VM's replies and breaks that Service", vm, s.Metadata.Name, bound.Metadata.Name)
+		}
+	}
+	return ""
+}
+
+func attachService(ctx context.Context, cli *k8sapi.Client, inv *ipInventory, ns string, view ipView, name string) error {
+	svc := inv.service(name)
+	if svc == nil {
+		return fmt.Errorf("Service %s not found in %s", name, ns)
+	}
+	if svc.Spec.Type != "LoadBalancer" {
+		return fmt.Errorf("Service %s is type %s; only a LoadBalancer Service can be bound to an EIp", name, fmtCoalesce(svc.Spec.Type, "ClusterIP"))
+	}
+	for _, t := range view.Targets {
+		if t.Kind == "fip" {
+			return fmt.Errorf("EIp %s is used by Floating IP %s, which already NATs every port; allocate another EIp for the Service", view.Name, t.Name)
+		}
+	}
+	switch cur := svc.Metadata.Annotations[k8sapi.BindOnEIpAnnotation]; cur {
+	case view.Name:
+		fmt.Printf("Service %s is already bound to EIp %s\n", name, view.Name)
+		return nil
+	case "":
+	default:
+		return fmt.Errorf("Service %s is bound to EIp %s; detach it first with `kube-dc ip detach %s service %s`", name, cur, cur, name)
+	}
+	patch := map[string]any{"metadata": map[string]any{"annotations": map[string]any{k8sapi.BindOnEIpAnnotation: view.Name}}}
+	if _, err := cli.PatchService(ctx, ns, name, patch); err != nil {
+		return err
+	}
+	fmt.Printf("Bound Service %s to EIp %s (%s)\n", name, view.Name, fmtCoalesce(view.Address, "address pending"))
+	return nil
+}
+
+// -------- detach ---------------------------------------------------
+
+func ipDetachCmd() *cobra.Command {
+	var namespace string
+	cmd := &cobra.Command{
+		Use:   "detach <ip> [vm|service <name>]",
+		Short: "Detach an EIp from its VM or Services, keeping the address",
+		Long: `Detach an EIp from what it is attached to: delete the Floating IP that uses it,
+or drop the bind-on-eip annotation from a Service. Without a target every
+attachment is removed. The EIp stays allocated for a later attach.
+
+A Floating IP created with its own address owns that EIp; detaching it would
+release the address, so use release for it instead.`,
+		Example: `  kube-dc ip detach web
+  kube-dc ip detach web service web-lb`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 && len(args) != 3 {
+				return fmt.Errorf("want <ip> or <ip> vm|service <name>, got %d arguments", len(args))
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ip := args[0]
+			var kind, target string
+			if len(args) == 3 {
+				kind, target = args[1], args[2]
+				if !containsString(validIPTargetKinds, kind) {
+					return fmt.Errorf("invalid target kind %q (want %s)", kind, strings.Join(validIPTargetKinds, "|"))
+				}
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			inv, err := loadIPInventory(ctx, cli, scope.Namespace)
+			if err != nil {
+				return err
+			}
+			var steps []ipTarget
+			if f := inv.fip(ip); inv.eip(ip) == nil && f != nil && !fipOwnsEIp(f) {
+				// Named by its Floating IP: remove just that one.
+				if kind != "" {
+					return fmt.Errorf("%s is a Floating IP; pass its EIp %s to detach by target", ip, f.Spec.EIp)
+				}
+				steps = []ipTarget{{Kind: "fip", Name: f.Metadata.Name, Workload: fipTarget(f).Workload}}
+				ip = f.Spec.EIp
+			} else if steps, err = planDetach(inv, scope.Namespace, ip, kind, target); err != nil {
+				return err
+			}
+			for _, s := range steps {
+				switch s.Kind {
+				case "fip":
+					if err := cli.DeleteFIp(ctx, scope.Namespace, s.Name); err != nil && !k8sapi.IsNotFound(err) {
+						return fmt.Errorf("delete Floating IP %s: %w", s.Name, err)
+					}
+					fmt.Printf("Deleted Floating IP %s (%s)\n", s.Name, s.Workload)
+				case "service":
+					patch := map[string]any{"metadata": map[string]any{"annotations": map[string]any{k8sapi.BindOnEIpAnnotation: nil}}}
+					if _, err := cli.PatchService(ctx, scope.Namespace, s.Name, patch); err != nil {
+						return fmt.Errorf("unbind Service %s: %w", s.Name, err)
+					}
+					fmt.Printf("Unbound Service %s\n", s.Name)
+				}
+			}
+			fmt.Printf("EIp %s stays allocated; release it with `kube-dc ip release %s --yes`\n", ip, ip)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	return cmd
+}
+
+// planDetach returns the attachments of EIp ip to remove: all of them,
+// or those of the given vm or service. kind "vm" with an empty target
+// matches every Floating IP.
+func planDetach(inv *ipInventory, ns, ip, kind, target string) ([]ipTarget, error) {
+	e := inv.eip(ip)
+	if e == nil {
+		if f := inv.fip(ip); f != nil {
+			return nil, fmt.Errorf("Floating IP %s owns its address; detaching would release it, use `kube-dc ip release %s --yes`", ip, ip)
+		}
+		return nil, fmt.Errorf("no EIp named %s in %s", ip, ns)
+	}
+	view := eipView(e, inv.FIps, inv.Services)
+	if view.OwnedBy != "" {
+		return nil, fmt.Errorf("EIp %s belongs to %s; release that instead", ip, view.OwnedBy)
+	}
+	var steps []ipTarget
+	for _, t := range view.Targets {
+		switch {
+		case kind == "":
+		case kind == "service" && t.Kind == "service" && t.Name == target:
+		case kind == "vm" && t.Kind == "fip" && (target == "" || vmOfFIp(inv.fip(t.Name)) == target):
+		default:
+			continue
+		}
+		steps = append(steps, t)
+	}
+	if len(steps) == 0 {
+		if kind != "" && target != "" {
+			return nil, fmt.Errorf("EIp %s is not attached to %s %s", ip, kind, target)
+		}
+		return nil, fmt.Errorf("EIp %s is not attached to anything", ip)
+	}
+	return steps, nil
+}
+
+func vmOfFIp(f *k8sapi.FIp) string {
+	if f == nil || f.Spec.VMTarget == nil {
+		return ""
+	}
+	return f.Spec.VMTarget.VMName
+}
+
+// -------- release --------------------------------------------------
+
+func ipReleaseCmd() *cobra.Command {
+	var namespace string
+	var yes bool
+	cmd := &cobra.Command{
+		Use:     "release <name>",
+		Aliases: []string{"delete"},
+		Short:   "Release an EIp, or a Floating IP together with the EIp it owns",
+		Long: `Return an address to its pool. An EIp has to be detached first. A Floating IP
+created with its own address is deleted together with that EIp; one that
+uses a separately allocated EIp is deleted and the EIp stays.
+
+A released address is not reserved: allocating again may yield a different
+one, so update DNS and allowlists.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			inv, err := loadIPInventory(ctx, cli, scope.Namespace)
+			if err != nil {
+				return err
+			}
+			v, err := inv.view(scope.Namespace, name)
+			if err != nil {
+				return err
+			}
+			if v.Kind == "EIp" {
+				if v.OwnedBy != "" {
+					return fmt.Errorf("EIp %s belongs to %s; release that instead", name, v.OwnedBy)
+				}
+				if len(v.Targets) > 0 {
+					var names []string
+					for _, t := range v.Targets {
+						names = append(names, t.String())
+					}
+					return fmt.Errorf("EIp %s is still attached to %s; detach it first with `kube-dc ip detach %s`", name, strings.Join(names, ", "), name)
+				}
+			}
+			if !yes {
+				fmt.Fprintf(os.Stderr, "Release %s %s (%s)? Re-run with --yes to confirm.\n", v.Kind, name, fmtCoalesce(v.Address, "no address yet"))
+				return fmt.Errorf("not confirmed")
+			}
+			if v.Kind == "EIp" {
+				if err := cli.DeleteEIp(ctx, scope.Namespace, name); err != nil {
+					return err
+				}
+				fmt.Printf("Released EIp %s (%s)\n", name, fmtCoalesce(v.Address, "-"))
+				return nil
+			}
+			if err := cli.DeleteFIp(ctx, scope.Namespace, name); err != nil {
+				return err
+			}
+			if fipOwnsEIp(inv.fip(name)) {
+				fmt.Printf("Released Floating IP %s and its address %s\n", name, fmtCoalesce(v.Address, "-"))
+			} else {
+				fmt.Printf("Deleted Floating IP %s; EIp %s stays allocated\n", name, v.EIp)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().BoolVar(&yes, "yes", false, "Confirm the release")
+	return cmd
+}
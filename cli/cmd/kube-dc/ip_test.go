@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+)
+
+func testIPInventory() *ipInventory {
+	meta := func(name string) k8sapi.ObjectMeta { return k8sapi.ObjectMeta{Name: name, Namespace: "acme-web"} }
+	return &ipInventory{
+		EIps: []k8sapi.EIp{
+			{Metadata: meta("web"), Spec: k8sapi.EIpSpec{ExternalNetworkType: "public"}, Status: k8sapi.EIpStatus{Ready: true, IPAddress: "203.0.113.10"}},
+			{Metadata: meta("lb"), Spec: k8sapi.EIpSpec{ExternalNetworkType: "cloud"}, Status: k8sapi.EIpStatus{Ready: true, IPAddress: "100.65.0.7"}},
+			{Metadata: meta("spare"), Spec: k8sapi.EIpSpec{ExternalNetworkType: "public"}, Status: k8sapi.EIpStatus{Ready: true, IPAddress: "203.0.113.11"}},
+			{Metadata: meta("fip-db-1-eip"), Spec: k8sapi.EIpSpec{ChildRef: "fip/db-1"}, Status: k8sapi.EIpStatus{Ready: true, IPAddress: "203.0.113.12"}},
+			{Metadata: meta("default-gw"), Spec: k8sapi.EIpSpec{ExternalNetworkType: "cloud"}, Status: k8sapi.EIpStatus{Ready: true, IPAddress: "100.65.0.2"}},
+		},
+		FIps: []k8sapi.FIp{
+			{Metadata: meta("web"), Spec: k8sapi.FIpSpec{EIp: "web", VMTarget: &k8sapi.FIpVMTarget{VMName: "web-1", InterfaceName: "default"}},
+				Status: k8sapi.FIpStatus{Ready: true, EIp: "web", ExternalIP: "203.0.113.10", ResolvedTargetIP: "10.0.0.12"}},
+			{Metadata: meta("db-1"), Spec: k8sapi.FIpSpec{ExternalNetworkType: "public", VMTarget: &k8sapi.FIpVMTarget{VMName: "db-1"}},
+				Status: k8sapi.FIpStatus{Ready: true, EIp: "fip-db-1-eip", ExternalIP: "203.0.113.12", ResolvedTargetIP: "10.0.0.20",
+					TargetVMInterface: &k8sapi.FIpVMTarget{VMName: "db-1", InterfaceName: "default"}}},
+		},
+		Services: []k8sapi.Service{
+			{Metadata: k8sapi.ObjectMeta{Name: "api", Annotations: map[string]string{k8sapi.BindOnEIpAnnotation: "lb"}}, Spec: k8sapi.ServiceSpec{Type: "LoadBalancer"}},
+			{Metadata: k8sapi.ObjectMeta{Name: "grpc", Annotations: map[string]string{k8sapi.BindOnEIpAnnotation: "lb"}},
+				Spec: k8sapi.ServiceSpec{Type: "LoadBalancer", Selector: map[string]string{vmNameLabel: "app-1"}}},
+			{Metadata: k8sapi.ObjectMeta{Name: "shared", Annotations: map[string]string{k8sapi.BindOnDefaultGwEIpAnnotation: "true"}}, Spec: k8sapi.ServiceSpec{Type: "LoadBalancer"}},
+			{Metadata: k8sapi.ObjectMeta{Name: "internal"}, Spec: k8sapi.ServiceSpec{Type: "ClusterIP"}},
+		},
+	}
+}
+
+func TestBuildIPViews(t *testing.T) {
+	inv := testIPInventory()
+	views := buildIPViews(inv.EIps, inv.FIps, inv.Services)
+	var got []string
+	for _, v := range views {
+		var targets []string
+		for _, tg := range v.Targets {
+			targets = append(targets, tg.String())
+		}
+		got = append(got, v.Kind+" "+v.Name+" "+v.NetworkType+" "+v.Address+" ["+strings.Join(targets, ",")+"]")
+	}
+	// The controller-owned EIp is folded into FIp db-1, which inherits
+	// its type from spec and its interface from status.
+	want := []string{
+		"FIp db-1 public 203.0.113.12 [vm/db-1:default]",
+		"EIp default-gw cloud 100.65.0.2 [service/shared]",
+		"EIp lb cloud 100.65.0.7 [service/api,service/grpc]",
+		"EIp spare public 203.0.113.11 []",
+		"EIp web public 203.0.113.10 [fip/web]",
+		"FIp web public 203.0.113.10 [vm/web-1:default]",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("views:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	v, err := inv.view("acme-web", "fip-db-1-eip")
+	if err != nil || v.OwnedBy != "fip/db-1" {
+		t.Fatalf("owned EIp: %+v, %v", v, err)
+	}
+	if v.Targets[0].Workload != "VM db-1, interface default → 10.0.0.20" {
+		t.Fatalf("workload = %q", v.Targets[0].Workload)
+	}
+	if _, err := inv.view("acme-web", "nope"); err == nil {
+		t.Fatal("want an error for an unknown name")
+	}
+}
+
+func TestVPCInterface(t *testing.T) {
+	vm := func(networks ...any) *k8sapi.VirtualMachine {
+		return &k8sapi.VirtualMachine{
+			Metadata: k8sapi.ObjectMeta{Name: "web-1"},
+			Spec:     k8sapi.VirtualMachineSpec{Template: k8sapi.VMTemplate{Spec: map[string]any{"networks": networks}}},
+		}
+	}
+	multus := func(name, net string) any {
+		return map[string]any{"name": name, "multus": map[string]any{"networkName": net}}
+	}
+	tests := []struct {
+		name    string
+		vm      *k8sapi.VirtualMachine
+		want    string
+		iface   string
+		wantErr string
+	}{
+		{name: "default multus", vm: vm(multus("default", "acme-web/default")), iface: "default"},
+		{name: "pod network", vm: vm(map[string]any{"name": "default", "pod": map[string]any{}}), iface: "default"},
+		{name: "first on vpc", vm: vm(multus("storage", "acme-web/vlan-200"), multus("eth1", "acme-web/default")), iface: "eth1"},
+		{name: "explicit", vm: vm(multus("a", "acme-web/default"), multus("b", "default")), want: "b", iface: "b"},
+		{name: "explicit off vpc", vm: vm(multus("storage", "acme-web/vlan-200")), want: "storage",
+			wantErr: "interface storage of VM web-1 is on acme-web/vlan-200, not the Project VPC (acme-web/default)"},
+		{name: "missing", vm: vm(multus("default", "acme-web/default")), want: "eth9",
+			wantErr: `VM web-1 has no interface "eth9"; it has default (acme-web/default)`},
+		{name: "none on vpc", vm: vm(multus("storage", "acme-web/vlan-200")),
+			wantErr: "VM web-1 has no interface on the Project VPC (acme-web/default); it has storage (acme-web/vlan-200)"},
+		{name: "no networks", vm: vm(), wantErr: "VM web-1 has no network interfaces"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := vpcInterface(tt.vm, "acme-web", tt.want)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.iface {
+				t.Fatalf("got %q, %v; want %q", got, err, tt.iface)
+			}
+		})
+	}
+}
+
+func TestPublicIPQuota(t *testing.T) {
+	if q := parsePublicIPQuota("acme", nil, ""); q != nil {
+		t.Fatalf("nil pair: %+v", q)
+	}
+	if q := parsePublicIPQuota("acme", &k8sapi.QuotaPair{Hard: "3"}, ""); q != nil {
+		t.Fatalf("empty used must be unknown, got %+v", q)
+	}
+	q := parsePublicIPQuota("acme", &k8sapi.QuotaPair{Used: "2", Hard: "3"}, "2026-10-16T09:00:00Z")
+	if q == nil || q.Used != 2 || q.Hard != 3 {
+		t.Fatalf("quota = %+v", q)
+	}
+	if err := q.check(1); err != nil {
+		t.Fatalf("2+1 of 3: %v", err)
+	}
+	err := q.check(2)
+	if err == nil || !strings.Contains(err.Error(), "Organization acme has used 2 of 3 public IPv4 addresses (as of 2026-10-16T09:00:00Z)") {
+		t.Fatalf("2+2 of 3: %v", err)
+	}
+	var unknown *ipQuota
+	if err := unknown.check(1); err != nil {
+		t.Fatalf("unknown quota must not block: %v", err)
+	}
+}
+
+func TestFIPRoutingConflict(t *testing.T) {
+	inv := testIPInventory()
+	if w := fipRoutingConflict(inv, inv.eip("spare"), "app-1"); !strings.Contains(w, "cloud LoadBalancer Service grpc (EIp lb)") {
+		t.Fatalf("public FIp to a cloud LB backend: %q", w)
+	}
+	if w := fipRoutingConflict(inv, inv.eip("spare"), "web-1"); w != "" {
+		t.Fatalf("no LB in front of web-1: %q", w)
+	}
+	if w := fipRoutingConflict(inv, inv.eip("lb"), "app-1"); w != "" {
+		t.Fatalf("cloud FIp: %q", w)
+	}
+}
+
+func TestPlanDetach(t *testing.T) {
+	inv := testIPInventory()
+	names := func(steps []ipTarget) string {
+		var out []string
+		for _, s := range steps {
+			out = append(out, s.String())
+		}
+		return strings.Join(out, ",")
+	}
+	tests := []struct {
+		ip, kind, target string
+		want, wantErr    string
+	}{
+		{ip: "lb", want: "service/api,service/grpc"},
+		{ip: "lb", kind: "service", target: "grpc", want: "service/grpc"},
+		{ip: "web", kind: "vm", target: "web-1", want: "fip/web"},
+		{ip: "web", kind: "vm", target: "web-2", wantErr: "EIp web is not attached to vm web-2"},
+		{ip: "spare", wantErr: "EIp spare is not attached to anything"},
+		{ip: "fip-db-1-eip", wantErr: "belongs to fip/db-1"},
+		{ip: "default-gw", wantErr: "belongs to the Project gateway"},
+		{ip: "db-1", wantErr: "Floating IP db-1 owns its address"},
+		{ip: "nope", wantErr: "no EIp named nope"},
+	}
+	for _, tt := range tests {
+		steps, err := planDetach(inv, "acme-web", tt.ip, tt.kind, tt.target)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s %s %s: err = %v, want %q", tt.ip, tt.kind, tt.target, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || names(steps) != tt.want {
+			t.Errorf("%s %s %s: got %q, %v; want %q", tt.ip, tt.kind, tt.target, names(steps), err, tt.want)
+		}
+	}
+}
+
+func TestWaitForAddress(t *testing.T) {
+	defer func(d time.Duration) { ipPollInterval = d }(ipPollInterval)
+	ipPollInterval = time.Millisecond
+
+	calls := 0
+	addr, err := waitForAddress(context.Background(), "EIp web", time.Second, func(context.Context) (bool, string, []k8sapi.Condition, error) {
+		calls++
+		if calls < 3 {
+			return false, "", nil, nil
+		}
+		return true, "203.0.113.10", nil, nil
+	})
+	if err != nil || addr != "203.0.113.10" {
+		t.Fatalf("got %q, %v", addr, err)
+	}
+
+	_, err = waitForAddress(context.Background(), "EIp web", 20*time.Millisecond, func(context.Context) (bool, string, []k8sapi.Condition, error) {
+		return false, "", []k8sapi.Condition{{Type: "Ready", Status: "False", Reason: "QuotaExceeded", Message: "public IPv4 quota exhausted"}}, nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "QuotaExceeded") {
+		t.Fatalf("timeout error = %v", err)
+	}
+
+	_, err = waitForAddress(context.Background(), "EIp web", time.Second, func(context.Context) (bool, string, []k8sapi.Condition, error) {
+		return false, "", nil, &k8sapi.APIError{Status: 404}
+	})
+	if err == nil || err.Error() != "EIp web not found" {
+		t.Fatalf("not found error = %v", err)
+	}
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -37,7 +36,8 @@ const (
 // named kube-dc/<domain>/admin into ~/.kube/config.
 //
 // Flow:
-//  1. PKCE OAuth against https://login.<domain>/realms/master.
+//  1. PKCE OAuth (or the device flow with --device-code) against
+//     https://login.<domain>/realms/master.
 //  2. Parse the access token's claims; verify the user is a member of
 //     the kube-dc-admin Keycloak group. (The API server enforces RBAC
 //     too, but failing here gives a much better error than kubectl's
@@ -52,13 +52,6 @@ func runAdminLogin(domain, caCertFile string, insecure, deviceCode bool) error {
 	if domain == "" {
 		return fmt.Errorf("--domain is required for --admin login")
 	}
-	if deviceCode {
-		return fmt.Errorf("device code flow is not implemented. " +
-			"On a headless machine use one of these instead:\n" +
-			"  - run `kube-dc login` on a workstation WITH a browser, then copy ~/.kube/config over; or\n" +
-			"  - use the client-certificate kubeconfig from `kube-dc bootstrap fetch-kubeconfig`, " +
-			"which needs neither a browser nor OIDC")
-	}
 
 	server := fmt.Sprintf("https://kube-api.%s:6443", domain)
 	keycloakURL := fmt.Sprintf("https://login.%s", domain)
@@ -77,17 +70,13 @@ func runAdminLogin(domain, caCertFile string, insecure, deviceCode bool) error {
 	fmt.Printf("   API Server: %s\n", server)
 	fmt.Printf("   Keycloak:   %s\n\n", keycloakURL)
 
-	flow := auth.NewOAuthFlow(&auth.OAuthConfig{
+	tokens, err := loginTokens(&auth.OAuthConfig{
 		KeycloakURL: keycloakURL,
 		Realm:       adminRealm,
 		ClientID:    adminClientID,
 		CACert:      caCertPEM,
 		Insecure:    insecure,
-	})
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-
-	tokens, err := flow.Login(ctx)
+	}, deviceCode)
 	if err != nil {
 		return fmt.Errorf("admin login failed: %w", err)
 	}
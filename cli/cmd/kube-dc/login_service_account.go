@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/auth"
+	"github.com/shalb/kube-dc/cli/internal/config"
+	"github.com/shalb/kube-dc/cli/internal/jwt"
+	"github.com/shalb/kube-dc/cli/internal/kubeconfig"
+)
+
+// serviceAccountLogin is the flag set of a non-interactive login. It is only
+// selected when --client-id is given; everything else in it is optional and
+// decides which grant is used.
+type serviceAccountLogin struct {
+	clientID         string
+	clientSecretFile string
+	subjectTokenFile string
+	subjectIssuer    string
+}
+
+// runServiceAccountLogin authenticates a CI job against an Organization realm
+// without a browser or a prompt, and writes the same per-Project contexts a
+// human login does.
+//
+// Flow:
+//  1. client_credentials with --client-secret-file, or RFC 8693 token
+//     exchange with --subject-token-file (e.g. a GitHub Actions ID token).
+//  2. Cache the token realm-scoped at ~/.kube-dc/credentials/, together
+//     with the file paths it was minted from. There is usually no refresh
+//     token, so the exec plugin replays the grant when the access token
+//     expires instead of asking for `kube-dc login`.
+//  3. One context per Project in the token's namespaces claim, exactly as
+//     runLogin does, so kubectl invocations in the job are unchanged.
+func runServiceAccountLogin(domain, org, caCertFile string, insecure bool, sa serviceAccountLogin) error {
+	if domain == "" || org == "" {
+		return fmt.Errorf("--domain and --org are required with --client-id (a service-account login never prompts)")
+	}
+	if sa.clientSecretFile == "" && sa.subjectTokenFile == "" {
+		return fmt.Errorf("--client-id needs --client-secret-file (client credentials) or --subject-token-file (token exchange)")
+	}
+	if sa.subjectIssuer != "" && sa.subjectTokenFile == "" {
+		return fmt.Errorf("--subject-issuer only applies with --subject-token-file")
+	}
+
+	// The exec plugin re-reads these files on every re-mint, from whatever
+	// directory kubectl happens to run in. A relative path would only resolve
+	// from the directory the login ran in.
+	secretFile, err := absPath(sa.clientSecretFile)
+	if err != nil {
+		return err
+	}
+	subjectFile, err := absPath(sa.subjectTokenFile)
+	if err != nil {
+		return err
+	}
+
+	server := fmt.Sprintf("https://kube-api.%s:6443", domain)
+	keycloakURL := fmt.Sprintf("https://login.%s", domain)
+
+	var caCertPEM string
+	if caCertFile != "" {
+		b, err := os.ReadFile(caCertFile)
+		if err != nil {
+			return fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		caCertPEM = string(b)
+	}
+
+	saCfg := &auth.ServiceAccountConfig{
+		KeycloakURL:      keycloakURL,
+		Realm:            org,
+		ClientID:         sa.clientID,
+		ClientSecretFile: secretFile,
+		SubjectTokenFile: subjectFile,
+		SubjectIssuer:    sa.subjectIssuer,
+		CACert:           caCertPEM,
+		Insecure:         insecure,
+	}
+
+	fmt.Printf("Service-account login to %s (Organization: %s, client: %s)\n", domain, org, sa.clientID)
+
+	tokens, err := auth.ServiceAccountToken(saCfg)
+	if err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+
+	claims, err := jwt.ParseToken(tokens.AccessToken)
+	if err != nil {
+		return fmt.Errorf("failed to parse token: %w", err)
+	}
+
+	credMgr, err := config.NewCredentialsManager()
+	if err != nil {
+		return fmt.Errorf("failed to initialize credentials manager: %w", err)
+	}
+
+	// RefreshTokenExpiry only means something when Keycloak handed out a
+	// refresh token (token exchange may; client_credentials does not). Left
+	// zero otherwise, so `config show` does not advertise a session that
+	// cannot be refreshed.
+	var refreshTokenExpiry time.Time
+	if tokens.RefreshToken != "" {
+		refreshTokenExpiry = time.Now().Add(30 * 24 * time.Hour)
+		if tokens.RefreshExpiresIn > 0 {
+			refreshTokenExpiry = time.Now().Add(time.Duration(tokens.RefreshExpiresIn) * time.Second)
+		}
+	}
+
+	creds := &config.Credentials{
+		Server:             server,
+		KeycloakURL:        keycloakURL,
+		Realm:              org,
+		ClientID:           sa.clientID,
+		AccessToken:        tokens.AccessToken,
+		RefreshToken:       tokens.RefreshToken,
+		IDToken:            tokens.IDToken,
+		AccessTokenExpiry:  claims.ExpiryTime(),
+		RefreshTokenExpiry: refreshTokenExpiry,
+		User: config.UserInfo{
+			Email:      claims.Email,
+			Org:        claims.Org,
+			Groups:     claims.Groups,
+			Namespaces: claims.Namespaces,
+		},
+		CACert:   caCertPEM,
+		Insecure: insecure,
+		ServiceAccount: &config.ServiceAccount{
+			GrantType:        saCfg.GrantType(),
+			ClientSecretFile: secretFile,
+			SubjectTokenFile: subjectFile,
+			SubjectIssuer:    sa.subjectIssuer,
+		},
+	}
+	if err := credMgr.Save(creds); err != nil {
+		return fmt.Errorf("failed to save credentials: %w", err)
+	}
+
+	identity := claims.Email
+	if identity == "" {
+		identity = claims.Subject
+	}
+	fmt.Printf("  Identity: %s\n", identity)
+	fmt.Printf("  Grant:    %s\n", saCfg.GrantType())
+
+	if err := warnIfNonDefaultKubeconfig(); err != nil {
+		return err
+	}
+	kubeMgr, err := kubeconfig.NewManager()
+	if err != nil {
+		return fmt.Errorf("failed to initialize kubeconfig manager: %w", err)
+	}
+	for i, ns := range claims.Namespaces {
+		params := tenantContextParams(domain, org, server, ns, caCertPEM, insecure, i == 0)
+		if err := kubeMgr.AddKubeDCContext(params); err != nil {
+			fmt.Printf("  Warning: failed to add context %s: %v\n", params.ContextName, err)
+		}
+	}
+
+	// A service account sees the Projects its client's namespaces mapper puts
+	// in the token. An empty claim is a Keycloak client configuration issue,
+	// not something a rerun fixes, so say where to look.
+	if len(claims.Namespaces) == 0 {
+		fmt.Println("  Authenticated, but the token carries no Projects, so NO kubectl context was created.")
+		fmt.Printf("  Check that client %q in realm %q has the namespaces mapper and the\n", sa.clientID, org)
+		fmt.Println("  service-account roles for the Projects this job should reach.")
+		return nil
+	}
+	fmt.Printf("  Contexts: %s\n", strings.Join(claims.Namespaces, ", "))
+	return nil
+}
+
+func absPath(p string) (string, error) {
+	if p == "" {
+		return "", nil
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", p, err)
+	}
+	return abs, nil
+}
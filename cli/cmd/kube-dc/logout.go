@@ -0,0 +1,371 @@
+package main
+
+// `kube-dc logout` ends the session at Keycloak before it forgets it
+// locally. Deleting the credential file alone leaves the refresh token —
+// an offline token for device-code logins — valid for weeks, so a copy
+// taken from a lost laptop keeps working. Each realm entry is therefore
+// logged out remotely (end-session, then RFC 7009 revocation) and only
+// removed from disk once that succeeded; a failure keeps the entry so the
+// logout can be retried. --local-only is the escape hatch for machines
+// that cannot reach Keycloak.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/shalb/kube-dc/cli/internal/auth"
+	"github.com/shalb/kube-dc/cli/internal/config"
+	"github.com/shalb/kube-dc/cli/internal/kubeconfig"
+	"github.com/shalb/kube-dc/cli/pkg/credential"
+	"github.com/spf13/cobra"
+)
+
+func logoutCmd() *cobra.Command {
+	var server string
+	var all bool
+	var removeContexts bool
+	var localOnly bool
+
+	cmd := &cobra.Command{
+		Use:   "logout",
+		Short: "End the Keycloak session and remove cached credentials",
+		Long: `End the Keycloak session for each cached realm credential and remove it.
+
+For every realm entry, logout ends the SSO session at the realm's end-session
+endpoint and revokes the refresh token (RFC 7009), so a copy of the credential
+file stops working. The local entry is removed only after that succeeded; if
+Keycloak cannot be reached the entry is kept and the command fails, so the
+logout can be retried. Use --local-only on a machine that is offline for good.
+
+A credential file that can no longer be decrypted cannot be revoked; it is
+reported and kept unless --local-only is set.`,
+		Example: `  # Logout from current server
+  kube-dc logout
+
+  # Logout from specific server
+  kube-dc logout --server https://api.kube-dc.cloud
+
+  # Logout from all servers and realms
+  kube-dc logout --all
+
+  # Offline machine: remove the local credentials without contacting Keycloak
+  kube-dc logout --all --local-only`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return runLogout(server, all, removeContexts, localOnly)
+		},
+	}
+
+	cmd.Flags().StringVar(&server, "server", "", "Kube-DC API server URL")
+	cmd.Flags().BoolVar(&all, "all", false, "Logout from all servers and realms")
+	cmd.Flags().BoolVar(&removeContexts, "remove-contexts", false, "Also remove kubeconfig contexts")
+	cmd.Flags().BoolVar(&localOnly, "local-only", false, "Only remove local credentials; do not revoke tokens at Keycloak")
+
+	return cmd
+}
+
+// logoutResult is one row of the per-realm report.
+type logoutResult struct {
+	server, realm string
+	session       string // ended | skipped | failed
+	token         string // revoked | skipped | failed
+	local         string // removed | kept | failed
+	err           error
+
+	// unreadable is set for a credential file that could not be decrypted;
+	// server then holds its file name.
+	unreadable *config.UnreadableCredential
+}
+
+func runLogout(server string, all, removeContexts, localOnly bool) error {
+	credMgr, err := config.NewCredentialsManager()
+	if err != nil {
+		return fmt.Errorf("failed to initialize credentials manager: %w", err)
+	}
+
+	if !all && server == "" {
+		server = currentKubeDCServer()
+		if server == "" {
+			return fmt.Errorf("no server specified and no kube-dc context active. Use --server or --all")
+		}
+	}
+
+	creds, unreadable, err := credMgr.List()
+	if err != nil {
+		return fmt.Errorf("failed to list credentials: %w", err)
+	}
+	var targets []*config.Credentials
+	for _, c := range creds {
+		if all || c.Server == server {
+			targets = append(targets, c)
+		}
+	}
+	var broken []config.UnreadableCredential
+	for _, u := range unreadable {
+		if all || u.ForServer(server) {
+			broken = append(broken, u)
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].Server != targets[j].Server {
+			return targets[i].Server < targets[j].Server
+		}
+		return targets[i].Realm < targets[j].Realm
+	})
+
+	var results []*logoutResult
+	failedServers := map[string]bool{}
+	for _, c := range targets {
+		r := logoutRealm(credMgr, c, localOnly)
+		results = append(results, r)
+		if r.err != nil {
+			failedServers[c.Server] = true
+		}
+	}
+	var keptBroken []config.UnreadableCredential
+	for _, u := range broken {
+		r := logoutUnreadable(credMgr, u, localOnly)
+		results = append(results, r)
+		if r.err != nil {
+			keptBroken = append(keptBroken, u)
+		}
+	}
+	// failed reports whether anything of s's was kept for a retry; its
+	// contexts and remaining files are left alone then.
+	failed := func(s string) bool {
+		if failedServers[s] {
+			return true
+		}
+		for _, u := range keptBroken {
+			if u.ForServer(s) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// A server whose realms all logged out cleanly is swept as a whole, which
+	// also catches a pre-realm legacy file for it.
+	var cleared []string
+	for _, r := range results {
+		if r.unreadable != nil || failed(r.server) || (len(cleared) > 0 && cleared[len(cleared)-1] == r.server) {
+			continue
+		}
+		cleared = append(cleared, r.server)
+		if err := credMgr.Delete(r.server); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+
+	if len(results) == 0 {
+		if all {
+			fmt.Println("No credentials to remove.")
+		} else {
+			fmt.Printf("No credentials for %s.\n", server)
+		}
+	} else {
+		printLogoutResults(results)
+	}
+
+	// Contexts do not depend on a credential file being there: a context
+	// left behind by an earlier logout is removed too.
+	if removeContexts {
+		removeLogoutContexts(all, server, cleared, failed)
+	}
+
+	var failures []string
+	for _, r := range results {
+		if r.err != nil {
+			failures = append(failures, fmt.Sprintf("  %s (realm %s): %v", r.server, realmLabel(r.realm), r.err))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("logout incomplete for %d realm(s); their credentials were kept so the logout can be retried:\n%s\nOn a machine that cannot reach Keycloak, rerun with --local-only",
+			len(failures), strings.Join(failures, "\n"))
+	}
+	return nil
+}
+
+// removeLogoutContexts removes the kubeconfig contexts of every server the
+// logout covered — with --all, every kube-dc server in the kubeconfig —
+// except those whose credentials were kept for a retry.
+func removeLogoutContexts(all bool, server string, cleared []string, failed func(string) bool) {
+	kubeMgr, err := kubeconfig.NewManager()
+	if err != nil {
+		fmt.Printf("Warning: failed to load kubeconfig: %v\n", err)
+		return
+	}
+	servers := append([]string(nil), cleared...)
+	if all {
+		kubeConfig, err := kubeMgr.Load()
+		if err != nil {
+			fmt.Printf("Warning: failed to load kubeconfig: %v\n", err)
+			return
+		}
+		for _, c := range kubeConfig.Clusters {
+			if strings.HasPrefix(c.Name, "kube-dc-") {
+				servers = append(servers, c.Cluster.Server)
+			}
+		}
+	} else {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+	for i, s := range servers {
+		if (i > 0 && servers[i-1] == s) || failed(s) {
+			continue
+		}
+		if err := kubeMgr.RemoveKubeDCContexts(s); err != nil {
+			fmt.Printf("Warning: failed to remove kubeconfig contexts for %s: %v\n", s, err)
+		} else {
+			fmt.Printf("Removed kubeconfig contexts for %s.\n", s)
+		}
+	}
+}
+
+// logoutUnreadable handles a credential file that could not be decrypted.
+// Its tokens cannot be revoked, so it is only removed with --local-only;
+// otherwise it is kept and reported, since unlocking the store may make it
+// readable for a proper logout.
+func logoutUnreadable(credMgr *config.CredentialsManager, u config.UnreadableCredential, localOnly bool) *logoutResult {
+	r := &logoutResult{server: filepath.Base(u.Path), session: "skipped", token: "skipped", local: "kept", unreadable: &u}
+	if !localOnly {
+		r.err = fmt.Errorf("cannot read it to revoke its tokens (%v); unlock the credential store and retry, or remove it with --local-only", u.Err)
+		return r
+	}
+	if err := credMgr.RemoveUnreadable(u); err != nil {
+		r.local, r.err = "failed", err
+		return r
+	}
+	r.local = "removed"
+	return r
+}
+
+// logoutRealm logs one realm entry out. The credential lock is held
+// throughout so a concurrent exec-plugin refresh cannot rotate the refresh
+// token between revocation and removal and write a fresh one back. c is
+// only the listing: the entry is re-read under the lock, since a refresh
+// that finished before it was taken has already rotated c's tokens.
+func logoutRealm(credMgr *config.CredentialsManager, c *config.Credentials, localOnly bool) *logoutResult {
+	r := &logoutResult{server: c.Server, realm: c.Realm, session: "skipped", token: "skipped", local: "kept"}
+
+	release, err := credMgr.Lock(c.Server, c.Realm)
+	if err != nil {
+		r.local, r.err = "failed", err
+		return r
+	}
+	defer release()
+
+	c, err = credMgr.LoadForRealm(c.Server, c.Realm)
+	if errors.Is(err, config.ErrNoCredentials) {
+		// Another logout got there first.
+		r.local = "gone"
+		return r
+	}
+	if err != nil {
+		r.err = err
+		return r
+	}
+
+	if !localOnly {
+		if err := endRemoteSession(c, r); err != nil {
+			r.err = err
+			return r
+		}
+	}
+
+	if err := credMgr.DeleteForRealm(c.Server, c.Realm); err != nil {
+		r.local, r.err = "failed", err
+		return r
+	}
+	_ = credential.Forget(c.Server, c.Realm)
+	r.local = "removed"
+	return r
+}
+
+// endRemoteSession ends the SSO session first, then revokes: once the
+// refresh token is revoked Keycloak can no longer find the session it
+// belonged to. Service-account entries without a refresh token revoke their
+// access token instead.
+func endRemoteSession(c *config.Credentials, r *logoutResult) error {
+	sc := &auth.SessionConfig{
+		KeycloakURL: c.KeycloakURL,
+		Realm:       c.Realm,
+		ClientID:    c.ClientID,
+		CACert:      c.CACert,
+		Insecure:    c.Insecure,
+	}
+	if c.ServiceAccount != nil {
+		sc.ClientSecretFile = c.ServiceAccount.ClientSecretFile
+	}
+	ctx, cancel := ctxWithTimeout()
+	defer cancel()
+
+	if c.RefreshToken != "" || c.IDToken != "" {
+		if err := auth.EndSession(ctx, sc, c.RefreshToken, c.IDToken); err != nil {
+			r.session = "failed"
+			return err
+		}
+		r.session = "ended"
+	}
+
+	token, hint := c.RefreshToken, auth.HintRefreshToken
+	if token == "" {
+		token, hint = c.AccessToken, auth.HintAccessToken
+	}
+	if token == "" {
+		return nil
+	}
+	if err := auth.RevokeToken(ctx, sc, token, hint); err != nil {
+		r.token = "failed"
+		return err
+	}
+	r.token = "revoked"
+	return nil
+}
+
+func printLogoutResults(results []*logoutResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVER\tREALM\tSESSION\tTOKEN\tLOCAL")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.server, realmLabel(r.realm), r.session, r.token, r.local)
+	}
+	_ = w.Flush()
+}
+
+func realmLabel(realm string) string {
+	if realm == "" {
+		return "-"
+	}
+	return realm
+}
+
+// currentKubeDCServer returns the API server of the current kubeconfig
+// context when it is a kube-dc one, or "".
+func currentKubeDCServer() string {
+	kubeMgr, err := kubeconfig.NewManager()
+	if err != nil {
+		return ""
+	}
+	kubeConfig, err := kubeMgr.Load()
+	if err != nil || !strings.HasPrefix(kubeConfig.CurrentContext, "kube-dc/") {
+		return ""
+	}
+	for _, ctx := range kubeConfig.Contexts {
+		if ctx.Name != kubeConfig.CurrentContext {
+			continue
+		}
+		for _, cluster := range kubeConfig.Clusters {
+			if cluster.Name == ctx.Context.Cluster {
+				return cluster.Cluster.Server
+			}
+		}
+		break
+	}
+	return ""
+}
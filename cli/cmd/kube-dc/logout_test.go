@@ -0,0 +1,242 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/config"
+	"github.com/shalb/kube-dc/cli/internal/kubeconfig"
+)
+
+// logoutKeycloak serves the end-session and revocation endpoints for any
+// realm, failing every call for the realms in down.
+type logoutKeycloak struct {
+	mu      sync.Mutex
+	down    map[string]bool
+	calls   []string
+	revoked []string
+}
+
+func (k *logoutKeycloak) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// /realms/<realm>/protocol/openid-connect/<endpoint>
+	parts := strings.Split(r.URL.Path, "/")
+	realm, endpoint := parts[2], parts[len(parts)-1]
+	k.mu.Lock()
+	k.calls = append(k.calls, realm+" "+endpoint)
+	if endpoint == "revoke" {
+		k.revoked = append(k.revoked, r.FormValue("token"))
+	}
+	k.mu.Unlock()
+	if k.down[realm] {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func setupLogins(t *testing.T, keycloakURL string, realms ...string) *config.CredentialsManager {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	mgr, err := config.NewCredentialsManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, realm := range realms {
+		if err := mgr.Save(&config.Credentials{
+			Server:            "https://kube-api.example.com:6443",
+			KeycloakURL:       keycloakURL,
+			Realm:             realm,
+			ClientID:          "kube-dc",
+			AccessToken:       "at-" + realm,
+			RefreshToken:      "rt-" + realm,
+			IDToken:           "it-" + realm,
+			AccessTokenExpiry: time.Now().Add(time.Hour),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return mgr
+}
+
+func remainingRealms(t *testing.T, mgr *config.CredentialsManager) []string {
+	t.Helper()
+	creds, _, err := mgr.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var realms []string
+	for _, c := range creds {
+		realms = append(realms, c.Realm)
+	}
+	return realms
+}
+
+func TestRunLogout_AllEndsSessionThenRevokesPerRealm(t *testing.T) {
+	kc := &logoutKeycloak{}
+	srv := httptest.NewServer(kc)
+	defer srv.Close()
+	mgr := setupLogins(t, srv.URL, "acme", "globex")
+
+	if err := runLogout("", true, false, false); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"acme logout", "acme revoke", "globex logout", "globex revoke"}
+	if strings.Join(kc.calls, ",") != strings.Join(want, ",") {
+		t.Errorf("calls = %v, want %v", kc.calls, want)
+	}
+	if left := remainingRealms(t, mgr); len(left) != 0 {
+		t.Errorf("credentials left: %v", left)
+	}
+}
+
+func TestRunLogout_KeepsCredentialsOfRealmsThatFailed(t *testing.T) {
+	kc := &logoutKeycloak{down: map[string]bool{"globex": true}}
+	srv := httptest.NewServer(kc)
+	defer srv.Close()
+	mgr := setupLogins(t, srv.URL, "acme", "globex")
+
+	err := runLogout("https://kube-api.example.com:6443", false, false, false)
+	if err == nil || !strings.Contains(err.Error(), "globex") || !strings.Contains(err.Error(), "--local-only") {
+		t.Fatalf("err = %v, want a failure naming globex and suggesting --local-only", err)
+	}
+	if left := remainingRealms(t, mgr); len(left) != 1 || left[0] != "globex" {
+		t.Errorf("credentials left = %v, want only globex", left)
+	}
+}
+
+// The listing a logout starts from can be stale by the time the lock is
+// held: the entry is re-read, so the refresh token a concurrent refresh
+// just rotated in is the one revoked, and an entry already removed is not
+// a failure.
+func TestLogoutRealm_RereadsUnderTheLock(t *testing.T) {
+	kc := &logoutKeycloak{}
+	srv := httptest.NewServer(kc)
+	defer srv.Close()
+	mgr := setupLogins(t, srv.URL, "acme")
+	listed, _, err := mgr.List()
+	if err != nil || len(listed) != 1 {
+		t.Fatalf("list = %v, %v", listed, err)
+	}
+	rotated := *listed[0]
+	rotated.RefreshToken = "rt-acme-rotated"
+	if err := mgr.Save(&rotated); err != nil {
+		t.Fatal(err)
+	}
+
+	if r := logoutRealm(mgr, listed[0], false); r.err != nil || r.local != "removed" {
+		t.Fatalf("logout = %+v", r)
+	}
+	if len(kc.revoked) != 1 || kc.revoked[0] != "rt-acme-rotated" {
+		t.Errorf("revoked %v, want the rotated refresh token", kc.revoked)
+	}
+
+	kc.calls = nil
+	if r := logoutRealm(mgr, listed[0], false); r.err != nil || r.local != "gone" {
+		t.Errorf("second logout = %+v", r)
+	}
+	if len(kc.calls) != 0 {
+		t.Errorf("logging out a removed entry called Keycloak: %v", kc.calls)
+	}
+}
+
+func TestRunLogout_LocalOnlyNeverContactsKeycloak(t *testing.T) {
+	kc := &logoutKeycloak{down: map[string]bool{"acme": true}}
+	srv := httptest.NewServer(kc)
+	defer srv.Close()
+	mgr := setupLogins(t, srv.URL, "acme")
+
+	if err := runLogout("", true, false, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(kc.calls) != 0 {
+		t.Errorf("--local-only made calls: %v", kc.calls)
+	}
+	if left := remainingRealms(t, mgr); len(left) != 0 {
+		t.Errorf("credentials left: %v", left)
+	}
+}
+
+// A credential file that cannot be decrypted is still the user's: --local-only
+// removes it, and a plain logout keeps it and says why.
+func TestRunLogout_UnreadableCredentials(t *testing.T) {
+	kc := &logoutKeycloak{}
+	srv := httptest.NewServer(kc)
+	defer srv.Close()
+	mgr := setupLogins(t, srv.URL, "acme")
+	t.Setenv(config.PassphraseEnv, "pw")
+	if _, err := mgr.SetEncryption(&config.Encryption{Mode: config.EncryptionPassphrase}); err != nil {
+		t.Fatal(err)
+	}
+	// Losing the settings leaves the file sealed with nothing to open it.
+	if err := os.Remove(filepath.Join(os.Getenv("HOME"), ".kube-dc", "credentials", "encryption.conf")); err != nil {
+		t.Fatal(err)
+	}
+	unreadable := func() int {
+		fresh, err := config.NewCredentialsManager()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, u, err := fresh.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(u)
+	}
+
+	err := runLogout("https://kube-api.example.com:6443", false, false, false)
+	if err == nil || !strings.Contains(err.Error(), "--local-only") {
+		t.Fatalf("err = %v, want the unreadable file reported with a --local-only hint", err)
+	}
+	if n := unreadable(); n != 1 {
+		t.Fatalf("unreadable files = %d after a plain logout, want it kept", n)
+	}
+
+	if err := runLogout("https://kube-api.example.com:6443", false, false, true); err != nil {
+		t.Fatal(err)
+	}
+	if n := unreadable(); n != 0 {
+		t.Errorf("unreadable files = %d after --local-only, want 0", n)
+	}
+	if len(kc.calls) != 0 {
+		t.Errorf("Keycloak called for a file that could not be read: %v", kc.calls)
+	}
+}
+
+// --remove-contexts does not depend on there being credentials to remove.
+func TestRunLogout_RemoveContextsWithoutCredentials(t *testing.T) {
+	setupLogins(t, "http://keycloak.invalid")
+	kubeconfigPath := filepath.Join(t.TempDir(), "config")
+	t.Setenv("KUBECONFIG", kubeconfigPath)
+	kubeMgr, err := kubeconfig.NewManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := kubeMgr.AddKubeDCContext(kubeconfig.AddContextParams{
+		Server:      "https://kube-api.example.com:6443",
+		ClusterName: "kube-dc-example",
+		UserName:    "kube-dc@example",
+		ContextName: "kube-dc/example/acme/demo",
+		Realm:       "acme",
+		SetCurrent:  true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runLogout("", true, true, false); err != nil {
+		t.Fatal(err)
+	}
+	contexts, err := kubeMgr.ListKubeDCContexts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contexts) != 0 {
+		t.Errorf("contexts left: %v", contexts)
+	}
+}
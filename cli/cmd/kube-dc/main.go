@@ -68,12 +68,20 @@ It follows the same patterns as AWS CLI, GCloud, and other cloud provider CLIs:
 	rootCmd.AddCommand(nsCmd())
 	rootCmd.AddCommand(configCmd())
 	rootCmd.AddCommand(credentialCmd())
+	rootCmd.AddCommand(whoamiCmd())
+	rootCmd.AddCommand(projectsCmd())
+	rootCmd.AddCommand(vmCmd())
 	rootCmd.AddCommand(alertsCmd())
 	rootCmd.AddCommand(bootstrapCmd())
 	rootCmd.AddCommand(secretsCmd())
 	rootCmd.AddCommand(certificatesCmd())
 	rootCmd.AddCommand(kmsCmd())
 	rootCmd.AddCommand(dbCmd())
+	rootCmd.AddCommand(ipCmd())
+	rootCmd.AddCommand(exposeCmd())
+	rootCmd.AddCommand(bucketsCmd())
+	rootCmd.AddCommand(quotaCmd())
+	rootCmd.AddCommand(clustersCmd())
 	rootCmd.AddCommand(orgsCmd())
 	rootCmd.AddCommand(auditCmd())
 	rootCmd.AddCommand(versionCmd())
@@ -130,6 +138,7 @@ func loginCmd() *cobra.Command {
 	var deviceCode bool
 	var caCertFile string
 	var insecure bool
+	var sa serviceAccountLogin
 
 	cmd := &cobra.Command{
 		Use:   "login",
@@ -139,6 +148,18 @@ func loginCmd() *cobra.Command {
 Opens your default browser for authentication. After successful login,
 your credentials are cached and kubectl is configured automatically.
 
+On a machine without a browser (jump host, tmux session, container) pass
+--device-code: the CLI prints a short code and a URL to open on any other
+device, and finishes the login once the code is approved there.
+
+CI pipelines pass --client-id instead and never touch a browser or prompt:
+  --client-secret-file   client-credentials grant for a confidential client
+  --subject-token-file   token exchange of a federated OIDC JWT (e.g. a
+                         GitHub Actions ID token), with --subject-issuer
+                         naming the Keycloak identity provider that trusts it
+Secrets are read from files and only the paths are cached; when the access
+token expires, kubectl re-mints it from the same files.
+
 The domain is used to derive the API and login URLs:
   - API Server: https://kube-api.{domain}:6443
   - Keycloak:   https://login.{domain}
@@ -159,12 +180,32 @@ Two identity modes:
   # Platform-admin login
   kube-dc login --domain kube-dc.cloud --admin
 
+  # Headless machine: approve the login from another device
+  kube-dc login --domain stage.kube-dc.com --org shalb --device-code
+
+  # CI: confidential client with a mounted secret
+  kube-dc login --domain stage.kube-dc.com --org shalb \
+    --client-id ci-deployer --client-secret-file /run/secrets/kube-dc-client
+
+  # GitHub Actions: exchange the workflow's ID token
+  curl -sH "Authorization: bearer $ACTIONS_ID_TOKEN_REQUEST_TOKEN" \
+    "$ACTIONS_ID_TOKEN_REQUEST_URL&audience=kube-dc" | jq -r .value > "$RUNNER_TEMP/id-token"
+  kube-dc login --domain stage.kube-dc.com --org shalb --client-id github-actions \
+    --subject-token-file "$RUNNER_TEMP/id-token" --subject-issuer github
+
   # With CA certificate (for self-hosted)
   kube-dc login --domain internal.example.com --org myorg --ca-cert /path/to/ca.crt`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if admin && org != "" {
 				return fmt.Errorf("--admin and --org are mutually exclusive (admin always uses the master realm)")
 			}
+			saFlags := sa.clientSecretFile != "" || sa.subjectTokenFile != "" || sa.subjectIssuer != ""
+			if saFlags && sa.clientID == "" {
+				return fmt.Errorf("--client-secret-file, --subject-token-file and --subject-issuer require --client-id")
+			}
+			if sa.clientID != "" && (admin || deviceCode) {
+				return fmt.Errorf("--client-id is a non-interactive Organization login and cannot be combined with --admin or --device-code")
+			}
 			// Past flag validation everything that can fail is a RUNTIME problem
 			// (unknown realm, unreachable Keycloak, browser flow timeout). Dumping
 			// the usage block in front of those buries the actual explanation —
@@ -173,6 +214,9 @@ Two identity modes:
 			if admin {
 				return runAdminLogin(domain, caCertFile, insecure, deviceCode)
 			}
+			if sa.clientID != "" {
+				return runServiceAccountLogin(domain, org, caCertFile, insecure, sa)
+			}
 			return runLogin(domain, org, caCertFile, insecure, deviceCode)
 		},
 	}
@@ -182,7 +226,11 @@ Two identity modes:
 	cmd.Flags().BoolVar(&admin, "admin", false, "Login as a platform admin against the master realm (cluster-wide RBAC)")
 	cmd.Flags().StringVar(&caCertFile, "ca-cert", "", "Path to CA certificate file")
 	cmd.Flags().BoolVar(&insecure, "insecure", false, "Skip TLS verification (not recommended)")
-	cmd.Flags().BoolVar(&deviceCode, "device-code", false, "NOT IMPLEMENTED — returns an error. On a headless machine, either run `kube-dc login` on a workstation WITH a browser and copy the resulting ~/.kube/config, or use the client-certificate kubeconfig from `kube-dc bootstrap fetch-kubeconfig` (which needs no browser and no OIDC)")
+	cmd.Flags().BoolVar(&deviceCode, "device-code", false, "Use the OAuth device authorization flow instead of opening a browser (for headless machines)")
+	cmd.Flags().StringVar(&sa.clientID, "client-id", "", "Keycloak client for a non-interactive (CI) login; requires --domain and --org")
+	cmd.Flags().StringVar(&sa.clientSecretFile, "client-secret-file", "", "File holding the client secret (client-credentials grant)")
+	cmd.Flags().StringVar(&sa.subjectTokenFile, "subject-token-file", "", "File holding a federated OIDC JWT to exchange for a realm token (token-exchange grant)")
+	cmd.Flags().StringVar(&sa.subjectIssuer, "subject-issuer", "", "Keycloak identity-provider alias that issued the --subject-token-file token")
 
 	return cmd
 }
@@ -225,14 +273,6 @@ func runLogin(domain, org, caCertFile string, insecure, deviceCode bool) error {
 		fmt.Printf("Using CA certificate from %s\n", caCertFile)
 	}
 
-	if deviceCode {
-		return fmt.Errorf("device code flow is not implemented. " +
-			"On a headless machine use one of these instead:\n" +
-			"  - run `kube-dc login` on a workstation WITH a browser, then copy ~/.kube/config over; or\n" +
-			"  - use the client-certificate kubeconfig from `kube-dc bootstrap fetch-kubeconfig`, " +
-			"which needs neither a browser nor OIDC")
-	}
-
 	fmt.Printf("\n🔐 Logging in to %s (Organization: %s)\n", domain, org)
 	fmt.Printf("   API Server: %s\n", server)
 	fmt.Printf("   Keycloak:   %s\n\n", keycloakURL)
@@ -261,12 +301,7 @@ func runLogin(domain, org, caCertFile string, insecure, deviceCode bool) error {
 		Insecure:    insecure,
 	}
 
-	// Run OAuth flow
-	flow := auth.NewOAuthFlow(oauthConfig)
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-
-	tokenResponse, err := flow.Login(ctx)
+	tokenResponse, err := loginTokens(oauthConfig, deviceCode)
 	if err != nil {
 		return fmt.Errorf("login failed: %w", err)
 	}
@@ -392,6 +427,25 @@ func runLogin(domain, org, caCertFile string, insecure, deviceCode bool) error {
 	return nil
 }
 
+// loginTokens runs the browser PKCE flow, or the RFC 8628 device flow when
+// deviceCode is set. Both yield the same token response, so everything after
+// it — claim checks, the credential cache, kubeconfig contexts — is shared
+// between the two and a device-code login is indistinguishable afterwards.
+//
+// The device flow gets a longer budget: the user is switching to another
+// machine to approve it, and Keycloak's own device-code lifespan (10 minutes
+// by default) ends the poll first anyway.
+func loginTokens(cfg *auth.OAuthConfig, deviceCode bool) (*auth.TokenResponse, error) {
+	if deviceCode {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+		defer cancel()
+		return auth.NewDeviceFlow(cfg).Login(ctx)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	return auth.NewOAuthFlow(cfg).Login(ctx)
+}
+
 // tenantContextParams keeps the security-sensitive relationship between a
 // Project context and its Organization realm in one testable place.
 func tenantContextParams(domain, org, server, namespace, caCertPEM string, insecure, setCurrent bool) kubeconfig.AddContextParams {
@@ -413,117 +467,6 @@ func tenantContextParams(domain, org, server, namespace, caCertPEM string, insec
 	}
 }
 
-func logoutCmd() *cobra.Command {
-	var server string
-	var all bool
-	var removeContexts bool
-
-	cmd := &cobra.Command{
-		Use:   "logout",
-		Short: "Remove cached credentials",
-		Long:  `Remove cached credentials and optionally remove kubeconfig entries.`,
-		Example: `  # Logout from current server
-  kube-dc logout
-
-  # Logout from specific server
-  kube-dc logout --server https://api.kube-dc.cloud
-
-  # Logout from all servers
-  kube-dc logout --all`,
-		RunE: func(cmd *cobra.Command, args []string) error {
-			return runLogout(server, all, removeContexts)
-		},
-	}
-
-	cmd.Flags().StringVar(&server, "server", "", "Kube-DC API server URL")
-	cmd.Flags().BoolVar(&all, "all", false, "Logout from all servers")
-	cmd.Flags().BoolVar(&removeContexts, "remove-contexts", false, "Also remove kubeconfig contexts")
-
-	return cmd
-}
-
-func runLogout(server string, all, removeContexts bool) error {
-	credMgr, err := config.NewCredentialsManager()
-	if err != nil {
-		return fmt.Errorf("failed to initialize credentials manager: %w", err)
-	}
-
-	if all {
-		// Remove all credentials
-		creds, err := credMgr.List()
-		if err != nil {
-			return fmt.Errorf("failed to list credentials: %w", err)
-		}
-
-		if len(creds) == 0 {
-			fmt.Println("No credentials to remove.")
-			return nil
-		}
-
-		for _, c := range creds {
-			if err := credMgr.Delete(c.Server); err != nil {
-				fmt.Printf("Warning: failed to remove credentials for %s: %v\n", c.Server, err)
-			} else {
-				fmt.Printf("Removed credentials for %s\n", c.Server)
-			}
-
-			if removeContexts {
-				kubeMgr, _ := kubeconfig.NewManager()
-				if kubeMgr != nil {
-					kubeMgr.RemoveKubeDCContexts(c.Server)
-				}
-			}
-		}
-		fmt.Println("Logged out from all servers.")
-		return nil
-	}
-
-	// If no server specified, try to get from current context
-	if server == "" {
-		kubeMgr, err := kubeconfig.NewManager()
-		if err == nil {
-			kubeConfig, err := kubeMgr.Load()
-			if err == nil && strings.HasPrefix(kubeConfig.CurrentContext, "kube-dc/") {
-				for _, ctx := range kubeConfig.Contexts {
-					if ctx.Name == kubeConfig.CurrentContext {
-						for _, cluster := range kubeConfig.Clusters {
-							if cluster.Name == ctx.Context.Cluster {
-								server = cluster.Cluster.Server
-								break
-							}
-						}
-						break
-					}
-				}
-			}
-		}
-	}
-
-	if server == "" {
-		return fmt.Errorf("no server specified and no kube-dc context active. Use --server or --all")
-	}
-
-	// Remove credentials for specific server
-	if err := credMgr.Delete(server); err != nil {
-		return fmt.Errorf("failed to remove credentials: %w", err)
-	}
-
-	fmt.Printf("Removed credentials for %s\n", server)
-
-	if removeContexts {
-		kubeMgr, err := kubeconfig.NewManager()
-		if err == nil {
-			if err := kubeMgr.RemoveKubeDCContexts(server); err != nil {
-				fmt.Printf("Warning: failed to remove kubeconfig contexts: %v\n", err)
-			} else {
-				fmt.Println("Removed kubeconfig contexts.")
-			}
-		}
-	}
-
-	return nil
-}
-
 func useCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "use [domain/org/project|context]",
@@ -809,6 +752,7 @@ func configCmd() *cobra.Command {
 
 	cmd.AddCommand(showCmd)
 	cmd.AddCommand(getContextsCmd)
+	cmd.AddCommand(credentialEncryptionCmds()...)
 
 	return cmd
 }
@@ -849,15 +793,21 @@ func runConfigShow() error {
 		fmt.Printf("Error loading credentials: %v\n", err)
 		return nil
 	}
+	fmt.Printf("Encryption: %s\n", encryptionLabel(credMgr))
 
-	creds, err := credMgr.List()
+	creds, unreadable, err := credMgr.List()
 	if err != nil {
 		fmt.Printf("Error listing credentials: %v\n", err)
 		return nil
 	}
 
+	for _, u := range unreadable {
+		fmt.Printf("\nUnreadable: %s\n  %v\n", u.Path, u.Err)
+	}
 	if len(creds) == 0 {
-		fmt.Println("No cached credentials.")
+		if len(unreadable) == 0 {
+			fmt.Println("No cached credentials.")
+		}
 		return nil
 	}
 
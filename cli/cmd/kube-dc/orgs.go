@@ -9,6 +9,8 @@
 //   release    <project>                 → DELETE /elevate
 //   status     <project>                 → GET    /elevate
 //   elevations                            → GET    /elevations  (org-wide)
+//   groups     list|create|bind-project|add-member|remove-member|import
+//                                         → orgs_groups.go
 
 package main
 
@@ -23,7 +25,7 @@ import (
 func orgsCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "orgs",
-		Short: "Manage organization-level controls (elevation, audit)",
+		Short: "Manage organization-level controls (groups, elevation, audit)",
 		Long: `Organization-scoped controls reserved for Organization admins. Use these
 when an admin needs short-lived cross-project access (15-minute
 elevation window) so secret-value reads are explicitly logged with a
@@ -34,6 +36,7 @@ permissions.`,
 	cmd.AddCommand(orgsReleaseCmd())
 	cmd.AddCommand(orgsStatusCmd())
 	cmd.AddCommand(orgsElevationsCmd())
+	cmd.AddCommand(orgsGroupsCmd())
 	return cmd
 }
 
@@ -0,0 +1,890 @@
+// `kube-dc orgs groups` — Organization Groups and their members.
+//
+// Two systems hold the answer to "what may this person do": the
+// OrganizationGroup resource (k8s) says which roles a group holds in
+// which Projects, and the Organization's Keycloak realm says who is in
+// the group. Every verb here works the same way: read both, compute a
+// plan, print it as a diff, and change nothing unless --yes is given.
+//
+// Verbs:
+//   list           — OrganizationGroups and their Project bindings   (k8s)
+//   create         — new OrganizationGroup, optional --bind          (k8s)
+//   bind-project   — grant a group roles in a Project                (k8s)
+//   add-member     — put users into a group                          (keycloak)
+//   remove-member  — take users out of a group                       (keycloak)
+//   import         — bulk groups + bindings + members from CSV       (k8s + keycloak)
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+	"github.com/shalb/kube-dc/cli/internal/keycloak"
+	"github.com/spf13/cobra"
+)
+
+// standardProjectRoles are the role names --role accepts. Custom Roles
+// can still be referenced by editing the OrganizationGroup directly.
+var standardProjectRoles = []string{"user", "developer", "project-manager", "admin"}
+
+// reservedGroups are the built-in realm groups. They can gain and lose
+// members, but an OrganizationGroup may not redefine them.
+var reservedGroups = map[string]bool{"org-admin": true, "user": true}
+
+// groupPollInterval paces the wait for the controller to create the
+// Keycloak group of a new OrganizationGroup. A var so tests can shorten it.
+var groupPollInterval = 2 * time.Second
+
+// groupSyncTimeout bounds that wait.
+const groupSyncTimeout = 2 * time.Minute
+
+func (s *orgScope) keycloak() (*keycloak.AdminClient, error) {
+	if s.kc.Realm != s.Org {
+		return nil, fmt.Errorf("group membership is managed in the %s realm; switch to one of its contexts (kube-dc use) first", s.Org)
+	}
+	c := s.kc.Creds
+	return keycloak.NewAdminClient(c.KeycloakURL, s.Org, c.AccessToken, c.CACert, c.Insecure)
+}
+
+func orgsGroupsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "groups",
+		Short: "Manage Organization Groups, their Project roles and members",
+		Long: `Manage Organization Groups: which roles a group holds in which Projects, and
+who belongs to it.
+
+Commands that change something print the planned changes as a diff and stop.
+Re-run with --yes to apply them. Membership changes take effect in newly
+issued tokens; members sign out and back in to pick them up at once.
+
+Roles: user, developer, project-manager, admin.`,
+	}
+	cmd.AddCommand(orgsGroupsListCmd())
+	cmd.AddCommand(orgsGroupsCreateCmd())
+	cmd.AddCommand(orgsGroupsBindProjectCmd())
+	cmd.AddCommand(orgsGroupsMemberCmd(true))
+	cmd.AddCommand(orgsGroupsMemberCmd(false))
+	cmd.AddCommand(orgsGroupsImportCmd())
+	return cmd
+}
+
+// -------- plan -----------------------------------------------------
+
+// groupPlan is everything a command is about to change.
+type groupPlan struct {
+	Org     string
+	Groups  []groupChange
+	Members []memberChange
+}
+
+// groupChange creates an OrganizationGroup (Create) or replaces its
+// permissions, read at ResourceVersion.
+type groupChange struct {
+	Name            string
+	Create          bool
+	ResourceVersion string
+	Before, After   []k8sapi.GroupPermission
+}
+
+type memberChange struct {
+	Group string
+	User  *keycloak.User
+	Ref   string // as the user was named on the command line / in the CSV
+	Add   bool
+}
+
+func (p *groupPlan) empty() bool { return len(p.Groups) == 0 && len(p.Members) == 0 }
+
+// bindRoles returns perms with roles added to project's entry, creating
+// the entry when the project is not bound yet. perms is not modified.
+func bindRoles(perms []k8sapi.GroupPermission, project string, roles ...string) []k8sapi.GroupPermission {
+	out := make([]k8sapi.GroupPermission, 0, len(perms)+1)
+	found := false
+	for _, p := range perms {
+		p.Roles = append([]string(nil), p.Roles...)
+		if p.Project == project {
+			found = true
+			for _, r := range roles {
+				if !containsString(p.Roles, r) {
+					p.Roles = append(p.Roles, r)
+				}
+			}
+		}
+		out = append(out, p)
+	}
+	if !found {
+		out = append(out, k8sapi.GroupPermission{Project: project, Roles: dedupe(roles)})
+	}
+	return out
+}
+
+func containsString(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func dedupe(list []string) []string {
+	var out []string
+	for _, s := range list {
+		if !containsString(out, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// permissionDiff renders before → after one Project per line:
+// "+ production: developer", "~ staging: user -> user, admin",
+// "- monitoring: project-manager".
+func permissionDiff(before, after []k8sapi.GroupPermission) []string {
+	index := func(perms []k8sapi.GroupPermission) map[string][]string {
+		m := map[string][]string{}
+		for _, p := range perms {
+			m[p.Project] = p.Roles
+		}
+		return m
+	}
+	b, a := index(before), index(after)
+	projects := map[string]bool{}
+	for p := range b {
+		projects[p] = true
+	}
+	for p := range a {
+		projects[p] = true
+	}
+	names := make([]string, 0, len(projects))
+	for p := range projects {
+		names = append(names, p)
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, p := range names {
+		old, hadOld := b[p]
+		cur, hasCur := a[p]
+		switch {
+		case !hadOld:
+			lines = append(lines, fmt.Sprintf("+ %s: %s", p, strings.Join(cur, ", ")))
+		case !hasCur:
+			lines = append(lines, fmt.Sprintf("- %s: %s", p, strings.Join(old, ", ")))
+		case strings.Join(old, ",") != strings.Join(cur, ","):
+			lines = append(lines, fmt.Sprintf("~ %s: %s -> %s", p, strings.Join(old, ", "), strings.Join(cur, ", ")))
+		}
+	}
+	return lines
+}
+
+func printGroupPlan(w io.Writer, p *groupPlan) {
+	if p.empty() {
+		fmt.Fprintln(w, "No changes.")
+		return
+	}
+	for _, g := range p.Groups {
+		if g.Create {
+			fmt.Fprintf(w, "+ group %s/%s\n", p.Org, g.Name)
+		} else {
+			fmt.Fprintf(w, "~ group %s/%s\n", p.Org, g.Name)
+		}
+		for _, line := range permissionDiff(g.Before, g.After) {
+			fmt.Fprintf(w, "    %s\n", line)
+		}
+	}
+	for _, m := range p.Members {
+		sign, verb := "+", "to"
+		if !m.Add {
+			sign, verb = "-", "from"
+		}
+		fmt.Fprintf(w, "%s member %s %s %s\n", sign, memberLabel(m), verb, m.Group)
+	}
+}
+
+func memberLabel(m memberChange) string {
+	if m.User != nil && m.User.Username != "" && !strings.EqualFold(m.User.Username, m.Ref) {
+		return fmt.Sprintf("%s (%s)", m.Ref, m.User.Username)
+	}
+	return m.Ref
+}
+
+// -------- apply ----------------------------------------------------
+
+// applyGroupPlan makes the changes: groups first, then — once the
+// controller has created the Keycloak group of every new one — the
+// membership changes, which need the group to exist in the realm.
+func applyGroupPlan(ctx context.Context, k8s *k8sapi.Client, kc *keycloak.AdminClient, p *groupPlan) error {
+	for _, g := range p.Groups {
+		if g.Create {
+			_, err := k8s.CreateOrganizationGroup(ctx, &k8sapi.OrganizationGroup{
+				Metadata: k8sapi.ObjectMeta{Name: g.Name, Namespace: p.Org},
+				Spec:     k8sapi.OrganizationGroupSpec{Permissions: nonNilPermissions(g.After)},
+			})
+			if err != nil {
+				return fmt.Errorf("create group %s: %w", g.Name, err)
+			}
+			continue
+		}
+		if _, err := k8s.SetOrganizationGroupPermissions(ctx, p.Org, g.Name, g.ResourceVersion, g.After); err != nil {
+			return fmt.Errorf("update group %s: %w", g.Name, err)
+		}
+	}
+
+	groupIDs := map[string]string{}
+	for _, m := range p.Members {
+		id, ok := groupIDs[m.Group]
+		if !ok {
+			g, err := waitForRealmGroup(ctx, kc, m.Group)
+			if err != nil {
+				return err
+			}
+			id = g.ID
+			groupIDs[m.Group] = id
+		}
+		if m.Add {
+			if err := kc.AddToGroup(ctx, m.User.ID, id); err != nil {
+				return fmt.Errorf("add %s to %s: %w", m.Ref, m.Group, err)
+			}
+			continue
+		}
+		if err := kc.RemoveFromGroup(ctx, m.User.ID, id); err != nil {
+			return fmt.Errorf("remove %s from %s: %w", m.Ref, m.Group, err)
+		}
+	}
+	return nil
+}
+
+func nonNilPermissions(perms []k8sapi.GroupPermission) []k8sapi.GroupPermission {
+	if perms == nil {
+		return []k8sapi.GroupPermission{}
+	}
+	return perms
+}
+
+// waitForRealmGroup returns the Keycloak group called name, polling
+// while the OrganizationGroup controller creates it.
+func waitForRealmGroup(ctx context.Context, kc *keycloak.AdminClient, name string) (*keycloak.Group, error) {
+	deadline := time.Now().Add(groupSyncTimeout)
+	for {
+		g, err := kc.FindGroup(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if g != nil {
+			return g, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("group %s does not exist in the %s realm (is its OrganizationGroup reconciled?)", name, kc.Realm)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(groupPollInterval):
+		}
+	}
+}
+
+// confirmOrPreview prints the plan and applies it only with --yes.
+func confirmOrPreview(scope *orgScope, p *groupPlan, yes bool) error {
+	printGroupPlan(os.Stdout, p)
+	if p.empty() {
+		return nil
+	}
+	if !yes {
+		fmt.Println("\nPreview only; re-run with --yes to apply.")
+		return nil
+	}
+	k8s, err := scope.k8s()
+	if err != nil {
+		return err
+	}
+	var kc *keycloak.AdminClient
+	if len(p.Members) > 0 {
+		if kc, err = scope.keycloak(); err != nil {
+			return err
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), groupSyncTimeout+time.Minute)
+	defer cancel()
+	if err := applyGroupPlan(ctx, k8s, kc, p); err != nil {
+		return err
+	}
+	fmt.Printf("\nApplied: %d group change(s), %d membership change(s).\n", len(p.Groups), len(p.Members))
+	if len(p.Members) > 0 {
+		fmt.Println("Members pick up new groups in their next token; sign out and back in to apply at once.")
+	}
+	return nil
+}
+
+// -------- validation -----------------------------------------------
+
+func validateGroupName(name string) error {
+	if !projectNameRE.MatchString(name) {
+		return fmt.Errorf("invalid group name %q: use lowercase letters, digits and '-'", name)
+	}
+	return nil
+}
+
+func validateRole(role string) error {
+	if !containsString(standardProjectRoles, role) {
+		return fmt.Errorf("invalid role %q (want one of: %s)", role, strings.Join(standardProjectRoles, ", "))
+	}
+	return nil
+}
+
+// parseBinding parses a --bind value, "<project>=<role>".
+func parseBinding(s string) (project, role string, err error) {
+	project, role, ok := strings.Cut(s, "=")
+	if !ok || project == "" || role == "" {
+		return "", "", fmt.Errorf("invalid --bind %q (want <project>=<role>)", s)
+	}
+	return project, role, validateRole(role)
+}
+
+// checkProjectsExist fails for bindings to Projects that do not exist —
+// the controller would silently skip them, leaving a grant that never
+// takes effect.
+func checkProjectsExist(ctx context.Context, k8s *k8sapi.Client, org string, p *groupPlan) error {
+	seen := map[string]bool{}
+	var missing []string
+	for _, g := range p.Groups {
+		for _, perm := range g.After {
+			if seen[perm.Project] {
+				continue
+			}
+			seen[perm.Project] = true
+			if _, err := k8s.GetProject(ctx, org, perm.Project); err != nil {
+				if !k8sapi.IsNotFound(err) {
+					return err
+				}
+				missing = append(missing, perm.Project)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("no such Project in %s: %s", org, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// -------- list -----------------------------------------------------
+
+func orgsGroupsListCmd() *cobra.Command {
+	var org, outFlag string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List Organization Groups and their Project roles",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveOrgScope(org)
+			if err != nil {
+				return err
+			}
+			k8s, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			list, err := k8s.ListOrganizationGroups(ctx, scope.Org)
+			if err != nil {
+				return err
+			}
+			if out != outTable {
+				return printSerialized(out, list)
+			}
+			if len(list.Items) == 0 {
+				fmt.Println("No Organization Groups in", scope.Org)
+				return nil
+			}
+			sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Metadata.Name < list.Items[j].Metadata.Name })
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "GROUP\tPROJECT\tROLES")
+			for _, g := range list.Items {
+				if len(g.Spec.Permissions) == 0 {
+					fmt.Fprintf(w, "%s\t-\t-\n", g.Metadata.Name)
+				}
+				for i, p := range g.Spec.Permissions {
+					name := g.Metadata.Name
+					if i > 0 {
+						name = ""
+					}
+					fmt.Fprintf(w, "%s\t%s\t%s\n", name, p.Project, strings.Join(p.Roles, ", "))
+				}
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().StringVar(&org, "org", "", "Organization (default: current context's Organization)")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+// -------- create ---------------------------------------------------
+
+func orgsGroupsCreateCmd() *cobra.Command {
+	var org string
+	var binds []string
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "create <group>",
+		Short: "Create an Organization Group",
+		Example: `  kube-dc orgs groups create backend-team --bind production=developer --bind staging=admin
+  kube-dc orgs groups create backend-team --bind production=developer --yes`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if err := validateGroupName(name); err != nil {
+				return err
+			}
+			if reservedGroups[name] {
+				return fmt.Errorf("%s is a built-in group and cannot be redefined", name)
+			}
+			var perms []k8sapi.GroupPermission
+			for _, b := range binds {
+				project, role, err := parseBinding(b)
+				if err != nil {
+					return err
+				}
+				perms = bindRoles(perms, project, role)
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveOrgScope(org)
+			if err != nil {
+				return err
+			}
+			k8s, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			if _, err := k8s.GetOrganizationGroup(ctx, scope.Org, name); err == nil {
+				return fmt.Errorf("group %s already exists in %s; use bind-project to change it", name, scope.Org)
+			} else if !k8sapi.IsNotFound(err) {
+				return err
+			}
+			plan := &groupPlan{Org: scope.Org, Groups: []groupChange{{Name: name, Create: true, After: perms}}}
+			if err := checkProjectsExist(ctx, k8s, scope.Org, plan); err != nil {
+				return err
+			}
+			return confirmOrPreview(scope, plan, yes)
+		},
+	}
+	cmd.Flags().StringVar(&org, "org", "", "Organization (default: current context's Organization)")
+	cmd.Flags().StringArrayVar(&binds, "bind", nil, "Grant a role in a Project, as <project>=<role> (repeatable)")
+	cmd.Flags().BoolVar(&yes, "yes", false, "Apply the changes; without it, only preview them")
+	return cmd
+}
+
+// -------- bind-project ---------------------------------------------
+
+func orgsGroupsBindProjectCmd() *cobra.Command {
+	var org string
+	var roles []string
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "bind-project <group> <project>",
+		Short: "Grant a group roles in a Project",
+		Long: `Grant a group one or more roles in a Project. Roles the group already holds
+there are kept.`,
+		Example: `  kube-dc orgs groups bind-project backend-team production --role developer
+  kube-dc orgs groups bind-project backend-team staging --role admin --yes`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, project := args[0], args[1]
+			if len(roles) == 0 {
+				return fmt.Errorf("--role is required")
+			}
+			for _, r := range roles {
+				if err := validateRole(r); err != nil {
+					return err
+				}
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveOrgScope(org)
+			if err != nil {
+				return err
+			}
+			k8s, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			g, err := k8s.GetOrganizationGroup(ctx, scope.Org, name)
+			if err != nil {
+				return err
+			}
+			after := bindRoles(g.Spec.Permissions, project, roles...)
+			plan := &groupPlan{Org: scope.Org}
+			if len(permissionDiff(g.Spec.Permissions, after)) > 0 {
+				plan.Groups = []groupChange{{Name: name, ResourceVersion: g.Metadata.ResourceVersion, Before: g.Spec.Permissions, After: after}}
+			}
+			if err := checkProjectsExist(ctx, k8s, scope.Org, plan); err != nil {
+				return err
+			}
+			return confirmOrPreview(scope, plan, yes)
+		},
+	}
+	cmd.Flags().StringVar(&org, "org", "", "Organization (default: current context's Organization)")
+	cmd.Flags().StringArrayVar(&roles, "role", nil, "Role to grant: user|developer|project-manager|admin (repeatable)")
+	cmd.Flags().BoolVar(&yes, "yes", false, "Apply the changes; without it, only preview them")
+	return cmd
+}
+
+// -------- add-member / remove-member -------------------------------
+
+func orgsGroupsMemberCmd(add bool) *cobra.Command {
+	var org string
+	var yes bool
+	use, short := "add-member", "Add users to a group"
+	if !add {
+		use, short = "remove-member", "Remove users from a group"
+	}
+	cmd := &cobra.Command{
+		Use:   use + " <group> <user>...",
+		Short: short,
+		Long: short + `. Users are named by username, or by email address.
+Built-in groups (org-admin, user) can be used as well.`,
+		Example: fmt.Sprintf("  kube-dc orgs groups %s backend-team alice bob@example.com\n  kube-dc orgs groups %s backend-team alice --yes", use, use),
+		Args:    cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, refs := args[0], args[1:]
+			cmd.SilenceUsage = true
+			scope, err := resolveOrgScope(org)
+			if err != nil {
+				return err
+			}
+			kc, err := scope.keycloak()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			group, err := kc.FindGroup(ctx, name)
+			if err != nil {
+				return err
+			}
+			if group == nil {
+				return fmt.Errorf("no group %s in the %s realm", name, scope.Org)
+			}
+			users, err := resolveUsers(ctx, kc, refs)
+			if err != nil {
+				return err
+			}
+			current, err := memberIDs(ctx, kc, group.ID)
+			if err != nil {
+				return err
+			}
+			plan := &groupPlan{Org: scope.Org}
+			for _, ref := range refs {
+				u := users[strings.ToLower(ref)]
+				if current[u.ID] == add {
+					continue
+				}
+				current[u.ID] = add
+				plan.Members = append(plan.Members, memberChange{Group: name, User: u, Ref: ref, Add: add})
+			}
+			return confirmOrPreview(scope, plan, yes)
+		},
+	}
+	cmd.Flags().StringVar(&org, "org", "", "Organization (default: current context's Organization)")
+	cmd.Flags().BoolVar(&yes, "yes", false, "Apply the changes; without it, only preview them")
+	return cmd
+}
+
+// resolveUsers looks every reference up once, keyed by its lower-cased
+// form. Unknown users are reported together, not one per run.
+func resolveUsers(ctx context.Context, kc *keycloak.AdminClient, refs []string) (map[string]*keycloak.User, error) {
+	users := map[string]*keycloak.User{}
+	var unknown []string
+	for _, ref := range refs {
+		key := strings.ToLower(ref)
+		if _, ok := users[key]; ok {
+			continue
+		}
+		u, err := kc.FindUser(ctx, ref)
+		if err != nil {
+			var apiErr *keycloak.APIError
+			if errors.As(err, &apiErr) {
+				return nil, err
+			}
+			unknown = append(unknown, ref)
+			continue
+		}
+		users[key] = u
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown user(s) in %s: %s (create them first in Manage Organization > Users)", kc.Realm, strings.Join(unknown, ", "))
+	}
+	return users, nil
+}
+
+func memberIDs(ctx context.Context, kc *keycloak.AdminClient, groupID string) (map[string]bool, error) {
+	members, err := kc.GroupMembers(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	ids := map[string]bool{}
+	for _, m := range members {
+		ids[m.ID] = true
+	}
+	return ids, nil
+}
+
+// -------- import ---------------------------------------------------
+
+// importRow is one CSV line: a member of a group and, optionally, a
+// role that group holds in a Project.
+type importRow struct {
+	Line                       int
+	User, Group, Project, Role string
+}
+
+// parseImportCSV reads the onboarding CSV. The header names the
+// columns — user and group are required, project and role optional —
+// so spreadsheets can carry extra columns. A row may leave user empty
+// to only bind a Project, or leave project and role empty to only add
+// a member.
+func parseImportCSV(r io.Reader) ([]importRow, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+	cr.Comment = '#'
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read CSV header: %w", err)
+	}
+	col := map[string]int{}
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, required := range []string{"user", "group"} {
+		if _, ok := col[required]; !ok {
+			return nil, fmt.Errorf("CSV header must have a %q column (columns: user, group, project, role)", required)
+		}
+	}
+	field := func(rec []string, name string) string {
+		i, ok := col[name]
+		if !ok || i >= len(rec) {
+			return ""
+		}
+		return strings.TrimSpace(rec[i])
+	}
+
+	var rows []importRow
+	var problems []string
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := cr.FieldPos(0)
+		if err != nil {
+			return nil, fmt.Errorf("read CSV: %w", err)
+		}
+		row := importRow{Line: line, User: field(rec, "user"), Group: field(rec, "group"), Project: field(rec, "project"), Role: field(rec, "role")}
+		if row.User == "" && row.Group == "" && row.Project == "" && row.Role == "" {
+			continue
+		}
+		if err := validateImportRow(row); err != nil {
+			problems = append(problems, fmt.Sprintf("line %d: %v", line, err))
+			continue
+		}
+		rows = append(rows, row)
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid CSV:\n  %s", strings.Join(problems, "\n  "))
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("CSV has no rows")
+	}
+	return rows, nil
+}
+
+func validateImportRow(r importRow) error {
+	if err := validateGroupName(r.Group); err != nil {
+		return err
+	}
+	if (r.Project == "") != (r.Role == "") {
+		return fmt.Errorf("project and role go together")
+	}
+	if r.Role != "" {
+		if reservedGroups[r.Group] {
+			return fmt.Errorf("%s is a built-in group; its Project roles cannot be changed", r.Group)
+		}
+		if err := validateRole(r.Role); err != nil {
+			return err
+		}
+	}
+	if r.User == "" && r.Role == "" {
+		return fmt.Errorf("row has neither a user nor a project role")
+	}
+	return nil
+}
+
+// buildImportPlan turns rows into a plan against the current state:
+// groups are the existing OrganizationGroups by name, users the
+// resolved CSV users by lower-cased reference, members the current
+// member IDs of each existing realm group. Pure.
+func buildImportPlan(org string, rows []importRow, groups map[string]*k8sapi.OrganizationGroup, users map[string]*keycloak.User, members map[string]map[string]bool) *groupPlan {
+	plan := &groupPlan{Org: org}
+	changes := map[string]*groupChange{}
+	var order []string
+	change := func(name string) *groupChange {
+		if c, ok := changes[name]; ok {
+			return c
+		}
+		c := &groupChange{Name: name, Create: true}
+		if g, ok := groups[name]; ok {
+			c.Create = false
+			c.ResourceVersion = g.Metadata.ResourceVersion
+			c.Before = g.Spec.Permissions
+		}
+		c.After = c.Before
+		changes[name] = c
+		order = append(order, name)
+		return c
+	}
+
+	added := map[string]bool{}
+	for _, r := range rows {
+		if !reservedGroups[r.Group] {
+			c := change(r.Group)
+			if r.Role != "" {
+				c.After = bindRoles(c.After, r.Project, r.Role)
+			}
+		}
+		if r.User == "" {
+			continue
+		}
+		u := users[strings.ToLower(r.User)]
+		key := r.Group + "\x00" + u.ID
+		if added[key] || members[r.Group][u.ID] {
+			continue
+		}
+		added[key] = true
+		plan.Members = append(plan.Members, memberChange{Group: r.Group, User: u, Ref: r.User, Add: true})
+	}
+	for _, name := range order {
+		c := changes[name]
+		if c.Create || len(permissionDiff(c.Before, c.After)) > 0 {
+			plan.Groups = append(plan.Groups, *c)
+		}
+	}
+	return plan
+}
+
+func orgsGroupsImportCmd() *cobra.Command {
+	var org string
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "import <file.csv>",
+		Short: "Onboard a team from a CSV of users, groups and Project roles",
+		Long: `Create groups, grant them Project roles and add their members from one CSV.
+
+The header row names the columns: user and group are required, project and
+role are optional. Each row adds the user to the group and, when project and
+role are set, grants the group that role in the Project. Missing groups are
+created. Users must already exist in the Organization and are named by
+username or email address. The whole file is validated, and every user is
+resolved, before anything is changed.
+
+  user,group,project,role
+  alice,backend-team,production,developer
+  bob@example.com,backend-team,,
+  ,backend-team,staging,admin
+  carol,frontend-team,web,developer`,
+		Example: `  kube-dc orgs groups import team.csv
+  kube-dc orgs groups import team.csv --yes`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			rows, err := parseImportCSV(f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveOrgScope(org)
+			if err != nil {
+				return err
+			}
+			k8s, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			kc, err := scope.keycloak()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			defer cancel()
+
+			list, err := k8s.ListOrganizationGroups(ctx, scope.Org)
+			if err != nil {
+				return err
+			}
+			groups := map[string]*k8sapi.OrganizationGroup{}
+			for i := range list.Items {
+				groups[list.Items[i].Metadata.Name] = &list.Items[i]
+			}
+			var refs []string
+			members := map[string]map[string]bool{}
+			for _, r := range rows {
+				if r.User != "" {
+					refs = append(refs, r.User)
+				}
+				if _, seen := members[r.Group]; seen {
+					continue
+				}
+				members[r.Group] = map[string]bool{}
+				g, err := kc.FindGroup(ctx, r.Group)
+				if err != nil {
+					return err
+				}
+				if g == nil {
+					if _, declared := groups[r.Group]; !declared && reservedGroups[r.Group] {
+						return fmt.Errorf("no group %s in the %s realm", r.Group, scope.Org)
+					}
+					continue
+				}
+				if members[r.Group], err = memberIDs(ctx, kc, g.ID); err != nil {
+					return err
+				}
+			}
+			users, err := resolveUsers(ctx, kc, refs)
+			if err != nil {
+				return err
+			}
+			plan := buildImportPlan(scope.Org, rows, groups, users, members)
+			if err := checkProjectsExist(ctx, k8s, scope.Org, plan); err != nil {
+				return err
+			}
+			return confirmOrPreview(scope, plan, yes)
+		},
+	}
+	cmd.Flags().StringVar(&org, "org", "", "Organization (default: current context's Organization)")
+	cmd.Flags().BoolVar(&yes, "yes", false, "Apply the changes; without it, only preview them")
+	return cmd
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+	"github.com/shalb/kube-dc/cli/internal/keycloak"
+)
+
+func TestParseImportCSV(t *testing.T) {
+	rows, err := parseImportCSV(strings.NewReader(`User, Group, Project, Role, Notes
+alice,backend-team,production,developer,lead
+bob@example.com,backend-team,,
+# staging access for the whole team
+,backend-team,staging,admin
+
+carol,org-admin,,
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("rows = %+v", rows)
+	}
+	if r := rows[0]; r.User != "alice" || r.Group != "backend-team" || r.Project != "production" || r.Role != "developer" || r.Line != 2 {
+		t.Errorf("row 0 = %+v", r)
+	}
+	if r := rows[2]; r.User != "" || r.Project != "staging" || r.Line != 5 {
+		t.Errorf("row 2 = %+v", r)
+	}
+
+	// Every bad row is reported, with its line number.
+	_, err = parseImportCSV(strings.NewReader(`user,group,project,role
+alice,Backend,,
+bob,backend-team,production,
+carol,backend-team,production,owner
+,backend-team,,
+dave,user,web,developer
+`))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"line 2:", "line 3: project and role", "line 4: invalid role", "line 5: row has neither", "line 6: user is a built-in group"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error lacks %q:\n%v", want, err)
+		}
+	}
+
+	if _, err := parseImportCSV(strings.NewReader("name,team\nalice,backend\n")); err == nil || !strings.Contains(err.Error(), `"user" column`) {
+		t.Errorf("missing columns: %v", err)
+	}
+}
+
+func TestBindRoles(t *testing.T) {
+	before := []k8sapi.GroupPermission{{Project: "web", Roles: []string{"user"}}}
+	after := bindRoles(before, "web", "admin", "user")
+	after = bindRoles(after, "api", "developer", "developer")
+
+	if strings.Join(before[0].Roles, ",") != "user" {
+		t.Errorf("input modified: %+v", before)
+	}
+	got, _ := json.Marshal(after)
+	if string(got) != `[{"project":"web","roles":["user","admin"]},{"project":"api","roles":["developer"]}]` {
+		t.Errorf("after = %s", got)
+	}
+	if lines := permissionDiff(before, bindRoles(before, "web", "user")); len(lines) != 0 {
+		t.Errorf("re-binding a held role should be a no-op, got %v", lines)
+	}
+}
+
+func TestBuildImportPlan(t *testing.T) {
+	rows := []importRow{
+		{User: "alice", Group: "backend-team", Project: "production", Role: "developer"},
+		{User: "Bob@Example.com", Group: "backend-team"},
+		{User: "alice", Group: "backend-team", Project: "staging", Role: "admin"},
+		{User: "carol", Group: "frontend-team", Project: "web", Role: "user"},
+		{User: "carol", Group: "org-admin"},
+		{User: "alice", Group: "ops"},
+	}
+	groups := map[string]*k8sapi.OrganizationGroup{
+		"backend-team": {
+			Metadata: k8sapi.ObjectMeta{Name: "backend-team", ResourceVersion: "41"},
+			Spec:     k8sapi.OrganizationGroupSpec{Permissions: []k8sapi.GroupPermission{{Project: "production", Roles: []string{"developer"}}}},
+		},
+		"ops": {Metadata: k8sapi.ObjectMeta{Name: "ops", ResourceVersion: "7"}},
+	}
+	users := map[string]*keycloak.User{
+		"alice":           {ID: "u-alice", Username: "alice"},
+		"bob@example.com": {ID: "u-bob", Username: "bob"},
+		"carol":           {ID: "u-carol", Username: "carol"},
+	}
+	members := map[string]map[string]bool{
+		"backend-team": {"u-alice": true},
+		"org-admin":    {},
+		"ops":          {},
+	}
+
+	plan := buildImportPlan("acme", rows, groups, users, members)
+	var out bytes.Buffer
+	printGroupPlan(&out, plan)
+	want := `~ group acme/backend-team
+    + staging: admin
++ group acme/frontend-team
+    + web: user
++ member Bob@Example.com (bob) to backend-team
++ member carol to frontend-team
++ member carol to org-admin
++ member alice to ops
+`
+	if out.String() != want {
+		t.Errorf("plan:\n%s\nwant:\n%s", out.String(), want)
+	}
+	if g := plan.Groups[0]; g.Create || g.ResourceVersion != "41" {
+		t.Errorf("backend-team change = %+v", g)
+	}
+}
+
+// fakeOrgAPI stands in for both the kube-apiserver (OrganizationGroups,
+// Projects) and the realm's Keycloak Admin API. Groups created through
+// the k8s side appear in Keycloak after realmDelay GETs, as they would
+// once the controller reconciles.
+type fakeOrgAPI struct {
+	mu         sync.Mutex
+	realmDelay int
+	realmGets  int
+	created    []k8sapi.OrganizationGroup
+	patches    []string
+	realm      map[string]string // group name -> id
+	members    map[string][]string
+}
+
+func (f *fakeOrgAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	const k8s = "/apis/kube-dc.com/v1/namespaces/acme/organizationgroups"
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == k8s:
+		var g k8sapi.OrganizationGroup
+		_ = json.NewDecoder(r.Body).Decode(&g)
+		f.created = append(f.created, g)
+		_ = json.NewEncoder(w).Encode(g)
+	case r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, k8s+"/"):
+		b, _ := io.ReadAll(r.Body)
+		f.patches = append(f.patches, strings.TrimPrefix(r.URL.Path, k8s+"/")+" "+string(b))
+		_, _ = w.Write([]byte("{}"))
+	case r.Method == http.MethodGet && r.URL.Path == "/admin/realms/acme/groups":
+		name := r.URL.Query().Get("search")
+		var groups []keycloak.Group
+		if id, ok := f.realm[name]; ok {
+			groups = append(groups, keycloak.Group{ID: id, Name: name, Path: "/" + name})
+		} else if f.realmGets++; f.realmGets >= f.realmDelay {
+			f.realm[name] = "g-" + name
+		}
+		_ = json.NewEncoder(w).Encode(groups)
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/admin/realms/acme/users/"):
+		parts := strings.Split(r.URL.Path, "/")
+		f.members[parts[7]] = append(f.members[parts[7]], parts[5])
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, r.Method+" "+r.URL.Path, http.StatusNotFound)
+	}
+}
+
+func TestApplyGroupPlan(t *testing.T) {
+	prev := groupPollInterval
+	groupPollInterval = 5 * time.Millisecond
+	t.Cleanup(func() { groupPollInterval = prev })
+
+	api := &fakeOrgAPI{realmDelay: 3, realm: map[string]string{"backend-team": "g-backend"}, members: map[string][]string{}}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	k8s, _ := k8sapi.New(srv.URL, "token", "", false)
+	kc, _ := keycloak.NewAdminClient(srv.URL, "acme", "token", "", false)
+
+	plan := &groupPlan{
+		Org: "acme",
+		Groups: []groupChange{
+			{Name: "frontend-team", Create: true, After: []k8sapi.GroupPermission{{Project: "web", Roles: []string{"user"}}}},
+			{Name: "backend-team", ResourceVersion: "41", After: []k8sapi.GroupPermission{{Project: "staging", Roles: []string{"admin"}}}},
+		},
+		Members: []memberChange{
+			{Group: "frontend-team", User: &keycloak.User{ID: "u-carol"}, Ref: "carol", Add: true},
+			{Group: "backend-team", User: &keycloak.User{ID: "u-bob"}, Ref: "bob", Add: true},
+			{Group: "frontend-team", User: &keycloak.User{ID: "u-dave"}, Ref: "dave", Add: true},
+		},
+	}
+	if err := applyGroupPlan(context.Background(), k8s, kc, plan); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.created) != 1 || api.created[0].Metadata.Name != "frontend-team" || api.created[0].Kind != "OrganizationGroup" {
+		t.Errorf("created = %+v", api.created)
+	}
+	if len(api.patches) != 1 || api.patches[0] != `backend-team {"metadata":{"resourceVersion":"41"},"spec":{"permissions":[{"project":"staging","roles":["admin"]}]}}` {
+		t.Errorf("patches = %q", api.patches)
+	}
+	if got := strings.Join(api.members["g-frontend-team"], ","); got != "u-carol,u-dave" {
+		t.Errorf("frontend-team members = %s", got)
+	}
+	if got := strings.Join(api.members["g-backend"], ","); got != "u-bob" {
+		t.Errorf("backend-team members = %s", got)
+	}
+}
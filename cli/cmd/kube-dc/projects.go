@@ -0,0 +1,583 @@
+// `kube-dc projects` — Project lifecycle on top of the kube-dc.com/v1
+// Project resource. Projects live in the Organization's API namespace
+// (the namespace named after the Organization), so every verb is
+// scoped by Organization, not by the current Project. All calls go
+// straight to the kube-apiserver with the user's Keycloak JWT; RBAC on
+// projects.kube-dc.com is the only gate.
+//
+// Verbs:
+//   list      — GET Projects in the Organization                    (k8s)
+//   create    — POST a Project; waits for Ready, adds the context   (k8s)
+//   describe  — one Project: network, quota usage, conditions       (k8s)
+//   delete    — DELETE the Project; the controller tears it down    (k8s)
+//   wait      — poll until Ready, or until the Project is gone      (k8s)
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+	"github.com/shalb/kube-dc/cli/internal/kubeconfig"
+	"github.com/spf13/cobra"
+)
+
+// projectPollInterval is how often create/delete/wait re-read the
+// Project. A var so tests can shorten it.
+var projectPollInterval = 3 * time.Second
+
+// projectNameRE is the DNS-label rule the backing namespace
+// <org>-<project> has to satisfy; checked client-side so a bad name
+// fails before the round-trip.
+var projectNameRE = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// orgScope is the Organization a command acts on plus the context
+// whose credentials are used to reach the kube-apiserver.
+type orgScope struct {
+	Org string
+	kc  *kubeDCContext
+}
+
+func resolveOrgScope(orgFlag string) (*orgScope, error) {
+	kc, err := resolveKubeDCContext()
+	if err != nil {
+		return nil, err
+	}
+	org := orgFlag
+	if org == "" {
+		if kc.Realm == adminRealm || kc.Realm == "" {
+			return nil, fmt.Errorf("admin context has no Organization; pass --org <organization>")
+		}
+		org = kc.Realm
+	}
+	return &orgScope{Org: org, kc: kc}, nil
+}
+
+func (s *orgScope) k8s() (*k8sapi.Client, error) {
+	return k8sapi.New(s.kc.APIServer, s.kc.Creds.AccessToken, s.kc.CACert, s.kc.Insecure)
+}
+
+func projectsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "projects",
+		Aliases: []string{"project"},
+		Short:   "Manage the Projects of an Organization",
+		Long: `Create, list, inspect and delete Projects in an Organization.
+
+The Organization defaults to the one of the current context; from the admin
+context pass --org. Project quota is platform-managed: describe shows the
+current usage against it, but it is not set here.`,
+	}
+	cmd.AddCommand(projectsListCmd())
+	cmd.AddCommand(projectsCreateCmd())
+	cmd.AddCommand(projectsDescribeCmd())
+	cmd.AddCommand(projectsDeleteCmd())
+	cmd.AddCommand(projectsWaitCmd())
+	return cmd
+}
+
+// -------- list -----------------------------------------------------
+
+func projectsListCmd() *cobra.Command {
+	var org, outFlag string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List Projects in the Organization",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveOrgScope(org)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			list, err := cli.ListProjects(ctx, scope.Org)
+			if err != nil {
+				return err
+			}
+			if out != outTable {
+				return printSerialized(out, list)
+			}
+			return printProjectsTable(scope.Org, list.Items)
+		},
+	}
+	cmd.Flags().StringVar(&org, "org", "", "Organization (default: current context's Organization)")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+// -------- create ---------------------------------------------------
+
+// projectOpts is the parsed flag set for `projects create`.
+type projectOpts struct {
+	Name, Org, CIDR, Network, GwLanIP string
+}
+
+// buildProject assembles and validates the Project payload. Pure — no
+// I/O — so the validation is table-testable.
+func buildProject(opts projectOpts) (*k8sapi.Project, error) {
+	if !projectNameRE.MatchString(opts.Name) {
+		return nil, fmt.Errorf("invalid Project name %q: use lowercase letters, digits and '-', starting and ending with a letter or digit", opts.Name)
+	}
+	if len(opts.Org)+1+len(opts.Name) > 63 {
+		return nil, fmt.Errorf("Project name %q is too long: the backing namespace %s-%s must fit in 63 characters", opts.Name, opts.Org, opts.Name)
+	}
+	switch opts.Network {
+	case k8sapi.EgressNetworkCloud, k8sapi.EgressNetworkPublic:
+	default:
+		return nil, fmt.Errorf("invalid --network %q (want cloud or public)", opts.Network)
+	}
+	if opts.CIDR == "" {
+		return nil, fmt.Errorf("--cidr is required: pick a private subnet that does not overlap networks the Project must reach")
+	}
+	if _, _, err := net.ParseCIDR(opts.CIDR); err != nil {
+		return nil, fmt.Errorf("invalid --cidr %q: %w", opts.CIDR, err)
+	}
+	if opts.GwLanIP != "" && net.ParseIP(opts.GwLanIP) == nil {
+		return nil, fmt.Errorf("invalid --gw-lan-ip %q", opts.GwLanIP)
+	}
+	return &k8sapi.Project{
+		Metadata: k8sapi.ObjectMeta{Name: opts.Name, Namespace: opts.Org},
+		Spec: k8sapi.ProjectSpec{
+			CIDRBlock:         opts.CIDR,
+			EgressNetworkType: opts.Network,
+			GwLanIP:           opts.GwLanIP,
+		},
+	}, nil
+}
+
+func projectsCreateCmd() *cobra.Command {
+	var opts projectOpts
+	var outFlag string
+	var noWait bool
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create a Project and wait for it to become Ready",
+		Long: `Create a Project in the Organization.
+
+--network selects spec.egressNetworkType and cannot be changed later:
+  cloud    the gateway uses the cloud-internal network (default)
+  public   the gateway gets a public address; only where the platform allows it
+
+Once the Project is Ready its kubeconfig context is added, so it can be
+selected with 'kube-dc use' straight away.`,
+		Example: `  kube-dc projects create web --cidr 10.20.0.0/16
+  kube-dc projects create edge --cidr 10.30.0.0/16 --network public
+  kube-dc projects create batch --cidr 10.40.0.0/16 --no-wait`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			opts.Name = args[0]
+			scope, err := resolveOrgScope(opts.Org)
+			if err != nil {
+				return err
+			}
+			opts.Org = scope.Org
+			p, err := buildProject(opts)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			created, err := cli.CreateProject(ctx, p)
+			cancel()
+			if err != nil {
+				return err
+			}
+			if noWait {
+				if out != outTable {
+					return printSerialized(out, created)
+				}
+				fmt.Printf("Created Project %s/%s (not waiting for Ready; run `kube-dc projects wait %s`)\n", scope.Org, opts.Name, opts.Name)
+				return nil
+			}
+
+			if out == outTable {
+				fmt.Printf("Created Project %s/%s; waiting for it to become Ready...\n", scope.Org, opts.Name)
+			}
+			ready, err := waitForProject(context.Background(), cli, scope.Org, opts.Name, false, timeout)
+			if err != nil {
+				return err
+			}
+			contextName, cerr := addProjectContext(scope, ready)
+			if out != outTable {
+				if cerr != nil {
+					fmt.Fprintf(os.Stderr, "Warning: %v\n", cerr)
+				}
+				return printSerialized(out, ready)
+			}
+			fmt.Printf("Project %s is Ready (backing namespace %s)\n", opts.Name, ready.Status.Namespace)
+			switch {
+			case cerr != nil:
+				fmt.Printf("Warning: %v\n", cerr)
+			case contextName != "":
+				fmt.Printf("Added context %s\nSwitch to it with: kube-dc use %s\n", contextName, strings.TrimPrefix(contextName, "kube-dc/"))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&opts.Org, "org", "", "Organization (default: current context's Organization)")
+	cmd.Flags().StringVar(&opts.CIDR, "cidr", "", "Project VPC subnet, e.g. 10.20.0.0/16 (required)")
+	cmd.Flags().StringVar(&opts.Network, "network", k8sapi.EgressNetworkCloud, "Egress network type: cloud|public (immutable)")
+	cmd.Flags().StringVar(&opts.GwLanIP, "gw-lan-ip", "", "Gateway LAN address (default: chosen by the platform)")
+	cmd.Flags().BoolVar(&noWait, "no-wait", false, "Return after the Project is accepted instead of waiting for Ready")
+	cmd.Flags().DurationVar(&timeout, "timeout", 10*time.Minute, "How long to wait for Ready")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+// addProjectContext writes the kubeconfig context for a Ready Project.
+// Only a tenant context's credentials can drive the new context — the
+// admin realm's token is not valid for the Organization's realm — so
+// from the admin context nothing is added and the name is "".
+func addProjectContext(scope *orgScope, p *k8sapi.Project) (string, error) {
+	kc := scope.kc
+	if kc.Realm != scope.Org || p.Status.Namespace == "" {
+		return "", nil
+	}
+	kubeMgr, err := kubeconfig.NewManager()
+	if err != nil {
+		return "", fmt.Errorf("add kubeconfig context: %w", err)
+	}
+	params := tenantContextParams(kc.Domain, scope.Org, kc.APIServer, p.Status.Namespace, kc.CACert, kc.Insecure, false)
+	if err := kubeMgr.AddKubeDCContext(params); err != nil {
+		return "", fmt.Errorf("add kubeconfig context: %w", err)
+	}
+	return params.ContextName, nil
+}
+
+// -------- describe -------------------------------------------------
+
+func projectsDescribeCmd() *cobra.Command {
+	var org, outFlag string
+	cmd := &cobra.Command{
+		Use:     "describe <name>",
+		Aliases: []string{"get", "show"},
+		Short:   "Show a Project's network, quota usage and conditions",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveOrgScope(org)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			p, err := cli.GetProject(ctx, scope.Org, args[0])
+			if err != nil {
+				return err
+			}
+			if out != outTable {
+				return printSerialized(out, p)
+			}
+			printProjectDetail(p)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&org, "org", "", "Organization (default: current context's Organization)")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+// -------- delete ---------------------------------------------------
+
+func projectsDeleteCmd() *cobra.Command {
+	var org string
+	var yes, wait bool
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a Project and everything in it",
+		Long: `Delete a Project. The controller removes the backing namespace with every
+workload, volume and address in it, then releases network, identity and
+security state. Its kubeconfig context is removed as well.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if !yes {
+				fmt.Fprintf(os.Stderr, "Delete Project %s and all of its resources? Re-run with --yes to confirm.\n", name)
+				return fmt.Errorf("not confirmed")
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveOrgScope(org)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			err = cli.DeleteProject(ctx, scope.Org, name)
+			cancel()
+			if err != nil {
+				return err
+			}
+			removeProjectContext(scope, name)
+			if !wait {
+				fmt.Printf("Deleting Project %s/%s (run `kube-dc projects wait %s --for delete` to follow)\n", scope.Org, name, name)
+				return nil
+			}
+			fmt.Printf("Deleting Project %s/%s; waiting for cleanup...\n", scope.Org, name)
+			if _, err := waitForProject(context.Background(), cli, scope.Org, name, true, timeout); err != nil {
+				return err
+			}
+			fmt.Printf("Project %s deleted\n", name)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&org, "org", "", "Organization (default: current context's Organization)")
+	cmd.Flags().BoolVar(&yes, "yes", false, "Confirm the deletion")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait until the Project is fully removed")
+	cmd.Flags().DurationVar(&timeout, "timeout", 10*time.Minute, "How long to wait with --wait")
+	return cmd
+}
+
+// removeProjectContext drops the Project's kubeconfig context, if this
+// machine has one. Best effort: a leftover context is harmless.
+func removeProjectContext(scope *orgScope, name string) {
+	kc := scope.kc
+	if kc.Realm != scope.Org {
+		return
+	}
+	kubeMgr, err := kubeconfig.NewManager()
+	if err != nil {
+		return
+	}
+	contextName := tenantContextParams(kc.Domain, scope.Org, kc.APIServer, scope.Org+"-"+name, "", false, false).ContextName
+	_ = kubeMgr.RemoveContext(contextName)
+}
+
+// -------- wait -----------------------------------------------------
+
+func projectsWaitCmd() *cobra.Command {
+	var org, forFlag string
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "wait <name>",
+		Short: "Wait until a Project is Ready, or until it is deleted",
+		Example: `  kube-dc projects wait web
+  kube-dc projects wait web --for delete --timeout 20m`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var forDelete bool
+			switch forFlag {
+			case "ready":
+			case "delete":
+				forDelete = true
+			default:
+				return fmt.Errorf("invalid --for %q (want ready or delete)", forFlag)
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveOrgScope(org)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			p, err := waitForProject(context.Background(), cli, scope.Org, args[0], forDelete, timeout)
+			if err != nil {
+				return err
+			}
+			if forDelete {
+				fmt.Printf("Project %s deleted\n", args[0])
+			} else {
+				fmt.Printf("Project %s is Ready (backing namespace %s)\n", args[0], p.Status.Namespace)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&org, "org", "", "Organization (default: current context's Organization)")
+	cmd.Flags().StringVar(&forFlag, "for", "ready", "Condition to wait for: ready|delete")
+	cmd.Flags().DurationVar(&timeout, "timeout", 10*time.Minute, "How long to wait")
+	return cmd
+}
+
+// waitForProject polls the Project until status.ready is true (or, with
+// forDelete, until it is gone). On timeout the error carries the
+// conditions that are not yet True, which is where the controller says
+// what it is stuck on. Transient API errors are retried until the
+// deadline; a 404 while waiting for Ready is fatal.
+func waitForProject(ctx context.Context, cli *k8sapi.Client, org, name string, forDelete bool, timeout time.Duration) (*k8sapi.Project, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var last *k8sapi.Project
+	var lastErr error
+	for {
+		reqCtx, reqCancel := context.WithTimeout(ctx, 30*time.Second)
+		p, err := cli.GetProject(reqCtx, org, name)
+		reqCancel()
+		switch {
+		case err == nil:
+			last, lastErr = p, nil
+			if !forDelete && p.Status.Ready {
+				return p, nil
+			}
+		case k8sapi.IsNotFound(err):
+			if forDelete {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("Project %s/%s not found", org, name)
+		default:
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			want := "become Ready"
+			if forDelete {
+				want = "be deleted"
+			}
+			msg := fmt.Sprintf("timed out after %s waiting for Project %s/%s to %s", timeout, org, name, want)
+			if pending := pendingConditions(last); pending != "" {
+				msg += "; pending: " + pending
+			}
+			if lastErr != nil {
+				msg += fmt.Sprintf(" (last error: %v)", lastErr)
+			}
+			return nil, fmt.Errorf("%s", msg)
+		case <-time.After(projectPollInterval):
+		}
+	}
+}
+
+// pendingConditions summarises the conditions that are not True, e.g.
+// "NetworkReady=False (SubnetConflict: 10.20.0.0/16 overlaps ...)".
+func pendingConditions(p *k8sapi.Project) string {
+	if p == nil {
+		return ""
+	}
+	return notTrueConditions(p.Status.Conditions)
+}
+
+// notTrueConditions renders every condition whose status is not True.
+func notTrueConditions(conditions []k8sapi.Condition) string {
+	var parts []string
+	for _, c := range conditions {
+		if c.Status == "True" {
+			continue
+		}
+		s := c.Type + "=" + c.Status
+		detail := c.Reason
+		if c.Message != "" {
+			if detail != "" {
+				detail += ": "
+			}
+			detail += c.Message
+		}
+		if detail != "" {
+			s += " (" + detail + ")"
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// -------- rendering helpers ----------------------------------------
+
+func printProjectsTable(org string, items []k8sapi.Project) error {
+	if len(items) == 0 {
+		fmt.Println("No Projects in", org)
+		return nil
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Metadata.Name < items[j].Metadata.Name })
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tNETWORK\tCIDR\tREADY\tNAMESPACE\tAGE")
+	for _, p := range items {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%s\t%s\n",
+			p.Metadata.Name,
+			p.Spec.EgressNetworkType,
+			p.Spec.CIDRBlock,
+			p.Status.Ready,
+			fmtCoalesce(p.Status.Namespace, "-"),
+			formatAge(p.Metadata.CreationTimestamp),
+		)
+	}
+	return w.Flush()
+}
+
+func printProjectDetail(p *k8sapi.Project) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", p.Metadata.Name)
+	fmt.Fprintf(w, "Organization:\t%s\n", p.Metadata.Namespace)
+	fmt.Fprintf(w, "Backing namespace:\t%s\n", fmtCoalesce(p.Status.Namespace, "-"))
+	fmt.Fprintf(w, "Network:\t%s\n", p.Spec.EgressNetworkType)
+	fmt.Fprintf(w, "CIDR:\t%s\n", p.Spec.CIDRBlock)
+	if p.Spec.GwLanIP != "" {
+		fmt.Fprintf(w, "Gateway LAN IP:\t%s\n", p.Spec.GwLanIP)
+	}
+	fmt.Fprintf(w, "Ready:\t%v\n", p.Status.Ready)
+	fmt.Fprintf(w, "Created:\t%s\n", fmtCoalesce(p.Metadata.CreationTimestamp, "-"))
+	_ = w.Flush()
+
+	if q := p.Status.QuotaUsage; q != nil {
+		source := "Organization plan"
+		if q.PerProjectQuotaSet {
+			source = "per-Project override"
+		}
+		fmt.Printf("\nQuota (%s", source)
+		if q.LastUpdated != "" {
+			fmt.Printf(", updated %s", q.LastUpdated)
+		}
+		fmt.Println("):")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  RESOURCE\tUSED\tHARD")
+		for _, row := range []struct {
+			name string
+			pair *k8sapi.QuotaPair
+		}{
+			{"cpu", q.CPU}, {"memory", q.Memory}, {"storage", q.Storage}, {"pods", q.Pods},
+		} {
+			if row.pair == nil {
+				continue
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", row.name, row.pair.Used, row.pair.Hard)
+		}
+		_ = w.Flush()
+	}
+
+	if len(p.Status.Conditions) > 0 {
+		fmt.Println("\nConditions:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  TYPE\tSTATUS\tREASON\tMESSAGE")
+		for _, c := range p.Status.Conditions {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", c.Type, c.Status, fmtCoalesce(c.Reason, "-"), c.Message)
+		}
+		_ = w.Flush()
+	}
+}
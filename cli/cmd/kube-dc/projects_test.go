@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+	"github.com/shalb/kube-dc/cli/internal/kubeconfig"
+)
+
+func TestBuildProject(t *testing.T) {
+	valid := projectOpts{Name: "web", Org: "acme", CIDR: "10.20.0.0/16", Network: "cloud"}
+	p, err := buildProject(valid)
+	if err != nil {
+		t.Fatalf("valid opts: %v", err)
+	}
+	if p.Metadata.Namespace != "acme" || p.Spec.CIDRBlock != "10.20.0.0/16" || p.Spec.EgressNetworkType != "cloud" {
+		t.Errorf("payload = %+v", p)
+	}
+
+	cases := map[string]func(o *projectOpts){
+		"uppercase name":  func(o *projectOpts) { o.Name = "Web" },
+		"trailing dash":   func(o *projectOpts) { o.Name = "web-" },
+		"namespace > 63":  func(o *projectOpts) { o.Name = strings.Repeat("a", 60) },
+		"unknown network": func(o *projectOpts) { o.Network = "private" },
+		"missing cidr":    func(o *projectOpts) { o.CIDR = "" },
+		"bad cidr":        func(o *projectOpts) { o.CIDR = "10.20.0.0" },
+		"bad gateway":     func(o *projectOpts) { o.GwLanIP = "10.20.0" },
+	}
+	for name, mutate := range cases {
+		o := valid
+		mutate(&o)
+		if _, err := buildProject(o); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// fakeProjectAPI serves one Project that turns Ready after readyAfter
+// GETs, or disappears after goneAfter GETs.
+type fakeProjectAPI struct {
+	mu         sync.Mutex
+	gets       int
+	readyAfter int
+	goneAfter  int
+	conditions []k8sapi.Condition
+}
+
+func (f *fakeProjectAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/apis/kube-dc.com/v1/namespaces/acme/projects/web" {
+		http.NotFound(w, r)
+		return
+	}
+	f.mu.Lock()
+	f.gets++
+	n := f.gets
+	f.mu.Unlock()
+	if f.goneAfter > 0 && n >= f.goneAfter {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]any{"kind": "Status", "message": `projects.kube-dc.com "web" not found`})
+		return
+	}
+	p := k8sapi.Project{Metadata: k8sapi.ObjectMeta{Name: "web", Namespace: "acme"}}
+	p.Status.Conditions = f.conditions
+	if f.readyAfter > 0 && n >= f.readyAfter {
+		p.Status.Ready = true
+		p.Status.Namespace = "acme-web"
+	}
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+func withFastPolling(t *testing.T) {
+	t.Helper()
+	prev := projectPollInterval
+	projectPollInterval = 5 * time.Millisecond
+	t.Cleanup(func() { projectPollInterval = prev })
+}
+
+func TestWaitForProject_Ready(t *testing.T) {
+	withFastPolling(t)
+	api := &fakeProjectAPI{readyAfter: 3}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	cli, _ := k8sapi.New(srv.URL, "token", "", false)
+
+	p, err := waitForProject(context.Background(), cli, "acme", "web", false, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Status.Ready || p.Status.Namespace != "acme-web" || api.gets != 3 {
+		t.Errorf("ready=%v namespace=%q gets=%d", p.Status.Ready, p.Status.Namespace, api.gets)
+	}
+}
+
+func TestWaitForProject_Delete(t *testing.T) {
+	withFastPolling(t)
+	srv := httptest.NewServer(&fakeProjectAPI{goneAfter: 2})
+	defer srv.Close()
+	cli, _ := k8sapi.New(srv.URL, "token", "", false)
+
+	if _, err := waitForProject(context.Background(), cli, "acme", "web", true, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// A Project that vanishes while waiting for Ready is an error, not a hang.
+	if _, err := waitForProject(context.Background(), cli, "acme", "web", false, time.Second); err == nil {
+		t.Error("expected a not-found error")
+	}
+}
+
+func TestWaitForProject_TimeoutNamesPendingConditions(t *testing.T) {
+	withFastPolling(t)
+	srv := httptest.NewServer(&fakeProjectAPI{conditions: []k8sapi.Condition{
+		{Type: "NamespaceReady", Status: "True"},
+		{Type: "NetworkReady", Status: "False", Reason: "SubnetConflict", Message: "10.20.0.0/16 overlaps acme-api"},
+	}})
+	defer srv.Close()
+	cli, _ := k8sapi.New(srv.URL, "token", "", false)
+
+	_, err := waitForProject(context.Background(), cli, "acme", "web", false, 30*time.Millisecond)
+	if err == nil {
+		t.Fatal("expected a timeout")
+	}
+	if !strings.Contains(err.Error(), "NetworkReady=False (SubnetConflict: 10.20.0.0/16 overlaps acme-api)") ||
+		strings.Contains(err.Error(), "NamespaceReady") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestAddProjectContext(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("KUBECONFIG", filepath.Join(home, "kubeconfig"))
+
+	ready := &k8sapi.Project{Metadata: k8sapi.ObjectMeta{Name: "web", Namespace: "acme"}}
+	ready.Status.Namespace = "acme-web"
+	scope := &orgScope{Org: "acme", kc: &kubeDCContext{
+		Domain: "kube-dc.cloud", APIServer: "https://kube-api.kube-dc.cloud:6443", Realm: "acme",
+	}}
+	name, err := addProjectContext(scope, ready)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "kube-dc/kube-dc.cloud/acme/web" {
+		t.Errorf("context = %q", name)
+	}
+	kubeMgr, _ := kubeconfig.NewManager()
+	contexts, err := kubeMgr.ListKubeDCContexts()
+	if err != nil || len(contexts) != 1 || contexts[0].Context.Namespace != "acme-web" {
+		t.Fatalf("contexts = %+v, err %v", contexts, err)
+	}
+
+	// From the admin context the Organization realm's credentials are not
+	// at hand, so no context is written.
+	scope.kc.Realm = adminRealm
+	if name, err := addProjectContext(scope, ready); err != nil || name != "" {
+		t.Errorf("admin context: name %q err %v", name, err)
+	}
+}
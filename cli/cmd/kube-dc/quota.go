@@ -0,0 +1,677 @@
+// `kube-dc quota` — the Organization's usage against its quota, so a
+// user sees exhaustion before a create fails rather than after. The
+// quota is one pool for every Project of the Organization: the
+// controller derives it from the billing plan and add-ons on the
+// Organization and enforces it as a HierarchicalResourceQuota that HNC
+// projects into each Project namespace as a plain ResourceQuota.
+//
+// Sources, all read-only:
+//   - Organization status.quotaUsage: the controller's summary, which
+//     lags by up to a minute and is the only source for public IPv4,
+//     object storage and GPU (per billing profile, as plans grant it);
+//   - the HRQ projection in a Project namespace: CPU, memory, storage
+//     and pods of the same pool, live;
+//   - Project status: per-Project usage, and an administrator's
+//     per-Project cap where one is set.
+//
+// --check compares a planned deployment against what is free in the
+// pool and, in a capped Project, under the cap, and fails when it does
+// not fit, so it can gate a pipeline. A resource the report does not
+// cover is unknown, not unlimited; --strict fails on those too.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Dimension keys, in display order. A GPU profile's count (shares or
+// whole devices) is gpu/<profile>, its memory and compute budgets
+// gpu/<profile>/memory and gpu/<profile>/compute.
+const (
+	quotaCPU           = "cpu"
+	quotaMemory        = "memory"
+	quotaStorage       = "storage"
+	quotaPods          = "pods"
+	quotaPublicIPv4    = "public-ipv4"
+	quotaObjectStorage = "object-storage"
+	quotaGPUPrefix     = "gpu/"
+	quotaGPUMemory     = "/memory"
+	quotaGPUCompute    = "/compute"
+
+	quotaBarWidth = 20
+)
+
+// quotaDimension is one resource of the pool. Used or Hard empty means
+// unknown; no Hard means not limited.
+type quotaDimension struct {
+	Key     string `json:"key"`
+	Used    string `json:"used,omitempty"`
+	Hard    string `json:"hard,omitempty"`
+	Percent *int   `json:"percent,omitempty"`
+
+	used, hard *resource.Quantity
+}
+
+func newQuotaDimension(key, used, hard string) quotaDimension {
+	d := quotaDimension{Key: key, used: parseQuotaQuantity(used), hard: parseQuotaQuantity(hard)}
+	if d.used != nil {
+		d.Used = formatQuotaQuantity(key, *d.used)
+	}
+	if d.hard != nil {
+		d.Hard = formatQuotaQuantity(key, *d.hard)
+	}
+	if d.used != nil && d.hard != nil && !d.hard.IsZero() {
+		pct := int(d.used.AsApproximateFloat64() * 100 / d.hard.AsApproximateFloat64())
+		d.Percent = &pct
+	}
+	return d
+}
+
+// free is what is left under the limit; ok is false when it cannot be
+// known.
+func (d quotaDimension) free() (resource.Quantity, bool) {
+	if d.used == nil || d.hard == nil {
+		return resource.Quantity{}, false
+	}
+	f := d.hard.DeepCopy()
+	f.Sub(*d.used)
+	return f, true
+}
+
+type quotaProject struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	CPU       string `json:"cpu,omitempty"`
+	Memory    string `json:"memory,omitempty"`
+	Storage   string `json:"storage,omitempty"`
+	Pods      string `json:"pods,omitempty"`
+	Capped    bool   `json:"capped,omitempty"`
+}
+
+type quotaReport struct {
+	Organization string                `json:"organization"`
+	Plan         string                `json:"plan,omitempty"`
+	PlanID       string                `json:"planId,omitempty"`
+	Subscription string                `json:"subscription,omitempty"`
+	Addons       []k8sapi.BillingAddon `json:"addons,omitempty"`
+	Enforced     bool                  `json:"enforced"`
+	// Live is true when CPU, memory, storage and pods come from the
+	// enforced ResourceQuota rather than the controller's summary.
+	Live        bool             `json:"live"`
+	LastUpdated string           `json:"lastUpdated,omitempty"`
+	Dimensions  []quotaDimension `json:"dimensions"`
+	Projects    []quotaProject   `json:"projects,omitempty"`
+	// Cap is the current Project's per-Project cap, when it has one.
+	CapProject string           `json:"capProject,omitempty"`
+	Cap        []quotaDimension `json:"cap,omitempty"`
+}
+
+func (r *quotaReport) dimension(key string) *quotaDimension {
+	for i := range r.Dimensions {
+		if r.Dimensions[i].Key == key {
+			return &r.Dimensions[i]
+		}
+	}
+	return nil
+}
+
+func quotaCmd() *cobra.Command {
+	var org, check, outFlag string
+	var strict bool
+	cmd := &cobra.Command{
+		Use:   "quota",
+		Short: "Show the Organization's resource usage against its quota",
+		Long: `Show CPU, memory, storage, pods, GPU, public IPv4 and object storage used by
+all Projects of the Organization against the quota its billing plan and
+add-ons grant, with a row per Project.
+
+--check takes a planned deployment as resource=quantity pairs and exits
+non-zero when it does not fit in what is free. In a Project with a
+per-Project cap it must fit under the cap too. Keys: cpu, memory, storage,
+pods, public-ipv4, object-storage, and gpu (or gpu/<profile> when the
+Organization has several GPU profiles; gpu/<profile>/memory in MiB and
+gpu/<profile>/compute in percent check the shared-GPU budgets).
+
+A resource with no hard limit is unlimited. One the quota report does not
+cover, or whose usage is not reported, is unknown: it is shown but passes,
+unless --strict is set.
+
+The Organization defaults to the one of the current context; from the admin
+context pass --org.`,
+		Example: `  kube-dc quota
+  kube-dc quota --org acme -o json
+  kube-dc quota --check cpu=4,memory=8Gi,public-ipv4=1
+  kube-dc quota --check cpu=4,object-storage=100Gi --strict`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			if strict && check == "" {
+				return errors.New("--strict applies to --check")
+			}
+			var planned map[string]resource.Quantity
+			if check != "" {
+				if planned, err = parseQuotaCheck(check); err != nil {
+					return err
+				}
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveOrgScope(org)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			report, err := readQuotaReport(ctx, cli, scope.Org, scope.kc.Namespace)
+			if err != nil {
+				return err
+			}
+			if planned != nil {
+				results, err := checkQuota(report, planned)
+				if err != nil {
+					return err
+				}
+				if out != outTable {
+					if perr := printSerialized(out, results); perr != nil {
+						return perr
+					}
+				} else {
+					printQuotaCheck(os.Stdout, results)
+				}
+				return quotaCheckError(results, strict)
+			}
+			if out != outTable {
+				return printSerialized(out, report)
+			}
+			printQuotaReport(os.Stdout, report)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&org, "org", "", "Organization (default: the current context's)")
+	cmd.Flags().StringVar(&check, "check", "", "Planned usage as key=quantity pairs, e.g. cpu=4,memory=8Gi; fail when it does not fit")
+	cmd.Flags().BoolVar(&strict, "strict", false, "With --check, also fail on resources whose limit or usage is unknown")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+// readQuotaReport assembles the report. currentNS is the context's
+// Project namespace, empty in an Organization or admin context.
+func readQuotaReport(ctx context.Context, cli *k8sapi.Client, org, currentNS string) (*quotaReport, error) {
+	o, err := cli.GetOrganization(ctx, org)
+	if err != nil {
+		if k8sapi.IsNotFound(err) {
+			return nil, fmt.Errorf("Organization %s not found", org)
+		}
+		return nil, fmt.Errorf("read Organization %s: %w", org, err)
+	}
+	report := &quotaReport{Organization: org, Enforced: o.Status.QuotaEnforced}
+	applyBillingAnnotations(report, o.Metadata.Annotations)
+	report.PlanID = fmtCoalesce(o.Status.QuotaPlanID, report.PlanID)
+	if u := o.Status.QuotaUsage; u != nil {
+		report.LastUpdated = u.LastUpdated
+		report.Dimensions = organizationQuotaDimensions(u)
+	}
+
+	// Projects the caller cannot list (a Project-scoped role) only
+	// cost the breakdown.
+	var projects []k8sapi.Project
+	if list, err := cli.ListProjects(ctx, org); err == nil {
+		projects = list.Items
+		sort.Slice(projects, func(i, j int) bool { return projects[i].Metadata.Name < projects[j].Metadata.Name })
+	}
+	for _, p := range projects {
+		report.Projects = append(report.Projects, projectQuotaRow(p))
+	}
+
+	// Every Project carries the same HRQ projection; prefer the
+	// context's own namespace, which the caller can certainly read.
+	var namespaces []string
+	if currentNS != "" && strings.HasPrefix(currentNS, org+"-") {
+		namespaces = append(namespaces, currentNS)
+	}
+	for _, p := range projects {
+		if p.Status.Namespace != "" && p.Status.Namespace != currentNS {
+			namespaces = append(namespaces, p.Status.Namespace)
+		}
+	}
+	for _, ns := range namespaces {
+		list, err := cli.ListResourceQuotas(ctx, ns)
+		if err != nil {
+			continue
+		}
+		applyResourceQuotas(report, list.Items, ns == currentNS, ns)
+		break
+	}
+	sortQuotaDimensions(report.Dimensions)
+	return report, nil
+}
+
+func organizationQuotaDimensions(u *k8sapi.OrganizationQuotaUsage) []quotaDimension {
+	type pair struct {
+		key string
+		p   *k8sapi.QuotaPair
+	}
+	pairs := []pair{
+		{quotaCPU, u.CPU}, {quotaMemory, u.Memory}, {quotaStorage, u.Storage}, {quotaPods, u.Pods},
+		{quotaPublicIPv4, u.PublicIPv4}, {quotaObjectStorage, u.ObjectStorage},
+	}
+	for profile, a := range u.Accelerators {
+		key := quotaGPUPrefix + profile
+		pairs = append(pairs, pair{key, a.Shares}, pair{key, a.Devices},
+			pair{key + quotaGPUMemory, a.MemoryMiB}, pair{key + quotaGPUCompute, a.CorePercent})
+	}
+	var out []quotaDimension
+	for _, p := range pairs {
+		if p.p != nil {
+			out = append(out, newQuotaDimension(p.key, p.p.Used, p.p.Hard))
+		}
+	}
+	return out
+}
+
+func applyBillingAnnotations(r *quotaReport, ann map[string]string) {
+	r.PlanID = ann[k8sapi.BillingPlanIDAnnotation]
+	r.Plan = fmtCoalesce(ann[k8sapi.BillingPlanNameAnnotation], r.PlanID)
+	r.Subscription = ann[k8sapi.BillingSubscriptionAnnotation]
+	if raw := ann[k8sapi.BillingAddonsAnnotation]; raw != "" {
+		// A malformed annotation is the controller's problem to
+		// report; the quota it computed is still shown.
+		_ = json.Unmarshal([]byte(raw), &r.Addons)
+	}
+}
+
+// applyResourceQuotas replaces the summary's pool figures with the
+// enforced HRQ projection and records the Project cap when the
+// namespace is the caller's own.
+func applyResourceQuotas(r *quotaReport, quotas []k8sapi.ResourceQuota, current bool, ns string) {
+	for _, q := range quotas {
+		dims := quotaDimensionsFromRQ(q)
+		switch {
+		case q.Metadata.Name == k8sapi.HRQProjectionQuota:
+			r.Live = true
+			for _, d := range dims {
+				if existing := r.dimension(d.Key); existing != nil {
+					*existing = d
+				} else {
+					r.Dimensions = append(r.Dimensions, d)
+				}
+			}
+		case q.Metadata.Name == k8sapi.ProjectCapQuota && current:
+			r.CapProject = ns
+			r.Cap = dims
+			sortQuotaDimensions(r.Cap)
+		}
+	}
+}
+
+// quotaDimensionsFromRQ keeps the request-side resources a plan
+// limits; limits.* follow from requests by the plan's burst ratio, and
+// GPU is read per profile from the Organization instead.
+func quotaDimensionsFromRQ(q k8sapi.ResourceQuota) []quotaDimension {
+	var out []quotaDimension
+	for name, hard := range q.Status.Hard {
+		key := ""
+		switch {
+		case name == "requests.cpu" || name == "cpu":
+			key = quotaCPU
+		case name == "requests.memory" || name == "memory":
+			key = quotaMemory
+		case name == "requests.storage":
+			key = quotaStorage
+		case name == "pods":
+			key = quotaPods
+		default:
+			continue
+		}
+		used := q.Status.Used[name]
+		if used == "" {
+			used = "0"
+		}
+		out = append(out, newQuotaDimension(key, used, hard))
+	}
+	return out
+}
+
+func projectQuotaRow(p k8sapi.Project) quotaProject {
+	row := quotaProject{Name: p.Metadata.Name, Namespace: p.Status.Namespace}
+	u := p.Status.QuotaUsage
+	if u == nil {
+		return row
+	}
+	used := func(key string, pair *k8sapi.QuotaPair) string {
+		if pair == nil {
+			return ""
+		}
+		return newQuotaDimension(key, pair.Used, "").Used
+	}
+	row.CPU = used(quotaCPU, u.CPU)
+	row.Memory = used(quotaMemory, u.Memory)
+	row.Storage = used(quotaStorage, u.Storage)
+	row.Pods = used(quotaPods, u.Pods)
+	row.Capped = u.PerProjectQuotaSet
+	return row
+}
+
+func quotaOrder(key string) int {
+	for i, k := range []string{quotaCPU, quotaMemory, quotaStorage, quotaPods} {
+		if k == key {
+			return i
+		}
+	}
+	switch {
+	case isGPUKey(key):
+		return 10
+	case key == quotaPublicIPv4:
+		return 20
+	}
+	return 30
+}
+
+func sortQuotaDimensions(dims []quotaDimension) {
+	sort.SliceStable(dims, func(i, j int) bool {
+		oi, oj := quotaOrder(dims[i].Key), quotaOrder(dims[j].Key)
+		if oi != oj {
+			return oi < oj
+		}
+		return dims[i].Key < dims[j].Key
+	})
+}
+
+// parseQuotaQuantity returns nil for an empty or unparseable value:
+// unknown, not zero.
+func parseQuotaQuantity(s string) *resource.Quantity {
+	if s == "" {
+		return nil
+	}
+	q, err := resource.ParseQuantity(s)
+	if err != nil {
+		return nil
+	}
+	return &q
+}
+
+// formatQuotaQuantity prints cores for CPU, binary units for bytes and
+// GPU memory (reported in MiB), a percentage for GPU compute and a
+// plain count for everything else.
+func formatQuotaQuantity(key string, q resource.Quantity) string {
+	switch {
+	case key == quotaCPU:
+		return strconv.FormatFloat(float64(q.MilliValue())/1000, 'f', -1, 64)
+	case key == quotaMemory, key == quotaStorage, key == quotaObjectStorage:
+		return humanBytes(q.Value())
+	case isGPUKey(key) && strings.HasSuffix(key, quotaGPUMemory):
+		return humanBytes(q.Value() << 20)
+	case isGPUKey(key) && strings.HasSuffix(key, quotaGPUCompute):
+		return strconv.FormatInt(q.Value(), 10) + "%"
+	}
+	return strconv.FormatInt(q.Value(), 10)
+}
+
+func isGPUKey(key string) bool { return strings.HasPrefix(key, quotaGPUPrefix) }
+
+// isGPUCount reports whether key is a profile's count, gpu/<profile>.
+func isGPUCount(key string) bool {
+	return isGPUKey(key) && !strings.Contains(strings.TrimPrefix(key, quotaGPUPrefix), "/")
+}
+
+func quotaLabel(key string) string {
+	switch {
+	case key == quotaPublicIPv4:
+		return "public IPv4"
+	case key == quotaObjectStorage:
+		return "object storage"
+	case isGPUKey(key):
+		return "gpu " + strings.ReplaceAll(strings.TrimPrefix(key, quotaGPUPrefix), "/", " ")
+	}
+	return key
+}
+
+// quotaBar renders pct as a fixed-width bar; past 100% it stays full.
+func quotaBar(pct int) string {
+	n := min(max(pct, 0)*quotaBarWidth/100, quotaBarWidth)
+	return strings.Repeat("█", n) + strings.Repeat("░", quotaBarWidth-n)
+}
+
+func printQuotaDimensions(w io.Writer, dims []quotaDimension) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RESOURCE\tUSED\tHARD\tUSAGE")
+	for _, d := range dims {
+		usage := "-"
+		switch {
+		case d.hard == nil:
+			usage = "not limited"
+		case d.Percent != nil:
+			usage = fmt.Sprintf("%s %3d%%", quotaBar(*d.Percent), *d.Percent)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", quotaLabel(d.Key), fmtCoalesce(d.Used, "-"), fmtCoalesce(d.Hard, "-"), usage)
+	}
+	_ = tw.Flush()
+}
+
+func printQuotaReport(w io.Writer, r *quotaReport) {
+	fmt.Fprintf(w, "Organization %s", r.Organization)
+	if r.Plan != "" {
+		fmt.Fprintf(w, ": plan %s", r.Plan)
+		if r.PlanID != "" && r.PlanID != r.Plan {
+			fmt.Fprintf(w, " (%s)", r.PlanID)
+		}
+	}
+	if r.Subscription != "" {
+		fmt.Fprintf(w, ", subscription %s", r.Subscription)
+	}
+	fmt.Fprintln(w)
+	if len(r.Addons) > 0 {
+		var parts []string
+		for _, a := range r.Addons {
+			parts = append(parts, fmt.Sprintf("%s ×%d", a.AddonID, a.Quantity))
+		}
+		fmt.Fprintf(w, "Add-ons: %s\n", strings.Join(parts, ", "))
+	}
+	if !r.Enforced {
+		fmt.Fprintln(w, "No plan quota is enforced for this Organization.")
+	}
+	if len(r.Dimensions) == 0 {
+		fmt.Fprintln(w, "\nNo quota usage is reported for this Organization.")
+		return
+	}
+	switch {
+	case r.Live && r.LastUpdated != "":
+		fmt.Fprintf(w, "Public IPv4 and object storage as of %s; the rest live.\n", r.LastUpdated)
+	case r.LastUpdated != "":
+		fmt.Fprintf(w, "As of %s.\n", r.LastUpdated)
+	}
+	fmt.Fprintln(w)
+	printQuotaDimensions(w, r.Dimensions)
+
+	if len(r.Cap) > 0 {
+		fmt.Fprintf(w, "\nPer-Project cap on %s (the smaller of this and the pool applies):\n", r.CapProject)
+		printQuotaDimensions(w, r.Cap)
+	}
+
+	if len(r.Projects) > 0 {
+		fmt.Fprintln(w, "\nBy Project:")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "PROJECT\tCPU\tMEMORY\tSTORAGE\tPODS\tCAPPED")
+		for _, p := range r.Projects {
+			capped := "-"
+			if p.Capped {
+				capped = "yes"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", p.Name,
+				fmtCoalesce(p.CPU, "-"), fmtCoalesce(p.Memory, "-"), fmtCoalesce(p.Storage, "-"), fmtCoalesce(p.Pods, "-"), capped)
+		}
+		_ = tw.Flush()
+	}
+}
+
+// -------- check ----------------------------------------------------
+
+var quotaCheckAliases = map[string]string{"ipv4": quotaPublicIPv4, "public-ip": quotaPublicIPv4, "s3": quotaObjectStorage}
+
+// parseQuotaCheck parses "cpu=4,memory=8Gi". gpu stays unqualified
+// until the report says which profiles exist.
+func parseQuotaCheck(s string) (map[string]resource.Quantity, error) {
+	out := map[string]resource.Quantity{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(item, "=")
+		k = strings.ToLower(strings.TrimSpace(k))
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid --check item %q: want key=quantity", item)
+		}
+		if alias, ok := quotaCheckAliases[k]; ok {
+			k = alias
+		}
+		switch {
+		case k == quotaCPU, k == quotaMemory, k == quotaStorage, k == quotaPods, k == quotaPublicIPv4, k == quotaObjectStorage,
+			k == "gpu", isGPUKey(k):
+		default:
+			return nil, fmt.Errorf("unknown --check key %q (want cpu, memory, storage, pods, public-ipv4, object-storage or gpu)", k)
+		}
+		q, err := resource.ParseQuantity(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid --check quantity for %s: %q", k, v)
+		}
+		if q.Sign() < 0 {
+			return nil, fmt.Errorf("invalid --check quantity for %s: %q is negative", k, v)
+		}
+		if prev, dup := out[k]; dup {
+			q.Add(prev)
+		}
+		out[k] = q
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("--check needs at least one key=quantity pair")
+	}
+	return out, nil
+}
+
+// quotaCheckResult is one planned resource against one limit.
+type quotaCheckResult struct {
+	Key       string `json:"key"`
+	Limit     string `json:"limit"` // Organization quota | Project cap
+	Requested string `json:"requested"`
+	Free      string `json:"free,omitempty"`
+	// Result is ok, exceeds, unlimited (reported with no hard limit), or
+	// unknown when the dimension or its usage is not reported. exceeds
+	// fails the check, and unknown does under --strict.
+	Result string `json:"result"`
+}
+
+func checkQuota(r *quotaReport, planned map[string]resource.Quantity) ([]quotaCheckResult, error) {
+	if q, ok := planned["gpu"]; ok {
+		var profiles []string
+		for _, d := range r.Dimensions {
+			if isGPUCount(d.Key) {
+				profiles = append(profiles, d.Key)
+			}
+		}
+		switch len(profiles) {
+		case 0:
+			return nil, fmt.Errorf("Organization %s has no GPU quota", r.Organization)
+		case 1:
+			delete(planned, "gpu")
+			q.Add(planned[profiles[0]])
+			planned[profiles[0]] = q
+		default:
+			return nil, fmt.Errorf("Organization %s has several GPU profiles (%s); name one as gpu/<profile>=N", r.Organization, strings.Join(profiles, ", "))
+		}
+	}
+	keys := make([]string, 0, len(planned))
+	for k := range planned {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if oi, oj := quotaOrder(keys[i]), quotaOrder(keys[j]); oi != oj {
+			return oi < oj
+		}
+		return keys[i] < keys[j]
+	})
+
+	var out []quotaCheckResult
+	for _, k := range keys {
+		want := planned[k]
+		out = append(out, checkOne(k, "Organization quota", want, r.dimension(k)))
+		for i := range r.Cap {
+			if r.Cap[i].Key == k {
+				out = append(out, checkOne(k, "Project cap", want, &r.Cap[i]))
+			}
+		}
+	}
+	return out, nil
+}
+
+func checkOne(key, limit string, want resource.Quantity, d *quotaDimension) quotaCheckResult {
+	res := quotaCheckResult{Key: key, Limit: limit, Requested: formatQuotaQuantity(key, want)}
+	switch {
+	case d == nil:
+		res.Result = "unknown"
+		return res
+	case d.hard == nil:
+		res.Result = "unlimited"
+		return res
+	}
+	free, ok := d.free()
+	if !ok {
+		res.Result = "unknown"
+		return res
+	}
+	res.Free = formatQuotaQuantity(key, free)
+	if want.Cmp(free) > 0 {
+		res.Result = "exceeds"
+	} else {
+		res.Result = "ok"
+	}
+	return res
+}
+
+func printQuotaCheck(w io.Writer, results []quotaCheckResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RESOURCE\tLIMIT\tREQUESTED\tFREE\tRESULT")
+	for _, c := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", quotaLabel(c.Key), c.Limit, c.Requested, fmtCoalesce(c.Free, "-"), c.Result)
+	}
+	_ = tw.Flush()
+}
+
+func quotaCheckError(results []quotaCheckResult, strict bool) error {
+	var over, unknown []string
+	for _, c := range results {
+		switch {
+		case c.Result == "exceeds":
+			over = append(over, fmt.Sprintf("%s (%s requested, %s free under the %s)", quotaLabel(c.Key), c.Requested, c.Free, c.Limit))
+		case c.Result == "unknown" && strict:
+			unknown = append(unknown, fmt.Sprintf("%s under the %s", quotaLabel(c.Key), c.Limit))
+		}
+	}
+	switch {
+	case len(over) > 0:
+		return fmt.Errorf("planned usage exceeds quota: %s", strings.Join(over, "; "))
+	case len(unknown) > 0:
+		return fmt.Errorf("cannot tell whether planned usage fits (--strict): %s not reported", strings.Join(unknown, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+)
+
+func testQuotaReport() *quotaReport {
+	r := &quotaReport{Organization: "acme", Enforced: true}
+	applyBillingAnnotations(r, map[string]string{
+		k8sapi.BillingPlanIDAnnotation:       "pro-pool",
+		k8sapi.BillingPlanNameAnnotation:     "Pro Pool",
+		k8sapi.BillingSubscriptionAnnotation: "active",
+		k8sapi.BillingAddonsAnnotation:       `[{"addonId":"turbo-x1","quantity":2}]`,
+	})
+	// The controller's summary, a minute old.
+	r.LastUpdated = "2026-10-16T09:00:00Z"
+	r.Dimensions = organizationQuotaDimensions(&k8sapi.OrganizationQuotaUsage{
+		CPU:           &k8sapi.QuotaPair{Used: "10", Hard: "26"},
+		Memory:        &k8sapi.QuotaPair{Used: "40Gi", Hard: "70Gi"},
+		PublicIPv4:    &k8sapi.QuotaPair{Used: "3", Hard: "3"},
+		ObjectStorage: &k8sapi.QuotaPair{Hard: "500Gi"},
+		Accelerators: map[string]k8sapi.AcceleratorQuotaUsage{
+			"nvidia-v100-hami": {
+				Shares:      &k8sapi.QuotaPair{Used: "1", Hard: "2"},
+				MemoryMiB:   &k8sapi.QuotaPair{Used: "8192", Hard: "16384"},
+				CorePercent: &k8sapi.QuotaPair{Used: "25", Hard: "50"},
+			},
+		},
+	})
+	rq := func(name string, hard, used map[string]string) k8sapi.ResourceQuota {
+		q := k8sapi.ResourceQuota{Metadata: k8sapi.ObjectMeta{Name: name}}
+		q.Status.Hard, q.Status.Used = hard, used
+		return q
+	}
+	applyResourceQuotas(r, []k8sapi.ResourceQuota{
+		rq(k8sapi.HRQProjectionQuota, map[string]string{
+			"requests.cpu":     "26",
+			"limits.cpu":       "52",
+			"requests.memory":  "70Gi",
+			"requests.storage": "460Gi",
+			"pods":             "500",
+		}, map[string]string{
+			"requests.cpu":     "18975m",
+			"limits.cpu":       "30",
+			"requests.memory":  "68719476736",
+			"requests.storage": "100Gi",
+			"pods":             "33",
+		}),
+		rq(k8sapi.ProjectCapQuota, map[string]string{"requests.cpu": "4"}, map[string]string{"requests.cpu": "3500m"}),
+	}, true, "acme-web")
+	sortQuotaDimensions(r.Dimensions)
+	return r
+}
+
+func TestQuotaReportSources(t *testing.T) {
+	r := testQuotaReport()
+	if r.Plan != "Pro Pool" || r.PlanID != "pro-pool" || len(r.Addons) != 1 || r.Addons[0].Quantity != 2 {
+		t.Fatalf("billing annotations: %+v", r)
+	}
+	var keys []string
+	for _, d := range r.Dimensions {
+		keys = append(keys, d.Key)
+	}
+	want := "cpu,memory,storage,pods,gpu/nvidia-v100-hami,gpu/nvidia-v100-hami/compute,gpu/nvidia-v100-hami/memory,public-ipv4,object-storage"
+	if strings.Join(keys, ",") != want {
+		t.Fatalf("dimensions = %v", keys)
+	}
+	if !r.Live {
+		t.Fatal("the HRQ projection should mark the report live")
+	}
+	cpu := r.dimension(quotaCPU)
+	if cpu.Used != "18.975" || cpu.Hard != "26" || *cpu.Percent != 72 {
+		t.Fatalf("cpu should come from the live quota: %+v", cpu)
+	}
+	if mem := r.dimension(quotaMemory); mem.Used != "64.0 GiB" || *mem.Percent != 91 {
+		t.Fatalf("memory: %+v", mem)
+	}
+	if gpu := r.dimension("gpu/nvidia-v100-hami"); gpu.Used != "1" || gpu.Hard != "2" {
+		t.Fatalf("gpu shares: %+v", gpu)
+	}
+	if m := r.dimension("gpu/nvidia-v100-hami/memory"); m.Used != "8.0 GiB" || m.Hard != "16.0 GiB" {
+		t.Fatalf("gpu memory is reported in MiB: %+v", m)
+	}
+	if c := r.dimension("gpu/nvidia-v100-hami/compute"); c.Hard != "50%" || *c.Percent != 50 {
+		t.Fatalf("gpu compute: %+v", c)
+	}
+	if os := r.dimension(quotaObjectStorage); os.Used != "" || os.Percent != nil {
+		t.Fatalf("unknown object storage usage must stay unknown: %+v", os)
+	}
+	if r.CapProject != "acme-web" || len(r.Cap) != 1 || r.Cap[0].Used != "3.5" {
+		t.Fatalf("project cap: %s %+v", r.CapProject, r.Cap)
+	}
+}
+
+func TestPrintQuotaReport(t *testing.T) {
+	r := testQuotaReport()
+	r.Projects = []quotaProject{{Name: "web", CPU: "3.5", Memory: "8.0 GiB", Pods: "12", Capped: true}}
+	var buf bytes.Buffer
+	printQuotaReport(&buf, r)
+	out := buf.String()
+	for _, want := range []string{
+		"Organization acme: plan Pro Pool (pro-pool), subscription active\n",
+		"Add-ons: turbo-x1 ×2\n",
+		"Public IPv4 and object storage as of 2026-10-16T09:00:00Z",
+		"cpu ", "██████████████░░░░░░  72%",
+		"public IPv4", "████████████████████ 100%",
+		"gpu nvidia-v100-hami memory",
+		"Per-Project cap on acme-web",
+		"web      3.5",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestQuotaBar(t *testing.T) {
+	for pct, want := range map[int]int{0: 0, 4: 0, 5: 1, 50: 10, 100: 20, 250: 20, -3: 0} {
+		if got := strings.Count(quotaBar(pct), "█"); got != want || len([]rune(quotaBar(pct))) != quotaBarWidth {
+			t.Errorf("quotaBar(%d) = %q", pct, quotaBar(pct))
+		}
+	}
+}
+
+func TestParseQuotaCheck(t *testing.T) {
+	got, err := parseQuotaCheck("cpu=4, memory=8Gi,ipv4=1,cpu=500m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cpu := got[quotaCPU]; cpu.MilliValue() != 4500 {
+		t.Errorf("repeated keys add up: cpu = %s", cpu.String())
+	}
+	if _, ok := got[quotaPublicIPv4]; !ok {
+		t.Errorf("ipv4 alias: %v", got)
+	}
+	for _, bad := range []string{"", "cpu", "cpus=4", "memory=lots", "cpu=-1"} {
+		if _, err := parseQuotaCheck(bad); err == nil {
+			t.Errorf("%q should be refused", bad)
+		}
+	}
+}
+
+func TestCheckQuota(t *testing.T) {
+	r := testQuotaReport()
+	planned, _ := parseQuotaCheck("cpu=1,memory=2Gi,gpu=1,object-storage=10Gi")
+	results, err := checkQuota(r, planned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range results {
+		got = append(got, c.Key+"/"+c.Limit+"="+c.Result)
+	}
+	want := "cpu/Organization quota=ok,cpu/Project cap=exceeds,memory/Organization quota=ok," +
+		"gpu/nvidia-v100-hami/Organization quota=ok,object-storage/Organization quota=unknown"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("results = %v", got)
+	}
+	err = quotaCheckError(results, false)
+	if err == nil || !strings.Contains(err.Error(), "cpu (1 requested, 0.5 free under the Project cap)") {
+		t.Fatalf("error = %v", err)
+	}
+
+	planned, _ = parseQuotaCheck("public-ipv4=1")
+	results, _ = checkQuota(r, planned)
+	if quotaCheckError(results, false) == nil {
+		t.Fatal("a full public IPv4 quota must fail the check")
+	}
+
+	planned, _ = parseQuotaCheck("memory=1Gi")
+	results, _ = checkQuota(r, planned)
+	if err := quotaCheckError(results, false); err != nil {
+		t.Fatalf("fits: %v", err)
+	}
+
+	planned, _ = parseQuotaCheck("gpu=2")
+	results, _ = checkQuota(r, planned)
+	if quotaCheckError(results, false) == nil {
+		t.Fatal("two shares with one free must fail the check")
+	}
+
+	// Storage is not in the report at all: unknown, which only --strict
+	// fails. Pods are reported without a hard limit: unlimited.
+	partial := &quotaReport{Organization: "acme", Dimensions: []quotaDimension{newQuotaDimension(quotaPods, "12", "")}}
+	planned, _ = parseQuotaCheck("storage=100Gi,pods=5")
+	results, _ = checkQuota(partial, planned)
+	got = got[:0]
+	for _, c := range results {
+		got = append(got, c.Key+"="+c.Result)
+	}
+	if strings.Join(got, ",") != "storage=unknown,pods=unlimited" {
+		t.Fatalf("results = %v", got)
+	}
+	if err := quotaCheckError(results, false); err != nil {
+		t.Fatalf("unknown failed without --strict: %v", err)
+	}
+	if err := quotaCheckError(results, true); err == nil || !strings.Contains(err.Error(), "storage under the Organization quota") || strings.Contains(err.Error(), "pods") {
+		t.Fatalf("--strict: %v", err)
+	}
+
+	r.Dimensions = append(r.Dimensions, newQuotaDimension("gpu/other", "0", "1"))
+	planned, _ = parseQuotaCheck("gpu=1")
+	if _, err := checkQuota(r, planned); err == nil || !strings.Contains(err.Error(), "several GPU profiles") {
+		t.Fatalf("ambiguous gpu: %v", err)
+	}
+}
@@ -12,12 +12,23 @@
 //   list      — GET ManagedSecrets in the namespace                 (k8s)
 //   get       — show one ManagedSecret; --value also shows KV data  (k8s + backend)
 //   describe  — alias for `get` (AWS/GCP-style)                     (k8s + backend)
-//   put       — upsert KV values from --from-literal/--from-file    (backend)
+//   put       — upsert KV values from --from-literal/--from-file;   (backend)
+//               --merge / --cas write check-and-set
 //   sync      — toggle/configure spec.sync                          (k8s)
 //   import    — adopt an existing Secret                            (backend)
 //   delete    — soft-delete; --destroy also wipes KV metadata       (k8s and/or backend)
 //   destroy-version  — destroy a single KV version (admin policy)   (backend)
+//   versions  — list KV versions (secrets_versions.go)              (backend)
+//   diff      — key-level changes between two versions; masked      (backend)
+//   rollback  — write an old version's data as a new version (CAS)  (backend)
+//   export    — secrets + values as dotenv/json/yaml/sops            (k8s + backend)
+//               (secrets_bundle.go)
+//   import-file — create/update many secrets from a file; --prune   (k8s + backend)
+//   promote   — copy values to another Project with provenance      (backend [+ k8s])
+//               (secrets_promote.go)
 //   consumers — list workloads referencing the synced Secret        (backend)
+//   run       — exec a command with values as env vars; --watch     (backend)
+//               restarts it on a new version (secrets_run.go)
 
 package main
 
@@ -25,6 +36,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"sort"
@@ -32,6 +44,7 @@ import (
 	"time"
 
 	"github.com/shalb/kube-dc/cli/internal/backend"
+	"github.com/shalb/kube-dc/cli/internal/config"
 	"github.com/shalb/kube-dc/cli/internal/k8sapi"
 	"github.com/shalb/kube-dc/cli/internal/kubeconfig"
 	"github.com/shalb/kube-dc/cli/pkg/credential"
@@ -58,9 +71,13 @@ type secretsScope struct {
 	Domain      string // e.g. kube-dc.cloud (without https:// or backend. prefix)
 	APIServer   string // e.g. https://kube-api.kube-dc.cloud:6443
 	Namespace   string // K8s namespace (e.g. shalb-envoy)
+	Realm       string // Organization realm, or "master" for the admin context
 	AccessToken string
 	K8sCACert   string // CA bundle for kube-apiserver (from kubeconfig)
 	K8sInsecure bool   // insecure-skip-tls-verify flag from kubeconfig
+	// PlatformCACert is the private CA the login trusts for the
+	// platform's own endpoints (PEM), empty for publicly trusted ones.
+	PlatformCACert string
 }
 
 func secretsCmd() *cobra.Command {
@@ -84,7 +101,14 @@ Permissions follow the exact standard Project roles:
 	cmd.AddCommand(secretsImportCmd())
 	cmd.AddCommand(secretsDeleteCmd())
 	cmd.AddCommand(secretsDestroyVersionCmd())
+	cmd.AddCommand(secretsVersionsCmd())
+	cmd.AddCommand(secretsDiffCmd())
+	cmd.AddCommand(secretsRollbackCmd())
+	cmd.AddCommand(secretsExportCmd())
+	cmd.AddCommand(secretsImportFileCmd())
+	cmd.AddCommand(secretsPromoteCmd())
 	cmd.AddCommand(secretsConsumersCmd())
+	cmd.AddCommand(secretsRunCmd())
 	return cmd
 }
 
@@ -93,6 +117,64 @@ Permissions follow the exact standard Project roles:
 // kubeconfig context's `namespace` field is used. Errors when the
 // user is not logged in OR the current context isn't kube-dc-managed.
 func resolveScope(nsOverride string) (*secretsScope, error) {
+	kc, err := resolveKubeDCContext()
+	if err != nil {
+		return nil, err
+	}
+	ns := nsOverride
+	if ns == "" {
+		ns = kc.Namespace
+	}
+	if ns == "" {
+		return nil, fmt.Errorf("Project context has no backing namespace — run `kube-dc use` and select a Project (or pass --namespace for compatibility)")
+	}
+	return &secretsScope{
+		Domain:      kc.Domain,
+		APIServer:   kc.APIServer,
+		Namespace:   ns,
+		Realm:       kc.Realm,
+		AccessToken: kc.Creds.AccessToken,
+		K8sCACert:   kc.CACert,
+		K8sInsecure: kc.Insecure,
+
+		PlatformCACert: kc.Creds.CACert,
+	}, nil
+}
+
+// refreshToken reloads the scope's access token from the credential
+// store, refreshing it when it is about to expire. A command that runs
+// longer than a token lives calls it before each request and builds its
+// clients afresh.
+func (s *secretsScope) refreshToken() error {
+	provider, err := credential.NewProvider()
+	if err != nil {
+		return fmt.Errorf("load credentials: %w", err)
+	}
+	creds, err := provider.LoadAndRefresh(s.APIServer, s.Realm)
+	if err != nil {
+		return err
+	}
+	s.AccessToken = creds.AccessToken
+	return nil
+}
+
+// kubeDCContext is the current kube-dc kubeconfig context with its
+// identity loaded. Namespace is empty for the cluster-scoped admin
+// context; commands that need a Project go through resolveScope.
+type kubeDCContext struct {
+	Name      string
+	Domain    string
+	APIServer string
+	Realm     string
+	Namespace string
+	CACert    string
+	Insecure  bool
+	Creds     *config.Credentials
+}
+
+// resolveKubeDCContext loads the current kube-dc context and its
+// credentials, refreshing the access token when it has expired.
+func resolveKubeDCContext() (*kubeDCContext, error) {
 	kubeMgr, err := kubeconfig.NewManager()
 	if err != nil {
 		return nil, fmt.Errorf("load kubeconfig: %w", err)
@@ -104,6 +186,9 @@ func resolveScope(nsOverride string) (*secretsScope, error) {
 	if !strings.HasPrefix(cfg.CurrentContext, "kube-dc/") {
 		return nil, fmt.Errorf("current kubeconfig context %q is not a kube-dc context — run `kube-dc login` first", cfg.CurrentContext)
 	}
+	if project, ok := projectOfClusterContext(cfg.CurrentContext); ok {
+		return nil, fmt.Errorf("current kubeconfig context %q is a Managed Cluster — switch back to its Project with `kube-dc use %s`", cfg.CurrentContext, project)
+	}
 	var (
 		serverURL, ctxNamespace string
 		caCertPEM               string
@@ -155,13 +240,6 @@ func resolveScope(nsOverride string) (*secretsScope, error) {
 	if err != nil {
 		return nil, err
 	}
-	ns := nsOverride
-	if ns == "" {
-		ns = ctxNamespace
-	}
-	if ns == "" {
-		return nil, fmt.Errorf("Project context has no backing namespace — run `kube-dc use` and select a Project (or pass --namespace for compatibility)")
-	}
 	// Domain is parsed from the API-server URL:
 	// https://kube-api.<DOMAIN>:6443 → <DOMAIN>.
 	domain := strings.TrimPrefix(serverURL, "https://")
@@ -169,13 +247,15 @@ func resolveScope(nsOverride string) (*secretsScope, error) {
 	if i := strings.LastIndex(domain, ":"); i >= 0 {
 		domain = domain[:i]
 	}
-	return &secretsScope{
-		Domain:      domain,
-		APIServer:   serverURL,
-		Namespace:   ns,
-		AccessToken: creds.AccessToken,
-		K8sCACert:   caCertPEM,
-		K8sInsecure: insecureSkip,
+	return &kubeDCContext{
+		Name:      cfg.CurrentContext,
+		Domain:    domain,
+		APIServer: serverURL,
+		Realm:     realm,
+		Namespace: ctxNamespace,
+		CACert:    caCertPEM,
+		Insecure:  insecureSkip,
+		Creds:     creds,
 	}, nil
 }
 
@@ -583,6 +663,8 @@ func secretsPutCmd() *cobra.Command {
 	var namespace string
 	var literals, files []string
 	var envFile string
+	var cas int
+	var merge bool
 	cmd := &cobra.Command{
 		Use:   "put <name>",
 		Short: "Write or update stored values (requires developer, project-manager, or admin).",
@@ -590,12 +672,21 @@ func secretsPutCmd() *cobra.Command {
 --from-literal=KEY=VAL, --from-file=KEY=path, or --from-env-file=path.
 File contents become the value as a UTF-8 string.
 
+The new version holds exactly the supplied keys. --merge reads the current
+version first and keeps the keys not supplied. --cas=N writes only if the
+current version is still N (0: no value yet); --merge always writes
+check-and-set against the version it read. A lost check-and-set writes
+nothing and reports both versions.
+
 Examples:
   kube-dc secrets put app-config \
     --from-literal=DATABASE_URL=postgres://... \
     --from-file=tls.crt=./tls.crt
 
-  kube-dc secrets put app-env --from-env-file=./app.env`,
+  kube-dc secrets put app-env --from-env-file=./app.env
+
+  kube-dc secrets put app-config --merge --from-literal=LOG_LEVEL=debug
+  kube-dc secrets put app-config --cas=5 --from-env-file=./app.env`,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			name := args[0]
@@ -618,6 +709,11 @@ Examples:
 			if len(data) == 0 {
 				return fmt.Errorf("at least one --from-literal, --from-file, or --from-env-file is required")
 			}
+			if !cmd.Flags().Changed("cas") {
+				cas = -1
+			} else if cas < 0 {
+				return fmt.Errorf("--cas must be >= 0")
+			}
 			scope, err := resolveScope(namespace)
 			if err != nil {
 				return err
@@ -628,10 +724,18 @@ Examples:
 			}
 			ctx, cancel := ctxWithTimeout()
 			defer cancel()
-			res, err := cli.PutSecretValues(ctx, scope.Namespace, name, data)
+			res, total, err := writeSecretValues(ctx, cli, scope.Namespace, name, data, cas, merge)
 			if err != nil {
+				var conflict *backend.CASConflictError
+				if errors.As(err, &conflict) {
+					return fmt.Errorf("%w. Review what changed with `kube-dc secrets diff %s --from v%d` and re-run", err, name, max(conflict.Expected, 1))
+				}
 				return err
 			}
+			if merge {
+				fmt.Printf("Wrote %s/%s v%d (%d keys updated, %d total)\n", scope.Namespace, name, res.Version, len(data), total)
+				return nil
+			}
 			fmt.Printf("Wrote %s/%s v%d (%d keys)\n", scope.Namespace, name, res.Version, len(data))
 			return nil
 		},
@@ -640,9 +744,60 @@ Examples:
 	cmd.Flags().StringArrayVar(&literals, "from-literal", nil, "KEY=VALUE pair (repeatable)")
 	cmd.Flags().StringArrayVar(&files, "from-file", nil, "KEY=path; file contents become VALUE (repeatable)")
 	cmd.Flags().StringVar(&envFile, "from-env-file", "", "Path to a .env file (KEY=VALUE lines) to seed values")
+	cmd.Flags().IntVar(&cas, "cas", 0, "Write only if the current version is still N (0: no value yet)")
+	cmd.Flags().BoolVar(&merge, "merge", false, "Keep the keys not supplied (read-modify-write, check-and-set)")
 	return cmd
 }
 
+// writeSecretValues is the write behind `secrets put`. cas < 0 means
+// no precondition. With merge, the current version is read and the
+// supplied keys are laid over it; the write is check-and-set against
+// the version read (and cas, if set, must match it). Returns the
+// number of keys in the written version. A lost check-and-set comes
+// back as *backend.CASConflictError, with Current filled in when the
+// backend did not report it.
+func writeSecretValues(ctx context.Context, cli *backend.Client, ns, name string, data map[string]string, cas int, merge bool) (*backend.PutSecretValuesResult, int, error) {
+	var res *backend.PutSecretValuesResult
+	var err error
+	switch {
+	case merge:
+		var s *backend.SecretSummary
+		if s, err = cli.GetSecret(ctx, ns, name, true); err != nil {
+			return nil, 0, err
+		}
+		// A soft-deleted current version has no keys to keep, but it
+		// is still the version check-and-set has to name.
+		cur := currentFromSummary(s)
+		current := cur.Version
+		merged := map[string]string{}
+		for k, v := range cur.Data {
+			merged[k] = v
+		}
+		if cas >= 0 && cas != current {
+			return nil, 0, &backend.CASConflictError{Name: name, Expected: cas, Current: current}
+		}
+		for k, v := range data {
+			merged[k] = v
+		}
+		data, cas = merged, current
+		res, err = cli.PutSecretValuesCAS(ctx, ns, name, data, cas)
+	case cas >= 0:
+		res, err = cli.PutSecretValuesCAS(ctx, ns, name, data, cas)
+	default:
+		res, err = cli.PutSecretValues(ctx, ns, name, data)
+	}
+	var conflict *backend.CASConflictError
+	if errors.As(err, &conflict) && conflict.Current == 0 {
+		if s, gerr := cli.GetSecret(ctx, ns, name, false); gerr == nil && s.OpenBao != nil {
+			conflict.Current = s.OpenBao.CurrentVersion
+		}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return res, len(data), nil
+}
+
 // parseEnvFile reads a kubectl-style .env file: each non-blank,
 // non-comment line is `KEY=VALUE`. KEY has surrounding whitespace
 // trimmed (kubectl's behaviour); VALUE is taken VERBATIM from the
@@ -662,6 +817,12 @@ func parseEnvFile(path string) (map[string]string, error) {
 	if err != nil {
 		return nil, fmt.Errorf("read --from-env-file %s: %w", path, err)
 	}
+	return parseEnvData(raw, path)
+}
+
+// parseEnvData is parseEnvFile on bytes already read (or decrypted);
+// path only labels errors.
+func parseEnvData(raw []byte, path string) (map[string]string, error) {
 	out := map[string]string{}
 	for i, line := range strings.Split(string(raw), "\n") {
 		// Strip a single trailing CR so a file with CRLF endings still
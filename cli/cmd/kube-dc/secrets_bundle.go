@@ -0,0 +1,602 @@
+// `kube-dc secrets export | import-file` — move many secrets as one
+// document: dotenv (a single secret), JSON or YAML, or YAML encrypted
+// with SOPS to age recipients. Export reads values through the backend
+// (audited like `get --value`). import-file plans against the Project,
+// prints the plan with values masked, then creates ManagedSecrets (k8s)
+// and writes values check-and-set (backend). SOPS runs as the `sops`
+// binary over stdin/stdout, so encrypting never puts plaintext on disk —
+// except on Windows, which has no /dev/stdin to name as sops's input:
+// there the plaintext goes through a 0600 file in a private temporary
+// directory that is removed as soon as sops is done.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/shalb/kube-dc/cli/internal/backend"
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// secretBundle is the JSON/YAML export document.
+type secretBundle struct {
+	Project string                    `json:"project,omitempty" yaml:"project,omitempty"`
+	Secrets map[string]*bundledSecret `json:"secrets" yaml:"secrets"`
+}
+
+// bundledSecret carries what `secrets create` takes, plus the values.
+type bundledSecret struct {
+	Type        string            `json:"type,omitempty" yaml:"type,omitempty"`
+	Description string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Sync        *bundledSync      `json:"sync,omitempty" yaml:"sync,omitempty"`
+	Data        map[string]string `json:"data" yaml:"data"`
+}
+
+type bundledSync struct {
+	Enabled bool     `json:"enabled" yaml:"enabled"`
+	Target  string   `json:"target,omitempty" yaml:"target,omitempty"`
+	Refresh string   `json:"refresh,omitempty" yaml:"refresh,omitempty"`
+	Keys    []string `json:"keys,omitempty" yaml:"keys,omitempty"`
+}
+
+func bundledFromSummary(s *backend.SecretSummary) *bundledSecret {
+	b := &bundledSecret{
+		Type:        s.Type,
+		Description: s.Description,
+		Sync: &bundledSync{
+			Enabled: s.Sync.Enabled,
+			Target:  s.Sync.TargetSecretName,
+			Refresh: s.Sync.RefreshInterval,
+			Keys:    s.Sync.Keys,
+		},
+		Data: map[string]string{},
+	}
+	if s.Value != nil && !s.ValueMissing {
+		b.Data = s.Value.Data
+	}
+	return b
+}
+
+// renderBundle encodes the bundle. dotenv holds exactly one secret and
+// refuses what parseEnvData could not read back: keys with '=', '#'
+// or spaces, values with line breaks.
+func renderBundle(b *secretBundle, format string) ([]byte, error) {
+	switch format {
+	case "dotenv":
+		if len(b.Secrets) != 1 {
+			return nil, fmt.Errorf("dotenv holds one secret; %d selected (use --format yaml, json or sops)", len(b.Secrets))
+		}
+		var buf bytes.Buffer
+		for name, s := range b.Secrets {
+			for _, k := range sortedEnvKeys(s.Data) {
+				v := s.Data[k]
+				if k != strings.TrimSpace(k) || strings.ContainsAny(k, "= \t\n") || strings.HasPrefix(k, "#") {
+					return nil, fmt.Errorf("%s: key %q cannot be written as dotenv; use --format yaml", name, k)
+				}
+				if strings.ContainsAny(v, "\r\n") {
+					return nil, fmt.Errorf("%s: value of %s spans lines and cannot be written as dotenv; use --format yaml", name, k)
+				}
+				fmt.Fprintf(&buf, "%s=%s\n", k, v)
+			}
+		}
+		return buf.Bytes(), nil
+	case "json":
+		out, err := json.MarshalIndent(b, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(out, '\n'), nil
+	case "yaml", "sops":
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(b); err != nil {
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unsupported --format %q (want dotenv|json|yaml|sops)", format)
+}
+
+// runSops runs the sops binary, feeding stdin; a var so tests can
+// stand in for it.
+var runSops = func(stdin []byte, args ...string) ([]byte, error) {
+	if _, err := exec.LookPath("sops"); err != nil {
+		return nil, fmt.Errorf("sops not found in PATH; install it from https://github.com/getsops/sops")
+	}
+	cmd := exec.Command("sops", args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("sops %s: %w (%s)", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// sopsStdin is the path sops reads its input from stdin by; empty
+// where there is none.
+var sopsStdin = func() string {
+	if runtime.GOOS == "windows" {
+		return ""
+	}
+	return "/dev/stdin"
+}()
+
+// sopsEncryptBundle encrypts the YAML bundle's data values to the age
+// recipients, leaving names, types and sync settings readable for
+// review. The result is checked: every value must come back as an
+// ENC[...] string, or nothing is returned.
+func sopsEncryptBundle(plain []byte, recipients []string) ([]byte, error) {
+	args := []string{"--encrypt", "--age", strings.Join(recipients, ","),
+		"--encrypted-regex", "^data$", "--input-type", "yaml", "--output-type", "yaml"}
+	stdin := plain
+	if sopsStdin != "" {
+		args = append(args, sopsStdin)
+	} else {
+		dir, err := os.MkdirTemp("", "kube-dc-sops-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		in := filepath.Join(dir, "bundle.yaml")
+		if err := os.WriteFile(in, plain, 0o600); err != nil {
+			return nil, err
+		}
+		args, stdin = append(args, in), nil
+	}
+	out, err := runSops(stdin, args...)
+	if err != nil {
+		return nil, err
+	}
+	var check struct {
+		Secrets map[string]struct {
+			Data map[string]string `yaml:"data"`
+		} `yaml:"secrets"`
+		Sops map[string]any `yaml:"sops"`
+	}
+	if err := yaml.Unmarshal(out, &check); err != nil || check.Sops == nil {
+		return nil, fmt.Errorf("sops output does not look encrypted; refusing to write it")
+	}
+	for name, s := range check.Secrets {
+		for k, v := range s.Data {
+			if !strings.HasPrefix(v, "ENC[") {
+				return nil, fmt.Errorf("sops left %s/%s in cleartext; refusing to write the output", name, k)
+			}
+		}
+	}
+	return out, nil
+}
+
+// readBundleFile parses an import file. format is dotenv, json or
+// yaml; empty picks by extension. Both json and yaml parse as YAML;
+// the format only tells sops what it is decrypting. A dotenv file is one
+// secret, named by name. SOPS-encrypted files are recognised by their
+// metadata and decrypted with the caller's sops keys.
+func readBundleFile(path, format, name string) (*secretBundle, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		switch {
+		case filepath.Ext(path) == ".env" || filepath.Base(path) == ".env":
+			format = "dotenv"
+		case filepath.Ext(path) == ".json":
+			format = "json"
+		default:
+			format = "yaml"
+		}
+	}
+	switch format {
+	case "dotenv":
+		if name == "" {
+			return nil, fmt.Errorf("a dotenv file holds one secret; name it with --name")
+		}
+		if !projectNameRE.MatchString(name) {
+			return nil, fmt.Errorf("invalid --name %q", name)
+		}
+		if bytes.Contains(raw, []byte("\nsops_version=")) || bytes.HasPrefix(raw, []byte("sops_version=")) {
+			if raw, err = runSops(nil, "--decrypt", "--input-type", "dotenv", "--output-type", "dotenv", path); err != nil {
+				return nil, err
+			}
+		}
+		data, err := parseEnvData(raw, path)
+		if err != nil {
+			return nil, err
+		}
+		return &secretBundle{Secrets: map[string]*bundledSecret{name: {Data: data}}}, nil
+	case "json", "yaml":
+	default:
+		return nil, fmt.Errorf("unsupported --format %q (want dotenv|json|yaml)", format)
+	}
+	if name != "" {
+		return nil, fmt.Errorf("--name applies to dotenv files only; %s names its secrets", path)
+	}
+	var probe map[string]any
+	if err := yaml.Unmarshal(raw, &probe); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if _, encrypted := probe["sops"]; encrypted {
+		if raw, err = runSops(nil, "--decrypt", "--input-type", format, "--output-type", "yaml", path); err != nil {
+			return nil, err
+		}
+	}
+	var b secretBundle
+	if err := yaml.Unmarshal(raw, &b); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(b.Secrets) == 0 {
+		return nil, fmt.Errorf("%s has no secrets: want a top-level secrets: map", path)
+	}
+	for n, s := range b.Secrets {
+		if !projectNameRE.MatchString(n) {
+			return nil, fmt.Errorf("%s: invalid secret name %q", path, n)
+		}
+		if s == nil {
+			b.Secrets[n] = &bundledSecret{}
+			s = b.Secrets[n]
+		}
+		if s.Data == nil {
+			s.Data = map[string]string{}
+		}
+	}
+	return &b, nil
+}
+
+// currentSecret is what import-file found in the Project: the stored
+// version and values, when the secret is also in the file.
+type currentSecret struct {
+	Version int
+	Data    map[string]string
+}
+
+// currentFromSummary is the version a check-and-set write goes against
+// (import-file, put --merge, promote). A deleted current version still counts:
+// check-and-set has to name it, so the new keys are written on top of
+// it.
+func currentFromSummary(s *backend.SecretSummary) *currentSecret {
+	switch {
+	case s.Value != nil && !s.ValueMissing:
+		return &currentSecret{Version: s.Value.Metadata.Version, Data: s.Value.Data}
+	case s.OpenBao != nil:
+		return &currentSecret{Version: s.OpenBao.CurrentVersion}
+	}
+	return &currentSecret{}
+}
+
+// importStep is one line of the import-file plan.
+type importStep struct {
+	Name    string            `json:"name"`
+	Action  string            `json:"action"` // create | update | unchanged | delete
+	Version int               `json:"version,omitempty"`
+	Keys    int               `json:"keys"`
+	Changes []secretKeyChange `json:"changes,omitempty"`
+}
+
+// planSecretImport compares the file with the Project's secrets
+// (existing: every name in the Project). Secrets in the file are
+// created or updated to hold exactly the file's keys; with prune,
+// secrets missing from the file are deleted. Pure — no I/O.
+func planSecretImport(b *secretBundle, existing map[string]*currentSecret, prune bool) []importStep {
+	names := make([]string, 0, len(b.Secrets))
+	for n := range b.Secrets {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	var steps []importStep
+	for _, n := range names {
+		want := b.Secrets[n].Data
+		cur, ok := existing[n]
+		switch {
+		case !ok:
+			steps = append(steps, importStep{Name: n, Action: "create", Keys: len(want)})
+		default:
+			changes := diffSecretData(cur.Data, want, false)
+			action := "update"
+			if len(changes) == 0 {
+				action = "unchanged"
+			}
+			steps = append(steps, importStep{Name: n, Action: action, Version: cur.Version, Keys: len(want), Changes: changes})
+		}
+	}
+	if prune {
+		var gone []string
+		for n := range existing {
+			if _, ok := b.Secrets[n]; !ok {
+				gone = append(gone, n)
+			}
+		}
+		sort.Strings(gone)
+		for _, n := range gone {
+			steps = append(steps, importStep{Name: n, Action: "delete"})
+		}
+	}
+	return steps
+}
+
+func printImportPlan(ns string, steps []importStep) {
+	fmt.Printf("Plan for %s:\n", ns)
+	for _, s := range steps {
+		switch s.Action {
+		case "create":
+			fmt.Printf("  + %s: create, %d keys\n", s.Name, s.Keys)
+		case "update":
+			fmt.Printf("  ~ %s: update from v%d\n", s.Name, s.Version)
+			for _, c := range s.Changes {
+				fmt.Printf("      %s %s\n", changeMark(c.Change), c.Key)
+			}
+		case "unchanged":
+			fmt.Printf("  = %s: unchanged\n", s.Name)
+		case "delete":
+			fmt.Printf("  - %s: delete (stored values kept)\n", s.Name)
+		}
+	}
+}
+
+func secretsExportCmd() *cobra.Command {
+	var namespace, format, file string
+	var all bool
+	var recipients []string
+	cmd := &cobra.Command{
+		Use:   "export [<name>...] [--all]",
+		Short: "Export secrets with their values as dotenv, JSON, YAML or SOPS (requires developer, project-manager, or admin).",
+		Long: `Export secrets, their type, description, sync settings and current values as
+one document. --format dotenv writes the keys of a single secret. --format sops
+writes YAML with the values encrypted by sops to the --age recipients (default:
+$SOPS_AGE_RECIPIENTS); the sops binary must be in PATH.
+
+Output goes to stdout unless --file is set. Plaintext formats hold the values in
+clear: keep them out of git and delete them after use.`,
+		Example: `  kube-dc secrets export app-config --format dotenv > app.env
+  kube-dc secrets export --all --format sops --age age1... --file stage.enc.yaml
+  kube-dc secrets export db-creds app-config -o json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if all == (len(args) > 0) {
+				return fmt.Errorf("name the secrets to export, or pass --all")
+			}
+			for _, n := range args {
+				if !projectNameRE.MatchString(n) {
+					return fmt.Errorf("invalid secret name %q", n)
+				}
+			}
+			switch format {
+			case "dotenv", "json", "yaml":
+				if len(recipients) > 0 {
+					return fmt.Errorf("--age applies to --format sops only")
+				}
+			case "sops":
+				if len(recipients) == 0 && os.Getenv("SOPS_AGE_RECIPIENTS") != "" {
+					recipients = strings.Split(os.Getenv("SOPS_AGE_RECIPIENTS"), ",")
+				}
+				if len(recipients) == 0 {
+					return fmt.Errorf("--format sops needs at least one --age recipient")
+				}
+			default:
+				return fmt.Errorf("unsupported --format %q (want dotenv|json|yaml|sops)", format)
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			names := args
+			if all {
+				k8s, err := scope.k8s()
+				if err != nil {
+					return err
+				}
+				list, err := k8s.ListManagedSecrets(ctx, scope.Namespace)
+				if err != nil {
+					return err
+				}
+				for _, it := range list.Items {
+					names = append(names, it.Metadata.Name)
+				}
+				if len(names) == 0 {
+					return fmt.Errorf("no secrets in %s", scope.Namespace)
+				}
+			}
+			cli, err := scope.backend()
+			if err != nil {
+				return err
+			}
+			b := &secretBundle{Project: scope.Namespace, Secrets: map[string]*bundledSecret{}}
+			for _, n := range names {
+				s, err := cli.GetSecret(ctx, scope.Namespace, n, true)
+				if err != nil {
+					return fmt.Errorf("read secret %s: %w", n, err)
+				}
+				b.Secrets[n] = bundledFromSummary(s)
+			}
+			out, err := renderBundle(b, format)
+			if err != nil {
+				return err
+			}
+			if format == "sops" {
+				if out, err = sopsEncryptBundle(out, recipients); err != nil {
+					return err
+				}
+			}
+			if file == "" || file == "-" {
+				_, err = os.Stdout.Write(out)
+				return err
+			}
+			if err := os.WriteFile(file, out, 0o600); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Exported %d secrets from %s to %s\n", len(b.Secrets), scope.Namespace, file)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().BoolVar(&all, "all", false, "Export every secret in the Project")
+	cmd.Flags().StringVarP(&format, "format", "o", "yaml", "Output format: dotenv|json|yaml|sops")
+	cmd.Flags().StringArrayVar(&recipients, "age", nil, "age recipient for --format sops (repeatable)")
+	cmd.Flags().StringVarP(&file, "file", "f", "", "Write to this file (mode 0600) instead of stdout")
+	return cmd
+}
+
+func secretsImportFileCmd() *cobra.Command {
+	var namespace, format, name string
+	var prune, dryRun, yes bool
+	cmd := &cobra.Command{
+		Use:   "import-file <file>",
+		Short: "Create or update many secrets from an exported file (requires developer, project-manager, or admin).",
+		Long: `Create or update the secrets in a file written by "kube-dc secrets export", or
+a hand-written file of the same shape:
+
+  secrets:
+    app-config:
+      type: opaque          # optional; used on create
+      data:
+        DATABASE_URL: postgres://...
+
+A .env file is one secret, named with --name. SOPS-encrypted files are
+decrypted with sops using your usual keys (e.g. $SOPS_AGE_KEY_FILE).
+
+The plan is printed first, values masked. Secrets in the file are created, or
+updated to hold exactly the file's keys; updates are check-and-set against the
+version planned against. Sync settings of existing secrets are left alone.
+--prune also deletes the Project's secrets that are not in the file (stored
+values are kept), and needs --yes. It takes a JSON, YAML or SOPS file only: a
+.env file holds one secret, so pruning against it would delete all others.`,
+		Example: `  kube-dc secrets import-file stage.enc.yaml --dry-run
+  kube-dc secrets import-file stage.enc.yaml
+  kube-dc secrets import-file app.env --name app-config
+  kube-dc secrets import-file prod.yaml --prune --yes`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if prune && (name != "" || format == "dotenv") {
+				return fmt.Errorf("--prune needs a JSON, YAML or SOPS file listing the Project's secrets; a dotenv file holds only one")
+			}
+			b, err := readBundleFile(args[0], format, name)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			k8s, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			cli, err := scope.backend()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			list, err := k8s.ListManagedSecrets(ctx, scope.Namespace)
+			if err != nil {
+				return err
+			}
+			existing := map[string]*currentSecret{}
+			for _, it := range list.Items {
+				n := it.Metadata.Name
+				existing[n] = &currentSecret{}
+				if _, inFile := b.Secrets[n]; !inFile {
+					continue
+				}
+				s, err := cli.GetSecret(ctx, scope.Namespace, n, true)
+				if err != nil {
+					return fmt.Errorf("read secret %s: %w", n, err)
+				}
+				existing[n] = currentFromSummary(s)
+			}
+			steps := planSecretImport(b, existing, prune)
+			printImportPlan(scope.Namespace, steps)
+			if dryRun {
+				fmt.Println("Dry run: nothing written.")
+				return nil
+			}
+			for _, s := range steps {
+				if s.Action == "delete" && !yes {
+					fmt.Fprintln(os.Stderr, "Re-run with --yes to confirm.")
+					return fmt.Errorf("not confirmed")
+				}
+			}
+			for _, s := range steps {
+				if err := applyImportStep(scope, k8s, cli, b, s); err != nil {
+					return fmt.Errorf("%s: %w (steps above it were applied)", s.Name, err)
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVar(&format, "format", "", "File format: dotenv|json|yaml (default: by extension)")
+	cmd.Flags().StringVar(&name, "name", "", "Secret name for a dotenv file")
+	cmd.Flags().BoolVar(&prune, "prune", false, "Delete secrets that are not in the file")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the plan without writing")
+	cmd.Flags().BoolVar(&yes, "yes", false, "Confirm --prune deletions")
+	return cmd
+}
+
+func applyImportStep(scope *secretsScope, k8s *k8sapi.Client, cli *backend.Client, b *secretBundle, s importStep) error {
+	ctx, cancel := ctxWithTimeout()
+	defer cancel()
+	switch s.Action {
+	case "create":
+		want := b.Secrets[s.Name]
+		opts := createOpts{Name: s.Name, Namespace: scope.Namespace, Type: fmtCoalesce(want.Type, "opaque"), Description: want.Description}
+		if want.Sync != nil {
+			opts.SyncDisabled = !want.Sync.Enabled
+			opts.SyncTarget, opts.SyncRefresh = want.Sync.Target, want.Sync.Refresh
+			opts.SyncKeysCSV = strings.Join(want.Sync.Keys, ",")
+		}
+		ms, err := buildCreateManagedSecret(opts)
+		if err != nil {
+			return err
+		}
+		if _, err := k8s.CreateManagedSecret(ctx, ms); err != nil {
+			return err
+		}
+		if len(want.Data) == 0 {
+			fmt.Printf("Created %s/%s (no values)\n", scope.Namespace, s.Name)
+			return nil
+		}
+		res, _, err := writeSecretValues(ctx, cli, scope.Namespace, s.Name, want.Data, 0, false)
+		if err != nil {
+			return fmt.Errorf("created, but the first value write failed: %w (re-run import-file to retry)", err)
+		}
+		fmt.Printf("Created %s/%s v%d (%d keys)\n", scope.Namespace, s.Name, res.Version, len(want.Data))
+	case "update":
+		res, _, err := writeSecretValues(ctx, cli, scope.Namespace, s.Name, b.Secrets[s.Name].Data, s.Version, false)
+		if err != nil {
+			var conflict *backend.CASConflictError
+			if errors.As(err, &conflict) {
+				return fmt.Errorf("%w. Re-run import-file to plan against the new version", err)
+			}
+			return err
+		}
+		fmt.Printf("Wrote %s/%s v%d (%d keys)\n", scope.Namespace, s.Name, res.Version, s.Keys)
+	case "delete":
+		if err := k8s.DeleteManagedSecret(ctx, scope.Namespace, s.Name); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted secret %s/%s (stored values preserved)\n", scope.Namespace, s.Name)
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/shalb/kube-dc/cli/internal/backend"
+)
+
+func writeTemp(t *testing.T, name, body string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(body), 0o600); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	return p
+}
+
+func stubSops(t *testing.T, fn func(stdin []byte, args ...string) ([]byte, error)) {
+	t.Helper()
+	orig := runSops
+	runSops = fn
+	t.Cleanup(func() { runSops = orig })
+}
+
+func TestRenderBundle_DotenvRoundTrips(t *testing.T) {
+	b := &secretBundle{Secrets: map[string]*bundledSecret{
+		"app-config": {Data: map[string]string{"B": "x=y", "A": " padded ", "EMPTY": ""}},
+	}}
+	out, err := renderBundle(b, "dotenv")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if got, want := string(out), "A= padded \nB=x=y\nEMPTY=\n"; got != want {
+		t.Errorf("dotenv = %q; want %q", got, want)
+	}
+	back, err := parseEnvData(out, "export")
+	if err != nil {
+		t.Fatalf("re-parse: %v", err)
+	}
+	for k, v := range b.Secrets["app-config"].Data {
+		if back[k] != v {
+			t.Errorf("round trip %s = %q; want %q", k, back[k], v)
+		}
+	}
+}
+
+func TestRenderBundle_DotenvRefusesWhatCannotRoundTrip(t *testing.T) {
+	cases := map[string]*secretBundle{
+		"two secrets": {Secrets: map[string]*bundledSecret{"a": {}, "b": {}}},
+		"multiline":   {Secrets: map[string]*bundledSecret{"a": {Data: map[string]string{"tls.crt": "line1\nline2"}}}},
+		"comment key": {Secrets: map[string]*bundledSecret{"a": {Data: map[string]string{"#K": "v"}}}},
+		"space key":   {Secrets: map[string]*bundledSecret{"a": {Data: map[string]string{"MY KEY": "v"}}}},
+	}
+	for name, b := range cases {
+		if _, err := renderBundle(b, "dotenv"); err == nil {
+			t.Errorf("%s: rendered as dotenv; want refusal", name)
+		}
+	}
+}
+
+func TestReadBundleFile_YAMLRoundTrip(t *testing.T) {
+	b := &secretBundle{Project: "acme-stage", Secrets: map[string]*bundledSecret{
+		"app-config": {Type: "opaque", Sync: &bundledSync{Enabled: true, Target: "app"}, Data: map[string]string{"tls.crt": "line1\nline2\n"}},
+		"db-creds":   {Type: "password"},
+	}}
+	for _, format := range []string{"yaml", "json"} {
+		out, err := renderBundle(b, format)
+		if err != nil {
+			t.Fatalf("%s render: %v", format, err)
+		}
+		got, err := readBundleFile(writeTemp(t, "bundle."+format, string(out)), "", "")
+		if err != nil {
+			t.Fatalf("%s read: %v", format, err)
+		}
+		if got.Secrets["app-config"].Data["tls.crt"] != "line1\nline2\n" || got.Secrets["app-config"].Sync.Target != "app" {
+			t.Errorf("%s app-config = %+v", format, got.Secrets["app-config"])
+		}
+		if got.Secrets["db-creds"].Type != "password" || got.Secrets["db-creds"].Data == nil {
+			t.Errorf("%s db-creds = %+v; want type kept and empty data", format, got.Secrets["db-creds"])
+		}
+	}
+}
+
+func TestReadBundleFile_Dotenv(t *testing.T) {
+	p := writeTemp(t, "app.env", "# comment\nA=1\nB=2\n")
+	if _, err := readBundleFile(p, "", ""); err == nil {
+		t.Error("dotenv without --name accepted")
+	}
+	b, err := readBundleFile(p, "", "app-config")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if d := b.Secrets["app-config"].Data; len(d) != 2 || d["A"] != "1" {
+		t.Errorf("data = %v", d)
+	}
+	if _, err := readBundleFile(writeTemp(t, "b.yaml", "secrets:\n  a: {}\n"), "", "app-config"); err == nil {
+		t.Error("--name with a yaml bundle accepted")
+	}
+}
+
+func TestReadBundleFile_Rejects(t *testing.T) {
+	for name, body := range map[string]string{
+		"no secrets":   "project: x\n",
+		"invalid name": "secrets:\n  Bad_Name:\n    data: {A: '1'}\n",
+		"not yaml":     "secrets: [\n",
+	} {
+		if _, err := readBundleFile(writeTemp(t, "b.yaml", body), "", ""); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestReadBundleFile_DecryptsSops(t *testing.T) {
+	p := writeTemp(t, "stage.enc.yaml", "secrets:\n  app-config:\n    data:\n      A: ENC[AES256_GCM,data:x]\nsops:\n  version: 3.9.0\n")
+	var gotArgs []string
+	stubSops(t, func(_ []byte, args ...string) ([]byte, error) {
+		gotArgs = args
+		return []byte("secrets:\n  app-config:\n    data:\n      A: plain\n"), nil
+	})
+	b, err := readBundleFile(p, "", "")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if b.Secrets["app-config"].Data["A"] != "plain" {
+		t.Errorf("data = %v; want the decrypted value", b.Secrets["app-config"].Data)
+	}
+	if len(gotArgs) == 0 || gotArgs[0] != "--decrypt" || gotArgs[len(gotArgs)-1] != p {
+		t.Errorf("sops args = %v", gotArgs)
+	}
+}
+
+func TestSopsEncryptBundle_RefusesCleartext(t *testing.T) {
+	plain := []byte("secrets:\n  a:\n    data:\n      K: hunter2\n")
+	stubSops(t, func(stdin []byte, args ...string) ([]byte, error) {
+		if !strings.Contains(strings.Join(args, " "), "--age age1one,age1two") {
+			t.Errorf("sops args = %v; want both recipients", args)
+		}
+		return []byte("secrets:\n  a:\n    data:\n      K: ENC[AES256_GCM,data:x]\nsops:\n  age: []\n"), nil
+	})
+	if _, err := sopsEncryptBundle(plain, []string{"age1one", "age1two"}); err != nil {
+		t.Fatalf("encrypted output refused: %v", err)
+	}
+
+	stubSops(t, func([]byte, ...string) ([]byte, error) {
+		return []byte("secrets:\n  a:\n    data:\n      K: hunter2\nsops:\n  age: []\n"), nil
+	})
+	if _, err := sopsEncryptBundle(plain, []string{"age1one"}); err == nil {
+		t.Error("output with a cleartext value accepted")
+	}
+	stubSops(t, func(stdin []byte, _ ...string) ([]byte, error) { return stdin, nil })
+	if _, err := sopsEncryptBundle(plain, []string{"age1one"}); err == nil {
+		t.Error("output without sops metadata accepted")
+	}
+}
+
+// Without /dev/stdin (Windows) sops gets a private file that is gone
+// once it is done.
+func TestSopsEncryptBundle_WithoutDevStdin(t *testing.T) {
+	orig := sopsStdin
+	sopsStdin = ""
+	t.Cleanup(func() { sopsStdin = orig })
+	plain := []byte("secrets:\n  a:\n    data:\n      K: hunter2\n")
+	var in string
+	stubSops(t, func(stdin []byte, args ...string) ([]byte, error) {
+		in = args[len(args)-1]
+		raw, err := os.ReadFile(in)
+		if err != nil || !bytes.Equal(raw, plain) || stdin != nil {
+			t.Errorf("sops input %s: %q, %v (stdin %q)", in, raw, err, stdin)
+		}
+		if fi, err := os.Stat(in); err == nil && runtime.GOOS != "windows" && fi.Mode().Perm() != 0o600 {
+			t.Errorf("input mode = %v", fi.Mode().Perm())
+		}
+		return []byte("secrets:\n  a:\n    data:\n      K: ENC[AES256_GCM,data:x]\nsops:\n  age: []\n"), nil
+	})
+	if _, err := sopsEncryptBundle(plain, []string{"age1one"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(in); !os.IsNotExist(err) {
+		t.Errorf("plaintext input left behind: %v", err)
+	}
+}
+
+func TestCurrentFromSummary_DeletedVersion(t *testing.T) {
+	live := &backend.SecretSummary{Value: &backend.SecretValue{Data: map[string]string{"A": "1"}, Metadata: backend.SecretValueMeta{Version: 3}}}
+	if got := currentFromSummary(live); got.Version != 3 || got.Data["A"] != "1" {
+		t.Errorf("live = %+v", got)
+	}
+	deleted := &backend.SecretSummary{ValueMissing: true, OpenBao: &backend.SecretOpenBao{CurrentVersion: 4}}
+	if got := currentFromSummary(deleted); got.Version != 4 || got.Data != nil {
+		t.Errorf("deleted current version = %+v; want CAS against v4 with no values", got)
+	}
+	if got := currentFromSummary(&backend.SecretSummary{}); got.Version != 0 {
+		t.Errorf("never written = %+v", got)
+	}
+}
+
+// A dotenv file names one secret; pruning against it would delete every
+// other secret in the Project.
+func TestImportFile_PruneRefusesDotenv(t *testing.T) {
+	p := writeTemp(t, "app.env", "A=1\n")
+	cmd := secretsImportFileCmd()
+	cmd.SetArgs([]string{p, "--name", "app-config", "--prune", "--yes"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--prune") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestPlanSecretImport(t *testing.T) {
+	b := &secretBundle{Secrets: map[string]*bundledSecret{
+		"new":     {Data: map[string]string{"A": "1"}},
+		"changed": {Data: map[string]string{"A": "2", "C": "3"}},
+		"same":    {Data: map[string]string{"A": "1"}},
+	}}
+	existing := map[string]*currentSecret{
+		"changed": {Version: 4, Data: map[string]string{"A": "1", "B": "x"}},
+		"same":    {Version: 2, Data: map[string]string{"A": "1"}},
+		"stray":   {},
+	}
+
+	steps := planSecretImport(b, existing, false)
+	want := []struct{ name, action string }{{"changed", "update"}, {"new", "create"}, {"same", "unchanged"}}
+	if len(steps) != len(want) {
+		t.Fatalf("steps = %+v", steps)
+	}
+	for i, w := range want {
+		if steps[i].Name != w.name || steps[i].Action != w.action {
+			t.Errorf("step %d = %s %s; want %s %s", i, steps[i].Name, steps[i].Action, w.name, w.action)
+		}
+	}
+	if steps[0].Version != 4 || len(steps[0].Changes) != 3 {
+		t.Errorf("update step = %+v; want v4 with 3 key changes", steps[0])
+	}
+	for _, c := range steps[0].Changes {
+		if c.From != "" || c.To != "" {
+			t.Errorf("plan change %+v carries a value", c)
+		}
+	}
+
+	steps = planSecretImport(b, existing, true)
+	if last := steps[len(steps)-1]; last.Name != "stray" || last.Action != "delete" {
+		t.Errorf("prune step = %+v; want delete stray", last)
+	}
+}
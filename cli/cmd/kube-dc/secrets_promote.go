@@ -0,0 +1,276 @@
+// `kube-dc secrets promote` — copy a ManagedSecret's values from one
+// Project to another (stage → prod) as a new version of the target,
+// recording where they came from in the target's custom metadata and
+// checking afterwards that the backend stored it. The target is created
+// with the source's type and sync settings when missing; an existing
+// target keeps its own. A target changed since its last promote has
+// diverged, and is only overwritten with --force.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/backend"
+	"github.com/shalb/kube-dc/cli/internal/jwt"
+	"github.com/spf13/cobra"
+)
+
+// Custom metadata keys a promote writes on the target.
+const (
+	promotedFromProjectKey = "promoted-from-project"
+	promotedFromVersionKey = "promoted-from-version"
+	promotedByKey          = "promoted-by"
+	promotedAtKey          = "promoted-at"
+	// promotedVersionKey is the target version the promote wrote; the
+	// divergence check compares it with the current version.
+	promotedVersionKey = "promoted-version"
+)
+
+// promotionData is what the target's new version holds: the source
+// data, or with keys set, the target's data with just those keys taken
+// from the source. Pure — no I/O.
+func promotionData(source map[string]string, keys []string, target map[string]string) (map[string]string, error) {
+	out := map[string]string{}
+	if len(keys) == 0 {
+		for k, v := range source {
+			out[k] = v
+		}
+		return out, nil
+	}
+	for k, v := range target {
+		out[k] = v
+	}
+	for _, k := range keys {
+		v, ok := source[k]
+		if !ok {
+			return nil, fmt.Errorf("source has no key %q", k)
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+// promotionDiverged reports whether the target's current value was
+// written by something other than the last promote. A target with no
+// value yet has nothing to lose.
+func promotionDiverged(target *backend.SecretSummary) (bool, string) {
+	if target.Value == nil || target.ValueMissing {
+		return false, ""
+	}
+	current := target.Value.Metadata.Version
+	var md map[string]string
+	if target.OpenBao != nil {
+		md = target.OpenBao.CustomMetadata
+	}
+	promoted := md[promotedVersionKey]
+	switch {
+	case promoted == "":
+		return true, fmt.Sprintf("v%d was not written by a promote", current)
+	case promoted != strconv.Itoa(current):
+		return true, fmt.Sprintf("changed since the last promote (promoted v%s, now v%d)", promoted, current)
+	}
+	return false, ""
+}
+
+func promotionMetadata(fromProject string, fromVersion int, actor string, targetVersion int, at time.Time) map[string]string {
+	return map[string]string{
+		promotedFromProjectKey: fromProject,
+		promotedFromVersionKey: strconv.Itoa(fromVersion),
+		promotedByKey:          actor,
+		promotedAtKey:          at.UTC().Format(time.RFC3339),
+		promotedVersionKey:     strconv.Itoa(targetVersion),
+	}
+}
+
+// unrecordedPromotionKeys lists the provenance keys of want that the
+// target's stored custom metadata lacks or holds differently, sorted.
+// Pure — no I/O.
+func unrecordedPromotionKeys(stored, want map[string]string) []string {
+	var out []string
+	for k, v := range want {
+		if stored[k] != v {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func secretsPromoteCmd() *cobra.Command {
+	var from, to, versionFlag string
+	var keys []string
+	var force, dryRun bool
+	cmd := &cobra.Command{
+		Use:   "promote <name> --from <project> [--to <project>]",
+		Short: "Copy a secret's values to another Project (requires developer, project-manager, or admin in both).",
+		Long: `Copy the current (or --version) values of a secret from one Project to the same
+secret in another, as a new version. --to defaults to the current Project.
+--keys copies only the named keys and keeps the target's others.
+
+A missing target is created with the source's type, description and sync
+settings; an existing target keeps its own. The target's custom metadata
+records the source Project and version, who promoted, and when.
+
+If the target was changed since its last promote — or was never promoted —
+it has diverged and promote refuses; review it with "kube-dc secrets versions"
+and "kube-dc secrets diff", then re-run with --force to overwrite it. The
+write is check-and-set.`,
+		Example: `  kube-dc secrets promote app-config --from stage --to prod --dry-run
+  kube-dc secrets promote app-config --from stage --to prod
+  kube-dc secrets promote app-config --from stage --to prod --version v7 --keys API_KEY`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			version := 0
+			if versionFlag != "" {
+				var err error
+				if version, err = parseSecretVersion(versionFlag); err != nil {
+					return fmt.Errorf("--version: %w", err)
+				}
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope("")
+			if err != nil {
+				return err
+			}
+			srcNS := projectNamespace(scope.Realm, from, scope.Namespace)
+			dstNS := projectNamespace(scope.Realm, to, scope.Namespace)
+			if srcNS == dstNS {
+				return fmt.Errorf("--from and --to are the same Project (%s)", srcNS)
+			}
+			cli, err := scope.backend()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+
+			src, err := cli.GetSecret(ctx, srcNS, name, true)
+			if err != nil {
+				return fmt.Errorf("read %s/%s: %w", srcNS, name, err)
+			}
+			if src.Value == nil || src.ValueMissing {
+				return fmt.Errorf("%s/%s has no stored value to promote", srcNS, name)
+			}
+			srcVersion, srcData := src.Value.Metadata.Version, src.Value.Data
+			if version > 0 && version != srcVersion {
+				v, err := cli.GetSecretVersion(ctx, srcNS, name, version)
+				if err != nil {
+					return fmt.Errorf("read %s/%s v%d: %w", srcNS, name, version, err)
+				}
+				srcVersion, srcData = version, v.Data
+			}
+
+			dst, err := cli.GetSecret(ctx, dstNS, name, true)
+			create := backend.IsNotFound(err)
+			if err != nil && !create {
+				return fmt.Errorf("read %s/%s: %w", dstNS, name, err)
+			}
+			current := 0
+			var curData map[string]string
+			if !create {
+				// A soft-deleted current version still takes a number:
+				// the write goes on top of it, as v(current+1).
+				cur := currentFromSummary(dst)
+				current, curData = cur.Version, cur.Data
+				if diverged, why := promotionDiverged(dst); diverged {
+					if !force {
+						return fmt.Errorf("%s/%s has diverged: %s. Review it with `kube-dc secrets versions %s -n %s` and re-run with --force to overwrite", dstNS, name, why, name, dstNS)
+					}
+					fmt.Printf("%s/%s has diverged (%s); overwriting (--force).\n", dstNS, name, why)
+				}
+			}
+			data, err := promotionData(srcData, keys, curData)
+			if err != nil {
+				return fmt.Errorf("%s/%s v%d: %w", srcNS, name, srcVersion, err)
+			}
+
+			changes := diffSecretData(curData, data, false)
+			switch {
+			case create:
+				fmt.Printf("Promoting %s v%d from %s to %s (new secret, %d keys)\n", name, srcVersion, srcNS, dstNS, len(data))
+			case len(changes) == 0:
+				fmt.Printf("%s/%s v%d already holds these values; nothing to do.\n", dstNS, name, current)
+				return nil
+			default:
+				fmt.Printf("Promoting %s v%d from %s to %s (v%d → v%d):\n", name, srcVersion, srcNS, dstNS, current, current+1)
+				printKeyChanges(changes, false)
+			}
+			if dryRun {
+				fmt.Println("Dry run: nothing written.")
+				return nil
+			}
+
+			if create {
+				opts := createOpts{
+					Name:         name,
+					Namespace:    dstNS,
+					Type:         fmtCoalesce(src.Type, "opaque"),
+					Description:  src.Description,
+					SyncDisabled: !src.Sync.Enabled,
+					SyncTarget:   src.Sync.TargetSecretName,
+					SyncRefresh:  src.Sync.RefreshInterval,
+					SyncKeysCSV:  strings.Join(src.Sync.Keys, ","),
+				}
+				ms, err := buildCreateManagedSecret(opts)
+				if err != nil {
+					return err
+				}
+				k8s, err := scope.k8s()
+				if err != nil {
+					return err
+				}
+				if _, err := k8s.CreateManagedSecret(ctx, ms); err != nil {
+					return fmt.Errorf("create %s/%s: %w", dstNS, name, err)
+				}
+			}
+			actor := "unknown"
+			if claims, err := jwt.ParseToken(scope.AccessToken); err == nil {
+				actor = fmtCoalesce(claims.Email, claims.PreferredUsername, claims.Subject, actor)
+			}
+			md := promotionMetadata(strings.TrimPrefix(srcNS, scope.Realm+"-"), srcVersion, actor, current+1, time.Now())
+			res, err := cli.PutSecretValuesWithOptions(ctx, dstNS, name, data, backend.PutSecretOptions{CAS: &current, CustomMetadata: md})
+			if err != nil {
+				var conflict *backend.CASConflictError
+				if errors.As(err, &conflict) {
+					return fmt.Errorf("%w. Re-run promote to check the new version", err)
+				}
+				if create {
+					return fmt.Errorf("%s/%s created but the value write failed: %w (re-run promote to retry)", dstNS, name, err)
+				}
+				return err
+			}
+			fmt.Printf("Wrote %s/%s v%d from %s v%d (%d keys)\n", dstNS, name, res.Version, srcNS, srcVersion, len(data))
+
+			// Without the provenance the next promote sees a diverged
+			// target, so a backend that dropped it is an error.
+			after, err := cli.GetSecret(ctx, dstNS, name, false)
+			if err != nil {
+				return fmt.Errorf("%s/%s v%d was written, but its provenance could not be checked: %w", dstNS, name, res.Version, err)
+			}
+			var stored map[string]string
+			if after.OpenBao != nil {
+				stored = after.OpenBao.CustomMetadata
+			}
+			if missing := unrecordedPromotionKeys(stored, md); len(missing) > 0 {
+				return fmt.Errorf("%s/%s v%d was written, but the backend did not store its provenance (%s); the next promote will see it as diverged and need --force",
+					dstNS, name, res.Version, strings.Join(missing, ", "))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "Source Project (required)")
+	cmd.Flags().StringVar(&to, "to", "", "Target Project (default: current Project)")
+	cmd.Flags().StringVar(&versionFlag, "version", "", "Source version to promote, e.g. v7 (default: current)")
+	cmd.Flags().StringSliceVar(&keys, "keys", nil, "Promote only these keys, keeping the target's others (comma-separated)")
+	cmd.Flags().BoolVar(&force, "force", false, "Overwrite a target that has diverged")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the key changes without writing")
+	_ = cmd.MarkFlagRequired("from")
+	return cmd
+}
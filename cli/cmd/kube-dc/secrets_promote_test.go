@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/backend"
+)
+
+func TestPromotionData(t *testing.T) {
+	source := map[string]string{"A": "src-a", "B": "src-b"}
+	target := map[string]string{"A": "dst-a", "C": "dst-c"}
+
+	all, err := promotionData(source, nil, target)
+	if err != nil || len(all) != 2 || all["A"] != "src-a" || all["B"] != "src-b" {
+		t.Errorf("whole promote = %v, %v; want exactly the source", all, err)
+	}
+	all["A"] = "mutated"
+	if source["A"] != "src-a" {
+		t.Error("promotionData aliased the source map")
+	}
+
+	some, err := promotionData(source, []string{"A"}, target)
+	if err != nil || len(some) != 2 || some["A"] != "src-a" || some["C"] != "dst-c" {
+		t.Errorf("--keys A = %v, %v; want A from source and C kept", some, err)
+	}
+	if _, err := promotionData(source, []string{"Z"}, target); err == nil {
+		t.Error("a key missing from the source was accepted")
+	}
+}
+
+func promotedTarget(version int, md map[string]string) *backend.SecretSummary {
+	return &backend.SecretSummary{
+		OpenBao: &backend.SecretOpenBao{CurrentVersion: version, CustomMetadata: md},
+		Value:   &backend.SecretValue{Data: map[string]string{"A": "1"}, Metadata: backend.SecretValueMeta{Version: version}},
+	}
+}
+
+func TestPromotionDiverged(t *testing.T) {
+	cases := []struct {
+		name     string
+		target   *backend.SecretSummary
+		diverged bool
+		why      string
+	}{
+		{"no value yet", &backend.SecretSummary{ValueMissing: true}, false, ""},
+		{"last write was the promote", promotedTarget(4, map[string]string{promotedVersionKey: "4"}), false, ""},
+		{"never promoted", promotedTarget(2, nil), true, "not written by a promote"},
+		{"put after promote", promotedTarget(5, map[string]string{promotedVersionKey: "4"}), true, "promoted v4, now v5"},
+	}
+	for _, c := range cases {
+		diverged, why := promotionDiverged(c.target)
+		if diverged != c.diverged || !strings.Contains(why, c.why) {
+			t.Errorf("%s: diverged = %v (%q); want %v (%q)", c.name, diverged, why, c.diverged, c.why)
+		}
+	}
+}
+
+func TestPromotionMetadata(t *testing.T) {
+	at := time.Date(2026, 10, 16, 12, 0, 0, 0, time.FixedZone("CEST", 2*3600))
+	md := promotionMetadata("stage", 7, "dev@example.com", 4, at)
+	want := map[string]string{
+		promotedFromProjectKey: "stage",
+		promotedFromVersionKey: "7",
+		promotedByKey:          "dev@example.com",
+		promotedAtKey:          "2026-10-16T10:00:00Z",
+		promotedVersionKey:     "4",
+	}
+	for k, v := range want {
+		if md[k] != v {
+			t.Errorf("%s = %q; want %q", k, md[k], v)
+		}
+	}
+	// The metadata a promote writes makes the next check pass.
+	if diverged, why := promotionDiverged(promotedTarget(4, md)); diverged {
+		t.Errorf("fresh promote reads as diverged: %s", why)
+	}
+}
+
+func TestUnrecordedPromotionKeys(t *testing.T) {
+	md := promotionMetadata("stage", 7, "dev@example.com", 4, time.Now())
+	if got := unrecordedPromotionKeys(md, md); len(got) != 0 {
+		t.Errorf("all stored: %v", got)
+	}
+	if got := unrecordedPromotionKeys(nil, md); len(got) != len(md) {
+		t.Errorf("nothing stored: %v", got)
+	}
+	stale := map[string]string{}
+	for k, v := range md {
+		stale[k] = v
+	}
+	stale[promotedVersionKey] = "3"
+	if got := unrecordedPromotionKeys(stale, md); len(got) != 1 || got[0] != promotedVersionKey {
+		t.Errorf("stale version: %v", got)
+	}
+}
@@ -0,0 +1,431 @@
+// `kube-dc secrets run` — run a command with stored secret values in
+// its environment. Values are read through the backend (the same
+// includeValue=true path as `secrets get --value`, so the read is
+// audited) and only ever live in this process and the child's
+// environment: nothing is written to disk or printed. --watch polls
+// each secret's currentVersion and restarts the child on a change.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/backend"
+	"github.com/spf13/cobra"
+)
+
+// envNameRE is what a POSIX shell accepts as a variable name.
+var envNameRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// runSource is one `secrets run` argument: every key of Secret, or
+// with Key set, the single key Secret/Key exported as Var.
+type runSource struct {
+	Secret string
+	Key    string
+	Var    string
+}
+
+// parseRunSources parses the arguments before --: `<secret>` or
+// `VAR=<secret>/<key>`.
+func parseRunSources(args []string) ([]runSource, error) {
+	var out []runSource
+	seen := map[string]bool{}
+	for _, a := range args {
+		v, ref, explicit := strings.Cut(a, "=")
+		if !explicit {
+			if !projectNameRE.MatchString(a) {
+				return nil, fmt.Errorf("invalid secret name %q", a)
+			}
+			if !seen[a] {
+				out = append(out, runSource{Secret: a})
+				seen[a] = true
+			}
+			continue
+		}
+		secret, key, ok := strings.Cut(ref, "/")
+		if !envNameRE.MatchString(v) || !ok || !projectNameRE.MatchString(secret) || key == "" {
+			return nil, fmt.Errorf("invalid mapping %q: use VAR=<secret>/<key>", a)
+		}
+		out = append(out, runSource{Secret: secret, Key: key, Var: v})
+	}
+	return out, nil
+}
+
+// parseRenames turns repeated --rename KEY=VAR flags into a map.
+func parseRenames(values []string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, r := range values {
+		key, v, ok := strings.Cut(r, "=")
+		if !ok || key == "" || !envNameRE.MatchString(v) {
+			return nil, fmt.Errorf("invalid --rename %q: use KEY=VAR", r)
+		}
+		out[key] = v
+	}
+	return out, nil
+}
+
+// envName turns a secret key into a variable name: prefix plus the key,
+// with every character a shell would not accept replaced by '_'.
+func envName(prefix, key string) string {
+	b := []byte(prefix + key)
+	for i, c := range b {
+		if !(c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9') {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 || b[0] >= '0' && b[0] <= '9' {
+		return "_" + string(b)
+	}
+	return string(b)
+}
+
+// buildRunEnv maps the fetched values (secret → key → value) to
+// environment variables. Whole secrets export every key as
+// prefix+key unless --rename names it; explicit VAR=secret/key
+// mappings take precedence over both. Two keys landing on one
+// variable is an error rather than a silent pick. Pure — no I/O.
+func buildRunEnv(sources []runSource, values map[string]map[string]string, prefix string, renames map[string]string) (map[string]string, error) {
+	env := map[string]string{}
+	from := map[string]string{}
+	renamed := map[string]bool{}
+	for _, s := range sources {
+		if s.Key != "" {
+			continue
+		}
+		data := values[s.Secret]
+		keys := make([]string, 0, len(data))
+		for k := range data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v, ok := renames[k]
+			if ok {
+				renamed[k] = true
+			} else {
+				v = envName(prefix, k)
+			}
+			if prev, dup := from[v]; dup {
+				return nil, fmt.Errorf("%s comes from both %s and %s/%s; use --rename or VAR=<secret>/<key>", v, prev, s.Secret, k)
+			}
+			env[v], from[v] = data[k], s.Secret+"/"+k
+		}
+	}
+	for k := range renames {
+		if !renamed[k] {
+			return nil, fmt.Errorf("--rename %s: no secret being exported has that key", k)
+		}
+	}
+	explicit := map[string]bool{}
+	for _, s := range sources {
+		if s.Key == "" {
+			continue
+		}
+		if explicit[s.Var] {
+			return nil, fmt.Errorf("%s is mapped twice", s.Var)
+		}
+		v, ok := values[s.Secret][s.Key]
+		if !ok {
+			return nil, fmt.Errorf("secret %s has no key %q", s.Secret, s.Key)
+		}
+		env[s.Var], explicit[s.Var] = v, true
+	}
+	return env, nil
+}
+
+// secretFetcher reads one secret, with or without its values.
+type secretFetcher func(ctx context.Context, name string, includeValue bool) (*backend.SecretSummary, error)
+
+// secretsRunner runs argv with secret values in its environment and,
+// with watch set, restarts it when one of the secrets changes version.
+type secretsRunner struct {
+	fetch secretFetcher
+	// refresh, when set, renews the credentials fetch uses before each
+	// --watch poll; the run can outlive the token it started with.
+	refresh  func() error
+	sources  []runSource
+	prefix   string
+	renames  map[string]string
+	argv     []string
+	watch    bool
+	interval time.Duration
+	grace    time.Duration
+	stderr   io.Writer
+
+	versions map[string]int
+}
+
+func (r *secretsRunner) secretNames() []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, s := range r.sources {
+		if !seen[s.Secret] {
+			names = append(names, s.Secret)
+			seen[s.Secret] = true
+		}
+	}
+	return names
+}
+
+// load fetches every secret's values and builds the environment,
+// recording the versions it read.
+func (r *secretsRunner) load(ctx context.Context) (map[string]string, map[string]int, error) {
+	values := map[string]map[string]string{}
+	versions := map[string]int{}
+	for _, name := range r.secretNames() {
+		s, err := r.fetch(ctx, name, true)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read secret %s: %w", name, err)
+		}
+		if s.Value == nil || s.ValueMissing {
+			return nil, nil, fmt.Errorf("secret %s has no stored value yet; write one with `kube-dc secrets put %s`", name, name)
+		}
+		values[name] = s.Value.Data
+		versions[name] = s.Value.Metadata.Version
+	}
+	env, err := buildRunEnv(r.sources, values, r.prefix, r.renames)
+	if err != nil {
+		return nil, nil, err
+	}
+	return env, versions, nil
+}
+
+// changed returns the secrets whose currentVersion moved since the
+// last load. A failed read is reported and treated as unchanged.
+func (r *secretsRunner) changed(ctx context.Context) []string {
+	var out []string
+	for _, name := range r.secretNames() {
+		s, err := r.fetch(ctx, name, false)
+		if err != nil {
+			fmt.Fprintf(r.stderr, "kube-dc: check secret %s: %v\n", name, err)
+			continue
+		}
+		if s.OpenBao != nil && s.OpenBao.CurrentVersion != r.versions[name] {
+			out = append(out, fmt.Sprintf("%s v%d", name, s.OpenBao.CurrentVersion))
+		}
+	}
+	return out
+}
+
+// run starts the child and returns its exit code. Interrupt and
+// terminate signals are passed on to the child and end the run once
+// it exits.
+func (r *secretsRunner) run(ctx context.Context) (int, error) {
+	env, versions, err := r.load(ctx)
+	if err != nil {
+		return 0, err
+	}
+	r.versions = versions
+	bin, err := exec.LookPath(r.argv[0])
+	if err != nil {
+		return 0, err
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	var tick <-chan time.Time
+	if r.watch {
+		t := time.NewTicker(r.interval)
+		defer t.Stop()
+		tick = t.C
+	}
+
+	child, exited, err := startSecretsChild(bin, r.argv, env)
+	if err != nil {
+		return 0, err
+	}
+	stopping := false
+	for {
+		select {
+		case err := <-exited:
+			return childExitCode(err)
+		case sig := <-sigs:
+			stopping = true
+			_ = child.Process.Signal(sig)
+		case <-tick:
+			if stopping {
+				continue
+			}
+			if r.refresh != nil {
+				if err := r.refresh(); err != nil {
+					fmt.Fprintf(r.stderr, "kube-dc: cannot check for new versions: %v\n", err)
+					continue
+				}
+			}
+			moved := r.changed(ctx)
+			if len(moved) == 0 {
+				continue
+			}
+			next, versions, err := r.load(ctx)
+			if err != nil {
+				fmt.Fprintf(r.stderr, "kube-dc: %s changed but cannot be loaded (%v); keeping %s running\n", strings.Join(moved, ", "), err, r.argv[0])
+				continue
+			}
+			fmt.Fprintf(r.stderr, "kube-dc: %s changed; restarting %s\n", strings.Join(moved, ", "), r.argv[0])
+			stopSecretsChild(child, exited, r.grace)
+			r.versions = versions
+			if child, exited, err = startSecretsChild(bin, r.argv, next); err != nil {
+				return 0, err
+			}
+		}
+	}
+}
+
+func startSecretsChild(bin string, argv []string, env map[string]string) (*exec.Cmd, <-chan error, error) {
+	child := exec.Command(bin, argv[1:]...)
+	// Later entries win, so the secrets override the inherited
+	// environment.
+	child.Env = os.Environ()
+	for _, k := range sortedEnvKeys(env) {
+		child.Env = append(child.Env, k+"="+env[k])
+	}
+	child.Stdin, child.Stdout, child.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := child.Start(); err != nil {
+		return nil, nil, fmt.Errorf("run %s: %w", argv[0], err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- child.Wait() }()
+	return child, exited, nil
+}
+
+// stopSecretsChild asks the child to terminate and kills it after
+// grace.
+func stopSecretsChild(child *exec.Cmd, exited <-chan error, grace time.Duration) {
+	if err := child.Process.Signal(syscall.SIGTERM); err != nil {
+		_ = child.Process.Kill()
+	}
+	select {
+	case <-exited:
+	case <-time.After(grace):
+		_ = child.Process.Kill()
+		<-exited
+	}
+}
+
+// childExitCode passes the child's status through; a child ended by a
+// signal counts as 1.
+func childExitCode(err error) (int, error) {
+	var ee *exec.ExitError
+	switch {
+	case errors.As(err, &ee):
+		if code := ee.ExitCode(); code > 0 {
+			return code, nil
+		}
+		return 1, nil
+	case err != nil:
+		return 0, err
+	}
+	return 0, nil
+}
+
+func sortedEnvKeys(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func secretsRunCmd() *cobra.Command {
+	var namespace, prefix string
+	var renameFlags []string
+	var watch bool
+	var interval, grace time.Duration
+	cmd := &cobra.Command{
+		Use:   "run <secret|VAR=secret/key>... -- <command> [args...]",
+		Short: "Run a command with stored values as environment variables (requires developer, project-manager, or admin).",
+		Long: `Read one or more secrets and run a command with their values as environment
+variables. Values are never written to disk or printed.
+
+A bare secret name exports every key, under its own name with characters a
+shell does not accept replaced by '_'. --prefix is prepended to those names and
+--rename KEY=VAR exports one key under another name. VAR=<secret>/<key> exports
+a single key as VAR and takes precedence. Two keys landing on one variable is
+an error. The values override variables already in the environment.
+
+With --watch, each secret's current version is checked every --interval,
+refreshing the login token as it expires. When
+one changes, the command is stopped (SIGTERM, then SIGKILL after --grace) and
+started again with the new values. The command's exit status is passed through.`,
+		Example: `  kube-dc secrets run app-config -- ./server
+  kube-dc secrets run app-config --prefix APP_ --rename DATABASE_URL=DB_URL -- npm start
+  kube-dc secrets run app-config PGPASSWORD=orders-db/password -- psql -h localhost
+  kube-dc secrets run app-config --watch --interval 1m -- ./server`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dash := cmd.ArgsLenAtDash()
+			switch {
+			case dash < 0:
+				return errors.New("pass the command to run after --")
+			case dash == 0:
+				return errors.New("name at least one secret before --")
+			case dash == len(args):
+				return errors.New("pass the command to run after --")
+			}
+			sources, err := parseRunSources(args[:dash])
+			if err != nil {
+				return err
+			}
+			renames, err := parseRenames(renameFlags)
+			if err != nil {
+				return err
+			}
+			if watch && interval < time.Second {
+				return fmt.Errorf("--interval %s is too short; use at least 1s", interval)
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			r := &secretsRunner{
+				fetch: func(ctx context.Context, name string, includeValue bool) (*backend.SecretSummary, error) {
+					cli, err := scope.backend()
+					if err != nil {
+						return nil, err
+					}
+					ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+					defer cancel()
+					return cli.GetSecret(ctx, scope.Namespace, name, includeValue)
+				},
+				refresh:  scope.refreshToken,
+				sources:  sources,
+				prefix:   prefix,
+				renames:  renames,
+				argv:     args[dash:],
+				watch:    watch,
+				interval: interval,
+				grace:    grace,
+				stderr:   os.Stderr,
+			}
+			code, err := r.run(context.Background())
+			if err != nil {
+				return err
+			}
+			if code != 0 {
+				return &exitCodeError{code: code}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context)")
+	cmd.Flags().StringVar(&prefix, "prefix", "", "Prefix for variables exported from whole secrets")
+	cmd.Flags().StringArrayVar(&renameFlags, "rename", nil, "KEY=VAR: export a key under another name (repeatable)")
+	cmd.Flags().BoolVar(&watch, "watch", false, "Restart the command when a secret's version changes")
+	cmd.Flags().DurationVar(&interval, "interval", 30*time.Second, "How often --watch checks the versions")
+	cmd.Flags().DurationVar(&grace, "grace", 10*time.Second, "How long a restart waits for the command to exit before killing it")
+	return cmd
+}
@@ -0,0 +1,286 @@
+// Tests for `kube-dc secrets run`: argument parsing, the key → env
+// var mapping, and the --watch restart against a fake fetcher and a
+// real shell child.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/backend"
+)
+
+func TestParseRunSources(t *testing.T) {
+	got, err := parseRunSources([]string{"app-config", "PGPASSWORD=orders-db/password", "app-config", "TLS=certs/tls.crt"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	want := []runSource{
+		{Secret: "app-config"},
+		{Secret: "orders-db", Key: "password", Var: "PGPASSWORD"},
+		{Secret: "certs", Key: "tls.crt", Var: "TLS"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("sources = %+v; want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("source %d = %+v; want %+v", i, got[i], want[i])
+		}
+	}
+
+	for _, bad := range []string{"Bad_Name", "1VAR=s/k", "VAR=s", "VAR=s/", "VAR=/k", "A-B=s/k"} {
+		if _, err := parseRunSources([]string{bad}); err == nil {
+			t.Errorf("parseRunSources(%q) = nil error; want rejection", bad)
+		}
+	}
+}
+
+func TestParseRenames(t *testing.T) {
+	got, err := parseRenames([]string{"DATABASE_URL=DB_URL", "tls.crt=TLS_CERT"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if got["DATABASE_URL"] != "DB_URL" || got["tls.crt"] != "TLS_CERT" {
+		t.Errorf("renames = %v", got)
+	}
+	for _, bad := range []string{"KEY", "=VAR", "KEY=bad-var"} {
+		if _, err := parseRenames([]string{bad}); err == nil {
+			t.Errorf("parseRenames(%q) = nil error; want rejection", bad)
+		}
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	cases := []struct{ prefix, key, want string }{
+		{"", "DATABASE_URL", "DATABASE_URL"},
+		{"", "tls.crt", "tls_crt"},
+		{"APP_", "api-key", "APP_api_key"},
+		{"", "2fa", "_2fa"},
+	}
+	for _, c := range cases {
+		if got := envName(c.prefix, c.key); got != c.want {
+			t.Errorf("envName(%q, %q) = %q; want %q", c.prefix, c.key, got, c.want)
+		}
+	}
+}
+
+func TestBuildRunEnv(t *testing.T) {
+	values := map[string]map[string]string{
+		"app-config": {"DATABASE_URL": "postgres://x", "API_KEY": "k1", "tls.crt": "pem"},
+		"orders-db":  {"password": "pw", "API_KEY": "k2"},
+	}
+
+	env, err := buildRunEnv([]runSource{
+		{Secret: "app-config"},
+		{Secret: "orders-db", Key: "password", Var: "PGPASSWORD"},
+		{Secret: "orders-db", Key: "API_KEY", Var: "APP_API_KEY"},
+	}, values, "APP_", map[string]string{"DATABASE_URL": "DB_URL"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	want := map[string]string{
+		"DB_URL":      "postgres://x",
+		"APP_API_KEY": "k2", // explicit mapping wins over the prefixed key
+		"APP_tls_crt": "pem",
+		"PGPASSWORD":  "pw",
+	}
+	if len(env) != len(want) {
+		t.Errorf("env = %v; want %v", env, want)
+	}
+	for k, v := range want {
+		if env[k] != v {
+			t.Errorf("env[%s] = %q; want %q", k, env[k], v)
+		}
+	}
+
+	// Two whole secrets exporting API_KEY collide.
+	_, err = buildRunEnv([]runSource{{Secret: "app-config"}, {Secret: "orders-db"}}, values, "", nil)
+	if err == nil || !strings.Contains(err.Error(), "API_KEY") {
+		t.Errorf("collision err = %v; want one naming API_KEY", err)
+	}
+
+	// A rename that matches nothing is a typo, not a no-op.
+	if _, err := buildRunEnv([]runSource{{Secret: "app-config"}}, values, "", map[string]string{"DB_URI": "X"}); err == nil {
+		t.Error("unmatched --rename accepted")
+	}
+
+	if _, err := buildRunEnv([]runSource{{Secret: "orders-db", Key: "user", Var: "PGUSER"}}, values, "", nil); err == nil {
+		t.Error("missing key accepted")
+	}
+	if _, err := buildRunEnv([]runSource{
+		{Secret: "orders-db", Key: "password", Var: "PW"},
+		{Secret: "app-config", Key: "API_KEY", Var: "PW"},
+	}, values, "", nil); err == nil {
+		t.Error("variable mapped twice accepted")
+	}
+}
+
+func TestSecretsRunner_NoValue(t *testing.T) {
+	r := &secretsRunner{
+		fetch: func(context.Context, string, bool) (*backend.SecretSummary, error) {
+			return &backend.SecretSummary{ValueMissing: true}, nil
+		},
+		sources: []runSource{{Secret: "app-config"}},
+		argv:    []string{"true"},
+		stderr:  io.Discard,
+	}
+	if _, err := r.run(context.Background()); err == nil || !strings.Contains(err.Error(), "no stored value") {
+		t.Errorf("err = %v; want no stored value", err)
+	}
+}
+
+func TestSecretsRunner_WatchRestartsOnNewVersion(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("needs sh")
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	t.Setenv("RUN_OUT", out)
+
+	var version atomic.Int64
+	version.Store(1)
+	fetch := func(_ context.Context, name string, includeValue bool) (*backend.SecretSummary, error) {
+		v := int(version.Load())
+		s := &backend.SecretSummary{OpenBao: &backend.SecretOpenBao{CurrentVersion: v}}
+		if includeValue {
+			token := "old"
+			if v > 1 {
+				token = "new"
+			}
+			s.Value = &backend.SecretValue{Data: map[string]string{"TOKEN": token}}
+			s.Value.Metadata.Version = v
+		}
+		return s, nil
+	}
+	r := &secretsRunner{
+		fetch:   fetch,
+		sources: []runSource{{Secret: "app-config"}},
+		// The first child waits to be restarted; the second exits 3.
+		argv:     []string{"sh", "-c", `echo "$TOKEN" >> "$RUN_OUT"; [ "$TOKEN" = new ] && exit 3; exec sleep 10`},
+		watch:    true,
+		interval: 20 * time.Millisecond,
+		grace:    2 * time.Second,
+		stderr:   io.Discard,
+	}
+
+	go func() {
+		// Bump the version once the first child has started.
+		for {
+			if b, _ := os.ReadFile(out); len(b) > 0 {
+				version.Store(2)
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	done := make(chan struct{})
+	var code int
+	var err error
+	go func() {
+		code, err = r.run(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(8 * time.Second):
+		t.Fatal("runner did not restart the child")
+	}
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if code != 3 {
+		t.Errorf("exit code = %d; want the child's 3", code)
+	}
+	b, _ := os.ReadFile(out)
+	if got := string(b); got != "old\nnew\n" {
+		t.Errorf("child runs = %q; want old then new", got)
+	}
+}
+
+// A --watch run outlives the token it started with: the poll renews it
+// first, so a backend that starts rejecting the old token still sees the
+// new version.
+func TestSecretsRunner_WatchRefreshesExpiredToken(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("needs sh")
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	t.Setenv("RUN_OUT", out)
+
+	var version, valid, held atomic.Int64
+	version.Store(1)
+	valid.Store(1)
+	held.Store(1)
+	var refreshes atomic.Int64
+	fetch := func(_ context.Context, name string, includeValue bool) (*backend.SecretSummary, error) {
+		if held.Load() != valid.Load() {
+			return nil, &backend.APIError{Status: 401, Message: "token expired"}
+		}
+		v := int(version.Load())
+		s := &backend.SecretSummary{OpenBao: &backend.SecretOpenBao{CurrentVersion: v}}
+		if includeValue {
+			s.Value = &backend.SecretValue{Data: map[string]string{"TOKEN": fmt.Sprintf("v%d", v)}}
+			s.Value.Metadata.Version = v
+		}
+		return s, nil
+	}
+	r := &secretsRunner{
+		fetch: fetch,
+		refresh: func() error {
+			refreshes.Add(1)
+			held.Store(valid.Load())
+			return nil
+		},
+		sources:  []runSource{{Secret: "app-config"}},
+		argv:     []string{"sh", "-c", `echo "$TOKEN" >> "$RUN_OUT"; [ "$TOKEN" = v2 ] && exit 3; exec sleep 10`},
+		watch:    true,
+		interval: 20 * time.Millisecond,
+		grace:    2 * time.Second,
+		stderr:   io.Discard,
+	}
+
+	go func() {
+		// Once the first child runs, the token expires and a new
+		// version lands.
+		for {
+			if b, _ := os.ReadFile(out); len(b) > 0 {
+				valid.Store(2)
+				version.Store(2)
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	done := make(chan struct{})
+	var code int
+	var err error
+	go func() {
+		code, err = r.run(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(8 * time.Second):
+		t.Fatal("runner did not pick up the new version after the token expired")
+	}
+	if err != nil || code != 3 {
+		t.Fatalf("code = %d, err = %v; want the second child's 3", code, err)
+	}
+	if b, _ := os.ReadFile(out); string(b) != "v1\nv2\n" {
+		t.Errorf("child runs = %q", b)
+	}
+	if refreshes.Load() == 0 {
+		t.Error("the token was never refreshed")
+	}
+}
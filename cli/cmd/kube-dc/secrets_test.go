@@ -3,15 +3,24 @@
 // exercised by the live stage smoke; this file pins the parsing
 // contract for --from-literal / --from-file because a regression
 // there is hard to spot (silent data corruption in the KV write).
+// The --merge / --cas write path runs against an httptest stand-in
+// for the backend, since a lost check-and-set must never write.
 
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/shalb/kube-dc/cli/internal/backend"
 )
 
 // jsonMarshal is a tiny adapter for tests so they don't need to
@@ -499,3 +508,150 @@ func TestParseEnvFile_HandlesCRLF(t *testing.T) {
 		t.Errorf("K2 = %q (CR should be stripped)", got["K2"])
 	}
 }
+
+// fakeSecretStore is a stand-in for the backend's /api/secrets value
+// surface: one secret with KV-v2-style versions and cas checks.
+type fakeSecretStore struct {
+	version int
+	deleted bool // the current version is soft-deleted
+	data    map[string]string
+	puts    []map[string]any // decoded PUT bodies
+}
+
+func (f *fakeSecretStore) serve(t *testing.T) *backend.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/secrets/acme-web/app-config":
+			s := backend.SecretSummary{Name: "app-config", OpenBao: &backend.SecretOpenBao{CurrentVersion: f.version}}
+			if r.URL.Query().Get("includeValue") == "true" {
+				if f.version == 0 || f.deleted {
+					s.ValueMissing = true
+				} else {
+					s.Value = &backend.SecretValue{Data: f.data, Metadata: backend.SecretValueMeta{Version: f.version}}
+				}
+			}
+			_ = json.NewEncoder(w).Encode(s)
+		case "PUT /api/secrets/acme-web/app-config/values":
+			var body struct {
+				Data    map[string]string `json:"data"`
+				Options *struct {
+					Cas int `json:"cas"`
+				} `json:"options"`
+			}
+			raw, _ := io.ReadAll(r.Body)
+			_ = json.Unmarshal(raw, &body)
+			var decoded map[string]any
+			_ = json.Unmarshal(raw, &decoded)
+			f.puts = append(f.puts, decoded)
+			if body.Options != nil && body.Options.Cas != f.version {
+				// OpenBao's own reply: a 400 that does not name the version.
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":"check-and-set parameter did not match the current version"}`))
+				return
+			}
+			f.version++
+			f.data, f.deleted = body.Data, false
+			_ = json.NewEncoder(w).Encode(backend.PutSecretValuesResult{Name: "app-config", Version: f.version})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	c, _ := backend.New("kube-dc.cloud", "tok", "", false)
+	c.BaseURL = srv.URL
+	return c
+}
+
+func TestWriteSecretValues_MergeKeepsOtherKeys(t *testing.T) {
+	f := &fakeSecretStore{version: 5, data: map[string]string{"A": "1", "B": "2"}}
+	cli := f.serve(t)
+	res, total, err := writeSecretValues(context.Background(), cli, "acme-web", "app-config", map[string]string{"B": "two", "C": "3"}, -1, true)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if res.Version != 6 || total != 3 {
+		t.Errorf("result = v%d, %d keys; want v6, 3 keys", res.Version, total)
+	}
+	if f.data["A"] != "1" || f.data["B"] != "two" || f.data["C"] != "3" {
+		t.Errorf("stored = %v", f.data)
+	}
+	if cas := f.puts[0]["options"].(map[string]any)["cas"]; cas != 5.0 {
+		t.Errorf("merge write cas = %v; want the version read (5)", cas)
+	}
+}
+
+func TestWriteSecretValues_MergeIntoEmptySecret(t *testing.T) {
+	f := &fakeSecretStore{}
+	cli := f.serve(t)
+	if _, _, err := writeSecretValues(context.Background(), cli, "acme-web", "app-config", map[string]string{"A": "1"}, -1, true); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if cas := f.puts[0]["options"].(map[string]any)["cas"]; cas != 0.0 {
+		t.Errorf("first merge write cas = %v; want 0", cas)
+	}
+}
+
+// A soft-deleted current version has no value to merge, but the write
+// still has to name it to pass check-and-set.
+func TestWriteSecretValues_MergeOverDeletedVersion(t *testing.T) {
+	f := &fakeSecretStore{version: 4, deleted: true, data: map[string]string{"OLD": "x"}}
+	cli := f.serve(t)
+	res, total, err := writeSecretValues(context.Background(), cli, "acme-web", "app-config", map[string]string{"A": "1"}, -1, true)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if res.Version != 5 || total != 1 || len(f.data) != 1 || f.data["A"] != "1" {
+		t.Errorf("result = v%d, %d keys, stored %v; want v5 with only A", res.Version, total, f.data)
+	}
+	if cas := f.puts[0]["options"].(map[string]any)["cas"]; cas != 4.0 {
+		t.Errorf("merge write cas = %v; want the deleted version (4)", cas)
+	}
+}
+
+func TestWriteSecretValues_CASConflictReportsBothVersions(t *testing.T) {
+	f := &fakeSecretStore{version: 7, data: map[string]string{"A": "1"}}
+	cli := f.serve(t)
+	_, _, err := writeSecretValues(context.Background(), cli, "acme-web", "app-config", map[string]string{"A": "2"}, 5, false)
+	var conflict *backend.CASConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("err = %v; want *backend.CASConflictError", err)
+	}
+	if conflict.Expected != 5 || conflict.Current != 7 {
+		t.Errorf("conflict = expected v%d, current v%d; want v5, v7", conflict.Expected, conflict.Current)
+	}
+	if !strings.Contains(err.Error(), "v7") || !strings.Contains(err.Error(), "v5") {
+		t.Errorf("message %q does not name both versions", err)
+	}
+	if f.version != 7 || f.data["A"] != "1" {
+		t.Errorf("a lost cas wrote: v%d %v", f.version, f.data)
+	}
+}
+
+// --merge with a stale --cas fails before writing anything.
+func TestWriteSecretValues_MergeWithStaleCAS(t *testing.T) {
+	f := &fakeSecretStore{version: 7, data: map[string]string{"A": "1"}}
+	cli := f.serve(t)
+	_, _, err := writeSecretValues(context.Background(), cli, "acme-web", "app-config", map[string]string{"B": "2"}, 6, true)
+	var conflict *backend.CASConflictError
+	if !errors.As(err, &conflict) || conflict.Expected != 6 || conflict.Current != 7 {
+		t.Fatalf("err = %v; want a conflict expecting v6 at v7", err)
+	}
+	if len(f.puts) != 0 {
+		t.Errorf("stale --cas still sent %d writes", len(f.puts))
+	}
+}
+
+func TestWriteSecretValues_PlainPutHasNoPrecondition(t *testing.T) {
+	f := &fakeSecretStore{version: 3, data: map[string]string{"A": "1"}}
+	cli := f.serve(t)
+	if _, _, err := writeSecretValues(context.Background(), cli, "acme-web", "app-config", map[string]string{"B": "2"}, -1, false); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, ok := f.puts[0]["options"]; ok {
+		t.Errorf("plain put sent options: %v", f.puts[0])
+	}
+	if len(f.data) != 1 || f.data["B"] != "2" {
+		t.Errorf("plain put should replace the data; stored = %v", f.data)
+	}
+}
@@ -0,0 +1,325 @@
+// `kube-dc secrets versions | diff | rollback` — the KV version
+// history of a secret. Everything goes through the backend: each
+// version read is a value read and is audited as one. Rollback never
+// rewrites history — it writes the old data as a new version, with
+// check-and-set against the version it diffed, so a concurrent `put`
+// fails the rollback instead of being overwritten.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/shalb/kube-dc/cli/internal/backend"
+	"github.com/spf13/cobra"
+)
+
+// parseSecretVersion accepts "v3" or "3".
+func parseSecretVersion(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(s), "v"))
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid version %q: use v<N> or <N>, N > 0", s)
+	}
+	return n, nil
+}
+
+// secretKeyChange is one key-level difference between two versions.
+// From/To hold values only when the caller reveals them.
+type secretKeyChange struct {
+	Key    string `json:"key"`
+	Change string `json:"change"` // added | removed | changed
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+}
+
+// diffSecretData lists the keys added, removed or changed going from
+// one version's data to another's, sorted by key. Unchanged keys are
+// left out. Pure — no I/O.
+func diffSecretData(from, to map[string]string, reveal bool) []secretKeyChange {
+	keys := map[string]bool{}
+	for k := range from {
+		keys[k] = true
+	}
+	for k := range to {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	var out []secretKeyChange
+	for _, k := range sorted {
+		a, inFrom := from[k]
+		b, inTo := to[k]
+		var c secretKeyChange
+		switch {
+		case !inFrom:
+			c = secretKeyChange{Key: k, Change: "added", To: b}
+		case !inTo:
+			c = secretKeyChange{Key: k, Change: "removed", From: a}
+		case a != b:
+			c = secretKeyChange{Key: k, Change: "changed", From: a, To: b}
+		default:
+			continue
+		}
+		if !reveal {
+			c.From, c.To = "", ""
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func printKeyChanges(changes []secretKeyChange, reveal bool) {
+	for _, c := range changes {
+		switch {
+		case !reveal:
+			fmt.Printf("  %s %s\n", changeMark(c.Change), c.Key)
+		case c.Change == "added":
+			fmt.Printf("  + %s = %q\n", c.Key, c.To)
+		case c.Change == "removed":
+			fmt.Printf("  - %s = %q\n", c.Key, c.From)
+		default:
+			fmt.Printf("  ~ %s: %q → %q\n", c.Key, c.From, c.To)
+		}
+	}
+}
+
+func changeMark(change string) string {
+	switch change {
+	case "added":
+		return "+"
+	case "removed":
+		return "-"
+	}
+	return "~"
+}
+
+// versionState is the STATE column of `secrets versions`.
+func versionState(v backend.SecretVersion, current int) string {
+	switch {
+	case v.Destroyed:
+		return "destroyed"
+	case v.DeletionTime != "":
+		return "deleted"
+	case v.Version == current:
+		return "current"
+	}
+	return ""
+}
+
+func secretsVersionsCmd() *cobra.Command {
+	var namespace, output string
+	cmd := &cobra.Command{
+		Use:   "versions <name>",
+		Short: "List the stored versions of a secret.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(output)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.backend()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			list, err := cli.ListSecretVersions(ctx, scope.Namespace, args[0])
+			if err != nil {
+				return err
+			}
+			if out != outTable {
+				return printSerialized(out, list)
+			}
+			if len(list.Items) == 0 {
+				fmt.Printf("%s has no stored versions.\n", args[0])
+				return nil
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "VERSION\tCREATED\tSTATE")
+			for _, v := range list.Items {
+				fmt.Fprintf(tw, "v%d\t%s\t%s\n", v.Version, fmtCoalesce(formatAge(v.CreatedTime), "-"), fmtCoalesce(versionState(v, list.CurrentVersion), "-"))
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+func secretsDiffCmd() *cobra.Command {
+	var namespace, output, fromFlag, toFlag string
+	var reveal bool
+	cmd := &cobra.Command{
+		Use:   "diff <name> --from v<N> [--to v<M>]",
+		Short: "Show which keys changed between two versions (requires developer, project-manager, or admin).",
+		Long: `Show the keys added, removed or changed between two versions of a secret.
+--to defaults to the current version. Values are masked unless --reveal is set.`,
+		Example: `  kube-dc secrets diff app-config --from v3 --to v5
+  kube-dc secrets diff app-config --from v3 --reveal`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			out, err := parseOutput(output)
+			if err != nil {
+				return err
+			}
+			from, err := parseSecretVersion(fromFlag)
+			if err != nil {
+				return fmt.Errorf("--from: %w", err)
+			}
+			to := 0
+			if toFlag != "" {
+				if to, err = parseSecretVersion(toFlag); err != nil {
+					return fmt.Errorf("--to: %w", err)
+				}
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.backend()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			if to == 0 {
+				s, err := cli.GetSecret(ctx, scope.Namespace, name, false)
+				if err != nil {
+					return err
+				}
+				if s.OpenBao == nil || s.OpenBao.CurrentVersion == 0 {
+					return fmt.Errorf("secret %s has no stored value yet", name)
+				}
+				to = s.OpenBao.CurrentVersion
+			}
+			a, err := cli.GetSecretVersion(ctx, scope.Namespace, name, from)
+			if err != nil {
+				return fmt.Errorf("read v%d: %w", from, err)
+			}
+			b, err := cli.GetSecretVersion(ctx, scope.Namespace, name, to)
+			if err != nil {
+				return fmt.Errorf("read v%d: %w", to, err)
+			}
+			changes := diffSecretData(a.Data, b.Data, reveal)
+			if out != outTable {
+				return printSerialized(out, map[string]any{"from": from, "to": to, "changes": changes})
+			}
+			if len(changes) == 0 {
+				fmt.Printf("No key changes between v%d and v%d.\n", from, to)
+				return nil
+			}
+			fmt.Printf("%s v%d → v%d:\n", name, from, to)
+			printKeyChanges(changes, reveal)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format: table|json|yaml")
+	cmd.Flags().StringVar(&fromFlag, "from", "", "Version to compare from, e.g. v3 (required)")
+	cmd.Flags().StringVar(&toFlag, "to", "", "Version to compare to (default: current)")
+	cmd.Flags().BoolVar(&reveal, "reveal", false, "Show the values of changed keys")
+	_ = cmd.MarkFlagRequired("from")
+	return cmd
+}
+
+func secretsRollbackCmd() *cobra.Command {
+	var namespace, toFlag string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "rollback <name> --to v<N>",
+		Short: "Write an earlier version's data as a new version (requires developer, project-manager, or admin).",
+		Long: `Restore the data of an earlier version by writing it as a new version. The
+versions in between are kept. The write is check-and-set against the current
+version, so it fails rather than overwrite a concurrent put; re-run it to roll
+back from the newer version.`,
+		Example: `  kube-dc secrets rollback app-config --to v3 --dry-run
+  kube-dc secrets rollback app-config --to v3`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			to, err := parseSecretVersion(toFlag)
+			if err != nil {
+				return fmt.Errorf("--to: %w", err)
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.backend()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			s, err := cli.GetSecret(ctx, scope.Namespace, name, false)
+			if err != nil {
+				return err
+			}
+			if s.OpenBao == nil || s.OpenBao.CurrentVersion == 0 {
+				return fmt.Errorf("secret %s has no stored value yet", name)
+			}
+			current := s.OpenBao.CurrentVersion
+			switch {
+			case to == current:
+				return fmt.Errorf("v%d is already the current version of %s", to, name)
+			case to > current:
+				return fmt.Errorf("v%d is newer than the current version v%d of %s", to, current, name)
+			}
+			old, err := cli.GetSecretVersion(ctx, scope.Namespace, name, to)
+			if err != nil {
+				return fmt.Errorf("read v%d: %w", to, err)
+			}
+			if len(old.Data) == 0 {
+				return fmt.Errorf("v%d of %s has no data (deleted or destroyed); pick another version", to, name)
+			}
+			cur, err := cli.GetSecretVersion(ctx, scope.Namespace, name, current)
+			if err != nil {
+				return fmt.Errorf("read v%d: %w", current, err)
+			}
+			changes := diffSecretData(cur.Data, old.Data, false)
+			if len(changes) == 0 {
+				fmt.Printf("v%d and the current v%d of %s hold the same data; nothing to do.\n", to, current, name)
+				return nil
+			}
+			fmt.Printf("Rolling back %s/%s from v%d to the data of v%d:\n", scope.Namespace, name, current, to)
+			printKeyChanges(changes, false)
+			if dryRun {
+				fmt.Println("Dry run: nothing written.")
+				return nil
+			}
+			res, _, err := writeSecretValues(ctx, cli, scope.Namespace, name, old.Data, current, false)
+			if err != nil {
+				var conflict *backend.CASConflictError
+				if errors.As(err, &conflict) {
+					return fmt.Errorf("%w. Re-run to roll back from the new version", err)
+				}
+				return err
+			}
+			fmt.Printf("Wrote %s/%s v%d with the data of v%d (%d keys)\n", scope.Namespace, name, res.Version, to, len(old.Data))
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVar(&toFlag, "to", "", "Version whose data to restore, e.g. v3 (required)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the key changes without writing")
+	_ = cmd.MarkFlagRequired("to")
+	return cmd
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/shalb/kube-dc/cli/internal/backend"
+)
+
+func TestParseSecretVersion(t *testing.T) {
+	for in, want := range map[string]int{"v3": 3, "3": 3, "V12": 12} {
+		if got, err := parseSecretVersion(in); err != nil || got != want {
+			t.Errorf("parseSecretVersion(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "v", "v0", "-1", "latest", "v1.2"} {
+		if _, err := parseSecretVersion(bad); err == nil {
+			t.Errorf("parseSecretVersion(%q) = nil error; want rejection", bad)
+		}
+	}
+}
+
+func TestDiffSecretData(t *testing.T) {
+	from := map[string]string{"A": "1", "B": "2", "C": "3"}
+	to := map[string]string{"A": "1", "B": "two", "D": "4"}
+
+	got := diffSecretData(from, to, true)
+	want := []secretKeyChange{
+		{Key: "B", Change: "changed", From: "2", To: "two"},
+		{Key: "C", Change: "removed", From: "3"},
+		{Key: "D", Change: "added", To: "4"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("changes = %+v; want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("change %d = %+v; want %+v", i, got[i], want[i])
+		}
+	}
+
+	for _, c := range diffSecretData(from, to, false) {
+		if c.From != "" || c.To != "" {
+			t.Errorf("masked change %+v carries a value", c)
+		}
+	}
+	if c := diffSecretData(from, from, true); len(c) != 0 {
+		t.Errorf("identical data diff = %+v; want none", c)
+	}
+}
+
+func TestVersionState(t *testing.T) {
+	cases := []struct {
+		v    backend.SecretVersion
+		want string
+	}{
+		{backend.SecretVersion{Version: 5}, "current"},
+		{backend.SecretVersion{Version: 4}, ""},
+		{backend.SecretVersion{Version: 3, DeletionTime: "2026-10-01T00:00:00Z"}, "deleted"},
+		{backend.SecretVersion{Version: 2, Destroyed: true, DeletionTime: "2026-10-01T00:00:00Z"}, "destroyed"},
+	}
+	for _, c := range cases {
+		if got := versionState(c.v, 5); got != c.want {
+			t.Errorf("versionState(v%d) = %q; want %q", c.v.Version, got, c.want)
+		}
+	}
+}
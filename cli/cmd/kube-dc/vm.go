@@ -0,0 +1,858 @@
+// `kube-dc vm` — KubeVirt virtual machines in the current Project.
+// `create` reads the live OS catalog and the VM StorageClasses from
+// the backend (the same endpoints the console's VM wizard uses), then
+// creates the DataVolume + VirtualMachine pair on the kube-apiserver
+// directly, as skills/create-vm/vm-template.yaml describes. Every other
+// verb is a plain kube-apiserver call with the user's JWT.
+//
+// Verbs:
+//   list      — VMs with status and address                         (k8s)
+//   create    — DataVolume + VirtualMachine from the OS catalog     (backend + k8s)
+//   describe  — one VM: sizing, disks, instance, conditions         (k8s)
+//   start     — virtualmachines/start subresource                   (k8s)
+//   stop      — virtualmachines/stop subresource                    (k8s)
+//   restart   — virtualmachines/restart subresource                 (k8s)
+//   delete    — VM and the DataVolumes it boots from                (k8s)
+//   ssh       — ssh with the Project keypair (vm_access.go)         (k8s)
+//   console   — serial console websocket (vm_access.go)             (k8s)
+//   snapshot  — snapshots, restore, retention (vm_snapshot.go)      (backend + k8s)
+//   import    — local qcow2/VMDK/raw/OVA via CDI upload (vm_import.go) (backend + k8s)
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/backend"
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// vmPollInterval is how often the wait loops re-read the VM. A var so
+// tests can shorten it.
+var vmPollInterval = 3 * time.Second
+
+// defaultCloudConfig installs the guest agent, which injects the
+// Project SSH key and reports addresses and readiness. Used when the
+// catalog entry brings no cloud-init of its own.
+const defaultCloudConfig = `#cloud-config
+package_update: true
+packages:
+  - qemu-guest-agent
+runcmd:
+  - systemctl enable --now qemu-guest-agent
+`
+
+func vmCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "vm",
+		Aliases: []string{"vms"},
+		Short:   "Manage virtual machines in the current Project",
+		Long: `Create, list, inspect, start, stop and delete KubeVirt virtual machines in
+the current Project, and log into them over SSH or the serial console.
+
+Images come from the live OS catalog of the installation; create validates
+the requested size against the image's minimum and the disk's access and
+volume mode against what the StorageClass supports. import brings an existing
+machine in instead, from a local qcow2, VMDK, raw or OVA file.`,
+	}
+	cmd.AddCommand(vmListCmd())
+	cmd.AddCommand(vmCreateCmd())
+	cmd.AddCommand(vmDescribeCmd())
+	cmd.AddCommand(vmActionCmd("start", "Start a stopped VM"))
+	cmd.AddCommand(vmActionCmd("stop", "Stop a running VM"))
+	cmd.AddCommand(vmActionCmd("restart", "Restart a running VM"))
+	cmd.AddCommand(vmDeleteCmd())
+	cmd.AddCommand(vmSSHCmd())
+	cmd.AddCommand(vmTunnelCmd())
+	cmd.AddCommand(vmConsoleCmd())
+	cmd.AddCommand(vmSnapshotCmd())
+	cmd.AddCommand(vmImportCmd())
+	return cmd
+}
+
+// -------- list -----------------------------------------------------
+
+func vmListCmd() *cobra.Command {
+	var namespace, outFlag string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List VMs in the current Project",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			list, err := cli.ListVirtualMachines(ctx, scope.Namespace)
+			if err != nil {
+				return err
+			}
+			if out != outTable {
+				return printSerialized(out, list)
+			}
+			// Addresses come from the running instances; a failure here
+			// only costs the IP column.
+			addrs := map[string]string{}
+			if vmis, err := cli.ListVirtualMachineInstances(ctx, scope.Namespace); err == nil {
+				for i := range vmis.Items {
+					addrs[vmis.Items[i].Metadata.Name] = vmiAddress(&vmis.Items[i])
+				}
+			}
+			return printVMTable(scope.Namespace, list.Items, addrs)
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+// -------- create ---------------------------------------------------
+
+// vmOpts is the parsed flag set for `vm create`. Zero CPU / empty
+// Memory / empty Disk mean "the catalog minimum".
+type vmOpts struct {
+	Name, Namespace              string
+	OS, OSVersion                string
+	CPU                          int
+	Memory, Disk                 string
+	StorageClass                 string
+	AccessMode, VolumeMode       string
+	CloudInitFile, CloudInitData string
+}
+
+var (
+	validAccessModes = []string{"ReadWriteOnce", "ReadWriteMany"}
+	validVolumeModes = []string{"Filesystem", "Block"}
+)
+
+// selectOSImage finds the catalog entry ref names, by family id or
+// display name.
+func selectOSImage(images []backend.OSImage, ref string) (*backend.OSImage, error) {
+	var ids []string
+	for i := range images {
+		if images[i].Matches(ref) {
+			return &images[i], nil
+		}
+		ids = append(ids, images[i].ID())
+	}
+	sort.Strings(ids)
+	if ref == "" {
+		return nil, fmt.Errorf("--os is required; available: %s", strings.Join(ids, ", "))
+	}
+	return nil, fmt.Errorf("no OS image %q in the catalog; available: %s", ref, strings.Join(ids, ", "))
+}
+
+// imageSource picks the DataVolume source for an entry. The
+// digest-pinned registry reference, pulled by the node, is preferred;
+// the HTTP image is used when the family has none or when a specific
+// catalog version was asked for.
+func imageSource(img *backend.OSImage, version string) (k8sapi.DataVolumeSource, error) {
+	if version != "" {
+		var tags []string
+		for _, v := range img.Versions {
+			if v.Tag == version {
+				return k8sapi.DataVolumeSource{HTTP: &k8sapi.DataVolumeHTTPSource{URL: v.ImageURL}}, nil
+			}
+			tags = append(tags, v.Tag)
+		}
+		if len(tags) == 0 {
+			return k8sapi.DataVolumeSource{}, fmt.Errorf("%s has no selectable versions in this catalog", img.ID())
+		}
+		return k8sapi.DataVolumeSource{}, fmt.Errorf("%s has no version %q; available: %s", img.ID(), version, strings.Join(tags, ", "))
+	}
+	if img.RegistryURL != "" {
+		if !strings.Contains(img.RegistryURL, "@sha256:") {
+			return k8sapi.DataVolumeSource{}, fmt.Errorf("catalog registry reference %q for %s is not digest-pinned", img.RegistryURL, img.ID())
+		}
+		return k8sapi.DataVolumeSource{Registry: &k8sapi.DataVolumeRegistrySource{URL: img.RegistryURL, PullMethod: "node"}}, nil
+	}
+	u := fmtCoalesce(img.LatestURL, img.ImageURL)
+	if u == "" {
+		return k8sapi.DataVolumeSource{}, fmt.Errorf("catalog entry %s has no image source", img.ID())
+	}
+	return k8sapi.DataVolumeSource{HTTP: &k8sapi.DataVolumeHTTPSource{URL: u}}, nil
+}
+
+// selectStorage resolves the StorageClass and the access/volume mode
+// pair. The class defaults to the one marked default; the pair to the
+// first one the class lists. A class that lists no pairs is assumed to
+// support ReadWriteOnce + Filesystem only.
+func selectStorage(classes []backend.VMStorageClass, class, access, volume string) (string, string, string, error) {
+	if access != "" && !containsString(validAccessModes, access) {
+		return "", "", "", fmt.Errorf("invalid --access-mode %q (want %s)", access, strings.Join(validAccessModes, " or "))
+	}
+	if volume != "" && !containsString(validVolumeModes, volume) {
+		return "", "", "", fmt.Errorf("invalid --volume-mode %q (want %s)", volume, strings.Join(validVolumeModes, " or "))
+	}
+	var sc *backend.VMStorageClass
+	var names []string
+	for i := range classes {
+		names = append(names, classes[i].Name)
+		if (class != "" && classes[i].Name == class) || (class == "" && classes[i].IsDefault) {
+			sc = &classes[i]
+			break
+		}
+	}
+	if sc == nil {
+		if class == "" {
+			return "", "", "", fmt.Errorf("no default StorageClass for VM disks; pass --storage-class (available: %s)", strings.Join(names, ", "))
+		}
+		return "", "", "", fmt.Errorf("StorageClass %q is not offered for VM disks (available: %s)", class, strings.Join(names, ", "))
+	}
+	modes := sc.Modes
+	if len(modes) == 0 {
+		modes = []backend.StorageModePair{{AccessMode: "ReadWriteOnce", VolumeMode: "Filesystem"}}
+	}
+	var supported []string
+	for _, m := range modes {
+		if (access == "" || m.AccessMode == access) && (volume == "" || m.VolumeMode == volume) {
+			return sc.Name, m.AccessMode, m.VolumeMode, nil
+		}
+		supported = append(supported, m.AccessMode+"/"+m.VolumeMode)
+	}
+	return "", "", "", fmt.Errorf("StorageClass %s does not support %s/%s for VM disks (supported: %s)",
+		sc.Name, fmtCoalesce(access, "*"), fmtCoalesce(volume, "*"), strings.Join(supported, ", "))
+}
+
+// vmSize is the resolved CPU / memory / disk of a new VM.
+type vmSize struct {
+	CPU          int
+	Memory, Disk string
+}
+
+// resolveVMSize fills unset sizes from the catalog minimum and rejects
+// sizes below it.
+func resolveVMSize(img *backend.OSImage, cpu int, memory, disk string) (vmSize, error) {
+	size := vmSize{CPU: cpu, Memory: memory, Disk: disk}
+	minCPU := 1
+	if img.MinVCPU != "" {
+		n, err := strconv.Atoi(img.MinVCPU)
+		if err != nil {
+			return size, fmt.Errorf("catalog MIN_VCPU %q for %s is not a number", img.MinVCPU, img.ID())
+		}
+		minCPU = n
+	}
+	if size.CPU == 0 {
+		size.CPU = minCPU
+	}
+	if size.CPU < minCPU {
+		return size, fmt.Errorf("--cpu %d is below the %s minimum of %d", size.CPU, img.ID(), minCPU)
+	}
+
+	var err error
+	if size.Memory, err = atLeast("--memory", memory, img.MinMemory, "1Gi", img.ID()); err != nil {
+		return size, err
+	}
+	if size.Disk, err = atLeast("--disk", disk, img.MinStorage, "10Gi", img.ID()); err != nil {
+		return size, err
+	}
+	return size, nil
+}
+
+// atLeast returns value (or the minimum, or fallback, when value is
+// empty) after checking it parses and is not below the minimum.
+func atLeast(flag, value, minimum, fallback, image string) (string, error) {
+	if value == "" {
+		value = fmtCoalesce(minimum, fallback)
+	}
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s %q: use a quantity such as 4Gi", flag, value)
+	}
+	if minimum != "" {
+		min, err := resource.ParseQuantity(minimum)
+		if err != nil {
+			return "", fmt.Errorf("catalog minimum %q for %s is not a quantity", minimum, image)
+		}
+		if q.Cmp(min) < 0 {
+			return "", fmt.Errorf("%s %s is below the %s minimum of %s", flag, value, image, minimum)
+		}
+	}
+	return value, nil
+}
+
+func vmDiskName(vm string) string { return vm + "-disk" }
+
+// buildVM assembles the DataVolume + VirtualMachine pair. Pure — no
+// I/O — so the manifest shape is table-testable.
+func buildVM(opts vmOpts, img *backend.OSImage, src k8sapi.DataVolumeSource, size vmSize, class, access, volume string) (*k8sapi.DataVolume, *k8sapi.VirtualMachine, error) {
+	if !projectNameRE.MatchString(opts.Name) || len(opts.Name) > 58 {
+		return nil, nil, fmt.Errorf("invalid VM name %q: use lowercase letters, digits and '-', at most 58 characters", opts.Name)
+	}
+	if img.AdditionalDisk != "" {
+		return nil, nil, fmt.Errorf("%s needs additional disks; create it with the console's VM wizard", img.ID())
+	}
+	if img.CloudUser == "" {
+		return nil, nil, fmt.Errorf("catalog entry %s names no default user", img.ID())
+	}
+	labels := map[string]string{"app.kubernetes.io/name": opts.Name}
+
+	dv := &k8sapi.DataVolume{
+		Metadata: k8sapi.ObjectMeta{Name: vmDiskName(opts.Name), Namespace: opts.Namespace, Labels: labels},
+		Spec:     k8sapi.DataVolumeSpec{Source: src},
+	}
+	dv.Spec.PVC.AccessModes = []string{access}
+	if volume != "Filesystem" {
+		dv.Spec.PVC.VolumeMode = volume
+	}
+	dv.Spec.PVC.StorageClassName = class
+	dv.Spec.PVC.Resources.Requests = map[string]string{"storage": size.Disk}
+
+	userData := fmtCoalesce(opts.CloudInitData, img.CloudInit, defaultCloudConfig)
+	domain := map[string]any{
+		"cpu":    map[string]any{"cores": size.CPU},
+		"memory": map[string]any{"guest": size.Memory},
+		"devices": map[string]any{
+			"disks": []any{
+				map[string]any{"name": "root", "disk": map[string]any{"bus": "virtio"}},
+				map[string]any{"name": "cloudinit", "disk": map[string]any{"bus": "virtio"}},
+			},
+			"interfaces": []any{map[string]any{"name": "default", "bridge": map[string]any{}}},
+		},
+		"resources": map[string]any{"requests": map[string]any{"memory": size.Memory}},
+	}
+	switch strings.ToLower(img.FirmwareType) {
+	case "efi", "uefi":
+		domain["firmware"] = map[string]any{"bootloader": map[string]any{"efi": map[string]any{"secureBoot": false}}}
+	}
+	if img.MachineType != "" {
+		domain["machine"] = map[string]any{"type": img.MachineType}
+	}
+
+	running := true
+	vm := &k8sapi.VirtualMachine{
+		Metadata: k8sapi.ObjectMeta{
+			Name: opts.Name, Namespace: opts.Namespace, Labels: labels,
+			Annotations: map[string]string{vmOSImageAnnotation: img.ID()},
+		},
+		Spec: k8sapi.VirtualMachineSpec{
+			Running: &running,
+			Template: k8sapi.VMTemplate{
+				Metadata: map[string]any{"labels": labels},
+				Spec: map[string]any{
+					"accessCredentials": []any{map[string]any{
+						"sshPublicKey": map[string]any{
+							"source": map[string]any{"secret": map[string]any{"secretName": "authorized-keys-default"}},
+							"propagationMethod": map[string]any{
+								"qemuGuestAgent": map[string]any{"users": []any{img.CloudUser}},
+							},
+						},
+					}},
+					"domain": domain,
+					"networks": []any{map[string]any{
+						"name":   "default",
+						"multus": map[string]any{"default": true, "networkName": opts.Namespace + "/default"},
+					}},
+					"readinessProbe": map[string]any{
+						"guestAgentPing":      map[string]any{},
+						"initialDelaySeconds": 30,
+						"periodSeconds":       10,
+						"timeoutSeconds":      5,
+						"failureThreshold":    10,
+					},
+					"volumes": []any{
+						map[string]any{"name": "root", "dataVolume": map[string]any{"name": dv.Metadata.Name}},
+						map[string]any{"name": "cloudinit", "cloudInitNoCloud": map[string]any{"userData": userData}},
+					},
+				},
+			},
+		},
+	}
+	return dv, vm, nil
+}
+
+func vmCreateCmd() *cobra.Command {
+	var opts vmOpts
+	var outFlag string
+	var noWait bool
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create a VM from the OS catalog and wait for it to run",
+		Long: `Create a VM in the current Project from an image of the live OS catalog.
+
+--os takes the catalog family id (e.g. ubuntu-24.04) or its display name.
+CPU, memory and disk default to the image's minimum and cannot go below it.
+The root disk uses the default VM StorageClass unless --storage-class is
+given; --access-mode and --volume-mode must be a pair that class supports
+(ReadWriteMany + Block on a shared class makes the VM live-migratable).
+
+The Project's generated SSH key (authorized-keys-default) is injected for the
+image's default user by the guest agent; log in with
+kube-dc vm ssh <name>.`,
+		Example: `  kube-dc vm create web-1 --os ubuntu-24.04
+  kube-dc vm create db-1 --os debian-12 --cpu 4 --memory 8Gi --disk 100Gi
+  kube-dc vm create ha-1 --os ubuntu-24.04 --storage-class rbd-vm --access-mode ReadWriteMany --volume-mode Block`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			opts.Name = args[0]
+			if opts.CloudInitFile != "" {
+				b, err := os.ReadFile(opts.CloudInitFile)
+				if err != nil {
+					return fmt.Errorf("read --cloud-init: %w", err)
+				}
+				opts.CloudInitData = string(b)
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(opts.Namespace)
+			if err != nil {
+				return err
+			}
+			opts.Namespace = scope.Namespace
+			be, err := scope.backend()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			images, err := be.ListOSImages(ctx, scope.Namespace)
+			if err != nil {
+				return fmt.Errorf("read OS catalog: %w", err)
+			}
+			img, err := selectOSImage(images, opts.OS)
+			if err != nil {
+				return err
+			}
+			src, err := imageSource(img, opts.OSVersion)
+			if err != nil {
+				return err
+			}
+			size, err := resolveVMSize(img, opts.CPU, opts.Memory, opts.Disk)
+			if err != nil {
+				return err
+			}
+			classes, err := be.ListVMStorageClasses(ctx, scope.Namespace)
+			if err != nil {
+				return fmt.Errorf("read VM StorageClasses: %w", err)
+			}
+			class, access, volume, err := selectStorage(classes, opts.StorageClass, opts.AccessMode, opts.VolumeMode)
+			if err != nil {
+				return err
+			}
+			dv, vm, err := buildVM(opts, img, src, size, class, access, volume)
+			if err != nil {
+				return err
+			}
+
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			if _, err := cli.CreateDataVolume(ctx, dv); err != nil {
+				return fmt.Errorf("create DataVolume %s: %w", dv.Metadata.Name, err)
+			}
+			created, err := cli.CreateVirtualMachine(ctx, vm)
+			if err != nil {
+				// Don't leave an orphaned import behind.
+				if derr := cli.DeleteDataVolume(ctx, scope.Namespace, dv.Metadata.Name); derr != nil {
+					fmt.Fprintf(os.Stderr, "Warning: DataVolume %s was left behind: %v\n", dv.Metadata.Name, derr)
+				}
+				return fmt.Errorf("create VirtualMachine %s: %w", opts.Name, err)
+			}
+			if noWait {
+				if out != outTable {
+					return printSerialized(out, created)
+				}
+				fmt.Printf("Created VM %s (%s, %d vCPU, %s memory, %s %s disk)\n", opts.Name, img.Name, size.CPU, size.Memory, size.Disk, class)
+				fmt.Printf("Not waiting; follow with `kube-dc vm describe %s`\n", opts.Name)
+				return nil
+			}
+			if out == outTable {
+				fmt.Printf("Created VM %s (%s, %d vCPU, %s memory, %s %s disk); waiting for it to run...\n", opts.Name, img.Name, size.CPU, size.Memory, size.Disk, class)
+			}
+			running, err := waitForVM(context.Background(), cli, scope.Namespace, opts.Name, vmWantRunning, timeout)
+			if err != nil {
+				return err
+			}
+			if out != outTable {
+				return printSerialized(out, running)
+			}
+			fmt.Printf("VM %s is Running; log in as %s with `kube-dc vm ssh %s`\n", opts.Name, img.CloudUser, opts.Name)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVar(&opts.OS, "os", "", "OS catalog entry: family id or display name (required)")
+	cmd.Flags().StringVar(&opts.OSVersion, "os-version", "", "Catalog version tag (default: latest)")
+	cmd.Flags().IntVar(&opts.CPU, "cpu", 0, "vCPU cores (default: image minimum)")
+	cmd.Flags().StringVar(&opts.Memory, "memory", "", "Memory, e.g. 4Gi (default: image minimum)")
+	cmd.Flags().StringVar(&opts.Disk, "disk", "", "Root disk size, e.g. 40Gi (default: image minimum)")
+	cmd.Flags().StringVar(&opts.StorageClass, "storage-class", "", "StorageClass for the root disk (default: the default VM class)")
+	cmd.Flags().StringVar(&opts.AccessMode, "access-mode", "", "Disk access mode: ReadWriteOnce|ReadWriteMany (default: the class's first supported pair)")
+	cmd.Flags().StringVar(&opts.VolumeMode, "volume-mode", "", "Disk volume mode: Filesystem|Block (default: the class's first supported pair)")
+	cmd.Flags().StringVar(&opts.CloudInitFile, "cloud-init", "", "cloud-config user-data file (default: the catalog's, installing qemu-guest-agent)")
+	cmd.Flags().BoolVar(&noWait, "no-wait", false, "Return once the VM is created instead of waiting for Running")
+	cmd.Flags().DurationVar(&timeout, "timeout", 15*time.Minute, "How long to wait for Running")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+// -------- describe -------------------------------------------------
+
+// vmDetail is what `vm describe -o json|yaml` prints.
+type vmDetail struct {
+	VirtualMachine *k8sapi.VirtualMachine         `json:"virtualMachine" yaml:"virtualMachine"`
+	Instance       *k8sapi.VirtualMachineInstance `json:"instance,omitempty" yaml:"instance,omitempty"`
+	DataVolumes    []k8sapi.DataVolume            `json:"dataVolumes,omitempty" yaml:"dataVolumes,omitempty"`
+}
+
+func vmDescribeCmd() *cobra.Command {
+	var namespace, outFlag string
+	cmd := &cobra.Command{
+		Use:     "describe <name>",
+		Aliases: []string{"get", "show"},
+		Short:   "Show a VM's sizing, disks, instance and conditions",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			vm, err := cli.GetVirtualMachine(ctx, scope.Namespace, args[0])
+			if err != nil {
+				return err
+			}
+			d := vmDetail{VirtualMachine: vm}
+			if vmi, err := cli.GetVirtualMachineInstance(ctx, scope.Namespace, vm.Metadata.Name); err == nil {
+				d.Instance = vmi
+			} else if !k8sapi.IsNotFound(err) {
+				return err
+			}
+			for _, name := range vmDataVolumes(vm) {
+				dv, err := cli.GetDataVolume(ctx, scope.Namespace, name)
+				if err != nil {
+					if k8sapi.IsNotFound(err) {
+						continue
+					}
+					return err
+				}
+				d.DataVolumes = append(d.DataVolumes, *dv)
+			}
+			if out != outTable {
+				return printSerialized(out, d)
+			}
+			printVMDetail(&d)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+// -------- start / stop / restart -----------------------------------
+
+func vmActionCmd(action, short string) *cobra.Command {
+	var namespace string
+	var wait bool
+	var timeout time.Duration
+	want := vmWantRunning
+	if action == "stop" {
+		want = vmWantStopped
+	}
+	cmd := &cobra.Command{
+		Use:   action + " <name>",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			err = cli.VirtualMachineAction(ctx, scope.Namespace, name, action)
+			cancel()
+			if err != nil {
+				return err
+			}
+			if !wait {
+				fmt.Printf("Requested %s of VM %s\n", action, name)
+				return nil
+			}
+			if action == "restart" {
+				// The old instance is still Running for a moment; give
+				// KubeVirt one poll to start replacing it.
+				time.Sleep(vmPollInterval)
+			}
+			if _, err := waitForVM(context.Background(), cli, scope.Namespace, name, want, timeout); err != nil {
+				return err
+			}
+			fmt.Printf("VM %s is %s\n", name, want)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().BoolVar(&wait, "wait", false, fmt.Sprintf("Wait until the VM is %s", want))
+	cmd.Flags().DurationVar(&timeout, "timeout", 10*time.Minute, "How long to wait with --wait")
+	return cmd
+}
+
+// -------- delete ---------------------------------------------------
+
+func vmDeleteCmd() *cobra.Command {
+	var namespace string
+	var yes, keepDisks bool
+	cmd := &cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a VM and its disks",
+		Long: `Delete a VM together with the DataVolumes it boots from. Pass --keep-disks
+to delete only the VM and keep its disks for a new one.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if !yes {
+				what := "and its disks"
+				if keepDisks {
+					what = "(keeping its disks)"
+				}
+				fmt.Fprintf(os.Stderr, "Delete VM %s %s? Re-run with --yes to confirm.\n", name, what)
+				return fmt.Errorf("not confirmed")
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			vm, err := cli.GetVirtualMachine(ctx, scope.Namespace, name)
+			if err != nil {
+				return err
+			}
+			if err := cli.DeleteVirtualMachine(ctx, scope.Namespace, name); err != nil {
+				return err
+			}
+			fmt.Printf("Deleted VM %s\n", name)
+			if keepDisks {
+				return nil
+			}
+			for _, dv := range vmDataVolumes(vm) {
+				if err := cli.DeleteDataVolume(ctx, scope.Namespace, dv); err != nil && !k8sapi.IsNotFound(err) {
+					return fmt.Errorf("delete DataVolume %s: %w", dv, err)
+				}
+				fmt.Printf("Deleted DataVolume %s\n", dv)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().BoolVar(&yes, "yes", false, "Confirm the deletion")
+	cmd.Flags().BoolVar(&keepDisks, "keep-disks", false, "Keep the VM's DataVolumes")
+	return cmd
+}
+
+// -------- wait -----------------------------------------------------
+
+// vmWant is the state a wait loop is after.
+type vmWant string
+
+const (
+	vmWantRunning vmWant = "Running"
+	vmWantStopped vmWant = "Stopped"
+)
+
+// waitForVM polls the VM until it reaches want: Running and ready
+// (guest agent answering), or Stopped. On timeout the error carries the
+// last printable status and the conditions that are not True.
+func waitForVM(ctx context.Context, cli *k8sapi.Client, ns, name string, want vmWant, timeout time.Duration) (*k8sapi.VirtualMachine, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var last *k8sapi.VirtualMachine
+	var lastErr error
+	for {
+		reqCtx, reqCancel := context.WithTimeout(ctx, 30*time.Second)
+		vm, err := cli.GetVirtualMachine(reqCtx, ns, name)
+		reqCancel()
+		switch {
+		case err == nil:
+			last, lastErr = vm, nil
+			status := vm.Status.PrintableStatus
+			if want == vmWantRunning && status == string(vmWantRunning) && vm.Status.Ready {
+				return vm, nil
+			}
+			if want == vmWantStopped && status == string(vmWantStopped) {
+				return vm, nil
+			}
+		case k8sapi.IsNotFound(err):
+			return nil, fmt.Errorf("VM %s not found", name)
+		default:
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			msg := fmt.Sprintf("timed out after %s waiting for VM %s to be %s", timeout, name, want)
+			if last != nil {
+				msg += fmt.Sprintf("; status %s", fmtCoalesce(last.Status.PrintableStatus, "unknown"))
+				if pending := notTrueConditions(last.Status.Conditions); pending != "" {
+					msg += "; pending: " + pending
+				}
+			}
+			if lastErr != nil {
+				msg += fmt.Sprintf(" (last error: %v)", lastErr)
+			}
+			return nil, fmt.Errorf("%s", msg)
+		case <-time.After(vmPollInterval):
+		}
+	}
+}
+
+// -------- rendering helpers ----------------------------------------
+
+// vmDataVolumes returns the DataVolumes the VM's volumes refer to.
+func vmDataVolumes(vm *k8sapi.VirtualMachine) []string {
+	var names []string
+	volumes, _ := vm.Spec.Template.Spec["volumes"].([]any)
+	for _, v := range volumes {
+		vol, _ := v.(map[string]any)
+		dv, _ := vol["dataVolume"].(map[string]any)
+		if name, _ := dv["name"].(string); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// vmShape reads cores and guest memory back out of the template.
+func vmShape(vm *k8sapi.VirtualMachine) (cpu, memory string) {
+	domain, _ := vm.Spec.Template.Spec["domain"].(map[string]any)
+	c, _ := domain["cpu"].(map[string]any)
+	if cores, ok := c["cores"].(float64); ok {
+		cpu = strconv.Itoa(int(cores))
+	}
+	m, _ := domain["memory"].(map[string]any)
+	memory, _ = m["guest"].(string)
+	return fmtCoalesce(cpu, "-"), fmtCoalesce(memory, "-")
+}
+
+// vmiAddress is the address of the Project network interface.
+func vmiAddress(vmi *k8sapi.VirtualMachineInstance) string {
+	for _, iface := range vmi.Status.Interfaces {
+		if iface.IPAddress != "" && (iface.Name == "default" || len(vmi.Status.Interfaces) == 1) {
+			return iface.IPAddress
+		}
+	}
+	for _, iface := range vmi.Status.Interfaces {
+		if iface.IPAddress != "" {
+			return iface.IPAddress
+		}
+	}
+	return ""
+}
+
+func printVMTable(ns string, items []k8sapi.VirtualMachine, addrs map[string]string) error {
+	if len(items) == 0 {
+		fmt.Println("No VMs in", ns)
+		return nil
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Metadata.Name < items[j].Metadata.Name })
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATUS\tREADY\tCPU\tMEMORY\tIP\tAGE")
+	for i := range items {
+		vm := &items[i]
+		cpu, memory := vmShape(vm)
+		fmt.Fprintf(w, "%s\t%s\t%v\t%s\t%s\t%s\t%s\n",
+			vm.Metadata.Name,
+			fmtCoalesce(vm.Status.PrintableStatus, "-"),
+			vm.Status.Ready,
+			cpu,
+			memory,
+			fmtCoalesce(addrs[vm.Metadata.Name], "-"),
+			formatAge(vm.Metadata.CreationTimestamp),
+		)
+	}
+	return w.Flush()
+}
+
+func printVMDetail(d *vmDetail) {
+	vm := d.VirtualMachine
+	cpu, memory := vmShape(vm)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", vm.Metadata.Name)
+	fmt.Fprintf(w, "Namespace:\t%s\n", vm.Metadata.Namespace)
+	fmt.Fprintf(w, "Status:\t%s\n", fmtCoalesce(vm.Status.PrintableStatus, "-"))
+	fmt.Fprintf(w, "Ready:\t%v\n", vm.Status.Ready)
+	fmt.Fprintf(w, "CPU:\t%s\n", cpu)
+	fmt.Fprintf(w, "Memory:\t%s\n", memory)
+	if vmi := d.Instance; vmi != nil {
+		fmt.Fprintf(w, "Node:\t%s\n", fmtCoalesce(vmi.Status.NodeName, "-"))
+		fmt.Fprintf(w, "IP:\t%s\n", fmtCoalesce(vmiAddress(vmi), "-"))
+		if guest := vmi.Status.GuestOSInfo.PrettyName; guest != "" {
+			fmt.Fprintf(w, "Guest OS:\t%s\n", guest)
+		}
+	}
+	fmt.Fprintf(w, "Created:\t%s\n", fmtCoalesce(vm.Metadata.CreationTimestamp, "-"))
+	_ = w.Flush()
+
+	if len(d.DataVolumes) > 0 {
+		fmt.Println("\nDisks:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  DATAVOLUME\tPHASE\tPROGRESS\tSIZE\tCLASS\tMODE")
+		for _, dv := range d.DataVolumes {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\n",
+				dv.Metadata.Name,
+				fmtCoalesce(dv.Status.Phase, "-"),
+				fmtCoalesce(dv.Status.Progress, "-"),
+				fmtCoalesce(dv.Spec.PVC.Resources.Requests["storage"], "-"),
+				fmtCoalesce(dv.Spec.PVC.StorageClassName, "-"),
+				strings.Join(dv.Spec.PVC.AccessModes, ",")+"/"+fmtCoalesce(dv.Spec.PVC.VolumeMode, "Filesystem"),
+			)
+		}
+		_ = w.Flush()
+	}
+
+	if len(vm.Status.Conditions) > 0 {
+		fmt.Println("\nConditions:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  TYPE\tSTATUS\tREASON\tMESSAGE")
+		for _, c := range vm.Status.Conditions {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", c.Type, c.Status, fmtCoalesce(c.Reason, "-"), truncCLI(c.Message, 80))
+		}
+		_ = w.Flush()
+	}
+}
@@ -0,0 +1,539 @@
+// `kube-dc vm ssh` and `kube-dc vm console` — getting into a VM.
+//
+// ssh follows skills/ssh-into-vm: it writes the Project's generated
+// private key (ssh-keypair-default) to a 0600 temp file, finds an
+// address the workstation can reach — a ready Floating IP targeting the
+// VM, else an EIP-bound LoadBalancer Service forwarding to its port 22
+// — logs in as the image's default user and removes the key when ssh
+// exits. Host-key checking is left to ssh and the user's known_hosts.
+//
+// Standard Project roles cannot port-forward into a VMI, so the tunnel
+// through the API server (a hidden `vm tunnel` used as ssh's
+// ProxyCommand) is only picked automatically when the caller's RBAC
+// allows it, and otherwise only on --via tunnel.
+//
+// console attaches to the VMI's serial console websocket with the
+// user's OIDC token; no key or address is involved.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+const (
+	// projectKeypairSecret holds the Project's generated RSA keypair;
+	// its public half is what authorized-keys-default injects.
+	projectKeypairSecret = "ssh-keypair-default"
+	// vmOSImageAnnotation records the catalog entry a VM was created
+	// from, so the default user can be looked up again later.
+	vmOSImageAnnotation = "kube-dc.com/os-image"
+	// vmNameLabel is set by KubeVirt on a VM's launcher pod; Services
+	// select a VM through it.
+	vmNameLabel = "vm.kubevirt.io/name"
+
+	// consoleEscape is Ctrl+], the telnet-style escape.
+	consoleEscape = 0x1d
+)
+
+var validSSHVia = []string{"auto", "fip", "lb", "private", "tunnel"}
+
+// sshEndpoint is where ssh connects. For the tunnel Host is only a
+// label; the ProxyCommand carries the bytes.
+type sshEndpoint struct {
+	Host string
+	Port int
+	Via  string
+}
+
+// -------- ssh ------------------------------------------------------
+
+func vmSSHCmd() *cobra.Command {
+	var namespace, user, via, identity string
+	cmd := &cobra.Command{
+		Use:   "ssh <name> [-- <remote command>]",
+		Short: "SSH into a VM with the Project's generated key",
+		Long: `Open an SSH session to a VM as the image's default user, authenticating with
+the Project's generated keypair (Secret ssh-keypair-default). The private key
+is written to a 0600 temp file for the session and removed afterwards.
+
+--via chooses how the VM is reached:
+  auto     a ready Floating IP targeting the VM, else an EIP-bound
+           LoadBalancer Service forwarding to its port 22, else the tunnel
+           when your role allows it (default)
+  fip      the Floating IP only
+  lb       the LoadBalancer Service only
+  private  the VM's Project-network address; needs a route to the Project
+           network (VPN, bastion)
+  tunnel   a port-forward through the API server; standard Project roles
+           are not allowed to use it
+
+ssh verifies the host key as usual; accept it only after checking it through a
+trusted channel.`,
+		Example: `  kube-dc vm ssh web-1
+  kube-dc vm ssh web-1 -- sudo systemctl status nginx
+  kube-dc vm ssh web-1 --user admin --identity ~/.ssh/id_ed25519`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, remote := args[0], args[1:]
+			if !containsString(validSSHVia, via) {
+				return fmt.Errorf("invalid --via %q (want %s)", via, strings.Join(validSSHVia, "|"))
+			}
+			cmd.SilenceUsage = true
+			sshBin, err := exec.LookPath("ssh")
+			if err != nil {
+				return fmt.Errorf("ssh not found in PATH: install an OpenSSH client")
+			}
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			vm, err := cli.GetVirtualMachine(ctx, scope.Namespace, name)
+			if err != nil {
+				return err
+			}
+			vmi, err := cli.GetVirtualMachineInstance(ctx, scope.Namespace, name)
+			if k8sapi.IsNotFound(err) {
+				return fmt.Errorf("VM %s is not running (status %s); start it with `kube-dc vm start %s`",
+					name, fmtCoalesce(vm.Status.PrintableStatus, "unknown"), name)
+			}
+			if err != nil {
+				return err
+			}
+
+			if user == "" {
+				if user, err = vmLoginUser(ctx, scope, vm); err != nil {
+					return err
+				}
+			}
+			ep, err := resolveSSHEndpoint(ctx, cli, scope.Namespace, vm.Metadata.Name, vmi, via)
+			if err != nil {
+				return err
+			}
+
+			keyFile := identity
+			if keyFile == "" {
+				secret, err := cli.GetSecret(ctx, scope.Namespace, projectKeypairSecret)
+				if err != nil {
+					return fmt.Errorf("read the Project SSH key: %w", err)
+				}
+				key, err := secret.Value("id_rsa")
+				if err != nil {
+					return err
+				}
+				path, cleanup, err := writeTempKey(key)
+				if err != nil {
+					return err
+				}
+				defer cleanup()
+				keyFile = path
+			}
+
+			var self string
+			if ep.Via == "tunnel" {
+				if self, err = os.Executable(); err != nil {
+					return fmt.Errorf("locate the kube-dc binary for the tunnel: %w", err)
+				}
+			}
+			sshArgs := buildSSHArgs(ep, user, keyFile, scope.Namespace, name, self, remote)
+			fmt.Fprintf(os.Stderr, "Connecting to %s@%s:%d (via %s)\n", user, ep.Host, ep.Port, ep.Via)
+
+			// ssh owns the terminal now: let it see Ctrl+C while this
+			// process stays alive to remove the key afterwards.
+			signal.Ignore(os.Interrupt)
+			defer signal.Reset(os.Interrupt)
+			c := exec.Command(sshBin, sshArgs...)
+			c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+			if err := c.Run(); err != nil {
+				var ee *exec.ExitError
+				if errors.As(err, &ee) {
+					// ssh has already said why; pass its status through.
+					return &exitCodeError{code: ee.ExitCode()}
+				}
+				return fmt.Errorf("run ssh: %w", err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVarP(&user, "user", "l", "", "Login user (default: the image's default user)")
+	cmd.Flags().StringVar(&via, "via", "auto", "How to reach the VM: auto|fip|lb|private|tunnel")
+	cmd.Flags().StringVarP(&identity, "identity", "i", "", "Private key file to use instead of the Project's generated key")
+	return cmd
+}
+
+// vmLoginUser is the user the Project key is injected for: the first
+// guest-agent user of the VM's access credentials, else the default
+// user of the catalog entry the VM was created from.
+func vmLoginUser(ctx context.Context, scope *secretsScope, vm *k8sapi.VirtualMachine) (string, error) {
+	if users := vmAccessUsers(vm); len(users) > 0 {
+		return users[0], nil
+	}
+	ref := vm.Metadata.Annotations[vmOSImageAnnotation]
+	if ref == "" {
+		return "", fmt.Errorf("cannot tell the login user of VM %s: it has no guest-agent access credentials and no %s annotation; pass --user", vm.Metadata.Name, vmOSImageAnnotation)
+	}
+	be, err := scope.backend()
+	if err != nil {
+		return "", err
+	}
+	images, err := be.ListOSImages(ctx, scope.Namespace)
+	if err != nil {
+		return "", fmt.Errorf("read OS catalog: %w", err)
+	}
+	img, err := selectOSImage(images, ref)
+	if err != nil {
+		return "", fmt.Errorf("VM %s: %w; pass --user", vm.Metadata.Name, err)
+	}
+	if img.CloudUser == "" {
+		return "", fmt.Errorf("catalog entry %s names no default user; pass --user", img.ID())
+	}
+	return img.CloudUser, nil
+}
+
+// vmAccessUsers lists the users the VM's SSH public keys are
+// propagated to by the guest agent.
+func vmAccessUsers(vm *k8sapi.VirtualMachine) []string {
+	var users []string
+	creds, _ := vm.Spec.Template.Spec["accessCredentials"].([]any)
+	for _, c := range creds {
+		cred, _ := c.(map[string]any)
+		key, _ := cred["sshPublicKey"].(map[string]any)
+		prop, _ := key["propagationMethod"].(map[string]any)
+		agent, _ := prop["qemuGuestAgent"].(map[string]any)
+		list, _ := agent["users"].([]any)
+		for _, u := range list {
+			if s, _ := u.(string); s != "" {
+				users = append(users, s)
+			}
+		}
+	}
+	return dedupe(users)
+}
+
+// resolveSSHEndpoint picks the address per --via. auto never falls
+// back to the private address: whether the workstation is routed to
+// the Project network is not something the API can tell.
+func resolveSSHEndpoint(ctx context.Context, cli *k8sapi.Client, ns, vm string, vmi *k8sapi.VirtualMachineInstance, via string) (sshEndpoint, error) {
+	if via == "private" {
+		addr := vmiAddress(vmi)
+		if addr == "" {
+			return sshEndpoint{}, fmt.Errorf("VM %s reports no Project-network address yet; is the guest agent running?", vm)
+		}
+		return sshEndpoint{Host: addr, Port: 22, Via: "private"}, nil
+	}
+	if via == "auto" || via == "fip" {
+		fips, err := cli.ListFIps(ctx, ns)
+		if err != nil && via == "fip" {
+			return sshEndpoint{}, fmt.Errorf("list Floating IPs: %w", err)
+		}
+		if err == nil {
+			if ep, ok := fipEndpoint(fips.Items, vm); ok {
+				return ep, nil
+			}
+		}
+		if via == "fip" {
+			return sshEndpoint{}, fmt.Errorf("no ready Floating IP targets VM %s; create one with `kube-dc ip allocate <name>` and `kube-dc ip attach <name> vm %s`", vm, vm)
+		}
+	}
+	if via == "auto" || via == "lb" {
+		svcs, err := cli.ListServices(ctx, ns)
+		if err != nil && via == "lb" {
+			return sshEndpoint{}, fmt.Errorf("list Services: %w", err)
+		}
+		if err == nil {
+			if ep, ok := lbEndpoint(svcs.Items, vm); ok {
+				return ep, nil
+			}
+		}
+		if via == "lb" {
+			return sshEndpoint{}, fmt.Errorf("no LoadBalancer Service with an address forwards to port 22 of VM %s (selector %s=%s)", vm, vmNameLabel, vm)
+		}
+	}
+	if via == "auto" {
+		review, err := cli.SelfSubjectAccessReview(ctx, k8sapi.ResourceAttributes{
+			Namespace: ns, Verb: "get", Group: "subresources.kubevirt.io",
+			Resource: "virtualmachineinstances", Subresource: "portforward", Name: vm,
+		})
+		if err != nil || !review.Allowed {
+			return sshEndpoint{}, fmt.Errorf(`VM %s has no address reachable from here: no ready Floating IP targets it and no LoadBalancer Service forwards to its port 22.
+Expose it with a Floating IP (kube-dc ip attach <ip> vm %s) or an EIP-bound LoadBalancer,
+or pass --via private from a host routed to the Project network`, vm, vm)
+		}
+	}
+	return sshEndpoint{Host: vm, Port: 22, Via: "tunnel"}, nil
+}
+
+// fipEndpoint returns the external address of a ready FIp targeting
+// the VM.
+func fipEndpoint(fips []k8sapi.FIp, vm string) (sshEndpoint, bool) {
+	sort.Slice(fips, func(i, j int) bool { return fips[i].Metadata.Name < fips[j].Metadata.Name })
+	for _, f := range fips {
+		if f.Spec.VMTarget == nil || f.Spec.VMTarget.VMName != vm {
+			continue
+		}
+		if f.Status.Ready && f.Status.ExternalIP != "" {
+			return sshEndpoint{Host: f.Status.ExternalIP, Port: 22, Via: "fip/" + f.Metadata.Name}, true
+		}
+	}
+	return sshEndpoint{}, false
+}
+
+// lbEndpoint returns the ingress address and external port of a
+// LoadBalancer Service selecting the VM and forwarding to port 22.
+// The conventional {vm}-ssh Service wins over others.
+func lbEndpoint(svcs []k8sapi.Service, vm string) (sshEndpoint, bool) {
+	sort.Slice(svcs, func(i, j int) bool {
+		a, b := svcs[i].Metadata.Name == vm+"-ssh", svcs[j].Metadata.Name == vm+"-ssh"
+		if a != b {
+			return a
+		}
+		return svcs[i].Metadata.Name < svcs[j].Metadata.Name
+	})
+	for _, s := range svcs {
+		if s.Spec.Type != "LoadBalancer" || s.Spec.Selector[vmNameLabel] != vm {
+			continue
+		}
+		var ip string
+		for _, in := range s.Status.LoadBalancer.Ingress {
+			if ip = fmtCoalesce(in.IP, in.Hostname); ip != "" {
+				break
+			}
+		}
+		if ip == "" {
+			continue
+		}
+		for _, p := range s.Spec.Ports {
+			if (p.Protocol == "" || p.Protocol == "TCP") && p.TargetPortNumber() == 22 {
+				return sshEndpoint{Host: ip, Port: p.Port, Via: "lb/" + s.Metadata.Name}, true
+			}
+		}
+	}
+	return sshEndpoint{}, false
+}
+
+// buildSSHArgs assembles the ssh command line. Through the tunnel the
+// host key is filed under a stable per-VM alias instead of the
+// meaningless host label.
+func buildSSHArgs(ep sshEndpoint, user, keyFile, ns, vm, self string, remote []string) []string {
+	args := []string{"-i", keyFile, "-o", "IdentitiesOnly=yes"}
+	if ep.Port != 22 {
+		args = append(args, "-p", strconv.Itoa(ep.Port))
+	}
+	if ep.Via == "tunnel" {
+		args = append(args,
+			"-o", fmt.Sprintf("ProxyCommand=%s vm tunnel %s -n %s --port 22", shellQuote(self), shellQuote(vm), shellQuote(ns)),
+			"-o", fmt.Sprintf("HostKeyAlias=%s.%s.kube-dc", vm, ns),
+		)
+	}
+	args = append(args, user+"@"+ep.Host)
+	return append(args, remote...)
+}
+
+// shellQuote quotes s for the /bin/sh that runs ssh's ProxyCommand.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// writeTempKey stores a private key in a fresh 0600 file. The returned
+// cleanup removes it.
+func writeTempKey(key []byte) (string, func(), error) {
+	f, err := os.CreateTemp("", "kube-dc-ssh-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("create key file: %w", err)
+	}
+	cleanup := func() { _ = os.Remove(f.Name()) }
+	if len(key) > 0 && key[len(key)-1] != '\n' {
+		// OpenSSH rejects a PEM key without the final newline.
+		key = append(key, '\n')
+	}
+	err = f.Chmod(0o600)
+	if err == nil {
+		_, err = f.Write(key)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("write key file: %w", err)
+	}
+	return f.Name(), cleanup, nil
+}
+
+// -------- tunnel (ssh ProxyCommand) --------------------------------
+
+func vmTunnelCmd() *cobra.Command {
+	var namespace string
+	var port int
+	cmd := &cobra.Command{
+		Use:    "tunnel <name>",
+		Short:  "Relay stdin/stdout to a port inside a VM (ssh ProxyCommand)",
+		Hidden: true,
+		Args:   cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			stream, err := cli.VMIPortForward(context.Background(), scope.Namespace, args[0], port)
+			if err != nil {
+				var apiErr *k8sapi.APIError
+				if errors.As(err, &apiErr) && apiErr.Status == 403 {
+					return fmt.Errorf("port-forward to VM %s is not allowed for your role; expose it with a Floating IP or a LoadBalancer instead", args[0])
+				}
+				return err
+			}
+			defer stream.Close()
+			return relay(stream, os.Stdin, os.Stdout)
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().IntVar(&port, "port", 22, "Port inside the VM")
+	return cmd
+}
+
+// relay copies in → stream and stream → out until either side ends.
+func relay(stream io.ReadWriter, in io.Reader, out io.Writer) error {
+	errc := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(stream, in)
+		errc <- err
+	}()
+	go func() {
+		_, err := io.Copy(out, stream)
+		errc <- err
+	}()
+	return <-errc
+}
+
+// -------- console --------------------------------------------------
+
+func vmConsoleCmd() *cobra.Command {
+	var namespace string
+	cmd := &cobra.Command{
+		Use:   "console <name>",
+		Short: "Attach to a VM's serial console",
+		Long: `Attach to the serial console of a running VM through the API server, with
+your login token. Press Ctrl+] to detach; the guest keeps running.
+
+Most cloud images print a login prompt on the serial console; log in with a
+password set through cloud-init, as the Project key is for SSH only.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			_, err = cli.GetVirtualMachineInstance(ctx, scope.Namespace, name)
+			cancel()
+			if k8sapi.IsNotFound(err) {
+				return fmt.Errorf("VM %s is not running; start it with `kube-dc vm start %s`", name, name)
+			}
+			if err != nil {
+				return err
+			}
+			stream, err := cli.VMIConsole(context.Background(), scope.Namespace, name)
+			if err != nil {
+				return err
+			}
+			defer stream.Close()
+
+			fmt.Fprintf(os.Stderr, "Connected to the console of VM %s. Press Ctrl+] to detach.\n", name)
+			if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+				state, err := term.MakeRaw(fd)
+				if err != nil {
+					return fmt.Errorf("set terminal raw mode: %w", err)
+				}
+				defer term.Restore(fd, state)
+			}
+			err = consoleSession(stream, os.Stdin, os.Stdout)
+			// Leave the guest's cursor position behind before printing.
+			fmt.Fprint(os.Stderr, "\r\n")
+			if err != nil && !errors.Is(err, io.EOF) {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Detached from VM %s.\r\n", name)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	return cmd
+}
+
+// consoleSession relays keystrokes to the console and its output back
+// until the user types the escape byte or the console closes.
+func consoleSession(stream io.ReadWriter, in io.Reader, out io.Writer) error {
+	done := make(chan error, 2)
+	var once sync.Once
+	finish := func(err error) { once.Do(func() { done <- err }) }
+	go func() {
+		_, err := io.Copy(out, stream)
+		finish(err)
+	}()
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, err := in.Read(buf)
+			if n > 0 {
+				chunk := buf[:n]
+				i := strings.IndexByte(string(chunk), consoleEscape)
+				if i >= 0 {
+					chunk = chunk[:i]
+				}
+				if len(chunk) > 0 {
+					if _, werr := stream.Write(chunk); werr != nil {
+						finish(werr)
+						return
+					}
+				}
+				if i >= 0 {
+					finish(nil)
+					return
+				}
+			}
+			if err != nil {
+				finish(err)
+				return
+			}
+		}
+	}()
+	return <-done
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+)
+
+func TestFIPAndLBEndpoints(t *testing.T) {
+	fips := []k8sapi.FIp{
+		{Metadata: k8sapi.ObjectMeta{Name: "db-fip"}, Spec: k8sapi.FIpSpec{VMTarget: &k8sapi.FIpVMTarget{VMName: "db-1"}},
+			Status: k8sapi.FIpStatus{Ready: true, ExternalIP: "203.0.113.9"}},
+		{Metadata: k8sapi.ObjectMeta{Name: "web-fip"}, Spec: k8sapi.FIpSpec{VMTarget: &k8sapi.FIpVMTarget{VMName: "web-1"}}},
+	}
+	if ep, ok := fipEndpoint(fips, "db-1"); !ok || ep != (sshEndpoint{Host: "203.0.113.9", Port: 22, Via: "fip/db-fip"}) {
+		t.Errorf("db-1 = %+v, %v", ep, ok)
+	}
+	if _, ok := fipEndpoint(fips, "web-1"); ok {
+		t.Error("a FIP that is not ready must be skipped")
+	}
+
+	var svcs []k8sapi.Service
+	raw := `[
+	  {"metadata":{"name":"web-http"},"spec":{"type":"LoadBalancer","selector":{"vm.kubevirt.io/name":"web-1"},
+	    "ports":[{"port":80,"targetPort":8080}]},"status":{"loadBalancer":{"ingress":[{"ip":"198.51.100.2"}]}}},
+	  {"metadata":{"name":"web-1-admin"},"spec":{"type":"LoadBalancer","selector":{"vm.kubevirt.io/name":"web-1"},
+	    "ports":[{"port":22}]},"status":{"loadBalancer":{"ingress":[{"ip":"198.51.100.3"}]}}},
+	  {"metadata":{"name":"web-1-ssh"},"spec":{"type":"LoadBalancer","selector":{"vm.kubevirt.io/name":"web-1"},
+	    "ports":[{"port":2222,"targetPort":22}]},"status":{"loadBalancer":{"ingress":[{"ip":"198.51.100.4"}]}}},
+	  {"metadata":{"name":"db-ssh"},"spec":{"type":"ClusterIP","selector":{"vm.kubevirt.io/name":"db-1"},
+	    "ports":[{"port":22}]}}
+	]`
+	if err := json.Unmarshal([]byte(raw), &svcs); err != nil {
+		t.Fatal(err)
+	}
+	if ep, ok := lbEndpoint(svcs, "web-1"); !ok || ep != (sshEndpoint{Host: "198.51.100.4", Port: 2222, Via: "lb/web-1-ssh"}) {
+		t.Errorf("web-1 = %+v, %v", ep, ok)
+	}
+	if _, ok := lbEndpoint(svcs, "db-1"); ok {
+		t.Error("a ClusterIP Service is not reachable from outside")
+	}
+}
+
+func TestBuildSSHArgs(t *testing.T) {
+	got := buildSSHArgs(sshEndpoint{Host: "198.51.100.4", Port: 2222, Via: "lb/web-1-ssh"}, "ubuntu", "/tmp/k", "acme-web", "web-1", "", []string{"uptime"})
+	if want := "-i /tmp/k -o IdentitiesOnly=yes -p 2222 ubuntu@198.51.100.4 uptime"; strings.Join(got, " ") != want {
+		t.Errorf("lb args = %q", got)
+	}
+	got = buildSSHArgs(sshEndpoint{Host: "web-1", Port: 22, Via: "tunnel"}, "ubuntu", "/tmp/k", "acme-web", "web-1", "/opt/my tools/kube-dc", nil)
+	joined := strings.Join(got, " ")
+	for _, want := range []string{
+		"ProxyCommand='/opt/my tools/kube-dc' vm tunnel web-1 -n acme-web --port 22",
+		"HostKeyAlias=web-1.acme-web.kube-dc",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("tunnel args lack %q: %q", want, got)
+		}
+	}
+	if strings.Contains(joined, "StrictHostKeyChecking") {
+		t.Error("host-key checking must stay on")
+	}
+}
+
+func TestVMAccessUsers(t *testing.T) {
+	src, _ := imageSource(&ubuntuImage, "")
+	_, vm, _ := buildVM(vmOpts{Name: "web-1", Namespace: "acme-web"}, &ubuntuImage, src, vmSize{CPU: 1, Memory: "1G", Disk: "20G"}, "local-path", "ReadWriteOnce", "Filesystem")
+	raw, _ := json.Marshal(vm)
+	var back k8sapi.VirtualMachine
+	_ = json.Unmarshal(raw, &back)
+	if users := vmAccessUsers(&back); len(users) != 1 || users[0] != "ubuntu" {
+		t.Errorf("users = %v", users)
+	}
+	if back.Metadata.Annotations[vmOSImageAnnotation] != "ubuntu-24.04" {
+		t.Errorf("annotations = %v", back.Metadata.Annotations)
+	}
+}
+
+func TestWriteTempKey(t *testing.T) {
+	path, cleanup, err := writeTempKey([]byte("-----BEGIN KEY-----"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil || fi.Mode().Perm() != 0o600 {
+		t.Fatalf("mode = %v, %v", fi.Mode(), err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "-----BEGIN KEY-----\n" {
+		t.Errorf("content = %q", b)
+	}
+	cleanup()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("key file left behind: %v", err)
+	}
+}
+
+func TestConsoleSessionEscape(t *testing.T) {
+	guest := &bytes.Buffer{}
+	stream := struct {
+		io.Reader
+		io.Writer
+	}{strings.NewReader("login: "), guest}
+	var out bytes.Buffer
+	err := consoleSession(stream, strings.NewReader("root\r\x1dignored"), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if guest.String() != "root\r" {
+		t.Errorf("sent to guest = %q", guest.String())
+	}
+}
+
+// TestVMTunnelStream relays through a stand-in for the KubeVirt
+// portforward websocket, which echoes what it receives.
+func TestVMTunnelStream(t *testing.T) {
+	upgrader := websocket.Upgrader{Subprotocols: []string{"plain.kubevirt.io"}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/apis/subresources.kubevirt.io/v1/namespaces/acme-web/virtualmachineinstances/web-1/portforward/22/tcp":
+		case "/apis/subresources.kubevirt.io/v1/namespaces/acme-web/virtualmachineinstances/db-1/portforward/22/tcp":
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"kind":"Status","message":"virtualmachineinstances/portforward is forbidden"}`))
+			return
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			typ, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(typ, msg); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+	cli, _ := k8sapi.New(srv.URL, "token", "", false)
+
+	stream, err := cli.VMIPortForward(context.Background(), "acme-web", "web-1", 22)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- relay(stream, inR, outW) }()
+	const hello = "SSH-2.0-OpenSSH_9.6\r\n"
+	go func() { _, _ = inW.Write([]byte(hello)) }()
+	echo := make([]byte, len(hello))
+	if _, err := io.ReadFull(outR, echo); err != nil || string(echo) != hello {
+		t.Errorf("echo = %q, %v", echo, err)
+	}
+	_ = inW.Close()
+	if err := <-done; err != nil {
+		t.Errorf("relay: %v", err)
+	}
+	stream.Close()
+
+	_, err = cli.VMIPortForward(context.Background(), "acme-web", "db-1", 22)
+	apiErr, ok := err.(*k8sapi.APIError)
+	if !ok || apiErr.Status != http.StatusForbidden || !strings.Contains(apiErr.Message, "forbidden") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,529 @@
+// `kube-dc vm import` — bring a local disk image (qcow2, VMDK, raw) or a
+// whole OVA into the current Project as a VirtualMachine. Each disk
+// becomes an upload DataVolume that is fed through the CDI upload proxy
+// (internal/cdiupload); the VM is created once every disk is in.
+//
+// Re-running the same import after an interruption picks up where it
+// stopped: DataVolumes that already Succeeded are kept, the one that
+// was in flight is uploaded again from the start.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/cdiupload"
+	"github.com/shalb/kube-dc/cli/internal/diskimage"
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// importSourceAnnotation ties an upload DataVolume to the file it is
+// fed from ("<file>:<bytes>"), so a re-run never mixes two images.
+const importSourceAnnotation = "kube-dc.com/import-source"
+
+// importOpts are the `vm import` flags.
+type importOpts struct {
+	Name, Namespace        string
+	File                   string
+	CPU                    int
+	Memory, DiskSize       string
+	StorageClass           string
+	AccessMode, VolumeMode string
+	LegacyDevices          bool
+	NoStart                bool
+}
+
+// importDisk is one disk to upload: where its bytes are and how big the
+// guest sees it.
+type importDisk struct {
+	Source      string // "<file>" or "<file>/<member>" for an OVA disk
+	Format      string
+	Reader      io.ReaderAt
+	Bytes       int64 // what is sent
+	VirtualSize int64 // what the volume must hold
+}
+
+// importPlan is everything read from the local file.
+type importPlan struct {
+	Disks    []importDisk
+	CPU      int
+	Memory   string
+	EFI      bool
+	Windows  bool
+	Original string // OVF VirtualSystem name, for the summary
+}
+
+// openImport inspects file: an OVA is indexed and its descriptor
+// parsed, anything else is probed as a single disk image. The returned
+// closer releases the file.
+func openImport(file string) (*importPlan, io.Closer, error) {
+	if strings.EqualFold(filepath.Ext(file), ".ova") {
+		ova, err := diskimage.OpenOVA(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		vm := ova.VM
+		plan := &importPlan{
+			CPU:      vm.CPUs,
+			EFI:      vm.Firmware == "efi",
+			Windows:  strings.Contains(strings.ToLower(vm.OSType), "windows"),
+			Original: vm.Name,
+		}
+		if vm.MemoryBytes > 0 {
+			plan.Memory = quantityGi(vm.MemoryBytes, 1)
+		}
+		for _, d := range vm.Disks {
+			r := ova.DiskReader(d)
+			format, virtual, err := diskimage.Probe(r, r.Size())
+			if err != nil {
+				ova.Close()
+				return nil, nil, fmt.Errorf("%s in %s: %w", d.File, file, err)
+			}
+			plan.Disks = append(plan.Disks, importDisk{
+				Source: filepath.Base(file) + "/" + d.File, Format: format, Reader: r, Bytes: r.Size(),
+				VirtualSize: max(virtual, d.CapacityBytes),
+			})
+		}
+		return plan, ova, nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	format, virtual, err := diskimage.Probe(f, st.Size())
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("%s: %w", file, err)
+	}
+	plan := &importPlan{Disks: []importDisk{{
+		Source: filepath.Base(file), Format: format, Reader: f, Bytes: st.Size(), VirtualSize: virtual,
+	}}}
+	return plan, f, nil
+}
+
+// quantityGi renders n bytes times factor as whole GiB, rounded up.
+func quantityGi(n int64, factor float64) string {
+	return fmt.Sprintf("%dGi", int64(math.Ceil(float64(n)*factor/(1<<30))))
+}
+
+// importDiskName names the DataVolume of the i-th disk: the boot disk
+// matches `vm create` (<vm>-disk), the others are <vm>-disk-1, -2, ...
+func importDiskName(vm string, i int) string {
+	if i == 0 {
+		return vmDiskName(vm)
+	}
+	return fmt.Sprintf("%s-%d", vmDiskName(vm), i)
+}
+
+// importSourceID is the importSourceAnnotation value for a disk.
+func importSourceID(d importDisk) string {
+	return fmt.Sprintf("%s:%d", d.Source, d.Bytes)
+}
+
+// buildImport assembles the upload DataVolumes and the VirtualMachine.
+// Pure — no I/O. Flags override what the OVA says; a single image
+// defaults to 2 vCPU and 4Gi. Volumes get 10% headroom over the virtual
+// size for CDI's filesystem overhead.
+func buildImport(opts importOpts, plan *importPlan, class, access, volume string) ([]*k8sapi.DataVolume, *k8sapi.VirtualMachine, error) {
+	if !projectNameRE.MatchString(opts.Name) || len(opts.Name) > 58 {
+		return nil, nil, fmt.Errorf("invalid VM name %q: use lowercase letters, digits and '-', at most 58 characters", opts.Name)
+	}
+	cpu := opts.CPU
+	if cpu == 0 {
+		cpu = plan.CPU
+	}
+	if cpu == 0 {
+		cpu = 2
+	}
+	if cpu < 0 {
+		return nil, nil, fmt.Errorf("invalid --cpu %d", cpu)
+	}
+	memory := fmtCoalesce(opts.Memory, plan.Memory, "4Gi")
+	if _, err := resource.ParseQuantity(memory); err != nil {
+		return nil, nil, fmt.Errorf("invalid --memory %q: use a quantity such as 4Gi", memory)
+	}
+
+	labels := map[string]string{"app.kubernetes.io/name": opts.Name}
+	bus, nic := "virtio", map[string]any{"name": "default", "bridge": map[string]any{}}
+	if opts.LegacyDevices || plan.Windows {
+		bus = "sata"
+		nic["model"] = "e1000"
+	}
+	var dvs []*k8sapi.DataVolume
+	var disks, volumes []any
+	for i, d := range plan.Disks {
+		size := quantityGi(d.VirtualSize, 1.1)
+		if i == 0 && opts.DiskSize != "" {
+			q, err := resource.ParseQuantity(opts.DiskSize)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid --disk-size %q: use a quantity such as 40Gi", opts.DiskSize)
+			}
+			if q.Value() < d.VirtualSize {
+				return nil, nil, fmt.Errorf("--disk-size %s is smaller than the image's virtual size of %s", opts.DiskSize, humanBytes(d.VirtualSize))
+			}
+			size = opts.DiskSize
+		}
+		dv := &k8sapi.DataVolume{
+			Metadata: k8sapi.ObjectMeta{
+				Name: importDiskName(opts.Name, i), Namespace: opts.Namespace, Labels: labels,
+				Annotations: map[string]string{importSourceAnnotation: importSourceID(d)},
+			},
+			Spec: k8sapi.DataVolumeSpec{Source: k8sapi.DataVolumeSource{Upload: &k8sapi.DataVolumeUploadSource{}}},
+		}
+		dv.Spec.PVC.AccessModes = []string{access}
+		if volume != "Filesystem" {
+			dv.Spec.PVC.VolumeMode = volume
+		}
+		dv.Spec.PVC.StorageClassName = class
+		dv.Spec.PVC.Resources.Requests = map[string]string{"storage": size}
+		dvs = append(dvs, dv)
+
+		volName := "root"
+		if i > 0 {
+			volName = fmt.Sprintf("disk-%d", i)
+		}
+		disk := map[string]any{"name": volName, "disk": map[string]any{"bus": bus}}
+		if i == 0 {
+			disk["bootOrder"] = 1
+		}
+		disks = append(disks, disk)
+		volumes = append(volumes, map[string]any{"name": volName, "dataVolume": map[string]any{"name": dv.Metadata.Name}})
+	}
+
+	domain := map[string]any{
+		"cpu":       map[string]any{"cores": cpu},
+		"memory":    map[string]any{"guest": memory},
+		"devices":   map[string]any{"disks": disks, "interfaces": []any{nic}},
+		"resources": map[string]any{"requests": map[string]any{"memory": memory}},
+	}
+	if plan.EFI {
+		domain["firmware"] = map[string]any{"bootloader": map[string]any{"efi": map[string]any{"secureBoot": false}}}
+	}
+	running := !opts.NoStart
+	vm := &k8sapi.VirtualMachine{
+		Metadata: k8sapi.ObjectMeta{Name: opts.Name, Namespace: opts.Namespace, Labels: labels},
+		Spec: k8sapi.VirtualMachineSpec{
+			Running: &running,
+			Template: k8sapi.VMTemplate{
+				Metadata: map[string]any{"labels": labels},
+				Spec: map[string]any{
+					"domain": domain,
+					"networks": []any{map[string]any{
+						"name":   "default",
+						"multus": map[string]any{"default": true, "networkName": opts.Namespace + "/default"},
+					}},
+					"volumes": volumes,
+				},
+			},
+		},
+	}
+	return dvs, vm, nil
+}
+
+func vmImportCmd() *cobra.Command {
+	var opts importOpts
+	var proxyURL string
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "import <name> --file <image>",
+		Short: "Import a local qcow2, VMDK, raw or OVA image as a VM",
+		Long: `Upload a local disk image into the current Project and create a VM that
+boots from it.
+
+--file takes a qcow2, VMDK (monolithic sparse or streamOptimized) or raw
+image, or an OVA. For an OVA, CPU, memory, firmware (BIOS/EFI) and every
+disk come from its OVF descriptor; --cpu and --memory override them. A single
+image defaults to 2 vCPU and 4Gi. Each disk's volume is sized from the image's
+virtual size plus 10%; --disk-size sets the boot disk's size instead.
+
+Disks travel through the CDI upload proxy, by default
+https://cdi-uploadproxy.<domain>; pass --upload-proxy for a different one.
+Failed uploads are retried. Uploads do not resume within a disk: a retry, or a
+re-run after the import was interrupted, sends that disk again from its first
+byte. Re-run the same command to continue; disks that finished uploading are
+kept and skipped.
+
+The VM gets the Project's default network but no cloud-init: the image keeps
+its own users and credentials. Guests without virtio drivers (Windows OVAs are
+detected) get SATA disks and an e1000 NIC; --legacy-devices forces that.`,
+		Example: `  kube-dc vm import legacy-app --file legacy-app.qcow2
+  kube-dc vm import erp --file erp-appliance.ova --memory 16Gi
+  kube-dc vm import win-build --file win2019.vmdk --legacy-devices --disk-size 120Gi`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Name = args[0]
+			if opts.File == "" {
+				return errors.New("--file is required")
+			}
+			cmd.SilenceUsage = true
+			plan, closer, err := openImport(opts.File)
+			if err != nil {
+				return err
+			}
+			defer closer.Close()
+
+			scope, err := resolveScope(opts.Namespace)
+			if err != nil {
+				return err
+			}
+			opts.Namespace = scope.Namespace
+			be, err := scope.backend()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			classes, err := be.ListVMStorageClasses(ctx, scope.Namespace)
+			if err != nil {
+				return fmt.Errorf("read VM StorageClasses: %w", err)
+			}
+			class, access, volume, err := selectStorage(classes, opts.StorageClass, opts.AccessMode, opts.VolumeMode)
+			if err != nil {
+				return err
+			}
+			dvs, vm, err := buildImport(opts, plan, class, access, volume)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			if _, err := cli.GetVirtualMachine(ctx, scope.Namespace, opts.Name); err == nil {
+				return fmt.Errorf("VM %s already exists", opts.Name)
+			} else if !k8sapi.IsNotFound(err) {
+				return err
+			}
+
+			// Uploads can take far longer than a login token lives, so
+			// every request from here on gets a freshly refreshed one.
+			fresh := func() (*k8sapi.Client, error) {
+				if err := scope.refreshToken(); err != nil {
+					return nil, err
+				}
+				return scope.k8s()
+			}
+			up := cdiupload.New(fmtCoalesce(proxyURL, "https://cdi-uploadproxy."+scope.Domain))
+			for i, dv := range dvs {
+				if err := importOne(context.Background(), fresh, up, dv, plan.Disks[i], timeout); err != nil {
+					return err
+				}
+			}
+
+			if cli, err = fresh(); err != nil {
+				return err
+			}
+			ctx, cancel = ctxWithTimeout()
+			defer cancel()
+			created, err := cli.CreateVirtualMachine(ctx, vm)
+			if err != nil {
+				return fmt.Errorf("create VirtualMachine %s (its disks are uploaded; re-run to retry): %w", opts.Name, err)
+			}
+			cpu, memory := vmShape(created)
+			from := filepath.Base(opts.File)
+			if plan.Original != "" {
+				from += " (" + plan.Original + ")"
+			}
+			fmt.Printf("Created VM %s from %s: %s vCPU, %s memory, %d disk(s) on %s\n", opts.Name, from, cpu, memory, len(dvs), class)
+			if opts.NoStart {
+				fmt.Printf("The VM is stopped; start it with `kube-dc vm start %s`\n", opts.Name)
+			} else {
+				fmt.Printf("The VM is starting; follow with `kube-dc vm describe %s` or `kube-dc vm console %s`\n", opts.Name, opts.Name)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVarP(&opts.File, "file", "f", "", "qcow2, VMDK, raw or OVA file to import (required)")
+	cmd.Flags().IntVar(&opts.CPU, "cpu", 0, "vCPU cores (default: the OVA's, or 2)")
+	cmd.Flags().StringVar(&opts.Memory, "memory", "", "Memory, e.g. 8Gi (default: the OVA's, or 4Gi)")
+	cmd.Flags().StringVar(&opts.DiskSize, "disk-size", "", "Boot disk size, e.g. 80Gi (default: virtual size + 10%)")
+	cmd.Flags().StringVar(&opts.StorageClass, "storage-class", "", "StorageClass for the disks (default: the default VM class)")
+	cmd.Flags().StringVar(&opts.AccessMode, "access-mode", "", "Disk access mode: ReadWriteOnce|ReadWriteMany (default: the class's first supported pair)")
+	cmd.Flags().StringVar(&opts.VolumeMode, "volume-mode", "", "Disk volume mode: Filesystem|Block (default: the class's first supported pair)")
+	cmd.Flags().BoolVar(&opts.LegacyDevices, "legacy-devices", false, "Use SATA disks and an e1000 NIC for guests without virtio drivers")
+	cmd.Flags().BoolVar(&opts.NoStart, "no-start", false, "Create the VM stopped")
+	cmd.Flags().StringVar(&proxyURL, "upload-proxy", "", "CDI upload proxy URL (default: https://cdi-uploadproxy.<domain>)")
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Minute, "How long to wait for each disk to be ready for upload and to finish processing")
+	return cmd
+}
+
+// importOne brings one disk in: create its DataVolume (or reuse the
+// one a previous run created), upload when it is UploadReady, and wait
+// for CDI to finish converting it. client is called for each API
+// request, so none goes out with a token that expired mid-upload.
+func importOne(ctx context.Context, client func() (*k8sapi.Client, error), up *cdiupload.Client, dv *k8sapi.DataVolume, disk importDisk, timeout time.Duration) error {
+	ns, name := dv.Metadata.Namespace, dv.Metadata.Name
+	cli, err := client()
+	if err != nil {
+		return err
+	}
+	reqCtx, cancel := ctxWithTimeout()
+	existing, err := cli.GetDataVolume(reqCtx, ns, name)
+	switch {
+	case k8sapi.IsNotFound(err):
+		_, err = cli.CreateDataVolume(reqCtx, dv)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("create DataVolume %s: %w", name, err)
+		}
+	case err != nil:
+		cancel()
+		return err
+	default:
+		cancel()
+		if got := existing.Metadata.Annotations[importSourceAnnotation]; got != importSourceID(disk) {
+			return fmt.Errorf("DataVolume %s already exists for %s, not %s; delete it or pick another VM name",
+				name, fmtCoalesce(got, "another image"), importSourceID(disk))
+		}
+		if existing.Status.Phase == "Succeeded" {
+			fmt.Fprintf(os.Stderr, "%s: already uploaded to %s\n", disk.Source, name)
+			return nil
+		}
+	}
+
+	if _, err := waitForDataVolume(ctx, client, ns, name, "UploadReady", timeout); err != nil {
+		return err
+	}
+	token := func(ctx context.Context) (string, error) {
+		cli, err := client()
+		if err != nil {
+			return "", err
+		}
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		tok, err := cli.CreateUploadToken(ctx, ns, name)
+		if err != nil {
+			return "", fmt.Errorf("upload token for %s: %w", name, err)
+		}
+		return tok, nil
+	}
+	progress := newUploadProgress(os.Stderr, fmt.Sprintf("%s → %s (%s)", disk.Source, name, disk.Format))
+	if err := up.Upload(ctx, token, disk.Reader, disk.Bytes, progress.update); err != nil {
+		progress.done(false)
+		return fmt.Errorf("upload %s: %w (re-run the import to retry)", disk.Source, err)
+	}
+	progress.done(true)
+	if _, err := waitForDataVolume(ctx, client, ns, name, "Succeeded", timeout); err != nil {
+		return err
+	}
+	return nil
+}
+
+// waitForDataVolume polls until the DataVolume reaches phase, failing
+// early when it reports Failed.
+func waitForDataVolume(ctx context.Context, client func() (*k8sapi.Client, error), ns, name, phase string, timeout time.Duration) (*k8sapi.DataVolume, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var last string
+	for {
+		cli, err := client()
+		if err != nil {
+			return nil, err
+		}
+		reqCtx, reqCancel := context.WithTimeout(ctx, 30*time.Second)
+		dv, err := cli.GetDataVolume(reqCtx, ns, name)
+		reqCancel()
+		switch {
+		case err == nil:
+			last = dv.Status.Phase
+			if last == phase || (phase == "UploadReady" && last == "Succeeded") {
+				return dv, nil
+			}
+			if last == "Failed" {
+				msg := fmt.Sprintf("DataVolume %s failed", name)
+				if pending := notTrueConditions(dv.Status.Conditions); pending != "" {
+					msg += ": " + pending
+				}
+				return nil, errors.New(msg)
+			}
+		case k8sapi.IsNotFound(err):
+			return nil, fmt.Errorf("DataVolume %s not found", name)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timed out after %s waiting for DataVolume %s to be %s (phase %s)", timeout, name, phase, fmtCoalesce(last, "unknown"))
+		case <-time.After(vmPollInterval):
+		}
+	}
+}
+
+// uploadProgress renders upload progress on stderr: a self-rewriting
+// line on a terminal, a line per 10% otherwise (CI logs).
+type uploadProgress struct {
+	w      io.Writer
+	label  string
+	tty    bool
+	last   int // last percentage printed
+	start  time.Time
+	prevAt time.Time
+}
+
+func newUploadProgress(w *os.File, label string) *uploadProgress {
+	return &uploadProgress{w: w, label: label, tty: term.IsTerminal(int(w.Fd())), last: -1, start: time.Now()}
+}
+
+func (p *uploadProgress) update(sent, total int64) {
+	pct := 100
+	if total > 0 {
+		pct = int(sent * 100 / total)
+	}
+	if sent < total && pct < p.last {
+		// A retry starts over.
+		p.last = -1
+		fmt.Fprintf(p.w, "\n%s: retrying\n", p.label)
+	}
+	if p.tty {
+		if now := time.Now(); now.Sub(p.prevAt) < 200*time.Millisecond && sent < total {
+			return
+		}
+		p.prevAt = time.Now()
+		fmt.Fprintf(p.w, "\r%s  %3d%%  %s / %s", p.label, pct, humanBytes(sent), humanBytes(total))
+		p.last = pct
+		return
+	}
+	if pct/10 > p.last/10 || p.last < 0 {
+		fmt.Fprintf(p.w, "%s  %3d%%  %s / %s\n", p.label, pct, humanBytes(sent), humanBytes(total))
+		p.last = pct
+	}
+}
+
+func (p *uploadProgress) done(ok bool) {
+	if p.tty {
+		fmt.Fprintln(p.w)
+	}
+	if ok {
+		fmt.Fprintf(p.w, "%s: uploaded in %s\n", p.label, time.Since(p.start).Round(time.Second))
+	}
+}
+
+// humanBytes formats n with binary units: 512 B, 1.5 GiB.
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
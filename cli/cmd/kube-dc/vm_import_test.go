@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/cdiupload"
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+)
+
+func TestOpenImport_QCOW2(t *testing.T) {
+	head := make([]byte, 4096)
+	copy(head, "QFI\xfb")
+	binary.BigEndian.PutUint64(head[24:], 10<<30)
+	file := filepath.Join(t.TempDir(), "app.qcow2")
+	if err := os.WriteFile(file, head, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	plan, closer, err := openImport(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+	d := plan.Disks[0]
+	if len(plan.Disks) != 1 || d.Format != "qcow2" || d.VirtualSize != 10<<30 || d.Bytes != 4096 || importSourceID(d) != "app.qcow2:4096" {
+		t.Errorf("plan = %+v", plan)
+	}
+}
+
+func TestBuildImport(t *testing.T) {
+	plan := &importPlan{
+		CPU: 4, Memory: "8Gi", EFI: true,
+		Disks: []importDisk{
+			{Source: "erp.ova/erp-disk1.vmdk", Bytes: 3 << 30, VirtualSize: 20 << 30},
+			{Source: "erp.ova/erp-disk2.vmdk", Bytes: 1 << 20, VirtualSize: 5 << 30},
+		},
+	}
+	opts := importOpts{Name: "erp", Namespace: "acme-web"}
+	dvs, vm, err := buildImport(opts, plan, "rbd-vm", "ReadWriteMany", "Block")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dvs) != 2 || dvs[0].Metadata.Name != "erp-disk" || dvs[1].Metadata.Name != "erp-disk-1" {
+		t.Fatalf("DataVolumes = %+v", dvs)
+	}
+	if dvs[0].Spec.Source.Upload == nil || dvs[0].Spec.PVC.Resources.Requests["storage"] != "22Gi" || dvs[1].Spec.PVC.Resources.Requests["storage"] != "6Gi" {
+		t.Errorf("sizes = %v, %v", dvs[0].Spec.PVC.Resources.Requests, dvs[1].Spec.PVC.Resources.Requests)
+	}
+	if dvs[0].Spec.PVC.VolumeMode != "Block" || dvs[1].Metadata.Annotations[importSourceAnnotation] != "erp.ova/erp-disk2.vmdk:1048576" {
+		t.Errorf("DataVolume 1 = %+v", dvs[1])
+	}
+	b, _ := json.Marshal(vm)
+	for _, want := range []string{`"cores":4`, `"guest":"8Gi"`, `"efi":{`, `"bus":"virtio"`, `"bootOrder":1`, `"name":"erp-disk-1"`, `"running":true`, `"networkName":"acme-web/default"`} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("VM lacks %s: %s", want, b)
+		}
+	}
+	if strings.Contains(string(b), "cloudInit") || strings.Contains(string(b), "accessCredentials") {
+		t.Errorf("an imported VM keeps its own credentials: %s", b)
+	}
+
+	// Flags win over the OVA; legacy devices swap the bus and the NIC.
+	opts.CPU, opts.Memory, opts.DiskSize, opts.LegacyDevices, opts.NoStart = 2, "16Gi", "40Gi", true, true
+	dvs, vm, err = buildImport(opts, plan, "rbd-vm", "ReadWriteOnce", "Filesystem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ = json.Marshal(vm)
+	for _, want := range []string{`"cores":2`, `"guest":"16Gi"`, `"bus":"sata"`, `"model":"e1000"`, `"running":false`} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("VM lacks %s: %s", want, b)
+		}
+	}
+	if dvs[0].Spec.PVC.Resources.Requests["storage"] != "40Gi" || dvs[0].Spec.PVC.VolumeMode != "" {
+		t.Errorf("DataVolume 0 = %+v", dvs[0].Spec.PVC)
+	}
+
+	opts.DiskSize = "10Gi"
+	if _, _, err := buildImport(opts, plan, "rbd-vm", "ReadWriteOnce", "Filesystem"); err == nil || !strings.Contains(err.Error(), "smaller than the image's virtual size of 20.0 GiB") {
+		t.Errorf("small disk: %v", err)
+	}
+	if _, _, err := buildImport(importOpts{Name: "Bad_Name"}, plan, "rbd-vm", "ReadWriteOnce", "Filesystem"); err == nil {
+		t.Error("expected a name error")
+	}
+
+	// A bare image with nothing from an OVF gets the defaults.
+	_, vm, _ = buildImport(importOpts{Name: "app", Namespace: "acme-web"}, &importPlan{Disks: plan.Disks[:1]}, "rbd-vm", "ReadWriteOnce", "Filesystem")
+	if cpu, memory := vmShape(roundTrip(t, vm)); cpu != "2" || memory != "4Gi" {
+		t.Errorf("defaults = %s, %s", cpu, memory)
+	}
+}
+
+func roundTrip(t *testing.T, vm *k8sapi.VirtualMachine) *k8sapi.VirtualMachine {
+	t.Helper()
+	b, _ := json.Marshal(vm)
+	var out k8sapi.VirtualMachine
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	return &out
+}
+
+// importCluster fakes the kube-apiserver side of an upload: one
+// DataVolume that is UploadReady once created and Succeeded once the
+// upload-proxy stand-in has the bytes, and the token endpoint.
+type importCluster struct {
+	mu       sync.Mutex
+	dv       *k8sapi.DataVolume
+	uploaded []byte
+	tokens   int
+}
+
+func (c *importCluster) apiserver(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		const dvs = "/apis/cdi.kubevirt.io/v1beta1/namespaces/acme-web/datavolumes"
+		switch {
+		case r.Method == "GET" && r.URL.Path == dvs+"/app-disk":
+			if c.dv == nil {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"kind":"Status","code":404,"reason":"NotFound"}`))
+				return
+			}
+			c.dv.Status.Phase = "UploadReady"
+			if c.uploaded != nil {
+				c.dv.Status.Phase = "Succeeded"
+			}
+			_ = json.NewEncoder(w).Encode(c.dv)
+		case r.Method == "POST" && r.URL.Path == dvs:
+			c.dv = &k8sapi.DataVolume{}
+			_ = json.NewDecoder(r.Body).Decode(c.dv)
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(c.dv)
+		case r.Method == "POST" && r.URL.Path == "/apis/upload.cdi.kubevirt.io/v1beta1/namespaces/acme-web/uploadtokenrequests":
+			c.tokens++
+			_, _ = w.Write([]byte(`{"status":{"token":"upload-token"}}`))
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func (c *importCluster) proxy() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta1/upload" || r.Header.Get("Authorization") != "Bearer upload-token" {
+			http.Error(w, "bad request", http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		c.mu.Lock()
+		c.uploaded = body
+		c.mu.Unlock()
+	}))
+}
+
+func TestImportOne(t *testing.T) {
+	prev := vmPollInterval
+	vmPollInterval = 5 * time.Millisecond
+	t.Cleanup(func() { vmPollInterval = prev })
+
+	c := &importCluster{}
+	api, proxy := c.apiserver(t), c.proxy()
+	defer api.Close()
+	defer proxy.Close()
+	// Every API request asks for a client, the way the command hands
+	// out one with a freshly refreshed token.
+	var clients int
+	client := func() (*k8sapi.Client, error) {
+		clients++
+		return k8sapi.New(api.URL, "token", "", false)
+	}
+	up := cdiupload.New(proxy.URL)
+
+	image := bytes.Repeat([]byte{0x42}, 3<<20)
+	disk := importDisk{Source: "app.raw", Format: "raw", Reader: bytes.NewReader(image), Bytes: int64(len(image)), VirtualSize: int64(len(image))}
+	plan := &importPlan{Disks: []importDisk{disk}}
+	dvs, _, err := buildImport(importOpts{Name: "app", Namespace: "acme-web"}, plan, "rbd-vm", "ReadWriteOnce", "Filesystem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := importOne(context.Background(), client, up, dvs[0], disk, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(c.uploaded, image) || c.tokens != 1 {
+		t.Errorf("proxy got %d bytes, %d tokens", len(c.uploaded), c.tokens)
+	}
+	// The DataVolume lookup, each poll for UploadReady and Succeeded,
+	// and the upload token.
+	if clients < 4 {
+		t.Errorf("%d clients built; want one per request", clients)
+	}
+
+	// A re-run skips the disk that is already in.
+	if err := importOne(context.Background(), client, up, dvs[0], disk, time.Second); err != nil || c.tokens != 1 {
+		t.Errorf("re-run: %v, %d tokens", err, c.tokens)
+	}
+
+	// A different file never reuses the DataVolume.
+	other := disk
+	other.Source = "other.raw"
+	if err := importOne(context.Background(), client, up, dvs[0], other, time.Second); err == nil || !strings.Contains(err.Error(), "already exists for app.raw") {
+		t.Errorf("other source: %v", err)
+	}
+}
+
+func TestHumanBytes(t *testing.T) {
+	for n, want := range map[int64]string{512: "512 B", 1536: "1.5 KiB", 20 << 30: "20.0 GiB"} {
+		if got := humanBytes(n); got != want {
+			t.Errorf("humanBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,1126 @@
+// `kube-dc vm snapshot` — KubeVirt VirtualMachineSnapshot /
+// VirtualMachineRestore in the current Project, plus a retention policy.
+//
+// A snapshot is a VolumeSnapshot of every disk, so each disk's
+// StorageClass must be one the backend's VM storage-class endpoint
+// reports as snapshot-capable; create and policy set check that up
+// front instead of leaving a snapshot stuck InProgress.
+//
+// A policy is a labelled ConfigMap ({vm}-snapshot-policy) holding how
+// many hourly, daily and weekly snapshots to keep. It is plain data the
+// platform can reconcile; `policy run` applies it client-side — take
+// whatever tier is due, prune what no tier keeps — and is meant to be
+// called from cron or CI on the policy's schedule.
+//
+// Verbs:
+//   create          — VirtualMachineSnapshot of a VM, wait until ready   (backend + k8s)
+//   list            — snapshots, optionally of one VM                    (k8s)
+//   restore         — VirtualMachineRestore onto a stopped VM            (k8s)
+//   delete          — snapshots                                          (k8s)
+//   policy set      — create/update a VM's retention policy              (backend + k8s)
+//   policy list     — policies in the Project                            (k8s)
+//   policy show     — one policy with the snapshots it holds             (k8s)
+//   policy delete   — remove a policy, keeping its snapshots             (k8s)
+//   policy run      — take due snapshots and prune expired ones          (backend + k8s)
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/backend"
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// vmLabel marks the VM a CLI-made snapshot or policy belongs to.
+	vmLabel = "kube-dc.com/vm"
+	// snapshotPolicyLabel marks policy ConfigMaps.
+	snapshotPolicyLabel = "kube-dc.com/snapshot-policy"
+	// snapshotTierLabelPrefix + tier marks a snapshot a policy tier
+	// holds; one snapshot can serve several tiers.
+	snapshotTierLabelPrefix = "snapshot.kube-dc.com/"
+	// maxSnapshotsPerTier bounds a tier's retention.
+	maxSnapshotsPerTier = 100
+	// snapshotDueSlack lets a run fired on schedule take the next
+	// snapshot even when the previous one started a little late.
+	snapshotDueSlack = 5 * time.Minute
+)
+
+// snapshotTiers are the retention tiers, finest first.
+var snapshotTiers = []struct {
+	Name     string
+	Every    time.Duration
+	Schedule string
+}{
+	{"hourly", time.Hour, "0 * * * *"},
+	{"daily", 24 * time.Hour, "0 0 * * *"},
+	{"weekly", 7 * 24 * time.Hour, "0 0 * * 0"},
+}
+
+// snapshotPollInterval is how often the wait loops re-read a snapshot
+// or restore. A var so tests can shorten it.
+var snapshotPollInterval = 3 * time.Second
+
+func vmSnapshotCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "snapshot",
+		Aliases: []string{"snapshots"},
+		Short:   "Snapshot, restore and schedule snapshots of VMs",
+		Long: `Take, list, restore and delete snapshots of a VM's disks and spec, and keep
+a retention policy of hourly, daily and weekly snapshots.
+
+Snapshots need every disk of the VM on a StorageClass that supports volume
+snapshots; local node storage does not. A snapshot lives in the same storage
+system as the disk, so it is not a disaster-recovery copy by itself.`,
+	}
+	cmd.AddCommand(vmSnapshotCreateCmd())
+	cmd.AddCommand(vmSnapshotListCmd())
+	cmd.AddCommand(vmSnapshotRestoreCmd())
+	cmd.AddCommand(vmSnapshotDeleteCmd())
+	cmd.AddCommand(vmSnapshotPolicyCmd())
+	return cmd
+}
+
+// -------- snapshot capability --------------------------------------
+
+// vmDiskClasses maps each disk (DataVolume or PVC) of the VM to its
+// StorageClass.
+func vmDiskClasses(ctx context.Context, cli *k8sapi.Client, ns string, vm *k8sapi.VirtualMachine) (map[string]string, error) {
+	classes := map[string]string{}
+	for _, name := range vmDataVolumes(vm) {
+		dv, err := cli.GetDataVolume(ctx, ns, name)
+		if err != nil && !k8sapi.IsNotFound(err) {
+			return nil, fmt.Errorf("read DataVolume %s: %w", name, err)
+		}
+		if err == nil && dv.Spec.PVC.StorageClassName != "" {
+			classes[name] = dv.Spec.PVC.StorageClassName
+			continue
+		}
+		// No class on the DataVolume: the PVC CDI made for it (same
+		// name) carries the default the API server filled in.
+		pvc, err := cli.GetPersistentVolumeClaim(ctx, ns, name)
+		if err != nil {
+			return nil, fmt.Errorf("read PVC %s: %w", name, err)
+		}
+		classes[name] = pvc.Spec.StorageClassName
+	}
+	for _, name := range vmClaims(vm) {
+		pvc, err := cli.GetPersistentVolumeClaim(ctx, ns, name)
+		if err != nil {
+			return nil, fmt.Errorf("read PVC %s: %w", name, err)
+		}
+		classes[name] = pvc.Spec.StorageClassName
+	}
+	return classes, nil
+}
+
+// vmClaims returns the PVCs the VM's volumes mount directly.
+func vmClaims(vm *k8sapi.VirtualMachine) []string {
+	var names []string
+	volumes, _ := vm.Spec.Template.Spec["volumes"].([]any)
+	for _, v := range volumes {
+		vol, _ := v.(map[string]any)
+		pvc, _ := vol["persistentVolumeClaim"].(map[string]any)
+		if name, _ := pvc["claimName"].(string); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// checkSnapshotCapable refuses when a disk sits on a StorageClass that
+// is not offered for VM disks or does not support snapshots.
+func checkSnapshotCapable(vm string, disks map[string]string, classes []backend.VMStorageClass) error {
+	if len(disks) == 0 {
+		return fmt.Errorf("VM %s has no persistent disks to snapshot", vm)
+	}
+	var capable []string
+	for _, c := range classes {
+		if c.Snapshot {
+			capable = append(capable, c.Name)
+		}
+	}
+	names := make([]string, 0, len(disks))
+	for d := range disks {
+		names = append(names, d)
+	}
+	sort.Strings(names)
+	var bad []string
+	for _, d := range names {
+		ok := false
+		for _, c := range classes {
+			if c.Name == disks[d] {
+				ok = c.Snapshot
+				break
+			}
+		}
+		if !ok {
+			bad = append(bad, fmt.Sprintf("%s (%s)", d, fmtCoalesce(disks[d], "no StorageClass")))
+		}
+	}
+	if len(bad) == 0 {
+		return nil
+	}
+	msg := fmt.Sprintf("VM %s cannot be snapshotted: these disks are on StorageClasses without snapshot support: %s", vm, strings.Join(bad, ", "))
+	if len(capable) > 0 {
+		msg += fmt.Sprintf("; snapshot-capable classes: %s", strings.Join(capable, ", "))
+	} else {
+		msg += "; no VM StorageClass in this installation supports snapshots"
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// ensureSnapshotCapable runs checkSnapshotCapable against the live VM
+// and the backend's class list.
+func ensureSnapshotCapable(ctx context.Context, scope *secretsScope, cli *k8sapi.Client, vm *k8sapi.VirtualMachine) error {
+	disks, err := vmDiskClasses(ctx, cli, scope.Namespace, vm)
+	if err != nil {
+		return err
+	}
+	be, err := scope.backend()
+	if err != nil {
+		return err
+	}
+	classes, err := be.ListVMStorageClasses(ctx, scope.Namespace)
+	if err != nil {
+		return fmt.Errorf("read VM StorageClasses: %w", err)
+	}
+	return checkSnapshotCapable(vm.Metadata.Name, disks, classes)
+}
+
+// -------- create ---------------------------------------------------
+
+// snapshotName is the default name of a snapshot taken at t.
+func snapshotName(vm string, t time.Time) string {
+	return vm + "-" + t.UTC().Format("20060102-150405")
+}
+
+func vmSnapshotCreateCmd() *cobra.Command {
+	var namespace, name, outFlag string
+	var noWait bool
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "create <vm>",
+		Short: "Snapshot a VM and wait until the snapshot is ready",
+		Long: `Snapshot a VM's disks and spec. A running VM is snapshotted online; with the
+QEMU guest agent installed its filesystems are frozen for a consistent
+capture.`,
+		Example: `  kube-dc vm snapshot create web-1
+  kube-dc vm snapshot create web-1 --name web-1-before-upgrade`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			vmName := args[0]
+			if name == "" {
+				name = snapshotName(vmName, time.Now())
+			}
+			if !projectNameRE.MatchString(name) || len(name) > 63 {
+				return fmt.Errorf("invalid snapshot name %q: use lowercase letters, digits and '-', at most 63 characters", name)
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			vm, err := cli.GetVirtualMachine(ctx, scope.Namespace, vmName)
+			if err != nil {
+				return err
+			}
+			if err := ensureSnapshotCapable(ctx, scope, cli, vm); err != nil {
+				return err
+			}
+			snap, err := cli.CreateVMSnapshot(ctx, newVMSnapshot(scope.Namespace, name, vmName, nil))
+			if err != nil {
+				return fmt.Errorf("create snapshot %s: %w", name, err)
+			}
+			if !noWait {
+				if out == outTable {
+					fmt.Printf("Snapshotting VM %s as %s...\n", vmName, name)
+				}
+				if snap, err = waitForVMSnapshot(context.Background(), cli, scope.Namespace, name, timeout); err != nil {
+					return err
+				}
+			}
+			if out != outTable {
+				return printSerialized(out, snap)
+			}
+			if noWait {
+				fmt.Printf("Requested snapshot %s of VM %s; follow with `kube-dc vm snapshot list %s`\n", name, vmName, vmName)
+				return nil
+			}
+			fmt.Printf("Snapshot %s of VM %s is ready (%s)\n", name, vmName, fmtCoalesce(strings.Join(snap.Status.Indications, ", "), "no indications"))
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVar(&name, "name", "", "Snapshot name (default: <vm>-<UTC timestamp>)")
+	cmd.Flags().BoolVar(&noWait, "no-wait", false, "Return once the snapshot is requested")
+	cmd.Flags().DurationVar(&timeout, "timeout", 10*time.Minute, "How long to wait for the snapshot to be ready")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+// newVMSnapshot builds a snapshot of vm, labelled with the VM and with
+// each policy tier it serves.
+func newVMSnapshot(ns, name, vm string, tiers []string) *k8sapi.VirtualMachineSnapshot {
+	labels := map[string]string{vmLabel: vm}
+	for _, t := range tiers {
+		labels[snapshotTierLabelPrefix+t] = "true"
+	}
+	return &k8sapi.VirtualMachineSnapshot{
+		Metadata: k8sapi.ObjectMeta{Name: name, Namespace: ns, Labels: labels},
+		Spec:     k8sapi.VirtualMachineSnapshotSpec{Source: k8sapi.NewVMRef(vm)},
+	}
+}
+
+// waitForVMSnapshot polls until the snapshot is ready to use, or
+// fails as soon as KubeVirt marks it Failed.
+func waitForVMSnapshot(ctx context.Context, cli *k8sapi.Client, ns, name string, timeout time.Duration) (*k8sapi.VirtualMachineSnapshot, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var last *k8sapi.VirtualMachineSnapshot
+	var lastErr error
+	for {
+		reqCtx, reqCancel := context.WithTimeout(ctx, 30*time.Second)
+		snap, err := cli.GetVMSnapshot(reqCtx, ns, name)
+		reqCancel()
+		switch {
+		case err == nil:
+			last, lastErr = snap, nil
+			if snap.Status.ReadyToUse {
+				return snap, nil
+			}
+			if snap.Status.Phase == "Failed" {
+				msg := "no reason given"
+				if snap.Status.Error != nil && snap.Status.Error.Message != "" {
+					msg = snap.Status.Error.Message
+				}
+				return nil, fmt.Errorf("snapshot %s failed: %s", name, msg)
+			}
+		case k8sapi.IsNotFound(err):
+			return nil, fmt.Errorf("snapshot %s not found", name)
+		default:
+			lastErr = err
+		}
+		select {
+		case <-ctx.Done():
+			msg := fmt.Sprintf("timed out after %s waiting for snapshot %s", timeout, name)
+			if last != nil {
+				msg += fmt.Sprintf("; phase %s", fmtCoalesce(last.Status.Phase, "unknown"))
+				if pending := notTrueConditions(last.Status.Conditions); pending != "" {
+					msg += "; pending: " + pending
+				}
+			}
+			if lastErr != nil {
+				msg += fmt.Sprintf(" (last error: %v)", lastErr)
+			}
+			return nil, fmt.Errorf("%s", msg)
+		case <-time.After(snapshotPollInterval):
+		}
+	}
+}
+
+// -------- list -----------------------------------------------------
+
+func vmSnapshotListCmd() *cobra.Command {
+	var namespace, outFlag string
+	cmd := &cobra.Command{
+		Use:   "list [vm]",
+		Short: "List snapshots, optionally of one VM",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			list, err := cli.ListVMSnapshots(ctx, scope.Namespace)
+			if err != nil {
+				return err
+			}
+			items := list.Items
+			if len(args) == 1 {
+				items = snapshotsOf(items, args[0])
+			}
+			if out != outTable {
+				return printSerialized(out, items)
+			}
+			if len(items) == 0 {
+				fmt.Println("No VM snapshots in", scope.Namespace)
+				return nil
+			}
+			printSnapshotTable(items)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+// snapshotsOf keeps the snapshots of vm.
+func snapshotsOf(items []k8sapi.VirtualMachineSnapshot, vm string) []k8sapi.VirtualMachineSnapshot {
+	var out []k8sapi.VirtualMachineSnapshot
+	for _, s := range items {
+		if s.Spec.Source.Name == vm {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// snapshotTiersOf lists the policy tiers holding s.
+func snapshotTiersOf(s *k8sapi.VirtualMachineSnapshot) []string {
+	var tiers []string
+	for _, t := range snapshotTiers {
+		if s.Metadata.Labels[snapshotTierLabelPrefix+t.Name] == "true" {
+			tiers = append(tiers, t.Name)
+		}
+	}
+	return tiers
+}
+
+func printSnapshotTable(items []k8sapi.VirtualMachineSnapshot) {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Spec.Source.Name != items[j].Spec.Source.Name {
+			return items[i].Spec.Source.Name < items[j].Spec.Source.Name
+		}
+		return items[i].Metadata.CreationTimestamp > items[j].Metadata.CreationTimestamp
+	})
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVM\tPHASE\tREADY\tINDICATIONS\tPOLICY\tAGE")
+	for i := range items {
+		s := &items[i]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%s\t%s\t%s\n",
+			s.Metadata.Name,
+			s.Spec.Source.Name,
+			fmtCoalesce(s.Status.Phase, "-"),
+			s.Status.ReadyToUse,
+			fmtCoalesce(strings.Join(s.Status.Indications, ","), "-"),
+			fmtCoalesce(strings.Join(snapshotTiersOf(s), ","), "-"),
+			formatAge(s.Metadata.CreationTimestamp),
+		)
+	}
+	_ = w.Flush()
+}
+
+// -------- restore --------------------------------------------------
+
+func vmSnapshotRestoreCmd() *cobra.Command {
+	var namespace, target string
+	var yes, stop, noWait bool
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "restore <snapshot>",
+		Short: "Restore a VM from a snapshot",
+		Long: `Restore a VM's disks and spec from a snapshot. The VM must be stopped; pass
+--stop to stop it first and start it again once the restore completes. Data
+written after the snapshot is lost.
+
+--target restores into another, new VM instead of the snapshot's source.`,
+		Example: `  kube-dc vm snapshot restore web-1-20261016-120000 --stop --yes`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			snapName := args[0]
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			snap, err := cli.GetVMSnapshot(ctx, scope.Namespace, snapName)
+			if err != nil {
+				return err
+			}
+			if !snap.Status.ReadyToUse {
+				return fmt.Errorf("snapshot %s is not ready to use (phase %s)", snapName, fmtCoalesce(snap.Status.Phase, "unknown"))
+			}
+			if target == "" {
+				target = snap.Spec.Source.Name
+			}
+			if !yes {
+				fmt.Fprintf(os.Stderr, "Restore VM %s from snapshot %s? Data written since %s is lost. Re-run with --yes to confirm.\n",
+					target, snapName, fmtCoalesce(snap.Status.CreationTime, snap.Metadata.CreationTimestamp))
+				return fmt.Errorf("not confirmed")
+			}
+
+			restart := false
+			vm, err := cli.GetVirtualMachine(ctx, scope.Namespace, target)
+			switch {
+			case k8sapi.IsNotFound(err):
+				// A new VM is created from the snapshot.
+			case err != nil:
+				return err
+			case vm.Status.PrintableStatus != string(vmWantStopped):
+				if !stop {
+					return fmt.Errorf("VM %s is %s; stop it first or pass --stop", target, fmtCoalesce(vm.Status.PrintableStatus, "not stopped"))
+				}
+				if err := cli.VirtualMachineAction(ctx, scope.Namespace, target, "stop"); err != nil {
+					return fmt.Errorf("stop VM %s: %w", target, err)
+				}
+				fmt.Printf("Stopping VM %s...\n", target)
+				if _, err := waitForVM(context.Background(), cli, scope.Namespace, target, vmWantStopped, timeout); err != nil {
+					return err
+				}
+				restart = true
+			}
+
+			restore := &k8sapi.VirtualMachineRestore{
+				Metadata: k8sapi.ObjectMeta{
+					Name:      target + "-restore-" + time.Now().UTC().Format("20060102-150405"),
+					Namespace: scope.Namespace,
+					Labels:    map[string]string{vmLabel: target},
+				},
+				Spec: k8sapi.VirtualMachineRestoreSpec{Target: k8sapi.NewVMRef(target), VirtualMachineSnapshotName: snapName},
+			}
+			if _, err := cli.CreateVMRestore(ctx, restore); err != nil {
+				return fmt.Errorf("create restore: %w", err)
+			}
+			if noWait {
+				fmt.Printf("Requested restore %s of VM %s from %s\n", restore.Metadata.Name, target, snapName)
+				return nil
+			}
+			fmt.Printf("Restoring VM %s from %s...\n", target, snapName)
+			if err := waitForVMRestore(context.Background(), cli, scope.Namespace, restore.Metadata.Name, timeout); err != nil {
+				return err
+			}
+			fmt.Printf("Restored VM %s from %s\n", target, snapName)
+			if !restart {
+				fmt.Printf("Start it with `kube-dc vm start %s`\n", target)
+				return nil
+			}
+			sctx, scancel := ctxWithTimeout()
+			defer scancel()
+			if err := cli.VirtualMachineAction(sctx, scope.Namespace, target, "start"); err != nil {
+				return fmt.Errorf("start VM %s after the restore: %w", target, err)
+			}
+			fmt.Printf("Started VM %s\n", target)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVar(&target, "target", "", "VM to restore into (default: the snapshot's source VM)")
+	cmd.Flags().BoolVar(&stop, "stop", false, "Stop a running target VM first and start it again afterwards")
+	cmd.Flags().BoolVar(&yes, "yes", false, "Confirm the restore")
+	cmd.Flags().BoolVar(&noWait, "no-wait", false, "Return once the restore is requested")
+	cmd.Flags().DurationVar(&timeout, "timeout", 15*time.Minute, "How long to wait for the VM to stop and the restore to complete")
+	return cmd
+}
+
+// waitForVMRestore polls until the restore reports complete.
+func waitForVMRestore(ctx context.Context, cli *k8sapi.Client, ns, name string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var last *k8sapi.VirtualMachineRestore
+	var lastErr error
+	for {
+		reqCtx, reqCancel := context.WithTimeout(ctx, 30*time.Second)
+		r, err := cli.GetVMRestore(reqCtx, ns, name)
+		reqCancel()
+		switch {
+		case err == nil:
+			last, lastErr = r, nil
+			if r.Status.Complete != nil && *r.Status.Complete {
+				return nil
+			}
+		case k8sapi.IsNotFound(err):
+			return fmt.Errorf("restore %s not found", name)
+		default:
+			lastErr = err
+		}
+		select {
+		case <-ctx.Done():
+			msg := fmt.Sprintf("timed out after %s waiting for restore %s", timeout, name)
+			if last != nil {
+				if pending := notTrueConditions(last.Status.Conditions); pending != "" {
+					msg += "; pending: " + pending
+				}
+			}
+			if lastErr != nil {
+				msg += fmt.Sprintf(" (last error: %v)", lastErr)
+			}
+			return fmt.Errorf("%s", msg)
+		case <-time.After(snapshotPollInterval):
+		}
+	}
+}
+
+// -------- delete ---------------------------------------------------
+
+func vmSnapshotDeleteCmd() *cobra.Command {
+	var namespace string
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "delete <snapshot>...",
+		Short: "Delete snapshots",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !yes {
+				fmt.Fprintf(os.Stderr, "Delete snapshot(s) %s? Re-run with --yes to confirm.\n", strings.Join(args, ", "))
+				return fmt.Errorf("not confirmed")
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			for _, name := range args {
+				if err := cli.DeleteVMSnapshot(ctx, scope.Namespace, name); err != nil {
+					return fmt.Errorf("delete snapshot %s: %w", name, err)
+				}
+				fmt.Printf("Deleted snapshot %s\n", name)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().BoolVar(&yes, "yes", false, "Confirm the deletion")
+	return cmd
+}
+
+// -------- policy ---------------------------------------------------
+
+// snapshotPolicy is a VM's retention: how many snapshots of each tier
+// to keep. Schedule is the cron expression `policy run` needs to be
+// called on — that of the finest tier in use.
+type snapshotPolicy struct {
+	VM       string `json:"vm" yaml:"vm"`
+	Hourly   int    `json:"hourly" yaml:"hourly"`
+	Daily    int    `json:"daily" yaml:"daily"`
+	Weekly   int    `json:"weekly" yaml:"weekly"`
+	Schedule string `json:"schedule" yaml:"schedule"`
+}
+
+func (p snapshotPolicy) keep(tier string) int {
+	switch tier {
+	case "hourly":
+		return p.Hourly
+	case "daily":
+		return p.Daily
+	case "weekly":
+		return p.Weekly
+	}
+	return 0
+}
+
+func snapshotPolicyName(vm string) string { return vm + "-snapshot-policy" }
+
+// newSnapshotPolicy validates the counts and fills in the schedule.
+func newSnapshotPolicy(vm string, hourly, daily, weekly int) (snapshotPolicy, error) {
+	p := snapshotPolicy{VM: vm, Hourly: hourly, Daily: daily, Weekly: weekly}
+	for _, t := range snapshotTiers {
+		n := p.keep(t.Name)
+		if n < 0 || n > maxSnapshotsPerTier {
+			return p, fmt.Errorf("--%s must be between 0 and %d", t.Name, maxSnapshotsPerTier)
+		}
+		if n > 0 && p.Schedule == "" {
+			p.Schedule = t.Schedule
+		}
+	}
+	if p.Schedule == "" {
+		return p, fmt.Errorf("keep at least one snapshot: set --hourly, --daily or --weekly")
+	}
+	return p, nil
+}
+
+// policyToConfigMap / policyFromConfigMap are the stored form: a
+// ConfigMap labelled for the platform to find.
+func policyToConfigMap(ns string, p snapshotPolicy) *k8sapi.ConfigMap {
+	return &k8sapi.ConfigMap{
+		Metadata: k8sapi.ObjectMeta{
+			Name:      snapshotPolicyName(p.VM),
+			Namespace: ns,
+			Labels:    map[string]string{snapshotPolicyLabel: "true", vmLabel: p.VM},
+		},
+		Data: map[string]string{
+			"vm":       p.VM,
+			"hourly":   strconv.Itoa(p.Hourly),
+			"daily":    strconv.Itoa(p.Daily),
+			"weekly":   strconv.Itoa(p.Weekly),
+			"schedule": p.Schedule,
+		},
+	}
+}
+
+func policyFromConfigMap(cm *k8sapi.ConfigMap) (snapshotPolicy, error) {
+	counts := map[string]int{}
+	for _, t := range snapshotTiers {
+		v := fmtCoalesce(cm.Data[t.Name], "0")
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return snapshotPolicy{}, fmt.Errorf("policy %s: %s = %q is not a number", cm.Metadata.Name, t.Name, v)
+		}
+		counts[t.Name] = n
+	}
+	return newSnapshotPolicy(fmtCoalesce(cm.Data["vm"], cm.Metadata.Labels[vmLabel]), counts["hourly"], counts["daily"], counts["weekly"])
+}
+
+// snapshotPlan is what one `policy run` does for a VM.
+type snapshotPlan struct {
+	Take  []string // tiers the new snapshot serves; none means no snapshot
+	Prune []string // snapshots no tier keeps any more
+}
+
+// planSnapshotPolicy decides, at now, which tiers are due and which
+// policy snapshots to prune. snaps are the VM's snapshots. A tier is
+// due when its newest snapshot that has not failed is older than its
+// period (less a small slack). Retention keeps the newest N ready
+// snapshots per tier and prunes the policy snapshots no tier keeps.
+// Only ready snapshots count: one still in progress — like the one
+// about to be taken — is neither counted nor pruned, so a new snapshot
+// that never becomes ready cannot displace the ones that are. Failed
+// snapshots are always pruned.
+func planSnapshotPolicy(p snapshotPolicy, snaps []k8sapi.VirtualMachineSnapshot, now time.Time) snapshotPlan {
+	type entry struct {
+		name   string
+		at     time.Time
+		tiers  []string
+		ready  bool
+		failed bool
+	}
+	var held []entry
+	for i := range snaps {
+		s := &snaps[i]
+		tiers := snapshotTiersOf(s)
+		if len(tiers) == 0 {
+			continue // taken by hand; not the policy's to prune
+		}
+		at, _ := time.Parse(time.RFC3339, s.Metadata.CreationTimestamp)
+		held = append(held, entry{s.Metadata.Name, at, tiers, s.Status.ReadyToUse, s.Status.Phase == "Failed"})
+	}
+	sort.Slice(held, func(i, j int) bool { return held[i].at.After(held[j].at) })
+
+	var plan snapshotPlan
+	for _, t := range snapshotTiers {
+		if p.keep(t.Name) == 0 {
+			continue
+		}
+		var newest time.Time
+		for _, e := range held {
+			if !e.failed && containsString(e.tiers, t.Name) {
+				newest = e.at
+				break
+			}
+		}
+		if newest.IsZero() || now.Sub(newest) >= t.Every-snapshotDueSlack {
+			plan.Take = append(plan.Take, t.Name)
+		}
+	}
+
+	kept := map[string]bool{}
+	for _, t := range snapshotTiers {
+		left := p.keep(t.Name)
+		for _, e := range held {
+			if left <= 0 {
+				break
+			}
+			if e.ready && containsString(e.tiers, t.Name) {
+				kept[e.name] = true
+				left--
+			}
+		}
+	}
+	for _, e := range held {
+		if !kept[e.name] && (e.ready || e.failed) {
+			plan.Prune = append(plan.Prune, e.name)
+		}
+	}
+	return plan
+}
+
+func vmSnapshotPolicyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "policy",
+		Short: "Manage a VM's snapshot retention policy",
+		Long: `Keep N hourly, daily and weekly snapshots of a VM.
+
+The policy is stored in the Project as the ConfigMap <vm>-snapshot-policy
+(label kube-dc.com/snapshot-policy=true). 'policy run' enforces it: it takes
+a snapshot when a tier is due and deletes policy snapshots that no tier keeps.
+Run it on the schedule 'policy list' shows, from cron or a CI pipeline.
+Snapshots taken by hand are never pruned.`,
+	}
+	cmd.AddCommand(vmSnapshotPolicySetCmd())
+	cmd.AddCommand(vmSnapshotPolicyListCmd())
+	cmd.AddCommand(vmSnapshotPolicyShowCmd())
+	cmd.AddCommand(vmSnapshotPolicyDeleteCmd())
+	cmd.AddCommand(vmSnapshotPolicyRunCmd())
+	return cmd
+}
+
+func vmSnapshotPolicySetCmd() *cobra.Command {
+	var namespace string
+	var hourly, daily, weekly int
+	cmd := &cobra.Command{
+		Use:     "set <vm>",
+		Short:   "Create or update a VM's snapshot policy",
+		Example: `  kube-dc vm snapshot policy set web-1 --hourly 24 --daily 7 --weekly 4`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			vmName := args[0]
+			p, err := newSnapshotPolicy(vmName, hourly, daily, weekly)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			vm, err := cli.GetVirtualMachine(ctx, scope.Namespace, vmName)
+			if err != nil {
+				return err
+			}
+			if err := ensureSnapshotCapable(ctx, scope, cli, vm); err != nil {
+				return err
+			}
+			cm := policyToConfigMap(scope.Namespace, p)
+			existing, err := cli.GetConfigMap(ctx, scope.Namespace, cm.Metadata.Name)
+			switch {
+			case k8sapi.IsNotFound(err):
+				if _, err := cli.CreateConfigMap(ctx, cm); err != nil {
+					return fmt.Errorf("create policy: %w", err)
+				}
+				fmt.Printf("Created snapshot policy for VM %s\n", vmName)
+			case err != nil:
+				return err
+			default:
+				cm.Metadata.ResourceVersion = existing.Metadata.ResourceVersion
+				if _, err := cli.UpdateConfigMap(ctx, cm); err != nil {
+					return fmt.Errorf("update policy: %w", err)
+				}
+				fmt.Printf("Updated snapshot policy for VM %s\n", vmName)
+			}
+			fmt.Printf("Keeping %d hourly, %d daily, %d weekly; run `kube-dc vm snapshot policy run` on %q\n", p.Hourly, p.Daily, p.Weekly, p.Schedule)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().IntVar(&hourly, "hourly", 0, "Hourly snapshots to keep")
+	cmd.Flags().IntVar(&daily, "daily", 0, "Daily snapshots to keep")
+	cmd.Flags().IntVar(&weekly, "weekly", 0, "Weekly snapshots to keep")
+	return cmd
+}
+
+// loadSnapshotPolicies reads the Project's policies, or only vm's.
+func loadSnapshotPolicies(ctx context.Context, cli *k8sapi.Client, ns, vm string) ([]snapshotPolicy, error) {
+	if vm != "" {
+		cm, err := cli.GetConfigMap(ctx, ns, snapshotPolicyName(vm))
+		if k8sapi.IsNotFound(err) {
+			return nil, fmt.Errorf("VM %s has no snapshot policy", vm)
+		}
+		if err != nil {
+			return nil, err
+		}
+		p, err := policyFromConfigMap(cm)
+		if err != nil {
+			return nil, err
+		}
+		return []snapshotPolicy{p}, nil
+	}
+	list, err := cli.ListConfigMaps(ctx, ns, snapshotPolicyLabel+"=true")
+	if err != nil {
+		return nil, err
+	}
+	var out []snapshotPolicy
+	for i := range list.Items {
+		p, err := policyFromConfigMap(&list.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].VM < out[j].VM })
+	return out, nil
+}
+
+func vmSnapshotPolicyListCmd() *cobra.Command {
+	var namespace, outFlag string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List snapshot policies in the current Project",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			policies, err := loadSnapshotPolicies(ctx, cli, scope.Namespace, "")
+			if err != nil {
+				return err
+			}
+			if out != outTable {
+				return printSerialized(out, policies)
+			}
+			if len(policies) == 0 {
+				fmt.Println("No snapshot policies in", scope.Namespace)
+				return nil
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "VM\tHOURLY\tDAILY\tWEEKLY\tSCHEDULE")
+			for _, p := range policies {
+				fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", p.VM, p.Hourly, p.Daily, p.Weekly, p.Schedule)
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+// snapshotPolicyDetail is what `policy show -o json|yaml` prints.
+type snapshotPolicyDetail struct {
+	Policy    snapshotPolicy                  `json:"policy" yaml:"policy"`
+	Snapshots []k8sapi.VirtualMachineSnapshot `json:"snapshots" yaml:"snapshots"`
+	Due       []string                        `json:"due,omitempty" yaml:"due,omitempty"`
+}
+
+func vmSnapshotPolicyShowCmd() *cobra.Command {
+	var namespace, outFlag string
+	cmd := &cobra.Command{
+		Use:   "show <vm>",
+		Short: "Show a VM's snapshot policy and the snapshots it holds",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			policies, err := loadSnapshotPolicies(ctx, cli, scope.Namespace, args[0])
+			if err != nil {
+				return err
+			}
+			list, err := cli.ListVMSnapshots(ctx, scope.Namespace)
+			if err != nil {
+				return err
+			}
+			d := snapshotPolicyDetail{Policy: policies[0]}
+			for _, s := range snapshotsOf(list.Items, args[0]) {
+				if len(snapshotTiersOf(&s)) > 0 {
+					d.Snapshots = append(d.Snapshots, s)
+				}
+			}
+			d.Due = planSnapshotPolicy(d.Policy, d.Snapshots, time.Now()).Take
+			if out != outTable {
+				return printSerialized(out, d)
+			}
+			fmt.Printf("VM:        %s\n", d.Policy.VM)
+			fmt.Printf("Keep:      %d hourly, %d daily, %d weekly\n", d.Policy.Hourly, d.Policy.Daily, d.Policy.Weekly)
+			fmt.Printf("Schedule:  %s\n", d.Policy.Schedule)
+			fmt.Printf("Due now:   %s\n", fmtCoalesce(strings.Join(d.Due, ", "), "nothing"))
+			if len(d.Snapshots) == 0 {
+				fmt.Println("\nNo policy snapshots yet.")
+				return nil
+			}
+			fmt.Println()
+			printSnapshotTable(d.Snapshots)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+func vmSnapshotPolicyDeleteCmd() *cobra.Command {
+	var namespace string
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "delete <vm>",
+		Short: "Delete a VM's snapshot policy, keeping its snapshots",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !yes {
+				fmt.Fprintf(os.Stderr, "Delete the snapshot policy of VM %s? Its snapshots are kept. Re-run with --yes to confirm.\n", args[0])
+				return fmt.Errorf("not confirmed")
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			if err := cli.DeleteConfigMap(ctx, scope.Namespace, snapshotPolicyName(args[0])); err != nil {
+				if k8sapi.IsNotFound(err) {
+					return fmt.Errorf("VM %s has no snapshot policy", args[0])
+				}
+				return err
+			}
+			fmt.Printf("Deleted the snapshot policy of VM %s; its snapshots are kept\n", args[0])
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().BoolVar(&yes, "yes", false, "Confirm the deletion")
+	return cmd
+}
+
+func vmSnapshotPolicyRunCmd() *cobra.Command {
+	var namespace string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "run [vm]",
+		Short: "Take due policy snapshots and prune expired ones",
+		Long: `Apply the snapshot policies of the Project, or of one VM: take a snapshot
+when any tier is due and delete policy snapshots no tier keeps. It does not
+wait for the new snapshots. Retention counts only snapshots that are ready, so
+a new one replaces an older one on the first run after it became ready, and a
+snapshot that failed is deleted without costing a ready one its place.`,
+		Example: `  # crontab: every hour
+  0 * * * * kube-dc vm snapshot policy run -n acme-web`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var vmName string
+			if len(args) == 1 {
+				vmName = args[0]
+			}
+			cmd.SilenceUsage = true
+			scope, err := resolveScope(namespace)
+			if err != nil {
+				return err
+			}
+			cli, err := scope.k8s()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := ctxWithTimeout()
+			defer cancel()
+			policies, err := loadSnapshotPolicies(ctx, cli, scope.Namespace, vmName)
+			if err != nil {
+				return err
+			}
+			if len(policies) == 0 {
+				fmt.Println("No snapshot policies in", scope.Namespace)
+				return nil
+			}
+			list, err := cli.ListVMSnapshots(ctx, scope.Namespace)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			var failed []string
+			for _, p := range policies {
+				if err := runSnapshotPolicy(ctx, scope, cli, p, snapshotsOf(list.Items, p.VM), now, dryRun); err != nil {
+					fmt.Fprintf(os.Stderr, "VM %s: %v\n", p.VM, err)
+					failed = append(failed, p.VM)
+				}
+			}
+			if len(failed) > 0 {
+				return fmt.Errorf("snapshot policy failed for %s", strings.Join(failed, ", "))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Project backing namespace (default: current context's namespace)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print what would be taken and pruned without doing it")
+	return cmd
+}
+
+func runSnapshotPolicy(ctx context.Context, scope *secretsScope, cli *k8sapi.Client, p snapshotPolicy, snaps []k8sapi.VirtualMachineSnapshot, now time.Time, dryRun bool) error {
+	plan := planSnapshotPolicy(p, snaps, now)
+	took, pruned := "Took", "Pruned"
+	if dryRun {
+		took, pruned = "Would take", "Would prune"
+	}
+	if len(plan.Take) > 0 {
+		name := snapshotName(p.VM, now)
+		if !dryRun {
+			vm, err := cli.GetVirtualMachine(ctx, scope.Namespace, p.VM)
+			if err != nil {
+				return err
+			}
+			if err := ensureSnapshotCapable(ctx, scope, cli, vm); err != nil {
+				return err
+			}
+			if _, err := cli.CreateVMSnapshot(ctx, newVMSnapshot(scope.Namespace, name, p.VM, plan.Take)); err != nil {
+				return fmt.Errorf("create snapshot %s: %w", name, err)
+			}
+		}
+		fmt.Printf("%s snapshot %s of VM %s (%s)\n", took, name, p.VM, strings.Join(plan.Take, ", "))
+	}
+	for _, name := range plan.Prune {
+		if !dryRun {
+			if err := cli.DeleteVMSnapshot(ctx, scope.Namespace, name); err != nil && !k8sapi.IsNotFound(err) {
+				return fmt.Errorf("delete snapshot %s: %w", name, err)
+			}
+		}
+		fmt.Printf("%s snapshot %s of VM %s\n", pruned, name, p.VM)
+	}
+	if len(plan.Take) == 0 && len(plan.Prune) == 0 {
+		fmt.Printf("VM %s: nothing due\n", p.VM)
+	}
+	return nil
+}
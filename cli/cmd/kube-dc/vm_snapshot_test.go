@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/backend"
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+)
+
+func TestCheckSnapshotCapable(t *testing.T) {
+	classes := []backend.VMStorageClass{
+		{Name: "local-path", IsDefault: true},
+		{Name: "rbd-vm", Snapshot: true},
+	}
+	if err := checkSnapshotCapable("web-1", map[string]string{"web-1-disk": "rbd-vm"}, classes); err != nil {
+		t.Errorf("rbd-vm: %v", err)
+	}
+	err := checkSnapshotCapable("web-1", map[string]string{"web-1-disk": "rbd-vm", "web-1-data": "local-path", "scratch": "nfs"}, classes)
+	if err == nil || !strings.Contains(err.Error(), "scratch (nfs), web-1-data (local-path); snapshot-capable classes: rbd-vm") {
+		t.Errorf("mixed: %v", err)
+	}
+	err = checkSnapshotCapable("web-1", map[string]string{"web-1-disk": "local-path"}, classes[:1])
+	if err == nil || !strings.Contains(err.Error(), "no VM StorageClass in this installation supports snapshots") {
+		t.Errorf("none capable: %v", err)
+	}
+	if err := checkSnapshotCapable("web-1", nil, classes); err == nil {
+		t.Error("a VM without disks has nothing to snapshot")
+	}
+}
+
+func TestSnapshotPolicyConfigMap(t *testing.T) {
+	if _, err := newSnapshotPolicy("web-1", 0, 0, 0); err == nil {
+		t.Error("an empty policy must be rejected")
+	}
+	if _, err := newSnapshotPolicy("web-1", -1, 7, 0); err == nil {
+		t.Error("a negative count must be rejected")
+	}
+	p, err := newSnapshotPolicy("web-1", 0, 7, 4)
+	if err != nil || p.Schedule != "0 0 * * *" {
+		t.Fatalf("policy = %+v, %v", p, err)
+	}
+	cm := policyToConfigMap("acme-web", p)
+	if cm.Metadata.Name != "web-1-snapshot-policy" || cm.Metadata.Labels[snapshotPolicyLabel] != "true" {
+		t.Errorf("ConfigMap = %+v", cm.Metadata)
+	}
+	back, err := policyFromConfigMap(cm)
+	if err != nil || back != p {
+		t.Errorf("round trip = %+v, %v", back, err)
+	}
+	cm.Data["daily"] = "seven"
+	if _, err := policyFromConfigMap(cm); err == nil {
+		t.Error("expected a parse error")
+	}
+}
+
+func policySnap(name, phase string, at time.Time, tiers ...string) k8sapi.VirtualMachineSnapshot {
+	s := *newVMSnapshot("acme-web", name, "web-1", tiers)
+	s.Metadata.CreationTimestamp = at.UTC().Format(time.RFC3339)
+	s.Status.Phase = phase
+	s.Status.ReadyToUse = phase == "Succeeded"
+	return s
+}
+
+func TestPlanSnapshotPolicy(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 30, 0, time.UTC)
+	p := snapshotPolicy{VM: "web-1", Hourly: 2, Daily: 2}
+
+	// Nothing yet: both tiers are due, nothing to prune.
+	if plan := planSnapshotPolicy(p, nil, now); !reflect.DeepEqual(plan.Take, []string{"hourly", "daily"}) || len(plan.Prune) != 0 {
+		t.Errorf("empty = %+v", plan)
+	}
+
+	snaps := []k8sapi.VirtualMachineSnapshot{
+		policySnap("h-1100", "Succeeded", now.Add(-58*time.Minute), "hourly"),
+		policySnap("h-1000", "Succeeded", now.Add(-2*time.Hour), "hourly"),
+		policySnap("h-0900", "Succeeded", now.Add(-3*time.Hour), "hourly"),
+		policySnap("d-yday", "Succeeded", now.Add(-12*time.Hour), "hourly", "daily"),
+		policySnap("d-old", "Succeeded", now.Add(-36*time.Hour), "daily"),
+		policySnap("d-older", "Succeeded", now.Add(-60*time.Hour), "daily"),
+		policySnap("broken", "Failed", now.Add(-30*time.Minute), "hourly"),
+		policySnap("by-hand", "Succeeded", now.Add(-100*time.Hour)),
+	}
+	plan := planSnapshotPolicy(p, snaps, now)
+	// The hourly is due within the slack; the daily is not.
+	if !reflect.DeepEqual(plan.Take, []string{"hourly"}) {
+		t.Errorf("take = %v", plan.Take)
+	}
+	// Hourly keeps h-1100 + h-1000 — the one about to be taken does not
+	// count until it is ready; daily keeps d-yday + d-old. Failed
+	// snapshots never count; hand-made ones are never pruned.
+	if want := []string{"broken", "h-0900", "d-older"}; !reflect.DeepEqual(plan.Prune, want) {
+		t.Errorf("prune = %v, want %v", plan.Prune, want)
+	}
+
+	// With --hourly 1, a new snapshot still in progress or failed leaves
+	// the last ready one in place; once it is ready, the old one goes.
+	one := snapshotPolicy{VM: "web-1", Hourly: 1}
+	ready := policySnap("h-1100", "Succeeded", now.Add(-58*time.Minute), "hourly")
+	for _, phase := range []string{"InProgress", "Failed"} {
+		next := policySnap("h-1200", phase, now, "hourly")
+		plan := planSnapshotPolicy(one, []k8sapi.VirtualMachineSnapshot{next, ready}, now)
+		if containsString(plan.Prune, "h-1100") {
+			t.Errorf("%s: pruned the only ready snapshot: %+v", phase, plan)
+		}
+		if phase == "InProgress" && (len(plan.Take) != 0 || len(plan.Prune) != 0) {
+			t.Errorf("in progress: %+v, want nothing to do", plan)
+		}
+		if phase == "Failed" && !reflect.DeepEqual(plan.Prune, []string{"h-1200"}) {
+			t.Errorf("failed: prune = %v, want the failed one", plan.Prune)
+		}
+	}
+	next := policySnap("h-1200", "Succeeded", now, "hourly")
+	if plan := planSnapshotPolicy(one, []k8sapi.VirtualMachineSnapshot{next, ready}, now); !reflect.DeepEqual(plan.Prune, []string{"h-1100"}) {
+		t.Errorf("ready: prune = %v, want h-1100", plan.Prune)
+	}
+
+	// Dropping a tier prunes what only it held.
+	plan = planSnapshotPolicy(snapshotPolicy{VM: "web-1", Daily: 1}, snaps, now)
+	if len(plan.Take) != 0 || !containsString(plan.Prune, "h-1100") || containsString(plan.Prune, "d-yday") || !containsString(plan.Prune, "d-old") {
+		t.Errorf("daily only = %+v", plan)
+	}
+}
+
+func TestWaitForVMSnapshot(t *testing.T) {
+	prev := snapshotPollInterval
+	snapshotPollInterval = 5 * time.Millisecond
+	t.Cleanup(func() { snapshotPollInterval = prev })
+
+	gets := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := "/apis/snapshot.kubevirt.io/v1beta1/namespaces/acme-web/virtualmachinesnapshots/"
+		name := strings.TrimPrefix(r.URL.Path, prefix)
+		s := newVMSnapshot("acme-web", name, "web-1", nil)
+		switch name {
+		case "good":
+			gets++
+			s.Status.Phase = "InProgress"
+			if gets >= 2 {
+				s.Status.Phase, s.Status.ReadyToUse = "Succeeded", true
+				s.Status.Indications = []string{"Online", "GuestAgent"}
+			}
+		case "bad":
+			s.Status.Phase = "Failed"
+			s.Status.Error = &struct {
+				Message string `json:"message,omitempty"`
+			}{Message: "VolumeSnapshotClass not found for local-path"}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(s)
+	}))
+	defer srv.Close()
+	cli, _ := k8sapi.New(srv.URL, "token", "", false)
+
+	snap, err := waitForVMSnapshot(context.Background(), cli, "acme-web", "good", time.Second)
+	if err != nil || !snap.Status.ReadyToUse || gets != 2 {
+		t.Errorf("good: %+v, %v, gets %d", snap, err, gets)
+	}
+	if _, err := waitForVMSnapshot(context.Background(), cli, "acme-web", "bad", time.Second); err == nil || !strings.Contains(err.Error(), "VolumeSnapshotClass not found") {
+		t.Errorf("bad: %v", err)
+	}
+	if _, err := waitForVMSnapshot(context.Background(), cli, "acme-web", "gone", time.Second); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("gone: %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/backend"
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+)
+
+var ubuntuImage = backend.OSImage{
+	Name: "Ubuntu 24.04 LTS", CloudUser: "ubuntu", FamilyID: "ubuntu-24.04",
+	ImageURL:  "https://s3.kube-dc.cloud/cdi-os-images/ubuntu/24.04/latest/noble.img",
+	LatestURL: "https://s3.kube-dc.cloud/cdi-os-images/ubuntu/24.04/20260321/noble.img",
+	MinMemory: "1G", MinVCPU: "1", MinStorage: "20G",
+	Versions: []backend.OSImageVersion{
+		{Tag: "20260321", ImageURL: "https://s3.kube-dc.cloud/cdi-os-images/ubuntu/24.04/20260321/noble.img", IsLatest: true},
+		{Tag: "20260225", ImageURL: "https://s3.kube-dc.cloud/cdi-os-images/ubuntu/24.04/20260225/noble.img"},
+	},
+}
+
+func TestSelectOSImageAndSource(t *testing.T) {
+	images := []backend.OSImage{ubuntuImage, {Name: "Debian 12", CloudUser: "debian"}}
+	img, err := selectOSImage(images, "Ubuntu-24.04")
+	if err != nil || img.CloudUser != "ubuntu" {
+		t.Fatalf("by family id: %+v, %v", img, err)
+	}
+	if img, err := selectOSImage(images, "debian 12"); err != nil || img.CloudUser != "debian" {
+		t.Errorf("by display name: %+v, %v", img, err)
+	}
+	if _, err := selectOSImage(images, "centos"); err == nil || !strings.Contains(err.Error(), "Debian 12, ubuntu-24.04") {
+		t.Errorf("unknown image: %v", err)
+	}
+
+	src, err := imageSource(img, "")
+	if err != nil || src.HTTP == nil || src.HTTP.URL != ubuntuImage.LatestURL {
+		t.Errorf("latest = %+v, %v", src, err)
+	}
+	if src, err := imageSource(img, "20260225"); err != nil || !strings.Contains(src.HTTP.URL, "/20260225/") {
+		t.Errorf("pinned version = %+v, %v", src, err)
+	}
+	if _, err := imageSource(img, "2019"); err == nil || !strings.Contains(err.Error(), "20260321, 20260225") {
+		t.Errorf("unknown version: %v", err)
+	}
+
+	withRegistry := ubuntuImage
+	withRegistry.RegistryURL = "docker://quay.io/containerdisks/ubuntu@sha256:abc"
+	if src, err := imageSource(&withRegistry, ""); err != nil || src.Registry == nil || src.Registry.PullMethod != "node" || src.HTTP != nil {
+		t.Errorf("registry = %+v, %v", src, err)
+	}
+	withRegistry.RegistryURL = "docker://quay.io/containerdisks/ubuntu:24.04"
+	if _, err := imageSource(&withRegistry, ""); err == nil {
+		t.Error("a mutable tag must be rejected")
+	}
+}
+
+func TestSelectStorage(t *testing.T) {
+	classes := []backend.VMStorageClass{
+		{Name: "local-path", IsDefault: true},
+		{Name: "rbd-vm", Modes: []backend.StorageModePair{
+			{AccessMode: "ReadWriteOnce", VolumeMode: "Filesystem"},
+			{AccessMode: "ReadWriteMany", VolumeMode: "Block"},
+		}},
+	}
+	cases := []struct {
+		class, access, volume string
+		want                  string // class/access/volume, or an error substring
+	}{
+		{"", "", "", "local-path/ReadWriteOnce/Filesystem"},
+		{"rbd-vm", "", "", "rbd-vm/ReadWriteOnce/Filesystem"},
+		{"rbd-vm", "ReadWriteMany", "", "rbd-vm/ReadWriteMany/Block"},
+		{"rbd-vm", "", "Block", "rbd-vm/ReadWriteMany/Block"},
+		{"rbd-vm", "ReadWriteMany", "Filesystem", "does not support ReadWriteMany/Filesystem"},
+		{"local-path", "", "Block", "does not support */Block"},
+		{"", "ReadWriteOncePod", "", "invalid --access-mode"},
+		{"ssd", "", "", `"ssd" is not offered`},
+	}
+	for _, c := range cases {
+		class, access, volume, err := selectStorage(classes, c.class, c.access, c.volume)
+		got := class + "/" + access + "/" + volume
+		if err != nil {
+			got = err.Error()
+		}
+		if !strings.Contains(got, c.want) {
+			t.Errorf("selectStorage(%q, %q, %q) = %s, want %s", c.class, c.access, c.volume, got, c.want)
+		}
+	}
+}
+
+func TestResolveVMSize(t *testing.T) {
+	size, err := resolveVMSize(&ubuntuImage, 0, "", "")
+	if err != nil || size != (vmSize{CPU: 1, Memory: "1G", Disk: "20G"}) {
+		t.Errorf("defaults = %+v, %v", size, err)
+	}
+	if size, err := resolveVMSize(&ubuntuImage, 4, "8Gi", "100Gi"); err != nil || size.Memory != "8Gi" {
+		t.Errorf("explicit = %+v, %v", size, err)
+	}
+	for _, bad := range []struct {
+		cpu          int
+		memory, disk string
+		want         string
+	}{
+		{0, "512Mi", "", "--memory 512Mi is below the ubuntu-24.04 minimum of 1G"},
+		{0, "", "10Gi", "--disk 10Gi is below"},
+		{0, "lots", "", "invalid --memory"},
+	} {
+		if _, err := resolveVMSize(&ubuntuImage, bad.cpu, bad.memory, bad.disk); err == nil || !strings.Contains(err.Error(), bad.want) {
+			t.Errorf("%+v: err = %v", bad, err)
+		}
+	}
+	twoCPU := ubuntuImage
+	twoCPU.MinVCPU = "2"
+	if _, err := resolveVMSize(&twoCPU, 1, "", ""); err == nil {
+		t.Error("expected a vCPU minimum error")
+	}
+}
+
+func TestBuildVM(t *testing.T) {
+	src, _ := imageSource(&ubuntuImage, "")
+	opts := vmOpts{Name: "web-1", Namespace: "acme-web"}
+	size := vmSize{CPU: 2, Memory: "4Gi", Disk: "40Gi"}
+	dv, vm, err := buildVM(opts, &ubuntuImage, src, size, "rbd-vm", "ReadWriteMany", "Block")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dv.Metadata.Name != "web-1-disk" || dv.Spec.PVC.VolumeMode != "Block" || dv.Spec.PVC.Resources.Requests["storage"] != "40Gi" {
+		t.Errorf("DataVolume = %+v", dv)
+	}
+	if got := vmDataVolumes(vm); len(got) != 1 || got[0] != "web-1-disk" {
+		t.Errorf("VM volumes = %v", got)
+	}
+
+	// Round-trip through JSON, as the API server would see it.
+	raw, _ := json.Marshal(vm)
+	var back k8sapi.VirtualMachine
+	if err := json.Unmarshal(raw, &back); err != nil {
+		t.Fatal(err)
+	}
+	if cpu, memory := vmShape(&back); cpu != "2" || memory != "4Gi" {
+		t.Errorf("shape = %s / %s", cpu, memory)
+	}
+	for _, want := range []string{
+		`"networkName":"acme-web/default"`,
+		`"users":["ubuntu"]`,
+		`"secretName":"authorized-keys-default"`,
+		`"running":true`,
+		`qemu-guest-agent`,
+	} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("manifest lacks %s:\n%s", want, raw)
+		}
+	}
+
+	// Filesystem is the API default and stays implicit.
+	dv, _, _ = buildVM(opts, &ubuntuImage, src, size, "local-path", "ReadWriteOnce", "Filesystem")
+	if dv.Spec.PVC.VolumeMode != "" {
+		t.Errorf("volumeMode = %q", dv.Spec.PVC.VolumeMode)
+	}
+
+	efi := ubuntuImage
+	efi.FirmwareType = "efi"
+	_, vm, _ = buildVM(opts, &efi, src, size, "local-path", "ReadWriteOnce", "Filesystem")
+	if raw, _ := json.Marshal(vm); !strings.Contains(string(raw), `"efi":{"secureBoot":false}`) {
+		t.Errorf("efi firmware missing: %s", raw)
+	}
+
+	windows := ubuntuImage
+	windows.AdditionalDisk = "virtio-drivers"
+	if _, _, err := buildVM(opts, &windows, src, size, "local-path", "ReadWriteOnce", "Filesystem"); err == nil {
+		t.Error("multi-disk images must go through the wizard")
+	}
+	if _, _, err := buildVM(vmOpts{Name: "Web_1"}, &ubuntuImage, src, size, "local-path", "ReadWriteOnce", "Filesystem"); err == nil {
+		t.Error("expected a name error")
+	}
+}
+
+// fakeVMAPI serves one VM whose printable status walks through
+// statuses, one per GET, staying on the last.
+type fakeVMAPI struct {
+	mu       sync.Mutex
+	gets     int
+	statuses []string
+}
+
+func (f *fakeVMAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/apis/kubevirt.io/v1/namespaces/acme-web/virtualmachines/web-1" {
+		http.NotFound(w, r)
+		return
+	}
+	f.mu.Lock()
+	status := f.statuses[min(f.gets, len(f.statuses)-1)]
+	f.gets++
+	f.mu.Unlock()
+	vm := k8sapi.VirtualMachine{Metadata: k8sapi.ObjectMeta{Name: "web-1"}}
+	vm.Status.PrintableStatus = status
+	vm.Status.Ready = status == "Running"
+	if status == "ErrorUnschedulable" {
+		vm.Status.Conditions = []k8sapi.Condition{{Type: "Ready", Status: "False", Reason: "Unschedulable", Message: "exceeded quota: cpu"}}
+	}
+	_ = json.NewEncoder(w).Encode(vm)
+}
+
+func TestWaitForVM(t *testing.T) {
+	prev := vmPollInterval
+	vmPollInterval = 5 * time.Millisecond
+	t.Cleanup(func() { vmPollInterval = prev })
+
+	api := &fakeVMAPI{statuses: []string{"Provisioning", "Starting", "Running"}}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	cli, _ := k8sapi.New(srv.URL, "token", "", false)
+
+	vm, err := waitForVM(context.Background(), cli, "acme-web", "web-1", vmWantRunning, time.Second)
+	if err != nil || !vm.Status.Ready || api.gets != 3 {
+		t.Fatalf("vm %+v err %v gets %d", vm, err, api.gets)
+	}
+
+	stuck := httptest.NewServer(&fakeVMAPI{statuses: []string{"ErrorUnschedulable"}})
+	defer stuck.Close()
+	cli, _ = k8sapi.New(stuck.URL, "token", "", false)
+	_, err = waitForVM(context.Background(), cli, "acme-web", "web-1", vmWantRunning, 30*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "status ErrorUnschedulable; pending: Ready=False (Unschedulable: exceeded quota: cpu)") {
+		t.Errorf("err = %v", err)
+	}
+	if _, err := waitForVM(context.Background(), cli, "acme-web", "db-1", vmWantStopped, time.Second); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("missing VM: %v", err)
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+// `kube-dc whoami` answers "who am I to this cluster, and what may I do
+// in this Project" — the first two questions of every "why can't I do
+// X" ticket. Identity comes from the cached access token of the
+// current context's realm; permissions come from the kube-apiserver
+// itself (SelfSubjectRulesReview / SelfSubjectAccessReview), so the
+// answer is what RBAC actually enforces, not what the token suggests.
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/jwt"
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+	"github.com/spf13/cobra"
+)
+
+// securityGroup is the API group of the Kube-DC resources whoami reports on.
+const securityGroup = "security.kube-dc.com"
+
+// whoamiResources are the Kube-DC resources whose verbs whoami reports, in
+// display order.
+var whoamiResources = []struct {
+	Kind     string
+	Resource string
+}{
+	{"ManagedSecret", "managedsecrets"},
+	{"KMSKey", "kmskeys"},
+	{"ManagedCertificate", "managedcertificates"},
+	{"DatabaseCredentialPolicy", "databasecredentialpolicies"},
+}
+
+var whoamiVerbs = []string{"get", "list", "watch", "create", "update", "patch", "delete"}
+
+// projectRoleSignatures tell the four standard Project roles apart by the
+// one grant each adds on the security resources, per the Role templates in
+// charts/kube-dc/templates/default-project-admin-role.yaml:
+//
+//	user              read-only
+//	developer         create managedsecrets (KMS keys stay read-only)
+//	project-manager   create kmskeys (but no managedsecrets create)
+//	admin             delete kmskeys — only admin holds "*" there
+//
+// If those templates change, this table has to move with them.
+var projectRoleSignatures = []struct {
+	Role     string
+	Verb     string
+	Resource string
+}{
+	{"admin", "delete", "kmskeys"},
+	{"developer", "create", "managedsecrets"},
+	{"project-manager", "create", "kmskeys"},
+	{"user", "list", "managedsecrets"},
+}
+
+type whoamiReport struct {
+	Context  string    `json:"context" yaml:"context"`
+	Realm    string    `json:"realm" yaml:"realm"`
+	Subject  string    `json:"subject" yaml:"subject"`
+	Email    string    `json:"email,omitempty" yaml:"email,omitempty"`
+	Username string    `json:"username,omitempty" yaml:"username,omitempty"`
+	Groups   []string  `json:"groups" yaml:"groups"`
+	Audience []string  `json:"audience" yaml:"audience"`
+	Expires  time.Time `json:"expires" yaml:"expires"`
+
+	Project *projectPermissions `json:"project,omitempty" yaml:"project,omitempty"`
+}
+
+type projectPermissions struct {
+	Name      string                `json:"name" yaml:"name"`
+	Namespace string                `json:"namespace" yaml:"namespace"`
+	Roles     []string              `json:"roles" yaml:"roles"`
+	Resources []resourcePermissions `json:"resources" yaml:"resources"`
+
+	// Incomplete means the rules review could not enumerate every rule
+	// (some authorizer does not support it); the verbs were then
+	// confirmed one by one with access reviews instead.
+	Incomplete bool `json:"incomplete,omitempty" yaml:"incomplete,omitempty"`
+}
+
+type resourcePermissions struct {
+	Kind     string   `json:"kind" yaml:"kind"`
+	Resource string   `json:"resource" yaml:"resource"`
+	Verbs    []string `json:"verbs" yaml:"verbs"`
+}
+
+func whoamiCmd() *cobra.Command {
+	var project, outFlag string
+	cmd := &cobra.Command{
+		Use:   "whoami",
+		Short: "Show the current identity and its Project permissions",
+		Long: `Show who the current kube-dc context authenticates as — subject, email,
+groups, audience and token expiry — and, for a Project, which of the four
+standard Project roles (user, developer, project-manager, admin) the identity
+holds and which verbs it has on ManagedSecret, KMSKey, ManagedCertificate and
+DatabaseCredentialPolicy.
+
+Permissions are asked of the kube-apiserver, so they reflect what RBAC
+actually enforces. The Project defaults to the current context's.`,
+		Example: `  kube-dc whoami
+  kube-dc whoami --project web
+  kube-dc whoami -o json | jq .project.roles`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := parseOutput(outFlag)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			return runWhoami(project, out)
+		},
+	}
+	cmd.Flags().StringVarP(&project, "project", "p", "", "Project to report permissions for (default: current context's Project)")
+	cmd.Flags().StringVarP(&outFlag, "output", "o", "table", "Output format: table|json|yaml")
+	return cmd
+}
+
+func runWhoami(project string, out outputFormat) error {
+	kc, err := resolveKubeDCContext()
+	if err != nil {
+		return err
+	}
+	claims, err := jwt.ParseToken(kc.Creds.AccessToken)
+	if err != nil {
+		return fmt.Errorf("failed to parse token: %w", err)
+	}
+	report := &whoamiReport{
+		Context:  kc.Name,
+		Realm:    kc.Realm,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+		Username: claims.PreferredUsername,
+		Groups:   claims.Groups,
+		Audience: claims.GetAudience(),
+		Expires:  claims.ExpiryTime().UTC(),
+	}
+
+	namespace := projectNamespace(kc.Realm, project, kc.Namespace)
+	if namespace != "" {
+		cli, err := k8sapi.New(kc.APIServer, kc.Creds.AccessToken, kc.CACert, kc.Insecure)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := ctxWithTimeout()
+		defer cancel()
+		perms, err := collectProjectPermissions(ctx, cli, namespace)
+		if err != nil {
+			return fmt.Errorf("check permissions in %s: %w", namespace, err)
+		}
+		perms.Name = strings.TrimPrefix(namespace, kc.Realm+"-")
+		report.Project = perms
+	}
+
+	if out != outTable {
+		return printSerialized(out, report)
+	}
+	printWhoami(report)
+	return nil
+}
+
+// projectNamespace maps --project to its backing namespace. Project
+// namespaces are <org>-<project>; a value that already carries the prefix,
+// or any value on the cluster-scoped admin context, is taken as given.
+func projectNamespace(realm, project, contextNamespace string) string {
+	if project == "" {
+		return contextNamespace
+	}
+	if realm == "" || realm == adminRealm || strings.HasPrefix(project, realm+"-") {
+		return project
+	}
+	return realm + "-" + project
+}
+
+// collectProjectPermissions reads the verb matrix from one rules review and
+// settles the role question with access reviews. Roles are never inferred
+// from an incomplete rules review: a missing rule there means "unknown", not
+// "denied".
+func collectProjectPermissions(ctx context.Context, cli *k8sapi.Client, namespace string) (*projectPermissions, error) {
+	rules, err := cli.SelfSubjectRulesReview(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	can := func(verb, resource string) (bool, error) {
+		if !rules.Incomplete {
+			return rules.Allows(securityGroup, resource, verb), nil
+		}
+		st, err := cli.SelfSubjectAccessReview(ctx, k8sapi.ResourceAttributes{
+			Namespace: namespace, Verb: verb, Group: securityGroup, Resource: resource,
+		})
+		if err != nil {
+			return false, err
+		}
+		return st.Allowed, nil
+	}
+
+	perms := &projectPermissions{Namespace: namespace, Incomplete: rules.Incomplete}
+	for _, r := range whoamiResources {
+		rp := resourcePermissions{Kind: r.Kind, Resource: r.Resource, Verbs: []string{}}
+		for _, verb := range whoamiVerbs {
+			ok, err := can(verb, r.Resource)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				rp.Verbs = append(rp.Verbs, verb)
+			}
+		}
+		perms.Resources = append(perms.Resources, rp)
+	}
+
+	held := map[string]bool{}
+	for _, sig := range projectRoleSignatures {
+		st, err := cli.SelfSubjectAccessReview(ctx, k8sapi.ResourceAttributes{
+			Namespace: namespace, Verb: sig.Verb, Group: securityGroup, Resource: sig.Resource,
+		})
+		if err != nil {
+			return nil, err
+		}
+		held[sig.Role] = st.Allowed
+	}
+	perms.Roles = projectRoles(held)
+	return perms, nil
+}
+
+// projectRoles turns signature hits into role names. The roles nest — every
+// admin also passes the developer and project-manager checks — so only the
+// most specific answer is reported: admin alone, else developer and/or
+// project-manager, else user.
+func projectRoles(held map[string]bool) []string {
+	if held["admin"] {
+		return []string{"admin"}
+	}
+	roles := []string{}
+	for _, r := range []string{"developer", "project-manager"} {
+		if held[r] {
+			roles = append(roles, r)
+		}
+	}
+	if len(roles) == 0 && held["user"] {
+		roles = append(roles, "user")
+	}
+	return roles
+}
+
+func printWhoami(r *whoamiReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Context:\t%s\n", r.Context)
+	fmt.Fprintf(w, "Realm:\t%s\n", r.Realm)
+	fmt.Fprintf(w, "Subject:\t%s\n", r.Subject)
+	if r.Email != "" {
+		fmt.Fprintf(w, "Email:\t%s\n", r.Email)
+	}
+	if r.Username != "" {
+		fmt.Fprintf(w, "Username:\t%s\n", r.Username)
+	}
+	fmt.Fprintf(w, "Groups:\t%s\n", joinOrNone(r.Groups))
+	fmt.Fprintf(w, "Audience:\t%s\n", joinOrNone(r.Audience))
+	if left := time.Until(r.Expires).Round(time.Second); left > 0 {
+		fmt.Fprintf(w, "Expires:\t%s (in %s)\n", r.Expires.Format(time.RFC3339), left)
+	} else {
+		fmt.Fprintf(w, "Expires:\t%s (expired)\n", r.Expires.Format(time.RFC3339))
+	}
+	_ = w.Flush()
+
+	p := r.Project
+	if p == nil {
+		fmt.Println("\nNo Project selected; pass --project to see Project permissions.")
+		return
+	}
+	fmt.Printf("\nProject:  %s (namespace %s)\n", p.Name, p.Namespace)
+	if len(p.Roles) == 0 {
+		fmt.Println("Roles:    none — this identity holds no standard Project role here")
+	} else {
+		fmt.Printf("Roles:    %s\n", strings.Join(p.Roles, ", "))
+	}
+	if p.Incomplete {
+		fmt.Println("(rules review incomplete; verbs confirmed individually)")
+	}
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tRESOURCE\tVERBS")
+	for _, rp := range p.Resources {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", rp.Kind, rp.Resource, joinOrNone(rp.Verbs))
+	}
+	_ = w.Flush()
+}
+
+func joinOrNone(v []string) string {
+	if len(v) == 0 {
+		return "-"
+	}
+	return strings.Join(v, ", ")
+}
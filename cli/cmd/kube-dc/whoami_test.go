@@ -0,0 +1,165 @@
+// Unit tests for `kube-dc whoami`. The kube-apiserver is a stand-in that
+// answers both review kinds from one rule set, the way RBAC would, so the
+// role inference is checked against the real Project Role templates'
+// security rules rather than against hand-picked booleans.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/shalb/kube-dc/cli/internal/k8sapi"
+)
+
+// securityRules mirrors the security.kube-dc.com rules of each Role in
+// charts/kube-dc/templates/default-project-admin-role.yaml.
+var securityRules = map[string][]k8sapi.ResourceRule{
+	"admin": {
+		{APIGroups: []string{securityGroup}, Resources: []string{"managedsecrets", "managedcertificates", "kmskeys", "databasecredentialpolicies"}, Verbs: []string{"*"}},
+	},
+	"developer": {
+		{APIGroups: []string{securityGroup}, Resources: []string{"managedsecrets", "managedcertificates", "databasecredentialpolicies"}, Verbs: []string{"create", "get", "list", "watch", "update", "patch", "delete"}},
+		{APIGroups: []string{securityGroup}, Resources: []string{"kmskeys"}, Verbs: []string{"get", "list", "watch"}},
+	},
+	"project-manager": {
+		{APIGroups: []string{securityGroup}, Resources: []string{"managedsecrets", "managedcertificates", "databasecredentialpolicies"}, Verbs: []string{"get", "list", "watch", "update", "patch"}},
+		{APIGroups: []string{securityGroup}, Resources: []string{"kmskeys"}, Verbs: []string{"get", "list", "watch", "create", "update", "patch"}},
+	},
+	"user": {
+		{APIGroups: []string{securityGroup}, Resources: []string{"managedsecrets", "managedcertificates", "kmskeys", "databasecredentialpolicies"}, Verbs: []string{"get", "list", "watch"}},
+	},
+}
+
+func fakeAuthzServer(t *testing.T, rules []k8sapi.ResourceRule, incomplete bool) (*httptest.Server, *int) {
+	t.Helper()
+	accessReviews := 0
+	status := &k8sapi.RulesReviewStatus{ResourceRules: rules}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/apis/authorization.k8s.io/v1/selfsubjectrulesreviews", func(w http.ResponseWriter, r *http.Request) {
+		reported := *status
+		reported.Incomplete = incomplete
+		if incomplete {
+			// An incomplete review may omit rules the caller does hold.
+			reported.ResourceRules = nil
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"status": reported})
+	})
+	mux.HandleFunc("/apis/authorization.k8s.io/v1/selfsubjectaccessreviews", func(w http.ResponseWriter, r *http.Request) {
+		accessReviews++
+		var req struct {
+			Spec struct {
+				ResourceAttributes k8sapi.ResourceAttributes `json:"resourceAttributes"`
+			} `json:"spec"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode access review: %v", err)
+		}
+		a := req.Spec.ResourceAttributes
+		if a.Namespace != "shalb-web" {
+			t.Errorf("access review namespace = %q, want shalb-web", a.Namespace)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"status": map[string]any{"allowed": status.Allows(a.Group, a.Resource, a.Verb)}})
+	})
+	return httptest.NewServer(mux), &accessReviews
+}
+
+func TestCollectProjectPermissions_InfersEachStandardRole(t *testing.T) {
+	for _, role := range []string{"user", "developer", "project-manager", "admin"} {
+		t.Run(role, func(t *testing.T) {
+			srv, _ := fakeAuthzServer(t, securityRules[role], false)
+			defer srv.Close()
+			cli, err := k8sapi.New(srv.URL, "token", "", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			perms, err := collectProjectPermissions(context.Background(), cli, "shalb-web")
+			if err != nil {
+				t.Fatalf("collectProjectPermissions: %v", err)
+			}
+			if !reflect.DeepEqual(perms.Roles, []string{role}) {
+				t.Errorf("roles = %v, want [%s]", perms.Roles, role)
+			}
+		})
+	}
+}
+
+func TestCollectProjectPermissions_VerbMatrix(t *testing.T) {
+	srv, _ := fakeAuthzServer(t, securityRules["project-manager"], false)
+	defer srv.Close()
+	cli, _ := k8sapi.New(srv.URL, "token", "", false)
+
+	perms, err := collectProjectPermissions(context.Background(), cli, "shalb-web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]string{}
+	for _, r := range perms.Resources {
+		got[r.Kind] = r.Verbs
+	}
+	want := map[string][]string{
+		"ManagedSecret":            {"get", "list", "watch", "update", "patch"},
+		"KMSKey":                   {"get", "list", "watch", "create", "update", "patch"},
+		"ManagedCertificate":       {"get", "list", "watch", "update", "patch"},
+		"DatabaseCredentialPolicy": {"get", "list", "watch", "update", "patch"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("verbs = %v\nwant    %v", got, want)
+	}
+}
+
+// When the rules review cannot enumerate everything, a missing rule is not
+// a denial: every verb has to be confirmed with an access review.
+func TestCollectProjectPermissions_IncompleteRulesFallBackToAccessReviews(t *testing.T) {
+	srv, reviews := fakeAuthzServer(t, securityRules["developer"], true)
+	defer srv.Close()
+	cli, _ := k8sapi.New(srv.URL, "token", "", false)
+
+	perms, err := collectProjectPermissions(context.Background(), cli, "shalb-web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !perms.Incomplete {
+		t.Error("Incomplete not reported")
+	}
+	if want := len(whoamiResources)*len(whoamiVerbs) + len(projectRoleSignatures); *reviews != want {
+		t.Errorf("access reviews = %d, want %d", *reviews, want)
+	}
+	if perms.Resources[0].Kind != "ManagedSecret" || len(perms.Resources[0].Verbs) != 7 {
+		t.Errorf("ManagedSecret verbs = %v, want all seven confirmed by access review", perms.Resources[0].Verbs)
+	}
+	if !reflect.DeepEqual(perms.Roles, []string{"developer"}) {
+		t.Errorf("roles = %v, want [developer]", perms.Roles)
+	}
+}
+
+func TestProjectRoles_DeveloperAndProjectManagerCombine(t *testing.T) {
+	got := projectRoles(map[string]bool{"developer": true, "project-manager": true, "user": true})
+	if !reflect.DeepEqual(got, []string{"developer", "project-manager"}) {
+		t.Errorf("roles = %v", got)
+	}
+	if got := projectRoles(map[string]bool{}); len(got) != 0 {
+		t.Errorf("no grants should report no roles, got %v", got)
+	}
+}
+
+func TestProjectNamespace(t *testing.T) {
+	cases := []struct {
+		realm, project, ctxNS, want string
+	}{
+		{"shalb", "", "shalb-web", "shalb-web"},
+		{"shalb", "api", "shalb-web", "shalb-api"},
+		{"shalb", "shalb-api", "shalb-web", "shalb-api"},
+		{"master", "shalb-api", "", "shalb-api"},
+		{"master", "", "", ""},
+	}
+	for _, c := range cases {
+		if got := projectNamespace(c.realm, c.project, c.ctxNS); got != c.want {
+			t.Errorf("projectNamespace(%q, %q, %q) = %q, want %q", c.realm, c.project, c.ctxNS, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// deviceCodeGrantType is the RFC 8628 §3.4 grant type for polling the token
+// endpoint with a device code.
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// DeviceAuthorization is Keycloak's response to the device authorization
+// request (RFC 8628 §3.2).
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// errDeviceCodeExpired is returned both when Keycloak reports expired_token and
+// when the code's own expires_in elapses locally; the remedy is the same.
+var errDeviceCodeExpired = errors.New("device code expired before authentication completed; run the login again")
+
+// oauthError is the RFC 6749 §5.2 error body. The device flow relies on it
+// for the authorization_pending / slow_down signals, not just for failures.
+type oauthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// DeviceFlow handles the OAuth 2.0 device authorization grant (RFC 8628), for
+// machines with no browser: jump hosts, tmux sessions, containers. The user
+// opens the verification URI on any other device and types the user code;
+// the CLI polls Keycloak until that happens.
+type DeviceFlow struct {
+	config *OAuthConfig
+
+	// after is time.After outside of tests. Tests swap it to record the
+	// requested waits and return immediately, so the backoff is observable
+	// without a test that sleeps for real.
+	after func(time.Duration) <-chan time.Time
+}
+
+// NewDeviceFlow creates a new device authorization flow handler
+func NewDeviceFlow(config *OAuthConfig) *DeviceFlow {
+	return &DeviceFlow{config: config, after: time.After}
+}
+
+// Login performs the device authorization flow: request a device code, show
+// the user where to enter it, then poll the token endpoint until the user
+// approves, denies, or the code expires.
+func (f *DeviceFlow) Login(ctx context.Context) (*TokenResponse, error) {
+	da, err := f.requestDeviceCode(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Println("To authenticate, open this URL on any device with a browser:")
+	fmt.Printf("\n    %s\n\n", da.VerificationURI)
+	fmt.Printf("and enter the code:  %s\n\n", da.UserCode)
+	if da.VerificationURIComplete != "" {
+		fmt.Printf("Or open this link, which has the code filled in:\n    %s\n\n", da.VerificationURIComplete)
+	}
+	fmt.Println("Waiting for authentication...")
+
+	return f.poll(ctx, da)
+}
+
+func (f *DeviceFlow) requestDeviceCode(ctx context.Context) (*DeviceAuthorization, error) {
+	endpoint := fmt.Sprintf("%s/realms/%s/protocol/openid-connect/auth/device",
+		f.config.KeycloakURL, f.config.Realm)
+	data := url.Values{
+		"client_id": {f.config.ClientID},
+		"scope":     {"openid offline_access"},
+	}
+
+	status, body, err := f.postForm(ctx, endpoint, data)
+	if err != nil {
+		return nil, fmt.Errorf("device authorization request failed: %w", err)
+	}
+	if status != http.StatusOK {
+		var oe oauthError
+		if json.Unmarshal(body, &oe) == nil && oe.Error == "unauthorized_client" {
+			// Keycloak answers this when "OAuth 2.0 Device Authorization
+			// Grant" is switched off on the client. That is a realm setting
+			// the user cannot fix from here, so name it.
+			return nil, fmt.Errorf("client %q in realm %q does not allow the device authorization grant; "+
+				"ask an administrator to enable \"OAuth 2.0 Device Authorization Grant\" on it, "+
+				"or log in without --device-code", f.config.ClientID, f.config.Realm)
+		}
+		return nil, fmt.Errorf("device authorization request failed: %s", string(body))
+	}
+
+	var da DeviceAuthorization
+	if err := json.Unmarshal(body, &da); err != nil {
+		return nil, fmt.Errorf("failed to parse device authorization response: %w", err)
+	}
+	if da.DeviceCode == "" || da.UserCode == "" || da.VerificationURI == "" {
+		return nil, fmt.Errorf("device authorization response is missing device_code, user_code or verification_uri")
+	}
+	return &da, nil
+}
+
+// poll implements RFC 8628 §3.4/§3.5. The interval defaults to 5s when the
+// server gives none, and every slow_down permanently adds 5s to it, as the RFC
+// requires — a client that ignores slow_down gets rate-limited into failure.
+func (f *DeviceFlow) poll(ctx context.Context, da *DeviceAuthorization) (*TokenResponse, error) {
+	tokenURL := fmt.Sprintf("%s/realms/%s/protocol/openid-connect/token",
+		f.config.KeycloakURL, f.config.Realm)
+	data := url.Values{
+		"grant_type":  {deviceCodeGrantType},
+		"client_id":   {f.config.ClientID},
+		"device_code": {da.DeviceCode},
+	}
+
+	interval := time.Duration(da.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	parent := ctx
+	if da.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(da.ExpiresIn)*time.Second)
+		defer cancel()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			if parent.Err() == nil {
+				return nil, errDeviceCodeExpired
+			}
+			return nil, parent.Err()
+		case <-f.after(interval):
+		}
+
+		status, body, err := f.postForm(ctx, tokenURL, data)
+		if err != nil {
+			if ctx.Err() != nil && parent.Err() == nil {
+				return nil, errDeviceCodeExpired
+			}
+			return nil, fmt.Errorf("token request failed: %w", err)
+		}
+		if status == http.StatusOK {
+			var token TokenResponse
+			if err := json.Unmarshal(body, &token); err != nil {
+				return nil, err
+			}
+			return &token, nil
+		}
+
+		var oe oauthError
+		if err := json.Unmarshal(body, &oe); err != nil || oe.Error == "" {
+			return nil, fmt.Errorf("token request failed: %s", string(body))
+		}
+		switch oe.Error {
+		case "authorization_pending":
+			// The user has not finished yet — keep polling.
+		case "slow_down":
+			interval += 5 * time.Second
+		case "access_denied":
+			return nil, fmt.Errorf("authentication was denied in the browser")
+		case "expired_token":
+			return nil, errDeviceCodeExpired
+		default:
+			return nil, fmt.Errorf("oauth error: %s - %s", oe.Error, oe.ErrorDescription)
+		}
+	}
+}
+
+func (f *DeviceFlow) postForm(ctx context.Context, endpoint string, data url.Values) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := CreateHTTPClient(f.config.CACert, f.config.Insecure).Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
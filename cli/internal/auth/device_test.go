@@ -0,0 +1,243 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeKeycloak is a stand-in for the two realm endpoints the device flow
+// talks to. tokenReplies is consumed one per poll; the last entry repeats.
+type fakeKeycloak struct {
+	t            *testing.T
+	deviceStatus int
+	deviceReply  map[string]any
+	tokenReplies []tokenReply
+
+	mu        sync.Mutex
+	polls     int
+	lastForms []map[string]string
+}
+
+type tokenReply struct {
+	status int
+	body   map[string]any
+}
+
+func (k *fakeKeycloak) server() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/realms/acme/protocol/openid-connect/auth/device", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if got := r.PostForm.Get("client_id"); got != "kube-dc" {
+			k.t.Errorf("device request client_id = %q, want kube-dc", got)
+		}
+		if !strings.Contains(r.PostForm.Get("scope"), "offline_access") {
+			k.t.Errorf("device request must ask for offline_access so the refresh token outlives the session; scope = %q", r.PostForm.Get("scope"))
+		}
+		status := k.deviceStatus
+		if status == 0 {
+			status = http.StatusOK
+		}
+		writeJSON(w, status, k.deviceReply)
+	})
+	mux.HandleFunc("/realms/acme/protocol/openid-connect/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		k.mu.Lock()
+		form := map[string]string{}
+		for key := range r.PostForm {
+			form[key] = r.PostForm.Get(key)
+		}
+		k.lastForms = append(k.lastForms, form)
+		i := k.polls
+		if i >= len(k.tokenReplies) {
+			i = len(k.tokenReplies) - 1
+		}
+		k.polls++
+		reply := k.tokenReplies[i]
+		k.mu.Unlock()
+		writeJSON(w, reply.status, reply.body)
+	})
+	return httptest.NewServer(mux)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func pending(code string) tokenReply {
+	return tokenReply{status: http.StatusBadRequest, body: map[string]any{"error": code}}
+}
+
+// newTestDeviceFlow returns a flow pointed at srv whose waits return at once
+// and are recorded, so the polling schedule can be asserted exactly.
+func newTestDeviceFlow(srv *httptest.Server) (*DeviceFlow, *[]time.Duration) {
+	f := NewDeviceFlow(&OAuthConfig{KeycloakURL: srv.URL, Realm: "acme", ClientID: "kube-dc"})
+	var waits []time.Duration
+	f.after = func(d time.Duration) <-chan time.Time {
+		waits = append(waits, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+	return f, &waits
+}
+
+var defaultDeviceReply = map[string]any{
+	"device_code":               "dev-123",
+	"user_code":                 "WDJB-MJHT",
+	"verification_uri":          "https://login.example.com/realms/acme/device",
+	"verification_uri_complete": "https://login.example.com/realms/acme/device?user_code=WDJB-MJHT",
+	"expires_in":                600,
+	"interval":                  2,
+}
+
+func TestDeviceFlow_PollsUntilApprovedAndHonoursSlowDown(t *testing.T) {
+	kc := &fakeKeycloak{
+		t:           t,
+		deviceReply: defaultDeviceReply,
+		tokenReplies: []tokenReply{
+			pending("authorization_pending"),
+			pending("slow_down"),
+			pending("authorization_pending"),
+			{status: http.StatusOK, body: map[string]any{
+				"access_token":       "at",
+				"refresh_token":      "rt",
+				"id_token":           "it",
+				"expires_in":         300,
+				"refresh_expires_in": 0,
+			}},
+		},
+	}
+	srv := kc.server()
+	defer srv.Close()
+
+	f, waits := newTestDeviceFlow(srv)
+	tok, err := f.Login(context.Background())
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if tok.AccessToken != "at" || tok.RefreshToken != "rt" || tok.IDToken != "it" {
+		t.Errorf("token = %+v, want at/rt/it", tok)
+	}
+
+	// interval=2s from the server; slow_down after the second poll adds 5s
+	// for every remaining wait, not just the next one.
+	want := []time.Duration{2 * time.Second, 2 * time.Second, 7 * time.Second, 7 * time.Second}
+	if len(*waits) != len(want) {
+		t.Fatalf("waits = %v, want %v", *waits, want)
+	}
+	for i := range want {
+		if (*waits)[i] != want[i] {
+			t.Errorf("wait[%d] = %v, want %v (all waits: %v)", i, (*waits)[i], want[i], *waits)
+		}
+	}
+
+	for _, form := range kc.lastForms {
+		if form["grant_type"] != deviceCodeGrantType || form["device_code"] != "dev-123" || form["client_id"] != "kube-dc" {
+			t.Errorf("token poll form = %v", form)
+		}
+	}
+}
+
+func TestDeviceFlow_DefaultsIntervalWhenServerOmitsIt(t *testing.T) {
+	reply := map[string]any{}
+	for k, v := range defaultDeviceReply {
+		reply[k] = v
+	}
+	delete(reply, "interval")
+	kc := &fakeKeycloak{
+		t:            t,
+		deviceReply:  reply,
+		tokenReplies: []tokenReply{{status: http.StatusOK, body: map[string]any{"access_token": "at"}}},
+	}
+	srv := kc.server()
+	defer srv.Close()
+
+	f, waits := newTestDeviceFlow(srv)
+	if _, err := f.Login(context.Background()); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if len(*waits) != 1 || (*waits)[0] != 5*time.Second {
+		t.Errorf("waits = %v, want [5s] (RFC 8628 default)", *waits)
+	}
+}
+
+func TestDeviceFlow_TerminalErrors(t *testing.T) {
+	cases := []struct {
+		code string
+		want string
+	}{
+		{"access_denied", "denied"},
+		{"expired_token", "expired"},
+		{"invalid_client", "invalid_client"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.code, func(t *testing.T) {
+			kc := &fakeKeycloak{
+				t:            t,
+				deviceReply:  defaultDeviceReply,
+				tokenReplies: []tokenReply{pending("authorization_pending"), pending(tc.code)},
+			}
+			srv := kc.server()
+			defer srv.Close()
+
+			f, _ := newTestDeviceFlow(srv)
+			_, err := f.Login(context.Background())
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("err = %v, want it to mention %q", err, tc.want)
+			}
+			if kc.polls != 2 {
+				t.Errorf("polls = %d, want 2 (stop at the first terminal error)", kc.polls)
+			}
+		})
+	}
+}
+
+// A client without the device grant enabled is a realm configuration problem;
+// the raw Keycloak JSON does not tell the user that.
+func TestDeviceFlow_UnauthorizedClientNamesTheSetting(t *testing.T) {
+	kc := &fakeKeycloak{
+		t:            t,
+		deviceStatus: http.StatusUnauthorized,
+		deviceReply:  map[string]any{"error": "unauthorized_client", "error_description": "Client is not allowed to initiate OAuth 2.0 Device Authorization Grant."},
+	}
+	srv := kc.server()
+	defer srv.Close()
+
+	f, _ := newTestDeviceFlow(srv)
+	_, err := f.Login(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "Device Authorization Grant") {
+		t.Fatalf("err = %v, want it to name the Keycloak client setting", err)
+	}
+}
+
+func TestDeviceFlow_ExpiresLocally(t *testing.T) {
+	reply := map[string]any{}
+	for k, v := range defaultDeviceReply {
+		reply[k] = v
+	}
+	reply["expires_in"] = 1
+	kc := &fakeKeycloak{
+		t:            t,
+		deviceReply:  reply,
+		tokenReplies: []tokenReply{pending("authorization_pending")},
+	}
+	srv := kc.server()
+	defer srv.Close()
+
+	f, _ := newTestDeviceFlow(srv)
+	// Real waits here, but short ones: the code's own lifetime must end the
+	// loop even if the server never says expired_token.
+	f.after = func(time.Duration) <-chan time.Time { return time.After(50 * time.Millisecond) }
+	_, err := f.Login(context.Background())
+	if err != errDeviceCodeExpired {
+		t.Fatalf("err = %v, want errDeviceCodeExpired", err)
+	}
+}
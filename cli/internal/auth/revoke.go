@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Token type hints for RFC 7009 §2.1.
+const (
+	HintRefreshToken = "refresh_token"
+	HintAccessToken  = "access_token"
+)
+
+// SessionConfig identifies the realm client a stored session belongs to, for
+// the calls that end it. ClientSecretFile is set for confidential clients
+// (service-account logins); Keycloak refuses to revoke their tokens without it.
+type SessionConfig struct {
+	KeycloakURL      string
+	Realm            string
+	ClientID         string
+	ClientSecretFile string
+	CACert           string
+	Insecure         bool
+}
+
+// RevokeToken revokes token at the realm's revocation endpoint (RFC 7009).
+// Revoking a refresh token also invalidates the offline session behind it,
+// which is what makes a copied credential file useless. An unknown or
+// already-expired token is not an error: the endpoint answers 200 for those.
+func RevokeToken(ctx context.Context, c *SessionConfig, token, hint string) error {
+	data := url.Values{"token": {token}}
+	if hint != "" {
+		data.Set("token_type_hint", hint)
+	}
+	endpoint := fmt.Sprintf("%s/realms/%s/protocol/openid-connect/revoke", c.KeycloakURL, c.Realm)
+	if err := c.post(ctx, endpoint, data); err != nil {
+		return fmt.Errorf("token revocation failed: %w", err)
+	}
+	return nil
+}
+
+// EndSession ends the Keycloak SSO session at the realm's end-session
+// endpoint. This is the back-channel form — a POST with the refresh token —
+// so no browser is involved; the ID token, when present, is passed as the
+// hint Keycloak uses to pick the session. Call it before RevokeToken: once
+// the refresh token is revoked Keycloak can no longer find the session. A
+// session that has already ended (invalid_grant) counts as ended.
+func EndSession(ctx context.Context, c *SessionConfig, refreshToken, idToken string) error {
+	data := url.Values{}
+	if refreshToken != "" {
+		data.Set("refresh_token", refreshToken)
+	}
+	if idToken != "" {
+		data.Set("id_token_hint", idToken)
+	}
+	endpoint := fmt.Sprintf("%s/realms/%s/protocol/openid-connect/logout", c.KeycloakURL, c.Realm)
+	err := c.post(ctx, endpoint, data)
+	var oe *oauthStatusError
+	if errors.As(err, &oe) && oe.oauth.Error == "invalid_grant" {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("end session failed: %w", err)
+	}
+	return nil
+}
+
+// oauthStatusError is a non-2xx answer from a realm endpoint, with the
+// RFC 6749 §5.2 error code when the body carried one.
+type oauthStatusError struct {
+	oauth  oauthError
+	status string
+	body   string
+}
+
+func (e *oauthStatusError) Error() string {
+	if e.oauth.Error != "" {
+		return fmt.Sprintf("%s: %s - %s", e.status, e.oauth.Error, e.oauth.ErrorDescription)
+	}
+	return fmt.Sprintf("%s: %s", e.status, e.body)
+}
+
+func (c *SessionConfig) post(ctx context.Context, endpoint string, data url.Values) error {
+	data.Set("client_id", c.ClientID)
+	if c.ClientSecretFile != "" {
+		secret, err := readTrimmed(c.ClientSecretFile)
+		if err != nil {
+			return fmt.Errorf("read client secret: %w", err)
+		}
+		data.Set("client_secret", secret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := CreateHTTPClient(c.CACert, c.Insecure).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		e := &oauthStatusError{status: resp.Status, body: strings.TrimSpace(string(body))}
+		_ = json.Unmarshal(body, &e.oauth)
+		return e
+	}
+	return nil
+}
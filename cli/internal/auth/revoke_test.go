@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRevokeToken_PostsRFC7009Form(t *testing.T) {
+	var form map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/realms/acme/protocol/openid-connect/revoke" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		_ = r.ParseForm()
+		form = map[string]string{}
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+	}))
+	defer srv.Close()
+
+	secret := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secret, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sc := &SessionConfig{KeycloakURL: srv.URL, Realm: "acme", ClientID: "ci-deployer", ClientSecretFile: secret}
+	if err := RevokeToken(context.Background(), sc, "rt", HintRefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"token": "rt", "token_type_hint": "refresh_token", "client_id": "ci-deployer", "client_secret": "s3cret"}
+	for k, v := range want {
+		if form[k] != v {
+			t.Errorf("%s = %q, want %q", k, form[k], v)
+		}
+	}
+}
+
+func TestRevokeToken_ReportsServerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "unauthorized_client"})
+	}))
+	defer srv.Close()
+
+	err := RevokeToken(context.Background(), &SessionConfig{KeycloakURL: srv.URL, Realm: "acme", ClientID: "kube-dc"}, "rt", HintRefreshToken)
+	if err == nil || !strings.Contains(err.Error(), "unauthorized_client") {
+		t.Fatalf("err = %v, want unauthorized_client", err)
+	}
+}
+
+func TestEndSession_SendsTokensAndToleratesEndedSession(t *testing.T) {
+	status := http.StatusNoContent
+	var form map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/realms/acme/protocol/openid-connect/logout" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		_ = r.ParseForm()
+		form = map[string]string{"refresh_token": r.PostForm.Get("refresh_token"), "id_token_hint": r.PostForm.Get("id_token_hint")}
+		if status == http.StatusNoContent {
+			w.WriteHeader(status)
+			return
+		}
+		writeJSON(w, status, map[string]any{"error": "invalid_grant", "error_description": "Session not active"})
+	}))
+	defer srv.Close()
+	sc := &SessionConfig{KeycloakURL: srv.URL, Realm: "acme", ClientID: "kube-dc"}
+
+	if err := EndSession(context.Background(), sc, "rt", "it"); err != nil {
+		t.Fatal(err)
+	}
+	if form["refresh_token"] != "rt" || form["id_token_hint"] != "it" {
+		t.Errorf("form = %v", form)
+	}
+
+	status = http.StatusBadRequest
+	if err := EndSession(context.Background(), sc, "rt", ""); err != nil {
+		t.Errorf("an already-ended session should count as ended, got %v", err)
+	}
+}
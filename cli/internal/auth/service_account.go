@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Grant types a non-interactive login can be re-minted with. They are stored
+// on config.Credentials so the exec plugin knows how to get a new token once
+// the access token expires and there is no refresh token to spend.
+const (
+	GrantClientCredentials = "client_credentials"
+	GrantTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+)
+
+// jwtTokenType is the RFC 8693 token type for a federated OIDC ID token such
+// as the one GitHub Actions issues to a workflow.
+const jwtTokenType = "urn:ietf:params:oauth:token-type:jwt"
+
+// ServiceAccountConfig describes a non-interactive login: a confidential
+// Keycloak client authenticating as itself (client_credentials), or trading
+// a token from a trusted external issuer for a realm token (token exchange).
+//
+// Secrets are referenced by file path, never by value, so the same config can
+// be persisted and replayed by the exec plugin without the secret landing in
+// the credential cache.
+type ServiceAccountConfig struct {
+	KeycloakURL      string
+	Realm            string
+	ClientID         string
+	ClientSecretFile string // optional for token exchange with a public client
+	SubjectTokenFile string // set → token exchange; empty → client_credentials
+	SubjectIssuer    string // Keycloak identity-provider alias that issued the subject token
+	CACert           string
+	Insecure         bool
+}
+
+// GrantType reports which grant this config mints tokens with.
+func (c *ServiceAccountConfig) GrantType() string {
+	if c.SubjectTokenFile != "" {
+		return GrantTokenExchange
+	}
+	return GrantClientCredentials
+}
+
+// ServiceAccountToken mints a token for a non-interactive login. Files are
+// read on every call so a rotated secret, or a fresh CI ID token written to
+// the same path, is picked up on the next re-mint.
+func ServiceAccountToken(c *ServiceAccountConfig) (*TokenResponse, error) {
+	if c.ClientID == "" {
+		return nil, fmt.Errorf("client ID is required")
+	}
+	data := url.Values{"client_id": {c.ClientID}}
+	if c.ClientSecretFile != "" {
+		secret, err := readTrimmed(c.ClientSecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("read client secret: %w", err)
+		}
+		data.Set("client_secret", secret)
+	}
+
+	switch c.GrantType() {
+	case GrantTokenExchange:
+		subject, err := readTrimmed(c.SubjectTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("read subject token: %w", err)
+		}
+		data.Set("grant_type", GrantTokenExchange)
+		data.Set("subject_token", subject)
+		data.Set("subject_token_type", jwtTokenType)
+		if c.SubjectIssuer != "" {
+			data.Set("subject_issuer", c.SubjectIssuer)
+		}
+	default:
+		if c.ClientSecretFile == "" {
+			return nil, fmt.Errorf("client credentials grant requires a client secret file")
+		}
+		data.Set("grant_type", GrantClientCredentials)
+	}
+
+	tokenURL := fmt.Sprintf("%s/realms/%s/protocol/openid-connect/token", c.KeycloakURL, c.Realm)
+	client := CreateHTTPClient(c.CACert, c.Insecure)
+	resp, err := client.Post(tokenURL, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s grant failed: %s", grantLabel(c.GrantType()), string(body))
+	}
+
+	var token TokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func grantLabel(grant string) string {
+	if grant == GrantTokenExchange {
+		return "token exchange"
+	}
+	return "client credentials"
+}
+
+// readTrimmed reads a secret file and strips the trailing newline that every
+// `echo ... > file` and CI secret mount leaves behind.
+func readTrimmed(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	s := strings.TrimSpace(string(b))
+	if s == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return s, nil
+}
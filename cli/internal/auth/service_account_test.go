@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSecret(t *testing.T, name, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestServiceAccountToken_ClientCredentials(t *testing.T) {
+	kc := &fakeKeycloak{t: t, tokenReplies: []tokenReply{{status: http.StatusOK, body: map[string]any{"access_token": "at", "expires_in": 300}}}}
+	srv := kc.server()
+	defer srv.Close()
+
+	cfg := &ServiceAccountConfig{
+		KeycloakURL: srv.URL,
+		Realm:       "acme",
+		ClientID:    "ci-deployer",
+		// CI secret mounts and `echo > file` both leave a trailing newline;
+		// sending it makes Keycloak reject an otherwise correct secret.
+		ClientSecretFile: writeSecret(t, "secret", "s3cret\n"),
+	}
+	tok, err := ServiceAccountToken(cfg)
+	if err != nil {
+		t.Fatalf("ServiceAccountToken: %v", err)
+	}
+	if tok.AccessToken != "at" {
+		t.Errorf("access token = %q, want at", tok.AccessToken)
+	}
+
+	form := kc.lastForms[0]
+	if form["grant_type"] != GrantClientCredentials || form["client_id"] != "ci-deployer" || form["client_secret"] != "s3cret" {
+		t.Errorf("form = %v", form)
+	}
+	if _, ok := form["subject_token"]; ok {
+		t.Errorf("client_credentials must not send a subject_token: %v", form)
+	}
+}
+
+func TestServiceAccountToken_TokenExchange(t *testing.T) {
+	kc := &fakeKeycloak{t: t, tokenReplies: []tokenReply{{status: http.StatusOK, body: map[string]any{"access_token": "at"}}}}
+	srv := kc.server()
+	defer srv.Close()
+
+	cfg := &ServiceAccountConfig{
+		KeycloakURL:      srv.URL,
+		Realm:            "acme",
+		ClientID:         "github-actions",
+		SubjectTokenFile: writeSecret(t, "id-token", "eyJ.github.jwt\n"),
+		SubjectIssuer:    "github",
+	}
+	if cfg.GrantType() != GrantTokenExchange {
+		t.Fatalf("GrantType = %q, want token exchange when a subject token is set", cfg.GrantType())
+	}
+	if _, err := ServiceAccountToken(cfg); err != nil {
+		t.Fatalf("ServiceAccountToken: %v", err)
+	}
+
+	form := kc.lastForms[0]
+	want := map[string]string{
+		"grant_type":         GrantTokenExchange,
+		"client_id":          "github-actions",
+		"subject_token":      "eyJ.github.jwt",
+		"subject_token_type": jwtTokenType,
+		"subject_issuer":     "github",
+	}
+	for k, v := range want {
+		if form[k] != v {
+			t.Errorf("form[%s] = %q, want %q", k, form[k], v)
+		}
+	}
+	if _, ok := form["client_secret"]; ok {
+		t.Errorf("public client must not send a client_secret: %v", form)
+	}
+}
+
+// Files are re-read on every call: a re-mint must pick up the ID token the CI
+// runner wrote since the last one, not a copy captured at login.
+func TestServiceAccountToken_RereadsSubjectTokenFile(t *testing.T) {
+	kc := &fakeKeycloak{t: t, tokenReplies: []tokenReply{{status: http.StatusOK, body: map[string]any{"access_token": "at"}}}}
+	srv := kc.server()
+	defer srv.Close()
+
+	path := writeSecret(t, "id-token", "first")
+	cfg := &ServiceAccountConfig{KeycloakURL: srv.URL, Realm: "acme", ClientID: "gha", SubjectTokenFile: path}
+	if _, err := ServiceAccountToken(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("second"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ServiceAccountToken(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := kc.lastForms[1]["subject_token"]; got != "second" {
+		t.Errorf("second call sent subject_token %q, want the rewritten file's content", got)
+	}
+}
+
+func TestServiceAccountToken_Errors(t *testing.T) {
+	kc := &fakeKeycloak{t: t, tokenReplies: []tokenReply{{status: http.StatusUnauthorized, body: map[string]any{"error": "unauthorized_client"}}}}
+	srv := kc.server()
+	defer srv.Close()
+
+	cases := []struct {
+		name string
+		cfg  ServiceAccountConfig
+		want string
+	}{
+		{"no secret for client credentials", ServiceAccountConfig{ClientID: "c"}, "client secret file"},
+		{"empty secret file", ServiceAccountConfig{ClientID: "c", ClientSecretFile: writeSecret(t, "empty", "\n")}, "is empty"},
+		{"missing subject token", ServiceAccountConfig{ClientID: "c", SubjectTokenFile: filepath.Join(t.TempDir(), "nope")}, "read subject token"},
+		{"rejected by keycloak", ServiceAccountConfig{ClientID: "c", ClientSecretFile: writeSecret(t, "s", "x")}, "client credentials grant failed"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := tc.cfg
+			cfg.KeycloakURL, cfg.Realm = srv.URL, "acme"
+			_, err := ServiceAccountToken(&cfg)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("err = %v, want it to mention %q", err, tc.want)
+			}
+		})
+	}
+}
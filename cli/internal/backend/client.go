@@ -15,6 +15,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -100,6 +101,12 @@ func (e *APIError) Error() string {
 	return fmt.Sprintf("backend %d", e.Status)
 }
 
+// IsNotFound reports whether err is a 404 from the backend.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound
+}
+
 func truncate(s string, n int) string {
 	if len(s) <= n {
 		return s
@@ -0,0 +1,96 @@
+// Typed wrappers for the /api/create-vm/* catalog surface the VM
+// wizard uses: the live OS image catalog and the StorageClasses a VM
+// disk may use. Read-only — the VM and its DataVolume are created on
+// the kube-apiserver directly (see internal/k8sapi).
+
+package backend
+
+import (
+	"context"
+	"strings"
+)
+
+// OSImage is one catalog entry. Field names follow the
+// images-configmap keys; the underscore fields come from
+// cdi-os-catalog and are absent on a cluster whose catalog has not
+// been refreshed yet.
+type OSImage struct {
+	Name           string `json:"OS_NAME"`
+	CloudUser      string `json:"CLOUD_USER"`
+	ImageURL       string `json:"OS_IMAGE_URL"`
+	MinMemory      string `json:"MIN_MEMORY,omitempty"`
+	MinVCPU        string `json:"MIN_VCPU,omitempty"`
+	MinStorage     string `json:"MIN_STORAGE,omitempty"`
+	FirmwareType   string `json:"FIRMWARE_TYPE,omitempty"`
+	MachineType    string `json:"MACHINE_TYPE,omitempty"`
+	CloudInit      string `json:"CLOUD_INIT,omitempty"`
+	AdditionalDisk string `json:"ADDITIONAL_DISKS,omitempty"`
+	FamilyID       string `json:"_familyId,omitempty"`
+	LatestURL      string `json:"_latestURL,omitempty"`
+	// RegistryURL is the digest-pinned containerdisk reference
+	// (docker://repo@sha256:...), present only for families that
+	// publish one.
+	RegistryURL string           `json:"_registryURL,omitempty"`
+	Versions    []OSImageVersion `json:"_versions,omitempty"`
+}
+
+type OSImageVersion struct {
+	Tag         string `json:"tag"`
+	DisplayName string `json:"displayName,omitempty"`
+	ImageURL    string `json:"imageURL"`
+	IsLatest    bool   `json:"isLatest,omitempty"`
+	SHA256      string `json:"sha256,omitempty"`
+	UploadedAt  string `json:"uploadedAt,omitempty"`
+}
+
+// ID is the stable handle for an entry: the family id when the
+// catalog has one, otherwise the display name.
+func (o OSImage) ID() string {
+	if o.FamilyID != "" {
+		return o.FamilyID
+	}
+	return o.Name
+}
+
+// Matches reports whether ref names this entry, by family id or by
+// display name, case-insensitively.
+func (o OSImage) Matches(ref string) bool {
+	return strings.EqualFold(ref, o.FamilyID) || strings.EqualFold(ref, o.Name)
+}
+
+// ListOSImages returns the live OS catalog as seen from a Project
+// namespace.
+func (c *Client) ListOSImages(ctx context.Context, namespace string) ([]OSImage, error) {
+	var out []OSImage
+	if err := c.do(ctx, "GET", "/api/create-vm/"+pathEscape(namespace)+"/os-images", nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// VMStorageClass is a StorageClass VM disks may use, with the
+// access-mode / volume-mode pairs it supports for them. Snapshot is
+// true when a VolumeSnapshotClass serves its provisioner, i.e. when VM
+// snapshots of disks on it can succeed.
+type VMStorageClass struct {
+	Name        string            `json:"name"`
+	Provisioner string            `json:"provisioner,omitempty"`
+	IsDefault   bool              `json:"isDefault,omitempty"`
+	Modes       []StorageModePair `json:"modes,omitempty"`
+	Snapshot    bool              `json:"snapshot,omitempty"`
+}
+
+type StorageModePair struct {
+	AccessMode string `json:"accessMode"`
+	VolumeMode string `json:"volumeMode"`
+}
+
+// ListVMStorageClasses returns the StorageClasses offered for VM disks
+// in a Project namespace.
+func (c *Client) ListVMStorageClasses(ctx context.Context, namespace string) ([]VMStorageClass, error) {
+	var out []VMStorageClass
+	if err := c.do(ctx, "GET", "/api/create-vm/"+pathEscape(namespace)+"/storageclasses", nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -10,7 +10,10 @@ package backend
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 )
 
 // DBCredentialRotation mirrors DatabaseCredentialPolicy.spec.rotation.
@@ -159,21 +162,118 @@ func (c *Client) GetDBCredentials(ctx context.Context, namespace, name string) (
 	return &out, nil
 }
 
-// IssueDBCredentials calls /issue — dynamic-mode credential issue.
-// Phase-1 the controller returns 501 / DynamicModeDeferred because
-// the dynamic-role plumbing isn't wired yet; the CLI surface is
-// shipped so the surface doesn't shift when the controller catches up.
+// IssueDBCredentials calls /issue on a mode=dynamic policy: OpenBao
+// creates a database user that lives for the lease. ttl (e.g. "15m")
+// asks for a shorter lease than the policy's; empty takes the policy
+// default. The controller still answering 501 / DynamicModeDeferred
+// means the installation has not enabled dynamic mode.
 type DBLease struct {
 	Username      string `json:"username"`
 	Password      string `json:"password"`
 	LeaseId       string `json:"leaseId,omitempty"`
 	LeaseDuration int    `json:"leaseDuration,omitempty"`
+	Renewable     bool   `json:"renewable,omitempty"`
+	ExpireTime    string `json:"expireTime,omitempty"`
 }
 
-func (c *Client) IssueDBCredentials(ctx context.Context, namespace, name string) (*DBLease, error) {
+func (c *Client) IssueDBCredentials(ctx context.Context, namespace, name, ttl string) (*DBLease, error) {
 	p := "/api/database-credentials/" + pathEscape(namespace) + "/" + pathEscape(name) + "/issue"
+	var body any
+	if ttl != "" {
+		body = map[string]string{"ttl": ttl}
+	}
 	var out DBLease
-	if err := c.do(ctx, "POST", p, nil, &out); err != nil {
+	if err := c.do(ctx, "POST", p, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Leases live under their own /api/database-leases/:ns family: lease
+// IDs are OpenBao paths (database/creds/<role>/<id>), so they travel in
+// the body rather than the URL, and renew/revoke need no policy name.
+
+// DBLeaseSummary is one outstanding lease. The password is never
+// listed.
+type DBLeaseSummary struct {
+	LeaseId    string `json:"leaseId"`
+	Policy     string `json:"policy"`
+	Username   string `json:"username,omitempty"`
+	IssueTime  string `json:"issueTime,omitempty"`
+	ExpireTime string `json:"expireTime,omitempty"`
+	TTL        int    `json:"ttl,omitempty"`
+	Renewable  bool   `json:"renewable,omitempty"`
+}
+
+type DBLeaseList struct {
+	Items []DBLeaseSummary `json:"items"`
+}
+
+// ListDBLeases lists the Project's outstanding leases, or one policy's
+// when policy is set.
+func (c *Client) ListDBLeases(ctx context.Context, namespace, policy string) (*DBLeaseList, error) {
+	p := "/api/database-leases/" + pathEscape(namespace)
+	if policy != "" {
+		p += "?policy=" + url.QueryEscape(policy)
+	}
+	var out DBLeaseList
+	if err := c.do(ctx, "GET", p, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DBLeasesAvailable reports whether the backend serves the lease
+// family at all. A backend without it answers 404 (no such route) or
+// 501; a lease issued there could be neither renewed nor revoked.
+func (c *Client) DBLeasesAvailable(ctx context.Context, namespace string) (bool, error) {
+	_, err := c.ListDBLeases(ctx, namespace, "")
+	var apiErr *APIError
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.As(err, &apiErr) && (apiErr.Status == http.StatusNotFound || apiErr.Status == http.StatusNotImplemented):
+		return false, nil
+	}
+	return false, err
+}
+
+// RenewDBLease extends a lease by increment seconds (0 = the policy's
+// TTL). OpenBao caps the result at the policy's max TTL, so the
+// returned LeaseDuration may be shorter than asked for. Username and
+// Password are empty.
+func (c *Client) RenewDBLease(ctx context.Context, namespace, leaseID string, increment int) (*DBLease, error) {
+	p := "/api/database-leases/" + pathEscape(namespace) + "/renew"
+	body := map[string]any{"leaseId": leaseID}
+	if increment > 0 {
+		body["increment"] = increment
+	}
+	var out DBLease
+	if err := c.do(ctx, "POST", p, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RevokeDBLeaseResult counts the leases revoked; OpenBao drops the
+// database users with them.
+type RevokeDBLeaseResult struct {
+	Revoked int `json:"revoked"`
+}
+
+// RevokeDBLeases revokes one lease, or with prefix=true every lease of
+// the Project whose ID starts with leaseID.
+func (c *Client) RevokeDBLeases(ctx context.Context, namespace, leaseID string, prefix bool) (*RevokeDBLeaseResult, error) {
+	if leaseID == "" {
+		return nil, fmt.Errorf("revoke: a lease ID or prefix is required")
+	}
+	p := "/api/database-leases/" + pathEscape(namespace) + "/revoke"
+	body := map[string]string{"leaseId": leaseID}
+	if prefix {
+		body = map[string]string{"prefix": leaseID}
+	}
+	var out RevokeDBLeaseResult
+	if err := c.do(ctx, "POST", p, body, &out); err != nil {
 		return nil, err
 	}
 	return &out, nil
@@ -0,0 +1,105 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Lease IDs are OpenBao paths with slashes, so they must travel in the
+// body, never the URL.
+func TestDBLeaseRequests(t *testing.T) {
+	type call struct{ Method, Path, Query, Body string }
+	var calls []call
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		calls = append(calls, call{r.Method, r.URL.Path, r.URL.RawQuery, string(body)})
+		switch r.URL.Path {
+		case "/api/database-credentials/acme-web/docs-pg-ro/issue":
+			_, _ = w.Write([]byte(`{"username":"v-oidc-ro-AbC","password":"pw","leaseId":"database/creds/ro/AbC","leaseDuration":900,"renewable":true}`))
+		case "/api/database-leases/acme-web/renew":
+			_, _ = w.Write([]byte(`{"leaseId":"database/creds/ro/AbC","leaseDuration":600,"renewable":true}`))
+		case "/api/database-leases/acme-web/revoke":
+			_, _ = w.Write([]byte(`{"revoked":3}`))
+		case "/api/database-leases/acme-web":
+			_, _ = w.Write([]byte(`{"items":[{"leaseId":"database/creds/ro/AbC","policy":"docs-pg-ro","ttl":600}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	c, _ := New("kube-dc.cloud", "tok", "", false)
+	c.BaseURL = srv.URL
+	ctx := context.Background()
+
+	lease, err := c.IssueDBCredentials(ctx, "acme-web", "docs-pg-ro", "900s")
+	if err != nil || lease.LeaseId != "database/creds/ro/AbC" || lease.LeaseDuration != 900 || !lease.Renewable {
+		t.Fatalf("issue = %+v, %v", lease, err)
+	}
+	if lease, err = c.RenewDBLease(ctx, "acme-web", lease.LeaseId, 900); err != nil || lease.LeaseDuration != 600 {
+		t.Fatalf("renew = %+v, %v", lease, err)
+	}
+	if res, err := c.RevokeDBLeases(ctx, "acme-web", "database/creds/ro/", true); err != nil || res.Revoked != 3 {
+		t.Fatalf("revoke = %+v, %v", res, err)
+	}
+	if list, err := c.ListDBLeases(ctx, "acme-web", "docs-pg-ro"); err != nil || len(list.Items) != 1 || list.Items[0].TTL != 600 {
+		t.Fatalf("list = %+v, %v", list, err)
+	}
+	if _, err := c.RevokeDBLeases(ctx, "acme-web", "", false); err == nil {
+		t.Error("an empty lease ID must be rejected before any request")
+	}
+
+	bodies := []map[string]any{{"ttl": "900s"}, {"leaseId": "database/creds/ro/AbC", "increment": 900.0}, {"prefix": "database/creds/ro/"}, nil}
+	if len(calls) != len(bodies) {
+		t.Fatalf("calls = %+v", calls)
+	}
+	for i, want := range bodies {
+		var got map[string]any
+		_ = json.Unmarshal([]byte(calls[i].Body), &got)
+		if len(got) != len(want) {
+			t.Errorf("call %d body = %s", i, calls[i].Body)
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("call %d %s = %v, want %v", i, k, got[k], v)
+			}
+		}
+	}
+	if calls[3].Method != "GET" || calls[3].Query != "policy=docs-pg-ro" {
+		t.Errorf("list call = %+v", calls[3])
+	}
+}
+
+func TestDBLeasesAvailable(t *testing.T) {
+	status := http.StatusNotFound
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+		}
+		_, _ = w.Write([]byte(`{"items":[]}`))
+	}))
+	defer srv.Close()
+	c, _ := New("kube-dc.cloud", "tok", "", false)
+	c.BaseURL = srv.URL
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		status  int
+		want    bool
+		wantErr bool
+	}{
+		{http.StatusNotFound, false, false},
+		{http.StatusNotImplemented, false, false},
+		{http.StatusOK, true, false},
+		{http.StatusForbidden, false, true},
+	} {
+		status = tc.status
+		ok, err := c.DBLeasesAvailable(ctx, "acme-web")
+		if ok != tc.want || (err != nil) != tc.wantErr {
+			t.Errorf("status %d: available = %v, err = %v", tc.status, ok, err)
+		}
+	}
+}
@@ -4,7 +4,10 @@ package backend
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 )
 
 // SecretSummary is the shape returned by GET /api/secrets/:ns and
@@ -94,6 +97,122 @@ func (c *Client) PutSecretValues(ctx context.Context, namespace, name string, da
 	return &out, nil
 }
 
+// PutSecretValuesCAS is PutSecretValues with a check-and-set
+// precondition: the write lands only if the current version is still
+// cas (0 means the secret must have no value yet). A mismatch comes
+// back as a *CASConflictError.
+func (c *Client) PutSecretValuesCAS(ctx context.Context, namespace, name string, data map[string]string, cas int) (*PutSecretValuesResult, error) {
+	return c.PutSecretValuesWithOptions(ctx, namespace, name, data, PutSecretOptions{CAS: &cas})
+}
+
+// PutSecretOptions are the optional parts of a value write.
+type PutSecretOptions struct {
+	// CAS, when set, is the check-and-set precondition.
+	CAS *int
+	// CustomMetadata is merged into the secret's custom metadata
+	// (secret-level in KV v2, not per version).
+	CustomMetadata map[string]string
+}
+
+func (c *Client) PutSecretValuesWithOptions(ctx context.Context, namespace, name string, data map[string]string, opts PutSecretOptions) (*PutSecretValuesResult, error) {
+	body := map[string]any{"data": data}
+	if opts.CAS != nil {
+		if *opts.CAS < 0 {
+			return nil, fmt.Errorf("put: cas version must be >= 0")
+		}
+		body["options"] = map[string]any{"cas": *opts.CAS}
+	}
+	if len(opts.CustomMetadata) > 0 {
+		body["customMetadata"] = opts.CustomMetadata
+	}
+	p := "/api/secrets/" + pathEscape(namespace) + "/" + pathEscape(name) + "/values"
+	var out PutSecretValuesResult
+	if err := c.do(ctx, "PUT", p, body, &out); err != nil {
+		if opts.CAS != nil {
+			return nil, asCASConflict(err, name, *opts.CAS)
+		}
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CASConflictError is a check-and-set write that lost: the secret was
+// no longer at Expected. Current is 0 when the backend did not report
+// the version it found.
+type CASConflictError struct {
+	Name     string
+	Expected int
+	Current  int
+	Err      *APIError
+}
+
+func (e *CASConflictError) Error() string {
+	if e.Current > 0 {
+		return fmt.Sprintf("conflict: %s is at v%d, but the write expected v%d; nothing written", e.Name, e.Current, e.Expected)
+	}
+	return fmt.Sprintf("conflict: %s is no longer at v%d; nothing written", e.Name, e.Expected)
+}
+
+func (e *CASConflictError) Unwrap() error { return e.Err }
+
+// asCASConflict recognises a lost check-and-set: 409 from the backend,
+// or the 400 OpenBao itself returns for a cas mismatch.
+func asCASConflict(err error, name string, expected int) error {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	if apiErr.Status != http.StatusConflict &&
+		!(apiErr.Status == http.StatusBadRequest && strings.Contains(apiErr.Message+apiErr.Raw, "check-and-set")) {
+		return err
+	}
+	conflict := &CASConflictError{Name: name, Expected: expected, Err: apiErr}
+	if v, ok := apiErr.Details["currentVersion"].(float64); ok {
+		conflict.Current = int(v)
+	}
+	return conflict
+}
+
+// SecretVersion is one KV version's metadata. DeletionTime is set
+// once the version is soft-deleted; Destroyed once its data is gone.
+type SecretVersion struct {
+	Version      int    `json:"version"`
+	CreatedTime  string `json:"createdTime,omitempty"`
+	DeletionTime string `json:"deletionTime,omitempty"`
+	Destroyed    bool   `json:"destroyed"`
+}
+
+// SecretVersionList is GET .../versions, newest first.
+type SecretVersionList struct {
+	Items          []SecretVersion `json:"items"`
+	CurrentVersion int             `json:"currentVersion"`
+	MaxVersions    int             `json:"maxVersions"`
+}
+
+func (c *Client) ListSecretVersions(ctx context.Context, namespace, name string) (*SecretVersionList, error) {
+	p := "/api/secrets/" + pathEscape(namespace) + "/" + pathEscape(name) + "/versions"
+	var out SecretVersionList
+	if err := c.do(ctx, "GET", p, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSecretVersion reads the data of one version. Like
+// ?includeValue=true it is a value read and is audited as one.
+func (c *Client) GetSecretVersion(ctx context.Context, namespace, name string, version int) (*SecretValue, error) {
+	if version <= 0 {
+		return nil, fmt.Errorf("get version: version must be > 0")
+	}
+	p := fmt.Sprintf("/api/secrets/%s/%s/versions/%d",
+		pathEscape(namespace), pathEscape(name), version)
+	var out SecretValue
+	if err := c.do(ctx, "GET", p, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
 // SyncOptions carries the partial-update payload for POST /sync. Use
 // pointer fields so the caller can distinguish "unset" from "set to
 // zero value" — only set fields are forwarded to the backend.
@@ -0,0 +1,119 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecretVersionRequests(t *testing.T) {
+	var putBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/secrets/acme-web/app-config/versions":
+			_, _ = w.Write([]byte(`{"currentVersion":5,"maxVersions":10,"items":[{"version":5,"createdTime":"2026-10-01T00:00:00Z"},{"version":4,"destroyed":true}]}`))
+		case "GET /api/secrets/acme-web/app-config/versions/3":
+			_, _ = w.Write([]byte(`{"data":{"K":"v3"},"metadata":{"version":3}}`))
+		case "PUT /api/secrets/acme-web/app-config/values":
+			b, _ := io.ReadAll(r.Body)
+			_ = json.Unmarshal(b, &putBody)
+			if putBody["options"].(map[string]any)["cas"] != 5.0 {
+				w.WriteHeader(http.StatusConflict)
+				_, _ = w.Write([]byte(`{"error":"check-and-set parameter did not match the current version","currentVersion":6}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"name":"app-config","version":6}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	c, _ := New("kube-dc.cloud", "tok", "", false)
+	c.BaseURL = srv.URL
+	ctx := context.Background()
+
+	list, err := c.ListSecretVersions(ctx, "acme-web", "app-config")
+	if err != nil || list.CurrentVersion != 5 || len(list.Items) != 2 || !list.Items[1].Destroyed {
+		t.Fatalf("versions = %+v, %v", list, err)
+	}
+	v, err := c.GetSecretVersion(ctx, "acme-web", "app-config", 3)
+	if err != nil || v.Data["K"] != "v3" || v.Metadata.Version != 3 {
+		t.Fatalf("version 3 = %+v, %v", v, err)
+	}
+	if _, err := c.GetSecretVersion(ctx, "acme-web", "app-config", 0); err == nil {
+		t.Error("version 0 must be rejected before any request")
+	}
+
+	res, err := c.PutSecretValuesCAS(ctx, "acme-web", "app-config", map[string]string{"K": "v3"}, 5)
+	if err != nil || res.Version != 6 {
+		t.Fatalf("cas put = %+v, %v", res, err)
+	}
+	if putBody["data"].(map[string]any)["K"] != "v3" {
+		t.Errorf("put body = %v", putBody)
+	}
+	_, err = c.PutSecretValuesCAS(ctx, "acme-web", "app-config", map[string]string{"K": "v3"}, 4)
+	var conflict *CASConflictError
+	if !errors.As(err, &conflict) || conflict.Expected != 4 || conflict.Current != 6 {
+		t.Errorf("stale cas err = %v; want a conflict at v6 expecting v4", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusConflict {
+		t.Errorf("conflict does not unwrap to the 409: %v", err)
+	}
+}
+
+// OpenBao's own cas mismatch is a 400 with no version in the body.
+func TestAsCASConflict_OpenBao400(t *testing.T) {
+	err := asCASConflict(&APIError{Status: 400, Message: "check-and-set parameter did not match the current version"}, "app-config", 5)
+	var conflict *CASConflictError
+	if !errors.As(err, &conflict) || conflict.Current != 0 {
+		t.Fatalf("err = %v; want a conflict with unknown current version", err)
+	}
+	if got, want := err.Error(), "conflict: app-config is no longer at v5; nothing written"; got != want {
+		t.Errorf("Error() = %q; want %q", got, want)
+	}
+	other := &APIError{Status: 400, Message: "data is required"}
+	if asCASConflict(other, "app-config", 5) != error(other) {
+		t.Error("an unrelated 400 must pass through unchanged")
+	}
+}
+
+func TestPutSecretValuesWithOptions_Body(t *testing.T) {
+	var bodies []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var b map[string]any
+		raw, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(raw, &b)
+		bodies = append(bodies, b)
+		_, _ = w.Write([]byte(`{"version":4}`))
+	}))
+	defer srv.Close()
+	c, _ := New("kube-dc.cloud", "tok", "", false)
+	c.BaseURL = srv.URL
+	ctx := context.Background()
+
+	cas := 3
+	if _, err := c.PutSecretValuesWithOptions(ctx, "acme-prod", "app-config", map[string]string{"K": "v"},
+		PutSecretOptions{CAS: &cas, CustomMetadata: map[string]string{"promoted-from-project": "stage"}}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, err := c.PutSecretValuesWithOptions(ctx, "acme-prod", "app-config", map[string]string{"K": "v"}, PutSecretOptions{}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if got := bodies[0]["options"].(map[string]any)["cas"]; got != 3.0 {
+		t.Errorf("cas = %v; want 3", got)
+	}
+	if got := bodies[0]["customMetadata"].(map[string]any)["promoted-from-project"]; got != "stage" {
+		t.Errorf("customMetadata = %v", bodies[0]["customMetadata"])
+	}
+	if _, ok := bodies[1]["options"]; ok {
+		t.Errorf("no-options write sent options: %v", bodies[1])
+	}
+	if _, ok := bodies[1]["customMetadata"]; ok {
+		t.Errorf("no-options write sent customMetadata: %v", bodies[1])
+	}
+}
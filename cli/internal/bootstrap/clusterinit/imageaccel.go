@@ -52,6 +52,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/shalb/kube-dc/cli/internal/filelock"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -296,7 +297,7 @@ func sopsEncryptToFile(fleetRepo, finalPath, plaintext string, mustNotLeak []str
 	// (codex pass-5, HIGH). The lock lives in the OS temp dir, keyed by the
 	// absolute artifact path: flock is machine-local either way, and a lock
 	// file inside the overlay would end up committed by wholesale git adds.
-	release, err := lockFile(sopsLockPath(finalPath))
+	release, err := filelock.Lock(sopsLockPath(finalPath))
 	if err != nil {
 		return err
 	}
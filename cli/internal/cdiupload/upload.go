@@ -0,0 +1,164 @@
+// Package cdiupload streams a disk image to the CDI upload proxy for a
+// DataVolume in phase UploadReady, authorised by an UploadTokenRequest
+// token (see k8sapi.CreateUploadToken).
+//
+// The proxy takes an image as one request body and cannot continue a
+// broken one, so "resume" happens at two levels: a failed attempt is
+// retried from the first byte (with a fresh token when the old one was
+// refused), and `vm import` skips disks whose DataVolume already
+// Succeeded, so re-running it after an interruption only re-sends the
+// disk that was in flight.
+package cdiupload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// uploadPath is the proxy's synchronous endpoint: it answers once the
+// upload server has the whole image and has started processing it.
+const uploadPath = "/v1beta1/upload"
+
+// Client talks to one upload proxy.
+type Client struct {
+	URL        string // e.g. https://cdi-uploadproxy.kube-dc.cloud
+	ChunkSize  int
+	Attempts   int
+	RetryDelay time.Duration
+	http       *http.Client
+}
+
+// New builds a Client for the proxy at proxyURL. Uploads have no
+// overall timeout; cancel the context to stop one.
+func New(proxyURL string) *Client {
+	return &Client{
+		URL:        strings.TrimRight(proxyURL, "/"),
+		ChunkSize:  8 << 20,
+		Attempts:   5,
+		RetryDelay: 5 * time.Second,
+		http:       &http.Client{Transport: http.DefaultTransport},
+	}
+}
+
+// Progress is called after every chunk with the bytes sent so far in
+// the current attempt; sent restarts at 0 when an attempt is retried.
+type Progress func(sent, total int64)
+
+// TokenFunc returns an upload token; it is called again before a retry
+// when the proxy refused the previous one.
+type TokenFunc func(ctx context.Context) (string, error)
+
+// Error is a non-2xx answer from the proxy.
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("upload proxy: HTTP %d: %s", e.Status, e.Message)
+}
+
+// retryable reports whether another attempt may succeed: transport
+// failures, and the proxy's answers while the upload pod is starting
+// or restarting.
+func retryable(err error) bool {
+	var pe *Error
+	if errors.As(err, &pe) {
+		switch pe.Status {
+		case http.StatusUnauthorized, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// Upload sends size bytes of src, retrying retryable failures from the
+// start up to c.Attempts times.
+func (c *Client) Upload(ctx context.Context, token TokenFunc, src io.ReaderAt, size int64, progress Progress) error {
+	tok, err := token(ctx)
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	for attempt := 1; attempt <= c.Attempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.RetryDelay):
+			}
+			var pe *Error
+			if errors.As(lastErr, &pe) && pe.Status == http.StatusUnauthorized {
+				if tok, err = token(ctx); err != nil {
+					return err
+				}
+			}
+		}
+		lastErr = c.attempt(ctx, tok, src, size, progress)
+		if lastErr == nil || !retryable(lastErr) {
+			return lastErr
+		}
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", c.Attempts, lastErr)
+}
+
+func (c *Client) attempt(ctx context.Context, token string, src io.ReaderAt, size int64, progress Progress) error {
+	body := &chunkReader{src: src, size: size, chunk: c.ChunkSize, progress: progress}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.URL+uploadPath, body)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("POST %s: %w", c.URL+uploadPath, err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &Error{Status: resp.StatusCode, Message: strings.TrimSpace(string(raw))}
+	}
+	return nil
+}
+
+// chunkReader reads src in chunk-sized pieces and reports progress
+// after each one.
+type chunkReader struct {
+	src      io.ReaderAt
+	size     int64
+	off      int64
+	chunk    int
+	progress Progress
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if r.off >= r.size {
+		return 0, io.EOF
+	}
+	if len(p) > r.chunk {
+		p = p[:r.chunk]
+	}
+	if rem := r.size - r.off; int64(len(p)) > rem {
+		p = p[:rem]
+	}
+	n, err := r.src.ReadAt(p, r.off)
+	r.off += int64(n)
+	if errors.Is(err, io.EOF) {
+		if r.off < r.size {
+			return n, io.ErrUnexpectedEOF
+		}
+		err = nil
+	}
+	if n > 0 && r.progress != nil {
+		r.progress(r.off, r.size)
+	}
+	return n, err
+}
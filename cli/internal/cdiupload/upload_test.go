@@ -0,0 +1,143 @@
+package cdiupload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// proxy is a stand-in for cdi-uploadproxy: it checks the token, answers
+// the first `unavailable` requests with 503 (as the real proxy does
+// while the upload pod is starting) and records what it received.
+type proxy struct {
+	mu          sync.Mutex
+	token       string
+	unavailable int
+	requests    int
+	got         []byte
+}
+
+func (p *proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests++
+	if r.Method != "POST" || r.URL.Path != "/v1beta1/upload" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+p.token {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	if r.ContentLength != int64(len(body)) {
+		http.Error(w, "short body", http.StatusBadRequest)
+		return
+	}
+	if p.unavailable > 0 {
+		p.unavailable--
+		http.Error(w, "upload server not ready", http.StatusServiceUnavailable)
+		return
+	}
+	p.got = body
+}
+
+func testClient(url string) *Client {
+	c := New(url)
+	c.ChunkSize = 1000
+	c.RetryDelay = 0
+	return c
+}
+
+func staticToken(tok string) TokenFunc {
+	return func(context.Context) (string, error) { return tok, nil }
+}
+
+func TestUpload_RetriesAndReportsProgress(t *testing.T) {
+	p := &proxy{token: "t0k", unavailable: 2}
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	image := bytes.Repeat([]byte("0123456789"), 550)
+	var sent []int64
+	err := testClient(srv.URL).Upload(context.Background(), staticToken("t0k"), bytes.NewReader(image), int64(len(image)), func(n, total int64) {
+		if total != int64(len(image)) {
+			t.Errorf("total = %d", total)
+		}
+		sent = append(sent, n)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(p.got, image) || p.requests != 3 {
+		t.Errorf("proxy got %d bytes in %d requests", len(p.got), p.requests)
+	}
+	// Three attempts of six chunks each; the last one ends on the full size.
+	if len(sent) != 18 || sent[5] != 5500 || sent[6] != 1000 || sent[17] != 5500 {
+		t.Errorf("progress = %v", sent)
+	}
+}
+
+func TestUpload_RefreshesRejectedToken(t *testing.T) {
+	p := &proxy{token: "fresh"}
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	calls := 0
+	token := func(context.Context) (string, error) {
+		calls++
+		if calls == 1 {
+			return "expired", nil
+		}
+		return "fresh", nil
+	}
+	if err := testClient(srv.URL).Upload(context.Background(), token, strings.NewReader("abc"), 3, nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 || string(p.got) != "abc" {
+		t.Errorf("token calls = %d, got %q", calls, p.got)
+	}
+}
+
+func TestUpload_PermanentFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "PVC is not ready for upload", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	err := testClient(srv.URL).Upload(context.Background(), staticToken("x"), strings.NewReader("abc"), 3, nil)
+	var pe *Error
+	if !errors.As(err, &pe) || pe.Status != http.StatusBadRequest || !strings.Contains(err.Error(), "not ready for upload") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestUpload_GivesUp(t *testing.T) {
+	p := &proxy{token: "t", unavailable: 100}
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	c := testClient(srv.URL)
+	c.Attempts = 3
+	err := c.Upload(context.Background(), staticToken("t"), strings.NewReader("abc"), 3, nil)
+	if err == nil || !strings.Contains(err.Error(), "giving up after 3 attempts") || p.requests != 3 {
+		t.Errorf("err = %v after %d requests", err, p.requests)
+	}
+}
+
+func TestUpload_ShortSource(t *testing.T) {
+	p := &proxy{token: "t"}
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	err := testClient(srv.URL).Upload(context.Background(), staticToken("t"), strings.NewReader("abc"), 10, nil)
+	if err == nil || p.got != nil {
+		t.Errorf("a source shorter than size must fail, got %v", err)
+	}
+}
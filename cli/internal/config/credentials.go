@@ -3,13 +3,20 @@ package config
 import (
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/filelock"
 )
 
+// ErrNoCredentials is wrapped by Load and LoadForRealm when nothing is
+// stored for the server (and realm).
+var ErrNoCredentials = errors.New("no credentials found")
+
 // Credentials stores the cached authentication tokens for a server
 type Credentials struct {
 	Server             string    `json:"server"`
@@ -26,6 +33,21 @@ type Credentials struct {
 	Insecure           bool      `json:"insecure,omitempty"`
 	CreatedAt          time.Time `json:"created_at"`
 	UpdatedAt          time.Time `json:"updated_at"`
+
+	// ServiceAccount is set for non-interactive (CI) logins. Such tokens
+	// usually come without a refresh token, so the exec plugin re-mints
+	// from this instead of sending the user back to `kube-dc login`.
+	ServiceAccount *ServiceAccount `json:"service_account,omitempty"`
+}
+
+// ServiceAccount records how a non-interactive login obtained its token.
+// Secrets are kept as file paths, never values: the credential cache must not
+// become a second copy of the CI secret.
+type ServiceAccount struct {
+	GrantType        string `json:"grant_type"`
+	ClientSecretFile string `json:"client_secret_file,omitempty"`
+	SubjectTokenFile string `json:"subject_token_file,omitempty"`
+	SubjectIssuer    string `json:"subject_issuer,omitempty"`
 }
 
 // UserInfo contains user details extracted from the JWT token
@@ -39,6 +61,11 @@ type UserInfo struct {
 // CredentialsManager handles loading and saving credentials
 type CredentialsManager struct {
 	baseDir string
+
+	// sealer encrypts files at rest; nil for a plaintext store. Loaded from
+	// the store's encryption settings on first use.
+	sealer       sealer
+	sealerLoaded bool
 }
 
 // NewCredentialsManager creates a new credentials manager
@@ -63,7 +90,7 @@ func NewCredentialsManager() (*CredentialsManager, error) {
 // New callers must use LoadForRealm.
 func (m *CredentialsManager) Load(server string) (*Credentials, error) {
 	// Legacy path: single <server-hash>.json file (no realm in name).
-	if c, err := readCredentials(m.legacyPath(server)); err == nil {
+	if c, err := m.read(m.legacyPath(server)); err == nil {
 		return c, nil
 	} else if !os.IsNotExist(err) {
 		return nil, err
@@ -74,7 +101,7 @@ func (m *CredentialsManager) Load(server string) (*Credentials, error) {
 		return nil, err
 	}
 	if len(matches) == 0 {
-		return nil, fmt.Errorf("no credentials found for %s", server)
+		return nil, fmt.Errorf("%w for %s", ErrNoCredentials, server)
 	}
 	if len(matches) == 1 {
 		// Exactly one identity is cached for this server, so there is nothing to
@@ -82,7 +109,7 @@ func (m *CredentialsManager) Load(server string) (*Credentials, error) {
 		// written before contexts carried --realm: those users would find each
 		// kubectl call failing after a CLI upgrade until they logged in again,
 		// which is a worse outcome than using the one credential that exists.
-		return readCredentials(matches[0])
+		return m.read(matches[0])
 	}
 	// Two or more identities (e.g. an Organization login and a platform-admin
 	// login against the same cluster). Guessing would run kubectl as the wrong
@@ -99,19 +126,19 @@ func (m *CredentialsManager) LoadForRealm(server, realm string) (*Credentials, e
 		return m.Load(server)
 	}
 	// New realm-aware path first.
-	if c, err := readCredentials(m.realmPath(server, realm)); err == nil {
+	if c, err := m.read(m.realmPath(server, realm)); err == nil {
 		return c, nil
 	} else if !os.IsNotExist(err) {
 		return nil, err
 	}
 	// Fallback to the legacy file ONLY if its embedded realm matches —
 	// otherwise we'd hand a tenant token to an admin context.
-	if c, err := readCredentials(m.legacyPath(server)); err == nil {
+	if c, err := m.read(m.legacyPath(server)); err == nil {
 		if c.Realm == realm {
 			return c, nil
 		}
 	}
-	return nil, fmt.Errorf("no credentials found for %s realm=%s", server, realm)
+	return nil, fmt.Errorf("%w for %s realm=%s", ErrNoCredentials, server, realm)
 }
 
 // Save saves credentials for a (server, realm) pair. Older callers that
@@ -124,20 +151,39 @@ func (m *CredentialsManager) Save(creds *Credentials) error {
 		creds.CreatedAt = creds.UpdatedAt
 	}
 
-	data, err := json.MarshalIndent(creds, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal credentials: %w", err)
-	}
-
 	filePath := m.legacyPath(creds.Server)
 	if creds.Realm != "" {
 		filePath = m.realmPath(creds.Server, creds.Realm)
 	}
-	if err := os.WriteFile(filePath, data, 0600); err != nil {
-		return fmt.Errorf("failed to write credentials: %w", err)
+	return m.write(filePath, creds)
+}
+
+// Lock serialises token refreshes for one (server, realm) identity across
+// processes. Callers must re-read the credentials after it returns: whoever
+// held the lock before may already have refreshed, spending the refresh token
+// the caller loaded earlier.
+func (m *CredentialsManager) Lock(server, realm string) (release func(), err error) {
+	name := serverHash(server) + ".lock"
+	if realm != "" {
+		name = serverHash(server) + "-" + slugify(realm) + ".lock"
 	}
+	return filelock.Lock(filepath.Join(m.baseDir, name))
+}
 
-	return nil
+// Fingerprint identifies the current contents of the credential file
+// LoadForRealm would read: a hash of its bytes as stored, so nothing is
+// decrypted. Any rewrite — a new login, a refresh by another process — gives
+// a new fingerprint, however coarse the filesystem's timestamps are.
+func (m *CredentialsManager) Fingerprint(server, realm string) (string, error) {
+	data, err := os.ReadFile(m.realmPath(server, realm))
+	if realm == "" || os.IsNotExist(err) {
+		data, err = os.ReadFile(m.legacyPath(server))
+	}
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:]), nil
 }
 
 // Delete removes the legacy file plus every realm-specific file for the
@@ -171,35 +217,53 @@ func (m *CredentialsManager) DeleteForRealm(server, realm string) error {
 	return nil
 }
 
-// List lists all saved credentials
-func (m *CredentialsManager) List() ([]*Credentials, error) {
-	entries, err := os.ReadDir(m.baseDir)
+// UnreadableCredential is a credential file List could not decode — sealed
+// with a key that is no longer available, or corrupt. Its file name still
+// says which server it belongs to, so it can be removed without reading it.
+type UnreadableCredential struct {
+	Path string
+	Err  error
+}
+
+// ForServer reports whether the file is the legacy or a realm-scoped entry
+// for server.
+func (u UnreadableCredential) ForServer(server string) bool {
+	name := filepath.Base(u.Path)
+	return name == serverHash(server)+".json" || strings.HasPrefix(name, serverHash(server)+"-")
+}
+
+// List lists all saved credentials. Files that cannot be decoded are
+// returned separately rather than dropped, so callers such as logout can
+// still see — and remove — them.
+func (m *CredentialsManager) List() ([]*Credentials, []UnreadableCredential, error) {
+	paths, err := m.credentialPaths()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
-		}
-		return nil, fmt.Errorf("failed to list credentials: %w", err)
+		return nil, nil, err
 	}
 
 	var creds []*Credentials
-	for _, entry := range entries {
-		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
-			continue
-		}
-
-		data, err := os.ReadFile(filepath.Join(m.baseDir, entry.Name()))
+	var unreadable []UnreadableCredential
+	for _, p := range paths {
+		c, err := m.read(p)
 		if err != nil {
+			unreadable = append(unreadable, UnreadableCredential{Path: p, Err: err})
 			continue
 		}
-
-		var c Credentials
-		if err := json.Unmarshal(data, &c); err != nil {
-			continue
-		}
-		creds = append(creds, &c)
+		creds = append(creds, c)
 	}
 
-	return creds, nil
+	return creds, unreadable, nil
+}
+
+// RemoveUnreadable deletes a file List reported as unreadable.
+func (m *CredentialsManager) RemoveUnreadable(u UnreadableCredential) error {
+	if filepath.Dir(u.Path) != m.baseDir {
+		return fmt.Errorf("%s is not in the credentials directory", u.Path)
+	}
+	if err := os.Remove(u.Path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete %s: %w", u.Path, err)
+	}
+	return nil
 }
 
 // IsAccessTokenValid checks if the access token is still valid
@@ -281,16 +345,3 @@ func slugify(s string) string {
 	}
 	return string(out)
 }
-
-// readCredentials is the shared decoder used by every Load* path.
-func readCredentials(path string) (*Credentials, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-	var creds Credentials
-	if err := json.Unmarshal(data, &creds); err != nil {
-		return nil, fmt.Errorf("parse %s: %w", path, err)
-	}
-	return &creds, nil
-}
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Encryption modes for the credential cache. The empty mode is the historical
+// plaintext layout and stays the default: turning encryption on is an explicit
+// `kube-dc config encrypt-credentials`, because two of the three modes need
+// something from the user (an identity file, a passphrase) that we cannot
+// invent for them.
+const (
+	EncryptionNone       = ""
+	EncryptionAge        = "age"        // external `age` binary, identity file on disk or on a plugin device
+	EncryptionPassphrase = "passphrase" // scrypt-derived key, cached for the login session
+	EncryptionHelper     = "helper"     // key held by a git-credential-style helper (OS keychain, pass, ...)
+)
+
+// encryptionFile holds the store's Encryption settings. It lives next to the
+// credential files but deliberately not with a .json extension: List and
+// realmMatches treat every *.json in the directory as a credential.
+const encryptionFile = "encryption.conf"
+
+// Encryption describes how the files in a credentials directory are sealed.
+// It never holds key material — only where to get it from.
+type Encryption struct {
+	Mode string `json:"mode"`
+
+	// AgeIdentity is the identity file passed to `age -i`. Files are
+	// encrypted to that identity's own recipient.
+	AgeIdentity string `json:"age_identity,omitempty"`
+
+	// Helper is run as `<helper> get` / `<helper> store` through the shell,
+	// speaking the git-credential key=value protocol.
+	Helper string `json:"helper,omitempty"`
+
+	// Salt and Check belong to the passphrase mode: one salt per store so a
+	// single derived key (and a single prompt) unlocks every file, and a
+	// sealed known value so a wrong passphrase is reported as such instead
+	// of as N undecryptable files.
+	Salt  []byte `json:"salt,omitempty"`
+	Check []byte `json:"check,omitempty"`
+}
+
+// envelope is the on-disk shape of an encrypted credential file. Still JSON,
+// so the .json name stays honest and a plaintext file is told apart from an
+// encrypted one by content, which is what makes lazy migration possible.
+type envelope struct {
+	Encrypted string `json:"kube_dc_encrypted"`
+	Data      []byte `json:"data"`
+}
+
+// EncryptionSettings returns the store's encryption settings; a store that
+// was never configured reports EncryptionNone.
+func (m *CredentialsManager) EncryptionSettings() (*Encryption, error) {
+	data, err := os.ReadFile(filepath.Join(m.baseDir, encryptionFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Encryption{Mode: EncryptionNone}, nil
+		}
+		return nil, fmt.Errorf("read credential encryption settings: %w", err)
+	}
+	var enc Encryption
+	if err := json.Unmarshal(data, &enc); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", filepath.Join(m.baseDir, encryptionFile), err)
+	}
+	return &enc, nil
+}
+
+// SetEncryption switches the store to enc and rewrites every credential file
+// under it, returning how many were rewritten. Files are all read with the
+// current settings before anything is written, so a file that cannot be
+// decrypted aborts the switch with the store unchanged.
+//
+// For the passphrase mode, enc.Salt and enc.Check are filled in here from a
+// newly prompted passphrase.
+func (m *CredentialsManager) SetEncryption(enc *Encryption) (int, error) {
+	paths, err := m.credentialPaths()
+	if err != nil {
+		return 0, err
+	}
+	all := make(map[string]*Credentials, len(paths))
+	for _, p := range paths {
+		c, err := m.read(p)
+		if err != nil {
+			return 0, fmt.Errorf("%w (nothing was changed)", err)
+		}
+		all[p] = c
+	}
+
+	next, err := newSealer(enc, m.baseDir, true)
+	if err != nil {
+		return 0, err
+	}
+
+	settingsPath := filepath.Join(m.baseDir, encryptionFile)
+	if enc.Mode == EncryptionNone {
+		if err := os.Remove(settingsPath); err != nil && !os.IsNotExist(err) {
+			return 0, fmt.Errorf("remove encryption settings: %w", err)
+		}
+	} else {
+		data, err := json.MarshalIndent(enc, "", "  ")
+		if err != nil {
+			return 0, err
+		}
+		if err := os.WriteFile(settingsPath, data, 0600); err != nil {
+			return 0, fmt.Errorf("write encryption settings: %w", err)
+		}
+	}
+	m.sealer, m.sealerLoaded = next, true
+
+	for p, c := range all {
+		if err := m.write(p, c); err != nil {
+			return 0, err
+		}
+	}
+	return len(all), nil
+}
+
+// LockEncryption forgets the session-cached passphrase key, so the next use
+// of a passphrase-encrypted store prompts again. A no-op for other modes.
+func (m *CredentialsManager) LockEncryption() error {
+	enc, err := m.EncryptionSettings()
+	if err != nil {
+		return err
+	}
+	if enc.Mode != EncryptionPassphrase {
+		return nil
+	}
+	return forgetSessionKey(enc.Salt)
+}
+
+// UnlockEncryption obtains the store's key now — prompting if needed — so
+// that later non-interactive callers, such as the kubectl exec plugin, find
+// it in the session cache.
+func (m *CredentialsManager) UnlockEncryption() error {
+	s, err := m.currentSealer()
+	if err != nil || s == nil {
+		return err
+	}
+	return s.unlock()
+}
+
+// currentSealer loads the store's sealer once per manager. nil means the
+// store is plaintext.
+func (m *CredentialsManager) currentSealer() (sealer, error) {
+	if m.sealerLoaded {
+		return m.sealer, nil
+	}
+	enc, err := m.EncryptionSettings()
+	if err != nil {
+		return nil, err
+	}
+	s, err := newSealer(enc, m.baseDir, false)
+	if err != nil {
+		return nil, err
+	}
+	m.sealer, m.sealerLoaded = s, true
+	return s, nil
+}
+
+// write stores creds at path, sealed when the store is encrypted.
+func (m *CredentialsManager) write(path string, creds *Credentials) error {
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal credentials: %w", err)
+	}
+	s, err := m.currentSealer()
+	if err != nil {
+		return err
+	}
+	if s != nil {
+		sealed, err := s.seal(data)
+		if err != nil {
+			return fmt.Errorf("encrypt credentials: %w", err)
+		}
+		data, err = json.MarshalIndent(envelope{Encrypted: s.mode(), Data: sealed}, "", "  ")
+		if err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+	return nil
+}
+
+// read is the shared decoder used by every Load* path. A plaintext file found
+// in an encrypted store is re-written sealed on the way through, so files from
+// before encryption was turned on migrate the first time they are used.
+func (m *CredentialsManager) read(path string) (*Credentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	s, err := m.currentSealer()
+	if err != nil {
+		return nil, err
+	}
+
+	if env.Encrypted != "" {
+		if s == nil || s.mode() != env.Encrypted {
+			return nil, fmt.Errorf("%s is encrypted with %q but the credential store is configured for %q; "+
+				"run kube-dc config encrypt-credentials with the original settings, or log in again",
+				path, env.Encrypted, modeName(s))
+		}
+		data, err = s.open(env.Data)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt %s: %w", path, err)
+		}
+	}
+
+	var creds Credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	if env.Encrypted == "" && s != nil {
+		// Best effort: a failed migration leaves a readable plaintext file,
+		// which is no worse than before encryption was configured.
+		_ = m.write(path, &creds)
+	}
+	return &creds, nil
+}
+
+// credentialPaths lists every credential file in the store, legacy and
+// realm-scoped alike.
+func (m *CredentialsManager) credentialPaths() ([]string, error) {
+	entries, err := os.ReadDir(m.baseDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list credentials: %w", err)
+	}
+	var out []string
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		out = append(out, filepath.Join(m.baseDir, e.Name()))
+	}
+	return out, nil
+}
+
+func modeName(s sealer) string {
+	if s == nil {
+		return "plaintext"
+	}
+	return s.mode()
+}
@@ -0,0 +1,349 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const testServer = "https://kube-api.example.com:6443"
+
+// stubPassphrase answers every prompt with pass and counts the prompts, so a
+// test can tell a session-cache hit from a re-prompt.
+func stubPassphrase(t *testing.T, pass string) *int {
+	t.Helper()
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	t.Setenv(PassphraseEnv, "")
+	prompts := 0
+	orig := readPassphrase
+	readPassphrase = func(string) ([]byte, error) {
+		prompts++
+		return []byte(pass), nil
+	}
+	t.Cleanup(func() { readPassphrase = orig })
+	return &prompts
+}
+
+func assertNoPlaintextTokens(t *testing.T, m *CredentialsManager) {
+	t.Helper()
+	paths, err := m.credentialPaths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(data, []byte("rt-")) || bytes.Contains(data, []byte("at-")) {
+			t.Errorf("%s still holds a plaintext token:\n%s", filepath.Base(p), data)
+		}
+	}
+}
+
+func TestSetEncryption_PassphraseMigratesAndLoadsTransparently(t *testing.T) {
+	prompts := stubPassphrase(t, "correct horse")
+	dir := t.TempDir()
+	m := &CredentialsManager{baseDir: dir}
+	for _, realm := range []string{"shalb", "master"} {
+		if err := m.Save(sampleCreds(testServer, realm)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := m.SetEncryption(&Encryption{Mode: EncryptionPassphrase})
+	if err != nil {
+		t.Fatalf("SetEncryption: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("rewrote %d files, want 2", n)
+	}
+	assertNoPlaintextTokens(t, m)
+
+	// A fresh manager — the next kubectl call — must find the key in the
+	// session cache and never prompt.
+	*prompts = 0
+	fresh := &CredentialsManager{baseDir: dir}
+	got, err := fresh.LoadForRealm(testServer, "shalb")
+	if err != nil {
+		t.Fatalf("LoadForRealm: %v", err)
+	}
+	if got.RefreshToken != "rt-shalb" {
+		t.Errorf("refresh token = %q, want rt-shalb", got.RefreshToken)
+	}
+	all, unreadable, err := fresh.List()
+	if err != nil || len(all) != 2 || len(unreadable) != 0 {
+		t.Errorf("List = %d entries, err %v; want 2 (encryption.conf must not be listed)", len(all), err)
+	}
+	if *prompts != 0 {
+		t.Errorf("prompted %d times with the key cached for the session", *prompts)
+	}
+
+	// After lock the next reader has to ask again.
+	if err := fresh.LockEncryption(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&CredentialsManager{baseDir: dir}).LoadForRealm(testServer, "master"); err != nil {
+		t.Fatalf("LoadForRealm after lock: %v", err)
+	}
+	if *prompts != 1 {
+		t.Errorf("prompts after lock = %d, want 1", *prompts)
+	}
+}
+
+func TestPassphrase_WrongPassphraseIsNamed(t *testing.T) {
+	stubPassphrase(t, "right")
+	dir := t.TempDir()
+	m := &CredentialsManager{baseDir: dir}
+	if err := m.Save(sampleCreds(testServer, "shalb")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SetEncryption(&Encryption{Mode: EncryptionPassphrase}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.LockEncryption(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(PassphraseEnv, "wrong")
+	_, err := (&CredentialsManager{baseDir: dir}).LoadForRealm(testServer, "shalb")
+	if err == nil || !strings.Contains(err.Error(), "wrong credential store passphrase") {
+		t.Fatalf("err = %v, want a wrong-passphrase error", err)
+	}
+}
+
+// A session key another user could read, or a RuntimeDir they could enter,
+// is not trusted: the store asks for the passphrase again.
+func TestPassphrase_SessionKeyMustBePrivate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("mode bits are not enforced on Windows")
+	}
+	prompts := stubPassphrase(t, "pw")
+	dir := t.TempDir()
+	m := &CredentialsManager{baseDir: dir}
+	if err := m.Save(sampleCreds(testServer, "shalb")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SetEncryption(&Encryption{Mode: EncryptionPassphrase}); err != nil {
+		t.Fatal(err)
+	}
+	enc, err := m.EncryptionSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(sessionKeyPath(enc.Salt), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	*prompts = 0
+	if _, err := (&CredentialsManager{baseDir: dir}).LoadForRealm(testServer, "shalb"); err != nil {
+		t.Fatalf("LoadForRealm: %v", err)
+	}
+	if *prompts != 1 {
+		t.Errorf("prompts = %d with a world-readable session key, want 1", *prompts)
+	}
+
+	// The re-prompt stored a private key again; an open directory still
+	// disqualifies it.
+	if !privateFile(sessionKeyPath(enc.Salt)) {
+		t.Fatal("re-prompt did not store a private session key")
+	}
+	if err := os.Chmod(RuntimeDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	*prompts = 0
+	if _, err := (&CredentialsManager{baseDir: dir}).LoadForRealm(testServer, "shalb"); err != nil {
+		t.Fatalf("LoadForRealm: %v", err)
+	}
+	if *prompts != 1 {
+		t.Errorf("prompts = %d with an open RuntimeDir, want 1", *prompts)
+	}
+}
+
+// Under kubectl there is no terminal to prompt on; the error has to say how
+// to supply the key instead.
+func TestPassphrase_NoTerminalExplainsHowToUnlock(t *testing.T) {
+	stubPassphrase(t, "pw")
+	dir := t.TempDir()
+	m := &CredentialsManager{baseDir: dir}
+	if err := m.Save(sampleCreds(testServer, "shalb")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SetEncryption(&Encryption{Mode: EncryptionPassphrase}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.LockEncryption(); err != nil {
+		t.Fatal(err)
+	}
+	readPassphrase = func(string) ([]byte, error) { return nil, errors.New("open /dev/tty: no such device") }
+
+	_, err := (&CredentialsManager{baseDir: dir}).LoadForRealm(testServer, "shalb")
+	if err == nil || !strings.Contains(err.Error(), PassphraseEnv) || !strings.Contains(err.Error(), "unlock-credentials") {
+		t.Fatalf("err = %v, want it to name %s and unlock-credentials", err, PassphraseEnv)
+	}
+}
+
+// A plaintext file that appears in an encrypted store — written by an older
+// CLI, or restored from a backup — is readable and gets sealed on first read.
+func TestRead_MigratesPlaintextFileLazily(t *testing.T) {
+	stubPassphrase(t, "pw")
+	dir := t.TempDir()
+	m := &CredentialsManager{baseDir: dir}
+	if _, err := m.SetEncryption(&Encryption{Mode: EncryptionPassphrase}); err != nil {
+		t.Fatal(err)
+	}
+
+	plain := &CredentialsManager{baseDir: dir, sealerLoaded: true} // writes plaintext, like an old CLI
+	if err := plain.Save(sampleCreds(testServer, "shalb")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := m.LoadForRealm(testServer, "shalb")
+	if err != nil {
+		t.Fatalf("LoadForRealm: %v", err)
+	}
+	if got.AccessToken != "at-shalb" {
+		t.Errorf("access token = %q", got.AccessToken)
+	}
+	assertNoPlaintextTokens(t, m)
+}
+
+func TestSetEncryption_DecryptRestoresPlaintext(t *testing.T) {
+	stubPassphrase(t, "pw")
+	dir := t.TempDir()
+	m := &CredentialsManager{baseDir: dir}
+	if err := m.Save(sampleCreds(testServer, "shalb")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SetEncryption(&Encryption{Mode: EncryptionPassphrase}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SetEncryption(&Encryption{Mode: EncryptionNone}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, encryptionFile)); !os.IsNotExist(err) {
+		t.Errorf("encryption settings left behind after decrypt: %v", err)
+	}
+	data, err := os.ReadFile(m.realmPath(testServer, "shalb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`"refresh_token": "rt-shalb"`)) {
+		t.Errorf("file is not plaintext JSON after decrypt:\n%s", data)
+	}
+}
+
+// Losing encryption.conf must not make encrypted files look like garbage or
+// empty credentials.
+func TestRead_EncryptedFileWithoutSettingsFailsClearly(t *testing.T) {
+	stubPassphrase(t, "pw")
+	dir := t.TempDir()
+	m := &CredentialsManager{baseDir: dir}
+	if err := m.Save(sampleCreds(testServer, "shalb")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SetEncryption(&Encryption{Mode: EncryptionPassphrase}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, encryptionFile)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := (&CredentialsManager{baseDir: dir}).LoadForRealm(testServer, "shalb")
+	if err == nil || !strings.Contains(err.Error(), `encrypted with "passphrase"`) {
+		t.Fatalf("err = %v, want it to say the file is passphrase-encrypted", err)
+	}
+
+	// List reports the file instead of dropping it, so it can still be
+	// removed.
+	fresh := &CredentialsManager{baseDir: dir}
+	creds, unreadable, err := fresh.List()
+	if err != nil || len(creds) != 0 || len(unreadable) != 1 {
+		t.Fatalf("List = %d readable, %d unreadable, err %v; want 0, 1", len(creds), len(unreadable), err)
+	}
+	if !unreadable[0].ForServer(testServer) || unreadable[0].ForServer("https://other.example.com:6443") {
+		t.Errorf("ForServer does not match %s to its server", filepath.Base(unreadable[0].Path))
+	}
+	if err := fresh.RemoveUnreadable(unreadable[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(unreadable[0].Path); !os.IsNotExist(err) {
+		t.Errorf("unreadable file still present: %v", err)
+	}
+}
+
+func TestHelper_GeneratesStoresAndReusesKey(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("helper script is POSIX sh")
+	}
+	dir := t.TempDir()
+	keyStore := filepath.Join(t.TempDir(), "keychain")
+	helper := filepath.Join(t.TempDir(), "helper.sh")
+	script := `#!/bin/sh
+case "$1" in
+  get)   [ -f "` + keyStore + `" ] && echo "password=$(cat "` + keyStore + `")" ;;
+  store) sed -n 's/^password=//p' > "` + keyStore + `" ;;
+esac
+exit 0
+`
+	if err := os.WriteFile(helper, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &CredentialsManager{baseDir: dir}
+	if err := m.Save(sampleCreds(testServer, "shalb")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SetEncryption(&Encryption{Mode: EncryptionHelper, Helper: helper}); err != nil {
+		t.Fatalf("SetEncryption: %v", err)
+	}
+	if fi, err := os.Stat(keyStore); err != nil || fi.Size() == 0 {
+		t.Fatalf("helper was never asked to store a generated key: %v", err)
+	}
+	assertNoPlaintextTokens(t, m)
+
+	got, err := (&CredentialsManager{baseDir: dir}).LoadForRealm(testServer, "shalb")
+	if err != nil {
+		t.Fatalf("LoadForRealm: %v", err)
+	}
+	if got.RefreshToken != "rt-shalb" {
+		t.Errorf("refresh token = %q", got.RefreshToken)
+	}
+}
+
+func TestAge_RoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("age"); err != nil {
+		t.Skip("age not installed")
+	}
+	if _, err := exec.LookPath("age-keygen"); err != nil {
+		t.Skip("age-keygen not installed")
+	}
+	identity := filepath.Join(t.TempDir(), "key.txt")
+	if out, err := exec.Command("age-keygen", "-o", identity).CombinedOutput(); err != nil {
+		t.Fatalf("age-keygen: %v: %s", err, out)
+	}
+
+	dir := t.TempDir()
+	m := &CredentialsManager{baseDir: dir}
+	if err := m.Save(sampleCreds(testServer, "shalb")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SetEncryption(&Encryption{Mode: EncryptionAge, AgeIdentity: identity}); err != nil {
+		t.Fatalf("SetEncryption: %v", err)
+	}
+	assertNoPlaintextTokens(t, m)
+
+	got, err := (&CredentialsManager{baseDir: dir}).LoadForRealm(testServer, "shalb")
+	if err != nil {
+		t.Fatalf("LoadForRealm: %v", err)
+	}
+	if got.AccessToken != "at-shalb" {
+		t.Errorf("access token = %q", got.AccessToken)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package config
+
+import (
+	"os"
+	"syscall"
+)
+
+// ownedByCurrentUser reports whether fi belongs to the calling user. In the
+// shared temp-dir fallback anyone can create a file or directory with the
+// name RuntimeDir expects, so its mode bits alone prove nothing.
+func ownedByCurrentUser(fi os.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	return ok && int(st.Uid) == os.Getuid()
+}
@@ -0,0 +1,427 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// PassphraseEnv lets non-interactive callers (CI, a kubectl exec plugin with
+// no terminal) supply the store passphrase without a prompt.
+const PassphraseEnv = "KUBE_DC_CREDENTIALS_PASSPHRASE"
+
+// sessionKeyTTL bounds how long a derived passphrase key stays in the session
+// cache. $XDG_RUNTIME_DIR is emptied at logout anyway; the TTL matters for the
+// temp-dir fallback, which is not.
+const sessionKeyTTL = 12 * time.Hour
+
+// checkPlaintext is sealed into Encryption.Check to recognise a wrong
+// passphrase.
+const checkPlaintext = "kube-dc credential store"
+
+// sealer encrypts and decrypts whole credential files.
+type sealer interface {
+	mode() string
+	seal(plaintext []byte) ([]byte, error)
+	open(ciphertext []byte) ([]byte, error)
+	// unlock obtains any key material now rather than on first use.
+	unlock() error
+}
+
+// readPassphrase prompts on the controlling terminal. Swapped in tests.
+var readPassphrase = readPassphraseFromTTY
+
+// newSealer builds the sealer for enc. With create set the store is being
+// switched to enc: key material is obtained immediately (prompting twice for
+// a new passphrase) and enc's passphrase fields are filled in. Otherwise keys
+// are obtained lazily, so a plaintext-only code path never prompts.
+func newSealer(enc *Encryption, baseDir string, create bool) (sealer, error) {
+	switch enc.Mode {
+	case EncryptionNone:
+		return nil, nil
+	case EncryptionAge:
+		if enc.AgeIdentity == "" {
+			return nil, fmt.Errorf("age encryption needs an identity file")
+		}
+		if _, err := exec.LookPath("age"); err != nil {
+			return nil, fmt.Errorf("age encryption needs the age binary on PATH: %w", err)
+		}
+		if create {
+			if _, err := os.Stat(enc.AgeIdentity); err != nil {
+				return nil, fmt.Errorf("age identity: %w", err)
+			}
+		}
+		return &ageSealer{identity: enc.AgeIdentity}, nil
+	case EncryptionPassphrase:
+		s := &aeadSealer{name: EncryptionPassphrase}
+		if create {
+			key, err := newPassphraseKey(enc)
+			if err != nil {
+				return nil, err
+			}
+			s.key = key
+			return s, nil
+		}
+		s.keyFn = func() ([]byte, error) { return passphraseKey(enc) }
+		return s, nil
+	case EncryptionHelper:
+		if enc.Helper == "" {
+			return nil, fmt.Errorf("helper encryption needs a helper command")
+		}
+		s := &aeadSealer{name: EncryptionHelper, keyFn: func() ([]byte, error) { return helperKey(enc.Helper) }}
+		if create {
+			if err := s.unlock(); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown credential encryption mode %q in %s", enc.Mode, filepath.Join(baseDir, encryptionFile))
+	}
+}
+
+// aeadSealer is XChaCha20-Poly1305 under a 32-byte key that comes from a
+// passphrase or a helper. Output is nonce || ciphertext.
+type aeadSealer struct {
+	name  string
+	key   []byte
+	keyFn func() ([]byte, error)
+}
+
+func (s *aeadSealer) mode() string { return s.name }
+
+func (s *aeadSealer) unlock() error {
+	if s.key != nil {
+		return nil
+	}
+	key, err := s.keyFn()
+	if err != nil {
+		return err
+	}
+	s.key = key
+	return nil
+}
+
+func (s *aeadSealer) seal(plaintext []byte) ([]byte, error) {
+	if err := s.unlock(); err != nil {
+		return nil, err
+	}
+	return sealWithKey(s.key, plaintext)
+}
+
+func (s *aeadSealer) open(ciphertext []byte) ([]byte, error) {
+	if err := s.unlock(); err != nil {
+		return nil, err
+	}
+	return openWithKey(s.key, ciphertext)
+}
+
+func sealWithKey(key, plaintext []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func openWithKey(key, ciphertext []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ct := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ct, nil)
+}
+
+// deriveKey is scrypt with the interactive-login parameters recommended by
+// the scrypt paper; it runs once per session, not once per kubectl call.
+func deriveKey(passphrase, salt []byte) ([]byte, error) {
+	return scrypt.Key(passphrase, salt, 1<<15, 8, 1, chacha20poly1305.KeySize)
+}
+
+// newPassphraseKey prompts for a new passphrase, fills enc.Salt/Check and
+// caches the derived key for the session.
+func newPassphraseKey(enc *Encryption) ([]byte, error) {
+	pass := []byte(os.Getenv(PassphraseEnv))
+	if len(pass) == 0 {
+		var err error
+		if pass, err = readPassphrase("New credential store passphrase: "); err != nil {
+			return nil, err
+		}
+		again, err := readPassphrase("Repeat passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(pass, again) {
+			return nil, fmt.Errorf("passphrases do not match")
+		}
+	}
+	if len(pass) == 0 {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+
+	enc.Salt = make([]byte, 16)
+	if _, err := rand.Read(enc.Salt); err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(pass, enc.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if enc.Check, err = sealWithKey(key, []byte(checkPlaintext)); err != nil {
+		return nil, err
+	}
+	storeSessionKey(enc.Salt, key)
+	return key, nil
+}
+
+// passphraseKey returns the store key from the session cache, or derives it
+// from $KUBE_DC_CREDENTIALS_PASSPHRASE or a terminal prompt.
+func passphraseKey(enc *Encryption) ([]byte, error) {
+	if key := loadSessionKey(enc.Salt); key != nil {
+		if _, err := openWithKey(key, enc.Check); err == nil {
+			return key, nil
+		}
+	}
+
+	pass := []byte(os.Getenv(PassphraseEnv))
+	if len(pass) == 0 {
+		var err error
+		pass, err = readPassphrase("Credential store passphrase: ")
+		if err != nil {
+			return nil, fmt.Errorf("the credential store is passphrase-encrypted and no terminal is available to ask for it; "+
+				"set %s, or run `kube-dc config unlock-credentials` in a terminal first: %w", PassphraseEnv, err)
+		}
+	}
+	key, err := deriveKey(pass, enc.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := openWithKey(key, enc.Check); err != nil {
+		return nil, fmt.Errorf("wrong credential store passphrase")
+	}
+	storeSessionKey(enc.Salt, key)
+	return key, nil
+}
+
+func readPassphraseFromTTY(prompt string) ([]byte, error) {
+	name := "/dev/tty"
+	if runtime.GOOS == "windows" {
+		name = "CONIN$"
+	}
+	tty, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer tty.Close()
+	// The prompt goes to the terminal, not stdout: under kubectl, stdout is
+	// the ExecCredential JSON.
+	fmt.Fprint(tty, prompt)
+	pass, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(tty)
+	return pass, err
+}
+
+// RuntimeDir is where short-lived, per-session state is kept: the passphrase
+// key and the exec plugin's credential cache. It is per-user and, where the OS
+// provides one, per-login — $XDG_RUNTIME_DIR is a tmpfs removed at logout and
+// never part of a home-directory backup.
+func RuntimeDir() string {
+	if d := os.Getenv("XDG_RUNTIME_DIR"); d != "" {
+		return filepath.Join(d, "kube-dc")
+	}
+	return filepath.Join(os.TempDir(), "kube-dc-"+strconv.Itoa(os.Getuid()))
+}
+
+func sessionKeyPath(salt []byte) string {
+	h := sha256.Sum256(salt)
+	return filepath.Join(RuntimeDir(), "credentials-"+hex.EncodeToString(h[:8])+".key")
+}
+
+type sessionKey struct {
+	Key     []byte    `json:"key"`
+	Expires time.Time `json:"expires"`
+}
+
+// EnsureRuntimeDir creates RuntimeDir and refuses to use one another user
+// could have planted — in the shared temp-dir fallback they could have
+// created it first.
+func EnsureRuntimeDir() (string, error) {
+	if err := os.MkdirAll(RuntimeDir(), 0700); err != nil {
+		return "", err
+	}
+	return CheckRuntimeDir()
+}
+
+// CheckRuntimeDir returns RuntimeDir if it exists, is a real directory (not
+// a symlink) owned by the calling user, and nobody else can enter it.
+// Anything read from it must pass this check first.
+func CheckRuntimeDir() (string, error) {
+	dir := RuntimeDir()
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() || !ownedByCurrentUser(fi) || (runtime.GOOS != "windows" && fi.Mode().Perm() != 0700) {
+		return "", fmt.Errorf("%s is not a private directory", dir)
+	}
+	return dir, nil
+}
+
+// privateFile reports whether path is a regular file owned by the calling
+// user that nobody else can read or write.
+func privateFile(path string) bool {
+	fi, err := os.Lstat(path)
+	if err != nil || !fi.Mode().IsRegular() || !ownedByCurrentUser(fi) {
+		return false
+	}
+	return runtime.GOOS == "windows" || fi.Mode().Perm()&0077 == 0
+}
+
+// storeSessionKey is best effort: without it the user is simply asked again.
+func storeSessionKey(salt, key []byte) {
+	if _, err := EnsureRuntimeDir(); err != nil {
+		return
+	}
+	data, err := json.Marshal(sessionKey{Key: key, Expires: time.Now().Add(sessionKeyTTL)})
+	if err != nil {
+		return
+	}
+	// WriteFile keeps an existing file's mode; start from a fresh file so a
+	// key that failed loadSessionKey's checks does not stay that way.
+	path := sessionKeyPath(salt)
+	_ = os.Remove(path)
+	_ = os.WriteFile(path, data, 0600)
+}
+
+// loadSessionKey only reads a key file storeSessionKey could have written:
+// private, the user's own, in a private RuntimeDir. Anything else may have
+// been planted or read by another user of the temp-dir fallback.
+func loadSessionKey(salt []byte) []byte {
+	if _, err := CheckRuntimeDir(); err != nil {
+		return nil
+	}
+	path := sessionKeyPath(salt)
+	if !privateFile(path) {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var sk sessionKey
+	if json.Unmarshal(data, &sk) != nil || time.Now().After(sk.Expires) {
+		return nil
+	}
+	return sk.Key
+}
+
+func forgetSessionKey(salt []byte) error {
+	if err := os.Remove(sessionKeyPath(salt)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove session key: %w", err)
+	}
+	return nil
+}
+
+// helperAttrs identify the store to the helper, the way protocol/host
+// identify a remote to a git credential helper.
+const helperAttrs = "protocol=kube-dc\nhost=credentials\nusername=kube-dc\n"
+
+// helperKey asks the helper for the store secret, generating and storing one
+// on first use, and turns it into a key. Any git credential helper works —
+// `git credential-osxkeychain`, `git credential-libsecret`, or a script.
+func helperKey(helper string) ([]byte, error) {
+	out, err := runHelper(helper, "get", helperAttrs+"\n")
+	if err != nil {
+		return nil, err
+	}
+	secret := ""
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		if v, ok := strings.CutPrefix(sc.Text(), "password="); ok {
+			secret = v
+		}
+	}
+	if secret == "" {
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		secret = base64.StdEncoding.EncodeToString(raw)
+		if _, err := runHelper(helper, "store", helperAttrs+"password="+secret+"\n\n"); err != nil {
+			return nil, err
+		}
+	}
+	key := sha256.Sum256([]byte(secret))
+	return key[:], nil
+}
+
+func runHelper(helper, action, input string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", helper+" "+action)
+	} else {
+		cmd = exec.Command("sh", "-c", helper+" "+action)
+	}
+	cmd.Stdin = strings.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("credential helper %q %s: %w: %s", helper, action, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// ageSealer shells out to the age binary, as the bootstrap commands do, so
+// identities age itself understands — including passphrase-protected and
+// plugin (hardware token) identities — work unchanged.
+type ageSealer struct {
+	identity string
+}
+
+func (s *ageSealer) mode() string  { return EncryptionAge }
+func (s *ageSealer) unlock() error { return nil }
+
+func (s *ageSealer) seal(plaintext []byte) ([]byte, error) {
+	// -e with -i encrypts to the identity's own recipient.
+	return runAge(plaintext, "-e", "-i", s.identity)
+}
+
+func (s *ageSealer) open(ciphertext []byte) ([]byte, error) {
+	return runAge(ciphertext, "-d", "-i", s.identity)
+}
+
+func runAge(input []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command("age", args...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("age %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
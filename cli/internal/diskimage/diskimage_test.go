@@ -0,0 +1,163 @@
+package diskimage
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testOVF = `<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1"
+  xmlns:rasd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_ResourceAllocationSettingData"
+  xmlns:vmw="http://www.vmware.com/schema/ovf">
+  <References>
+    <File ovf:id="file1" ovf:href="web-disk1.vmdk" ovf:size="1024"/>
+    <File ovf:id="file2" ovf:href="web-disk2.vmdk" ovf:size="512"/>
+  </References>
+  <DiskSection>
+    <Disk ovf:diskId="vmdisk1" ovf:fileRef="file1" ovf:capacity="20" ovf:capacityAllocationUnits="byte * 2^30"
+      ovf:format="http://www.vmware.com/interfaces/specifications/vmdk.html#streamOptimized"/>
+    <Disk ovf:diskId="vmdisk2" ovf:fileRef="file2" ovf:capacity="1073741824"/>
+  </DiskSection>
+  <VirtualSystem ovf:id="web-01">
+    <Name>web-01</Name>
+    <OperatingSystemSection ovf:id="96" vmw:osType="ubuntu64Guest"><Description>Ubuntu Linux (64-bit)</Description></OperatingSystemSection>
+    <VirtualHardwareSection>
+      <Item><rasd:ResourceType>3</rasd:ResourceType><rasd:VirtualQuantity>4</rasd:VirtualQuantity></Item>
+      <Item><rasd:AllocationUnits>byte * 2^20</rasd:AllocationUnits><rasd:ResourceType>4</rasd:ResourceType><rasd:VirtualQuantity>8192</rasd:VirtualQuantity></Item>
+      <Item><rasd:HostResource>ovf:/disk/vmdisk1</rasd:HostResource><rasd:ResourceType>17</rasd:ResourceType></Item>
+      <Item><rasd:HostResource>ovf:/disk/vmdisk2</rasd:HostResource><rasd:ResourceType>17</rasd:ResourceType></Item>
+      <vmw:Config ovf:required="false" vmw:key="firmware" vmw:value="efi"/>
+    </VirtualHardwareSection>
+  </VirtualSystem>
+</Envelope>`
+
+func TestParseOVF(t *testing.T) {
+	vm, err := ParseOVF(strings.NewReader(testOVF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vm.Name != "web-01" || vm.OSType != "ubuntu64Guest" || vm.CPUs != 4 || vm.MemoryBytes != 8<<30 || vm.Firmware != "efi" {
+		t.Errorf("vm = %+v", vm)
+	}
+	if len(vm.Disks) != 2 || vm.Disks[0].File != "web-disk1.vmdk" || vm.Disks[0].CapacityBytes != 20<<30 || vm.Disks[1].CapacityBytes != 1<<30 {
+		t.Errorf("disks = %+v", vm.Disks)
+	}
+
+	bad := strings.Replace(testOVF, "ovf:/disk/vmdisk2", "ovf:/disk/missing", 1)
+	if _, err := ParseOVF(strings.NewReader(bad)); err == nil || !strings.Contains(err.Error(), "unknown disk") {
+		t.Errorf("unknown disk: %v", err)
+	}
+	bad = strings.Replace(testOVF, "byte * 2^30", "furlongs", 1)
+	if _, err := ParseOVF(strings.NewReader(bad)); err == nil || !strings.Contains(err.Error(), "unsupported allocation units") {
+		t.Errorf("bad units: %v", err)
+	}
+}
+
+func TestUnitMultiplier(t *testing.T) {
+	for units, want := range map[string]float64{
+		"byte": 1, "byte * 2^20": 1 << 20, "byte*10^3": 1000, "MegaBytes": 1 << 20, "GigaBytes": 1 << 30,
+	} {
+		if got, err := unitMultiplier(units); err != nil || got != want {
+			t.Errorf("%q = %v, %v; want %v", units, got, err, want)
+		}
+	}
+}
+
+func writeOVA(t *testing.T, members map[string][]byte, order []string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "web.ova")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(f)
+	for _, name := range order {
+		body := members[name]
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	return file
+}
+
+func TestOpenOVA(t *testing.T) {
+	disk1 := bytes.Repeat([]byte{0xAB}, 1024)
+	disk2 := bytes.Repeat([]byte{0xCD}, 512)
+	file := writeOVA(t, map[string][]byte{
+		"web.ovf": []byte(testOVF), "web-disk1.vmdk": disk1, "web-disk2.vmdk": disk2,
+	}, []string{"web.ovf", "web-disk1.vmdk", "web-disk2.vmdk"})
+
+	ova, err := OpenOVA(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ova.Close()
+	if ova.VM.CPUs != 4 || len(ova.VM.Disks) != 2 {
+		t.Fatalf("vm = %+v", ova.VM)
+	}
+	for i, want := range [][]byte{disk1, disk2} {
+		r := ova.DiskReader(ova.VM.Disks[i])
+		got, _ := io.ReadAll(r)
+		if !bytes.Equal(got, want) || r.Size() != int64(len(want)) {
+			t.Errorf("disk %d: read %d bytes", i, len(got))
+		}
+	}
+
+	missing := writeOVA(t, map[string][]byte{"web.ovf": []byte(testOVF), "web-disk1.vmdk": disk1}, []string{"web.ovf", "web-disk1.vmdk"})
+	if _, err := OpenOVA(missing); err == nil || !strings.Contains(err.Error(), "web-disk2.vmdk") {
+		t.Errorf("missing disk: %v", err)
+	}
+	noOVF := writeOVA(t, map[string][]byte{"disk.vmdk": disk1}, []string{"disk.vmdk"})
+	if _, err := OpenOVA(noOVF); err == nil || !strings.Contains(err.Error(), "no .ovf descriptor") {
+		t.Errorf("no descriptor: %v", err)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	qcow := make([]byte, 512)
+	copy(qcow, "QFI\xfb")
+	binary.BigEndian.PutUint64(qcow[24:], 10<<30)
+	vmdk := make([]byte, 512)
+	copy(vmdk, "KDMV")
+	binary.LittleEndian.PutUint64(vmdk[12:], 41943040) // 20 GiB in sectors
+
+	cases := []struct {
+		name   string
+		data   []byte
+		format string
+		size   int64
+		errSub string
+	}{
+		{"qcow2", qcow, FormatQCOW2, 10 << 30, ""},
+		{"vmdk", vmdk, FormatVMDK, 20 << 30, ""},
+		{"raw", make([]byte, 2048), FormatRaw, 2048, ""},
+		{"odd raw", make([]byte, 1000), "", 0, "whole number of sectors"},
+		{"descriptor", []byte("# Disk DescriptorFile\nversion=1\n"), "", 0, "-flat.vmdk"},
+		{"gzip", append([]byte{0x1f, 0x8b}, make([]byte, 510)...), "", 0, "decompress"},
+	}
+	for _, c := range cases {
+		format, size, err := Probe(bytes.NewReader(c.data), int64(len(c.data)))
+		if c.errSub != "" {
+			if err == nil || !strings.Contains(err.Error(), c.errSub) {
+				t.Errorf("%s: err = %v", c.name, err)
+			}
+			continue
+		}
+		if err != nil || format != c.format || size != c.size {
+			t.Errorf("%s = %s, %d, %v", c.name, format, size, err)
+		}
+	}
+}
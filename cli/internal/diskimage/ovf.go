@@ -0,0 +1,275 @@
+// Package diskimage reads local VM disk images for `kube-dc vm
+// import`: the OVF descriptor of an OVA (CPU, memory, firmware and disk
+// layout) and the virtual size of qcow2, VMDK and raw disks, so the
+// target DataVolumes can be sized before any byte is uploaded.
+package diskimage
+
+import (
+	"archive/tar"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// VM is the hardware an OVF descriptor asks for.
+type VM struct {
+	Name        string
+	OSType      string // vmw:osType / OperatingSystemSection description
+	CPUs        int
+	MemoryBytes int64
+	Firmware    string // "efi" or "" (BIOS)
+	Disks       []Disk // in controller order; the first is the boot disk
+}
+
+// Disk is one virtual disk of an OVF VM.
+type Disk struct {
+	ID            string
+	File          string // href inside the OVA
+	CapacityBytes int64
+	Format        string // the OVF format URI, e.g. ...#streamOptimized
+}
+
+// ovfEnvelope maps the parts of DSP0243 the CLI uses. Tags carry no
+// namespace so both the ovf: and rasd: qualified names match.
+type ovfEnvelope struct {
+	References struct {
+		Files []struct {
+			ID   string `xml:"id,attr"`
+			Href string `xml:"href,attr"`
+		} `xml:"File"`
+	} `xml:"References"`
+	DiskSection struct {
+		Disks []struct {
+			DiskID        string `xml:"diskId,attr"`
+			FileRef       string `xml:"fileRef,attr"`
+			Capacity      string `xml:"capacity,attr"`
+			CapacityUnits string `xml:"capacityAllocationUnits,attr"`
+			Format        string `xml:"format,attr"`
+		} `xml:"Disk"`
+	} `xml:"DiskSection"`
+	VirtualSystem struct {
+		ID   string `xml:"id,attr"`
+		Name string `xml:"Name"`
+		OS   struct {
+			OSType      string `xml:"osType,attr"`
+			Description string `xml:"Description"`
+		} `xml:"OperatingSystemSection"`
+		Hardware struct {
+			Items []struct {
+				ResourceType    int    `xml:"ResourceType"`
+				VirtualQuantity string `xml:"VirtualQuantity"`
+				AllocationUnits string `xml:"AllocationUnits"`
+				HostResource    string `xml:"HostResource"`
+			} `xml:"Item"`
+			Configs []struct {
+				Key   string `xml:"key,attr"`
+				Value string `xml:"value,attr"`
+			} `xml:"Config"`
+		} `xml:"VirtualHardwareSection"`
+	} `xml:"VirtualSystem"`
+}
+
+// CIM resource types (DSP0243 / CIM_ResourceAllocationSettingData).
+const (
+	resourceCPU    = 3
+	resourceMemory = 4
+	resourceDisk   = 17
+)
+
+// ParseOVF reads an OVF descriptor.
+func ParseOVF(r io.Reader) (*VM, error) {
+	var env ovfEnvelope
+	if err := xml.NewDecoder(r).Decode(&env); err != nil {
+		return nil, fmt.Errorf("parse OVF: %w", err)
+	}
+	vs := env.VirtualSystem
+	vm := &VM{Name: fmtFirst(vs.Name, vs.ID), OSType: fmtFirst(vs.OS.OSType, vs.OS.Description)}
+
+	files := map[string]string{}
+	for _, f := range env.References.Files {
+		files[f.ID] = f.Href
+	}
+	disks := map[string]Disk{}
+	for _, d := range env.DiskSection.Disks {
+		capacity, err := strconv.ParseFloat(strings.TrimSpace(d.Capacity), 64)
+		if err != nil {
+			return nil, fmt.Errorf("OVF disk %s: invalid capacity %q", d.DiskID, d.Capacity)
+		}
+		mult, err := unitMultiplier(fmtFirst(d.CapacityUnits, "byte"))
+		if err != nil {
+			return nil, fmt.Errorf("OVF disk %s: %w", d.DiskID, err)
+		}
+		disk := Disk{ID: d.DiskID, CapacityBytes: int64(capacity * mult), Format: d.Format}
+		if d.FileRef != "" {
+			href, ok := files[d.FileRef]
+			if !ok {
+				return nil, fmt.Errorf("OVF disk %s refers to unknown file %q", d.DiskID, d.FileRef)
+			}
+			disk.File = href
+		}
+		disks[d.DiskID] = disk
+	}
+
+	for _, item := range vs.Hardware.Items {
+		switch item.ResourceType {
+		case resourceCPU:
+			n, err := strconv.Atoi(strings.TrimSpace(item.VirtualQuantity))
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("OVF: invalid CPU count %q", item.VirtualQuantity)
+			}
+			vm.CPUs = n
+		case resourceMemory:
+			q, err := strconv.ParseFloat(strings.TrimSpace(item.VirtualQuantity), 64)
+			if err != nil {
+				return nil, fmt.Errorf("OVF: invalid memory quantity %q", item.VirtualQuantity)
+			}
+			// OVF's default memory unit is MiB.
+			mult, err := unitMultiplier(fmtFirst(item.AllocationUnits, "byte * 2^20"))
+			if err != nil {
+				return nil, fmt.Errorf("OVF memory: %w", err)
+			}
+			vm.MemoryBytes = int64(q * mult)
+		case resourceDisk:
+			id := item.HostResource
+			for _, prefix := range []string{"ovf:/disk/", "/disk/"} {
+				id = strings.TrimPrefix(id, prefix)
+			}
+			disk, ok := disks[id]
+			if !ok {
+				return nil, fmt.Errorf("OVF: disk drive refers to unknown disk %q", item.HostResource)
+			}
+			if disk.File == "" {
+				return nil, fmt.Errorf("OVF disk %s has no backing file; an empty disk cannot be imported", id)
+			}
+			vm.Disks = append(vm.Disks, disk)
+		}
+	}
+	for _, c := range vs.Hardware.Configs {
+		if c.Key == "firmware" && strings.EqualFold(c.Value, "efi") {
+			vm.Firmware = "efi"
+		}
+	}
+	if len(vm.Disks) == 0 {
+		return nil, errors.New("OVF describes no disks")
+	}
+	return vm, nil
+}
+
+var unitRE = regexp.MustCompile(`^(?i)\s*(byte|bytes)?\s*(?:\*\s*(2|10)\s*\^\s*(\d+))?\s*$`)
+
+// unitMultiplier turns a DMTF programmatic unit ("byte * 2^30") into a
+// byte multiplier. The legacy "KiloBytes"/"MegaBytes"/"GigaBytes"
+// spellings are accepted too.
+func unitMultiplier(units string) (float64, error) {
+	switch strings.ToLower(strings.TrimSpace(units)) {
+	case "kilobytes":
+		return 1 << 10, nil
+	case "megabytes":
+		return 1 << 20, nil
+	case "gigabytes":
+		return 1 << 30, nil
+	}
+	m := unitRE.FindStringSubmatch(units)
+	if m == nil || (m[1] == "" && m[2] == "") {
+		return 0, fmt.Errorf("unsupported allocation units %q", units)
+	}
+	if m[2] == "" {
+		return 1, nil
+	}
+	base, _ := strconv.ParseFloat(m[2], 64)
+	exp, _ := strconv.ParseFloat(m[3], 64)
+	return math.Pow(base, exp), nil
+}
+
+// OVA is an opened OVA archive: the parsed descriptor plus the location
+// of every member, so disks can be read in place without unpacking.
+type OVA struct {
+	VM      *VM
+	f       *os.File
+	members map[string]member
+}
+
+type member struct {
+	offset, size int64
+}
+
+// OpenOVA reads the tar index and the OVF descriptor of an OVA.
+func OpenOVA(file string) (*OVA, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	ova := &OVA{f: f, members: map[string]member{}}
+	var descriptor string
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("read OVA %s: %w", file, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		// archive/tar reads exactly the header blocks, so the file's
+		// position is now the member's first data byte.
+		off, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		name := path.Clean(hdr.Name)
+		ova.members[name] = member{offset: off, size: hdr.Size}
+		if descriptor == "" && strings.EqualFold(path.Ext(name), ".ovf") {
+			descriptor = name
+		}
+	}
+	if descriptor == "" {
+		f.Close()
+		return nil, fmt.Errorf("%s contains no .ovf descriptor; is it an OVA?", file)
+	}
+	vm, err := ParseOVF(ova.open(descriptor))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	for _, d := range vm.Disks {
+		if _, ok := ova.members[path.Clean(d.File)]; !ok {
+			f.Close()
+			return nil, fmt.Errorf("OVA lacks disk file %s named by its descriptor", d.File)
+		}
+	}
+	ova.VM = vm
+	return ova, nil
+}
+
+func (o *OVA) open(name string) *io.SectionReader {
+	m := o.members[path.Clean(name)]
+	return io.NewSectionReader(o.f, m.offset, m.size)
+}
+
+// DiskReader returns the bytes of a disk file inside the OVA.
+func (o *OVA) DiskReader(d Disk) *io.SectionReader {
+	return o.open(d.File)
+}
+
+func (o *OVA) Close() error { return o.f.Close() }
+
+func fmtFirst(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
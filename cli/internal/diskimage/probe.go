@@ -0,0 +1,50 @@
+package diskimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Image formats Probe recognises. CDI converts all of them to raw on
+// the target volume.
+const (
+	FormatRaw   = "raw"
+	FormatQCOW2 = "qcow2"
+	FormatVMDK  = "vmdk"
+)
+
+// Probe identifies a disk image from its header and returns the
+// guest-visible (virtual) size, which is what the target volume must
+// hold — a sparse qcow2 or VMDK file is usually much smaller.
+func Probe(r io.ReaderAt, fileSize int64) (format string, virtualSize int64, err error) {
+	head := make([]byte, 512)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", 0, fmt.Errorf("read image header: %w", err)
+	}
+	head = head[:n]
+	switch {
+	case bytes.HasPrefix(head, []byte("QFI\xfb")):
+		if len(head) < 32 {
+			return "", 0, errors.New("truncated qcow2 header")
+		}
+		return FormatQCOW2, int64(binary.BigEndian.Uint64(head[24:32])), nil
+	case bytes.HasPrefix(head, []byte("KDMV")):
+		// Hosted sparse / streamOptimized extent; capacity in sectors.
+		if len(head) < 20 {
+			return "", 0, errors.New("truncated VMDK header")
+		}
+		return FormatVMDK, int64(binary.LittleEndian.Uint64(head[12:20])) * 512, nil
+	case bytes.HasPrefix(head, []byte("# Disk DescriptorFile")):
+		return "", 0, errors.New("this is a VMDK descriptor only; import its -flat.vmdk extent (raw) instead")
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}), bytes.HasPrefix(head, []byte("\xfd7zXZ\x00")):
+		return "", 0, errors.New("compressed images are not supported; decompress the file first")
+	}
+	if fileSize%512 != 0 {
+		return "", 0, fmt.Errorf("not a qcow2 or VMDK image, and %d bytes is not a whole number of sectors for a raw one", fileSize)
+	}
+	return FormatRaw, fileSize, nil
+}
@@ -1,6 +1,8 @@
 //go:build !windows
 
-package clusterinit
+// Package filelock provides the cross-process exclusive lock shared by the
+// credential store and the bootstrap pipeline.
+package filelock
 
 import (
 	"fmt"
@@ -8,17 +10,14 @@ import (
 	"syscall"
 )
 
-// lockFile takes an exclusive advisory flock on path (creating it), so two
-// processes cannot interleave a sweep-write-encrypt-rename sequence on the
-// same artifact: without it, one run's stale-temp sweep can delete the OTHER
-// run's live temp, and two completing runs race last-rename-wins (codex
-// pass-5, HIGH). flock releases on process death, so a crash never wedges
-// later runs the way an O_EXCL lock file would.
+// Lock takes an exclusive advisory flock on path (creating it). flock
+// releases on process death, so a process killed while holding it never
+// wedges later ones the way an O_EXCL lock file would.
 //
 // The returned release func closes (and thereby unlocks) the handle; the lock
 // file itself is left in place — removing it would reopen the race for a
 // third process that opened it between our unlock and unlink.
-func lockFile(path string) (release func(), err error) {
+func Lock(path string) (release func(), err error) {
 	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
 	if err != nil {
 		return nil, fmt.Errorf("open lock %s: %w", path, err)
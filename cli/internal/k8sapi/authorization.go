@@ -0,0 +1,103 @@
+// Self-subject authorization reviews (authorization.k8s.io/v1). These
+// back `kube-dc whoami`: SelfSubjectRulesReview lists what the caller
+// may do in a namespace in one round trip, SelfSubjectAccessReview
+// answers a single question authoritatively. Rules reviews are
+// best-effort by design — an authorizer that cannot enumerate its
+// rules (a webhook) marks the result Incomplete — so callers fall
+// back to access reviews for anything that matters.
+
+package k8sapi
+
+import (
+	"context"
+)
+
+const authzBasePath = "/apis/authorization.k8s.io/v1"
+
+// ResourceRule is one allow rule from a SelfSubjectRulesReview.
+type ResourceRule struct {
+	Verbs         []string `json:"verbs"`
+	APIGroups     []string `json:"apiGroups,omitempty"`
+	Resources     []string `json:"resources,omitempty"`
+	ResourceNames []string `json:"resourceNames,omitempty"`
+}
+
+// RulesReviewStatus is the status of a SelfSubjectRulesReview.
+type RulesReviewStatus struct {
+	ResourceRules   []ResourceRule `json:"resourceRules"`
+	Incomplete      bool           `json:"incomplete"`
+	EvaluationError string         `json:"evaluationError,omitempty"`
+}
+
+// Allows reports whether the rules grant verb on group/resource for
+// every object name. Rules scoped to resourceNames do not count: they
+// answer "may I touch that one object", not "do I hold this verb".
+func (s *RulesReviewStatus) Allows(group, resource, verb string) bool {
+	for _, r := range s.ResourceRules {
+		if len(r.ResourceNames) > 0 {
+			continue
+		}
+		if matchesAny(r.APIGroups, group) && matchesAny(r.Resources, resource) && matchesAny(r.Verbs, verb) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(list []string, want string) bool {
+	for _, v := range list {
+		if v == "*" || v == want {
+			return true
+		}
+	}
+	return false
+}
+
+// SelfSubjectRulesReview lists the caller's rules in namespace.
+func (c *Client) SelfSubjectRulesReview(ctx context.Context, namespace string) (*RulesReviewStatus, error) {
+	body := map[string]any{
+		"apiVersion": "authorization.k8s.io/v1",
+		"kind":       "SelfSubjectRulesReview",
+		"spec":       map[string]any{"namespace": namespace},
+	}
+	var out struct {
+		Status RulesReviewStatus `json:"status"`
+	}
+	if err := c.do(ctx, "POST", authzBasePath+"/selfsubjectrulesreviews", body, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out.Status, nil
+}
+
+// ResourceAttributes is the question a SelfSubjectAccessReview asks.
+type ResourceAttributes struct {
+	Namespace   string `json:"namespace,omitempty"`
+	Verb        string `json:"verb"`
+	Group       string `json:"group,omitempty"`
+	Resource    string `json:"resource"`
+	Subresource string `json:"subresource,omitempty"`
+	Name        string `json:"name,omitempty"`
+}
+
+// AccessReviewStatus is the answer to a SelfSubjectAccessReview.
+type AccessReviewStatus struct {
+	Allowed bool   `json:"allowed"`
+	Denied  bool   `json:"denied,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// SelfSubjectAccessReview asks whether the caller may perform attrs.
+func (c *Client) SelfSubjectAccessReview(ctx context.Context, attrs ResourceAttributes) (*AccessReviewStatus, error) {
+	body := map[string]any{
+		"apiVersion": "authorization.k8s.io/v1",
+		"kind":       "SelfSubjectAccessReview",
+		"spec":       map[string]any{"resourceAttributes": attrs},
+	}
+	var out struct {
+		Status AccessReviewStatus `json:"status"`
+	}
+	if err := c.do(ctx, "POST", authzBasePath+"/selfsubjectaccessreviews", body, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out.Status, nil
+}
@@ -0,0 +1,108 @@
+// Typed direct-K8s wrappers for objectbucket.io/v1alpha1
+// ObjectBucketClaim, the S3 bucket request the Rook provisioner binds
+// into a bucket plus a same-name Secret (AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY) and ConfigMap (BUCKET_NAME, BUCKET_HOST,
+// BUCKET_PORT, BUCKET_REGION). Project admin and developer may create,
+// read and delete claims; project-manager and user only read them.
+// StorageClasses are cluster-scoped and readable by every user.
+
+package k8sapi
+
+import (
+	"context"
+	"net/url"
+)
+
+const (
+	obcAPIVersion          = "objectbucket.io/v1alpha1"
+	obcResource            = "objectbucketclaims"
+	storageClassesPath     = "/apis/storage.k8s.io/v1/storageclasses"
+	OrganizationLabel      = "kube-dc.com/organization"
+	ObjectBucketClaimBound = "Bound"
+)
+
+type ObjectBucketClaim struct {
+	APIVersion string                  `json:"apiVersion"`
+	Kind       string                  `json:"kind"`
+	Metadata   ObjectMeta              `json:"metadata"`
+	Spec       ObjectBucketClaimSpec   `json:"spec"`
+	Status     ObjectBucketClaimStatus `json:"status,omitempty"`
+}
+
+type ObjectBucketClaimSpec struct {
+	BucketName       string `json:"bucketName,omitempty"`
+	StorageClassName string `json:"storageClassName,omitempty"`
+	ObjectBucketName string `json:"objectBucketName,omitempty"`
+}
+
+// ObjectBucketClaimStatus.Phase is Pending, Bound, Released or Failed.
+type ObjectBucketClaimStatus struct {
+	Phase string `json:"phase,omitempty"`
+}
+
+type ObjectBucketClaimList struct {
+	Items []ObjectBucketClaim `json:"items"`
+}
+
+// StorageClass carries only what the CLI needs to pick a bucket class.
+type StorageClass struct {
+	Metadata    ObjectMeta `json:"metadata"`
+	Provisioner string     `json:"provisioner"`
+}
+
+type StorageClassList struct {
+	Items []StorageClass `json:"items"`
+}
+
+func (c *Client) ListObjectBucketClaims(ctx context.Context, ns string) (*ObjectBucketClaimList, error) {
+	var out ObjectBucketClaimList
+	if err := c.do(ctx, "GET", groupPath(obcAPIVersion, ns, obcResource, ""), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) GetObjectBucketClaim(ctx context.Context, ns, name string) (*ObjectBucketClaim, error) {
+	var out ObjectBucketClaim
+	if err := c.do(ctx, "GET", groupPath(obcAPIVersion, ns, obcResource, name), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) CreateObjectBucketClaim(ctx context.Context, obc *ObjectBucketClaim) (*ObjectBucketClaim, error) {
+	if obc.APIVersion == "" {
+		obc.APIVersion = obcAPIVersion
+	}
+	if obc.Kind == "" {
+		obc.Kind = "ObjectBucketClaim"
+	}
+	var out ObjectBucketClaim
+	if err := c.do(ctx, "POST", groupPath(obcAPIVersion, obc.Metadata.Namespace, obcResource, ""), obc, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteObjectBucketClaim removes the claim; with the provisioner's
+// usual Delete reclaim policy that deletes the bucket and its objects.
+func (c *Client) DeleteObjectBucketClaim(ctx context.Context, ns, name string) error {
+	return c.do(ctx, "DELETE", groupPath(obcAPIVersion, ns, obcResource, name), nil, nil, "")
+}
+
+func (c *Client) ListStorageClasses(ctx context.Context) (*StorageClassList, error) {
+	var out StorageClassList
+	if err := c.do(ctx, "GET", storageClassesPath, nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetStorageClass reads one cluster-scoped StorageClass.
+func (c *Client) GetStorageClass(ctx context.Context, name string) (*StorageClass, error) {
+	var out StorageClass
+	if err := c.do(ctx, "GET", storageClassesPath+"/"+url.PathEscape(name), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
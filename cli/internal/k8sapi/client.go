@@ -23,6 +23,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -35,6 +36,7 @@ type Client struct {
 	APIServerURL string // e.g. https://kube-api.kube-dc.cloud:6443
 	AccessToken  string
 	http         *http.Client
+	tlsConfig    *tls.Config // shared with websocket dials (streams.go)
 }
 
 // New builds a Client from a kube-apiserver URL + access token. caCert
@@ -59,6 +61,7 @@ func New(apiServerURL, accessToken, caCert string, insecure bool) (*Client, erro
 	return &Client{
 		APIServerURL: strings.TrimRight(apiServerURL, "/"),
 		AccessToken:  accessToken,
+		tlsConfig:    tlsCfg,
 		http: &http.Client{
 			Timeout:   30 * time.Second,
 			Transport: &http.Transport{TLSClientConfig: tlsCfg},
@@ -82,6 +85,12 @@ func (e *APIError) Error() string {
 	return fmt.Sprintf("kube-apiserver %d: %s", e.Status, truncate(e.Raw, 200))
 }
 
+// IsNotFound reports whether err is a kube-apiserver 404.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound
+}
+
 func truncate(s string, n int) string {
 	if len(s) <= n {
 		return s
@@ -0,0 +1,320 @@
+// Typed direct-K8s wrappers for the few core/v1 objects the CLI uses
+// in a Project namespace: Services (LoadBalancer exposure), Secrets
+// (the Project's generated SSH keypair and the like), PVCs, Pods
+// (port-forward targets), ConfigMaps (client-side settings such as
+// VM snapshot policies), and ResourceQuotas (read-only quota usage).
+
+package k8sapi
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+type Service struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   ObjectMeta    `json:"metadata"`
+	Spec       ServiceSpec   `json:"spec"`
+	Status     ServiceStatus `json:"status,omitempty"`
+}
+
+type ServiceSpec struct {
+	Type     string            `json:"type,omitempty"`
+	Selector map[string]string `json:"selector,omitempty"`
+	Ports    []ServicePort     `json:"ports,omitempty"`
+}
+
+// ServicePort.TargetPort is an int-or-string on the wire; see
+// TargetPortNumber.
+type ServicePort struct {
+	Name       string `json:"name,omitempty"`
+	Protocol   string `json:"protocol,omitempty"`
+	Port       int    `json:"port"`
+	TargetPort any    `json:"targetPort,omitempty"`
+}
+
+// TargetPortNumber returns the numeric target port, falling back to
+// Port when it is unset as the API server does. A named target port
+// yields 0.
+func (p ServicePort) TargetPortNumber() int {
+	switch v := p.TargetPort.(type) {
+	case nil:
+		return p.Port
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+	return 0
+}
+
+type ServiceStatus struct {
+	LoadBalancer struct {
+		Ingress []struct {
+			IP       string `json:"ip,omitempty"`
+			Hostname string `json:"hostname,omitempty"`
+		} `json:"ingress,omitempty"`
+	} `json:"loadBalancer,omitempty"`
+}
+
+type ServiceList struct {
+	Items []Service `json:"items"`
+}
+
+// Secret keeps Data base64-encoded as the API serves it; use Value to
+// read a key.
+type Secret struct {
+	Metadata ObjectMeta        `json:"metadata"`
+	Type     string            `json:"type,omitempty"`
+	Data     map[string]string `json:"data,omitempty"`
+}
+
+// Value returns the decoded value of key.
+func (s *Secret) Value(key string) ([]byte, error) {
+	v, ok := s.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("secret %s has no key %q", s.Metadata.Name, key)
+	}
+	b, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		return nil, fmt.Errorf("secret %s key %q: %w", s.Metadata.Name, key, err)
+	}
+	return b, nil
+}
+
+func corePath(ns, resource, name string) string {
+	p := fmt.Sprintf("/api/v1/namespaces/%s/%s", url.PathEscape(ns), resource)
+	if name != "" {
+		p += "/" + url.PathEscape(name)
+	}
+	return p
+}
+
+func (c *Client) ListServices(ctx context.Context, ns string) (*ServiceList, error) {
+	var out ServiceList
+	if err := c.do(ctx, "GET", corePath(ns, "services", ""), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) GetService(ctx context.Context, ns, name string) (*Service, error) {
+	var out Service
+	if err := c.do(ctx, "GET", corePath(ns, "services", name), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateService POSTs a new Service; metadata.namespace is the Project
+// backing namespace.
+func (c *Client) CreateService(ctx context.Context, svc *Service) (*Service, error) {
+	svc.APIVersion = "v1"
+	svc.Kind = "Service"
+	var out Service
+	if err := c.do(ctx, "POST", corePath(svc.Metadata.Namespace, "services", ""), svc, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PatchService applies a JSON merge patch; a nil annotation value
+// removes the key.
+func (c *Client) PatchService(ctx context.Context, ns, name string, patch any) (*Service, error) {
+	var out Service
+	if err := c.do(ctx, "PATCH", corePath(ns, "services", name), patch, &out, "application/merge-patch+json"); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Pod carries what port-forwarding needs: readiness and named ports.
+type Pod struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Spec     struct {
+		Containers []struct {
+			Name  string `json:"name"`
+			Ports []struct {
+				Name          string `json:"name,omitempty"`
+				ContainerPort int    `json:"containerPort"`
+			} `json:"ports,omitempty"`
+		} `json:"containers"`
+	} `json:"spec"`
+	Status struct {
+		Phase      string      `json:"phase,omitempty"`
+		Conditions []Condition `json:"conditions,omitempty"`
+	} `json:"status,omitempty"`
+}
+
+// Ready reports the PodReady condition.
+func (p *Pod) Ready() bool {
+	for _, c := range p.Status.Conditions {
+		if c.Type == "Ready" {
+			return c.Status == "True"
+		}
+	}
+	return false
+}
+
+// NamedPort resolves a container port name; 0 when no container has it.
+func (p *Pod) NamedPort(name string) int {
+	for _, c := range p.Spec.Containers {
+		for _, port := range c.Ports {
+			if port.Name == name {
+				return port.ContainerPort
+			}
+		}
+	}
+	return 0
+}
+
+type PodList struct {
+	Items []Pod `json:"items"`
+}
+
+// ListPods lists the namespace's Pods matching labelSelector.
+func (c *Client) ListPods(ctx context.Context, ns, labelSelector string) (*PodList, error) {
+	p := corePath(ns, "pods", "")
+	if labelSelector != "" {
+		p += "?labelSelector=" + url.QueryEscape(labelSelector)
+	}
+	var out PodList
+	if err := c.do(ctx, "GET", p, nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSecret reads one Secret. RBAC: `get secrets` in the namespace.
+func (c *Client) GetSecret(ctx context.Context, ns, name string) (*Secret, error) {
+	var out Secret
+	if err := c.do(ctx, "GET", corePath(ns, "secrets", name), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PersistentVolumeClaim carries only what the CLI reads back.
+type PersistentVolumeClaim struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Spec     struct {
+		StorageClassName string   `json:"storageClassName,omitempty"`
+		AccessModes      []string `json:"accessModes,omitempty"`
+		VolumeMode       string   `json:"volumeMode,omitempty"`
+	} `json:"spec"`
+	Status struct {
+		Phase string `json:"phase,omitempty"`
+	} `json:"status,omitempty"`
+}
+
+func (c *Client) GetPersistentVolumeClaim(ctx context.Context, ns, name string) (*PersistentVolumeClaim, error) {
+	var out PersistentVolumeClaim
+	if err := c.do(ctx, "GET", corePath(ns, "persistentvolumeclaims", name), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type ConfigMap struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   ObjectMeta        `json:"metadata"`
+	Data       map[string]string `json:"data,omitempty"`
+}
+
+type ConfigMapList struct {
+	Items []ConfigMap `json:"items"`
+}
+
+// ListConfigMaps lists ConfigMaps matching labelSelector (all when
+// empty).
+func (c *Client) ListConfigMaps(ctx context.Context, ns, labelSelector string) (*ConfigMapList, error) {
+	p := corePath(ns, "configmaps", "")
+	if labelSelector != "" {
+		p += "?labelSelector=" + url.QueryEscape(labelSelector)
+	}
+	var out ConfigMapList
+	if err := c.do(ctx, "GET", p, nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) GetConfigMap(ctx context.Context, ns, name string) (*ConfigMap, error) {
+	var out ConfigMap
+	if err := c.do(ctx, "GET", corePath(ns, "configmaps", name), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateConfigMap POSTs a ConfigMap into metadata.namespace.
+func (c *Client) CreateConfigMap(ctx context.Context, cm *ConfigMap) (*ConfigMap, error) {
+	if cm == nil || cm.Metadata.Name == "" || cm.Metadata.Namespace == "" {
+		return nil, fmt.Errorf("CreateConfigMap: metadata.name and metadata.namespace are required")
+	}
+	cm.APIVersion = "v1"
+	cm.Kind = "ConfigMap"
+	var out ConfigMap
+	if err := c.do(ctx, "POST", corePath(cm.Metadata.Namespace, "configmaps", ""), cm, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateConfigMap PUTs cm back. A stale metadata.resourceVersion is
+// rejected with 409 Conflict.
+func (c *Client) UpdateConfigMap(ctx context.Context, cm *ConfigMap) (*ConfigMap, error) {
+	if cm == nil || cm.Metadata.Name == "" || cm.Metadata.Namespace == "" {
+		return nil, fmt.Errorf("UpdateConfigMap: metadata.name and metadata.namespace are required")
+	}
+	cm.APIVersion = "v1"
+	cm.Kind = "ConfigMap"
+	var out ConfigMap
+	if err := c.do(ctx, "PUT", corePath(cm.Metadata.Namespace, "configmaps", cm.Metadata.Name), cm, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) DeleteConfigMap(ctx context.Context, ns, name string) error {
+	return c.do(ctx, "DELETE", corePath(ns, "configmaps", name), nil, nil, "")
+}
+
+// ResourceQuota names the platform writes into a Project namespace.
+// HNC projects the Organization's HierarchicalResourceQuota into every
+// Project as HRQProjectionQuota, with the Organization-wide hard and
+// used; ProjectCapQuota is an administrator's optional per-Project cap.
+const (
+	HRQProjectionQuota = "hrq.hnc.x-k8s.io"
+	ProjectCapQuota    = "project-quota"
+)
+
+// ResourceQuota values are Kubernetes quantities keyed by resource
+// name ("requests.cpu", "pods", "<class>.deviceclass.resource.k8s.io/devices").
+type ResourceQuota struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Status   struct {
+		Hard map[string]string `json:"hard,omitempty"`
+		Used map[string]string `json:"used,omitempty"`
+	} `json:"status,omitempty"`
+}
+
+type ResourceQuotaList struct {
+	Items []ResourceQuota `json:"items"`
+}
+
+func (c *Client) ListResourceQuotas(ctx context.Context, ns string) (*ResourceQuotaList, error) {
+	var out ResourceQuotaList
+	if err := c.do(ctx, "GET", corePath(ns, "resourcequotas", ""), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,199 @@
+// Typed direct-K8s wrappers for db.kube-dc.com/v1alpha1 KdcDatabase,
+// the managed PostgreSQL / MariaDB instance db-manager reconciles into
+// a CNPG Cluster or a MariaDB CR. Standard Project roles: admin and
+// developer get full CRUD, project-manager and user read only.
+
+package k8sapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+const (
+	dbGroup             = "db.kube-dc.com"
+	dbVersion           = "v1alpha1"
+	kdcDatabaseResource = "kdcdatabases"
+)
+
+// Database engines (spec.engine enum).
+const (
+	DBEnginePostgreSQL = "postgresql"
+	DBEngineMariaDB    = "mariadb"
+)
+
+type KdcDatabase struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   ObjectMeta        `json:"metadata"`
+	Spec       KdcDatabaseSpec   `json:"spec"`
+	Status     KdcDatabaseStatus `json:"status,omitempty"`
+}
+
+type KdcDatabaseSpec struct {
+	Engine           string              `json:"engine"`
+	Version          string              `json:"version"`
+	Replicas         int                 `json:"replicas,omitempty"`
+	CPU              Quantity            `json:"cpu"`
+	Memory           Quantity            `json:"memory"`
+	Storage          Quantity            `json:"storage"`
+	StorageClassName string              `json:"storageClassName,omitempty"`
+	DatabaseName     string              `json:"databaseName,omitempty"`
+	Username         string              `json:"username,omitempty"`
+	Expose           *KdcDatabaseExpose  `json:"expose,omitempty"`
+	Backup           *KdcDatabaseBackup  `json:"backup,omitempty"`
+	Parameters       map[string]string   `json:"parameters,omitempty"`
+	RestoreFrom      *KdcDatabaseRestore `json:"restoreFrom,omitempty"`
+}
+
+// KdcDatabaseExpose.Type is internal, loadbalancer or gateway.
+type KdcDatabaseExpose struct {
+	Type string `json:"type"`
+}
+
+// KdcDatabaseBackup carries the fields the CLI sets; encryption and
+// the S3 overrides stay manifest-only.
+type KdcDatabaseBackup struct {
+	Enabled       bool   `json:"enabled"`
+	Schedule      string `json:"schedule,omitempty"`
+	RetentionDays int    `json:"retentionDays,omitempty"`
+}
+
+// KdcDatabaseRestore bootstraps a new database from an engine backup.
+type KdcDatabaseRestore struct {
+	BackupName         string `json:"backupName"`
+	SourceDatabaseName string `json:"sourceDatabaseName,omitempty"`
+	TargetTime         string `json:"targetTime,omitempty"`
+}
+
+// KdcDatabaseStatus.Phase is Pending, Provisioning, Ready, Upgrading
+// or Failed.
+type KdcDatabaseStatus struct {
+	Phase                 string                    `json:"phase,omitempty"`
+	Endpoint              string                    `json:"endpoint,omitempty"`
+	ExternalEndpoint      string                    `json:"externalEndpoint,omitempty"`
+	Version               string                    `json:"version,omitempty"`
+	Instances             int                       `json:"instances,omitempty"`
+	ReadyInstances        int                       `json:"readyInstances,omitempty"`
+	StorageUsed           string                    `json:"storageUsed,omitempty"`
+	LatestBackup          string                    `json:"latestBackup,omitempty"`
+	BackupDestinationPath string                    `json:"backupDestinationPath,omitempty"`
+	ObservedGeneration    int64                     `json:"observedGeneration,omitempty"`
+	Conditions            []Condition               `json:"conditions,omitempty"`
+	Restore               *KdcDatabaseRestoreStatus `json:"restore,omitempty"`
+}
+
+// KdcDatabaseRestoreStatus tracks the most recent restore. Phase is
+// InProgress, Succeeded or Failed.
+type KdcDatabaseRestoreStatus struct {
+	BackupName         string `json:"backupName"`
+	SourceDatabaseName string `json:"sourceDatabaseName,omitempty"`
+	Phase              string `json:"phase"`
+	Message            string `json:"message,omitempty"`
+	StartedAt          string `json:"startedAt,omitempty"`
+	CompletedAt        string `json:"completedAt,omitempty"`
+	TargetTime         string `json:"targetTime,omitempty"`
+}
+
+type KdcDatabaseList struct {
+	Items []KdcDatabase `json:"items"`
+}
+
+// Quantity is an int-or-string resource quantity ("2Gi", "500m", 1).
+// It always marshals as a string.
+type Quantity string
+
+func (q *Quantity) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*q = Quantity(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return fmt.Errorf("quantity: %s is neither a string nor a number", b)
+	}
+	*q = Quantity(n.String())
+	return nil
+}
+
+func kdcDatabasePath(ns, name string) string {
+	p := fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s", dbGroup, dbVersion, url.PathEscape(ns), kdcDatabaseResource)
+	if name != "" {
+		p += "/" + url.PathEscape(name)
+	}
+	return p
+}
+
+func (c *Client) ListKdcDatabases(ctx context.Context, ns string) (*KdcDatabaseList, error) {
+	var out KdcDatabaseList
+	if err := c.do(ctx, "GET", kdcDatabasePath(ns, ""), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) GetKdcDatabase(ctx context.Context, ns, name string) (*KdcDatabase, error) {
+	var out KdcDatabase
+	if err := c.do(ctx, "GET", kdcDatabasePath(ns, name), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) CreateKdcDatabase(ctx context.Context, db *KdcDatabase) (*KdcDatabase, error) {
+	if db.APIVersion == "" {
+		db.APIVersion = dbGroup + "/" + dbVersion
+	}
+	if db.Kind == "" {
+		db.Kind = "KdcDatabase"
+	}
+	var out KdcDatabase
+	if err := c.do(ctx, "POST", kdcDatabasePath(db.Metadata.Namespace, ""), db, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PatchKdcDatabase applies a JSON merge patch, e.g.
+// {"spec":{"storage":"40Gi"}}.
+func (c *Client) PatchKdcDatabase(ctx context.Context, ns, name string, patch map[string]any) (*KdcDatabase, error) {
+	var out KdcDatabase
+	if err := c.do(ctx, "PATCH", kdcDatabasePath(ns, name), patch, &out, "application/merge-patch+json"); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) DeleteKdcDatabase(ctx context.Context, ns, name string) error {
+	return c.do(ctx, "DELETE", kdcDatabasePath(ns, name), nil, nil, "")
+}
+
+// DBServicePort is the engine's listening port.
+func DBServicePort(engine string) int {
+	if engine == DBEngineMariaDB {
+		return 3306
+	}
+	return 5432
+}
+
+// WriteServiceName is the Service that routes to the writable
+// instance: CNPG's <name>-rw, or the MariaDB operator's <name>-primary
+// once there is more than one replica.
+func (db *KdcDatabase) WriteServiceName() string {
+	switch {
+	case db.Spec.Engine != DBEngineMariaDB:
+		return db.Metadata.Name + "-rw"
+	case db.Spec.Replicas > 1:
+		return db.Metadata.Name + "-primary"
+	}
+	return db.Metadata.Name
+}
+
+// InternalEndpoint is host:port of the write Service inside the cluster.
+func (db *KdcDatabase) InternalEndpoint() string {
+	return fmt.Sprintf("%s.%s.svc:%s", db.WriteServiceName(), db.Metadata.Namespace, strconv.Itoa(DBServicePort(db.Spec.Engine)))
+}
@@ -0,0 +1,184 @@
+// Typed direct-K8s wrappers for the engine backup CRs behind a
+// KdcDatabase: CNPG's postgresql.cnpg.io/v1 Backup (plus the Cluster,
+// read for its WAL recoverability window) and the mariadb-operator's
+// k8s.mariadb.com/v1alpha1 PhysicalBackup. Standard Project roles:
+// admin and developer create, get, list and delete backups;
+// project-manager and user read only.
+//
+// A restore itself is not an engine CR: it is spec.restoreFrom on a new
+// KdcDatabase, or the kube-dc.com/restore-from annotation on an
+// existing one (databases.go).
+
+package k8sapi
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+const (
+	cnpgAPIVersion         = "postgresql.cnpg.io/v1"
+	mariadbAPIVersion      = "k8s.mariadb.com/v1alpha1"
+	pgBackupResource       = "backups"
+	pgClusterResource      = "clusters"
+	physicalBackupResource = "physicalbackups"
+)
+
+// Restore trigger annotations on an existing KdcDatabase. db-manager
+// clears both once status.restore.phase reaches Succeeded.
+const (
+	RestoreFromAnnotation       = "kube-dc.com/restore-from"
+	RestoreTargetTimeAnnotation = "kube-dc.com/restore-target-time"
+)
+
+// PGBackup is a CNPG base backup. CNPG archives WAL continuously next
+// to it, which is what makes point-in-time recovery possible.
+type PGBackup struct {
+	APIVersion string         `json:"apiVersion"`
+	Kind       string         `json:"kind"`
+	Metadata   ObjectMeta     `json:"metadata"`
+	Spec       PGBackupSpec   `json:"spec"`
+	Status     PGBackupStatus `json:"status,omitempty"`
+}
+
+type PGBackupSpec struct {
+	Cluster struct {
+		Name string `json:"name"`
+	} `json:"cluster"`
+	Method string `json:"method,omitempty"`
+}
+
+// PGBackupStatus.Phase is pending, started, running, finalizing,
+// completed, failed or walArchivingFailing.
+type PGBackupStatus struct {
+	Phase     string `json:"phase,omitempty"`
+	Method    string `json:"method,omitempty"`
+	BeginWAL  string `json:"beginWal,omitempty"`
+	EndWAL    string `json:"endWal,omitempty"`
+	StartedAt string `json:"startedAt,omitempty"`
+	StoppedAt string `json:"stoppedAt,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type PGBackupList struct {
+	Items []PGBackup `json:"items"`
+}
+
+// PGCluster is the slice of a CNPG Cluster the CLI reads: the
+// recoverability window and the ContinuousArchiving condition.
+type PGCluster struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Status   struct {
+		FirstRecoverabilityPoint string      `json:"firstRecoverabilityPoint,omitempty"`
+		LastSuccessfulBackup     string      `json:"lastSuccessfulBackup,omitempty"`
+		Conditions               []Condition `json:"conditions,omitempty"`
+	} `json:"status,omitempty"`
+}
+
+// PhysicalBackup is a mariadb-operator physical backup. db-manager
+// keeps a scheduled one named <database>-scheduled; its Storage block
+// is what an on-demand backup copies, so it stays untyped.
+type PhysicalBackup struct {
+	APIVersion string               `json:"apiVersion"`
+	Kind       string               `json:"kind"`
+	Metadata   ObjectMeta           `json:"metadata"`
+	Spec       PhysicalBackupSpec   `json:"spec"`
+	Status     PhysicalBackupStatus `json:"status,omitempty"`
+}
+
+type PhysicalBackupSpec struct {
+	MariaDBRef struct {
+		Name string `json:"name"`
+	} `json:"mariaDbRef"`
+	Target       string         `json:"target,omitempty"`
+	BackoffLimit int            `json:"backoffLimit,omitempty"`
+	Schedule     map[string]any `json:"schedule,omitempty"`
+	Storage      map[string]any `json:"storage,omitempty"`
+}
+
+// PhysicalBackupStatus reports progress through the Complete condition.
+type PhysicalBackupStatus struct {
+	Conditions       []Condition `json:"conditions,omitempty"`
+	LastScheduleTime string      `json:"lastScheduleTime,omitempty"`
+}
+
+type PhysicalBackupList struct {
+	Items []PhysicalBackup `json:"items"`
+}
+
+func groupPath(apiVersion, ns, resource, name string) string {
+	p := fmt.Sprintf("/apis/%s/namespaces/%s/%s", apiVersion, url.PathEscape(ns), resource)
+	if name != "" {
+		p += "/" + url.PathEscape(name)
+	}
+	return p
+}
+
+func (c *Client) ListPGBackups(ctx context.Context, ns string) (*PGBackupList, error) {
+	var out PGBackupList
+	if err := c.do(ctx, "GET", groupPath(cnpgAPIVersion, ns, pgBackupResource, ""), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) GetPGBackup(ctx context.Context, ns, name string) (*PGBackup, error) {
+	var out PGBackup
+	if err := c.do(ctx, "GET", groupPath(cnpgAPIVersion, ns, pgBackupResource, name), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) CreatePGBackup(ctx context.Context, b *PGBackup) (*PGBackup, error) {
+	if b.APIVersion == "" {
+		b.APIVersion = cnpgAPIVersion
+	}
+	if b.Kind == "" {
+		b.Kind = "Backup"
+	}
+	var out PGBackup
+	if err := c.do(ctx, "POST", groupPath(cnpgAPIVersion, b.Metadata.Namespace, pgBackupResource, ""), b, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) GetPGCluster(ctx context.Context, ns, name string) (*PGCluster, error) {
+	var out PGCluster
+	if err := c.do(ctx, "GET", groupPath(cnpgAPIVersion, ns, pgClusterResource, name), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) ListPhysicalBackups(ctx context.Context, ns string) (*PhysicalBackupList, error) {
+	var out PhysicalBackupList
+	if err := c.do(ctx, "GET", groupPath(mariadbAPIVersion, ns, physicalBackupResource, ""), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) GetPhysicalBackup(ctx context.Context, ns, name string) (*PhysicalBackup, error) {
+	var out PhysicalBackup
+	if err := c.do(ctx, "GET", groupPath(mariadbAPIVersion, ns, physicalBackupResource, name), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) CreatePhysicalBackup(ctx context.Context, b *PhysicalBackup) (*PhysicalBackup, error) {
+	if b.APIVersion == "" {
+		b.APIVersion = mariadbAPIVersion
+	}
+	if b.Kind == "" {
+		b.Kind = "PhysicalBackup"
+	}
+	var out PhysicalBackup
+	if err := c.do(ctx, "POST", groupPath(mariadbAPIVersion, b.Metadata.Namespace, physicalBackupResource, ""), b, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,49 @@
+// Typed direct-K8s wrapper for the slice of apps/v1 Deployment the CLI
+// reads when exposing one: its selector and the ports its containers
+// declare.
+
+package k8sapi
+
+import "context"
+
+const (
+	appsAPIVersion     = "apps/v1"
+	deploymentResource = "deployments"
+)
+
+type Deployment struct {
+	Metadata ObjectMeta     `json:"metadata"`
+	Spec     DeploymentSpec `json:"spec"`
+}
+
+// DeploymentSpec.Selector.MatchExpressions is left untyped: a Service
+// selector can only carry MatchLabels, so the CLI only needs to know
+// whether expressions are present.
+type DeploymentSpec struct {
+	Selector struct {
+		MatchLabels      map[string]string `json:"matchLabels,omitempty"`
+		MatchExpressions []any             `json:"matchExpressions,omitempty"`
+	} `json:"selector"`
+	Template struct {
+		Spec struct {
+			Containers []struct {
+				Name  string          `json:"name"`
+				Ports []ContainerPort `json:"ports,omitempty"`
+			} `json:"containers"`
+		} `json:"spec"`
+	} `json:"template"`
+}
+
+type ContainerPort struct {
+	Name          string `json:"name,omitempty"`
+	ContainerPort int    `json:"containerPort"`
+	Protocol      string `json:"protocol,omitempty"`
+}
+
+func (c *Client) GetDeployment(ctx context.Context, ns, name string) (*Deployment, error) {
+	var out Deployment
+	if err := c.do(ctx, "GET", groupPath(appsAPIVersion, ns, deploymentResource, name), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
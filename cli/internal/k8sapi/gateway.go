@@ -0,0 +1,102 @@
+// Typed direct-K8s wrappers for the Gateway API routes the platform's
+// Service controller generates from the service.nlb.kube-dc.com/
+// expose-route annotation (HTTPRoute for http and https, TLSRoute for
+// tls-passthrough), and for the cert-manager Issuer an https route
+// without a certificate of its own is issued from. The CLI only reads
+// them: it finds a route by its backendRef and waits for the Gateway
+// to accept it.
+
+package k8sapi
+
+import "context"
+
+const (
+	gatewayAPIVersion      = "gateway.networking.k8s.io/v1"
+	gatewayAlphaAPIVersion = "gateway.networking.k8s.io/v1alpha2"
+	certManagerAPIVersion  = "cert-manager.io/v1"
+	httpRouteResource      = "httproutes"
+	tlsRouteResource       = "tlsroutes"
+	issuerResource         = "issuers"
+)
+
+// Route annotations on a LoadBalancer Service. ExposeRoute is http,
+// https or tls-passthrough; the controller writes the hostname it
+// serves back to RouteHostnameStatus. An https route uses TLSSecret
+// when set, else a certificate from TLSIssuer (default letsencrypt).
+const (
+	ExposeRouteAnnotation         = "service.nlb.kube-dc.com/expose-route"
+	RouteHostnameAnnotation       = "service.nlb.kube-dc.com/route-hostname"
+	RouteHostnameStatusAnnotation = "service.nlb.kube-dc.com/route-hostname-status"
+	RoutePortAnnotation           = "service.nlb.kube-dc.com/route-port"
+	TLSIssuerAnnotation           = "service.nlb.kube-dc.com/tls-issuer"
+	TLSSecretAnnotation           = "service.nlb.kube-dc.com/tls-secret"
+)
+
+// GatewayRoute is the part HTTPRoute and TLSRoute have in common.
+type GatewayRoute struct {
+	Kind     string             `json:"kind,omitempty"`
+	Metadata ObjectMeta         `json:"metadata"`
+	Spec     GatewayRouteSpec   `json:"spec"`
+	Status   GatewayRouteStatus `json:"status,omitempty"`
+}
+
+type GatewayRouteSpec struct {
+	Hostnames  []string `json:"hostnames,omitempty"`
+	ParentRefs []struct {
+		Name        string `json:"name"`
+		Namespace   string `json:"namespace,omitempty"`
+		SectionName string `json:"sectionName,omitempty"`
+	} `json:"parentRefs,omitempty"`
+	Rules []struct {
+		BackendRefs []struct {
+			Kind string `json:"kind,omitempty"`
+			Name string `json:"name"`
+			Port int    `json:"port,omitempty"`
+		} `json:"backendRefs,omitempty"`
+	} `json:"rules,omitempty"`
+}
+
+// GatewayRouteStatus has one entry per parent Gateway; Accepted and
+// ResolvedRefs are the conditions that matter.
+type GatewayRouteStatus struct {
+	Parents []struct {
+		ControllerName string      `json:"controllerName,omitempty"`
+		Conditions     []Condition `json:"conditions,omitempty"`
+	} `json:"parents,omitempty"`
+}
+
+type GatewayRouteList struct {
+	Items []GatewayRoute `json:"items"`
+}
+
+// Issuer is read only to learn whether it exists and is Ready.
+type Issuer struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Status   struct {
+		Conditions []Condition `json:"conditions,omitempty"`
+	} `json:"status,omitempty"`
+}
+
+func (c *Client) ListHTTPRoutes(ctx context.Context, ns string) (*GatewayRouteList, error) {
+	var out GatewayRouteList
+	if err := c.do(ctx, "GET", groupPath(gatewayAPIVersion, ns, httpRouteResource, ""), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) ListTLSRoutes(ctx context.Context, ns string) (*GatewayRouteList, error) {
+	var out GatewayRouteList
+	if err := c.do(ctx, "GET", groupPath(gatewayAlphaAPIVersion, ns, tlsRouteResource, ""), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) GetIssuer(ctx context.Context, ns, name string) (*Issuer, error) {
+	var out Issuer
+	if err := c.do(ctx, "GET", groupPath(certManagerAPIVersion, ns, issuerResource, name), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
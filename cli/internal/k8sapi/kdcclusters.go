@@ -0,0 +1,251 @@
+// Typed direct-K8s wrappers for k8s.kube-dc.com/v1alpha1 KdcCluster,
+// the Managed Cluster: a Kamaji control plane plus Cluster API worker
+// pools (KubevirtMachineTemplate + MachineDeployment per pool) that the
+// kdc-cluster controller reconciles in the Project's backing namespace.
+// Worker pools are a list, so changes go through JSON Patch — a merge
+// patch would replace every pool.
+
+package k8sapi
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	kdcClusterAPIVersion = "k8s.kube-dc.com/v1alpha1"
+	kdcClusterResource   = "kdcclusters"
+
+	// KdcClusterExposeRouteAnnotation ("true") publishes the Managed
+	// Cluster's API through a TLSRoute and creates the external
+	// kubeconfig Secret.
+	KdcClusterExposeRouteAnnotation = "k8s.kube-dc.com/expose-route"
+	// KdcClusterExternalKubeconfigKey is the data key of the
+	// {cluster}-cp-admin-kubeconfig-external Secret.
+	KdcClusterExternalKubeconfigKey = "admin.conf"
+)
+
+// Worker infrastructure providers (spec.workers[].infrastructureProvider).
+const (
+	WorkerProviderKubeVirt   = "kubevirt"
+	WorkerProviderCloudSigma = "cloudsigma"
+)
+
+type KdcCluster struct {
+	APIVersion string           `json:"apiVersion"`
+	Kind       string           `json:"kind"`
+	Metadata   ObjectMeta       `json:"metadata"`
+	Spec       KdcClusterSpec   `json:"spec"`
+	Status     KdcClusterStatus `json:"status,omitempty"`
+}
+
+// KdcClusterSpec carries the fields the CLI sets or reads; backup,
+// kubelet tuning, CloudSigma placement and the control-plane VPA stay
+// manifest-only.
+type KdcClusterSpec struct {
+	Version          string                `json:"version"`
+	ControlPlane     *KdcControlPlaneSpec  `json:"controlPlane,omitempty"`
+	DataStore        *KdcClusterDataStore  `json:"dataStore,omitempty"`
+	Network          *KdcClusterNetwork    `json:"network,omitempty"`
+	EIP              *KdcClusterEIP        `json:"eip,omitempty"`
+	EnableClusterAPI bool                  `json:"enableClusterAPI,omitempty"`
+	Workers          []WorkerPool          `json:"workers,omitempty"`
+	Encryption       *KdcClusterEncryption `json:"encryption,omitempty"`
+}
+
+type KdcControlPlaneSpec struct {
+	Replicas int `json:"replicas,omitempty"`
+}
+
+type KdcClusterDataStore struct {
+	Dedicated bool   `json:"dedicated,omitempty"`
+	EIPName   string `json:"eipName,omitempty"`
+	Port      int    `json:"port,omitempty"`
+}
+
+type KdcClusterNetwork struct {
+	ServiceCIDR string `json:"serviceCIDR,omitempty"`
+	PodCIDR     string `json:"podCIDR,omitempty"`
+}
+
+// KdcClusterEIP.ExternalNetworkType is cloud or public.
+type KdcClusterEIP struct {
+	Create              bool   `json:"create"`
+	ExternalNetworkType string `json:"externalNetworkType,omitempty"`
+}
+
+type KdcClusterEncryption struct {
+	Etcd *KdcClusterEtcdEncryption `json:"etcd,omitempty"`
+}
+
+type KdcClusterEtcdEncryption struct {
+	Enabled bool `json:"enabled"`
+}
+
+// WorkerPool is one spec.workers entry. Version, when set, pins the
+// pool behind spec.version (at most two minors, never ahead).
+type WorkerPool struct {
+	Name                   string             `json:"name"`
+	Replicas               int                `json:"replicas"`
+	CPUCores               int                `json:"cpuCores,omitempty"`
+	Memory                 string             `json:"memory,omitempty"`
+	DiskSize               string             `json:"diskSize,omitempty"`
+	Image                  string             `json:"image,omitempty"`
+	Architecture           string             `json:"architecture,omitempty"`
+	InfrastructureProvider string             `json:"infrastructureProvider,omitempty"`
+	StorageType            string             `json:"storageType,omitempty"`
+	Version                string             `json:"version,omitempty"`
+	Paused                 bool               `json:"paused,omitempty"`
+	Autoscaling            *WorkerAutoscaling `json:"autoscaling,omitempty"`
+}
+
+// WorkerAutoscaling.Mode is empty (PendingPods, grow-only) or
+// ClusterAutoscaler, in which the platform owns replicas.
+type WorkerAutoscaling struct {
+	Enabled     bool   `json:"enabled"`
+	Mode        string `json:"mode,omitempty"`
+	MinReplicas int    `json:"minReplicas,omitempty"`
+	MaxReplicas int    `json:"maxReplicas,omitempty"`
+}
+
+// KdcClusterStatus.Phase is Pending, WaitingForService, Provisioning,
+// Ready or Failed.
+type KdcClusterStatus struct {
+	Phase             string                  `json:"phase,omitempty"`
+	Endpoint          string                  `json:"endpoint,omitempty"`
+	PublicAPIEndpoint string                  `json:"publicApiEndpoint,omitempty"`
+	DataStoreName     string                  `json:"dataStoreName,omitempty"`
+	ControlPlaneReady bool                    `json:"controlPlaneReady,omitempty"`
+	ControlPlane      *KdcControlPlaneStatus  `json:"controlPlane,omitempty"`
+	WorkerPools       []WorkerPoolStatus      `json:"workerPools,omitempty"`
+	Conditions        []Condition             `json:"conditions,omitempty"`
+	Encryption        *KdcClusterEncryptState `json:"encryption,omitempty"`
+}
+
+// KdcControlPlaneStatus.Version is the version the control plane runs;
+// it lags spec.version while a control-plane upgrade rolls out.
+type KdcControlPlaneStatus struct {
+	Version string `json:"version,omitempty"`
+}
+
+// WorkerPoolStatus.ObservedVersion is what the pool's Machines run;
+// Version is what they are heading to.
+type WorkerPoolStatus struct {
+	Name            string                   `json:"name"`
+	Phase           string                   `json:"phase,omitempty"`
+	Replicas        int                      `json:"replicas"`
+	ReadyReplicas   int                      `json:"readyReplicas"`
+	UpdatedReplicas int                      `json:"updatedReplicas,omitempty"`
+	Version         string                   `json:"version,omitempty"`
+	ObservedVersion string                   `json:"observedVersion,omitempty"`
+	Autoscaling     *WorkerAutoscalingStatus `json:"autoscaling,omitempty"`
+}
+
+// WorkerAutoscalingStatus.LimitedBy is Max, Quota, Placement or
+// RollingUpdate when the loop wanted to scale and could not.
+type WorkerAutoscalingStatus struct {
+	Mode            string `json:"mode,omitempty"`
+	LastScaleReason string `json:"lastScaleReason,omitempty"`
+	LimitedBy       string `json:"limitedBy,omitempty"`
+}
+
+type KdcClusterEncryptState struct {
+	ResolvedKeyRef string `json:"resolvedKeyRef,omitempty"`
+}
+
+type KdcClusterList struct {
+	Items []KdcCluster `json:"items"`
+}
+
+// JSONPatchOp is one RFC 6902 operation. Value is always sent, so a
+// replace to 0 or false survives encoding; "test" operations make a
+// patch fail instead of landing on a list entry that has moved.
+type JSONPatchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value"`
+}
+
+// KdcClusterExternalKubeconfigSecret is the Secret holding the
+// workstation kubeconfig; it exists only while the API is exposed.
+func KdcClusterExternalKubeconfigSecret(cluster string) string {
+	return cluster + "-cp-admin-kubeconfig-external"
+}
+
+func (c *Client) ListKdcClusters(ctx context.Context, ns string) (*KdcClusterList, error) {
+	var out KdcClusterList
+	if err := c.do(ctx, "GET", groupPath(kdcClusterAPIVersion, ns, kdcClusterResource, ""), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) GetKdcCluster(ctx context.Context, ns, name string) (*KdcCluster, error) {
+	var out KdcCluster
+	if err := c.do(ctx, "GET", groupPath(kdcClusterAPIVersion, ns, kdcClusterResource, name), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) CreateKdcCluster(ctx context.Context, kc *KdcCluster) (*KdcCluster, error) {
+	if kc.APIVersion == "" {
+		kc.APIVersion = kdcClusterAPIVersion
+	}
+	if kc.Kind == "" {
+		kc.Kind = "KdcCluster"
+	}
+	var out KdcCluster
+	if err := c.do(ctx, "POST", groupPath(kdcClusterAPIVersion, kc.Metadata.Namespace, kdcClusterResource, ""), kc, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PatchKdcClusterJSON applies a JSON Patch, e.g.
+// [{"op":"replace","path":"/spec/workers/0/replicas","value":5}].
+func (c *Client) PatchKdcClusterJSON(ctx context.Context, ns, name string, ops []JSONPatchOp) (*KdcCluster, error) {
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("patch KdcCluster %s: no operations", name)
+	}
+	var out KdcCluster
+	if err := c.do(ctx, "PATCH", groupPath(kdcClusterAPIVersion, ns, kdcClusterResource, name), ops, &out, "application/json-patch+json"); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) DeleteKdcCluster(ctx context.Context, ns, name string) error {
+	return c.do(ctx, "DELETE", groupPath(kdcClusterAPIVersion, ns, kdcClusterResource, name), nil, nil, "")
+}
+
+// WorkerPoolIndex returns the spec.workers index of the named pool,
+// or -1.
+func (kc *KdcCluster) WorkerPoolIndex(name string) int {
+	for i := range kc.Spec.Workers {
+		if kc.Spec.Workers[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// WorkerPoolStatus returns the status entry of the named pool, or nil
+// before the controller has reported it.
+func (kc *KdcCluster) WorkerPoolStatus(name string) *WorkerPoolStatus {
+	for i := range kc.Status.WorkerPools {
+		if kc.Status.WorkerPools[i].Name == name {
+			return &kc.Status.WorkerPools[i]
+		}
+	}
+	return nil
+}
+
+// ControlPlaneVersion is the version the control plane runs, falling
+// back to spec.version before the controller has reported one.
+func (kc *KdcCluster) ControlPlaneVersion() string {
+	if kc.Status.ControlPlane != nil && kc.Status.ControlPlane.Version != "" {
+		return kc.Status.ControlPlane.Version
+	}
+	return kc.Spec.Version
+}
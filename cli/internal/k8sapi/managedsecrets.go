@@ -32,6 +32,8 @@ type ObjectMeta struct {
 	Name              string            `json:"name"`
 	Namespace         string            `json:"namespace"`
 	CreationTimestamp string            `json:"creationTimestamp,omitempty"`
+	ResourceVersion   string            `json:"resourceVersion,omitempty"`
+	Generation        int64             `json:"generation,omitempty"`
 	Annotations       map[string]string `json:"annotations,omitempty"`
 	Labels            map[string]string `json:"labels,omitempty"`
 }
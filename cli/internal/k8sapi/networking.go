@@ -0,0 +1,189 @@
+// Typed direct-K8s wrappers for the kube-dc.com/v1 networking
+// resources a Project owns: EIp (an address from an external pool) and
+// FIp (one-to-one NAT from such an address to a VM interface or an
+// internal IP). Both live in the Project backing namespace. Standard
+// Project roles: admin and developer create, get, list and delete
+// both; neither role may update them, so re-pointing an address means
+// a new FIp.
+
+package k8sapi
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+const (
+	eipResource = "eips"
+	fipResource = "fips"
+)
+
+// BindOnEIpAnnotation on a LoadBalancer Service names the EIp the
+// Service is exposed on; several Services may share one EIp as long
+// as their ports differ.
+const BindOnEIpAnnotation = "service.nlb.kube-dc.com/bind-on-eip"
+
+// ExternalNetworkTypeAnnotation (public or cloud) on a new LoadBalancer
+// Service without a bind annotation makes it allocate an EIp of its
+// own from that pool. It is immutable once the address exists.
+const ExternalNetworkTypeAnnotation = "network.kube-dc.com/external-network-type"
+
+// BindOnDefaultGwEIpAnnotation ("true") exposes a LoadBalancer Service
+// on the Project's gateway EIp, DefaultGwEIp, which the Project
+// controller allocates and owns.
+const (
+	BindOnDefaultGwEIpAnnotation = "service.nlb.kube-dc.com/bind-on-default-gw-eip"
+	DefaultGwEIp                 = "default-gw"
+)
+
+type EIp struct {
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Metadata   ObjectMeta `json:"metadata"`
+	Spec       EIpSpec    `json:"spec"`
+	Status     EIpStatus  `json:"status,omitempty"`
+}
+
+// EIpSpec.ExternalNetworkType is "public" or "cloud" and is immutable
+// once the address is allocated. ChildRef is set instead on an EIp a
+// controller allocated on behalf of another object (a FIp created with
+// externalNetworkType).
+type EIpSpec struct {
+	ExternalNetworkType string `json:"externalNetworkType,omitempty"`
+	ChildRef            string `json:"childRef,omitempty"`
+}
+
+// EIpStatus.IPAddress is non-empty once the EIp is Ready.
+// OwnershipType is Released, Exclusive or Shared; Owners lists what
+// currently uses the address.
+type EIpStatus struct {
+	Ready         bool        `json:"ready,omitempty"`
+	IPAddress     string      `json:"ipAddress,omitempty"`
+	OwnershipType string      `json:"ownershipType,omitempty"`
+	Owners        []EIpOwner  `json:"owners,omitempty"`
+	Conditions    []Condition `json:"conditions,omitempty"`
+}
+
+type EIpOwner struct {
+	Type     string `json:"type,omitempty"`
+	OwnerRef string `json:"ownerRef,omitempty"`
+}
+
+type EIpList struct {
+	Items []EIp `json:"items"`
+}
+
+type FIp struct {
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Metadata   ObjectMeta `json:"metadata"`
+	Spec       FIpSpec    `json:"spec"`
+	Status     FIpStatus  `json:"status,omitempty"`
+}
+
+// FIpSpec sets exactly one of EIp (an existing EIp to use) or
+// ExternalNetworkType (the controller allocates and owns an EIp), and
+// exactly one of VMTarget or IPAddress.
+type FIpSpec struct {
+	EIp                 string       `json:"eip,omitempty"`
+	ExternalNetworkType string       `json:"externalNetworkType,omitempty"`
+	IPAddress           string       `json:"ipAddress,omitempty"`
+	VMTarget            *FIpVMTarget `json:"vmTarget,omitempty"`
+}
+
+type FIpVMTarget struct {
+	VMName        string `json:"vmName"`
+	InterfaceName string `json:"interfaceName,omitempty"`
+}
+
+// FIpStatus: a Ready FIp has both ExternalIP and ResolvedTargetIP.
+// EIp names the address in use, including one the controller
+// allocated for spec.externalNetworkType.
+type FIpStatus struct {
+	Ready             bool         `json:"ready,omitempty"`
+	EIp               string       `json:"eip,omitempty"`
+	ExternalIP        string       `json:"externalIP,omitempty"`
+	ResolvedTargetIP  string       `json:"resolvedTargetIP,omitempty"`
+	TargetVMInterface *FIpVMTarget `json:"targetVMInterface,omitempty"`
+	Conditions        []Condition  `json:"conditions,omitempty"`
+}
+
+type FIpList struct {
+	Items []FIp `json:"items"`
+}
+
+func kdcPath(ns, resource, name string) string {
+	p := fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s", kdcGroup, kdcVersion, url.PathEscape(ns), resource)
+	if name != "" {
+		p += "/" + url.PathEscape(name)
+	}
+	return p
+}
+
+func (c *Client) ListEIps(ctx context.Context, ns string) (*EIpList, error) {
+	var out EIpList
+	if err := c.do(ctx, "GET", kdcPath(ns, eipResource, ""), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) GetEIp(ctx context.Context, ns, name string) (*EIp, error) {
+	var out EIp
+	if err := c.do(ctx, "GET", kdcPath(ns, eipResource, name), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateEIp POSTs a new EIp; metadata.namespace is the Project
+// backing namespace.
+func (c *Client) CreateEIp(ctx context.Context, e *EIp) (*EIp, error) {
+	e.APIVersion = kdcGroup + "/" + kdcVersion
+	e.Kind = "EIp"
+	var out EIp
+	if err := c.do(ctx, "POST", kdcPath(e.Metadata.Namespace, eipResource, ""), e, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteEIp releases the address back to its pool.
+func (c *Client) DeleteEIp(ctx context.Context, ns, name string) error {
+	return c.do(ctx, "DELETE", kdcPath(ns, eipResource, name), nil, nil, "")
+}
+
+func (c *Client) ListFIps(ctx context.Context, ns string) (*FIpList, error) {
+	var out FIpList
+	if err := c.do(ctx, "GET", kdcPath(ns, fipResource, ""), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) GetFIp(ctx context.Context, ns, name string) (*FIp, error) {
+	var out FIp
+	if err := c.do(ctx, "GET", kdcPath(ns, fipResource, name), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateFIp POSTs a new FIp; metadata.namespace is the Project backing
+// namespace.
+func (c *Client) CreateFIp(ctx context.Context, f *FIp) (*FIp, error) {
+	f.APIVersion = kdcGroup + "/" + kdcVersion
+	f.Kind = "FIp"
+	var out FIp
+	if err := c.do(ctx, "POST", kdcPath(f.Metadata.Namespace, fipResource, ""), f, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteFIp removes the NAT. An EIp the controller allocated for the
+// FIp is released with it; one referenced through spec.eip stays.
+func (c *Client) DeleteFIp(ctx context.Context, ns, name string) error {
+	return c.do(ctx, "DELETE", kdcPath(ns, fipResource, name), nil, nil, "")
+}
@@ -0,0 +1,103 @@
+// Typed direct-K8s wrappers for kube-dc.com/v1 OrganizationGroup. A
+// group lives in the Organization namespace and declares which roles
+// its members hold in which Projects; the controller turns that into a
+// Keycloak group of the same name plus one RoleBinding per
+// Project/role pair. Who is in the group is a Keycloak matter — see
+// internal/keycloak.
+
+package k8sapi
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+const orgGroupResource = "organizationgroups"
+
+type OrganizationGroup struct {
+	APIVersion string                `json:"apiVersion"`
+	Kind       string                `json:"kind"`
+	Metadata   ObjectMeta            `json:"metadata"`
+	Spec       OrganizationGroupSpec `json:"spec"`
+}
+
+type OrganizationGroupSpec struct {
+	Permissions []GroupPermission `json:"permissions"`
+}
+
+// GroupPermission grants Roles in one Project. Project is the Project
+// name, not its backing namespace.
+type GroupPermission struct {
+	Project string   `json:"project"`
+	Roles   []string `json:"roles"`
+}
+
+type OrganizationGroupList struct {
+	APIVersion string              `json:"apiVersion"`
+	Kind       string              `json:"kind"`
+	Items      []OrganizationGroup `json:"items"`
+}
+
+func orgGroupBasePath(org string) string {
+	return fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s",
+		kdcGroup, kdcVersion, url.PathEscape(org), orgGroupResource)
+}
+
+func orgGroupItemPath(org, name string) string {
+	return orgGroupBasePath(org) + "/" + url.PathEscape(name)
+}
+
+func (c *Client) ListOrganizationGroups(ctx context.Context, org string) (*OrganizationGroupList, error) {
+	var out OrganizationGroupList
+	if err := c.do(ctx, "GET", orgGroupBasePath(org), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) GetOrganizationGroup(ctx context.Context, org, name string) (*OrganizationGroup, error) {
+	var out OrganizationGroup
+	if err := c.do(ctx, "GET", orgGroupItemPath(org, name), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateOrganizationGroup POSTs a new group; metadata.namespace is the
+// Organization. APIVersion/Kind are stamped here.
+func (c *Client) CreateOrganizationGroup(ctx context.Context, g *OrganizationGroup) (*OrganizationGroup, error) {
+	if g == nil || g.Metadata.Name == "" || g.Metadata.Namespace == "" {
+		return nil, fmt.Errorf("CreateOrganizationGroup: metadata.name and metadata.namespace are required")
+	}
+	g.APIVersion = kdcGroup + "/" + kdcVersion
+	g.Kind = "OrganizationGroup"
+	var out OrganizationGroup
+	if err := c.do(ctx, "POST", orgGroupBasePath(g.Metadata.Namespace), g, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SetOrganizationGroupPermissions replaces spec.permissions with a
+// merge patch (lists are replaced whole). A patch rather than a PUT so
+// fields this partial type does not model — finalizers above all — are
+// left alone; resourceVersion in the patch still makes a concurrent
+// edit fail with 409 instead of being overwritten.
+func (c *Client) SetOrganizationGroupPermissions(ctx context.Context, org, name, resourceVersion string, perms []GroupPermission) (*OrganizationGroup, error) {
+	if resourceVersion == "" {
+		return nil, fmt.Errorf("SetOrganizationGroupPermissions: resourceVersion is required")
+	}
+	if perms == nil {
+		perms = []GroupPermission{}
+	}
+	patch := map[string]any{
+		"metadata": map[string]any{"resourceVersion": resourceVersion},
+		"spec":     map[string]any{"permissions": perms},
+	}
+	var out OrganizationGroup
+	if err := c.do(ctx, "PATCH", orgGroupItemPath(org, name), patch, &out, "application/merge-patch+json"); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
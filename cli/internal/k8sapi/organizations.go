@@ -0,0 +1,71 @@
+// Typed direct-K8s wrapper for the slice of kube-dc.com/v1
+// Organization the CLI reads: the controller's quota summary and the
+// billing annotations it is computed from. An Organization lives in
+// the namespace named after it.
+
+package k8sapi
+
+import "context"
+
+const organizationResource = "organizations"
+
+// Billing annotations on an Organization. The controller derives the
+// Organization quota from the plan, the add-ons (a JSON array of
+// {"addonId", "quantity"}) and the subscription state.
+const (
+	BillingPlanIDAnnotation       = "billing.kube-dc.com/plan-id"
+	BillingPlanNameAnnotation     = "billing.kube-dc.com/plan-name"
+	BillingSubscriptionAnnotation = "billing.kube-dc.com/subscription"
+	BillingAddonsAnnotation       = "billing.kube-dc.com/addons"
+)
+
+// BillingAddon is one entry of BillingAddonsAnnotation.
+type BillingAddon struct {
+	AddonID  string `json:"addonId"`
+	Quantity int    `json:"quantity"`
+}
+
+type Organization struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Status   struct {
+		// QuotaEnforced is false while no plan quota is applied, in
+		// which case nothing limits the Organization.
+		QuotaEnforced bool                    `json:"quotaEnforced,omitempty"`
+		QuotaPlanID   string                  `json:"quotaPlanId,omitempty"`
+		QuotaUsage    *OrganizationQuotaUsage `json:"quotaUsage,omitempty"`
+	} `json:"status,omitempty"`
+}
+
+// OrganizationQuotaUsage is summed across the Organization's Projects
+// and refreshed periodically, so it can lag a create by a minute.
+// PublicIPv4 and ObjectStorage are accounted outside ResourceQuota; an
+// empty Used means unknown, not zero.
+type OrganizationQuotaUsage struct {
+	CPU           *QuotaPair `json:"cpu,omitempty"`
+	Memory        *QuotaPair `json:"memory,omitempty"`
+	Storage       *QuotaPair `json:"storage,omitempty"`
+	Pods          *QuotaPair `json:"pods,omitempty"`
+	PublicIPv4    *QuotaPair `json:"publicIPv4,omitempty"`
+	ObjectStorage *QuotaPair `json:"objectStorage,omitempty"`
+	// Accelerators is keyed by the stable GPU profile ID plans and
+	// add-ons grant.
+	Accelerators map[string]AcceleratorQuotaUsage `json:"accelerators,omitempty"`
+	LastUpdated  string                           `json:"lastUpdated,omitempty"`
+}
+
+// AcceleratorQuotaUsage is one GPU profile's quota. Shared profiles
+// account Shares, MemoryMiB and CorePercent; whole-device ones Devices.
+type AcceleratorQuotaUsage struct {
+	Shares      *QuotaPair `json:"shares,omitempty"`
+	Devices     *QuotaPair `json:"devices,omitempty"`
+	MemoryMiB   *QuotaPair `json:"memoryMiB,omitempty"`
+	CorePercent *QuotaPair `json:"corePercent,omitempty"`
+}
+
+func (c *Client) GetOrganization(ctx context.Context, org string) (*Organization, error) {
+	var out Organization
+	if err := c.do(ctx, "GET", kdcPath(org, organizationResource, org), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,127 @@
+// Typed direct-K8s wrappers for kube-dc.com/v1 Project. Projects live
+// in their Organization's API namespace (the Organization name); the
+// controller creates the <org>-<project> backing namespace and reports
+// it in status.namespace once the Project is reconciled.
+
+package k8sapi
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+const (
+	kdcGroup        = "kube-dc.com"
+	kdcVersion      = "v1"
+	projectResource = "projects"
+)
+
+// Egress network types accepted by spec.egressNetworkType (CRD enum).
+// The field is immutable once the Project exists.
+const (
+	EgressNetworkCloud  = "cloud"
+	EgressNetworkPublic = "public"
+)
+
+type Project struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   ObjectMeta    `json:"metadata"`
+	Spec       ProjectSpec   `json:"spec"`
+	Status     ProjectStatus `json:"status,omitempty"`
+}
+
+type ProjectSpec struct {
+	CIDRBlock         string `json:"cidrBlock"`
+	EgressNetworkType string `json:"egressNetworkType"`
+	GwLanIP           string `json:"gwLanIp,omitempty"`
+}
+
+type ProjectStatus struct {
+	Ready      bool               `json:"ready"`
+	Namespace  string             `json:"namespace,omitempty"`
+	Conditions []Condition        `json:"conditions,omitempty"`
+	QuotaUsage *ProjectQuotaUsage `json:"quotaUsage,omitempty"`
+}
+
+// Condition is the metav1.Condition shape CRDs in kube-dc.com report.
+type Condition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+}
+
+// ProjectQuotaUsage is the controller's ~60s summary of the backing
+// namespace's ResourceQuota. Quota itself is platform-managed; this is
+// read-only. Values are human-readable quantities ("2", "4Gi").
+type ProjectQuotaUsage struct {
+	CPU                *QuotaPair `json:"cpu,omitempty"`
+	Memory             *QuotaPair `json:"memory,omitempty"`
+	Storage            *QuotaPair `json:"storage,omitempty"`
+	Pods               *QuotaPair `json:"pods,omitempty"`
+	PerProjectQuotaSet bool       `json:"perProjectQuotaSet,omitempty"`
+	LastUpdated        string     `json:"lastUpdated,omitempty"`
+}
+
+type QuotaPair struct {
+	Hard string `json:"hard"`
+	Used string `json:"used"`
+}
+
+type ProjectList struct {
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Items      []Project `json:"items"`
+}
+
+func projectBasePath(org string) string {
+	return fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s",
+		kdcGroup, kdcVersion, url.PathEscape(org), projectResource)
+}
+
+func projectItemPath(org, name string) string {
+	return projectBasePath(org) + "/" + url.PathEscape(name)
+}
+
+// ListProjects lists the Projects of an Organization. RBAC: `list
+// projects.kube-dc.com` in the Organization namespace.
+func (c *Client) ListProjects(ctx context.Context, org string) (*ProjectList, error) {
+	var out ProjectList
+	if err := c.do(ctx, "GET", projectBasePath(org), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) GetProject(ctx context.Context, org, name string) (*Project, error) {
+	var out Project
+	if err := c.do(ctx, "GET", projectItemPath(org, name), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateProject POSTs a new Project. metadata.namespace is the
+// Organization; APIVersion/Kind are stamped here.
+func (c *Client) CreateProject(ctx context.Context, p *Project) (*Project, error) {
+	if p == nil || p.Metadata.Name == "" || p.Metadata.Namespace == "" {
+		return nil, fmt.Errorf("CreateProject: metadata.name and metadata.namespace are required")
+	}
+	p.APIVersion = kdcGroup + "/" + kdcVersion
+	p.Kind = "Project"
+	var out Project
+	if err := c.do(ctx, "POST", projectBasePath(p.Metadata.Namespace), p, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteProject deletes the Project resource. The controller's
+// finalizer tears down the backing namespace, network and identity
+// state; the object disappears only when that is done.
+func (c *Client) DeleteProject(ctx context.Context, org, name string) error {
+	return c.do(ctx, "DELETE", projectItemPath(org, name), nil, nil, "")
+}
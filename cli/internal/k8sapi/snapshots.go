@@ -0,0 +1,147 @@
+// Typed direct-K8s wrappers for KubeVirt's snapshot.kubevirt.io
+// VirtualMachineSnapshot and VirtualMachineRestore. A snapshot captures
+// the VM spec plus a VolumeSnapshot of each of its disks, so it only
+// works on StorageClasses with a CSI snapshot class behind them.
+
+package k8sapi
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+const (
+	snapshotAPIVersion = "snapshot.kubevirt.io/v1beta1"
+	vmSnapshotResource = "virtualmachinesnapshots"
+	vmRestoreResource  = "virtualmachinerestores"
+)
+
+// VMRef points a snapshot or restore at a VirtualMachine.
+type VMRef struct {
+	APIGroup string `json:"apiGroup"`
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+}
+
+// NewVMRef refers to the VirtualMachine name.
+func NewVMRef(name string) VMRef {
+	return VMRef{APIGroup: "kubevirt.io", Kind: "VirtualMachine", Name: name}
+}
+
+type VirtualMachineSnapshot struct {
+	APIVersion string                       `json:"apiVersion"`
+	Kind       string                       `json:"kind"`
+	Metadata   ObjectMeta                   `json:"metadata"`
+	Spec       VirtualMachineSnapshotSpec   `json:"spec"`
+	Status     VirtualMachineSnapshotStatus `json:"status,omitempty"`
+}
+
+type VirtualMachineSnapshotSpec struct {
+	Source VMRef `json:"source"`
+}
+
+// VirtualMachineSnapshotStatus.Phase runs InProgress → Succeeded (or
+// Failed). Indications name the consistency of the capture: Online,
+// GuestAgent (filesystems frozen) or NoGuestAgent.
+type VirtualMachineSnapshotStatus struct {
+	Phase        string      `json:"phase,omitempty"`
+	ReadyToUse   bool        `json:"readyToUse,omitempty"`
+	CreationTime string      `json:"creationTime,omitempty"`
+	Indications  []string    `json:"indications,omitempty"`
+	Conditions   []Condition `json:"conditions,omitempty"`
+	Error        *struct {
+		Message string `json:"message,omitempty"`
+	} `json:"error,omitempty"`
+}
+
+type VirtualMachineSnapshotList struct {
+	Items []VirtualMachineSnapshot `json:"items"`
+}
+
+type VirtualMachineRestore struct {
+	APIVersion string                      `json:"apiVersion"`
+	Kind       string                      `json:"kind"`
+	Metadata   ObjectMeta                  `json:"metadata"`
+	Spec       VirtualMachineRestoreSpec   `json:"spec"`
+	Status     VirtualMachineRestoreStatus `json:"status,omitempty"`
+}
+
+type VirtualMachineRestoreSpec struct {
+	Target                     VMRef  `json:"target"`
+	VirtualMachineSnapshotName string `json:"virtualMachineSnapshotName"`
+}
+
+type VirtualMachineRestoreStatus struct {
+	Complete    *bool       `json:"complete,omitempty"`
+	RestoreTime string      `json:"restoreTime,omitempty"`
+	Conditions  []Condition `json:"conditions,omitempty"`
+}
+
+func snapshotPath(ns, resource, name string) string {
+	p := fmt.Sprintf("/apis/%s/namespaces/%s/%s", snapshotAPIVersion, url.PathEscape(ns), resource)
+	if name != "" {
+		p += "/" + url.PathEscape(name)
+	}
+	return p
+}
+
+func (c *Client) ListVMSnapshots(ctx context.Context, ns string) (*VirtualMachineSnapshotList, error) {
+	var out VirtualMachineSnapshotList
+	if err := c.do(ctx, "GET", snapshotPath(ns, vmSnapshotResource, ""), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) GetVMSnapshot(ctx context.Context, ns, name string) (*VirtualMachineSnapshot, error) {
+	var out VirtualMachineSnapshot
+	if err := c.do(ctx, "GET", snapshotPath(ns, vmSnapshotResource, name), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateVMSnapshot POSTs a snapshot into metadata.namespace.
+// APIVersion/Kind are stamped here.
+func (c *Client) CreateVMSnapshot(ctx context.Context, s *VirtualMachineSnapshot) (*VirtualMachineSnapshot, error) {
+	if s == nil || s.Metadata.Name == "" || s.Metadata.Namespace == "" {
+		return nil, fmt.Errorf("CreateVMSnapshot: metadata.name and metadata.namespace are required")
+	}
+	s.APIVersion = snapshotAPIVersion
+	s.Kind = "VirtualMachineSnapshot"
+	var out VirtualMachineSnapshot
+	if err := c.do(ctx, "POST", snapshotPath(s.Metadata.Namespace, vmSnapshotResource, ""), s, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteVMSnapshot deletes the snapshot and, through KubeVirt's
+// snapshot content, the VolumeSnapshots behind it.
+func (c *Client) DeleteVMSnapshot(ctx context.Context, ns, name string) error {
+	return c.do(ctx, "DELETE", snapshotPath(ns, vmSnapshotResource, name), nil, nil, "")
+}
+
+func (c *Client) GetVMRestore(ctx context.Context, ns, name string) (*VirtualMachineRestore, error) {
+	var out VirtualMachineRestore
+	if err := c.do(ctx, "GET", snapshotPath(ns, vmRestoreResource, name), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateVMRestore POSTs a restore into metadata.namespace. KubeVirt
+// acts on it only while the target VM is stopped.
+func (c *Client) CreateVMRestore(ctx context.Context, r *VirtualMachineRestore) (*VirtualMachineRestore, error) {
+	if r == nil || r.Metadata.Name == "" || r.Metadata.Namespace == "" {
+		return nil, fmt.Errorf("CreateVMRestore: metadata.name and metadata.namespace are required")
+	}
+	r.APIVersion = snapshotAPIVersion
+	r.Kind = "VirtualMachineRestore"
+	var out VirtualMachineRestore
+	if err := c.do(ctx, "POST", snapshotPath(r.Metadata.Namespace, vmRestoreResource, ""), r, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
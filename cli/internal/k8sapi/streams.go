@@ -0,0 +1,218 @@
+// Websocket subresources of a running VirtualMachineInstance: the
+// serial console and port-forward. KubeVirt serves both through the
+// kube-apiserver's aggregation layer with the "plain.kubevirt.io"
+// subprotocol — raw bytes in binary messages, no channel framing — so
+// each one is handed back as a plain io.ReadWriteCloser.
+//
+// Pod port-forward uses the kubelet's "v4.channel.k8s.io" websocket
+// protocol instead, which multiplexes a data and an error channel per
+// port; channelStream unwraps it to the same io.ReadWriteCloser.
+
+package k8sapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	kubevirtPlainSubprotocol = "plain.kubevirt.io"
+	portForwardSubprotocol   = "v4.channel.k8s.io"
+)
+
+// VMIConsole attaches to the serial console of a running VMI. RBAC:
+// `get virtualmachineinstances/console`.
+func (c *Client) VMIConsole(ctx context.Context, ns, name string) (io.ReadWriteCloser, error) {
+	conn, err := c.dialStream(ctx, vmiSubresourcePath(ns, name, "console"), kubevirtPlainSubprotocol)
+	if err != nil {
+		return nil, err
+	}
+	return &wsStream{conn: conn}, nil
+}
+
+// VMIPortForward opens a TCP stream to port inside the VMI. RBAC:
+// `get virtualmachineinstances/portforward`, which the standard
+// Project roles do not grant.
+func (c *Client) VMIPortForward(ctx context.Context, ns, name string, port int) (io.ReadWriteCloser, error) {
+	conn, err := c.dialStream(ctx, vmiSubresourcePath(ns, name, fmt.Sprintf("portforward/%d/tcp", port)), kubevirtPlainSubprotocol)
+	if err != nil {
+		return nil, err
+	}
+	return &wsStream{conn: conn}, nil
+}
+
+// PodPortForward opens one TCP connection to port inside a Pod. RBAC:
+// `create pods/portforward`.
+func (c *Client) PodPortForward(ctx context.Context, ns, pod string, port int) (io.ReadWriteCloser, error) {
+	p := fmt.Sprintf("%s/portforward?ports=%d", corePath(ns, "pods", pod), port)
+	conn, err := c.dialStream(ctx, p, portForwardSubprotocol)
+	if err != nil {
+		return nil, err
+	}
+	return &channelStream{ws: &wsStream{conn: conn}}, nil
+}
+
+func vmiSubresourcePath(ns, name, sub string) string {
+	return fmt.Sprintf("/apis/%s/namespaces/%s/%s/%s/%s",
+		kubevirtSubresources, url.PathEscape(ns), vmiResource, url.PathEscape(name), sub)
+}
+
+func (c *Client) dialStream(ctx context.Context, path, subprotocol string) (*websocket.Conn, error) {
+	u := c.APIServerURL + path
+	switch {
+	case strings.HasPrefix(u, "https://"):
+		u = "wss://" + strings.TrimPrefix(u, "https://")
+	case strings.HasPrefix(u, "http://"):
+		u = "ws://" + strings.TrimPrefix(u, "http://")
+	}
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		TLSClientConfig:  c.tlsConfig,
+		HandshakeTimeout: 30 * time.Second,
+		Subprotocols:     []string{subprotocol},
+	}
+	header := http.Header{}
+	if c.AccessToken != "" {
+		header.Set("Authorization", "Bearer "+c.AccessToken)
+	}
+	conn, resp, err := dialer.DialContext(ctx, u, header)
+	if err != nil {
+		// A refused upgrade carries the usual metav1.Status body.
+		if resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+			defer resp.Body.Close()
+			raw, _ := io.ReadAll(resp.Body)
+			apiErr := &APIError{Status: resp.StatusCode, Raw: string(raw)}
+			var status struct {
+				Message string `json:"message"`
+			}
+			if json.Unmarshal(raw, &status) == nil {
+				apiErr.Message = status.Message
+			}
+			return nil, apiErr
+		}
+		return nil, fmt.Errorf("GET %s: %w", u, err)
+	}
+	return conn, nil
+}
+
+// wsStream adapts a plain.kubevirt.io websocket to io.ReadWriteCloser.
+type wsStream struct {
+	conn *websocket.Conn
+	r    io.Reader
+	wmu  sync.Mutex
+}
+
+func (s *wsStream) Read(p []byte) (int, error) {
+	for {
+		if s.r == nil {
+			typ, r, err := s.conn.NextReader()
+			if err != nil {
+				if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					return 0, io.EOF
+				}
+				return 0, err
+			}
+			if typ != websocket.BinaryMessage && typ != websocket.TextMessage {
+				continue
+			}
+			s.r = r
+		}
+		n, err := s.r.Read(p)
+		if errors.Is(err, io.EOF) {
+			s.r = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (s *wsStream) Write(p []byte) (int, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	if err := s.conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *wsStream) Close() error {
+	s.wmu.Lock()
+	_ = s.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	s.wmu.Unlock()
+	return s.conn.Close()
+}
+
+// channelStream speaks the single-port form of v4.channel.k8s.io: every
+// message starts with a channel byte, 0 for data and 1 for errors, and
+// the first message on each channel is the little-endian port number.
+type channelStream struct {
+	ws                   *wsStream
+	buf                  []byte
+	dataSeen, errorsSeen bool
+}
+
+const (
+	channelData  = 0
+	channelError = 1
+)
+
+func (s *channelStream) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		typ, msg, err := s.ws.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				return 0, io.EOF
+			}
+			return 0, err
+		}
+		if typ != websocket.BinaryMessage || len(msg) == 0 {
+			continue
+		}
+		ch, body := msg[0], msg[1:]
+		switch ch {
+		case channelData:
+			if !s.dataSeen {
+				s.dataSeen = true
+				body = body[min(2, len(body)):]
+			}
+			s.buf = body
+		case channelError:
+			if !s.errorsSeen {
+				s.errorsSeen = true
+				body = body[min(2, len(body)):]
+			}
+			if len(body) > 0 {
+				return 0, fmt.Errorf("port-forward: %s", body)
+			}
+		}
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+func (s *channelStream) Write(p []byte) (int, error) {
+	msg := make([]byte, len(p)+1)
+	msg[0] = channelData
+	copy(msg[1:], p)
+	if _, err := s.ws.Write(msg); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *channelStream) Close() error { return s.ws.Close() }
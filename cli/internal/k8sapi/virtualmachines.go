@@ -0,0 +1,273 @@
+// Typed direct-K8s wrappers for KubeVirt VirtualMachine /
+// VirtualMachineInstance and the CDI DataVolume that backs a VM's root
+// disk. All three live in the Project backing namespace.
+//
+// The VM template spec is kept untyped: the CLI writes it once from
+// the OS catalog and reads back only a handful of fields, so modelling
+// the whole KubeVirt domain schema would buy nothing.
+
+package k8sapi
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+const (
+	kubevirtAPIVersion   = "kubevirt.io/v1"
+	kubevirtSubresources = "subresources.kubevirt.io/v1"
+	cdiAPIVersion        = "cdi.kubevirt.io/v1beta1"
+	vmResource           = "virtualmachines"
+	vmiResource          = "virtualmachineinstances"
+	dataVolumeResource   = "datavolumes"
+)
+
+type VirtualMachine struct {
+	APIVersion string               `json:"apiVersion"`
+	Kind       string               `json:"kind"`
+	Metadata   ObjectMeta           `json:"metadata"`
+	Spec       VirtualMachineSpec   `json:"spec"`
+	Status     VirtualMachineStatus `json:"status,omitempty"`
+}
+
+// VirtualMachineSpec carries both run controls: older VMs set Running,
+// newer ones RunStrategy. Exactly one is set on any given VM.
+type VirtualMachineSpec struct {
+	Running     *bool      `json:"running,omitempty"`
+	RunStrategy string     `json:"runStrategy,omitempty"`
+	Template    VMTemplate `json:"template"`
+}
+
+type VMTemplate struct {
+	Metadata map[string]any `json:"metadata,omitempty"`
+	Spec     map[string]any `json:"spec"`
+}
+
+// VirtualMachineStatus.PrintableStatus is what `kubectl get vm` shows:
+// Stopped, Provisioning, Starting, Running, Stopping, ErrorUnschedulable,
+// DataVolumeError, CrashLoopBackOff, ...
+type VirtualMachineStatus struct {
+	PrintableStatus string      `json:"printableStatus,omitempty"`
+	Ready           bool        `json:"ready,omitempty"`
+	Created         bool        `json:"created,omitempty"`
+	Conditions      []Condition `json:"conditions,omitempty"`
+}
+
+type VirtualMachineList struct {
+	APIVersion string           `json:"apiVersion"`
+	Kind       string           `json:"kind"`
+	Items      []VirtualMachine `json:"items"`
+}
+
+// VirtualMachineInstance is the running incarnation of a VM; it exists
+// only while the VM is started.
+type VirtualMachineInstance struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Status   VMIStatus  `json:"status,omitempty"`
+}
+
+type VMIStatus struct {
+	Phase       string         `json:"phase,omitempty"`
+	NodeName    string         `json:"nodeName,omitempty"`
+	Interfaces  []VMIInterface `json:"interfaces,omitempty"`
+	GuestOSInfo struct {
+		PrettyName string `json:"prettyName,omitempty"`
+	} `json:"guestOSInfo,omitempty"`
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+type VMIInterface struct {
+	Name        string   `json:"name,omitempty"`
+	IPAddress   string   `json:"ipAddress,omitempty"`
+	IPAddresses []string `json:"ipAddresses,omitempty"`
+}
+
+type VirtualMachineInstanceList struct {
+	Items []VirtualMachineInstance `json:"items"`
+}
+
+type DataVolume struct {
+	APIVersion string           `json:"apiVersion"`
+	Kind       string           `json:"kind"`
+	Metadata   ObjectMeta       `json:"metadata"`
+	Spec       DataVolumeSpec   `json:"spec"`
+	Status     DataVolumeStatus `json:"status,omitempty"`
+}
+
+type DataVolumeSpec struct {
+	Source DataVolumeSource `json:"source"`
+	PVC    DataVolumePVC    `json:"pvc"`
+}
+
+// DataVolumeSource sets exactly one of Registry, HTTP or Upload.
+type DataVolumeSource struct {
+	Registry *DataVolumeRegistrySource `json:"registry,omitempty"`
+	HTTP     *DataVolumeHTTPSource     `json:"http,omitempty"`
+	Upload   *DataVolumeUploadSource   `json:"upload,omitempty"`
+}
+
+type DataVolumeRegistrySource struct {
+	URL        string `json:"url"`
+	PullMethod string `json:"pullMethod,omitempty"`
+}
+
+type DataVolumeHTTPSource struct {
+	URL string `json:"url"`
+}
+
+// DataVolumeUploadSource has no fields: the DataVolume waits in phase
+// UploadReady for the bytes to arrive through the CDI upload proxy.
+type DataVolumeUploadSource struct{}
+
+type DataVolumePVC struct {
+	AccessModes      []string `json:"accessModes"`
+	VolumeMode       string   `json:"volumeMode,omitempty"`
+	StorageClassName string   `json:"storageClassName,omitempty"`
+	Resources        struct {
+		Requests map[string]string `json:"requests"`
+	} `json:"resources"`
+}
+
+// DataVolumeStatus.Phase runs Pending → ImportScheduled →
+// ImportInProgress → Succeeded (or Failed); Progress is "42.5%". An
+// upload DataVolume goes UploadScheduled → UploadReady → Succeeded.
+type DataVolumeStatus struct {
+	Phase      string      `json:"phase,omitempty"`
+	Progress   string      `json:"progress,omitempty"`
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+func vmPath(ns, resource, name string) string {
+	p := fmt.Sprintf("/apis/%s/namespaces/%s/%s", kubevirtAPIVersion, url.PathEscape(ns), resource)
+	if name != "" {
+		p += "/" + url.PathEscape(name)
+	}
+	return p
+}
+
+func dataVolumePath(ns, name string) string {
+	p := fmt.Sprintf("/apis/%s/namespaces/%s/%s", cdiAPIVersion, url.PathEscape(ns), dataVolumeResource)
+	if name != "" {
+		p += "/" + url.PathEscape(name)
+	}
+	return p
+}
+
+func (c *Client) ListVirtualMachines(ctx context.Context, ns string) (*VirtualMachineList, error) {
+	var out VirtualMachineList
+	if err := c.do(ctx, "GET", vmPath(ns, vmResource, ""), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) GetVirtualMachine(ctx context.Context, ns, name string) (*VirtualMachine, error) {
+	var out VirtualMachine
+	if err := c.do(ctx, "GET", vmPath(ns, vmResource, name), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateVirtualMachine POSTs a VM into metadata.namespace.
+// APIVersion/Kind are stamped here.
+func (c *Client) CreateVirtualMachine(ctx context.Context, vm *VirtualMachine) (*VirtualMachine, error) {
+	if vm == nil || vm.Metadata.Name == "" || vm.Metadata.Namespace == "" {
+		return nil, fmt.Errorf("CreateVirtualMachine: metadata.name and metadata.namespace are required")
+	}
+	vm.APIVersion = kubevirtAPIVersion
+	vm.Kind = "VirtualMachine"
+	var out VirtualMachine
+	if err := c.do(ctx, "POST", vmPath(vm.Metadata.Namespace, vmResource, ""), vm, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) DeleteVirtualMachine(ctx context.Context, ns, name string) error {
+	return c.do(ctx, "DELETE", vmPath(ns, vmResource, name), nil, nil, "")
+}
+
+// VirtualMachineAction calls a KubeVirt subresource — start, stop or
+// restart. RBAC: `update virtualmachines/<action>` in the namespace.
+func (c *Client) VirtualMachineAction(ctx context.Context, ns, name, action string) error {
+	switch action {
+	case "start", "stop", "restart":
+	default:
+		return fmt.Errorf("VirtualMachineAction: unsupported action %q", action)
+	}
+	p := fmt.Sprintf("/apis/%s/namespaces/%s/%s/%s/%s",
+		kubevirtSubresources, url.PathEscape(ns), vmResource, url.PathEscape(name), action)
+	return c.do(ctx, "PUT", p, nil, nil, "")
+}
+
+func (c *Client) GetVirtualMachineInstance(ctx context.Context, ns, name string) (*VirtualMachineInstance, error) {
+	var out VirtualMachineInstance
+	if err := c.do(ctx, "GET", vmPath(ns, vmiResource, name), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) ListVirtualMachineInstances(ctx context.Context, ns string) (*VirtualMachineInstanceList, error) {
+	var out VirtualMachineInstanceList
+	if err := c.do(ctx, "GET", vmPath(ns, vmiResource, ""), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) GetDataVolume(ctx context.Context, ns, name string) (*DataVolume, error) {
+	var out DataVolume
+	if err := c.do(ctx, "GET", dataVolumePath(ns, name), nil, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateDataVolume POSTs a DataVolume into metadata.namespace. CDI
+// starts importing as soon as it exists.
+func (c *Client) CreateDataVolume(ctx context.Context, dv *DataVolume) (*DataVolume, error) {
+	if dv == nil || dv.Metadata.Name == "" || dv.Metadata.Namespace == "" {
+		return nil, fmt.Errorf("CreateDataVolume: metadata.name and metadata.namespace are required")
+	}
+	dv.APIVersion = cdiAPIVersion
+	dv.Kind = "DataVolume"
+	var out DataVolume
+	if err := c.do(ctx, "POST", dataVolumePath(dv.Metadata.Namespace, ""), dv, &out, ""); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteDataVolume deletes the DataVolume and, through CDI's owner
+// reference, its PVC.
+func (c *Client) DeleteDataVolume(ctx context.Context, ns, name string) error {
+	return c.do(ctx, "DELETE", dataVolumePath(ns, name), nil, nil, "")
+}
+
+// CreateUploadToken asks CDI for a short-lived token that lets the
+// upload proxy accept bytes for the PVC of an UploadReady DataVolume.
+// RBAC: `create uploadtokenrequests.upload.cdi.kubevirt.io`.
+func (c *Client) CreateUploadToken(ctx context.Context, ns, pvc string) (string, error) {
+	body := map[string]any{
+		"apiVersion": "upload.cdi.kubevirt.io/v1beta1",
+		"kind":       "UploadTokenRequest",
+		"metadata":   map[string]any{"name": pvc, "namespace": ns},
+		"spec":       map[string]any{"pvcName": pvc},
+	}
+	var out struct {
+		Status struct {
+			Token string `json:"token"`
+		} `json:"status"`
+	}
+	p := fmt.Sprintf("/apis/upload.cdi.kubevirt.io/v1beta1/namespaces/%s/uploadtokenrequests", url.PathEscape(ns))
+	if err := c.do(ctx, "POST", p, body, &out, ""); err != nil {
+		return "", err
+	}
+	if out.Status.Token == "" {
+		return "", fmt.Errorf("CDI returned no upload token for %s", pvc)
+	}
+	return out.Status.Token, nil
+}
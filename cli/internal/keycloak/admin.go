@@ -0,0 +1,209 @@
+// Package keycloak is a thin client for the slice of the Keycloak Admin
+// REST API the CLI needs: looking up users and groups in an
+// Organization realm and changing group membership.
+//
+// Membership has no Kubernetes resource — OrganizationGroup declares
+// what a group may do, the realm decides who is in it — so this is the
+// one place the CLI talks to Keycloak as an administrator. Calls carry
+// the caller's own realm token; Keycloak's realm-management roles are
+// the gate, exactly as for the console's Users page.
+
+package keycloak
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/shalb/kube-dc/cli/internal/auth"
+)
+
+// AdminClient talks to /admin/realms/<realm> with a bearer token.
+type AdminClient struct {
+	BaseURL     string // Keycloak root, e.g. https://login.kube-dc.cloud
+	Realm       string
+	AccessToken string
+	http        *http.Client
+}
+
+// NewAdminClient builds an AdminClient. caCert/insecure are the TLS
+// settings the login used for the same Keycloak.
+func NewAdminClient(keycloakURL, realm, accessToken, caCert string, insecure bool) (*AdminClient, error) {
+	if keycloakURL == "" || realm == "" {
+		return nil, fmt.Errorf("keycloak: URL and realm are required")
+	}
+	return &AdminClient{
+		BaseURL:     strings.TrimRight(keycloakURL, "/"),
+		Realm:       realm,
+		AccessToken: accessToken,
+		http:        auth.CreateHTTPClient(caCert, insecure),
+	}, nil
+}
+
+// User is the subset of UserRepresentation the CLI uses.
+type User struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email,omitempty"`
+	Enabled  bool   `json:"enabled"`
+}
+
+// Group is the subset of GroupRepresentation the CLI uses.
+type Group struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// APIError is a non-2xx answer from the Admin API.
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	switch e.Status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Sprintf("keycloak %d: your identity may not manage users in this realm (Organization admin required)", e.Status)
+	}
+	if e.Message != "" {
+		return fmt.Sprintf("keycloak %d: %s", e.Status, e.Message)
+	}
+	return fmt.Sprintf("keycloak %d", e.Status)
+}
+
+func (c *AdminClient) realmPath(format string, args ...any) string {
+	return fmt.Sprintf("%s/admin/realms/%s", c.BaseURL, url.PathEscape(c.Realm)) + fmt.Sprintf(format, args...)
+}
+
+func (c *AdminClient) do(ctx context.Context, method, u string, body, out any) error {
+	var bodyReader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode body: %w", err)
+		}
+		bodyReader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, bodyReader)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+c.AccessToken)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, u, err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{Status: resp.StatusCode}
+		var parsed struct {
+			Error            string `json:"error"`
+			ErrorMessage     string `json:"errorMessage"`
+			ErrorDescription string `json:"error_description"`
+		}
+		if json.Unmarshal(raw, &parsed) == nil {
+			apiErr.Message = firstNonEmpty(parsed.ErrorMessage, parsed.ErrorDescription, parsed.Error)
+		}
+		return apiErr
+	}
+	if out == nil || len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// FindUser resolves a username, or an email address when ref contains
+// "@", to exactly one user. Keycloak's search is a substring match
+// unless exact=true, which is why the result is still checked.
+func (c *AdminClient) FindUser(ctx context.Context, ref string) (*User, error) {
+	q := url.Values{"exact": {"true"}}
+	byEmail := strings.Contains(ref, "@")
+	if byEmail {
+		q.Set("email", ref)
+	} else {
+		q.Set("username", ref)
+	}
+	var users []User
+	if err := c.do(ctx, http.MethodGet, c.realmPath("/users?%s", q.Encode()), nil, &users); err != nil {
+		return nil, err
+	}
+	for i := range users {
+		u := &users[i]
+		if (byEmail && strings.EqualFold(u.Email, ref)) || (!byEmail && strings.EqualFold(u.Username, ref)) {
+			return u, nil
+		}
+	}
+	return nil, fmt.Errorf("no user %q in Organization %s", ref, c.Realm)
+}
+
+// FindGroup returns the top-level group called name, or nil when the
+// realm has none. OrganizationGroups and the built-in org-admin / user
+// groups are all top-level.
+func (c *AdminClient) FindGroup(ctx context.Context, name string) (*Group, error) {
+	q := url.Values{"search": {name}, "exact": {"true"}, "briefRepresentation": {"true"}}
+	var groups []Group
+	if err := c.do(ctx, http.MethodGet, c.realmPath("/groups?%s", q.Encode()), nil, &groups); err != nil {
+		return nil, err
+	}
+	for i := range groups {
+		if groups[i].Name == name && (groups[i].Path == "" || groups[i].Path == "/"+name) {
+			return &groups[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// groupMembersPage is how many members GroupMembers asks for per request.
+// A var so tests can page through a handful of users.
+var groupMembersPage = 500
+
+// GroupMembers lists the direct members of a group, paging with first/max
+// until Keycloak returns a short page.
+func (c *AdminClient) GroupMembers(ctx context.Context, groupID string) ([]User, error) {
+	var users []User
+	for first := 0; ; first += groupMembersPage {
+		q := url.Values{"first": {strconv.Itoa(first)}, "max": {strconv.Itoa(groupMembersPage)}, "briefRepresentation": {"true"}}
+		var page []User
+		if err := c.do(ctx, http.MethodGet, c.realmPath("/groups/%s/members?%s", url.PathEscape(groupID), q.Encode()), nil, &page); err != nil {
+			return nil, err
+		}
+		users = append(users, page...)
+		if len(page) < groupMembersPage {
+			return users, nil
+		}
+	}
+}
+
+// AddToGroup makes the user a member of the group. Idempotent.
+func (c *AdminClient) AddToGroup(ctx context.Context, userID, groupID string) error {
+	return c.do(ctx, http.MethodPut, c.realmPath("/users/%s/groups/%s", url.PathEscape(userID), url.PathEscape(groupID)), nil, nil)
+}
+
+// RemoveFromGroup removes the user from the group. Idempotent.
+func (c *AdminClient) RemoveFromGroup(ctx context.Context, userID, groupID string) error {
+	return c.do(ctx, http.MethodDelete, c.realmPath("/users/%s/groups/%s", url.PathEscape(userID), url.PathEscape(groupID)), nil, nil)
+}
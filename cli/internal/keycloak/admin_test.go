@@ -0,0 +1,87 @@
+package keycloak
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAdminClient(t *testing.T) {
+	var lastAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastAuth = r.Header.Get("Authorization")
+		q := r.URL.Query()
+		switch r.URL.Path {
+		case "/admin/realms/acme/users":
+			if q.Get("exact") != "true" {
+				t.Errorf("user search not exact: %s", r.URL.RawQuery)
+			}
+			// Keycloak matches email case-insensitively and may return
+			// near-misses; FindUser picks the exact one.
+			_ = json.NewEncoder(w).Encode([]User{
+				{ID: "u-2", Username: "alice2", Email: "alice2@example.com"},
+				{ID: "u-1", Username: "alice", Email: "Alice@example.com"},
+			})
+		case "/admin/realms/acme/groups":
+			_ = json.NewEncoder(w).Encode([]Group{
+				{ID: "g-sub", Name: q.Get("search"), Path: "/parent/" + q.Get("search")},
+				{ID: "g-top", Name: q.Get("search"), Path: "/" + q.Get("search")},
+			})
+		case "/admin/realms/acme/groups/g-top/members":
+			// Five members served in pages of max, starting at first.
+			first, _ := strconv.Atoi(q.Get("first"))
+			max, _ := strconv.Atoi(q.Get("max"))
+			page := []User{}
+			for i := first; i < 5 && i < first+max; i++ {
+				page = append(page, User{ID: fmt.Sprintf("m-%d", i)})
+			}
+			_ = json.NewEncoder(w).Encode(page)
+		case "/admin/realms/acme/users/u-1/groups/g-top":
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"error":"unknown_error"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	c, err := NewAdminClient(srv.URL+"/", "acme", "tok", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	u, err := c.FindUser(ctx, "alice@example.com")
+	if err != nil || u.ID != "u-1" {
+		t.Fatalf("FindUser by email = %+v, %v", u, err)
+	}
+	if lastAuth != "Bearer tok" {
+		t.Errorf("Authorization = %q", lastAuth)
+	}
+	if u, err := c.FindUser(ctx, "al"); err == nil {
+		t.Errorf("partial username matched %+v", u)
+	}
+
+	g, err := c.FindGroup(ctx, "backend-team")
+	if err != nil || g == nil || g.ID != "g-top" {
+		t.Fatalf("FindGroup = %+v, %v", g, err)
+	}
+
+	groupMembersPage = 2
+	defer func() { groupMembersPage = 500 }()
+	members, err := c.GroupMembers(ctx, "g-top")
+	if err != nil || len(members) != 5 || members[4].ID != "m-4" {
+		t.Errorf("GroupMembers = %+v, %v; want all 5 members across pages", members, err)
+	}
+
+	err = c.AddToGroup(ctx, "u-1", "g-top")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusForbidden || !strings.Contains(err.Error(), "Organization admin required") {
+		t.Errorf("AddToGroup err = %v", err)
+	}
+}
@@ -53,9 +53,15 @@ type NamedUser struct {
 	User User   `yaml:"user"`
 }
 
-// User contains user authentication information
+// User contains user authentication information. Kube-DC users
+// authenticate through the exec plugin; the static credentials are
+// carried so imported Managed Cluster users (and anyone else's) survive
+// a Load/Save round trip.
 type User struct {
-	Exec *ExecConfig `yaml:"exec,omitempty"`
+	Exec                  *ExecConfig `yaml:"exec,omitempty"`
+	ClientCertificateData string      `yaml:"client-certificate-data,omitempty"`
+	ClientKeyData         string      `yaml:"client-key-data,omitempty"`
+	Token                 string      `yaml:"token,omitempty"`
 }
 
 // ExecConfig contains exec credential plugin configuration
@@ -294,6 +300,104 @@ func execArgs(p AddContextParams) []string {
 	return args
 }
 
+// MergeContextParams names the entries MergeContext writes.
+type MergeContextParams struct {
+	ClusterName string
+	UserName    string
+	ContextName string
+	Namespace   string
+	SetCurrent  bool
+}
+
+// MergeContext imports the current context of a standalone kubeconfig
+// (such as a Managed Cluster's admin.conf) under the given names,
+// replacing an earlier import of the same names. The cluster and user
+// are copied verbatim: the server, CA and client credentials are never
+// rewritten. All other entries are preserved.
+func (m *Manager) MergeContext(data []byte, params MergeContextParams) error {
+	var src Config
+	if err := yaml.Unmarshal(data, &src); err != nil {
+		return fmt.Errorf("failed to parse kubeconfig to merge: %w", err)
+	}
+	srcContextName := src.CurrentContext
+	if srcContextName == "" && len(src.Contexts) == 1 {
+		srcContextName = src.Contexts[0].Name
+	}
+	var srcContext *Context
+	for i := range src.Contexts {
+		if src.Contexts[i].Name == srcContextName {
+			srcContext = &src.Contexts[i].Context
+			break
+		}
+	}
+	if srcContext == nil {
+		return fmt.Errorf("kubeconfig to merge has no current context")
+	}
+	var cluster *Cluster
+	for i := range src.Clusters {
+		if src.Clusters[i].Name == srcContext.Cluster {
+			cluster = &src.Clusters[i].Cluster
+			break
+		}
+	}
+	var user *User
+	for i := range src.Users {
+		if src.Users[i].Name == srcContext.User {
+			user = &src.Users[i].User
+			break
+		}
+	}
+	if cluster == nil || cluster.Server == "" {
+		return fmt.Errorf("kubeconfig to merge has no cluster %q", srcContext.Cluster)
+	}
+	if user == nil {
+		return fmt.Errorf("kubeconfig to merge has no user %q", srcContext.User)
+	}
+
+	config, err := m.Load()
+	if err != nil {
+		return err
+	}
+	clusterFound := false
+	for i := range config.Clusters {
+		if config.Clusters[i].Name == params.ClusterName {
+			config.Clusters[i].Cluster = *cluster
+			clusterFound = true
+			break
+		}
+	}
+	if !clusterFound {
+		config.Clusters = append(config.Clusters, NamedCluster{Name: params.ClusterName, Cluster: *cluster})
+	}
+	userFound := false
+	for i := range config.Users {
+		if config.Users[i].Name == params.UserName {
+			config.Users[i].User = *user
+			userFound = true
+			break
+		}
+	}
+	if !userFound {
+		config.Users = append(config.Users, NamedUser{Name: params.UserName, User: *user})
+	}
+	context := Context{Cluster: params.ClusterName, User: params.UserName, Namespace: params.Namespace}
+	contextFound := false
+	for i := range config.Contexts {
+		if config.Contexts[i].Name == params.ContextName {
+			config.Contexts[i].Context = context
+			contextFound = true
+			break
+		}
+	}
+	if !contextFound {
+		config.Contexts = append(config.Contexts, NamedContext{Name: params.ContextName, Context: context})
+	}
+	if params.SetCurrent {
+		config.CurrentContext = params.ContextName
+	}
+	return m.Save(config)
+}
+
 // RemoveContext deletes a single named context from the kubeconfig and
 // also drops the cluster and user entries it referenced **only if** no
 // other surviving context still uses them. This is the surgical
@@ -382,7 +486,20 @@ func userStillReferenced(user string, contexts []NamedContext) bool {
 	return false
 }
 
-// RemoveKubeDCContexts removes all Kube-DC contexts for a server
+func isManagedClusterOf(name string, orgClusters []string) bool {
+	if !strings.Contains(name, "-cluster-") {
+		return false
+	}
+	for _, org := range orgClusters {
+		if strings.HasPrefix(name, org+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveKubeDCContexts removes all Kube-DC contexts for a server,
+// including the Managed Clusters merged from its Projects
 func (m *Manager) RemoveKubeDCContexts(server string) error {
 	config, err := m.Load()
 	if err != nil {
@@ -399,7 +516,15 @@ func (m *Manager) RemoveKubeDCContexts(server string) error {
 			newClusters = append(newClusters, c)
 		}
 	}
-	config.Clusters = newClusters
+	// A merged Managed Cluster keeps its own server, so it is found by
+	// name: kube-dc-<domain>-<org>-<project>-cluster-<name> under one of
+	// the Organization clusters just removed.
+	config.Clusters = newClusters[:0]
+	for _, c := range newClusters {
+		if !isManagedClusterOf(c.Name, clusterNames) {
+			config.Clusters = append(config.Clusters, c)
+		}
+	}
 
 	// Remove users and contexts for those clusters
 	var newUsers []NamedUser
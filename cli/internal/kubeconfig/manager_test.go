@@ -257,3 +257,90 @@ func TestAddKubeDCContext_TLSVerificationIsOnUnlessExplicitlyWaived(t *testing.T
 		}
 	}
 }
+
+// TestMergeContext_ImportsVerbatimAndReplaces covers `kube-dc clusters
+// kubeconfig`: the Managed Cluster's admin.conf lands under the kube-dc
+// names with its server and client certificate untouched, a second
+// import replaces the first, and unrelated entries survive.
+func TestMergeContext_ImportsVerbatimAndReplaces(t *testing.T) {
+	mgr, path := newTestManager(t)
+	writeConfig(t, path, &Config{
+		APIVersion:     "v1",
+		Kind:           "Config",
+		CurrentContext: "kube-dc/example.com/acme/web",
+		Clusters:       []NamedCluster{{Name: "kube-dc-example.com-acme", Cluster: Cluster{Server: "https://kube-api.example.com:6443"}}},
+		Users:          []NamedUser{{Name: "kube-dc@example.com/acme", User: User{Exec: &ExecConfig{Command: "kube-dc"}}}},
+		Contexts: []NamedContext{{Name: "kube-dc/example.com/acme/web", Context: Context{
+			Cluster: "kube-dc-example.com-acme", User: "kube-dc@example.com/acme", Namespace: "acme-web"}}},
+	})
+	adminConf := func(server, cert string) []byte {
+		return []byte(`apiVersion: v1
+kind: Config
+current-context: kubernetes-admin@dev
+clusters:
+- name: dev
+  cluster:
+    server: ` + server + `
+    certificate-authority-data: Q0E=
+contexts:
+- name: kubernetes-admin@dev
+  context:
+    cluster: dev
+    user: kubernetes-admin
+users:
+- name: kubernetes-admin
+  user:
+    client-certificate-data: ` + cert + `
+    client-key-data: S0VZ
+`)
+	}
+	params := MergeContextParams{
+		ClusterName: "kube-dc-example.com-acme-web-cluster-dev",
+		UserName:    "kube-dc@example.com/acme/web/cluster/dev",
+		ContextName: "kube-dc/example.com/acme/web/cluster/dev",
+	}
+	if err := mgr.MergeContext(adminConf("https://dev-cp-acme-web.example.com:443", "Q0VSVDE="), params); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.MergeContext(adminConf("https://dev-cp-acme-web.example.com:443", "Q0VSVDI="), params); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := mgr.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Clusters) != 2 || len(cfg.Users) != 2 || len(cfg.Contexts) != 2 {
+		t.Fatalf("want the project entries plus one import, got %d clusters, %d users, %d contexts",
+			len(cfg.Clusters), len(cfg.Users), len(cfg.Contexts))
+	}
+	if cfg.CurrentContext != "kube-dc/example.com/acme/web" {
+		t.Errorf("current-context changed without SetCurrent: %q", cfg.CurrentContext)
+	}
+	cl := cfg.Clusters[1].Cluster
+	if cl.Server != "https://dev-cp-acme-web.example.com:443" || cl.CertificateAuthorityData != "Q0E=" {
+		t.Errorf("cluster not copied verbatim: %+v", cl)
+	}
+	u := cfg.Users[1].User
+	if u.ClientCertificateData != "Q0VSVDI=" || u.ClientKeyData != "S0VZ" || u.Exec != nil {
+		t.Errorf("user not replaced by the second import: %+v", u)
+	}
+	if cfg.Users[0].User.Exec == nil {
+		t.Error("the Project's exec user was lost")
+	}
+
+	if err := mgr.MergeContext([]byte("apiVersion: v1\nkind: Config\n"), params); err == nil {
+		t.Error("a kubeconfig without a context must be refused")
+	}
+
+	// Logging out of the server takes the merged cluster along, though
+	// its server differs.
+	if err := mgr.RemoveKubeDCContexts("https://kube-api.example.com:6443"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = mgr.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Clusters) != 0 || len(cfg.Users) != 0 || len(cfg.Contexts) != 0 {
+		t.Errorf("after RemoveKubeDCContexts: %+v", cfg)
+	}
+}
@@ -0,0 +1,336 @@
+// Package s3 is the small S3 client behind `kube-dc buckets cp` and
+// `sync`: list, get, put (multipart above PartSize) and delete, signed
+// with Signature Version 4 and addressed path-style, which Ceph RGW and
+// every S3 stand-in accept without wildcard DNS. It exists so a jump
+// host needs only the kube-dc binary, not awscli.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultRegion is what RGW answers to when a bucket has none.
+	DefaultRegion = "us-east-1"
+
+	minPartSize = 5 << 20
+	maxParts    = 10000
+)
+
+// Client talks to one S3 endpoint with one key pair.
+type Client struct {
+	Endpoint  *url.URL
+	Region    string
+	AccessKey string
+	SecretKey string
+	// PartSize is the multipart threshold and part size; raised as
+	// needed to stay within S3's 10,000 parts.
+	PartSize int64
+	http     *http.Client
+	now      func() time.Time
+}
+
+// New builds a Client for endpoint, e.g. https://s3.example.com.
+func New(endpoint, region, accessKey, secretKey string) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q: want http(s)://host[:port]", endpoint)
+	}
+	if region == "" {
+		region = DefaultRegion
+	}
+	return &Client{
+		Endpoint:  u,
+		Region:    region,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		PartSize:  16 << 20,
+		http:      &http.Client{Transport: http.DefaultTransport},
+		now:       time.Now,
+	}, nil
+}
+
+// Object is one entry of a listing, or the metadata of a download.
+type Object struct {
+	Key          string
+	Size         int64
+	ETag         string // without quotes; "<md5>-<parts>" for multipart uploads
+	LastModified time.Time
+}
+
+// Multipart reports whether the ETag is not a plain MD5 of the content.
+func (o Object) Multipart() bool { return strings.Contains(o.ETag, "-") }
+
+// Error is an S3 error response.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: HTTP %d", e.Status)
+	}
+	return fmt.Sprintf("s3: %s: %s", e.Code, fmtCoalesce(e.Message, http.StatusText(e.Status)))
+}
+
+// IsNotFound reports whether err is a missing key or bucket.
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Status == http.StatusNotFound
+}
+
+// List returns every object under prefix, following continuation
+// tokens.
+func (c *Client) List(ctx context.Context, bucket, prefix string) ([]Object, error) {
+	var out []Object
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}}
+		if prefix != "" {
+			q.Set("prefix", prefix)
+		}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		var page struct {
+			IsTruncated           bool
+			NextContinuationToken string
+			Contents              []struct {
+				Key          string
+				Size         int64
+				ETag         string
+				LastModified time.Time
+			}
+		}
+		if err := c.call(ctx, "GET", bucket, "", q, nil, &page); err != nil {
+			return nil, err
+		}
+		for _, o := range page.Contents {
+			out = append(out, Object{Key: o.Key, Size: o.Size, ETag: strings.Trim(o.ETag, `"`), LastModified: o.LastModified})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return out, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// Get writes the object to w and returns its metadata.
+func (c *Client) Get(ctx context.Context, bucket, key string, w io.Writer) (*Object, error) {
+	resp, err := c.send(ctx, "GET", bucket, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	n, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("download %s/%s: %w", bucket, key, err)
+	}
+	obj := &Object{Key: key, Size: n, ETag: strings.Trim(resp.Header.Get("ETag"), `"`)}
+	obj.LastModified, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return obj, nil
+}
+
+// Delete removes one object; a missing object is not an error.
+func (c *Client) Delete(ctx context.Context, bucket, key string) error {
+	err := c.call(ctx, "DELETE", bucket, key, nil, nil, nil)
+	if IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Put uploads size bytes of src as bucket/key: in one request up to
+// PartSize, as a multipart upload above it.
+func (c *Client) Put(ctx context.Context, bucket, key string, src io.ReaderAt, size int64) error {
+	partSize := c.partSize(size)
+	if size <= partSize {
+		return c.call(ctx, "PUT", bucket, key, nil, &body{src: io.NewSectionReader(src, 0, size), size: size, contentType: contentType(key)}, nil)
+	}
+
+	var initiated struct{ UploadId string }
+	if err := c.call(ctx, "POST", bucket, key, url.Values{"uploads": {""}}, &body{contentType: contentType(key)}, &initiated); err != nil {
+		return err
+	}
+	uploadID := initiated.UploadId
+	type part struct {
+		PartNumber int
+		ETag       string
+	}
+	var parts []part
+	for off, n := int64(0), 1; off < size; off, n = off+partSize, n+1 {
+		q := url.Values{"partNumber": {strconv.Itoa(n)}, "uploadId": {uploadID}}
+		resp, err := c.send(ctx, "PUT", bucket, key, q, &body{src: io.NewSectionReader(src, off, min(partSize, size-off)), size: min(partSize, size-off)})
+		if err != nil {
+			c.abort(bucket, key, uploadID)
+			return fmt.Errorf("upload part %d of %s/%s: %w", n, bucket, key, err)
+		}
+		resp.Body.Close()
+		parts = append(parts, part{PartNumber: n, ETag: resp.Header.Get("ETag")})
+	}
+	complete, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	if err := c.call(ctx, "POST", bucket, key, url.Values{"uploadId": {uploadID}}, &body{src: bytes.NewReader(complete), size: int64(len(complete)), contentType: "application/xml"}, nil); err != nil {
+		c.abort(bucket, key, uploadID)
+		return err
+	}
+	return nil
+}
+
+func (c *Client) partSize(size int64) int64 {
+	p := max(c.PartSize, minPartSize)
+	if size > p*maxParts {
+		p = (size + maxParts - 1) / maxParts
+	}
+	return p
+}
+
+// abort drops an unfinished multipart upload so its parts stop
+// counting against the bucket. Best effort: the caller already has an
+// error to report.
+func (c *Client) abort(bucket, key, uploadID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_ = c.call(ctx, "DELETE", bucket, key, url.Values{"uploadId": {uploadID}}, nil, nil)
+}
+
+func contentType(key string) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// body is a request payload; src is read twice, once to hash it.
+type body struct {
+	src         io.ReadSeeker
+	size        int64
+	contentType string
+}
+
+func (b *body) hash() (string, error) {
+	if b == nil || b.src == nil {
+		return EmptyPayloadHash, nil
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, b.src); err != nil {
+		return "", fmt.Errorf("read upload: %w", err)
+	}
+	if _, err := b.src.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// call sends a request and decodes an XML answer into out when given.
+// S3 can answer a CompleteMultipartUpload with 200 and an error
+// document, so a 2xx body is checked for <Error> too.
+func (c *Client) call(ctx context.Context, method, bucket, key string, q url.Values, b *body, out any) error {
+	resp, err := c.send(ctx, method, bucket, key, q, b)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s %s/%s: %w", method, bucket, key, err)
+	}
+	if bytes.Contains(raw, []byte("<Error>")) {
+		return parseError(resp.StatusCode, raw)
+	}
+	if out != nil {
+		if err := xml.Unmarshal(raw, out); err != nil {
+			return fmt.Errorf("decode %s %s/%s response: %w", method, bucket, key, err)
+		}
+	}
+	return nil
+}
+
+// send signs and sends one request, returning the response only when
+// it is 2xx; the caller closes its body.
+func (c *Client) send(ctx context.Context, method, bucket, key string, q url.Values, b *body) (*http.Response, error) {
+	u := *c.Endpoint
+	u.Path = strings.TrimRight(u.Path, "/") + "/" + bucket
+	u.RawPath = uriEncode(u.Path, false)
+	if key != "" {
+		u.Path += "/" + key
+		u.RawPath += "/" + uriEncode(key, false)
+	}
+	u.RawQuery = canonicalQuery(q)
+
+	hash, err := b.hash()
+	if err != nil {
+		return nil, err
+	}
+	// An empty body must be http.NoBody: any other reader with a zero
+	// ContentLength is sent chunked, which S3 refuses on PUT.
+	var rd io.Reader
+	if b != nil && b.src != nil && b.size > 0 {
+		rd = b.src
+	} else if b != nil {
+		rd = http.NoBody
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), rd)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	if b != nil {
+		req.ContentLength = b.size
+		if b.contentType != "" {
+			req.Header.Set("Content-Type", b.contentType)
+		}
+	}
+	Sign(req, c.AccessKey, c.SecretKey, c.Region, hash, c.now())
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, u.Redacted(), err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return nil, parseError(resp.StatusCode, raw)
+	}
+	return resp, nil
+}
+
+func parseError(status int, raw []byte) error {
+	var e struct {
+		Code    string
+		Message string
+	}
+	_ = xml.Unmarshal(raw, &e)
+	if e.Code == "" && status == http.StatusNotFound {
+		e.Code = "NotFound"
+	}
+	return &Error{Status: status, Code: e.Code, Message: e.Message}
+}
+
+func fmtCoalesce(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
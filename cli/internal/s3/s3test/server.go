@@ -0,0 +1,252 @@
+// Package s3test is an in-memory S3 stand-in for tests: path-style
+// buckets, ListObjectsV2 with paging, single and multipart PUT, GET and
+// DELETE. It checks that each request carries a SigV4 Authorization
+// header for its access key and that X-Amz-Content-Sha256 matches the
+// body; the signature itself is covered by the AWS test vectors in
+// package s3.
+package s3test
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Object is a stored object.
+type Object struct {
+	Data         []byte
+	ETag         string
+	LastModified time.Time
+}
+
+// Server is the stand-in; Objects is keyed by bucket, then key.
+type Server struct {
+	*httptest.Server
+	AccessKey string
+	// MaxKeys caps a listing page, so tests can exercise continuation.
+	MaxKeys int
+
+	mu      sync.Mutex
+	Objects map[string]map[string]*Object
+	uploads map[string]map[int][]byte
+	nextID  int
+}
+
+// New starts a stand-in holding the given empty buckets.
+func New(accessKey string, buckets ...string) *Server {
+	s := &Server{AccessKey: accessKey, MaxKeys: 1000, Objects: map[string]map[string]*Object{}, uploads: map[string]map[int][]byte{}}
+	for _, b := range buckets {
+		s.Objects[b] = map[string]*Object{}
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// Put stores an object directly, bypassing HTTP.
+func (s *Server) Put(bucket, key string, data []byte, modified time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Objects[bucket][key] = &Object{Data: data, ETag: md5Hex(data), LastModified: modified.UTC().Truncate(time.Second)}
+}
+
+// Keys lists a bucket's keys in order.
+func (s *Server) Keys(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for k := range s.Objects[bucket] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/") {
+		writeError(w, http.StatusForbidden, "InvalidAccessKeyId", "unknown access key")
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	if h := r.Header.Get("X-Amz-Content-Sha256"); h != "UNSIGNED-PAYLOAD" && h != sha256Hex(body) {
+		writeError(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch", "payload hash does not match")
+		return
+	}
+	if r.ContentLength != int64(len(body)) {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", "body shorter than Content-Length")
+		return
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	objects, ok := s.Objects[bucket]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", "bucket does not exist")
+		return
+	}
+	q := r.URL.Query()
+	switch {
+	case key == "" && r.Method == "GET" && q.Get("list-type") == "2":
+		s.list(w, objects, q.Get("prefix"), q.Get("continuation-token"))
+	case key == "":
+		writeError(w, http.StatusNotImplemented, "NotImplemented", r.Method+" on a bucket")
+	case r.Method == "POST" && q.Has("uploads"):
+		s.nextID++
+		id := strconv.Itoa(s.nextID)
+		s.uploads[id] = map[int][]byte{}
+		writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadId string
+		}{Bucket: bucket, Key: key, UploadId: id})
+	case r.Method == "PUT" && q.Has("uploadId"):
+		parts, ok := s.uploads[q.Get("uploadId")]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchUpload", "unknown upload")
+			return
+		}
+		n, _ := strconv.Atoi(q.Get("partNumber"))
+		parts[n] = body
+		w.Header().Set("ETag", `"`+md5Hex(body)+`"`)
+	case r.Method == "POST" && q.Has("uploadId"):
+		s.complete(w, objects, key, q.Get("uploadId"), body)
+	case r.Method == "DELETE" && q.Has("uploadId"):
+		delete(s.uploads, q.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "PUT":
+		objects[key] = &Object{Data: body, ETag: md5Hex(body), LastModified: time.Now().UTC().Truncate(time.Second)}
+		w.Header().Set("ETag", `"`+objects[key].ETag+`"`)
+	case r.Method == "GET":
+		o, ok := objects[key]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey", "key does not exist")
+			return
+		}
+		w.Header().Set("ETag", `"`+o.ETag+`"`)
+		w.Header().Set("Last-Modified", o.LastModified.Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(o.Data)))
+		w.Write(o.Data)
+	case r.Method == "DELETE":
+		delete(objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented", r.Method)
+	}
+}
+
+func (s *Server) list(w http.ResponseWriter, objects map[string]*Object, prefix, token string) {
+	var keys []string
+	for k := range objects {
+		if strings.HasPrefix(k, prefix) && k > token {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	type content struct {
+		Key          string
+		LastModified string
+		ETag         string
+		Size         int
+	}
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Prefix                string
+		KeyCount              int
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+		Contents              []content
+	}{Prefix: prefix}
+	if len(keys) > s.MaxKeys {
+		keys = keys[:s.MaxKeys]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[len(keys)-1]
+	}
+	for _, k := range keys {
+		o := objects[k]
+		result.Contents = append(result.Contents, content{Key: k, LastModified: o.LastModified.Format(time.RFC3339), ETag: `"` + o.ETag + `"`, Size: len(o.Data)})
+	}
+	result.KeyCount = len(keys)
+	writeXML(w, result)
+}
+
+func (s *Server) complete(w http.ResponseWriter, objects map[string]*Object, key, id string, body []byte) {
+	parts, ok := s.uploads[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchUpload", "unknown upload")
+		return
+	}
+	var req struct {
+		Parts []struct {
+			PartNumber int
+			ETag       string
+		} `xml:"Part"`
+	}
+	if err := xml.Unmarshal(body, &req); err != nil || len(req.Parts) == 0 {
+		writeError(w, http.StatusBadRequest, "MalformedXML", "bad part list")
+		return
+	}
+	var data, sums []byte
+	for i, p := range req.Parts {
+		part, ok := parts[p.PartNumber]
+		if !ok || p.PartNumber != i+1 || strings.Trim(p.ETag, `"`) != md5Hex(part) {
+			writeError(w, http.StatusBadRequest, "InvalidPart", fmt.Sprintf("part %d", p.PartNumber))
+			return
+		}
+		data = append(data, part...)
+		sum := md5.Sum(part)
+		sums = append(sums, sum[:]...)
+	}
+	delete(s.uploads, id)
+	etag := fmt.Sprintf("%s-%d", md5Hex(sums), len(req.Parts))
+	objects[key] = &Object{Data: data, ETag: etag, LastModified: time.Now().UTC().Truncate(time.Second)}
+	writeXML(w, struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		Key     string
+		ETag    string
+	}{Key: key, ETag: `"` + etag + `"`})
+}
+
+// Uploads is the number of multipart uploads neither completed nor
+// aborted.
+func (s *Server) Uploads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.uploads)
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+		Message string
+	}{Code: code, Message: msg})
+}
+
+func md5Hex(b []byte) string {
+	sum := md5.Sum(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,133 @@
+package credential
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/config"
+)
+
+// execCacheSkew keeps a cached credential from being handed out in its last
+// seconds, matching the buffer Credentials.IsAccessTokenValid uses, so kubectl
+// never receives a token that expires on the way to the API server.
+const execCacheSkew = 30 * time.Second
+
+// cachedExec is one minted ExecCredential, kept in memory and in
+// config.RuntimeDir. Only the short-lived access token is stored there —
+// never the refresh token — and outside the home directory, so an encrypted
+// credential store does not gain a plaintext copy in its backups.
+type cachedExec struct {
+	Credential *ExecCredential `json:"credential"`
+	Expiry     time.Time       `json:"expiry"`
+
+	// Source is the fingerprint of the credential file the token came from.
+	// A new login, a refresh by another process, or a logout all change or
+	// remove that file, which retires the cache entry without decrypting
+	// anything.
+	Source string `json:"source"`
+}
+
+func (c *cachedExec) usable(source string) bool {
+	return c != nil && c.Credential != nil &&
+		c.Source == source &&
+		time.Now().Add(execCacheSkew).Before(c.Expiry)
+}
+
+func cacheKey(server, realm string) string {
+	return server + "\x00" + realm
+}
+
+func execCachePath(server, realm string) string {
+	h := sha256.Sum256([]byte(cacheKey(server, realm)))
+	return filepath.Join(config.RuntimeDir(), "exec-"+hex.EncodeToString(h[:8])+".json")
+}
+
+// cachedCredential returns a still-valid credential minted earlier by this or
+// another plugin process, or nil. It costs two small reads, which is
+// what keeps a burst of kubectl calls from each loading — and possibly
+// decrypting — the credential file.
+func (p *Provider) cachedCredential(server, realm string) *ExecCredential {
+	source, err := p.credMgr.Fingerprint(server, realm)
+	if err != nil {
+		// Not logged in: let the slow path produce the real error.
+		return nil
+	}
+	key := cacheKey(server, realm)
+
+	p.mu.Lock()
+	entry := p.memo[key]
+	p.mu.Unlock()
+	if entry.usable(source) {
+		return entry.Credential
+	}
+
+	// Only read the cache from a RuntimeDir the user owns and nobody else
+	// can enter; in the temp-dir fallback anyone could have created it.
+	if _, err := config.CheckRuntimeDir(); err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(execCachePath(server, realm))
+	if err != nil {
+		return nil
+	}
+	var disk cachedExec
+	if json.Unmarshal(data, &disk) != nil || !disk.usable(source) {
+		return nil
+	}
+	p.remember(key, &disk)
+	return disk.Credential
+}
+
+// storeCredential records cred for later calls. The disk write is best
+// effort: without it every process simply loads the credential file itself.
+func (p *Provider) storeCredential(server, realm string, cred *ExecCredential, expiry time.Time) {
+	source, err := p.credMgr.Fingerprint(server, realm)
+	if err != nil {
+		return
+	}
+	entry := &cachedExec{Credential: cred, Expiry: expiry, Source: source}
+	p.remember(cacheKey(server, realm), entry)
+
+	dir, err := config.EnsureRuntimeDir()
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	// Write-then-rename so a concurrent reader sees the old entry or the new
+	// one, never half of one.
+	tmp, err := os.CreateTemp(dir, "exec-*.tmp")
+	if err != nil {
+		return
+	}
+	_, werr := tmp.Write(data)
+	cerr := tmp.Close()
+	if werr != nil || cerr != nil || os.Rename(tmp.Name(), execCachePath(server, realm)) != nil {
+		_ = os.Remove(tmp.Name())
+	}
+}
+
+func (p *Provider) remember(key string, entry *cachedExec) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.memo == nil {
+		p.memo = map[string]*cachedExec{}
+	}
+	p.memo[key] = entry
+}
+
+// Forget drops the cached ExecCredential for server/realm. Logout calls it so
+// the last access token does not linger in the runtime directory after the
+// session behind it has been revoked.
+func Forget(server, realm string) error {
+	if err := os.Remove(execCachePath(server, realm)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
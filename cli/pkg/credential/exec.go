@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"sync"
 	"time"
 
 	"github.com/shalb/kube-dc/cli/internal/auth"
 	"github.com/shalb/kube-dc/cli/internal/config"
 	"github.com/shalb/kube-dc/cli/internal/jwt"
+	"golang.org/x/sync/singleflight"
 )
 
 // reloginCmd returns a copy-pasteable login command for the given
@@ -92,6 +94,10 @@ type ExecCredentialStatus struct {
 // Provider handles credential provisioning for kubectl
 type Provider struct {
 	credMgr *config.CredentialsManager
+
+	flight singleflight.Group
+	mu     sync.Mutex
+	memo   map[string]*cachedExec
 }
 
 // NewProvider creates a new credential provider
@@ -136,76 +142,57 @@ func (p *Provider) loadAndRefresh(server, realm string) (*config.Credentials, er
 	if creds.IsAccessTokenValid() {
 		return creds, nil
 	}
-	if creds.RefreshToken == "" {
-		return nil, fmt.Errorf("session expired (no refresh token). Run: %s", reloginCmd(server, creds.Realm))
-	}
-	newTokens, err := auth.RefreshToken(
-		creds.KeycloakURL,
-		creds.Realm,
-		creds.ClientID,
-		creds.RefreshToken,
-		creds.CACert,
-		creds.Insecure,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("session expired. Run: %s", reloginCmd(server, creds.Realm))
-	}
-	claims, err := jwt.ParseToken(newTokens.AccessToken)
+
+	// Refresh under a cross-process lock. kubectl starts one plugin process
+	// per request and tools like k9s and helm fan them out in parallel; Keycloak
+	// rotates refresh tokens, so a second process spending the token we just
+	// loaded gets invalid_grant and a forced browser login. Whoever held the
+	// lock before us has probably refreshed already — re-read and reuse theirs.
+	release, err := p.credMgr.Lock(server, realm)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse token: %w", err)
-	}
-	creds.AccessToken = newTokens.AccessToken
-	creds.RefreshToken = newTokens.RefreshToken
-	creds.AccessTokenExpiry = claims.ExpiryTime()
-	if newTokens.RefreshExpiresIn <= 0 {
-		creds.RefreshTokenExpiry = time.Now().Add(30 * 24 * time.Hour)
-	} else {
-		creds.RefreshTokenExpiry = time.Now().Add(time.Duration(newTokens.RefreshExpiresIn) * time.Second)
-	}
-	if newTokens.IDToken != "" {
-		creds.IDToken = newTokens.IDToken
-	}
-	creds.User.Email = claims.Email
-	creds.User.Org = claims.Org
-	creds.User.Groups = claims.Groups
-	creds.User.Namespaces = claims.Namespaces
-	if err := p.credMgr.Save(creds); err != nil {
-		// Log + continue: we still have a valid token in memory.
-		fmt.Printf("Warning: failed to cache refreshed credentials: %v\n", err)
+		return nil, err
 	}
-	return creds, nil
-}
-
-func (p *Provider) getCredential(server, realm string) (*ExecCredential, error) {
-	creds, err := p.credMgr.LoadForRealm(server, realm)
+	defer release()
+	creds, err = p.credMgr.LoadForRealm(server, realm)
 	if err != nil {
 		return nil, notLoggedInErr(server, realm)
 	}
-
-	// Check if access token is still valid
 	if creds.IsAccessTokenValid() {
-		return p.buildExecCredential(creds.AccessToken, creds.AccessTokenExpiry), nil
+		return creds, nil
 	}
 
-	// Access token expired, try to refresh
-	// Always try to refresh - let Keycloak be the source of truth for token validity
-	if creds.RefreshToken == "" {
+	// Access token expired. Spend the refresh token when there is one —
+	// Keycloak is the source of truth for whether it is still good. A
+	// service-account login usually has none (client_credentials never
+	// issues one) and re-mints from its stored grant instead; it also falls
+	// back to re-minting when the refresh is refused, since unlike a human it
+	// can always get a brand-new session.
+	var newTokens *auth.TokenResponse
+	switch {
+	case creds.RefreshToken != "":
+		newTokens, err = auth.RefreshToken(
+			creds.KeycloakURL,
+			creds.Realm,
+			creds.ClientID,
+			creds.RefreshToken,
+			creds.CACert,
+			creds.Insecure,
+		)
+		if err != nil && creds.ServiceAccount != nil {
+			newTokens, err = remint(creds)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("session expired. Run: %s", reloginCmd(server, creds.Realm))
+		}
+	case creds.ServiceAccount != nil:
+		newTokens, err = remint(creds)
+		if err != nil {
+			return nil, fmt.Errorf("service-account token re-mint failed: %w", err)
+		}
+	default:
 		return nil, fmt.Errorf("session expired (no refresh token). Run: %s", reloginCmd(server, creds.Realm))
 	}
 
-	// Refresh the token
-	newTokens, err := auth.RefreshToken(
-		creds.KeycloakURL,
-		creds.Realm,
-		creds.ClientID,
-		creds.RefreshToken,
-		creds.CACert,
-		creds.Insecure,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("session expired. Run: %s", reloginCmd(server, creds.Realm))
-	}
-
 	// Parse the new access token to get expiry and user info
 	claims, err := jwt.ParseToken(newTokens.AccessToken)
 	if err != nil {
@@ -216,11 +203,14 @@ func (p *Provider) getCredential(server, realm string) (*ExecCredential, error)
 	creds.AccessToken = newTokens.AccessToken
 	creds.RefreshToken = newTokens.RefreshToken
 	creds.AccessTokenExpiry = claims.ExpiryTime()
-	// For offline tokens, RefreshExpiresIn is 0 which means "never expires"
-	// We set a reasonable default of 30 days (standard for CLI tools)
-	if newTokens.RefreshExpiresIn <= 0 {
-		creds.RefreshTokenExpiry = time.Now().Add(30 * 24 * time.Hour)
-	} else {
+	// Only a lifetime Keycloak reported moves RefreshTokenExpiry. A
+	// re-mint without a refresh token leaves it zero (as login does); an
+	// offline token (RefreshExpiresIn 0) keeps the expiry set at login
+	// rather than inventing a new one.
+	switch {
+	case newTokens.RefreshToken == "":
+		creds.RefreshTokenExpiry = time.Time{}
+	case newTokens.RefreshExpiresIn > 0:
 		creds.RefreshTokenExpiry = time.Now().Add(time.Duration(newTokens.RefreshExpiresIn) * time.Second)
 	}
 	if newTokens.IDToken != "" {
@@ -237,8 +227,45 @@ func (p *Provider) getCredential(server, realm string) (*ExecCredential, error)
 		// Log warning but don't fail - we still have a valid token
 		fmt.Printf("Warning: failed to cache refreshed credentials: %v\n", err)
 	}
+	return creds, nil
+}
+
+func (p *Provider) getCredential(server, realm string) (*ExecCredential, error) {
+	if cred := p.cachedCredential(server, realm); cred != nil {
+		return cred, nil
+	}
+	// Concurrent callers in this process share one load-and-refresh; the file
+	// lock in loadAndRefresh covers callers in other processes.
+	v, err, _ := p.flight.Do(cacheKey(server, realm), func() (any, error) {
+		creds, err := p.loadAndRefresh(server, realm)
+		if err != nil {
+			return nil, err
+		}
+		cred := p.buildExecCredential(creds.AccessToken, creds.AccessTokenExpiry)
+		p.storeCredential(server, realm, cred, creds.AccessTokenExpiry)
+		return cred, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*ExecCredential), nil
+}
 
-	return p.buildExecCredential(creds.AccessToken, creds.AccessTokenExpiry), nil
+// remint obtains a brand-new token for a service-account login by replaying
+// the grant it was created with. The secret and subject token are re-read
+// from their files, so a rotated secret or a fresh CI ID token is used.
+func remint(creds *config.Credentials) (*auth.TokenResponse, error) {
+	sa := creds.ServiceAccount
+	return auth.ServiceAccountToken(&auth.ServiceAccountConfig{
+		KeycloakURL:      creds.KeycloakURL,
+		Realm:            creds.Realm,
+		ClientID:         creds.ClientID,
+		ClientSecretFile: sa.ClientSecretFile,
+		SubjectTokenFile: sa.SubjectTokenFile,
+		SubjectIssuer:    sa.SubjectIssuer,
+		CACert:           creds.CACert,
+		Insecure:         creds.Insecure,
+	})
 }
 
 // buildExecCredential creates the ExecCredential JSON structure
@@ -0,0 +1,213 @@
+package credential
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/config"
+)
+
+// rotatingKeycloak behaves like a realm with refresh-token rotation: every
+// refresh returns a new refresh token and retires the one it was given, so a
+// second spend of the same token gets invalid_grant — the failure concurrent
+// kubectl calls used to trigger.
+type rotatingKeycloak struct {
+	t       *testing.T
+	mu      sync.Mutex
+	current string
+	spent   int
+	reused  int
+}
+
+func (k *rotatingKeycloak) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	// Widen the window in which an unlocked second process would load the
+	// same, about-to-be-spent refresh token.
+	time.Sleep(50 * time.Millisecond)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if r.PostForm.Get("refresh_token") != k.current {
+		k.reused++
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"invalid_grant","error_description":"Maximum allowed refresh token reuse exceeded"}`)
+		return
+	}
+	k.spent++
+	k.current = fmt.Sprintf("rt-%d", k.spent)
+	fmt.Fprintf(w, `{"access_token":%q,"refresh_token":%q,"expires_in":300}`,
+		fakeJWT(k.t, time.Now().Add(5*time.Minute)), k.current)
+}
+
+func setupExpiredLogin(t *testing.T, keycloakURL string) string {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	t.Setenv("REFRESH_TOKEN", "")
+	t.Setenv("SERVER_ENDPOINT", "")
+
+	mgr, err := config.NewCredentialsManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := "https://kube-api.example.com:6443"
+	if err := mgr.Save(&config.Credentials{
+		Server:            server,
+		KeycloakURL:       keycloakURL,
+		Realm:             "acme",
+		ClientID:          "kube-dc",
+		AccessToken:       fakeJWT(t, time.Now().Add(-time.Minute)),
+		RefreshToken:      "rt-0",
+		AccessTokenExpiry: time.Now().Add(-time.Minute),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+func TestGetCredential_ConcurrentCallsRefreshOnce(t *testing.T) {
+	kc := &rotatingKeycloak{t: t, current: "rt-0"}
+	srv := httptest.NewServer(kc)
+	defer srv.Close()
+	server := setupExpiredLogin(t, srv.URL)
+
+	// One Provider per goroutine stands in for one plugin process each: they
+	// share nothing but the files on disk.
+	const calls = 8
+	tokens := make([]string, calls)
+	errs := make([]error, calls)
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p, err := NewProvider()
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			cred, err := p.GetCredentialForRealm(server, "acme")
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			tokens[i] = cred.Status.Token
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("call %d: %v", i, err)
+		}
+	}
+	if kc.spent != 1 || kc.reused != 0 {
+		t.Errorf("refreshes = %d, reused refresh tokens = %d; want exactly one refresh and no reuse", kc.spent, kc.reused)
+	}
+	for i := range tokens {
+		if tokens[i] != tokens[0] {
+			t.Errorf("call %d got a different access token than call 0", i)
+		}
+	}
+}
+
+func TestGetCredential_ConcurrentCallsInOneProcessShareARefresh(t *testing.T) {
+	kc := &rotatingKeycloak{t: t, current: "rt-0"}
+	srv := httptest.NewServer(kc)
+	defer srv.Close()
+	server := setupExpiredLogin(t, srv.URL)
+
+	p, err := NewProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.GetCredentialForRealm(server, "acme"); err != nil {
+				t.Errorf("GetCredentialForRealm: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if kc.spent != 1 {
+		t.Errorf("refreshes = %d, want 1", kc.spent)
+	}
+}
+
+// The exec credential cache must never outlive the login it came from: a
+// re-login as someone else has to take effect on the next kubectl call.
+func TestGetCredential_CacheFollowsTheCredentialFile(t *testing.T) {
+	kc := &rotatingKeycloak{t: t, current: "rt-0"}
+	srv := httptest.NewServer(kc)
+	defer srv.Close()
+	server := setupExpiredLogin(t, srv.URL)
+
+	p, err := NewProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := p.GetCredentialForRealm(server, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A second Provider (another process) is served from the disk cache.
+	again, err := (&Provider{credMgr: p.credMgr}).GetCredentialForRealm(server, "acme")
+	if err != nil || again.Status.Token != first.Status.Token {
+		t.Fatalf("second process: token changed or err %v", err)
+	}
+	if kc.spent != 1 {
+		t.Errorf("refreshes = %d, want 1", kc.spent)
+	}
+
+	relogin := fakeJWT(t, time.Now().Add(10*time.Minute))
+	creds, err := p.credMgr.LoadForRealm(server, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds.AccessToken, creds.AccessTokenExpiry = relogin, time.Now().Add(10*time.Minute)
+	if err := p.credMgr.Save(creds); err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.GetCredentialForRealm(server, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.Token != relogin {
+		t.Errorf("served the cached token from before the re-login")
+	}
+}
+
+// A cache directory someone else could have created or entered is not read:
+// the next process goes back to the credential file instead.
+func TestGetCredential_CacheIgnoredInOpenRuntimeDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("mode bits are not enforced on Windows")
+	}
+	kc := &rotatingKeycloak{t: t, current: "rt-0"}
+	srv := httptest.NewServer(kc)
+	defer srv.Close()
+	server := setupExpiredLogin(t, srv.URL)
+
+	p, err := NewProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.GetCredentialForRealm(server, "acme"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(config.RuntimeDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if cred := (&Provider{credMgr: p.credMgr}).cachedCredential(server, "acme"); cred != nil {
+		t.Errorf("served a cached credential from a RuntimeDir others can enter")
+	}
+}
@@ -0,0 +1,109 @@
+package credential
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shalb/kube-dc/cli/internal/auth"
+	"github.com/shalb/kube-dc/cli/internal/config"
+)
+
+func fakeJWT(t *testing.T, exp time.Time) string {
+	t.Helper()
+	payload, err := json.Marshal(map[string]any{"exp": exp.Unix(), "sub": "service-account-ci", "namespaces": []string{"acme-web"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := base64.RawURLEncoding.EncodeToString
+	return enc([]byte(`{"alg":"none"}`)) + "." + enc(payload) + ".sig"
+}
+
+// A client_credentials login has no refresh token. Once its access token
+// expires the provider must replay the grant from the cached file paths
+// rather than tell a CI job to run an interactive login.
+func TestLoadAndRefresh_ReMintsServiceAccountWithoutRefreshToken(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("REFRESH_TOKEN", "")
+	t.Setenv("SERVER_ENDPOINT", "")
+
+	fresh := fakeJWT(t, time.Now().Add(5*time.Minute))
+	var grants []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		grants = append(grants, r.PostForm.Get("grant_type"))
+		if r.PostForm.Get("client_secret") != "s3cret" {
+			http.Error(w, `{"error":"unauthorized_client"}`, http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token":%q,"expires_in":300}`, fresh)
+	}))
+	defer srv.Close()
+
+	secret := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secret, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := "https://kube-api.example.com:6443"
+	if err := p.credMgr.Save(&config.Credentials{
+		Server:            server,
+		KeycloakURL:       srv.URL,
+		Realm:             "acme",
+		ClientID:          "ci-deployer",
+		AccessToken:       fakeJWT(t, time.Now().Add(-time.Minute)),
+		AccessTokenExpiry: time.Now().Add(-time.Minute),
+		ServiceAccount: &config.ServiceAccount{
+			GrantType:        auth.GrantClientCredentials,
+			ClientSecretFile: secret,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	cred, err := p.GetCredentialForRealm(server, "acme")
+	if err != nil {
+		t.Fatalf("GetCredentialForRealm: %v", err)
+	}
+	if cred.Status.Token != fresh {
+		t.Errorf("token was not re-minted")
+	}
+	if len(grants) != 1 || grants[0] != auth.GrantClientCredentials {
+		t.Errorf("grants sent = %v, want one client_credentials", grants)
+	}
+
+	cached, err := p.credMgr.LoadForRealm(server, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached.AccessToken != fresh || cached.ServiceAccount == nil {
+		t.Errorf("re-minted token must be cached with its service-account config kept: %+v", cached)
+	}
+	if !cached.RefreshTokenExpiry.IsZero() {
+		t.Errorf("RefreshTokenExpiry = %v, want zero: the grant returned no refresh token", cached.RefreshTokenExpiry)
+	}
+
+	// A broken secret surfaces as a re-mint failure, not a "run kube-dc login"
+	// hint that a pipeline cannot follow.
+	cached.AccessTokenExpiry = time.Now().Add(-time.Minute)
+	if err := p.credMgr.Save(cached); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(secret, []byte("rotated-away"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.GetCredentialForRealm(server, "acme"); err == nil || !strings.Contains(err.Error(), "re-mint") {
+		t.Errorf("err = %v, want a service-account re-mint failure", err)
+	}
+}